@@ -14,12 +14,14 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/FocuswithJustin/JuniperBible/core/detect"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 
 	// Import embedded plugins registry to register all embedded plugins
@@ -57,6 +59,7 @@ func main() {
 func runDetect(args []string) {
 	fs := flag.NewFlagSet("detect", flag.ExitOnError)
 	pluginDir := fs.String("plugin-dir", "", "Path to plugin directory (default: embedded plugins)")
+	depth := fs.Int("depth", detect.DefaultDepth, "Levels of nested containers to detect (0 for none)")
 	fs.Parse(args)
 
 	if len(fs.Args()) < 1 {
@@ -85,27 +88,33 @@ func runDetect(args []string) {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Detecting format of: %s\n\n", path)
-
-	for _, p := range formatPlugins {
-		req := plugins.NewDetectRequest(path)
-		resp, err := plugins.ExecutePlugin(p, req)
-		if err != nil {
-			fmt.Printf("  %s: error (%v)\n", p.Manifest.PluginID, err)
-			continue
-		}
+	detector := detect.NewWithPlugins(formatPlugins)
+	detector.Depth = *depth
+	result, err := detector.Detect(context.Background(), path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-		result, err := plugins.ParseDetectResult(resp)
-		if err != nil {
-			fmt.Printf("  %s: parse error (%v)\n", p.Manifest.PluginID, err)
-			continue
-		}
+	fmt.Printf("Detecting format of: %s\n\n", path)
+	printDetection(result, "")
+}
 
-		if result.Detected {
-			fmt.Printf("  [MATCH] %s: %s\n", p.Manifest.PluginID, result.Reason)
-		} else {
-			fmt.Printf("  [no]    %s: %s\n", p.Manifest.PluginID, result.Reason)
-		}
+// printDetection prints the ranked candidates of a detection and, indented,
+// those of a container's entries.
+func printDetection(result *detect.Result, indent string) {
+	if len(result.Candidates) == 0 {
+		fmt.Printf("%s  no format detected\n", indent)
+	}
+	for i, c := range result.Candidates {
+		fmt.Printf("%s  %d. %s (confidence %.2f, specificity %d): %s\n", indent, i+1, c.PluginID, c.Confidence, c.Specificity, c.Reason)
+	}
+	for _, nested := range result.Nested {
+		fmt.Printf("%s  %s:\n", indent, nested.Path)
+		printDetection(nested, indent+"    ")
+	}
+	if result.Truncated {
+		fmt.Printf("%s  ... more entries not detected\n", indent)
 	}
 }
 
@@ -149,26 +158,14 @@ func runConvert(args []string) {
 	fmt.Println()
 
 	// Step 1: Detect source format
-	formatPlugins := loader.GetPluginsByKind("format")
-	var sourcePlugin *plugins.Plugin
-	for _, p := range formatPlugins {
-		req := plugins.NewDetectRequest(inputPath)
-		resp, err := plugins.ExecutePlugin(p, req)
-		if err != nil {
-			continue
-		}
-		result, err := plugins.ParseDetectResult(resp)
-		if err != nil || !result.Detected {
-			continue
-		}
-		sourcePlugin = p
-		break
-	}
-
-	if sourcePlugin == nil {
+	detector := detect.New(loader)
+	detector.Depth = 0
+	detection, err := detector.Detect(context.Background(), inputPath)
+	if err != nil || detection.Best() == nil {
 		fmt.Fprintf(os.Stderr, "Error: could not detect source format\n")
 		os.Exit(1)
 	}
+	sourcePlugin := detection.Best().Plugin
 
 	fmt.Printf("Detected source format: %s\n", sourcePlugin.Manifest.PluginID)
 
@@ -468,6 +465,7 @@ Commands:
 
 Options for 'detect':
   --plugin-dir  Path to plugin directory (default: embedded plugins)
+  --depth       Levels of nested containers to detect (default: 2)
 
 Options for 'convert':
   --to          Target format (required)
@@ -4,7 +4,7 @@
 // Usage:
 //
 //	capsule-juniper list [--path ~/.sword]
-//	capsule-juniper ingest --all [--path ~/.sword] [--output ./capsules]
+//	capsule-juniper ingest --all [--path ~/.sword] [--output ./capsules] [--recipient age1...]
 //	capsule-juniper cas-to-sword capsule.tar.gz [--output ~/.sword] [--name MODULE]
 //	capsule-juniper repoman <command> [options]
 //
@@ -16,6 +16,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/FocuswithJustin/JuniperBible/internal/juniper"
 	"github.com/FocuswithJustin/JuniperBible/internal/juniper/repoman"
@@ -68,13 +69,16 @@ func runIngest(args []string) {
 	path := fs.String("path", "", "Path to SWORD installation (default: ~/.sword)")
 	output := fs.String("output", "capsules", "Output directory for capsules")
 	all := fs.Bool("all", false, "Ingest all Bible modules")
+	var recipients recipientList
+	fs.Var(&recipients, "recipient", "Archive encrypted modules encrypted to this age recipient (repeatable)")
 	fs.Parse(args)
 
 	cfg := juniper.IngestConfig{
-		Path:    *path,
-		Output:  *output,
-		All:     *all,
-		Modules: fs.Args(),
+		Path:       *path,
+		Output:     *output,
+		All:        *all,
+		Modules:    fs.Args(),
+		Recipients: recipients,
 	}
 	if err := juniper.Ingest(cfg); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -82,6 +86,16 @@ func runIngest(args []string) {
 	}
 }
 
+// recipientList collects repeated --recipient flags.
+type recipientList []string
+
+func (r *recipientList) String() string { return strings.Join(*r, ",") }
+
+func (r *recipientList) Set(v string) error {
+	*r = append(*r, v)
+	return nil
+}
+
 func runInstall(args []string) {
 	fs := flag.NewFlagSet("install", flag.ExitOnError)
 	path := fs.String("path", "", "Path to SWORD installation (default: ~/.sword)")
@@ -392,6 +406,7 @@ Options for 'ingest':
   --path        Path to SWORD installation (default: ~/.sword)
   --output      Output directory for capsules (default: capsules)
   --all         Ingest all Bible modules
+  --recipient   Archive encrypted modules encrypted to this age recipient (repeatable)
 
 Options for 'install':
   --path        Path to SWORD installation (default: ~/.sword)
@@ -6,6 +6,7 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,18 +14,27 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/alecthomas/kong"
 
+	"github.com/FocuswithJustin/JuniperBible/core/audit"
 	"github.com/FocuswithJustin/JuniperBible/core/capsule"
 	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/conformance"
+	"github.com/FocuswithJustin/JuniperBible/core/detect"
 	"github.com/FocuswithJustin/JuniperBible/core/docgen"
+	"github.com/FocuswithJustin/JuniperBible/core/golden"
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/license"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+	"github.com/FocuswithJustin/JuniperBible/core/reporter"
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
 	"github.com/FocuswithJustin/JuniperBible/core/runner"
 	"github.com/FocuswithJustin/JuniperBible/core/selfcheck"
 	"github.com/FocuswithJustin/JuniperBible/internal/archive"
@@ -44,6 +54,14 @@ const version = "0.3.0"
 var CLI struct {
 	// Global flags
 	PluginDir string `name:"plugin-dir" short:"p" help:"Plugin directory path" type:"path"`
+	Identity  string `name:"identity" short:"i" env:"CAPSULE_IDENTITY" help:"Identity file for decrypting encrypted capsules" type:"path"`
+
+	LicensePolicy string `name:"license-policy" env:"CAPSULE_LICENSE_POLICY" help:"License policy file (default: built-in policy)" type:"path"`
+	LicenseLog    string `name:"license-log" env:"CAPSULE_LICENSE_LOG" help:"License override log (default: <user config dir>/juniper/license-overrides.jsonl)" type:"path"`
+
+	PluginTrust       string   `name:"plugin-trust" env:"CAPSULE_PLUGIN_TRUST" enum:"all,signed,publishers" default:"all" help:"External plugins to trust: all, signed (by a publisher in the trust store) or publishers (listed with --trusted-publisher)"`
+	TrustedPublishers []string `name:"trusted-publisher" env:"CAPSULE_TRUSTED_PUBLISHERS" help:"Publisher allowed by --plugin-trust=publishers (repeatable)"`
+	TrustStore        string   `name:"trust-store" env:"CAPSULE_TRUST_STORE" help:"Plugin publisher trust store (default: <user config dir>/juniper/plugin-trust.json)" type:"path"`
 
 	// Command groups (noun-first organization)
 	Capsule CapsuleGroup `cmd:"" help:"Capsule operations (ingest, export, verify, enumerate)"`
@@ -60,12 +78,23 @@ var CLI struct {
 
 // CapsuleGroup contains capsule lifecycle operations.
 type CapsuleGroup struct {
-	Ingest    IngestCmd         `cmd:"" help:"Ingest a file into a new capsule"`
-	Export    ExportCmd         `cmd:"" help:"Export an artifact from a capsule"`
-	Verify    VerifyCmd         `cmd:"" help:"Verify capsule integrity"`
-	Selfcheck SelfcheckCmd      `cmd:"" help:"Run self-check verification plan"`
-	Enumerate EnumerateCmd      `cmd:"" help:"Enumerate contents of archive"`
-	Convert   CapsuleConvertCmd `cmd:"" help:"Convert capsule content to different format"`
+	Ingest     IngestCmd            `cmd:"" help:"Ingest a file into a new capsule"`
+	Export     ExportCmd            `cmd:"" help:"Export an artifact from a capsule"`
+	Verify     VerifyCmd            `cmd:"" help:"Verify capsule integrity"`
+	Selfcheck  SelfcheckCmd         `cmd:"" help:"Run self-check verification plan"`
+	Enumerate  EnumerateCmd         `cmd:"" help:"Enumerate contents of archive"`
+	Convert    CapsuleConvertCmd    `cmd:"" help:"Convert capsule content to different format"`
+	Repack     CapsuleRepackCmd     `cmd:"" help:"Repack capsules into a different container (e.g. random-access zip)"`
+	Log        CapsuleLogCmd        `cmd:"" help:"Show capsule revision history"`
+	Diff       CapsuleDiffCmd       `cmd:"" help:"Show differences between two capsules or revisions"`
+	Keygen     CapsuleKeygenCmd     `cmd:"" help:"Generate an identity for encrypted capsules"`
+	Encrypt    CapsuleEncryptCmd    `cmd:"" help:"Encrypt capsule blobs to recipients"`
+	Decrypt    CapsuleDecryptCmd    `cmd:"" help:"Decrypt capsule blobs with an identity"`
+	Recipients CapsuleRecipientsCmd `cmd:"" help:"List the recipients of an encrypted capsule"`
+	Preserve   CapsulePreserveCmd   `cmd:"" help:"Export a capsule as an OCFL object or BagIt bag"`
+	Import     CapsuleImportCmd     `cmd:"" help:"Import a capsule from an OCFL object or BagIt bag"`
+	Fsck       CapsuleFsckCmd       `cmd:"" help:"Check capsule consistency and repair blobs from other copies"`
+	Audit      CapsuleAuditCmd      `cmd:"" help:"Run a fixity audit over a capsule library"`
 }
 
 // FormatGroup contains format detection and IR operations.
@@ -85,14 +114,19 @@ type IRGroup struct {
 
 // PluginsGroup contains plugin management operations.
 type PluginsGroup struct {
-	List PluginsListCmd `cmd:"" help:"List available plugins"`
+	List   PluginsListCmd   `cmd:"" help:"List available plugins"`
+	Keygen PluginsKeygenCmd `cmd:"" help:"Generate a plugin signing key"`
+	Sign   PluginsSignCmd   `cmd:"" help:"Sign a plugin manifest and entrypoint"`
+	Trust  PluginsTrustCmd  `cmd:"" help:"Add a publisher key to the trust store"`
+
+	Conformance PluginsConformanceCmd `cmd:"" help:"Run the conformance suite against a format plugin"`
 }
 
 // ToolsGroup contains tool execution operations.
 type ToolsGroup struct {
 	List    ToolListCmd    `cmd:"" help:"List available tools in contrib/tool"`
 	Archive ToolArchiveCmd `cmd:"" help:"Create tool archive capsule from binaries"`
-	Run     RunCmd         `cmd:"" help:"Run a tool plugin with Nix executor"`
+	Run     RunCmd         `cmd:"" help:"Run a tool plugin with the Nix, sandbox or replay executor"`
 	Execute ToolRunCmd     `cmd:"" help:"Run tool on artifact and store transcript"`
 }
 
@@ -105,8 +139,10 @@ type RunsGroup struct {
 
 // GoldenGroup contains golden hash operations.
 type GoldenGroup struct {
-	Save  GoldenSaveCmd  `cmd:"" help:"Save golden transcript hash"`
-	Check GoldenCheckCmd `cmd:"" help:"Check transcript against golden hash"`
+	Save   GoldenSaveCmd   `cmd:"" help:"Save golden transcript hash"`
+	Update GoldenUpdateCmd `cmd:"" help:"Change golden transcript hash with a ledger entry"`
+	Check  GoldenCheckCmd  `cmd:"" help:"Check transcript against golden hash"`
+	Log    GoldenLogCmd    `cmd:"" help:"Show golden hash change ledger"`
 }
 
 // DevGroup contains development and maintenance tools.
@@ -171,9 +207,13 @@ func (c *IngestCmd) Run() error {
 
 // ExportCmd exports an artifact from a capsule.
 type ExportCmd struct {
-	Capsule  string `arg:"" help:"Path to capsule" type:"existingfile"`
-	Artifact string `required:"" help:"Artifact ID to export"`
-	Out      string `required:"" help:"Output path" type:"path"`
+	Capsule         string            `arg:"" help:"Path to capsule" type:"existingfile"`
+	Artifact        string            `required:"" help:"Artifact ID to export"`
+	Out             string            `required:"" help:"Output path" type:"path"`
+	Format          string            `help:"Export a derived format via IR and record its provenance in the capsule"`
+	Opt             map[string]string `help:"Option of the target format's emitter, with --format (repeatable)" placeholder:"KEY=VALUE"`
+	ExtractOpt      map[string]string `name:"extract-opt" help:"Option of the source format's IR extractor, with --format (repeatable)" placeholder:"KEY=VALUE"`
+	LicenseOverride string            `name:"license-override" help:"Reason for exporting despite the license policy (logged)"`
 }
 
 func (c *ExportCmd) Run() error {
@@ -194,6 +234,18 @@ func (c *ExportCmd) Run() error {
 		return fmt.Errorf("failed to unpack capsule: %w", err)
 	}
 
+	action := license.ActionExport
+	if c.Format != "" {
+		action = license.ActionForFormat(c.Format)
+	}
+	if err := enforceLicense(filepath.Base(capsulePath), cap.Manifest.License, action, c.LicenseOverride); err != nil {
+		return err
+	}
+
+	if c.Format != "" {
+		return c.exportDerived(cap)
+	}
+
 	// Export the artifact
 	if err := cap.Export(artifactID, capsule.ExportModeIdentity, outputPath); err != nil {
 		return fmt.Errorf("failed to export artifact: %w", err)
@@ -217,9 +269,59 @@ func (c *ExportCmd) Run() error {
 	return nil
 }
 
+// exportDerived converts the artifact to the requested format and stores the
+// output, export record and provenance statement as a new capsule revision.
+func (c *ExportCmd) exportDerived(cap *capsule.Capsule) error {
+	container, err := capsule.DetectCompression(c.Capsule)
+	if err != nil {
+		return fmt.Errorf("failed to detect compression: %w", err)
+	}
+
+	loader := plugins.NewLoader()
+	if err := loader.LoadFromDir(getPluginDir()); err != nil {
+		return fmt.Errorf("failed to load plugins: %w", err)
+	}
+
+	var result *capsule.DerivedExportResult
+	_, err = cap.Amend(capsule.AmendOptions{
+		Message: fmt.Sprintf("derived export of %s to %s", c.Artifact, c.Format),
+	}, func(cap *capsule.Capsule) error {
+		var err error
+		result, err = cap.ExportDerived(c.Artifact, capsule.DerivedExportOptions{
+			TargetFormat:     c.Format,
+			PluginLoader:     loader,
+			ExtractOptions:   optionValues(c.ExtractOpt),
+			EmitOptions:      optionValues(c.Opt),
+			RecordProvenance: true,
+		}, c.Out)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export artifact: %w", err)
+	}
+
+	if err := cap.SaveManifest(); err != nil {
+		return fmt.Errorf("failed to save manifest: %w", err)
+	}
+	if err := cap.PackWithOptions(c.Capsule, &capsule.PackOptions{Compression: container}); err != nil {
+		return fmt.Errorf("failed to repack capsule: %w", err)
+	}
+
+	fmt.Printf("Exported: %s as %s\n", c.Artifact, c.Format)
+	fmt.Printf("  SHA-256: %s\n", result.OutputSHA256)
+	fmt.Printf("  Loss class: %s\n", result.CombinedLossClass)
+	fmt.Printf("  Output: %s\n", c.Out)
+	printOptions(result.EmitOptions)
+	fmt.Printf("  Provenance: %s (export %s)\n", result.Provenance.ID, result.ExportID)
+	return nil
+}
+
 // VerifyCmd verifies capsule integrity.
 type VerifyCmd struct {
-	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
+	Capsule    string `arg:"" help:"Path to capsule" type:"existingfile"`
+	Provenance bool   `help:"Also verify provenance statements of derived artifacts"`
+	Subject    string `help:"Trace a published file back to the provenance that produced it (implies --provenance)" type:"existingfile"`
+	Format     string `default:"text" enum:"text,junit,tap,sarif" help:"Output format (text, junit, tap, sarif)"`
 }
 
 func (c *VerifyCmd) Run() error {
@@ -238,50 +340,180 @@ func (c *VerifyCmd) Run() error {
 		return fmt.Errorf("failed to unpack capsule: %w", err)
 	}
 
-	fmt.Printf("Capsule: %s\n", capsulePath)
-	fmt.Printf("  Version: %s\n", cap.Manifest.CapsuleVersion)
-	fmt.Printf("  Created: %s\n", cap.Manifest.CreatedAt)
-	fmt.Printf("  Artifacts: %d\n", len(cap.Manifest.Artifacts))
+	// Structured formats replace the text output
+	var out io.Writer = os.Stdout
+	if reporter.IsStructured(c.Format) {
+		out = io.Discard
+	}
+	suite := reporter.NewSuite("verify " + filepath.Base(capsulePath))
+
+	fmt.Fprintf(out, "Capsule: %s\n", capsulePath)
+	fmt.Fprintf(out, "  Version: %s\n", cap.Manifest.CapsuleVersion)
+	fmt.Fprintf(out, "  Created: %s\n", cap.Manifest.CreatedAt)
+	fmt.Fprintf(out, "  Artifacts: %d\n", len(cap.Manifest.Artifacts))
+
+	if enc := cap.Manifest.Encryption; enc != nil {
+		fmt.Fprintf(out, "  Encryption: %s (%d recipient(s))\n", enc.Scheme, len(enc.Recipients))
+	}
 
 	// Verify each artifact
 	errors := 0
 	for id, artifact := range cap.Manifest.Artifacts {
-		data, err := cap.GetStore().Retrieve(artifact.PrimaryBlobSHA256)
-		if err != nil {
-			fmt.Printf("  [FAIL] %s: blob not found\n", id)
+		fail := func(message string) {
+			fmt.Fprintf(out, "  [FAIL] %s: %s\n", id, message)
 			errors++
+			suite.Add(verifyFailure(id, "verify.artifacts", "fixity", artifactFile(cap, id), message))
+		}
+
+		// Without keys, encrypted blobs are checked against their ciphertext hash
+		if record := cap.Manifest.Blobs.BySHA256[artifact.PrimaryBlobSHA256]; record != nil && record.Encryption != nil && !cap.HasIdentities() {
+			if err := cap.VerifyBlob(artifact.PrimaryBlobSHA256); err != nil {
+				fail(err.Error())
+				continue
+			}
+			fmt.Fprintf(out, "  [OK] %s (%d bytes, ciphertext verified)\n", id, record.Encryption.CiphertextSize)
+			suite.Add(reporter.Case{Name: id, ClassName: "verify.artifacts", Status: reporter.StatusPass})
+			continue
+		}
+
+		data, err := cap.ReadBlob(artifact.PrimaryBlobSHA256)
+		if err != nil {
+			fail("blob not found")
 			continue
 		}
 
 		hash := cas.Hash(data)
 		if hash != artifact.Hashes.SHA256 {
-			fmt.Printf("  [FAIL] %s: hash mismatch\n", id)
-			errors++
+			fail("hash mismatch")
 			continue
 		}
 
-		fmt.Printf("  [OK] %s (%d bytes)\n", id, len(data))
+		fmt.Fprintf(out, "  [OK] %s (%d bytes)\n", id, len(data))
+		suite.Add(reporter.Case{Name: id, ClassName: "verify.artifacts", Status: reporter.StatusPass})
+	}
+
+	if c.Provenance || c.Subject != "" {
+		n, err := c.verifyProvenance(cap, out, suite)
+		if err != nil {
+			return err
+		}
+		errors += n
+	}
+
+	if reporter.IsStructured(c.Format) {
+		if err := reporter.Write(os.Stdout, c.Format, "capsule", version, suite); err != nil {
+			return err
+		}
 	}
 
 	if errors > 0 {
 		return fmt.Errorf("verification failed: %d error(s)", errors)
 	}
 
-	fmt.Println("Verification passed!")
+	fmt.Fprintln(out, "Verification passed!")
 	return nil
 }
 
+// verifyProvenance verifies every provenance record and, if a subject file
+// was given, checks that it is attested. It returns the number of failures.
+func (c *VerifyCmd) verifyProvenance(cap *capsule.Capsule, out io.Writer, suite *reporter.Suite) (int, error) {
+	failures := 0
+	results := cap.VerifyProvenance()
+	fmt.Fprintf(out, "  Provenance: %d statement(s)\n", len(results))
+	for _, v := range results {
+		if !v.OK() {
+			message := strings.Join(v.Errors, "; ")
+			fmt.Fprintf(out, "  [FAIL] %s: %s\n", v.RecordID, message)
+			suite.Add(verifyFailure(v.RecordID, "verify.provenance", "provenance", v.RecordID, message))
+			failures++
+			continue
+		}
+		fmt.Fprintf(out, "  [OK] %s subject sha256:%s (builder %s)\n", v.RecordID, v.SubjectSHA256, v.Builder)
+		suite.Add(reporter.Case{Name: v.RecordID, ClassName: "verify.provenance", Status: reporter.StatusPass})
+	}
+
+	if c.Subject == "" {
+		return failures, nil
+	}
+
+	data, err := os.ReadFile(c.Subject)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read subject: %w", err)
+	}
+	records := cap.FindProvenanceBySubject(data)
+	if len(records) == 0 {
+		message := fmt.Sprintf("no provenance attests sha256:%s", cas.Hash(data))
+		fmt.Fprintf(out, "  [FAIL] %s: %s\n", c.Subject, message)
+		suite.Add(verifyFailure(c.Subject, "verify.subject", "provenance", c.Subject, message))
+		return failures + 1, nil
+	}
+	suite.Add(reporter.Case{Name: c.Subject, ClassName: "verify.subject", Status: reporter.StatusPass})
+	for _, v := range results {
+		if v.SubjectSHA256 != records[0].SubjectSHA256 {
+			continue
+		}
+		fmt.Fprintf(out, "  Subject %s produced by %s from:\n", c.Subject, v.Builder)
+		for _, m := range v.Materials {
+			fmt.Fprintf(out, "    %s\n", m)
+		}
+	}
+	return failures, nil
+}
+
+// verifyFailure returns a failed test case carrying a finding in file.
+func verifyFailure(name, className, rule, file, message string) reporter.Case {
+	return reporter.Case{
+		Name:      name,
+		ClassName: className,
+		Status:    reporter.StatusFail,
+		Message:   message,
+		Findings: []reporter.Finding{{
+			RuleID:  rule,
+			Level:   reporter.LevelError,
+			Message: fmt.Sprintf("%s: %s", name, message),
+			File:    file,
+		}},
+	}
+}
+
+// artifactFile names a capsule artifact, or a path within it, by the
+// artifact's original file name when known.
+func artifactFile(cap *capsule.Capsule, key string) string {
+	id, rest, _ := strings.Cut(key, "/")
+	artifact, ok := cap.Manifest.Artifacts[id]
+	if !ok || artifact.OriginalName == "" {
+		return key
+	}
+	if rest == "" {
+		return artifact.OriginalName
+	}
+	return artifact.OriginalName + "/" + rest
+}
+
 // SelfcheckCmd runs self-check verification plan.
 type SelfcheckCmd struct {
 	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
 	Plan    string `help:"Plan ID to run"`
 	JSON    bool   `help:"Output as JSON"`
+	Workers int    `short:"w" help:"Number of steps run concurrently (default: number of CPUs)" default:"0"`
+	Matrix  bool   `help:"Round-trip every IR plugin pair and report the measured loss matrix"`
+	Format  string `default:"text" enum:"text,json,junit,tap,sarif" help:"Output format (text, json, junit, tap, sarif)"`
+
+	Engine      string   `default:"plugin" enum:"plugin,nix,sandbox,replay" help:"Engine of RUN_TOOL steps (plugin, nix, sandbox, replay)"`
+	ToolRoot    string   `help:"Tool root with bin/ and lib/ for the sandbox engine" type:"existingdir"`
+	ToolArchive string   `help:"Tool archive capsule providing the sandbox tool root" type:"existingfile"`
+	ReplayFrom  []string `help:"Capsules whose recorded runs the replay engine serves, besides the checked capsule" type:"existingfile"`
+	Provenance  bool     `help:"Record provenance of plugin-emitted outputs and save it to the capsule"`
+	LimitFlags
 }
 
+// errNoProvenance ends a selfcheck amendment that recorded no provenance.
+var errNoProvenance = errors.New("no provenance recorded")
+
 func (c *SelfcheckCmd) Run() error {
 	capsulePath := c.Capsule
 	planID := c.Plan
-	jsonOutput := c.JSON
+	jsonOutput := c.JSON || c.Format == reporter.FormatJSON
 
 	// Create temporary directory for unpacking
 	tempDir, err := os.MkdirTemp("", "capsule-selfcheck-*")
@@ -296,6 +528,10 @@ func (c *SelfcheckCmd) Run() error {
 		return fmt.Errorf("failed to unpack capsule: %w", err)
 	}
 
+	if c.Matrix {
+		return c.runMatrix(cap)
+	}
+
 	// Determine which plan to run
 	var plan *selfcheck.Plan
 	if planID != "" {
@@ -324,6 +560,14 @@ func (c *SelfcheckCmd) Run() error {
 								OutputKey:  fmt.Sprintf("step_%d_output", i),
 							}
 						}
+						if s.RunTool != nil {
+							step.RunTool = &selfcheck.RunToolStep{
+								ToolPluginID: s.RunTool.ToolPluginID,
+								Profile:      s.RunTool.Profile,
+								Inputs:       s.RunTool.Inputs,
+								OutputKey:    fmt.Sprintf("step_%d_output", i),
+							}
+						}
 						plan.Steps = append(plan.Steps, step)
 					}
 					// Convert manifest checks to selfcheck checks
@@ -338,6 +582,12 @@ func (c *SelfcheckCmd) Run() error {
 								ArtifactB: ck.ByteEqual.ArtifactB,
 							}
 						}
+						if ck.TranscriptEqual != nil {
+							check.TranscriptEqual = &selfcheck.TranscriptEqualDef{
+								RunA: ck.TranscriptEqual.RunA,
+								RunB: ck.TranscriptEqual.RunB,
+							}
+						}
 						plan.Checks = append(plan.Checks, check)
 					}
 				}
@@ -359,13 +609,31 @@ func (c *SelfcheckCmd) Run() error {
 
 	// Execute the plan
 	executor := selfcheck.NewExecutor(cap)
-	report, err := executor.Execute(plan)
+	executor.Workers = c.Workers
+	if c.Engine != "plugin" {
+		engine := EngineFlags{Engine: c.Engine, ToolRoot: c.ToolRoot, ToolArchive: c.ToolArchive, ReplayFrom: c.ReplayFrom, LimitFlags: c.LimitFlags}
+		tools, cleanup, err := engine.executor(cap)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		executor.Tools = tools
+	} else if c.ToolRoot != "" || c.ToolArchive != "" || len(c.ReplayFrom) > 0 {
+		return fmt.Errorf("--tool-root, --tool-archive and --replay-from require --engine")
+	} else {
+		plugins.SetExternalPluginLimits(c.limits())
+	}
+	report, err := c.execute(cap, executor, plan)
 	if err != nil {
 		return fmt.Errorf("selfcheck execution failed: %w", err)
 	}
 
 	// Output results
-	if jsonOutput {
+	if reporter.IsStructured(c.Format) {
+		if err := writeSuite(c.Format, cap, report.Suite(plan)); err != nil {
+			return err
+		}
+	} else if jsonOutput {
 		data, err := report.ToJSON()
 		if err != nil {
 			return fmt.Errorf("failed to serialize report: %w", err)
@@ -376,7 +644,23 @@ func (c *SelfcheckCmd) Run() error {
 		fmt.Printf("  Plan: %s\n", report.PlanID)
 		fmt.Printf("  Status: %s\n", report.Status)
 		fmt.Printf("  Created: %s\n", report.CreatedAt)
+		if report.Engine != nil {
+			fmt.Printf("  Engine: %s\n", report.Engine.EngineID)
+		}
 		fmt.Println()
+		for _, step := range report.Steps {
+			label := step.Label
+			if label == "" {
+				label = step.Type
+			}
+			fmt.Printf("  step %d [%s] %s (%dms)\n", step.Index, strings.ToUpper(step.Status), label, step.DurationMS)
+			if step.Error != "" {
+				fmt.Printf("    %s\n", step.Error)
+			}
+		}
+		if len(report.Steps) > 0 {
+			fmt.Println()
+		}
 		for _, result := range report.Results {
 			status := "[PASS]"
 			if !result.Pass {
@@ -387,6 +671,9 @@ func (c *SelfcheckCmd) Run() error {
 				fmt.Printf("    Expected: %s\n", result.Expected.SHA256)
 				fmt.Printf("    Actual:   %s\n", result.Actual.SHA256)
 			}
+			if details, ok := result.Details.(map[string]string); ok && !result.Pass && details["error"] != "" {
+				fmt.Printf("    %s\n", details["error"])
+			}
 		}
 		fmt.Println()
 		if report.Status == selfcheck.StatusPass {
@@ -402,6 +689,143 @@ func (c *SelfcheckCmd) Run() error {
 	return nil
 }
 
+// execute runs the plan. With --provenance the recorded statements and
+// their blobs are stored as a new capsule revision and the capsule is
+// repacked in place.
+func (c *SelfcheckCmd) execute(cap *capsule.Capsule, executor *selfcheck.Executor, plan *selfcheck.Plan) (*selfcheck.Report, error) {
+	if !c.Provenance {
+		return executor.Execute(plan)
+	}
+
+	container, err := capsule.DetectCompression(c.Capsule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect compression: %w", err)
+	}
+
+	executor.RecordProvenance = true
+	var report *selfcheck.Report
+	_, err = cap.Amend(capsule.AmendOptions{
+		Message: fmt.Sprintf("selfcheck provenance of plan %s", plan.ID),
+	}, func(cap *capsule.Capsule) error {
+		var err error
+		if report, err = executor.Execute(plan); err != nil {
+			return err
+		}
+		if len(report.Provenance) == 0 {
+			return errNoProvenance
+		}
+		return nil
+	})
+	if errors.Is(err, errNoProvenance) {
+		return report, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cap.SaveManifest(); err != nil {
+		return nil, fmt.Errorf("failed to save manifest: %w", err)
+	}
+	if err := cap.PackWithOptions(c.Capsule, &capsule.PackOptions{Compression: container}); err != nil {
+		return nil, fmt.Errorf("failed to repack capsule: %w", err)
+	}
+	return report, nil
+}
+
+// runMatrix round-trips the capsule's sample artifacts through every pair
+// of IR-capable plugins and reports plugins exceeding their declared loss.
+func (c *SelfcheckCmd) runMatrix(cap *capsule.Capsule) error {
+	loader := plugins.NewLoader()
+	if err := loader.LoadFromDir(getPluginDir()); err != nil {
+		return fmt.Errorf("failed to load plugins: %w", err)
+	}
+
+	samples, err := selfcheck.MatrixSamples(cap, loader)
+	if err != nil {
+		return err
+	}
+	if len(samples) == 0 {
+		return fmt.Errorf("no artifact in the capsule can be read by an IR-capable plugin")
+	}
+
+	matrix, err := selfcheck.RunMatrix(cap, loader, samples, c.Workers)
+	if err != nil {
+		return fmt.Errorf("selfcheck matrix failed: %w", err)
+	}
+
+	if reporter.IsStructured(c.Format) {
+		if err := writeSuite(c.Format, cap, matrix.Suite()); err != nil {
+			return err
+		}
+	} else if c.JSON || c.Format == reporter.FormatJSON {
+		data, err := json.MarshalIndent(matrix, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize matrix: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("Loss Matrix (measured/declared)\n")
+		fmt.Printf("  Status: %s\n", matrix.Status)
+		fmt.Println()
+
+		cells := make(map[string]selfcheck.MatrixCell)
+		for _, cell := range matrix.Cells {
+			cells[cell.Source+"\x00"+cell.Target] = cell
+		}
+		fmt.Printf("  %-20s", "source \\ target")
+		for _, target := range matrix.Targets {
+			fmt.Printf(" %-14s", strings.TrimPrefix(target, "format."))
+		}
+		fmt.Println()
+		for _, source := range matrix.Sources {
+			fmt.Printf("  %-20s", strings.TrimPrefix(source, "format."))
+			for _, target := range matrix.Targets {
+				cell := cells[source+"\x00"+target]
+				entry := fmt.Sprintf("%s/%s", cell.Measured, cell.Declared)
+				if cell.Error != "" {
+					entry = "error"
+				} else if cell.Status != selfcheck.StatusPass {
+					entry += "!"
+				}
+				fmt.Printf(" %-14s", entry)
+			}
+			fmt.Println()
+		}
+
+		for _, cell := range matrix.Cells {
+			if cell.Error != "" {
+				fmt.Printf("\n  %s -> %s: %s\n", cell.Source, cell.Target, cell.Error)
+			}
+		}
+		if len(matrix.Violations) > 0 {
+			fmt.Println()
+			fmt.Println("Plugins exceeding their declared loss:")
+			for _, v := range matrix.Violations {
+				fmt.Printf("  %s (%s -> %s, %s): %s\n", v.PluginID, v.Source, v.Target, v.Stage, v.Reason)
+			}
+		}
+	}
+
+	if matrix.Status != selfcheck.StatusPass {
+		return fmt.Errorf("selfcheck matrix failed")
+	}
+	return nil
+}
+
+// writeSuite renders a suite in a structured output format, naming
+// capsule artifacts in findings by their original file names.
+func writeSuite(format string, cap *capsule.Capsule, suite *reporter.Suite) error {
+	for i := range suite.Cases {
+		for j := range suite.Cases[i].Findings {
+			f := &suite.Cases[i].Findings[j]
+			if f.File != "" {
+				f.File = artifactFile(cap, f.File)
+			}
+		}
+	}
+	return reporter.Write(os.Stdout, format, "capsule", version, suite)
+}
+
 // PluginsListCmd lists available plugins.
 type PluginsListCmd struct {
 	Dir string `help:"Plugin directory path" type:"path"`
@@ -418,37 +842,198 @@ func (c *PluginsListCmd) Run(ctx *kong.Context) error {
 		return fmt.Errorf("failed to load plugins: %w", err)
 	}
 
-	allPlugins := loader.ListPlugins()
-	if len(allPlugins) == 0 {
+	// Plugins in the directory are listed even when they are not loaded,
+	// so their signature status can be checked before enabling them
+	byID := make(map[string]*plugins.Plugin)
+	for _, p := range loader.ListPlugins() {
+		byID[p.Manifest.PluginID] = p
+	}
+	discovered, err := plugins.DiscoverPlugins(pluginDir)
+	if err != nil {
+		return fmt.Errorf("failed to discover plugins: %w", err)
+	}
+	for _, p := range discovered {
+		byID[p.Manifest.PluginID] = p
+	}
+	if len(byID) == 0 {
 		fmt.Printf("No plugins found in %s\n", pluginDir)
 		return nil
 	}
 
-	fmt.Printf("Plugins in %s:\n\n", pluginDir)
-
-	formatPlugins := loader.GetPluginsByKind("format")
-	if len(formatPlugins) > 0 {
-		fmt.Println("Format Plugins:")
-		for _, p := range formatPlugins {
-			fmt.Printf("  %s v%s\n", p.Manifest.PluginID, p.Manifest.Version)
+	fmt.Printf("Plugins in %s (trust policy: %s):\n\n", pluginDir, CLI.PluginTrust)
+	for _, group := range []struct{ kind, title string }{
+		{"format", "Format Plugins"},
+		{"tool", "Tool Plugins"},
+	} {
+		var ids []string
+		for id, p := range byID {
+			if p.Manifest.Kind == group.kind {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		sort.Strings(ids)
+		fmt.Printf("%s:\n", group.title)
+		for _, id := range ids {
+			p := byID[id]
+			status := p.VerifySignature().String()
+			if err := plugins.CheckPluginTrust(p); err != nil {
+				status += ", rejected by trust policy"
+			}
+			fmt.Printf("  %s v%s  [%s]\n", id, p.Manifest.Version, status)
 		}
 		fmt.Println()
 	}
 
-	toolPlugins := loader.GetPluginsByKind("tool")
-	if len(toolPlugins) > 0 {
-		fmt.Println("Tool Plugins:")
-		for _, p := range toolPlugins {
-			fmt.Printf("  %s v%s\n", p.Manifest.PluginID, p.Manifest.Version)
+	return nil
+}
+
+// PluginsKeygenCmd generates a publisher signing key.
+type PluginsKeygenCmd struct {
+	Output string `arg:"" help:"Private key file to create" type:"path"`
+}
+
+func (c *PluginsKeygenCmd) Run() error {
+	if _, err := os.Stat(c.Output); err == nil {
+		return fmt.Errorf("%s already exists", c.Output)
+	}
+	pub, key, err := plugins.GenerateSigningKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	if err := os.WriteFile(c.Output, plugins.MarshalSigningKey(key), 0600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+	fmt.Printf("Signing key written to %s\n", c.Output)
+	fmt.Printf("  Key ID:     %s\n", plugins.KeyID(pub))
+	fmt.Printf("  Public key: %s\n", base64.StdEncoding.EncodeToString(pub))
+	return nil
+}
+
+// PluginsSignCmd signs a plugin directory.
+type PluginsSignCmd struct {
+	Dir       string `arg:"" help:"Plugin directory containing plugin.json" type:"existingdir"`
+	Key       string `required:"" help:"Private key file from 'plugins keygen'" type:"existingfile"`
+	Publisher string `required:"" help:"Publisher name recorded in the signature"`
+}
+
+func (c *PluginsSignCmd) Run() error {
+	data, err := os.ReadFile(c.Key)
+	if err != nil {
+		return fmt.Errorf("failed to read key: %w", err)
+	}
+	key, err := plugins.ParseSigningKey(data)
+	if err != nil {
+		return err
+	}
+	sig, err := plugins.SignPlugin(c.Dir, c.Publisher, key)
+	if err != nil {
+		return fmt.Errorf("failed to sign plugin: %w", err)
+	}
+	fmt.Printf("Signed %s as %s (key %s)\n", c.Dir, sig.Publisher, sig.KeyID)
+	return nil
+}
+
+// PluginsTrustCmd adds a publisher key to the trust store.
+type PluginsTrustCmd struct {
+	Publisher string `arg:"" help:"Publisher name"`
+	PublicKey string `arg:"" help:"Base64 public key printed by 'plugins keygen'"`
+}
+
+func (c *PluginsTrustCmd) Run() error {
+	pub, err := plugins.ParsePublicKey(c.PublicKey)
+	if err != nil {
+		return err
+	}
+	path := trustStorePath()
+	if path == "" {
+		return fmt.Errorf("no trust store path; use --trust-store")
+	}
+	store, err := plugins.LoadTrustStore(path)
+	if err != nil {
+		return err
+	}
+	store.Add(c.Publisher, pub)
+	if err := store.Save(path); err != nil {
+		return err
+	}
+	fmt.Printf("Trusted %s (key %s) in %s\n", c.Publisher, plugins.KeyID(pub), path)
+	return nil
+}
+
+// PluginsConformanceCmd runs the conformance suite against a plugin.
+type PluginsConformanceCmd struct {
+	PluginID string        `arg:"" name:"plugin-id" help:"Format plugin ID, with or without the format. prefix"`
+	Dir      string        `help:"Plugin directory to find the external implementation in" type:"path"`
+	Fixtures string        `help:"Fixture directory with a subdirectory of samples per format" default:"testdata/fixtures/inputs" type:"path"`
+	Positive []string      `help:"Fixture the plugin must detect (repeatable; replaces the format's fixtures)" type:"existingpath"`
+	Negative []string      `help:"Fixture the plugin must not detect (repeatable; replaces the other formats' fixtures)" type:"existingpath"`
+	Impl     string        `default:"auto" enum:"auto,embedded,external" help:"Implementation to test: auto (every one found, comparing them), embedded or external"`
+	Timeout  time.Duration `default:"60s" help:"Timeout for each plugin call"`
+	Format   string        `default:"text" enum:"text,junit,tap,sarif" help:"Output format (text, junit, tap, sarif)"`
+}
+
+func (c *PluginsConformanceCmd) Run() error {
+	pluginDir := c.Dir
+	if pluginDir == "" {
+		pluginDir = getPluginDir()
+	}
+	kit, err := conformance.New(c.PluginID, pluginDir)
+	if err != nil {
+		return err
+	}
+	if err := kit.Select(c.Impl); err != nil {
+		return err
+	}
+	kit.Fixtures = c.Fixtures
+	kit.Positive = c.Positive
+	kit.Negative = c.Negative
+	kit.Timeout = c.Timeout
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	suite, err := kit.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("conformance suite failed to run: %w", err)
+	}
+
+	if reporter.IsStructured(c.Format) {
+		if err := reporter.Write(os.Stdout, c.Format, "capsule", version, suite); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("Conformance: %s\n", kit.PluginID)
+		fmt.Printf("  Fixtures: %s\n\n", kit.Fixtures)
+		class := ""
+		for _, tc := range suite.Cases {
+			if tc.ClassName != class {
+				class = tc.ClassName
+				fmt.Printf("%s:\n", strings.TrimPrefix(class, "conformance."+kit.PluginID+"."))
+			}
+			fmt.Printf("  [%s] %s\n", strings.ToUpper(tc.Status), tc.Name)
+			if tc.Message != "" {
+				fmt.Printf("    %s\n", tc.Message)
+			}
 		}
+		fmt.Println()
+		fmt.Printf("%d passed, %d failed, %d errors, %d skipped\n",
+			suite.Count(reporter.StatusPass), suite.Count(reporter.StatusFail),
+			suite.Count(reporter.StatusError), suite.Count(reporter.StatusSkipped))
 	}
 
+	if suite.Count(reporter.StatusFail)+suite.Count(reporter.StatusError) > 0 {
+		return fmt.Errorf("plugin %s failed conformance", kit.PluginID)
+	}
 	return nil
 }
 
 // DetectCmd detects file format using plugins.
 type DetectCmd struct {
-	Path string `arg:"" help:"Path to file to detect" type:"existingpath"`
+	Path  string `arg:"" help:"Path to file to detect" type:"existingpath"`
+	Depth int    `default:"2" help:"Levels of nested zip, tar and directory containers to detect (0 for none)"`
+	JSON  bool   `help:"Output the ranked detection as JSON"`
 }
 
 func (c *DetectCmd) Run(ctx *kong.Context) error {
@@ -468,30 +1053,58 @@ func (c *DetectCmd) Run(ctx *kong.Context) error {
 		return fmt.Errorf("no format plugins found")
 	}
 
-	fmt.Printf("Detecting format of: %s\n\n", path)
+	detector := detect.NewWithPlugins(formatPlugins)
+	detector.Depth = c.Depth
+	result, err := detector.Detect(context.Background(), path)
+	if err != nil {
+		return err
+	}
 
-	for _, p := range formatPlugins {
-		req := plugins.NewDetectRequest(path)
-		resp, err := plugins.ExecutePlugin(p, req)
+	if c.JSON {
+		data, err := json.MarshalIndent(result, "", "  ")
 		if err != nil {
-			fmt.Printf("  %s: error (%v)\n", p.Manifest.PluginID, err)
-			continue
+			return fmt.Errorf("failed to serialize detection: %w", err)
 		}
+		fmt.Println(string(data))
+		return nil
+	}
 
-		result, err := plugins.ParseDetectResult(resp)
-		if err != nil {
-			fmt.Printf("  %s: parse error (%v)\n", p.Manifest.PluginID, err)
-			continue
-		}
+	fmt.Printf("Detecting format of: %s\n\n", path)
+	printDetection(result, "")
+	return nil
+}
 
-		if result.Detected {
-			fmt.Printf("  [MATCH] %s: %s\n", p.Manifest.PluginID, result.Reason)
-		} else {
-			fmt.Printf("  [no]    %s: %s\n", p.Manifest.PluginID, result.Reason)
-		}
+// printDetection prints the ranked candidates of a detection and, indented,
+// those of a container's entries.
+func printDetection(result *detect.Result, indent string) {
+	if result.Container != "" {
+		fmt.Printf("%s(%s container)\n", indent, result.Container)
+	}
+	if len(result.Candidates) == 0 {
+		fmt.Printf("%s  no format detected\n", indent)
+	}
+	for i, c := range result.Candidates {
+		fmt.Printf("%s  %d. %-20s confidence %.2f, %s: %s\n", indent, i+1, c.PluginID, c.Confidence, specificityName(c.Specificity), c.Reason)
+	}
+	for _, nested := range result.Nested {
+		fmt.Printf("%s  %s:\n", indent, nested.Path)
+		printDetection(nested, indent+"    ")
 	}
+	if result.Truncated {
+		fmt.Printf("%s  ... more entries not detected\n", indent)
+	}
+}
 
-	return nil
+// specificityName describes a detection specificity.
+func specificityName(specificity int) string {
+	switch specificity {
+	case plugins.SpecificityAny:
+		return "any file"
+	case plugins.SpecificityGeneric:
+		return "generic"
+	default:
+		return "specific"
+	}
 }
 
 // EnumerateCmd enumerates contents of archive.
@@ -512,30 +1125,17 @@ func (c *EnumerateCmd) Run(ctx *kong.Context) error {
 	}
 
 	// First detect which plugin matches
-	formatPlugins := loader.GetPluginsByKind("format")
-	var matchedPlugin *plugins.Plugin
-
-	for _, p := range formatPlugins {
-		req := plugins.NewDetectRequest(path)
-		resp, err := plugins.ExecutePlugin(p, req)
-		if err != nil {
-			continue
-		}
-
-		result, err := plugins.ParseDetectResult(resp)
-		if err != nil {
-			continue
-		}
-
-		if result.Detected {
-			matchedPlugin = p
-			break
-		}
+	detector := detect.New(loader)
+	detector.Depth = 0
+	detection, err := detector.Detect(context.Background(), path)
+	if err != nil {
+		return err
 	}
-
-	if matchedPlugin == nil {
+	best := detection.Best()
+	if best == nil {
 		return fmt.Errorf("no matching format plugin found for: %s", path)
 	}
+	matchedPlugin := best.Plugin
 
 	fmt.Printf("Enumerating: %s (using %s)\n\n", path, matchedPlugin.Manifest.PluginID)
 
@@ -566,6 +1166,7 @@ func (c *EnumerateCmd) Run(ctx *kong.Context) error {
 type TestCmd struct {
 	FixturesDir string `arg:"" help:"Path to fixtures directory" type:"existingdir"`
 	Golden      string `help:"Path to golden hashes directory" type:"path"`
+	Format      string `default:"text" enum:"text,junit,tap,sarif" help:"Output format (text, junit, tap, sarif)"`
 }
 
 func (c *TestCmd) Run() error {
@@ -593,33 +1194,59 @@ func (c *TestCmd) Run() error {
 		inputFiles = nil
 	}
 
-	fmt.Printf("Capsule Test Runner\n")
-	fmt.Printf("  Fixtures: %s\n", fixturesDir)
-	fmt.Printf("  Goldens:  %s\n", goldenDir)
-	fmt.Println()
+	// Structured formats replace the text output
+	var out io.Writer = os.Stdout
+	if reporter.IsStructured(c.Format) {
+		out = io.Discard
+	}
+	suite := reporter.NewSuite("golden " + filepath.Base(fixturesDir))
+
+	fmt.Fprintf(out, "Capsule Test Runner\n")
+	fmt.Fprintf(out, "  Fixtures: %s\n", fixturesDir)
+	fmt.Fprintf(out, "  Goldens:  %s\n", goldenDir)
+	fmt.Fprintln(out)
 
 	passed := 0
 	failed := 0
 	var failures []string
 
+	// record prints and collects the result of one golden test.
+	record := func(name, label, className, path string, result bool, err error, duration time.Duration) {
+		testCase := reporter.Case{Name: name, ClassName: className, Status: reporter.StatusPass, Duration: duration}
+		message := ""
+		if err != nil {
+			message = err.Error()
+		} else if !result {
+			message = "hash mismatch"
+		}
+		if message == "" {
+			fmt.Fprintf(out, "  [PASS] %s\n", label)
+			passed++
+			suite.Add(testCase)
+			return
+		}
+		fmt.Fprintf(out, "  [FAIL] %s: %s\n", label, message)
+		failed++
+		failures = append(failures, fmt.Sprintf("%s: %s", name, message))
+		testCase.Status = reporter.StatusFail
+		testCase.Message = message
+		testCase.Findings = []reporter.Finding{{
+			RuleID:  "golden",
+			Level:   reporter.LevelError,
+			Message: fmt.Sprintf("%s: %s", name, message),
+			File:    path,
+		}}
+		suite.Add(testCase)
+	}
+
 	// Test existing capsules
 	for _, capsulePath := range capsuleFiles {
 		name := filepath.Base(capsulePath)
 		name = name[:len(name)-len(".capsule.tar.xz")]
 
-		result, err := runCapsuleTest(capsulePath, goldenDir, name)
-		if err != nil {
-			fmt.Printf("  [FAIL] %s: %v\n", name, err)
-			failed++
-			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
-		} else if result {
-			fmt.Printf("  [PASS] %s\n", name)
-			passed++
-		} else {
-			fmt.Printf("  [FAIL] %s: hash mismatch\n", name)
-			failed++
-			failures = append(failures, fmt.Sprintf("%s: hash mismatch", name))
-		}
+		startedAt := time.Now()
+		result, err := runCapsuleTest(out, capsulePath, goldenDir, name)
+		record(name, name, "golden.capsules", capsulePath, result, err, time.Since(startedAt))
 	}
 
 	// Test input files (ingest -> selfcheck -> compare)
@@ -633,28 +1260,24 @@ func (c *TestCmd) Run() error {
 		ext := filepath.Ext(name)
 		testName := name[:len(name)-len(ext)]
 
-		result, err := runIngestTest(inputPath, goldenDir, testName)
-		if err != nil {
-			fmt.Printf("  [FAIL] %s (ingest): %v\n", testName, err)
-			failed++
-			failures = append(failures, fmt.Sprintf("%s: %v", testName, err))
-		} else if result {
-			fmt.Printf("  [PASS] %s (ingest)\n", testName)
-			passed++
-		} else {
-			fmt.Printf("  [FAIL] %s (ingest): hash mismatch\n", testName)
-			failed++
-			failures = append(failures, fmt.Sprintf("%s: hash mismatch", testName))
-		}
+		startedAt := time.Now()
+		result, err := runIngestTest(out, inputPath, goldenDir, testName)
+		record(testName, testName+" (ingest)", "golden.inputs", inputPath, result, err, time.Since(startedAt))
 	}
 
-	fmt.Println()
-	fmt.Printf("Results: %d passed, %d failed\n", passed, failed)
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Results: %d passed, %d failed\n", passed, failed)
+
+	if reporter.IsStructured(c.Format) {
+		if err := reporter.Write(os.Stdout, c.Format, "capsule", version, suite); err != nil {
+			return err
+		}
+	}
 
 	if failed > 0 {
-		fmt.Println("\nFailures:")
+		fmt.Fprintln(out, "\nFailures:")
 		for _, f := range failures {
-			fmt.Printf("  - %s\n", f)
+			fmt.Fprintf(out, "  - %s\n", f)
 		}
 		return fmt.Errorf("%d test(s) failed", failed)
 	}
@@ -662,12 +1285,100 @@ func (c *TestCmd) Run() error {
 	return nil
 }
 
-// RunCmd runs a tool plugin with Nix executor.
+// EngineFlags selects the execution engine of tool runs.
+type EngineFlags struct {
+	Engine      string   `default:"nix" enum:"nix,sandbox,replay" help:"Execution engine (nix, sandbox, replay)"`
+	ToolRoot    string   `help:"Tool root with bin/ and lib/ for the sandbox engine" type:"existingdir"`
+	ToolArchive string   `help:"Tool archive capsule providing the sandbox tool root" type:"existingfile"`
+	ReplayFrom  []string `help:"Capsules whose recorded runs the replay engine serves" type:"existingfile"`
+	LimitFlags
+}
+
+// LimitFlags are the resource limits of tool and external plugin
+// processes. Zero leaves a limit unset.
+type LimitFlags struct {
+	LimitCPU       uint64 `help:"CPU time limit in seconds (0 for none)" default:"300"`
+	LimitMemory    uint64 `help:"Address space limit in MiB (0 for none)" default:"4096"`
+	LimitFileSize  uint64 `help:"Size limit of written files in MiB (0 for none)" default:"1024"`
+	LimitOpenFiles uint64 `help:"Open file descriptor limit (0 for none)" default:"256"`
+}
+
+// limits returns the resource limits of the flags.
+func (f *LimitFlags) limits() resource.Limits {
+	return resource.Limits{
+		CPUSeconds:   f.LimitCPU,
+		AddressSpace: f.LimitMemory << 20,
+		FileSize:     f.LimitFileSize << 20,
+		OpenFiles:    f.LimitOpenFiles,
+	}
+}
+
+// executor creates the executor of the selected engine. The replay engine
+// serves the runs recorded in the given capsules and in --replay-from;
+// the returned cleanup function removes what was unpacked for it. The
+// resource limits apply to the executor's tools and to external plugins.
+func (f *EngineFlags) executor(caps ...*capsule.Capsule) (runner.Executor, func(), error) {
+	cleanup := func() {}
+	if f.Engine != "replay" && len(f.ReplayFrom) > 0 {
+		return nil, cleanup, fmt.Errorf("--replay-from requires --engine=replay")
+	}
+	limits := f.limits()
+	plugins.SetExternalPluginLimits(limits)
+	switch f.Engine {
+	case "sandbox":
+		e := runner.NewSandboxExecutor(f.ToolRoot)
+		if f.ToolArchive != "" {
+			tool, err := runner.LoadToolArchive(f.ToolArchive)
+			if err != nil {
+				return nil, cleanup, err
+			}
+			e = runner.NewSandboxExecutorForTool(tool)
+		}
+		e.Limits = limits
+		return e, cleanup, nil
+	case "replay":
+		if f.ToolRoot != "" || f.ToolArchive != "" {
+			return nil, cleanup, fmt.Errorf("--tool-root and --tool-archive require --engine=sandbox")
+		}
+		tempDir, err := os.MkdirTemp("", "capsule-replay-*")
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		cleanup = func() { os.RemoveAll(tempDir) }
+		for i, path := range f.ReplayFrom {
+			cap, err := capsule.Unpack(path, filepath.Join(tempDir, strconv.Itoa(i)))
+			if err != nil {
+				cleanup()
+				return nil, func() {}, fmt.Errorf("failed to unpack %s: %w", path, err)
+			}
+			caps = append(caps, cap)
+		}
+		if len(caps) == 0 {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("the replay engine needs recorded runs (--replay-from)")
+		}
+		return runner.NewReplayExecutor(caps...), cleanup, nil
+	default:
+		if f.ToolRoot != "" || f.ToolArchive != "" {
+			return nil, cleanup, fmt.Errorf("--tool-root and --tool-archive require --engine=sandbox")
+		}
+		flakePath := getFlakePath()
+		if flakePath == "" {
+			return nil, cleanup, fmt.Errorf("nix flake not found (looked for nix/flake.nix)")
+		}
+		e := runner.NewNixExecutor(flakePath)
+		e.Limits = limits
+		return e, cleanup, nil
+	}
+}
+
+// RunCmd runs a tool plugin with the Nix, sandbox or replay executor.
 type RunCmd struct {
 	Tool    string `arg:"" help:"Tool plugin ID"`
 	Profile string `arg:"" help:"Profile to run"`
 	Input   string `help:"Input file path" type:"existingfile"`
 	Out     string `help:"Output directory" type:"path"`
+	EngineFlags
 }
 
 func (c *RunCmd) Run() error {
@@ -690,17 +1401,17 @@ func (c *RunCmd) Run() error {
 		}
 	}
 
-	// Find the nix flake
-	flakePath := getFlakePath()
-	if flakePath == "" {
-		return fmt.Errorf("nix flake not found (looked for nix/flake.nix)")
+	executor, cleanup, err := c.executor()
+	if err != nil {
+		return err
 	}
+	defer cleanup()
 
 	fmt.Printf("Running tool: %s\n", toolID)
 	fmt.Printf("  Profile: %s\n", profile)
 	fmt.Printf("  Input: %s\n", inputPath)
 	fmt.Printf("  Output: %s\n", outDir)
-	fmt.Printf("  Flake: %s\n", flakePath)
+	fmt.Printf("  Engine: %s\n", executor.EngineSpec())
 	fmt.Println()
 
 	// Create request
@@ -709,8 +1420,6 @@ func (c *RunCmd) Run() error {
 		req.Inputs = []string{inputPath}
 	}
 
-	// Execute with Nix
-	executor := runner.NewNixExecutor(flakePath)
 	ctx := context.Background()
 
 	var inputPaths []string
@@ -767,6 +1476,7 @@ type ToolRunCmd struct {
 	Artifact string `arg:"" help:"Artifact ID"`
 	Tool     string `arg:"" help:"Tool plugin ID"`
 	Profile  string `arg:"" help:"Profile to run"`
+	EngineFlags
 }
 
 func (c *ToolRunCmd) Run() error {
@@ -810,26 +1520,33 @@ func (c *ToolRunCmd) Run() error {
 		return fmt.Errorf("failed to export artifact: %w", err)
 	}
 
-	// Find the nix flake
-	flakePath := getFlakePath()
-	if flakePath == "" {
-		return fmt.Errorf("nix flake not found")
+	pluginInfo, err := runner.ToolPluginInfo(getPluginDir(), toolID)
+	if err != nil {
+		return err
+	}
+
+	executor, cleanup, err := c.executor(cap)
+	if err != nil {
+		return err
 	}
+	defer cleanup()
 
 	// Create runner request
 	req := runner.NewRequest(toolID, profile)
 	req.Inputs = []string{inputPath}
 
-	// Execute with Nix
-	executor := runner.NewNixExecutor(flakePath)
 	ctx := context.Background()
 
 	result, err := executor.ExecuteRequest(ctx, req, []string{inputPath})
 	if err != nil {
 		return fmt.Errorf("tool execution failed: %w", err)
 	}
+	if err := runner.CheckToolPluginInfo(getPluginDir(), pluginInfo); err != nil {
+		return err
+	}
 
 	fmt.Printf("Tool execution completed\n")
+	fmt.Printf("  Engine: %s\n", result.Engine)
 	fmt.Printf("  Exit code: %d\n", result.ExitCode)
 	fmt.Printf("  Duration: %v\n", result.Duration)
 
@@ -842,11 +1559,9 @@ func (c *ToolRunCmd) Run() error {
 	// Create run record
 	runID := fmt.Sprintf("run-%s-%s-%d", toolID, profile, len(cap.Manifest.Runs)+1)
 	run := &capsule.Run{
-		ID: runID,
-		Plugin: &capsule.PluginInfo{
-			PluginID: toolID,
-			Kind:     "tool",
-		},
+		ID:     runID,
+		Engine: result.Engine.CapsuleEngine(),
+		Plugin: pluginInfo,
 		Inputs: []capsule.RunInput{
 			{ArtifactID: artifactID},
 		},
@@ -856,8 +1571,8 @@ func (c *ToolRunCmd) Run() error {
 		Status: "completed",
 	}
 
-	// Add run to capsule
-	if err := cap.AddRun(run, result.TranscriptData); err != nil {
+	// Add run to capsule with its request and outputs, so it can be replayed
+	if err := runner.RecordRun(cap, run, req, []string{inputPath}, result); err != nil {
 		return fmt.Errorf("failed to add run: %w", err)
 	}
 
@@ -889,9 +1604,21 @@ func (c *ToolRunCmd) Run() error {
 
 // CompareCmd compares transcripts between two runs.
 type CompareCmd struct {
-	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
-	Run1    string `arg:"" help:"First run ID"`
-	Run2    string `arg:"" help:"Second run ID"`
+	Capsule string   `arg:"" help:"Path to capsule" type:"existingfile"`
+	Run1    string   `arg:"" help:"First run ID"`
+	Run2    string   `arg:"" help:"Second run ID"`
+	JSON    bool     `help:"Output the semantic diff as JSON"`
+	Ignore  []string `help:"Event attributes to ignore besides the volatile defaults"`
+	Limit   int      `default:"50" help:"Maximum number of changes to list (0 for all)"`
+}
+
+// compareResult is the JSON output of runs compare.
+type compareResult struct {
+	RunA  string                 `json:"run_a"`
+	RunB  string                 `json:"run_b"`
+	HashA string                 `json:"hash_a"`
+	HashB string                 `json:"hash_b"`
+	Diff  *runner.TranscriptDiff `json:"diff"`
 }
 
 func (c *CompareCmd) Run() error {
@@ -922,12 +1649,6 @@ func (c *CompareCmd) Run() error {
 		return fmt.Errorf("run not found: %s", run2ID)
 	}
 
-	fmt.Printf("Comparing transcripts\n")
-	fmt.Printf("  Capsule: %s\n", capsulePath)
-	fmt.Printf("  Run 1: %s\n", run1ID)
-	fmt.Printf("  Run 2: %s\n", run2ID)
-	fmt.Println()
-
 	// Get transcript hashes
 	hash1 := ""
 	hash2 := ""
@@ -945,21 +1666,6 @@ func (c *CompareCmd) Run() error {
 		return fmt.Errorf("run %s has no transcript", run2ID)
 	}
 
-	fmt.Printf("Transcript hashes:\n")
-	fmt.Printf("  Run 1: %s\n", hash1)
-	fmt.Printf("  Run 2: %s\n", hash2)
-	fmt.Println()
-
-	if hash1 == hash2 {
-		fmt.Println("Result: IDENTICAL")
-		fmt.Println("  Transcripts are byte-for-byte identical.")
-		return nil
-	}
-
-	fmt.Println("Result: DIFFERENT")
-	fmt.Println("  Transcripts differ. Showing diff:")
-	fmt.Println()
-
 	// Get transcript contents
 	transcript1, err := cap.GetTranscript(run1ID)
 	if err != nil {
@@ -970,61 +1676,69 @@ func (c *CompareCmd) Run() error {
 		return fmt.Errorf("failed to get transcript 2: %w", err)
 	}
 
-	// Parse and compare events
-	events1, err := runner.ParseNixTranscript(transcript1)
-	if err != nil {
-		return fmt.Errorf("failed to parse transcript 1: %w", err)
-	}
-	events2, err := runner.ParseNixTranscript(transcript2)
+	opts := runner.DefaultDiffOptions()
+	opts.IgnoreAttributes = append(opts.IgnoreAttributes, c.Ignore...)
+	diff, err := runner.DiffTranscripts(transcript1, transcript2, opts)
 	if err != nil {
-		return fmt.Errorf("failed to parse transcript 2: %w", err)
-	}
-
-	fmt.Printf("Event counts: Run 1=%d, Run 2=%d\n\n", len(events1), len(events2))
-
-	// Simple diff: show events that differ
-	maxLen := len(events1)
-	if len(events2) > maxLen {
-		maxLen = len(events2)
+		return fmt.Errorf("failed to compare transcripts: %w", err)
 	}
 
-	for i := 0; i < maxLen; i++ {
-		var e1, e2 string
-		if i < len(events1) {
-			data, _ := json.Marshal(events1[i])
-			e1 = string(data)
+	if c.JSON {
+		data, err := json.MarshalIndent(compareResult{RunA: run1ID, RunB: run2ID, HashA: hash1, HashB: hash2, Diff: diff}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize diff: %w", err)
 		}
-		if i < len(events2) {
-			data, _ := json.Marshal(events2[i])
-			e2 = string(data)
+		fmt.Println(string(data))
+		if !diff.Identical {
+			return fmt.Errorf("transcripts differ")
 		}
+		return nil
+	}
 
-		if e1 != e2 {
-			fmt.Printf("[%d] DIFFERS:\n", i)
-			if e1 != "" {
-				fmt.Printf("  - %s\n", e1)
-			} else {
-				fmt.Printf("  - (missing)\n")
-			}
-			if e2 != "" {
-				fmt.Printf("  + %s\n", e2)
-			} else {
-				fmt.Printf("  + (missing)\n")
-			}
-		}
+	fmt.Printf("Comparing transcripts\n")
+	fmt.Printf("  Capsule: %s\n", capsulePath)
+	fmt.Printf("  Run 1: %s\n", run1ID)
+	fmt.Printf("  Run 2: %s\n", run2ID)
+	fmt.Println()
+
+	fmt.Printf("Transcript hashes:\n")
+	fmt.Printf("  Run 1: %s\n", hash1)
+	fmt.Printf("  Run 2: %s\n", hash2)
+	fmt.Println()
+
+	if hash1 == hash2 {
+		fmt.Println("Result: IDENTICAL")
+		fmt.Println("  Transcripts are byte-for-byte identical.")
+		return nil
 	}
+	if diff.Identical {
+		fmt.Println("Result: EQUIVALENT")
+		fmt.Println("  Transcripts differ only in ignored attributes.")
+		return nil
+	}
+
+	fmt.Println("Result: DIFFERENT")
+	fmt.Println()
+	diff.WriteText(os.Stdout, "Run 1", "Run 2", c.Limit)
 
 	return fmt.Errorf("transcripts differ")
 }
 
 // RunsListCmd lists all runs in a capsule.
 type RunsListCmd struct {
-	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
+	Capsule   string   `arg:"" help:"Path to capsule" type:"existingfile"`
+	Golden    []string `help:"Golden files whose latest ledger entries are resource baselines for runs of the same plugin and profile" type:"existingfile"`
+	Threshold float64  `help:"Report resources growing by more than this factor over the baseline" default:"2.0"`
 }
 
 func (c *RunsListCmd) Run() error {
 	capsulePath := c.Capsule
 
+	baselines, err := loadResourceBaselines(c.Golden)
+	if err != nil {
+		return err
+	}
+
 	// Create temporary directory for unpacking
 	tempDir, err := os.MkdirTemp("", "capsule-runs-*")
 	if err != nil {
@@ -1045,11 +1759,22 @@ func (c *RunsListCmd) Run() error {
 		return nil
 	}
 
-	for id, run := range cap.Manifest.Runs {
+	ids := make([]string, 0, len(cap.Manifest.Runs))
+	for id := range cap.Manifest.Runs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	regressed := 0
+	for _, id := range ids {
+		run := cap.Manifest.Runs[id]
 		fmt.Printf("  %s\n", id)
 		if run.Plugin != nil {
 			fmt.Printf("    Plugin: %s\n", run.Plugin.PluginID)
 		}
+		if run.Engine != nil && run.Engine.Type != "" {
+			fmt.Printf("    Engine: %s/%s\n", run.Engine.Type, run.Engine.EngineID)
+		}
 		if run.Command != nil && run.Command.Profile != "" {
 			fmt.Printf("    Profile: %s\n", run.Command.Profile)
 		}
@@ -1067,170 +1792,416 @@ func (c *RunsListCmd) Run() error {
 			}
 			fmt.Println()
 		}
+		if run.Resources != nil {
+			fmt.Printf("    Resources: %s\n", run.Resources)
+			if baseline, ok := baselines[resourceBaselineKey(run)]; ok {
+				regressions := resource.Compare(baseline.entry.Resources, run.Resources, c.Threshold)
+				if len(regressions) > 0 {
+					regressed++
+					fmt.Printf("    REGRESSION vs %s (run %s):\n", baseline.path, baseline.entry.RunID)
+					for _, r := range regressions {
+						fmt.Printf("      %s\n", r)
+					}
+				}
+			}
+		}
 		fmt.Println()
 	}
 
 	fmt.Printf("Total: %d run(s)\n", len(cap.Manifest.Runs))
+	if len(baselines) > 0 {
+		fmt.Printf("Resource regressions: %d run(s)\n", regressed)
+	}
 	return nil
 }
 
-// GoldenSaveCmd saves golden transcript hash to a file.
-type GoldenSaveCmd struct {
-	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
-	RunID   string `arg:"" name:"run" help:"Run ID"`
-	Out     string `arg:"" help:"Output golden hash file path" type:"path"`
+// resourceBaseline is the latest ledger entry of a golden with resources.
+type resourceBaseline struct {
+	path  string
+	entry *golden.Entry
 }
 
-func (c *GoldenSaveCmd) Run() error {
-	capsulePath := c.Capsule
-	runID := c.RunID
-	saveFile := c.Out
-
-	// Create temporary directory for unpacking
-	tempDir, err := os.MkdirTemp("", "capsule-golden-save-*")
-	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Unpack the capsule
-	cap, err := capsule.Unpack(capsulePath, tempDir)
-	if err != nil {
-		return fmt.Errorf("failed to unpack capsule: %w", err)
+// loadResourceBaselines returns the resource baselines of golden files by
+// plugin and profile. Goldens whose latest entry has no resources are
+// skipped; for the same plugin and profile, earlier files take precedence.
+func loadResourceBaselines(paths []string) (map[string]resourceBaseline, error) {
+	baselines := make(map[string]resourceBaseline)
+	for _, path := range paths {
+		g, err := golden.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load golden %s: %w", path, err)
+		}
+		latest := g.Latest()
+		if latest == nil || latest.Resources == nil {
+			continue
+		}
+		key := latest.Plugin + "\x00" + latest.Profile
+		if _, ok := baselines[key]; !ok {
+			baselines[key] = resourceBaseline{path: path, entry: latest}
+		}
 	}
+	return baselines, nil
+}
 
-	// Get run
-	run, ok := cap.Manifest.Runs[runID]
-	if !ok {
-		return fmt.Errorf("run not found: %s", runID)
+// resourceBaselineKey returns the key of a run in loadResourceBaselines.
+func resourceBaselineKey(run *capsule.Run) string {
+	var plugin, profile string
+	if run.Plugin != nil {
+		plugin = run.Plugin.PluginID
 	}
-
-	if run.Outputs == nil || run.Outputs.TranscriptBlobSHA256 == "" {
-		return fmt.Errorf("run has no transcript: %s", runID)
+	if run.Command != nil {
+		profile = run.Command.Profile
 	}
+	return plugin + "\x00" + profile
+}
 
-	transcriptHash := run.Outputs.TranscriptBlobSHA256
-
-	// Save the golden hash
-	goldenContent := fmt.Sprintf("%s\n", transcriptHash)
-	if err := os.WriteFile(saveFile, []byte(goldenContent), 0644); err != nil {
-		return fmt.Errorf("failed to save golden: %w", err)
+// printTranscriptDiff prints the semantic difference between two
+// transcripts.
+func printTranscriptDiff(out io.Writer, transcript1, transcript2 []byte, label1, label2 string) {
+	diff, err := runner.DiffTranscripts(transcript1, transcript2, runner.DefaultDiffOptions())
+	if err != nil {
+		fmt.Fprintf(out, "failed to compare transcripts: %v\n", err)
+		return
 	}
-	fmt.Printf("Golden saved: %s\n", saveFile)
-	fmt.Printf("  Run: %s\n", runID)
-	fmt.Printf("  Hash: %s\n", transcriptHash)
-	return nil
+	diff.WriteText(out, label1, label2, 50)
 }
 
-// GoldenCheckCmd checks transcript against golden hash.
-type GoldenCheckCmd struct {
-	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
-	RunID   string `arg:"" name:"run" help:"Run ID"`
-	Golden  string `arg:"" help:"Golden hash file to check against" type:"existingfile"`
+// goldenRun is the transcript of a run being saved or checked against a
+// golden.
+type goldenRun struct {
+	ID         string
+	Run        *capsule.Run
+	SHA256     string
+	Transcript []byte // nil if the capsule does not hold the transcript blob
 }
 
-func (c *GoldenCheckCmd) Run() error {
-	capsulePath := c.Capsule
-	runID := c.RunID
-	checkFile := c.Golden
-
+// loadGoldenRun reads the transcript of a run from a capsule.
+func loadGoldenRun(capsulePath, runID string) (*goldenRun, error) {
 	// Create temporary directory for unpacking
-	tempDir, err := os.MkdirTemp("", "capsule-golden-check-*")
+	tempDir, err := os.MkdirTemp("", "capsule-golden-*")
 	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
 	defer os.RemoveAll(tempDir)
 
 	// Unpack the capsule
 	cap, err := capsule.Unpack(capsulePath, tempDir)
 	if err != nil {
-		return fmt.Errorf("failed to unpack capsule: %w", err)
+		return nil, fmt.Errorf("failed to unpack capsule: %w", err)
 	}
 
 	// Get run
 	run, ok := cap.Manifest.Runs[runID]
 	if !ok {
-		return fmt.Errorf("run not found: %s", runID)
+		return nil, fmt.Errorf("run not found: %s", runID)
 	}
 
 	if run.Outputs == nil || run.Outputs.TranscriptBlobSHA256 == "" {
-		return fmt.Errorf("run has no transcript: %s", runID)
+		return nil, fmt.Errorf("run has no transcript: %s", runID)
 	}
 
-	transcriptHash := run.Outputs.TranscriptBlobSHA256
-
-	// Check against golden
-	goldenData, err := os.ReadFile(checkFile)
-	if err != nil {
-		return fmt.Errorf("failed to read golden: %w", err)
+	r := &goldenRun{ID: runID, Run: run, SHA256: run.Outputs.TranscriptBlobSHA256}
+	if data, err := cap.GetTranscript(runID); err == nil {
+		r.Transcript = data
 	}
-	goldenHash := strings.TrimSpace(string(goldenData))
-
-	fmt.Printf("Checking against golden: %s\n", checkFile)
-	fmt.Printf("  Run: %s\n", runID)
-	fmt.Printf("  Expected: %s\n", goldenHash)
-	fmt.Printf("  Actual:   %s\n", transcriptHash)
-	fmt.Println()
+	return r, nil
+}
 
-	if goldenHash == transcriptHash {
-		fmt.Println("Result: PASS")
-		fmt.Println("  Transcript matches golden.")
-		return nil
+// ledgerEntry returns a golden ledger entry for the run.
+func (r *goldenRun) ledgerEntry(reason, commit string) golden.Entry {
+	toolVersion := "capsule " + version
+	if r.Run.Plugin != nil && r.Run.Plugin.PluginID != "" {
+		toolVersion += fmt.Sprintf(", %s %s", r.Run.Plugin.PluginID, r.Run.Plugin.PluginVersion)
+	}
+	if commit == "" {
+		commit = gitCommit()
+	}
+	entry := golden.Entry{
+		Reason:      reason,
+		Commit:      commit,
+		ToolVersion: strings.TrimSpace(toolVersion),
+		Engine:      r.engine(),
+		RunID:       r.ID,
+		Resources:   r.Run.Resources,
+	}
+	if r.Run.Plugin != nil {
+		entry.Plugin = r.Run.Plugin.PluginID
 	}
+	if r.Run.Command != nil {
+		entry.Profile = r.Run.Command.Profile
+	}
+	return entry
+}
 
-	fmt.Println("Result: FAIL")
-	fmt.Println("  Transcript does not match golden!")
-	return fmt.Errorf("golden mismatch")
+// engine returns the identity of the engine that ran the run, or an empty
+// string for runs recorded without one.
+func (r *goldenRun) engine() string {
+	if r.Run.Engine == nil || r.Run.Engine.Type == "" {
+		return ""
+	}
+	return r.Run.Engine.Type + "/" + r.Run.Engine.EngineID
 }
 
-// ExtractIRCmd extracts IR from a file.
-type ExtractIRCmd struct {
-	Path   string `arg:"" help:"Path to input file" type:"existingfile"`
-	Format string `required:"" help:"Source format (e.g., usfm, osis)"`
-	Out    string `required:"" help:"Output IR JSON path" type:"path"`
+// gitCommit returns the commit checked out in the working directory, or an
+// empty string outside a git work tree.
+func gitCommit() string {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
 }
 
-func (c *ExtractIRCmd) Run() error {
-	inputPath, _ := filepath.Abs(c.Path)
-	format := c.Format
-	outputPath, _ := filepath.Abs(c.Out)
+// GoldenSaveCmd saves golden transcript hash to a file.
+type GoldenSaveCmd struct {
+	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
+	RunID   string `arg:"" name:"run" help:"Run ID"`
+	Out     string `arg:"" help:"Output golden hash file path" type:"path"`
+	Reason  string `help:"Why this golden is recorded (default: initial golden)"`
+	Commit  string `help:"Commit the golden was produced at (default: git HEAD)"`
+}
 
-	// Load plugins
-	pluginDir := getPluginDir()
-	loader := plugins.NewLoader()
-	if err := loader.LoadFromDir(pluginDir); err != nil {
-		return fmt.Errorf("failed to load plugins: %w", err)
+func (c *GoldenSaveCmd) Run() error {
+	run, err := loadGoldenRun(c.Capsule, c.RunID)
+	if err != nil {
+		return err
 	}
 
-	// Find format plugin
-	pluginID := "format." + format
-	plugin, err := loader.GetPlugin(pluginID)
+	g, err := golden.Load(c.Out)
 	if err != nil {
-		return fmt.Errorf("plugin not found: %s", pluginID)
+		return fmt.Errorf("failed to read golden: %w", err)
+	}
+	if g.SHA256 != "" && g.SHA256 != run.SHA256 {
+		return fmt.Errorf("golden %s records a different hash; use 'runs golden update --reason' to change it", c.Out)
+	}
+	if latest := g.Latest(); latest != nil && latest.SHA256 == run.SHA256 {
+		fmt.Printf("Golden already saved: %s\n", c.Out)
+		fmt.Printf("  Hash: %s\n", run.SHA256)
+		return nil
 	}
 
-	fmt.Printf("Extracting IR from: %s\n", inputPath)
-	fmt.Printf("  Format: %s\n", format)
-	fmt.Printf("  Output: %s\n", outputPath)
-	fmt.Println()
+	reason := c.Reason
+	if reason == "" {
+		reason = "initial golden from run " + c.RunID
+	}
+	if _, err := golden.Update(c.Out, run.SHA256, run.Transcript, run.ledgerEntry(reason, c.Commit)); err != nil {
+		return fmt.Errorf("failed to save golden: %w", err)
+	}
+	fmt.Printf("Golden saved: %s\n", c.Out)
+	fmt.Printf("  Run: %s\n", c.RunID)
+	fmt.Printf("  Hash: %s\n", run.SHA256)
+	fmt.Printf("  Ledger: %s\n", golden.LedgerPath(c.Out))
+	return nil
+}
 
-	// Create temp directory for IR output
-	tempDir, err := os.MkdirTemp("", "capsule-extract-ir-*")
+// GoldenUpdateCmd changes a golden hash and records why in its ledger.
+type GoldenUpdateCmd struct {
+	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
+	RunID   string `arg:"" name:"run" help:"Run ID"`
+	Golden  string `arg:"" help:"Golden hash file to update" type:"existingfile"`
+	Reason  string `required:"" help:"Why the golden transcript changed"`
+	Commit  string `help:"Commit the new golden was produced at (default: git HEAD)"`
+}
+
+func (c *GoldenUpdateCmd) Run() error {
+	run, err := loadGoldenRun(c.Capsule, c.RunID)
 	if err != nil {
-		return fmt.Errorf("failed to create temp dir: %w", err)
+		return err
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Execute extract-ir
-	req := plugins.NewExtractIRRequest(inputPath, tempDir)
-	resp, err := plugins.ExecutePlugin(plugin, req)
+	g, err := golden.Load(c.Golden)
 	if err != nil {
-		return fmt.Errorf("extract-ir failed: %w", err)
+		return fmt.Errorf("failed to read golden: %w", err)
 	}
-
-	result, err := plugins.ParseExtractIRResult(resp)
+	entry, err := golden.Update(c.Golden, run.SHA256, run.Transcript, run.ledgerEntry(c.Reason, c.Commit))
 	if err != nil {
-		return fmt.Errorf("failed to parse result: %w", err)
+		return fmt.Errorf("failed to update golden: %w", err)
+	}
+
+	fmt.Printf("Golden updated: %s\n", c.Golden)
+	fmt.Printf("  Run: %s\n", c.RunID)
+	fmt.Printf("  Old: %s\n", g.SHA256)
+	fmt.Printf("  New: %s\n", entry.SHA256)
+	fmt.Printf("  Reason: %s\n", entry.Reason)
+	return nil
+}
+
+// GoldenCheckCmd checks transcript against golden hash.
+type GoldenCheckCmd struct {
+	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
+	RunID   string `arg:"" name:"run" help:"Run ID"`
+	Golden  string `arg:"" help:"Golden hash file to check against" type:"existingfile"`
+	Strict  bool   `help:"Fail if the golden has no ledger"`
+}
+
+func (c *GoldenCheckCmd) Run() error {
+	run, err := loadGoldenRun(c.Capsule, c.RunID)
+	if err != nil {
+		return err
+	}
+
+	g, err := golden.Load(c.Golden)
+	if err != nil {
+		return fmt.Errorf("failed to read golden: %w", err)
+	}
+
+	fmt.Printf("Checking against golden: %s\n", c.Golden)
+	fmt.Printf("  Run: %s\n", c.RunID)
+	fmt.Printf("  Expected: %s\n", g.SHA256)
+	fmt.Printf("  Actual:   %s\n", run.SHA256)
+	if engine := run.engine(); engine != "" {
+		fmt.Printf("  Engine:   %s\n", engine)
+	}
+	if latest := g.Latest(); latest != nil && latest.Engine != "" && latest.Engine != run.engine() {
+		fmt.Printf("  Note: golden was recorded with engine %s\n", latest.Engine)
+	}
+	fmt.Println()
+
+	// Goldens saved before the ledger existed are only checked for a
+	// ledger in strict mode
+	var problems []string
+	if len(g.Ledger) > 0 || c.Strict {
+		problems = g.Problems()
+	}
+	if len(problems) > 0 {
+		fmt.Println("Ledger problems:")
+		for _, p := range problems {
+			fmt.Printf("  - %s\n", p)
+		}
+		fmt.Println()
+	}
+
+	if g.SHA256 != run.SHA256 {
+		fmt.Println("Result: FAIL")
+		fmt.Println("  Transcript does not match golden!")
+		if old, err := golden.Transcript(c.Golden, g.SHA256); err == nil && run.Transcript != nil {
+			fmt.Println()
+			printTranscriptDiff(os.Stdout, old, run.Transcript, "Golden", "Run")
+		}
+		return fmt.Errorf("golden mismatch")
+	}
+	if len(problems) > 0 {
+		fmt.Println("Result: FAIL")
+		fmt.Println("  Golden hash is not explained by its ledger.")
+		return fmt.Errorf("golden ledger check failed")
+	}
+
+	fmt.Println("Result: PASS")
+	fmt.Println("  Transcript matches golden.")
+	return nil
+}
+
+// GoldenLogCmd shows the change ledger of a golden.
+type GoldenLogCmd struct {
+	Golden string `arg:"" help:"Golden hash file" type:"existingfile"`
+	JSON   bool   `help:"Output ledger as JSON"`
+}
+
+func (c *GoldenLogCmd) Run() error {
+	g, err := golden.Load(c.Golden)
+	if err != nil {
+		return fmt.Errorf("failed to read golden: %w", err)
+	}
+
+	if c.JSON {
+		data, err := json.MarshalIndent(g.Ledger, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Golden: %s\n", c.Golden)
+	fmt.Printf("  Hash: %s\n\n", g.SHA256)
+	if len(g.Ledger) == 0 {
+		fmt.Println("No ledger entries.")
+		return nil
+	}
+	for i := len(g.Ledger) - 1; i >= 0; i-- {
+		e := g.Ledger[i]
+		fmt.Printf("%s  %s\n", e.Time.Format(time.RFC3339), e.SHA256)
+		if e.Previous != "" {
+			fmt.Printf("  Previous: %s\n", e.Previous)
+		}
+		if e.User != "" {
+			fmt.Printf("  User: %s\n", e.User)
+		}
+		if e.Commit != "" {
+			fmt.Printf("  Commit: %s\n", e.Commit)
+		}
+		if e.ToolVersion != "" {
+			fmt.Printf("  Tool: %s\n", e.ToolVersion)
+		}
+		if e.Engine != "" {
+			fmt.Printf("  Engine: %s\n", e.Engine)
+		}
+		if e.RunID != "" {
+			fmt.Printf("  Run: %s\n", e.RunID)
+		}
+		fmt.Printf("  Reason: %s\n\n", e.Reason)
+	}
+
+	for _, p := range g.Problems() {
+		fmt.Printf("WARNING: %s\n", p)
+	}
+	return nil
+}
+
+// ExtractIRCmd extracts IR from a file.
+type ExtractIRCmd struct {
+	Path   string            `arg:"" help:"Path to input file" type:"existingfile"`
+	Format string            `required:"" help:"Source format (e.g., usfm, osis)"`
+	Out    string            `required:"" help:"Output IR JSON path" type:"path"`
+	Opt    map[string]string `help:"Option declared by the format plugin (repeatable)" placeholder:"KEY=VALUE"`
+}
+
+func (c *ExtractIRCmd) Run() error {
+	inputPath, _ := filepath.Abs(c.Path)
+	format := c.Format
+	outputPath, _ := filepath.Abs(c.Out)
+
+	// Load plugins
+	pluginDir := getPluginDir()
+	loader := plugins.NewLoader()
+	if err := loader.LoadFromDir(pluginDir); err != nil {
+		return fmt.Errorf("failed to load plugins: %w", err)
+	}
+
+	// Find format plugin
+	pluginID := "format." + format
+	plugin, err := loader.GetPlugin(pluginID)
+	if err != nil {
+		return fmt.Errorf("plugin not found: %s", pluginID)
+	}
+	opts, err := plugin.Manifest.ResolveOptions("extract-ir", optionValues(c.Opt))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Extracting IR from: %s\n", inputPath)
+	fmt.Printf("  Format: %s\n", format)
+	fmt.Printf("  Output: %s\n", outputPath)
+	printOptions(opts)
+	fmt.Println()
+
+	// Create temp directory for IR output
+	tempDir, err := os.MkdirTemp("", "capsule-extract-ir-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Execute extract-ir
+	req := plugins.NewExtractIRRequest(inputPath, tempDir).WithOptions(opts)
+	resp, err := plugins.ExecutePlugin(plugin, req)
+	if err != nil {
+		return fmt.Errorf("extract-ir failed: %w", err)
+	}
+
+	result, err := plugins.ParseExtractIRResult(resp)
+	if err != nil {
+		return fmt.Errorf("failed to parse result: %w", err)
 	}
 
 	// Copy IR to output
@@ -1257,9 +2228,10 @@ func (c *ExtractIRCmd) Run() error {
 
 // EmitNativeCmd emits native format from IR.
 type EmitNativeCmd struct {
-	IR     string `arg:"" help:"Path to IR JSON file" type:"existingfile"`
-	Format string `required:"" help:"Target format (e.g., osis, html)"`
-	Out    string `required:"" help:"Output path" type:"path"`
+	IR     string            `arg:"" help:"Path to IR JSON file" type:"existingfile"`
+	Format string            `required:"" help:"Target format (e.g., osis, html)"`
+	Out    string            `required:"" help:"Output path" type:"path"`
+	Opt    map[string]string `help:"Option declared by the format plugin (repeatable)" placeholder:"KEY=VALUE"`
 }
 
 func (c *EmitNativeCmd) Run() error {
@@ -1280,10 +2252,15 @@ func (c *EmitNativeCmd) Run() error {
 	if err != nil {
 		return fmt.Errorf("plugin not found: %s", pluginID)
 	}
+	opts, err := plugin.Manifest.ResolveOptions("emit-native", optionValues(c.Opt))
+	if err != nil {
+		return err
+	}
 
 	fmt.Printf("Emitting native format from IR: %s\n", irPath)
 	fmt.Printf("  Format: %s\n", format)
 	fmt.Printf("  Output: %s\n", outputPath)
+	printOptions(opts)
 	fmt.Println()
 
 	// Create temp directory for output
@@ -1294,7 +2271,7 @@ func (c *EmitNativeCmd) Run() error {
 	defer os.RemoveAll(tempDir)
 
 	// Execute emit-native
-	req := plugins.NewEmitNativeRequest(irPath, tempDir)
+	req := plugins.NewEmitNativeRequest(irPath, tempDir).WithOptions(opts)
 	resp, err := plugins.ExecutePlugin(plugin, req)
 	if err != nil {
 		return fmt.Errorf("emit-native failed: %w", err)
@@ -1306,16 +2283,8 @@ func (c *EmitNativeCmd) Run() error {
 	}
 
 	// Copy output to destination
-	outputData, err := os.ReadFile(result.OutputPath)
-	if err != nil {
-		return fmt.Errorf("failed to read output: %w", err)
-	}
-
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-		return fmt.Errorf("failed to create output dir: %w", err)
-	}
-	if err := os.WriteFile(outputPath, outputData, 0644); err != nil {
-		return fmt.Errorf("failed to write output: %w", err)
+	if err := copyEmitOutput(result.OutputPath, outputPath); err != nil {
+		return err
 	}
 
 	fmt.Printf("Native format emitted successfully\n")
@@ -1329,9 +2298,11 @@ func (c *EmitNativeCmd) Run() error {
 
 // ConvertCmd converts file to different format via IR.
 type ConvertCmd struct {
-	Path string `arg:"" help:"Path to input file" type:"existingfile"`
-	To   string `required:"" help:"Target format"`
-	Out  string `required:"" help:"Output path" type:"path"`
+	Path       string            `arg:"" help:"Path to input file" type:"existingfile"`
+	To         string            `required:"" help:"Target format"`
+	Out        string            `required:"" help:"Output path" type:"path"`
+	Opt        map[string]string `help:"Option of the target format's emitter (repeatable)" placeholder:"KEY=VALUE"`
+	ExtractOpt map[string]string `name:"extract-opt" help:"Option of the source format's IR extractor (repeatable)" placeholder:"KEY=VALUE"`
 }
 
 func (c *ConvertCmd) Run() error {
@@ -1352,30 +2323,18 @@ func (c *ConvertCmd) Run() error {
 	fmt.Println()
 
 	// Detect source format
-	formatPlugins := loader.GetPluginsByKind("format")
-	var sourcePlugin *plugins.Plugin
-	var sourceFormat string
-
-	for _, p := range formatPlugins {
-		req := plugins.NewDetectRequest(inputPath)
-		resp, err := plugins.ExecutePlugin(p, req)
-		if err != nil {
-			continue
-		}
-		result, err := plugins.ParseDetectResult(resp)
-		if err != nil {
-			continue
-		}
-		if result.Detected {
-			sourcePlugin = p
-			sourceFormat = strings.TrimPrefix(p.Manifest.PluginID, "format.")
-			break
-		}
+	detector := detect.New(loader)
+	detector.Depth = 0
+	detection, err := detector.Detect(context.Background(), inputPath)
+	if err != nil {
+		return err
 	}
-
-	if sourcePlugin == nil {
+	best := detection.Best()
+	if best == nil {
 		return fmt.Errorf("could not detect source format")
 	}
+	sourcePlugin := best.Plugin
+	sourceFormat := best.Format
 
 	fmt.Printf("Detected source format: %s\n", sourceFormat)
 	fmt.Println()
@@ -1387,6 +2346,15 @@ func (c *ConvertCmd) Run() error {
 		return fmt.Errorf("target plugin not found: %s", targetPluginID)
 	}
 
+	extractOpts, err := sourcePlugin.Manifest.ResolveOptions("extract-ir", optionValues(c.ExtractOpt))
+	if err != nil {
+		return err
+	}
+	emitOpts, err := targetPlugin.Manifest.ResolveOptions("emit-native", optionValues(c.Opt))
+	if err != nil {
+		return err
+	}
+
 	// Create temp directory for intermediate files
 	tempDir, err := os.MkdirTemp("", "capsule-convert-*")
 	if err != nil {
@@ -1399,7 +2367,7 @@ func (c *ConvertCmd) Run() error {
 	irDir := filepath.Join(tempDir, "ir")
 	os.MkdirAll(irDir, 0755)
 
-	extractReq := plugins.NewExtractIRRequest(inputPath, irDir)
+	extractReq := plugins.NewExtractIRRequest(inputPath, irDir).WithOptions(extractOpts)
 	extractResp, err := plugins.ExecutePlugin(sourcePlugin, extractReq)
 	if err != nil {
 		return fmt.Errorf("extract-ir failed: %w", err)
@@ -1420,7 +2388,7 @@ func (c *ConvertCmd) Run() error {
 	emitDir := filepath.Join(tempDir, "output")
 	os.MkdirAll(emitDir, 0755)
 
-	emitReq := plugins.NewEmitNativeRequest(extractResult.IRPath, emitDir)
+	emitReq := plugins.NewEmitNativeRequest(extractResult.IRPath, emitDir).WithOptions(emitOpts)
 	emitResp, err := plugins.ExecutePlugin(targetPlugin, emitReq)
 	if err != nil {
 		return fmt.Errorf("emit-native failed: %w", err)
@@ -1435,25 +2403,71 @@ func (c *ConvertCmd) Run() error {
 	if emitResult.LossClass != "" {
 		fmt.Printf("  Loss class: %s\n", emitResult.LossClass)
 	}
+	printOptions(emitOpts)
 
 	// Copy output to destination
-	outputData, err := os.ReadFile(emitResult.OutputPath)
+	if err := copyEmitOutput(emitResult.OutputPath, outputPath); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Printf("Conversion complete!\n")
+	fmt.Printf("  Input: %s (%s)\n", inputPath, sourceFormat)
+	fmt.Printf("  Output: %s (%s)\n", outputPath, toFormat)
+
+	return nil
+}
+
+// optionValues converts --opt KEY=VALUE flags for
+// PluginManifest.ResolveOptions, which parses the strings.
+func optionValues(opt map[string]string) map[string]interface{} {
+	values := make(map[string]interface{}, len(opt))
+	for name, value := range opt {
+		values[name] = value
+	}
+	return values
+}
+
+// printOptions prints the options a plugin is called with.
+func printOptions(opts plugins.Options) {
+	if len(opts) == 0 {
+		return
+	}
+	names := make([]string, 0, len(opts))
+	for name := range opts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = fmt.Sprintf("%s=%v", name, opts[name])
+	}
+	fmt.Printf("  Options: %s\n", strings.Join(names, ", "))
+}
+
+// copyEmitOutput copies an emitted file to dest. Plugins emitting several
+// files return a directory, which is copied as a whole.
+func copyEmitOutput(src, dest string) error {
+	info, err := os.Stat(src)
 	if err != nil {
 		return fmt.Errorf("failed to read output: %w", err)
 	}
+	if info.IsDir() {
+		if err := fileutil.CopyDir(src, dest); err != nil {
+			return fmt.Errorf("failed to copy output: %w", err)
+		}
+		return nil
+	}
 
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read output: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
 		return fmt.Errorf("failed to create output dir: %w", err)
 	}
-	if err := os.WriteFile(outputPath, outputData, 0644); err != nil {
+	if err := os.WriteFile(dest, data, 0644); err != nil {
 		return fmt.Errorf("failed to write output: %w", err)
 	}
-
-	fmt.Println()
-	fmt.Printf("Conversion complete!\n")
-	fmt.Printf("  Input: %s (%s)\n", inputPath, sourceFormat)
-	fmt.Printf("  Output: %s (%s)\n", outputPath, toFormat)
-
 	return nil
 }
 
@@ -1679,10 +2693,12 @@ func (c *JuniperListCmd) Run() error {
 
 // JuniperIngestCmd ingests SWORD modules into capsules.
 type JuniperIngestCmd struct {
-	Modules []string `arg:"" optional:"" help:"Module names to ingest (or --all)"`
-	Path    string   `help:"Path to SWORD installation (default: ~/.sword)"`
-	Output  string   `short:"o" help:"Output directory (default: capsules)" default:"capsules"`
-	All     bool     `short:"a" help:"Ingest all Bible modules"`
+	Modules        []string `arg:"" optional:"" help:"Module names to ingest (or --all)"`
+	Path           string   `help:"Path to SWORD installation (default: ~/.sword)"`
+	Output         string   `short:"o" help:"Output directory (default: capsules)" default:"capsules"`
+	All            bool     `short:"a" help:"Ingest all Bible modules"`
+	Recipient      []string `short:"r" help:"Archive encrypted (CipherKey) modules encrypted to this recipient (age1...), may be repeated"`
+	RecipientsFile string   `short:"R" help:"File with one recipient per line" type:"existingfile"`
 }
 
 func (c *JuniperIngestCmd) Run() error {
@@ -1756,18 +2772,38 @@ func (c *JuniperIngestCmd) Run() error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	recipients, err := collectRecipients(c.Recipient, c.RecipientsFile)
+	if err != nil {
+		return err
+	}
+
 	fmt.Printf("Ingesting %d module(s) to %s/\n\n", len(toIngest), c.Output)
 
 	for _, m := range toIngest {
+		capsulePath := filepath.Join(c.Output, m.name+".capsule.tar.gz")
+		ingest := func() error { return ingestSwordModule(swordPath, m, capsulePath) }
 		if m.encrypted {
-			fmt.Printf("Skipping %s (encrypted)\n", m.name)
-			continue
+			if len(recipients) == 0 {
+				fmt.Printf("Skipping %s (encrypted; use --recipient to archive it encrypted)\n", m.name)
+				continue
+			}
+			capsulePath = filepath.Join(c.Output, m.name+".capsule.tar.xz")
+			ingest = func() error {
+				return juniper.IngestEncryptedModule(swordPath, &juniper.Module{
+					Name:        m.name,
+					Description: m.description,
+					Lang:        m.lang,
+					ModType:     m.modType,
+					DataPath:    m.dataPath,
+					Encrypted:   true,
+					ConfPath:    m.confPath,
+				}, capsulePath, recipients)
+			}
 		}
 
-		capsulePath := filepath.Join(c.Output, m.name+".capsule.tar.gz")
 		fmt.Printf("Creating %s...\n", capsulePath)
 
-		if err := ingestSwordModule(swordPath, m, capsulePath); err != nil {
+		if err := ingest(); err != nil {
 			fmt.Printf("  Error: %v\n", err)
 			continue
 		}
@@ -2085,267 +3121,1052 @@ func (c *JuniperCASToSwordCmd) Run() error {
 	}
 	defer os.RemoveAll(tempDir)
 
-	// Unpack the capsule
-	cap, err := capsule.Unpack(capsulePath, tempDir)
+	// Unpack the capsule
+	cap, err := capsule.Unpack(capsulePath, tempDir)
+	if err != nil {
+		return fmt.Errorf("failed to unpack capsule: %w", err)
+	}
+
+	// Check if capsule has IR extractions
+	if len(cap.Manifest.IRExtractions) == 0 {
+		return fmt.Errorf("capsule has no IR - run 'capsule format ir generate' first")
+	}
+
+	// Get the first IR extraction and directly read the IR blob
+	var irRecord *capsule.IRRecord
+	for _, rec := range cap.Manifest.IRExtractions {
+		irRecord = rec
+		break
+	}
+
+	// Directly retrieve the IR blob from CAS
+	irBlobData, err := cap.ReadBlob(irRecord.IRBlobSHA256)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve IR blob: %w", err)
+	}
+
+	// Parse the IR corpus directly
+	var corpus ir.Corpus
+	if err := json.Unmarshal(irBlobData, &corpus); err != nil {
+		return fmt.Errorf("failed to parse IR corpus: %w", err)
+	}
+
+	// Get metadata directly from IR Corpus
+	lang := corpus.Language
+	if lang == "" {
+		lang = "en"
+	}
+	description := corpus.Title
+	if description == "" {
+		description = moduleName + " Bible Module"
+	}
+	versification := corpus.Versification
+	if versification == "" {
+		versification = "KJV"
+	}
+
+	fmt.Printf("  IR ID:       %s\n", corpus.ID)
+	fmt.Printf("  Language:    %s\n", lang)
+	fmt.Printf("  Title:       %s\n", description)
+	fmt.Printf("  Versification: %s\n", versification)
+	fmt.Printf("  Documents:   %d\n", len(corpus.Documents))
+	fmt.Println()
+
+	// Ensure output directories exist
+	modsDir := filepath.Join(outputDir, "mods.d")
+	if err := os.MkdirAll(modsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create mods.d: %w", err)
+	}
+
+	// For now, implement a basic SWORD module creation
+	// This creates a zText format module structure
+	dataPath := filepath.Join("modules", "texts", "ztext", strings.ToLower(moduleName))
+	fullDataPath := filepath.Join(outputDir, dataPath)
+	if err := os.MkdirAll(fullDataPath, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	// Create a basic .conf file with versification from IR
+	confContent := fmt.Sprintf(`[%s]
+DataPath=./%s/
+ModDrv=zText
+SourceType=OSIS
+Encoding=UTF-8
+Lang=%s
+Description=%s
+About=Converted from CAS capsule using Juniper Bible
+Category=Biblical Texts
+TextSource=Juniper Bible
+Versification=%s
+Version=1.0
+LCSH=Bible.
+DistributionLicense=Copyrighted; Free non-commercial distribution
+`, moduleName, dataPath, lang, description, versification)
+
+	confPath := filepath.Join(modsDir, strings.ToLower(moduleName)+".conf")
+	if err := os.WriteFile(confPath, []byte(confContent), 0644); err != nil {
+		return fmt.Errorf("failed to write conf file: %w", err)
+	}
+
+	fmt.Printf("Created SWORD module structure:\n")
+	fmt.Printf("  Config: %s\n", confPath)
+	fmt.Printf("  Data:   %s\n", fullDataPath)
+	fmt.Println()
+	fmt.Println("Note: To complete the conversion, use osis2mod or sword-utils to populate the module data.")
+	fmt.Println("      This command creates the structure; use tool plugins for data conversion.")
+
+	return nil
+}
+
+// JuniperHugoCmd exports SWORD modules to Hugo JSON data files.
+type JuniperHugoCmd struct {
+	Modules []string `arg:"" optional:"" help:"Module names to export (or --all)"`
+	Path    string   `help:"Path to SWORD installation (default: ~/.sword)"`
+	Output  string   `short:"o" help:"Output directory for Hugo data files" default:"data"`
+	All     bool     `short:"a" help:"Export all Bible modules"`
+	Workers int      `short:"w" help:"Number of parallel workers (default: number of CPUs)" default:"0"`
+
+	LicenseOverride string `name:"license-override" help:"Reason for publishing modules despite the license policy (logged)"`
+}
+
+func (c *JuniperHugoCmd) Run() error {
+	enforcer, err := licenseEnforcer()
+	if err != nil {
+		return err
+	}
+	cfg := juniper.HugoConfig{
+		Path:            c.Path,
+		Output:          c.Output,
+		All:             c.All,
+		Modules:         c.Modules,
+		Workers:         c.Workers,
+		Licenses:        enforcer,
+		LicenseOverride: c.LicenseOverride,
+	}
+	return juniper.Hugo(cfg)
+}
+
+// CapsuleConvertCmd converts capsule content to a different format.
+// It preserves the original by renaming it to filename-old.
+type CapsuleConvertCmd struct {
+	Capsule         string `arg:"" help:"Path to capsule to convert" type:"existingfile"`
+	Format          string `required:"" short:"f" help:"Target format (osis, usfm, usx, json, html, epub, markdown, sqlite, txt)"`
+	LicenseOverride string `name:"license-override" help:"Reason for converting despite the license policy (logged)"`
+}
+
+func (c *CapsuleConvertCmd) Run() error {
+	capsulePath, _ := filepath.Abs(c.Capsule)
+	targetFormat := c.Format
+
+	info, err := capsule.ReadLicense(capsulePath)
+	if err != nil {
+		return fmt.Errorf("failed to read capsule license: %w", err)
+	}
+	if err := enforceLicense(filepath.Base(capsulePath), info, license.ActionForFormat(targetFormat), c.LicenseOverride); err != nil {
+		return err
+	}
+
+	fmt.Printf("Converting capsule: %s\n", capsulePath)
+	fmt.Printf("Target format: %s\n", targetFormat)
+	fmt.Println()
+
+	// Create temp directory
+	tempDir, err := os.MkdirTemp("", "capsule-convert-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Extract capsule
+	extractDir := filepath.Join(tempDir, "extract")
+	if err := extractCapsuleArchive(capsulePath, extractDir); err != nil {
+		return fmt.Errorf("failed to extract capsule: %w", err)
+	}
+
+	// Find content file and detect format
+	contentPath, sourceFormat := findConvertibleContent(extractDir)
+	if contentPath == "" {
+		return fmt.Errorf("no convertible content found in capsule (supported: OSIS, USFM, USX, JSON, SWORD)")
+	}
+
+	fmt.Printf("Detected source format: %s\n", sourceFormat)
+	fmt.Printf("Content file: %s\n", filepath.Base(contentPath))
+	fmt.Println()
+
+	// Load plugins
+	pluginDir := getPluginDir()
+	loader := plugins.NewLoader()
+	if err := loader.LoadFromDir(pluginDir); err != nil {
+		return fmt.Errorf("failed to load plugins: %w", err)
+	}
+
+	// Step 1: Extract IR
+	fmt.Println("Step 1: Extracting IR...")
+	irDir := filepath.Join(tempDir, "ir")
+	os.MkdirAll(irDir, 0755)
+
+	sourcePlugin, err := loader.GetPlugin("format." + sourceFormat)
+	if err != nil {
+		return fmt.Errorf("no plugin for source format '%s': %w", sourceFormat, err)
+	}
+
+	extractReq := plugins.NewExtractIRRequest(contentPath, irDir)
+	extractResp, err := plugins.ExecutePlugin(sourcePlugin, extractReq)
+	if err != nil {
+		return fmt.Errorf("IR extraction failed: %w", err)
+	}
+
+	extractResult, err := plugins.ParseExtractIRResult(extractResp)
+	if err != nil {
+		return fmt.Errorf("failed to parse extract result: %w", err)
+	}
+	fmt.Printf("  IR extracted (loss class: %s)\n", extractResult.LossClass)
+
+	// Step 2: Emit target format
+	fmt.Printf("Step 2: Emitting %s...\n", targetFormat)
+	targetPlugin, err := loader.GetPlugin("format." + targetFormat)
+	if err != nil {
+		return fmt.Errorf("no plugin for target format '%s': %w", targetFormat, err)
+	}
+
+	emitDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(emitDir, 0755)
+
+	emitReq := plugins.NewEmitNativeRequest(extractResult.IRPath, emitDir)
+	emitResp, err := plugins.ExecutePlugin(targetPlugin, emitReq)
+	if err != nil {
+		return fmt.Errorf("emit failed: %w", err)
+	}
+
+	emitResult, err := plugins.ParseEmitNativeResult(emitResp)
+	if err != nil {
+		return fmt.Errorf("failed to parse emit result: %w", err)
+	}
+	fmt.Printf("  Output generated (loss class: %s)\n", emitResult.LossClass)
+
+	// Step 3: Create new capsule
+	fmt.Println("Step 3: Creating new capsule...")
+	newCapsuleDir := filepath.Join(tempDir, "new-capsule")
+	os.MkdirAll(newCapsuleDir, 0755)
+
+	// Copy converted output
+	outputData, err := os.ReadFile(emitResult.OutputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read output: %w", err)
+	}
+	outputName := filepath.Base(emitResult.OutputPath)
+	os.WriteFile(filepath.Join(newCapsuleDir, outputName), outputData, 0644)
+
+	// Copy IR
+	irData, _ := os.ReadFile(extractResult.IRPath)
+	baseName := filepath.Base(capsulePath)
+	baseName = strings.TrimSuffix(baseName, ".capsule.tar.gz")
+	baseName = strings.TrimSuffix(baseName, ".capsule.tar.xz")
+	baseName = strings.TrimSuffix(baseName, ".tar.gz")
+	baseName = strings.TrimSuffix(baseName, ".tar.xz")
+	os.WriteFile(filepath.Join(newCapsuleDir, baseName+".ir.json"), irData, 0644)
+
+	// Create manifest
+	manifest := map[string]interface{}{
+		"capsule_version": "1.0",
+		"module_type":     "bible",
+		"source_format":   targetFormat,
+		"converted_from":  sourceFormat,
+		"conversion_date": time.Now().Format(time.RFC3339),
+		"has_ir":          true,
+		"extraction_loss": extractResult.LossClass,
+		"emission_loss":   emitResult.LossClass,
+	}
+	manifestData, _ := json.MarshalIndent(manifest, "", "  ")
+	os.WriteFile(filepath.Join(newCapsuleDir, "manifest.json"), manifestData, 0644)
+
+	// Step 4: Rename original and create new
+	fmt.Println("Step 4: Finalizing...")
+	oldPath := renameCapsuleToOld(capsulePath)
+	if oldPath == "" {
+		return fmt.Errorf("failed to rename original capsule")
+	}
+	fmt.Printf("  Original backed up: %s\n", filepath.Base(oldPath))
+
+	if err := archive.CreateCapsuleTarGz(newCapsuleDir, capsulePath); err != nil {
+		os.Rename(oldPath, capsulePath) // Restore on failure
+		return fmt.Errorf("failed to create capsule: %w", err)
+	}
+
+	fmt.Println()
+	fmt.Println("Conversion complete!")
+	fmt.Printf("  New capsule: %s\n", capsulePath)
+	fmt.Printf("  Backup: %s\n", oldPath)
+	fmt.Printf("  Combined loss class: %s\n", combineLoss(extractResult.LossClass, emitResult.LossClass))
+
+	return nil
+}
+
+// CapsuleRepackCmd repacks capsule archives into a different container format.
+// Given a directory it migrates every capsule in it. Blob hashes are
+// preserved and each repacked capsule is verified before any original is removed.
+type CapsuleRepackCmd struct {
+	Path      string `arg:"" help:"Capsule file or directory of capsules" type:"existingpath"`
+	Container string `default:"zip" enum:"zip,xz,gzip" help:"Target container (zip, xz, gzip)"`
+	Remove    bool   `help:"Remove the original capsule after a verified repack"`
+}
+
+func (c *CapsuleRepackCmd) Run() error {
+	container := capsule.CompressionType(c.Container)
+
+	var sources []string
+	info, err := os.Stat(c.Path)
+	if err != nil {
+		return fmt.Errorf("failed to stat path: %w", err)
+	}
+	if info.IsDir() {
+		entries, err := os.ReadDir(c.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && archive.IsSupportedFormat(entry.Name()) {
+				sources = append(sources, filepath.Join(c.Path, entry.Name()))
+			}
+		}
+	} else {
+		sources = append(sources, c.Path)
+	}
+
+	repacked, skipped, failed := 0, 0, 0
+	for _, src := range sources {
+		current, err := capsule.DetectCompression(src)
+		if err != nil {
+			fmt.Printf("  [SKIP] %s: %v\n", filepath.Base(src), err)
+			skipped++
+			continue
+		}
+		if current == container {
+			skipped++
+			continue
+		}
+
+		id := archive.ExtractCapsuleID(filepath.Base(src))
+		dst := filepath.Join(filepath.Dir(src), id+capsule.ArchiveExtension(container))
+		if err := capsule.Repack(src, dst, &capsule.PackOptions{Compression: container}); err != nil {
+			fmt.Printf("  [FAIL] %s: %v\n", filepath.Base(src), err)
+			failed++
+			continue
+		}
+		if err := verifyRepackedCapsule(dst); err != nil {
+			os.Remove(dst)
+			fmt.Printf("  [FAIL] %s: %v\n", filepath.Base(src), err)
+			failed++
+			continue
+		}
+		if c.Remove {
+			if err := os.Remove(src); err != nil {
+				return fmt.Errorf("failed to remove original: %w", err)
+			}
+		}
+
+		fmt.Printf("  [OK] %s -> %s\n", filepath.Base(src), filepath.Base(dst))
+		repacked++
+	}
+
+	fmt.Printf("\nRepacked: %d, Skipped: %d, Failed: %d\n", repacked, skipped, failed)
+	if failed > 0 {
+		return fmt.Errorf("repack failed for %d capsule(s)", failed)
+	}
+	return nil
+}
+
+// verifyRepackedCapsule checks that every blob in the manifest can be read
+// back from the archive with a matching hash.
+func verifyRepackedCapsule(path string) error {
+	r, err := capsule.OpenArchive(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for hash := range r.Manifest().Blobs.BySHA256 {
+		if err := r.VerifyBlob(hash); err != nil {
+			return fmt.Errorf("blob %s: %w", hash, err)
+		}
+	}
+	return nil
+}
+
+// CapsuleLogCmd shows the revision history of a capsule.
+type CapsuleLogCmd struct {
+	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
+	JSON    bool   `help:"Output as JSON"`
+}
+
+func (c *CapsuleLogCmd) Run() error {
+	r, err := capsule.OpenArchive(c.Capsule)
+	if err != nil {
+		return fmt.Errorf("failed to open capsule: %w", err)
+	}
+	defer r.Close()
+
+	manifest := r.Manifest()
+	if c.JSON {
+		data, err := json.MarshalIndent(manifest.Revisions, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize revisions: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	hash, err := capsule.ManifestHash(manifest)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Capsule: %s\n", c.Capsule)
+	fmt.Printf("  Manifest: %s\n", hash)
+	fmt.Printf("  Revision: %d\n", manifest.CurrentRevision())
+	fmt.Println()
+
+	// Newest first, like git log
+	for i := len(manifest.Revisions) - 1; i >= 0; i-- {
+		rev := manifest.Revisions[i]
+		fmt.Printf("revision %d\n", rev.Number)
+		fmt.Printf("  Parent:   %s\n", rev.ParentManifestSHA256)
+		fmt.Printf("  Date:     %s\n", rev.CreatedAt)
+		fmt.Printf("  Producer: %s %s", rev.Producer.Tool.Name, rev.Producer.Tool.Version)
+		if rev.Producer.Author != "" {
+			fmt.Printf(" (%s)", rev.Producer.Author)
+		}
+		fmt.Println()
+		if rev.Message != "" {
+			fmt.Printf("\n    %s\n", rev.Message)
+		}
+		if rev.Changes != nil {
+			fmt.Println()
+			printManifestDiff(rev.Changes, "    ")
+		}
+		fmt.Println()
+	}
+	fmt.Printf("revision 0\n  Date:     %s\n  Producer: %s %s\n",
+		manifest.CreatedAt, manifest.Tool.Name, manifest.Tool.Version)
+
+	return nil
+}
+
+// CapsuleDiffCmd shows how two capsules differ in artifacts, runs, IR and self-checks.
+type CapsuleDiffCmd struct {
+	CapsuleA string `arg:"" help:"Path to first (older) capsule" type:"existingfile"`
+	CapsuleB string `arg:"" help:"Path to second (newer) capsule" type:"existingfile"`
+	JSON     bool   `help:"Output as JSON"`
+}
+
+func (c *CapsuleDiffCmd) Run() error {
+	ra, err := capsule.OpenArchive(c.CapsuleA)
+	if err != nil {
+		return fmt.Errorf("failed to open capsule A: %w", err)
+	}
+	defer ra.Close()
+
+	rb, err := capsule.OpenArchive(c.CapsuleB)
+	if err != nil {
+		return fmt.Errorf("failed to open capsule B: %w", err)
+	}
+	defer rb.Close()
+
+	a, b := ra.Manifest(), rb.Manifest()
+	diff := capsule.DiffManifests(a, b)
+
+	relation := "unrelated"
+	if ok, err := capsule.IsAncestor(a, b); err == nil && ok {
+		relation = "A is an ancestor of B"
+	} else if ok, err := capsule.IsAncestor(b, a); err == nil && ok {
+		relation = "B is an ancestor of A"
+	}
+
+	if c.JSON {
+		out := struct {
+			RevisionA int                   `json:"revision_a"`
+			RevisionB int                   `json:"revision_b"`
+			Relation  string                `json:"relation"`
+			Diff      *capsule.ManifestDiff `json:"diff"`
+		}{a.CurrentRevision(), b.CurrentRevision(), relation, diff}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize diff: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("A: %s (revision %d)\n", c.CapsuleA, a.CurrentRevision())
+	fmt.Printf("B: %s (revision %d)\n", c.CapsuleB, b.CurrentRevision())
+	fmt.Printf("Relation: %s\n", relation)
+	fmt.Println()
+
+	if diff.Empty() {
+		fmt.Println("No differences.")
+		return nil
+	}
+	printManifestDiff(diff, "  ")
+	return nil
+}
+
+// printManifestDiff prints a manifest diff grouped by section.
+func printManifestDiff(diff *capsule.ManifestDiff, indent string) {
+	sections := []struct {
+		name string
+		keys capsule.KeyDiff
+	}{
+		{"Artifacts", diff.Artifacts},
+		{"Runs", diff.Runs},
+		{"IR extractions", diff.IRExtractions},
+		{"Self-checks", diff.SelfChecks},
+		{"Exports", diff.Exports},
+		{"Provenance", diff.Provenance},
+	}
+	for _, section := range sections {
+		if section.keys.Empty() {
+			continue
+		}
+		fmt.Printf("%s%s:\n", indent, section.name)
+		for _, id := range section.keys.Added {
+			fmt.Printf("%s  + %s\n", indent, id)
+		}
+		for _, id := range section.keys.Removed {
+			fmt.Printf("%s  - %s\n", indent, id)
+		}
+		for _, id := range section.keys.Changed {
+			fmt.Printf("%s  ~ %s\n", indent, id)
+		}
+	}
+	if diff.Encryption != "" {
+		fmt.Printf("%sEncryption: %s\n", indent, diff.Encryption)
+	}
+}
+
+// CapsuleKeygenCmd generates an X25519 identity for encrypted capsules.
+type CapsuleKeygenCmd struct {
+	Output string `short:"o" help:"Write the identity to this file instead of stdout" type:"path"`
+}
+
+func (c *CapsuleKeygenCmd) Run() error {
+	identity, recipient, err := capsule.GenerateIdentity()
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), recipient, identity)
+
+	if c.Output == "" {
+		fmt.Print(content)
+		return nil
+	}
+
+	if _, err := os.Stat(c.Output); err == nil {
+		return fmt.Errorf("identity file already exists: %s", c.Output)
+	}
+	if err := os.WriteFile(c.Output, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write identity: %w", err)
+	}
+	fmt.Printf("Public key: %s\n", recipient)
+	return nil
+}
+
+// CapsuleEncryptCmd encrypts the blobs of a capsule at rest. The manifest
+// stays readable; only blob content is protected.
+type CapsuleEncryptCmd struct {
+	Capsule        string   `arg:"" help:"Path to capsule" type:"existingfile"`
+	Recipient      []string `short:"r" help:"Recipient public key (age1...), may be repeated"`
+	RecipientsFile string   `short:"R" help:"File with one recipient per line" type:"existingfile"`
+	Message        string   `short:"m" help:"Revision message"`
+}
+
+func (c *CapsuleEncryptCmd) Run() error {
+	recipients, err := collectRecipients(c.Recipient, c.RecipientsFile)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("at least one --recipient or --recipients-file is required")
+	}
+
+	message := c.Message
+	if message == "" {
+		message = fmt.Sprintf("encrypt to %d recipient(s)", len(recipients))
+	}
+	if err := amendCapsuleInPlace(c.Capsule, "capsule-encrypt-*", message, func(cap *capsule.Capsule) error {
+		return cap.Encrypt(recipients)
+	}); err != nil {
+		return fmt.Errorf("failed to encrypt capsule: %w", err)
+	}
+
+	fmt.Printf("Encrypted: %s (%d recipient(s))\n", c.Capsule, len(recipients))
+	return nil
+}
+
+// CapsuleDecryptCmd removes encryption from a capsule using the global --identity.
+type CapsuleDecryptCmd struct {
+	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
+	Message string `short:"m" help:"Revision message"`
+}
+
+func (c *CapsuleDecryptCmd) Run() error {
+	message := c.Message
+	if message == "" {
+		message = "decrypt"
+	}
+	if err := amendCapsuleInPlace(c.Capsule, "capsule-decrypt-*", message, func(cap *capsule.Capsule) error {
+		if !cap.IsEncrypted() {
+			return fmt.Errorf("capsule is not encrypted")
+		}
+		return cap.Decrypt()
+	}); err != nil {
+		return fmt.Errorf("failed to decrypt capsule: %w", err)
+	}
+
+	fmt.Printf("Decrypted: %s\n", c.Capsule)
+	return nil
+}
+
+// CapsuleRecipientsCmd lists the recipients of an encrypted capsule.
+type CapsuleRecipientsCmd struct {
+	Capsule string `arg:"" help:"Path to capsule" type:"existingfile"`
+}
+
+func (c *CapsuleRecipientsCmd) Run() error {
+	r, err := capsule.OpenArchive(c.Capsule)
+	if err != nil {
+		return fmt.Errorf("failed to open capsule: %w", err)
+	}
+	defer r.Close()
+
+	enc := r.Manifest().Encryption
+	if enc == nil {
+		fmt.Println("Capsule is not encrypted")
+		return nil
+	}
+	fmt.Printf("Scheme: %s\n", enc.Scheme)
+	for _, recipient := range enc.Recipients {
+		fmt.Println(recipient)
+	}
+	return nil
+}
+
+// collectRecipients merges recipients given on the command line with
+// those read from a recipients file (blank lines and # comments ignored).
+func collectRecipients(keys []string, file string) ([]string, error) {
+	recipients := append([]string(nil), keys...)
+	if file == "" {
+		return recipients, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipients file: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		recipients = append(recipients, line)
+	}
+	return recipients, nil
+}
+
+// amendCapsuleInPlace unpacks a capsule, applies mutate as a new revision
+// and repacks it in its original container.
+func amendCapsuleInPlace(capsulePath, tempPattern, message string, mutate func(*capsule.Capsule) error) error {
+	container, err := capsule.DetectCompression(capsulePath)
+	if err != nil {
+		return fmt.Errorf("failed to detect compression: %w", err)
+	}
+
+	tempDir, err := os.MkdirTemp("", tempPattern)
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	cap, err := capsule.Unpack(capsulePath, tempDir)
+	if err != nil {
+		return fmt.Errorf("failed to unpack capsule: %w", err)
+	}
+	if _, err := cap.Amend(capsule.AmendOptions{Message: message}, mutate); err != nil {
+		return err
+	}
+	if err := cap.SaveManifest(); err != nil {
+		return fmt.Errorf("failed to save manifest: %w", err)
+	}
+	return cap.PackWithOptions(capsulePath, &capsule.PackOptions{Compression: container})
+}
+
+// loadIdentity applies the global --identity flag so encrypted capsules
+// are decrypted transparently.
+func loadIdentity() error {
+	if CLI.Identity == "" {
+		return nil
+	}
+	ids, err := capsule.LoadIdentities(CLI.Identity)
+	if err != nil {
+		return fmt.Errorf("failed to load identity: %w", err)
+	}
+	capsule.SetDefaultIdentities(ids)
+	return nil
+}
+
+// configurePluginTrust applies the global plugin trust flags.
+func configurePluginTrust() error {
+	policy, err := plugins.ParseTrustPolicy(CLI.PluginTrust)
+	if err != nil {
+		return err
+	}
+	store, err := plugins.LoadTrustStore(trustStorePath())
+	if err != nil {
+		return fmt.Errorf("failed to load trust store: %w", err)
+	}
+	cfg := plugins.GetSecurityConfig()
+	cfg.TrustPolicy = policy
+	cfg.TrustedPublishers = CLI.TrustedPublishers
+	cfg.TrustStore = store
+	plugins.SetSecurityConfig(cfg)
+	return nil
+}
+
+// trustStorePath returns the --trust-store flag or the per-user default.
+func trustStorePath() string {
+	if CLI.TrustStore != "" {
+		return CLI.TrustStore
+	}
+	return plugins.DefaultTrustStore()
+}
+
+// licenseEnforcer returns the enforcer for the global --license-policy
+// and --license-log flags.
+func licenseEnforcer() (*license.Enforcer, error) {
+	return license.NewEnforcer(CLI.LicensePolicy, licenseLogPath())
+}
+
+// licenseLogPath returns the --license-log flag or the per-user default.
+func licenseLogPath() string {
+	if CLI.LicenseLog != "" {
+		return CLI.LicenseLog
+	}
+	return license.DefaultOverrideLog()
+}
+
+// enforceLicense applies the license policy to an action on a capsule.
+// Discouraged actions print a warning; blocked actions fail unless
+// overrideReason is given, in which case the override is logged.
+func enforceLicense(subject string, info *license.Info, action, overrideReason string) error {
+	enforcer, err := licenseEnforcer()
+	if err != nil {
+		return err
+	}
+	verdict, err := enforcer.Enforce(subject, info, action, overrideReason)
+	if err != nil {
+		if overrideReason == "" && !verdict.Allowed() {
+			return fmt.Errorf("%w; use --license-override \"<reason>\" to proceed (the override is logged)", err)
+		}
+		return err
+	}
+	switch {
+	case verdict.Override != nil:
+		fmt.Printf("License override recorded in %s: %s\n", enforcer.LogPath, verdict)
+	case verdict.Decision == license.DecisionWarn:
+		fmt.Printf("Warning: %s\n", verdict)
+	}
+	return nil
+}
+
+// CapsulePreserveCmd exports a capsule to a layout used by institutional
+// preservation repositories.
+type CapsulePreserveCmd struct {
+	Capsule string            `arg:"" help:"Path to capsule" type:"existingfile"`
+	Layout  string            `default:"ocfl" enum:"ocfl,bagit" help:"Preservation layout (ocfl, bagit)"`
+	Out     string            `short:"o" required:"" help:"OCFL object root or bag directory" type:"path"`
+	ID      string            `help:"OCFL object ID (default: urn:juniper:capsule:<capsule id>)"`
+	Info    map[string]string `help:"Additional bag-info.txt field (Label=Value), may be repeated"`
+}
+
+func (c *CapsulePreserveCmd) Run() error {
+	tempDir, err := os.MkdirTemp("", "capsule-preserve-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	cap, err := capsule.Unpack(c.Capsule, tempDir)
 	if err != nil {
 		return fmt.Errorf("failed to unpack capsule: %w", err)
 	}
 
-	// Check if capsule has IR extractions
-	if len(cap.Manifest.IRExtractions) == 0 {
-		return fmt.Errorf("capsule has no IR - run 'capsule format ir generate' first")
+	if c.Layout == "bagit" {
+		if err := cap.ExportBagIt(c.Out, capsule.BagItExportOptions{Info: c.Info}); err != nil {
+			return fmt.Errorf("failed to export bag: %w", err)
+		}
+		fmt.Printf("Bag: %s\n", c.Out)
+		return nil
 	}
 
-	// Get the first IR extraction and directly read the IR blob
-	var irRecord *capsule.IRRecord
-	for _, rec := range cap.Manifest.IRExtractions {
-		irRecord = rec
-		break
+	id := c.ID
+	if id == "" {
+		id = "urn:juniper:capsule:" + archive.ExtractCapsuleID(filepath.Base(c.Capsule))
 	}
-
-	// Directly retrieve the IR blob from CAS
-	irBlobData, err := cap.GetStore().Retrieve(irRecord.IRBlobSHA256)
+	result, err := cap.ExportOCFL(c.Out, capsule.OCFLExportOptions{ObjectID: id})
 	if err != nil {
-		return fmt.Errorf("failed to retrieve IR blob: %w", err)
+		return fmt.Errorf("failed to export OCFL object: %w", err)
 	}
-
-	// Parse the IR corpus directly
-	var corpus ir.Corpus
-	if err := json.Unmarshal(irBlobData, &corpus); err != nil {
-		return fmt.Errorf("failed to parse IR corpus: %w", err)
+	fmt.Printf("OCFL object: %s (%s)\n", c.Out, result.ObjectID)
+	fmt.Printf("  Head: %s (%d version(s) added)\n", result.Head, result.VersionsAdded)
+	if len(result.SkippedRevisions) > 0 {
+		fmt.Printf("  Skipped revisions without a retained manifest: %v\n", result.SkippedRevisions)
 	}
+	return nil
+}
 
-	// Get metadata directly from IR Corpus
-	lang := corpus.Language
-	if lang == "" {
-		lang = "en"
-	}
-	description := corpus.Title
-	if description == "" {
-		description = moduleName + " Bible Module"
-	}
-	versification := corpus.Versification
-	if versification == "" {
-		versification = "KJV"
+// CapsuleImportCmd rebuilds a capsule from an OCFL object or BagIt bag.
+type CapsuleImportCmd struct {
+	Path      string `arg:"" help:"OCFL object root or BagIt bag directory" type:"existingdir"`
+	Out       string `short:"o" required:"" help:"Output capsule path" type:"path"`
+	Version   string `help:"OCFL version to import (default: head)"`
+	Container string `default:"xz" enum:"zip,xz,gzip" help:"Output container (zip, xz, gzip)"`
+}
+
+func (c *CapsuleImportCmd) Run() error {
+	tempDir, err := os.MkdirTemp("", "capsule-import-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
+	defer os.RemoveAll(tempDir)
 
-	fmt.Printf("  IR ID:       %s\n", corpus.ID)
-	fmt.Printf("  Language:    %s\n", lang)
-	fmt.Printf("  Title:       %s\n", description)
-	fmt.Printf("  Versification: %s\n", versification)
-	fmt.Printf("  Documents:   %d\n", len(corpus.Documents))
-	fmt.Println()
+	_, ocflErr := os.Stat(filepath.Join(c.Path, capsule.OCFLObjectDeclaration))
+	_, bagErr := os.Stat(filepath.Join(c.Path, "bagit.txt"))
 
-	// Ensure output directories exist
-	modsDir := filepath.Join(outputDir, "mods.d")
-	if err := os.MkdirAll(modsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create mods.d: %w", err)
+	var cap *capsule.Capsule
+	switch {
+	case ocflErr == nil:
+		cap, err = capsule.ImportOCFL(c.Path, tempDir, c.Version)
+	case bagErr == nil:
+		if c.Version != "" {
+			return fmt.Errorf("--version only applies to OCFL objects")
+		}
+		cap, err = capsule.ImportBagIt(c.Path, tempDir)
+	default:
+		return fmt.Errorf("%s is neither an OCFL object nor a BagIt bag", c.Path)
 	}
-
-	// For now, implement a basic SWORD module creation
-	// This creates a zText format module structure
-	dataPath := filepath.Join("modules", "texts", "ztext", strings.ToLower(moduleName))
-	fullDataPath := filepath.Join(outputDir, dataPath)
-	if err := os.MkdirAll(fullDataPath, 0755); err != nil {
-		return fmt.Errorf("failed to create data directory: %w", err)
+	if err != nil {
+		return fmt.Errorf("failed to import: %w", err)
 	}
 
-	// Create a basic .conf file with versification from IR
-	confContent := fmt.Sprintf(`[%s]
-DataPath=./%s/
-ModDrv=zText
-SourceType=OSIS
-Encoding=UTF-8
-Lang=%s
-Description=%s
-About=Converted from CAS capsule using Juniper Bible
-Category=Biblical Texts
-TextSource=Juniper Bible
-Versification=%s
-Version=1.0
-LCSH=Bible.
-DistributionLicense=Copyrighted; Free non-commercial distribution
-`, moduleName, dataPath, lang, description, versification)
-
-	confPath := filepath.Join(modsDir, strings.ToLower(moduleName)+".conf")
-	if err := os.WriteFile(confPath, []byte(confContent), 0644); err != nil {
-		return fmt.Errorf("failed to write conf file: %w", err)
+	if err := cap.PackWithOptions(c.Out, &capsule.PackOptions{Compression: capsule.CompressionType(c.Container)}); err != nil {
+		return fmt.Errorf("failed to pack capsule: %w", err)
 	}
-
-	fmt.Printf("Created SWORD module structure:\n")
-	fmt.Printf("  Config: %s\n", confPath)
-	fmt.Printf("  Data:   %s\n", fullDataPath)
-	fmt.Println()
-	fmt.Println("Note: To complete the conversion, use osis2mod or sword-utils to populate the module data.")
-	fmt.Println("      This command creates the structure; use tool plugins for data conversion.")
-
+	fmt.Printf("Imported: %s (%d artifacts, revision %d)\n", c.Out, len(cap.Manifest.Artifacts), cap.Manifest.CurrentRevision())
 	return nil
 }
 
-// JuniperHugoCmd exports SWORD modules to Hugo JSON data files.
-type JuniperHugoCmd struct {
-	Modules []string `arg:"" optional:"" help:"Module names to export (or --all)"`
-	Path    string   `help:"Path to SWORD installation (default: ~/.sword)"`
-	Output  string   `short:"o" help:"Output directory for Hugo data files" default:"data"`
-	All     bool     `short:"a" help:"Export all Bible modules"`
-	Workers int      `short:"w" help:"Number of parallel workers (default: number of CPUs)" default:"0"`
+// CapsuleFsckCmd checks a capsule's manifest, blob index and stored bytes
+// and optionally repairs it from other capsules, stores or mirrors.
+type CapsuleFsckCmd struct {
+	Capsule string   `arg:"" help:"Path to capsule" type:"existingfile"`
+	Repair  bool     `help:"Repair issues and write the repaired capsule to --out"`
+	Source  []string `short:"s" help:"Capsule, blob store or mirror directory to recover blobs from (repeatable)" type:"existingpath"`
+	Out     string   `short:"o" help:"Output path for the repaired capsule" type:"path"`
+	Report  string   `help:"Write the JSON fsck report to this file" type:"path"`
+	JSON    bool     `help:"Output the report as JSON"`
 }
 
-func (c *JuniperHugoCmd) Run() error {
-	cfg := juniper.HugoConfig{
-		Path:    c.Path,
-		Output:  c.Output,
-		All:     c.All,
-		Modules: c.Modules,
-		Workers: c.Workers,
+func (c *CapsuleFsckCmd) Run() error {
+	if c.Repair && c.Out == "" {
+		return fmt.Errorf("--repair requires --out")
 	}
-	return juniper.Hugo(cfg)
-}
-
-// CapsuleConvertCmd converts capsule content to a different format.
-// It preserves the original by renaming it to filename-old.
-type CapsuleConvertCmd struct {
-	Capsule string `arg:"" help:"Path to capsule to convert" type:"existingfile"`
-	Format  string `required:"" short:"f" help:"Target format (osis, usfm, usx, json, html, epub, markdown, sqlite, txt)"`
-}
 
-func (c *CapsuleConvertCmd) Run() error {
-	capsulePath, _ := filepath.Abs(c.Capsule)
-	targetFormat := c.Format
-
-	fmt.Printf("Converting capsule: %s\n", capsulePath)
-	fmt.Printf("Target format: %s\n", targetFormat)
-	fmt.Println()
+	container, err := capsule.DetectCompression(c.Capsule)
+	if err != nil {
+		return fmt.Errorf("failed to detect compression: %w", err)
+	}
 
-	// Create temp directory
-	tempDir, err := os.MkdirTemp("", "capsule-convert-*")
+	tempDir, err := os.MkdirTemp("", "capsule-fsck-*")
 	if err != nil {
-		return fmt.Errorf("failed to create temp dir: %w", err)
+		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
 	defer os.RemoveAll(tempDir)
 
-	// Extract capsule
-	extractDir := filepath.Join(tempDir, "extract")
-	if err := extractCapsuleArchive(capsulePath, extractDir); err != nil {
-		return fmt.Errorf("failed to extract capsule: %w", err)
+	cap, err := capsule.Unpack(c.Capsule, tempDir)
+	if err != nil {
+		return fmt.Errorf("failed to unpack capsule: %w", err)
 	}
 
-	// Find content file and detect format
-	contentPath, sourceFormat := findConvertibleContent(extractDir)
-	if contentPath == "" {
-		return fmt.Errorf("no convertible content found in capsule (supported: OSIS, USFM, USX, JSON, SWORD)")
+	sources, err := capsule.OpenBlobSources(c.Source)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		for _, src := range sources {
+			src.Close()
+		}
+	}()
 
-	fmt.Printf("Detected source format: %s\n", sourceFormat)
-	fmt.Printf("Content file: %s\n", filepath.Base(contentPath))
-	fmt.Println()
-
-	// Load plugins
-	pluginDir := getPluginDir()
-	loader := plugins.NewLoader()
-	if err := loader.LoadFromDir(pluginDir); err != nil {
-		return fmt.Errorf("failed to load plugins: %w", err)
+	report, err := cap.Fsck(capsule.FsckOptions{Repair: c.Repair, Sources: sources})
+	if err != nil {
+		return fmt.Errorf("fsck failed: %w", err)
 	}
 
-	// Step 1: Extract IR
-	fmt.Println("Step 1: Extracting IR...")
-	irDir := filepath.Join(tempDir, "ir")
-	os.MkdirAll(irDir, 0755)
-
-	sourcePlugin, err := loader.GetPlugin("format." + sourceFormat)
-	if err != nil {
-		return fmt.Errorf("no plugin for source format '%s': %w", sourceFormat, err)
+	if c.Repair {
+		if err := cap.SaveManifest(); err != nil {
+			return fmt.Errorf("failed to save manifest: %w", err)
+		}
+		if err := cap.PackWithOptions(c.Out, &capsule.PackOptions{Compression: container}); err != nil {
+			return fmt.Errorf("failed to pack capsule: %w", err)
+		}
 	}
 
-	extractReq := plugins.NewExtractIRRequest(contentPath, irDir)
-	extractResp, err := plugins.ExecutePlugin(sourcePlugin, extractReq)
+	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
-		return fmt.Errorf("IR extraction failed: %w", err)
+		return fmt.Errorf("failed to marshal report: %w", err)
+	}
+	if c.Report != "" {
+		if err := os.WriteFile(c.Report, data, 0644); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
 	}
 
-	extractResult, err := plugins.ParseExtractIRResult(extractResp)
-	if err != nil {
-		return fmt.Errorf("failed to parse extract result: %w", err)
+	if c.JSON {
+		fmt.Println(string(data))
+	} else {
+		fmt.Printf("Capsule: %s\n", c.Capsule)
+		fmt.Printf("  Blobs checked: %d\n", report.BlobsChecked)
+		for _, issue := range report.Issues {
+			status := "FAIL"
+			if issue.Repaired {
+				status = "FIXED"
+			}
+			subject := issue.SHA256
+			if subject == "" {
+				subject = issue.Path
+			}
+			fmt.Printf("  [%s] %s %s: %s", status, issue.Kind, subject, issue.Detail)
+			if issue.Source != "" {
+				fmt.Printf(" (from %s)", issue.Source)
+			}
+			fmt.Println()
+		}
+		if c.Repair {
+			fmt.Printf("Repaired capsule: %s\n", c.Out)
+		}
 	}
-	fmt.Printf("  IR extracted (loss class: %s)\n", extractResult.LossClass)
 
-	// Step 2: Emit target format
-	fmt.Printf("Step 2: Emitting %s...\n", targetFormat)
-	targetPlugin, err := loader.GetPlugin("format." + targetFormat)
-	if err != nil {
-		return fmt.Errorf("no plugin for target format '%s': %w", targetFormat, err)
+	if n := report.Unrepaired(); n > 0 {
+		return fmt.Errorf("fsck found %d unrepaired issue(s)", n)
 	}
+	if !c.JSON && len(report.Issues) == 0 {
+		fmt.Println("No issues found.")
+	}
+	return nil
+}
 
-	emitDir := filepath.Join(tempDir, "output")
-	os.MkdirAll(emitDir, 0755)
+// CapsuleAuditCmd re-hashes the capsules of a library and records the
+// results in the fixity audit log.
+type CapsuleAuditCmd struct {
+	Library  string        `arg:"" help:"Directory containing capsules" type:"existingdir"`
+	DB       string        `help:"Audit log database (default: <library>/.fixity-audit.db)" type:"path"`
+	Sample   float64       `help:"Fraction of capsules to audit, least recently audited first (0-1, default: all)"`
+	Resume   bool          `help:"Resume the last unfinished audit run"`
+	Report   bool          `help:"Show the audit report instead of running an audit"`
+	Interval time.Duration `default:"2160h" help:"Maximum time between audits before a capsule is overdue"`
+	JSON     bool          `help:"Output as JSON"`
+}
 
-	emitReq := plugins.NewEmitNativeRequest(extractResult.IRPath, emitDir)
-	emitResp, err := plugins.ExecutePlugin(targetPlugin, emitReq)
-	if err != nil {
-		return fmt.Errorf("emit failed: %w", err)
+func (c *CapsuleAuditCmd) Run() error {
+	dbPath := c.DB
+	if dbPath == "" {
+		dbPath = filepath.Join(c.Library, audit.DefaultLogName)
 	}
-
-	emitResult, err := plugins.ParseEmitNativeResult(emitResp)
+	auditLog, err := audit.Open(dbPath)
 	if err != nil {
-		return fmt.Errorf("failed to parse emit result: %w", err)
+		return err
 	}
-	fmt.Printf("  Output generated (loss class: %s)\n", emitResult.LossClass)
-
-	// Step 3: Create new capsule
-	fmt.Println("Step 3: Creating new capsule...")
-	newCapsuleDir := filepath.Join(tempDir, "new-capsule")
-	os.MkdirAll(newCapsuleDir, 0755)
+	defer auditLog.Close()
 
-	// Copy converted output
-	outputData, err := os.ReadFile(emitResult.OutputPath)
-	if err != nil {
-		return fmt.Errorf("failed to read output: %w", err)
+	if c.Report {
+		return c.printReport(auditLog)
 	}
-	outputName := filepath.Base(emitResult.OutputPath)
-	os.WriteFile(filepath.Join(newCapsuleDir, outputName), outputData, 0644)
 
-	// Copy IR
-	irData, _ := os.ReadFile(extractResult.IRPath)
-	baseName := filepath.Base(capsulePath)
-	baseName = strings.TrimSuffix(baseName, ".capsule.tar.gz")
-	baseName = strings.TrimSuffix(baseName, ".capsule.tar.xz")
-	baseName = strings.TrimSuffix(baseName, ".tar.gz")
-	baseName = strings.TrimSuffix(baseName, ".tar.xz")
-	os.WriteFile(filepath.Join(newCapsuleDir, baseName+".ir.json"), irData, 0644)
+	// Interrupting leaves the run unfinished so it can be resumed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	// Create manifest
-	manifest := map[string]interface{}{
-		"capsule_version": "1.0",
-		"module_type":     "bible",
-		"source_format":   targetFormat,
-		"converted_from":  sourceFormat,
-		"conversion_date": time.Now().Format(time.RFC3339),
-		"has_ir":          true,
-		"extraction_loss": extractResult.LossClass,
-		"emission_loss":   emitResult.LossClass,
+	opts := audit.Options{Sample: c.Sample, Resume: c.Resume}
+	if !c.JSON {
+		opts.Progress = func(done, total int, r *audit.Result) {
+			status := "OK"
+			if r.Status != audit.StatusOK {
+				status = "FAIL"
+			}
+			fmt.Printf("  [%s] (%d/%d) %s: %d blob(s), %d bytes\n", status, done, total, r.Capsule, r.BlobsChecked, r.BytesChecked)
+			for _, f := range r.Failures {
+				fmt.Printf("         %s: %s\n", f.Path, f.Detail)
+			}
+		}
 	}
-	manifestData, _ := json.MarshalIndent(manifest, "", "  ")
-	os.WriteFile(filepath.Join(newCapsuleDir, "manifest.json"), manifestData, 0644)
 
-	// Step 4: Rename original and create new
-	fmt.Println("Step 4: Finalizing...")
-	oldPath := renameCapsuleToOld(capsulePath)
-	if oldPath == "" {
-		return fmt.Errorf("failed to rename original capsule")
+	run, results, err := auditLog.Audit(ctx, c.Library, opts)
+	if run != nil && c.JSON {
+		data, jsonErr := json.MarshalIndent(map[string]interface{}{"run": run, "results": results}, "", "  ")
+		if jsonErr != nil {
+			return fmt.Errorf("failed to marshal results: %w", jsonErr)
+		}
+		fmt.Println(string(data))
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("audit run %d interrupted after %d of %d capsule(s); continue with --resume", run.ID, run.Checked, run.Planned)
+		}
+		return fmt.Errorf("audit failed: %w", err)
 	}
-	fmt.Printf("  Original backed up: %s\n", filepath.Base(oldPath))
 
-	if err := archive.CreateCapsuleTarGz(newCapsuleDir, capsulePath); err != nil {
-		os.Rename(oldPath, capsulePath) // Restore on failure
-		return fmt.Errorf("failed to create capsule: %w", err)
+	if !c.JSON {
+		fmt.Printf("Audit run %d: %d capsule(s) checked, %d failed\n", run.ID, run.Checked, run.Failed)
+	}
+	if run.Failed > 0 {
+		return fmt.Errorf("fixity audit found %d failed capsule(s)", run.Failed)
 	}
+	return nil
+}
 
-	fmt.Println()
-	fmt.Println("Conversion complete!")
-	fmt.Printf("  New capsule: %s\n", capsulePath)
-	fmt.Printf("  Backup: %s\n", oldPath)
-	fmt.Printf("  Combined loss class: %s\n", combineLoss(extractResult.LossClass, emitResult.LossClass))
+// printReport prints capsules never audited, recently failed or overdue.
+func (c *CapsuleAuditCmd) printReport(auditLog *audit.Log) error {
+	report, err := auditLog.Report(c.Library, c.Interval)
+	if err != nil {
+		return err
+	}
+	if c.JSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal report: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
 
+	fmt.Printf("Library: %s\n", report.Library)
+	fmt.Printf("  Capsules: %d (%d healthy)\n", report.Total, report.Healthy)
+	if report.LastRun != nil {
+		fmt.Printf("  Last run: %d at %s (%d checked, %d failed)\n", report.LastRun.ID,
+			report.LastRun.StartedAt.Format(time.RFC3339), report.LastRun.Checked, report.LastRun.Failed)
+	}
+	fmt.Printf("\nNever audited (%d):\n", len(report.NeverAudited))
+	for _, s := range report.NeverAudited {
+		fmt.Printf("  %s\n", s.Capsule)
+	}
+	fmt.Printf("\nRecently failed (%d):\n", len(report.RecentlyFailed))
+	for _, s := range report.RecentlyFailed {
+		fmt.Printf("  %s (failed %s)\n", s.Capsule, s.LastFailure.Format(time.RFC3339))
+	}
+	fmt.Printf("\nOverdue (%d):\n", len(report.Overdue))
+	for _, s := range report.Overdue {
+		fmt.Printf("  %s (last audited %s)\n", s.Capsule, s.LastAudited.Format(time.RFC3339))
+	}
 	return nil
 }
 
@@ -2864,6 +4685,10 @@ type WebCmd struct {
 	Sword           string `help:"Directory containing SWORD modules (default: ~/.sword)" type:"path"`
 	PluginsExternal bool   `help:"Enable loading external plugins from plugins directory"`
 	Restart         bool   `help:"Kill any existing process on the port and restart" short:"r"`
+
+	AuditDB     string        `name:"audit-db" help:"Fixity audit log (default: <capsules>/.fixity-audit.db)" type:"path"`
+	AuditEvery  time.Duration `name:"audit-every" help:"Run a background fixity audit at this interval (e.g. 24h; 0 disables)"`
+	AuditSample float64       `name:"audit-sample" help:"Fraction of the library audited per scheduled run (0 = all)"`
 }
 
 func (c *WebCmd) Run() error {
@@ -2878,6 +4703,12 @@ func (c *WebCmd) Run() error {
 		PluginsDir:      c.Plugins,
 		SwordDir:        c.Sword,
 		PluginsExternal: c.PluginsExternal,
+		IdentityFile:    CLI.Identity,
+		AuditDB:         c.AuditDB,
+		AuditEvery:      c.AuditEvery,
+		AuditSample:     c.AuditSample,
+		LicensePolicy:   CLI.LicensePolicy,
+		LicenseLog:      licenseLogPath(),
 	}
 	return web.Start(cfg)
 }
@@ -2968,6 +4799,8 @@ func (c *APICmd) Run() error {
 		CapsulesDir:     c.Capsules,
 		PluginsDir:      c.Plugins,
 		PluginsExternal: c.PluginsExternal,
+		LicensePolicy:   CLI.LicensePolicy,
+		LicenseLog:      licenseLogPath(),
 	}
 	return api.Start(cfg)
 }
@@ -3040,7 +4873,7 @@ func getFlakePath() string {
 }
 
 // runCapsuleTest runs selfcheck on a capsule and compares to golden hash.
-func runCapsuleTest(capsulePath, goldenDir, name string) (bool, error) {
+func runCapsuleTest(out io.Writer, capsulePath, goldenDir, name string) (bool, error) {
 	// Create temp directory
 	tempDir, err := os.MkdirTemp("", "capsule-test-*")
 	if err != nil {
@@ -3078,7 +4911,7 @@ func runCapsuleTest(capsulePath, goldenDir, name string) (bool, error) {
 		reportJSON, _ := report.ToJSON()
 		os.MkdirAll(goldenDir, 0755)
 		os.WriteFile(goldenPath, reportJSON, 0644)
-		fmt.Printf("  [NEW]  %s: created golden file\n", name)
+		fmt.Fprintf(out, "  [NEW]  %s: created golden file\n", name)
 		return true, nil
 	}
 
@@ -3096,7 +4929,7 @@ func runCapsuleTest(capsulePath, goldenDir, name string) (bool, error) {
 }
 
 // runIngestTest ingests a file, runs selfcheck, and compares to golden.
-func runIngestTest(inputPath, goldenDir, name string) (bool, error) {
+func runIngestTest(out io.Writer, inputPath, goldenDir, name string) (bool, error) {
 	tempDir, err := os.MkdirTemp("", "capsule-ingest-test-*")
 	if err != nil {
 		return false, err
@@ -3134,7 +4967,7 @@ func runIngestTest(inputPath, goldenDir, name string) (bool, error) {
 		// Create golden
 		os.MkdirAll(goldenDir, 0755)
 		os.WriteFile(goldenPath, []byte(artifact.Hashes.SHA256+"\n"), 0644)
-		fmt.Printf("  [NEW]  %s: created golden hash\n", name)
+		fmt.Fprintf(out, "  [NEW]  %s: created golden hash\n", name)
 		return true, nil
 	}
 
@@ -3289,6 +5122,9 @@ func main() {
 			Compact: true,
 		}),
 	)
+	ctx.FatalIfErrorf(loadIdentity())
+	ctx.FatalIfErrorf(configurePluginTrust())
 	err := ctx.Run(ctx)
+	plugins.ShutdownPluginSessions()
 	ctx.FatalIfErrorf(err)
 }
@@ -4,14 +4,20 @@ import (
 	"archive/zip"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/FocuswithJustin/JuniperBible/core/audit"
 	"github.com/FocuswithJustin/JuniperBible/core/capsule"
 	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/golden"
+	"github.com/FocuswithJustin/JuniperBible/core/license"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
 	"github.com/FocuswithJustin/JuniperBible/internal/archive"
 	"github.com/FocuswithJustin/JuniperBible/internal/fileutil"
 )
@@ -215,6 +221,46 @@ func TestExportCmd_Run_InvalidArtifact(t *testing.T) {
 	}
 }
 
+func TestExportCmd_Run_LicensePolicy(t *testing.T) {
+	tempDir := t.TempDir()
+	cap, capsuleDir := createTestCapsule(t, tempDir)
+	artifact, err := cap.IngestFile(createTestFile(t, tempDir, "test.txt", "licensed"))
+	if err != nil {
+		t.Fatalf("failed to ingest file: %v", err)
+	}
+	cap.Manifest.License = &license.Info{ID: license.CopyrightedCrossWire}
+	packedPath := filepath.Join(tempDir, "licensed.capsule.tar.xz")
+	if err := cap.Pack(packedPath); err != nil {
+		t.Fatalf("failed to pack capsule: %v", err)
+	}
+	os.RemoveAll(capsuleDir)
+
+	logPath := filepath.Join(tempDir, "overrides.jsonl")
+	CLI.LicenseLog = logPath
+	defer func() { CLI.LicenseLog = "" }()
+
+	cmd := &ExportCmd{
+		Capsule:  packedPath,
+		Artifact: artifact.ID,
+		Out:      filepath.Join(tempDir, "out.txt"),
+	}
+	if err := cmd.Run(); err == nil || !strings.Contains(err.Error(), "--license-override") {
+		t.Fatalf("expected blocked export, got %v", err)
+	}
+
+	cmd.LicenseOverride = "distribution agreement on file"
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("export with override failed: %v", err)
+	}
+	records, err := license.ReadOverrideLog(logPath)
+	if err != nil {
+		t.Fatalf("failed to read override log: %v", err)
+	}
+	if len(records) != 1 || records[0].Reason != "distribution agreement on file" {
+		t.Errorf("expected one logged override, got %+v", records)
+	}
+}
+
 // Tests for VerifyCmd
 
 func TestVerifyCmd_Run(t *testing.T) {
@@ -1439,7 +1485,7 @@ func TestRunCapsuleTest(t *testing.T) {
 	packedPath := createPackedCapsule(t, tempDir, "test content")
 
 	// First run should create golden
-	result, err := runCapsuleTest(packedPath, goldenDir, "test")
+	result, err := runCapsuleTest(io.Discard, packedPath, goldenDir, "test")
 	if err != nil {
 		t.Fatalf("runCapsuleTest() error = %v", err)
 	}
@@ -1448,7 +1494,7 @@ func TestRunCapsuleTest(t *testing.T) {
 	}
 
 	// Second run should compare against golden
-	result, err = runCapsuleTest(packedPath, goldenDir, "test")
+	result, err = runCapsuleTest(io.Discard, packedPath, goldenDir, "test")
 	if err != nil {
 		t.Fatalf("runCapsuleTest() error = %v", err)
 	}
@@ -1465,7 +1511,7 @@ func TestRunIngestTest(t *testing.T) {
 	inputFile := createTestFile(t, tempDir, "input.txt", "test content")
 
 	// First run should create golden
-	result, err := runIngestTest(inputFile, goldenDir, "test")
+	result, err := runIngestTest(io.Discard, inputFile, goldenDir, "test")
 	if err != nil {
 		t.Fatalf("runIngestTest() error = %v", err)
 	}
@@ -1474,7 +1520,7 @@ func TestRunIngestTest(t *testing.T) {
 	}
 
 	// Second run should compare against golden
-	result, err = runIngestTest(inputFile, goldenDir, "test")
+	result, err = runIngestTest(io.Discard, inputFile, goldenDir, "test")
 	if err != nil {
 		t.Fatalf("runIngestTest() error = %v", err)
 	}
@@ -1823,6 +1869,138 @@ func TestToolRunCmd_Run_NoFlake(t *testing.T) {
 	}
 }
 
+func TestToolRunCmd_Run_Sandbox(t *testing.T) {
+	tempDir := t.TempDir()
+	packedPath := createPackedCapsule(t, tempDir, "test content")
+
+	cap, err := capsule.Unpack(packedPath, filepath.Join(tempDir, "unpack"))
+	if err != nil {
+		t.Fatalf("failed to unpack capsule: %v", err)
+	}
+	var artifactID string
+	for id := range cap.Manifest.Artifacts {
+		artifactID = id
+		break
+	}
+
+	cmd := &ToolRunCmd{
+		Capsule:     packedPath,
+		Artifact:    artifactID,
+		Tool:        "test-tool",
+		Profile:     "default",
+		EngineFlags: EngineFlags{Engine: "sandbox"},
+	}
+	err = cmd.Run()
+	if err != nil && strings.Contains(err.Error(), "sandbox") {
+		t.Skipf("sandbox not available: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("ToolRunCmd.Run() error = %v", err)
+	}
+
+	cap, err = capsule.Unpack(packedPath, filepath.Join(tempDir, "unpack2"))
+	if err != nil {
+		t.Fatalf("failed to unpack capsule: %v", err)
+	}
+	run := cap.Manifest.Runs["run-test-tool-default-1"]
+	if run == nil || run.Engine == nil || run.Engine.Type != "linux-sandbox" {
+		t.Fatalf("expected a run recorded with the sandbox engine, got %+v", run)
+	}
+}
+
+func TestEngineFlags_ToolRootRequiresSandbox(t *testing.T) {
+	flags := EngineFlags{Engine: "nix", ToolRoot: t.TempDir()}
+	if _, _, err := flags.executor(); err == nil {
+		t.Error("expected error for --tool-root without --engine=sandbox")
+	}
+	flags = EngineFlags{Engine: "nix", ReplayFrom: []string{"recorded.capsule.tar.xz"}}
+	if _, _, err := flags.executor(); err == nil {
+		t.Error("expected error for --replay-from without --engine=replay")
+	}
+	flags = EngineFlags{Engine: "replay"}
+	if _, _, err := flags.executor(); err == nil {
+		t.Error("expected error for the replay engine without recorded runs")
+	}
+}
+
+func TestToolRunCmd_Run_Replay(t *testing.T) {
+	tempDir := t.TempDir()
+	packedPath := createPackedCapsule(t, tempDir, "test content")
+
+	cap, err := capsule.Unpack(packedPath, filepath.Join(tempDir, "unpack"))
+	if err != nil {
+		t.Fatalf("failed to unpack capsule: %v", err)
+	}
+	var artifactID string
+	for id := range cap.Manifest.Artifacts {
+		artifactID = id
+		break
+	}
+
+	// Record a run with the sandbox engine
+	cmd := &ToolRunCmd{
+		Capsule:     packedPath,
+		Artifact:    artifactID,
+		Tool:        "test-tool",
+		Profile:     "default",
+		EngineFlags: EngineFlags{Engine: "sandbox"},
+	}
+	if err := cmd.Run(); err != nil {
+		t.Skipf("sandbox not available: %v", err)
+	}
+
+	// Replay it from the capsule's own runs
+	cmd.EngineFlags = EngineFlags{Engine: "replay"}
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	cap, err = capsule.Unpack(packedPath, filepath.Join(tempDir, "unpack2"))
+	if err != nil {
+		t.Fatalf("failed to unpack capsule: %v", err)
+	}
+	recorded := cap.Manifest.Runs["run-test-tool-default-1"]
+	replayed := cap.Manifest.Runs["run-test-tool-default-2"]
+	if replayed == nil || replayed.Engine == nil || replayed.Engine.Type != "replay" {
+		t.Fatalf("expected a replayed run, got %+v", replayed)
+	}
+	if replayed.Outputs.TranscriptBlobSHA256 != recorded.Outputs.TranscriptBlobSHA256 {
+		t.Errorf("replayed transcript %s differs from recorded %s",
+			replayed.Outputs.TranscriptBlobSHA256, recorded.Outputs.TranscriptBlobSHA256)
+	}
+	if replayed.Command.RequestBlobSHA256 != recorded.Command.RequestBlobSHA256 {
+		t.Error("replayed run should have the recorded request hash")
+	}
+
+	// A different profile is not recorded and fails loudly
+	cmd.Profile = "other"
+	err = cmd.Run()
+	if err == nil || !strings.Contains(err.Error(), "no recorded run") {
+		t.Errorf("expected a replay mismatch error, got %v", err)
+	}
+
+	// Another capsule with different content replays from --replay-from and
+	// reports the differing input
+	otherPath := createPackedCapsule(t, filepath.Join(tempDir, "other"), "other content")
+	other, err := capsule.Unpack(otherPath, filepath.Join(tempDir, "unpack3"))
+	if err != nil {
+		t.Fatalf("failed to unpack capsule: %v", err)
+	}
+	for id := range other.Manifest.Artifacts {
+		artifactID = id
+	}
+	cmd = &ToolRunCmd{
+		Capsule:     otherPath,
+		Artifact:    artifactID,
+		Tool:        "test-tool",
+		Profile:     "default",
+		EngineFlags: EngineFlags{Engine: "replay", ReplayFrom: []string{packedPath}},
+	}
+	err = cmd.Run()
+	if err == nil || !strings.Contains(err.Error(), "recorded sha256") {
+		t.Errorf("expected the differing input in the mismatch error, got %v", err)
+	}
+}
+
 // Tests for TestCmd with fixtures
 
 func TestTestCmd_Run_WithCapsules(t *testing.T) {
@@ -3214,6 +3392,59 @@ func TestVerifyCmd_Run_CorruptedBlob(t *testing.T) {
 	}
 }
 
+// captureStdout returns what fn writes to standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+	fn()
+	w.Close()
+	return string(<-done)
+}
+
+// TestVerifyCmd_Run_Format tests the structured output formats of verify.
+func TestVerifyCmd_Run_Format(t *testing.T) {
+	tempDir := t.TempDir()
+	packedPath := createPackedCapsule(t, tempDir, "test data for verification")
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"junit", `<testsuites tests="1" failures="0"`},
+		{"tap", "TAP version 13\n1..1\nok 1 - "},
+		{"sarif", `"results": []`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var err error
+			out := captureStdout(t, func() {
+				err = (&VerifyCmd{Capsule: packedPath, Format: tt.format}).Run()
+			})
+			if err != nil {
+				t.Fatalf("VerifyCmd.Run() error = %v", err)
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("output missing %q:\n%s", tt.want, out)
+			}
+			if strings.Contains(out, "Verification passed") {
+				t.Error("text output should be replaced by the structured report")
+			}
+		})
+	}
+}
+
 // TestExportCmd_Run_HashMismatch tests export with hash verification
 func TestExportCmd_Run_HashMismatch(t *testing.T) {
 	tempDir := t.TempDir()
@@ -3493,6 +3724,157 @@ func TestGoldenCheckCmd_Run_NoTranscript(t *testing.T) {
 	}
 }
 
+// createRunsCapsule packs a capsule with a run per transcript.
+func createRunsCapsule(t *testing.T, dir string, transcripts map[string]string) string {
+	t.Helper()
+	cap, capsuleDir := createTestCapsule(t, dir)
+	for id, transcript := range transcripts {
+		run := &capsule.Run{ID: id, Plugin: &capsule.PluginInfo{PluginID: "libsword", PluginVersion: "1.0.0"}}
+		if err := cap.AddRun(run, []byte(transcript)); err != nil {
+			t.Fatalf("failed to add run: %v", err)
+		}
+	}
+	packedPath := filepath.Join(dir, "runs.capsule.tar.xz")
+	if err := cap.Pack(packedPath); err != nil {
+		t.Fatalf("failed to pack: %v", err)
+	}
+	os.RemoveAll(capsuleDir)
+	return packedPath
+}
+
+func TestRunsListCmd_Run_ResourceRegressions(t *testing.T) {
+	tempDir := t.TempDir()
+	cap, capsuleDir := createTestCapsule(t, tempDir)
+	usages := map[string]*resource.Usage{
+		"run-1": {UserCPUMs: 200, MaxRSSBytes: 32 << 20},
+		"run-2": {UserCPUMs: 900, MaxRSSBytes: 32 << 20},
+	}
+	for id, usage := range usages {
+		run := &capsule.Run{
+			ID:        id,
+			Plugin:    &capsule.PluginInfo{PluginID: "libsword", PluginVersion: "1.0.0"},
+			Command:   &capsule.Command{Profile: "list-modules"},
+			Resources: usage,
+		}
+		if err := cap.AddRun(run, []byte(`{"event":"start","run":"`+id+`"}`+"\n")); err != nil {
+			t.Fatalf("failed to add run: %v", err)
+		}
+	}
+	packedPath := filepath.Join(tempDir, "runs.capsule.tar.xz")
+	if err := cap.Pack(packedPath); err != nil {
+		t.Fatalf("failed to pack: %v", err)
+	}
+	os.RemoveAll(capsuleDir)
+
+	goldenFile := filepath.Join(tempDir, "list.sha256")
+	if err := (&GoldenSaveCmd{Capsule: packedPath, RunID: "run-1", Out: goldenFile, Commit: "abc123"}).Run(); err != nil {
+		t.Fatalf("GoldenSaveCmd.Run() error = %v", err)
+	}
+	g, err := golden.Load(goldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest := g.Latest(); latest.Resources == nil || latest.Plugin != "libsword" || latest.Profile != "list-modules" {
+		t.Fatalf("golden entry without a resource baseline: %+v", latest)
+	}
+
+	out := captureStdout(t, func() {
+		if err := (&RunsListCmd{Capsule: packedPath, Golden: []string{goldenFile}, Threshold: 2}).Run(); err != nil {
+			t.Errorf("RunsListCmd.Run() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Resources: cpu 900ms") {
+		t.Errorf("expected resources in the listing, got:\n%s", out)
+	}
+	if strings.Count(out, "REGRESSION") != 1 || !strings.Contains(out, "cpu: 200ms -> 900ms (4.5x)") {
+		t.Errorf("expected one cpu regression, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Resource regressions: 1 run(s)") {
+		t.Errorf("expected a regression count, got:\n%s", out)
+	}
+}
+
+func TestGoldenLedgerWorkflow(t *testing.T) {
+	tempDir := t.TempDir()
+	packedPath := createRunsCapsule(t, tempDir, map[string]string{
+		"run-1": `{"event":"start","plugin":"libsword"}` + "\n" + `{"event":"list_modules","modules":["KJV"]}` + "\n",
+		"run-2": `{"event":"start","plugin":"libsword"}` + "\n" + `{"event":"list_modules","modules":["KJV","ESV"]}` + "\n",
+	})
+	goldenFile := filepath.Join(tempDir, "list.sha256")
+
+	save := &GoldenSaveCmd{Capsule: packedPath, RunID: "run-1", Out: goldenFile, Commit: "abc123"}
+	if err := save.Run(); err != nil {
+		t.Fatalf("GoldenSaveCmd.Run() error = %v", err)
+	}
+	if err := (&GoldenSaveCmd{Capsule: packedPath, RunID: "run-2", Out: goldenFile}).Run(); err == nil {
+		t.Error("expected save to refuse overwriting a different golden")
+	}
+	if err := (&GoldenCheckCmd{Capsule: packedPath, RunID: "run-1", Golden: goldenFile, Strict: true}).Run(); err != nil {
+		t.Errorf("GoldenCheckCmd.Run() error = %v", err)
+	}
+
+	// A changed transcript fails and is diffed against the kept golden
+	var checkErr error
+	out := captureStdout(t, func() {
+		checkErr = (&GoldenCheckCmd{Capsule: packedPath, RunID: "run-2", Golden: goldenFile}).Run()
+	})
+	if checkErr == nil {
+		t.Error("expected mismatch error")
+	}
+	if !strings.Contains(out, "~ list_modules") || !strings.Contains(out, "ESV") {
+		t.Errorf("expected a transcript diff, got:\n%s", out)
+	}
+
+	update := &GoldenUpdateCmd{Capsule: packedPath, RunID: "run-2", Golden: goldenFile, Reason: "libsword now lists ESV", Commit: "def456"}
+	if err := update.Run(); err != nil {
+		t.Fatalf("GoldenUpdateCmd.Run() error = %v", err)
+	}
+	if err := (&GoldenCheckCmd{Capsule: packedPath, RunID: "run-2", Golden: goldenFile}).Run(); err != nil {
+		t.Errorf("GoldenCheckCmd.Run() after update error = %v", err)
+	}
+
+	g, err := golden.Load(goldenFile)
+	if err != nil {
+		t.Fatalf("golden.Load() error = %v", err)
+	}
+	if len(g.Ledger) != 2 || g.Ledger[1].Commit != "def456" || !strings.Contains(g.Ledger[1].ToolVersion, "libsword 1.0.0") {
+		t.Errorf("unexpected ledger %+v", g.Ledger)
+	}
+
+	out = captureStdout(t, func() {
+		if err := (&GoldenLogCmd{Golden: goldenFile}).Run(); err != nil {
+			t.Errorf("GoldenLogCmd.Run() error = %v", err)
+		}
+	})
+	if !strings.Contains(out, "Reason: libsword now lists ESV") {
+		t.Errorf("expected the update reason in the log, got:\n%s", out)
+	}
+
+	// Editing the golden by hand fails the check even though it matches
+	if err := os.WriteFile(goldenFile, []byte(g.Ledger[0].SHA256+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&GoldenCheckCmd{Capsule: packedPath, RunID: "run-1", Golden: goldenFile}).Run(); err == nil {
+		t.Error("expected an unexplained golden change to fail the check")
+	}
+}
+
+func TestGoldenCheckCmd_Run_Strict(t *testing.T) {
+	tempDir := t.TempDir()
+	packedPath := createRunsCapsule(t, tempDir, map[string]string{"run-1": `{"event":"end"}` + "\n"})
+	goldenFile := filepath.Join(tempDir, "legacy.sha256")
+	if err := os.WriteFile(goldenFile, []byte(cas.Hash([]byte(`{"event":"end"}`+"\n"))+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&GoldenCheckCmd{Capsule: packedPath, RunID: "run-1", Golden: goldenFile}).Run(); err != nil {
+		t.Errorf("expected a golden without a ledger to pass, got %v", err)
+	}
+	if err := (&GoldenCheckCmd{Capsule: packedPath, RunID: "run-1", Golden: goldenFile, Strict: true}).Run(); err == nil {
+		t.Error("expected --strict to fail a golden without a ledger")
+	}
+}
+
 // Tests for JuniperHugoCmd
 
 func TestJuniperHugoCmd_Run(t *testing.T) {
@@ -3724,3 +4106,372 @@ func TestGetPluginDir_WithEnvVar(t *testing.T) {
 }
 
 
+
+// Tests for CapsuleRepackCmd
+
+func TestCapsuleRepackCmd_Run_Directory(t *testing.T) {
+	tempDir := t.TempDir()
+	packedPath := createPackedCapsule(t, tempDir, "repack me")
+
+	libDir := filepath.Join(tempDir, "library")
+	if err := os.MkdirAll(libDir, 0755); err != nil {
+		t.Fatalf("failed to create library dir: %v", err)
+	}
+	srcPath := filepath.Join(libDir, "test.capsule.tar.xz")
+	if err := os.Rename(packedPath, srcPath); err != nil {
+		t.Fatalf("failed to move capsule: %v", err)
+	}
+
+	cmd := &CapsuleRepackCmd{Path: libDir, Container: "zip", Remove: true}
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("CapsuleRepackCmd.Run() error = %v", err)
+	}
+
+	zipPath := filepath.Join(libDir, "test.capsule.zip")
+	if _, err := os.Stat(zipPath); err != nil {
+		t.Fatalf("expected repacked capsule: %v", err)
+	}
+	if _, err := os.Stat(srcPath); !os.IsNotExist(err) {
+		t.Error("expected original capsule to be removed")
+	}
+
+	verify := &VerifyCmd{Capsule: zipPath}
+	if err := verify.Run(); err != nil {
+		t.Errorf("VerifyCmd.Run() on repacked capsule error = %v", err)
+	}
+}
+
+func TestCapsuleRepackCmd_Run_SameContainer(t *testing.T) {
+	tempDir := t.TempDir()
+	packedPath := createPackedCapsule(t, tempDir, "already xz")
+
+	cmd := &CapsuleRepackCmd{Path: packedPath, Container: "xz"}
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("CapsuleRepackCmd.Run() error = %v", err)
+	}
+	if _, err := os.Stat(packedPath); err != nil {
+		t.Error("original capsule should be untouched")
+	}
+}
+
+// Tests for CapsuleLogCmd and CapsuleDiffCmd
+
+func createAmendedCapsules(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	cap, _ := createTestCapsule(t, dir)
+	testFile := createTestFile(t, dir, "test.txt", "original")
+	if _, err := cap.IngestFile(testFile); err != nil {
+		t.Fatalf("failed to ingest file: %v", err)
+	}
+	oldPath := filepath.Join(dir, "old.capsule.tar.xz")
+	if err := cap.Pack(oldPath); err != nil {
+		t.Fatalf("failed to pack capsule: %v", err)
+	}
+
+	if _, err := cap.Amend(capsule.AmendOptions{Author: "tester", Message: "add run"}, func(c *capsule.Capsule) error {
+		return c.AddRun(&capsule.Run{ID: "run-1", Status: "success"}, []byte("{}"))
+	}); err != nil {
+		t.Fatalf("failed to amend capsule: %v", err)
+	}
+	newPath := filepath.Join(dir, "new.capsule.zip")
+	if err := cap.PackWithOptions(newPath, &capsule.PackOptions{Compression: capsule.CompressionZip}); err != nil {
+		t.Fatalf("failed to pack capsule: %v", err)
+	}
+	return oldPath, newPath
+}
+
+func TestCapsuleLogCmd_Run(t *testing.T) {
+	_, newPath := createAmendedCapsules(t, t.TempDir())
+
+	for _, jsonOut := range []bool{false, true} {
+		cmd := &CapsuleLogCmd{Capsule: newPath, JSON: jsonOut}
+		if err := cmd.Run(); err != nil {
+			t.Errorf("CapsuleLogCmd.Run(json=%v) error = %v", jsonOut, err)
+		}
+	}
+}
+
+func TestCapsuleDiffCmd_Run(t *testing.T) {
+	oldPath, newPath := createAmendedCapsules(t, t.TempDir())
+
+	for _, jsonOut := range []bool{false, true} {
+		cmd := &CapsuleDiffCmd{CapsuleA: oldPath, CapsuleB: newPath, JSON: jsonOut}
+		if err := cmd.Run(); err != nil {
+			t.Errorf("CapsuleDiffCmd.Run(json=%v) error = %v", jsonOut, err)
+		}
+	}
+}
+
+// createProvenanceCapsule packs a capsule holding a derived output with a
+// provenance statement and returns the capsule path and the output path.
+func createProvenanceCapsule(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	cap, _ := createTestCapsule(t, dir)
+	artifact, err := cap.IngestFile(createTestFile(t, dir, "source.txt", "source bytes"))
+	if err != nil {
+		t.Fatalf("failed to ingest file: %v", err)
+	}
+
+	output := []byte("derived bytes")
+	outputPath := createTestFile(t, dir, "derived.txt", string(output))
+	if _, err := cap.StoreBlob(output, ""); err != nil {
+		t.Fatalf("failed to store output: %v", err)
+	}
+
+	createTestFile(t, dir, "plugin.sh", "#!/bin/sh\n")
+	stmt, err := capsule.NewDerivedProvenance(capsule.DerivationInputs{
+		SubjectName: "derived.txt",
+		Output:      output,
+		Source:      artifact,
+		TargetPlugin: &plugins.Plugin{
+			Manifest: &plugins.PluginManifest{PluginID: "format-test", Version: "1.0.0", Entrypoint: "plugin.sh"},
+			Path:     dir,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to build provenance: %v", err)
+	}
+	if _, err := cap.AddProvenance(stmt, ""); err != nil {
+		t.Fatalf("failed to add provenance: %v", err)
+	}
+
+	capsulePath := filepath.Join(dir, "prov.capsule.tar.xz")
+	if err := cap.Pack(capsulePath); err != nil {
+		t.Fatalf("failed to pack capsule: %v", err)
+	}
+	return capsulePath, outputPath
+}
+
+func TestVerifyCmd_Run_Provenance(t *testing.T) {
+	dir := t.TempDir()
+	capsulePath, outputPath := createProvenanceCapsule(t, dir)
+
+	if err := (&VerifyCmd{Capsule: capsulePath, Provenance: true}).Run(); err != nil {
+		t.Errorf("verify --provenance failed: %v", err)
+	}
+	if err := (&VerifyCmd{Capsule: capsulePath, Subject: outputPath}).Run(); err != nil {
+		t.Errorf("verify --subject failed: %v", err)
+	}
+
+	unrelated := createTestFile(t, dir, "unrelated.txt", "not derived")
+	if err := (&VerifyCmd{Capsule: capsulePath, Subject: unrelated}).Run(); err == nil {
+		t.Error("expected failure for unattested subject")
+	}
+}
+
+func TestCapsuleEncryptDecryptCmds(t *testing.T) {
+	dir := t.TempDir()
+	capsulePath := createPackedCapsule(t, dir, "restricted text")
+
+	identityPath := filepath.Join(dir, "key.txt")
+	if err := (&CapsuleKeygenCmd{Output: identityPath}).Run(); err != nil {
+		t.Fatalf("keygen failed: %v", err)
+	}
+	if err := (&CapsuleKeygenCmd{Output: identityPath}).Run(); err == nil {
+		t.Error("expected keygen to refuse to overwrite an identity")
+	}
+
+	keyData, err := os.ReadFile(identityPath)
+	if err != nil {
+		t.Fatalf("failed to read identity: %v", err)
+	}
+	var recipient string
+	for _, line := range strings.Split(string(keyData), "\n") {
+		if strings.HasPrefix(line, "# public key: ") {
+			recipient = strings.TrimPrefix(line, "# public key: ")
+		}
+	}
+	recipientsFile := createTestFile(t, dir, "recipients.txt", "# team\n"+recipient+"\n")
+
+	if err := (&CapsuleEncryptCmd{Capsule: capsulePath}).Run(); err == nil {
+		t.Error("expected encrypt to require a recipient")
+	}
+	if err := (&CapsuleEncryptCmd{Capsule: capsulePath, RecipientsFile: recipientsFile}).Run(); err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	if err := (&CapsuleRecipientsCmd{Capsule: capsulePath}).Run(); err != nil {
+		t.Errorf("recipients failed: %v", err)
+	}
+
+	// Ciphertext integrity is verifiable without keys
+	if err := (&VerifyCmd{Capsule: capsulePath}).Run(); err != nil {
+		t.Errorf("verify without identity failed: %v", err)
+	}
+	if err := (&CapsuleDecryptCmd{Capsule: capsulePath}).Run(); err == nil {
+		t.Error("expected decrypt to fail without identity")
+	}
+
+	CLI.Identity = identityPath
+	defer func() {
+		CLI.Identity = ""
+		capsule.SetDefaultIdentities(nil)
+	}()
+	if err := loadIdentity(); err != nil {
+		t.Fatalf("loadIdentity failed: %v", err)
+	}
+	if err := (&VerifyCmd{Capsule: capsulePath}).Run(); err != nil {
+		t.Errorf("verify with identity failed: %v", err)
+	}
+	if err := (&CapsuleDecryptCmd{Capsule: capsulePath}).Run(); err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+
+	r, err := capsule.OpenArchive(capsulePath)
+	if err != nil {
+		t.Fatalf("failed to open capsule: %v", err)
+	}
+	defer r.Close()
+	if r.Manifest().Encryption != nil {
+		t.Error("expected decrypted capsule")
+	}
+	if len(r.Manifest().Revisions) != 2 {
+		t.Errorf("expected encrypt and decrypt revisions, got %d", len(r.Manifest().Revisions))
+	}
+}
+
+func TestCapsulePreserveAndImportCmds(t *testing.T) {
+	dir := t.TempDir()
+	capsulePath := createPackedCapsule(t, dir, "preserved text")
+	orig, err := capsule.OpenArchive(capsulePath)
+	if err != nil {
+		t.Fatalf("failed to open capsule: %v", err)
+	}
+	want, _ := orig.Manifest().ToJSON()
+	orig.Close()
+
+	for _, layout := range []string{"ocfl", "bagit"} {
+		t.Run(layout, func(t *testing.T) {
+			out := filepath.Join(dir, layout)
+			if err := (&CapsulePreserveCmd{Capsule: capsulePath, Layout: layout, Out: out}).Run(); err != nil {
+				t.Fatalf("preserve failed: %v", err)
+			}
+
+			imported := filepath.Join(dir, layout+".capsule.tar.xz")
+			if err := (&CapsuleImportCmd{Path: out, Out: imported, Container: "xz"}).Run(); err != nil {
+				t.Fatalf("import failed: %v", err)
+			}
+			r, err := capsule.OpenArchive(imported)
+			if err != nil {
+				t.Fatalf("failed to open imported capsule: %v", err)
+			}
+			defer r.Close()
+			got, _ := r.Manifest().ToJSON()
+			if string(got) != string(want) {
+				t.Error("imported manifest differs from original")
+			}
+		})
+	}
+
+	if err := (&CapsuleImportCmd{Path: dir, Out: filepath.Join(dir, "x.capsule.tar.xz"), Container: "xz"}).Run(); err == nil {
+		t.Error("expected error for a directory that is neither OCFL nor BagIt")
+	}
+}
+
+func TestCapsuleFsckCmd(t *testing.T) {
+	dir := t.TempDir()
+	mirror := createPackedCapsule(t, dir, "archived text")
+
+	// Damage a copy of the capsule
+	cap, err := capsule.Unpack(mirror, filepath.Join(dir, "damaged"))
+	if err != nil {
+		t.Fatalf("failed to unpack capsule: %v", err)
+	}
+	for hash, record := range cap.Manifest.Blobs.BySHA256 {
+		if err := os.WriteFile(filepath.Join(cap.GetRoot(), record.Path), []byte("rot"), 0644); err != nil {
+			t.Fatalf("failed to corrupt blob %s: %v", hash, err)
+		}
+	}
+	damaged := filepath.Join(dir, "damaged.capsule.tar.xz")
+	if err := cap.Pack(damaged); err != nil {
+		t.Fatalf("failed to pack capsule: %v", err)
+	}
+
+	report := filepath.Join(dir, "fsck.json")
+	if err := (&CapsuleFsckCmd{Capsule: damaged, Report: report}).Run(); err == nil {
+		t.Fatal("expected fsck to fail on a damaged capsule")
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	var parsed capsule.FsckReport
+	if err := json.Unmarshal(data, &parsed); err != nil || len(parsed.Issues) == 0 {
+		t.Fatalf("unexpected report %s: %v", data, err)
+	}
+
+	if err := (&CapsuleFsckCmd{Capsule: damaged, Repair: true}).Run(); err == nil {
+		t.Error("expected error for --repair without --out")
+	}
+
+	repaired := filepath.Join(dir, "repaired.capsule.tar.xz")
+	if err := (&CapsuleFsckCmd{Capsule: damaged, Repair: true, Source: []string{mirror}, Out: repaired}).Run(); err != nil {
+		t.Fatalf("repair failed: %v", err)
+	}
+	if err := verifyRepackedCapsule(repaired); err != nil {
+		t.Errorf("repaired capsule does not verify: %v", err)
+	}
+}
+
+func TestCapsuleAuditCmd(t *testing.T) {
+	library := t.TempDir()
+	packed := createPackedCapsule(t, library, "audited text")
+
+	if err := (&CapsuleAuditCmd{Library: library, JSON: true}).Run(); err != nil {
+		t.Fatalf("audit failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(library, audit.DefaultLogName)); err != nil {
+		t.Errorf("expected default audit log in library: %v", err)
+	}
+	if err := (&CapsuleAuditCmd{Library: library, Report: true, Interval: time.Hour}).Run(); err != nil {
+		t.Errorf("report failed: %v", err)
+	}
+
+	// Replace the capsule with a damaged copy
+	cap, err := capsule.Unpack(packed, filepath.Join(t.TempDir(), "damaged"))
+	if err != nil {
+		t.Fatalf("failed to unpack capsule: %v", err)
+	}
+	for _, record := range cap.Manifest.Blobs.BySHA256 {
+		if err := os.WriteFile(filepath.Join(cap.GetRoot(), record.Path), []byte("rot"), 0644); err != nil {
+			t.Fatalf("failed to corrupt blob: %v", err)
+		}
+	}
+	if err := cap.Pack(packed); err != nil {
+		t.Fatalf("failed to pack capsule: %v", err)
+	}
+	if err := (&CapsuleAuditCmd{Library: library}).Run(); err == nil {
+		t.Error("expected audit to fail on a damaged capsule")
+	}
+}
+
+func TestCompareCmd_Run_SemanticDiff(t *testing.T) {
+	tempDir := t.TempDir()
+	packedPath := createRunsCapsule(t, tempDir, map[string]string{
+		"run-1": `{"event":"start","timestamp":"1"}` + "\n" + `{"event":"render","ref":"Gen.1.1","text":"a"}` + "\n",
+		"run-2": `{"event":"start","timestamp":"2"}` + "\n" + `{"event":"render","ref":"Gen.1.0","text":"z"}` + "\n" + `{"event":"render","ref":"Gen.1.1","text":"a"}` + "\n",
+		"run-3": `{"event":"start","timestamp":"3"}` + "\n" + `{"event":"render","ref":"Gen.1.1","text":"a"}` + "\n",
+	})
+
+	// Only the volatile timestamp differs
+	if err := (&CompareCmd{Capsule: packedPath, Run1: "run-1", Run2: "run-3", Limit: 50}).Run(); err != nil {
+		t.Errorf("expected equivalent transcripts, got %v", err)
+	}
+
+	var runErr error
+	out := captureStdout(t, func() {
+		runErr = (&CompareCmd{Capsule: packedPath, Run1: "run-1", Run2: "run-2", JSON: true}).Run()
+	})
+	if runErr == nil {
+		t.Error("expected transcripts to differ")
+	}
+	var result compareResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, out)
+	}
+	if result.Diff.Summary.Added != 1 || result.Diff.Summary.Unchanged != 2 || len(result.Diff.Changes) != 1 {
+		t.Errorf("unexpected diff %+v", result.Diff)
+	}
+	if c := result.Diff.Changes[0]; c.Kind != "added" || c.Key != "Gen.1.0" {
+		t.Errorf("unexpected change %+v", c)
+	}
+}
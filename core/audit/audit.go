@@ -0,0 +1,563 @@
+// Package audit provides scheduled fixity auditing for a library of
+// capsules. Each audit re-hashes capsule blobs (SHA-256 and BLAKE3) and
+// appends the results to an SQLite audit log, so preservation policy can
+// show when every capsule was last checked and with what outcome.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zeebo/blake3"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+	"github.com/FocuswithJustin/JuniperBible/core/sqlite"
+)
+
+// Result status values.
+const (
+	// StatusOK means every blob (or the archive file) matched.
+	StatusOK = "ok"
+
+	// StatusFailed means at least one fixity check failed.
+	StatusFailed = "failed"
+)
+
+// Check modes.
+const (
+	// ModeBlobs re-hashes every blob listed in the capsule manifest.
+	ModeBlobs = "blobs"
+
+	// ModeFile hashes the whole archive and compares it with the previous
+	// audit. It is used for archives without a capsule manifest.
+	ModeFile = "file"
+)
+
+// DefaultLogName is the audit log file name used inside a library
+// directory when no other location is given. ListCapsules skips it.
+const DefaultLogName = ".fixity-audit.db"
+
+// timeLayout is used for timestamps in the log; it sorts lexically.
+const timeLayout = time.RFC3339
+
+const schema = `
+CREATE TABLE IF NOT EXISTS audit_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	library TEXT NOT NULL,
+	started_at TEXT NOT NULL,
+	finished_at TEXT,
+	sample REAL NOT NULL,
+	planned INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS audit_plan (
+	run_id INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	capsule TEXT NOT NULL,
+	PRIMARY KEY (run_id, position)
+);
+CREATE TABLE IF NOT EXISTS audit_results (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id INTEGER NOT NULL,
+	library TEXT NOT NULL,
+	capsule TEXT NOT NULL,
+	checked_at TEXT NOT NULL,
+	mode TEXT NOT NULL,
+	status TEXT NOT NULL,
+	blobs_checked INTEGER NOT NULL,
+	bytes_checked INTEGER NOT NULL,
+	file_sha256 TEXT NOT NULL,
+	file_blake3 TEXT NOT NULL,
+	failures TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS audit_results_capsule ON audit_results(library, capsule, checked_at);
+`
+
+// BlobFailure describes a blob that failed its fixity check.
+type BlobFailure struct {
+	SHA256 string `json:"sha256,omitempty"`
+	Path   string `json:"path"`
+	Detail string `json:"detail"`
+}
+
+// Result is the outcome of auditing one capsule.
+type Result struct {
+	Capsule      string        `json:"capsule"`
+	CheckedAt    time.Time     `json:"checked_at"`
+	Mode         string        `json:"mode"`
+	Status       string        `json:"status"`
+	BlobsChecked int           `json:"blobs_checked"`
+	BytesChecked int64         `json:"bytes_checked"`
+	FileSHA256   string        `json:"file_sha256"`
+	FileBLAKE3   string        `json:"file_blake3"`
+	Failures     []BlobFailure `json:"failures,omitempty"`
+}
+
+// Run describes one audit run.
+type Run struct {
+	ID         int64     `json:"id"`
+	Library    string    `json:"library"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Sample     float64   `json:"sample"`
+	Planned    int       `json:"planned"`
+	Checked    int       `json:"checked"`
+	Failed     int       `json:"failed"`
+	Resumed    bool      `json:"resumed,omitempty"`
+}
+
+// Finished returns true if every planned capsule was audited.
+func (r *Run) Finished() bool {
+	return !r.FinishedAt.IsZero()
+}
+
+// Options configures an audit run.
+type Options struct {
+	// Sample is the fraction of capsules to audit, in (0, 1]. Capsules
+	// never audited come first, then those audited longest ago, so
+	// repeated sampled runs cover the whole library. Zero means all.
+	Sample float64
+
+	// Resume continues the most recent unfinished run of the library
+	// instead of starting a new one.
+	Resume bool
+
+	// Progress, if set, is called after each capsule is audited.
+	Progress func(done, total int, result *Result)
+}
+
+// Log is an append-only fixity audit log stored in SQLite.
+type Log struct {
+	db *sql.DB
+}
+
+// Open opens (creating if necessary) the audit log at path.
+func Open(path string) (*Log, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, errors.NewIO("open", path, err)
+	}
+	// A single connection serialises writers from the CLI and web jobs
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create audit schema: %w", err)
+	}
+	return &Log{db: db}, nil
+}
+
+// Close closes the audit log.
+func (l *Log) Close() error {
+	return l.db.Close()
+}
+
+// Audit audits the capsules of the library directory and appends the
+// results to the log. Cancelling ctx stops the run between capsules; the
+// run can then be continued with Options.Resume.
+func (l *Log) Audit(ctx context.Context, library string, opts Options) (*Run, []*Result, error) {
+	library, err := filepath.Abs(library)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.Sample < 0 || opts.Sample > 1 {
+		return nil, nil, errors.NewValidation("sample", "must be between 0 and 1")
+	}
+	if opts.Sample == 0 {
+		opts.Sample = 1
+	}
+
+	var run *Run
+	if opts.Resume {
+		if run, err = l.unfinishedRun(library); err != nil {
+			return nil, nil, err
+		}
+	}
+	if run == nil {
+		if run, err = l.startRun(library, opts.Sample); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		run.Resumed = true
+	}
+
+	plan, err := l.pending(run)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var results []*Result
+	for i, name := range plan {
+		if err := ctx.Err(); err != nil {
+			return run, results, err
+		}
+
+		previous, err := l.lastResult(library, name)
+		if err != nil {
+			return run, results, err
+		}
+		result := CheckCapsule(filepath.Join(library, filepath.FromSlash(name)), previous)
+		result.Capsule = name
+		if err := l.record(run, result); err != nil {
+			return run, results, err
+		}
+		results = append(results, result)
+
+		run.Checked++
+		if result.Status != StatusOK {
+			run.Failed++
+		}
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(plan), result)
+		}
+	}
+
+	run.FinishedAt = time.Now().UTC()
+	if _, err := l.db.Exec(`UPDATE audit_runs SET finished_at = ? WHERE id = ?`, run.FinishedAt.Format(timeLayout), run.ID); err != nil {
+		return run, results, fmt.Errorf("failed to finish audit run: %w", err)
+	}
+	return run, results, nil
+}
+
+// startRun plans a new run: capsules never audited first, then the least
+// recently audited, limited to the sample fraction.
+func (l *Log) startRun(library string, sample float64) (*Run, error) {
+	capsules, err := ListCapsules(library)
+	if err != nil {
+		return nil, err
+	}
+	last, err := l.lastAudited(library)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(capsules, func(i, j int) bool {
+		return last[capsules[i]] < last[capsules[j]]
+	})
+	n := int(math.Ceil(sample * float64(len(capsules))))
+	capsules = capsules[:n]
+
+	run := &Run{Library: library, StartedAt: time.Now().UTC(), Sample: sample, Planned: n}
+	tx, err := l.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO audit_runs (library, started_at, sample, planned) VALUES (?, ?, ?, ?)`,
+		library, run.StartedAt.Format(timeLayout), sample, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start audit run: %w", err)
+	}
+	if run.ID, err = res.LastInsertId(); err != nil {
+		return nil, err
+	}
+	for i, name := range capsules {
+		if _, err := tx.Exec(`INSERT INTO audit_plan (run_id, position, capsule) VALUES (?, ?, ?)`, run.ID, i, name); err != nil {
+			return nil, fmt.Errorf("failed to plan audit run: %w", err)
+		}
+	}
+	return run, tx.Commit()
+}
+
+// unfinishedRun returns the most recent unfinished run of the library,
+// or nil if there is none.
+func (l *Log) unfinishedRun(library string) (*Run, error) {
+	runs, err := l.runs(`WHERE r.library = ? AND r.finished_at IS NULL ORDER BY r.id DESC LIMIT 1`, library)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return runs[0], nil
+}
+
+// pending returns the planned capsules of a run that have no result yet.
+func (l *Log) pending(run *Run) ([]string, error) {
+	rows, err := l.db.Query(`SELECT capsule FROM audit_plan p
+		WHERE p.run_id = ? AND NOT EXISTS (
+			SELECT 1 FROM audit_results r WHERE r.run_id = p.run_id AND r.capsule = p.capsule)
+		ORDER BY p.position`, run.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit plan: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// record appends a result to the log.
+func (l *Log) record(run *Run, r *Result) error {
+	failures, err := json.Marshal(r.Failures)
+	if err != nil {
+		return err
+	}
+	_, err = l.db.Exec(`INSERT INTO audit_results
+		(run_id, library, capsule, checked_at, mode, status, blobs_checked, bytes_checked, file_sha256, file_blake3, failures)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, run.Library, r.Capsule, r.CheckedAt.Format(timeLayout), r.Mode, r.Status,
+		r.BlobsChecked, r.BytesChecked, r.FileSHA256, r.FileBLAKE3, string(failures))
+	if err != nil {
+		return fmt.Errorf("failed to record audit result: %w", err)
+	}
+	return nil
+}
+
+// lastAudited returns the time of the latest result of each capsule.
+func (l *Log) lastAudited(library string) (map[string]string, error) {
+	rows, err := l.db.Query(`SELECT capsule, MAX(checked_at) FROM audit_results WHERE library = ? GROUP BY capsule`, library)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	last := make(map[string]string)
+	for rows.Next() {
+		var name, at string
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, err
+		}
+		last[name] = at
+	}
+	return last, rows.Err()
+}
+
+// lastResult returns the latest result of a capsule, or nil.
+func (l *Log) lastResult(library, name string) (*Result, error) {
+	results, err := l.History(library, name, 1)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// History returns up to limit results for a capsule, newest first.
+func (l *Log) History(library, name string, limit int) ([]*Result, error) {
+	library, err := filepath.Abs(library)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := l.db.Query(`SELECT capsule, checked_at, mode, status, blobs_checked, bytes_checked, file_sha256, file_blake3, failures
+		FROM audit_results WHERE library = ? AND capsule = ? ORDER BY id DESC LIMIT ?`, library, name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+	return scanResults(rows)
+}
+
+// scanResults reads result rows.
+func scanResults(rows *sql.Rows) ([]*Result, error) {
+	var results []*Result
+	for rows.Next() {
+		var r Result
+		var checkedAt, failures string
+		if err := rows.Scan(&r.Capsule, &checkedAt, &r.Mode, &r.Status, &r.BlobsChecked, &r.BytesChecked, &r.FileSHA256, &r.FileBLAKE3, &failures); err != nil {
+			return nil, err
+		}
+		r.CheckedAt, _ = time.Parse(timeLayout, checkedAt)
+		if err := json.Unmarshal([]byte(failures), &r.Failures); err != nil {
+			return nil, errors.NewParse("audit failures", r.Capsule, err.Error())
+		}
+		results = append(results, &r)
+	}
+	return results, rows.Err()
+}
+
+// Runs returns up to limit runs of the library, newest first.
+func (l *Log) Runs(library string, limit int) ([]*Run, error) {
+	library, err := filepath.Abs(library)
+	if err != nil {
+		return nil, err
+	}
+	return l.runs(`WHERE r.library = ? ORDER BY r.id DESC LIMIT ?`, library, limit)
+}
+
+// runs queries runs with their result counts.
+func (l *Log) runs(where string, args ...any) ([]*Run, error) {
+	rows, err := l.db.Query(`SELECT r.id, r.library, r.started_at, COALESCE(r.finished_at, ''), r.sample, r.planned,
+		(SELECT COUNT(*) FROM audit_results x WHERE x.run_id = r.id),
+		(SELECT COUNT(*) FROM audit_results x WHERE x.run_id = r.id AND x.status != 'ok')
+		FROM audit_runs r `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*Run
+	for rows.Next() {
+		var r Run
+		var started, finished string
+		if err := rows.Scan(&r.ID, &r.Library, &started, &finished, &r.Sample, &r.Planned, &r.Checked, &r.Failed); err != nil {
+			return nil, err
+		}
+		r.StartedAt, _ = time.Parse(timeLayout, started)
+		if finished != "" {
+			r.FinishedAt, _ = time.Parse(timeLayout, finished)
+		}
+		runs = append(runs, &r)
+	}
+	return runs, rows.Err()
+}
+
+// ListCapsules returns the capsule archives under library as sorted
+// slash-separated relative paths. Hidden files and directories are skipped.
+func ListCapsules(library string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(library, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != library {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(info.Name()) {
+		case ".xz", ".gz", ".tar", ".zip":
+		default:
+			return nil
+		}
+		rel, err := filepath.Rel(library, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.NewIO("scan", library, err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// CheckCapsule re-hashes a capsule archive. Capsules with a manifest have
+// every blob checked against its SHA-256 and BLAKE3 (encrypted blobs
+// against their ciphertext hash, so no keys are needed). Other archives are
+// hashed whole and compared with previous, the capsule's last result.
+func CheckCapsule(path string, previous *Result) *Result {
+	result := &Result{Capsule: path, CheckedAt: time.Now().UTC(), Mode: ModeBlobs, Status: StatusOK}
+
+	fileSHA, fileB3, size, err := hashFile(path)
+	if err != nil {
+		result.Status = StatusFailed
+		result.Failures = []BlobFailure{{Path: filepath.Base(path), Detail: err.Error()}}
+		return result
+	}
+	result.FileSHA256, result.FileBLAKE3 = fileSHA, fileB3
+
+	r, err := capsule.OpenArchive(path)
+	if err != nil {
+		result.Mode = ModeFile
+		result.BytesChecked = size
+		switch {
+		case previous == nil:
+		case previous.Mode == ModeBlobs:
+			result.Failures = []BlobFailure{{Path: "manifest.json", Detail: err.Error()}}
+		case previous.FileSHA256 != fileSHA || previous.FileBLAKE3 != fileB3:
+			result.Failures = []BlobFailure{{Path: filepath.Base(path), Detail: "archive changed since last audit"}}
+		}
+		if len(result.Failures) > 0 {
+			result.Status = StatusFailed
+		}
+		return result
+	}
+	defer r.Close()
+
+	// Index blob records by archive path
+	records := make(map[string]*capsule.BlobRecord)
+	for _, record := range r.Manifest().Blobs.BySHA256 {
+		records[record.Path] = record
+	}
+
+	seen := make(map[string]bool, len(records))
+	walkErr := r.WalkFiles(func(name string, data []byte) error {
+		record, ok := records[name]
+		if !ok {
+			return nil
+		}
+		seen[name] = true
+		result.BlobsChecked++
+		result.BytesChecked += int64(len(data))
+		if detail := checkBlob(record, data); detail != "" {
+			result.Failures = append(result.Failures, BlobFailure{SHA256: record.SHA256, Path: name, Detail: detail})
+		}
+		return nil
+	})
+	if walkErr != nil {
+		result.Failures = append(result.Failures, BlobFailure{Path: filepath.Base(path), Detail: walkErr.Error()})
+	}
+
+	var missing []string
+	for name := range records {
+		if !seen[name] {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		result.Failures = append(result.Failures, BlobFailure{SHA256: records[name].SHA256, Path: name, Detail: "blob missing from archive"})
+	}
+
+	if len(result.Failures) > 0 {
+		result.Status = StatusFailed
+	}
+	return result
+}
+
+// checkBlob re-hashes a blob and returns a failure detail, or "".
+func checkBlob(record *capsule.BlobRecord, data []byte) string {
+	if enc := record.Encryption; enc != nil {
+		if cas.Hash(data) != enc.CiphertextSHA256 {
+			return "ciphertext sha256 mismatch"
+		}
+		return ""
+	}
+	if actual := cas.Hash(data); actual != record.SHA256 {
+		return fmt.Sprintf("sha256 mismatch: got %s", actual)
+	}
+	if record.BLAKE3 != "" {
+		if actual := cas.Blake3Hash(data); actual != record.BLAKE3 {
+			return fmt.Sprintf("blake3 mismatch: got %s", actual)
+		}
+	}
+	return ""
+}
+
+// hashFile returns the SHA-256 and BLAKE3 of a file and its size.
+func hashFile(path string) (string, string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer f.Close()
+
+	sh := sha256.New()
+	bh := blake3.New()
+	n, err := io.Copy(io.MultiWriter(sh, bh), f)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return hex.EncodeToString(sh.Sum(nil)), hex.EncodeToString(bh.Sum(nil)), n, nil
+}
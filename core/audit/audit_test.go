@@ -0,0 +1,225 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+)
+
+// writeCapsule packs a capsule containing content into the library. If
+// corrupt is set, the blob is damaged before packing so the archive no
+// longer matches its manifest.
+func writeCapsule(t *testing.T, library, name, content string, corrupt bool) string {
+	t.Helper()
+	work := t.TempDir()
+	src := filepath.Join(work, "content.txt")
+	if err := os.WriteFile(src, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write content: %v", err)
+	}
+	c, err := capsule.New(filepath.Join(work, "capsule"))
+	if err != nil {
+		t.Fatalf("failed to create capsule: %v", err)
+	}
+	a, err := c.IngestFile(src)
+	if err != nil {
+		t.Fatalf("failed to ingest: %v", err)
+	}
+	if corrupt {
+		record := c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256]
+		if err := os.WriteFile(filepath.Join(c.GetRoot(), record.Path), []byte("bit rot"), 0644); err != nil {
+			t.Fatalf("failed to corrupt blob: %v", err)
+		}
+	}
+
+	path := filepath.Join(library, name)
+	if err := c.PackWithOptions(path, &capsule.PackOptions{Compression: capsule.CompressionZip}); err != nil {
+		t.Fatalf("failed to pack capsule: %v", err)
+	}
+	return path
+}
+
+// openTestLog opens an audit log in a temp directory.
+func openTestLog(t *testing.T) *Log {
+	t.Helper()
+	l, err := Open(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// TestAuditDetectsCorruption tests blob re-hashing and the recorded log.
+func TestAuditDetectsCorruption(t *testing.T) {
+	library := t.TempDir()
+	writeCapsule(t, library, "good.capsule.zip", "intact", false)
+	writeCapsule(t, library, "bad.capsule.zip", "damaged", true)
+	l := openTestLog(t)
+
+	run, results, err := l.Audit(context.Background(), library, Options{})
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if !run.Finished() || run.Checked != 2 || run.Failed != 1 {
+		t.Errorf("unexpected run %+v", run)
+	}
+
+	byName := make(map[string]*Result)
+	for _, r := range results {
+		byName[r.Capsule] = r
+	}
+	if r := byName["good.capsule.zip"]; r == nil || r.Status != StatusOK || r.BlobsChecked != 1 || r.Mode != ModeBlobs {
+		t.Errorf("unexpected good result %+v", r)
+	}
+	if r := byName["bad.capsule.zip"]; r == nil || r.Status != StatusFailed || len(r.Failures) != 1 {
+		t.Errorf("unexpected bad result %+v", r)
+	}
+
+	history, err := l.History(library, "bad.capsule.zip", 10)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Failures[0].Detail == "" {
+		t.Errorf("unexpected history %+v", history)
+	}
+}
+
+// TestAuditSamplingCoversLibrary tests that sampled runs audit the least
+// recently audited capsules first.
+func TestAuditSamplingCoversLibrary(t *testing.T) {
+	library := t.TempDir()
+	for _, name := range []string{"a.capsule.zip", "b.capsule.zip", "c.capsule.zip", "d.capsule.zip"} {
+		writeCapsule(t, library, name, name, false)
+	}
+	l := openTestLog(t)
+
+	seen := make(map[string]int)
+	for i := 0; i < 2; i++ {
+		run, results, err := l.Audit(context.Background(), library, Options{Sample: 0.5})
+		if err != nil {
+			t.Fatalf("Audit failed: %v", err)
+		}
+		if run.Planned != 2 {
+			t.Errorf("Planned = %d, want 2", run.Planned)
+		}
+		for _, r := range results {
+			seen[r.Capsule]++
+		}
+	}
+	if len(seen) != 4 {
+		t.Errorf("expected two half-samples to cover all capsules, got %v", seen)
+	}
+
+	if _, _, err := l.Audit(context.Background(), library, Options{Sample: 2}); err == nil {
+		t.Error("expected error for sample > 1")
+	}
+}
+
+// TestAuditResume tests continuing an interrupted run.
+func TestAuditResume(t *testing.T) {
+	library := t.TempDir()
+	for _, name := range []string{"a.capsule.zip", "b.capsule.zip", "c.capsule.zip"} {
+		writeCapsule(t, library, name, name, false)
+	}
+	l := openTestLog(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run, results, err := l.Audit(ctx, library, Options{Progress: func(done, total int, r *Result) {
+		cancel()
+	}})
+	if err == nil || run.Finished() || len(results) != 1 {
+		t.Fatalf("expected interrupted run, got %+v, %d results, %v", run, len(results), err)
+	}
+
+	resumed, results, err := l.Audit(context.Background(), library, Options{Resume: true})
+	if err != nil {
+		t.Fatalf("resumed Audit failed: %v", err)
+	}
+	if resumed.ID != run.ID || !resumed.Resumed || !resumed.Finished() {
+		t.Errorf("expected run %d to be resumed and finished, got %+v", run.ID, resumed)
+	}
+	if len(results) != 2 || resumed.Checked != 3 {
+		t.Errorf("expected 2 remaining capsules (3 total), got %d (%d)", len(results), resumed.Checked)
+	}
+
+	// Nothing left to resume: a new run is started
+	again, _, err := l.Audit(context.Background(), library, Options{Resume: true})
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if again.ID == run.ID || again.Resumed {
+		t.Errorf("expected a new run, got %+v", again)
+	}
+}
+
+// TestAuditFileMode tests whole-archive fixity for archives without a
+// capsule manifest.
+func TestAuditFileMode(t *testing.T) {
+	library := t.TempDir()
+	legacy := filepath.Join(library, "legacy.tar.gz")
+	if err := os.WriteFile(legacy, []byte("not a capsule"), 0644); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	l := openTestLog(t)
+
+	_, results, err := l.Audit(context.Background(), library, Options{})
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if results[0].Mode != ModeFile || results[0].Status != StatusOK || results[0].FileBLAKE3 == "" {
+		t.Errorf("unexpected baseline result %+v", results[0])
+	}
+
+	if err := os.WriteFile(legacy, []byte("changed bytes"), 0644); err != nil {
+		t.Fatalf("failed to modify archive: %v", err)
+	}
+	_, results, err = l.Audit(context.Background(), library, Options{})
+	if err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	if results[0].Status != StatusFailed {
+		t.Errorf("expected changed archive to fail, got %+v", results[0])
+	}
+}
+
+// TestReport tests never-audited, failed and overdue capsules.
+func TestReport(t *testing.T) {
+	library := t.TempDir()
+	writeCapsule(t, library, "good.capsule.zip", "intact", false)
+	writeCapsule(t, library, "bad.capsule.zip", "damaged", true)
+	l := openTestLog(t)
+
+	if _, _, err := l.Audit(context.Background(), library, Options{}); err != nil {
+		t.Fatalf("Audit failed: %v", err)
+	}
+	writeCapsule(t, library, "new.capsule.zip", "added later", false)
+
+	report, err := l.Report(library, time.Hour)
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if report.Total != 3 || report.Healthy != 1 || report.LastRun == nil {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if len(report.NeverAudited) != 1 || report.NeverAudited[0].Capsule != "new.capsule.zip" {
+		t.Errorf("unexpected never audited %+v", report.NeverAudited)
+	}
+	if len(report.RecentlyFailed) != 1 || report.RecentlyFailed[0].Capsule != "bad.capsule.zip" {
+		t.Errorf("unexpected recently failed %+v", report.RecentlyFailed)
+	}
+	if len(report.Overdue) != 0 {
+		t.Errorf("expected nothing overdue, got %+v", report.Overdue)
+	}
+
+	report, err = l.Report(library, time.Nanosecond)
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if len(report.Overdue) != 2 {
+		t.Errorf("expected 2 overdue capsules, got %+v", report.Overdue)
+	}
+}
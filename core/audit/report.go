@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultInterval is the default maximum age of a capsule's last audit
+// before it is considered overdue.
+const DefaultInterval = 90 * 24 * time.Hour
+
+// CapsuleStatus summarises the audit history of one capsule.
+type CapsuleStatus struct {
+	Capsule     string    `json:"capsule"`
+	LastAudited time.Time `json:"last_audited,omitempty"`
+	LastStatus  string    `json:"last_status,omitempty"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+	Detail      string    `json:"detail,omitempty"`
+}
+
+// Report lists the capsules of a library that need attention.
+type Report struct {
+	Library     string        `json:"library"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Interval    time.Duration `json:"interval"`
+	Total       int           `json:"total"`
+	Healthy     int           `json:"healthy"`
+
+	// NeverAudited are capsules with no audit result.
+	NeverAudited []CapsuleStatus `json:"never_audited"`
+
+	// RecentlyFailed are capsules whose last audit failed or that failed
+	// an audit within the interval.
+	RecentlyFailed []CapsuleStatus `json:"recently_failed"`
+
+	// Overdue are capsules last audited longer ago than the interval.
+	Overdue []CapsuleStatus `json:"overdue"`
+
+	// LastRun is the most recent audit run, if any.
+	LastRun *Run `json:"last_run,omitempty"`
+}
+
+// Report builds a report for the capsules currently in the library.
+// A capsule can appear in both RecentlyFailed and Overdue.
+func (l *Log) Report(library string, interval time.Duration) (*Report, error) {
+	library, err := filepath.Abs(library)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	now := time.Now().UTC()
+	report := &Report{
+		Library:        library,
+		GeneratedAt:    now,
+		Interval:       interval,
+		NeverAudited:   []CapsuleStatus{},
+		RecentlyFailed: []CapsuleStatus{},
+		Overdue:        []CapsuleStatus{},
+	}
+
+	capsules, err := ListCapsules(library)
+	if err != nil {
+		return nil, err
+	}
+	report.Total = len(capsules)
+
+	statuses, err := l.statuses(library)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := now.Add(-interval)
+	for _, name := range capsules {
+		status, ok := statuses[name]
+		if !ok {
+			report.NeverAudited = append(report.NeverAudited, CapsuleStatus{Capsule: name})
+			continue
+		}
+		healthy := true
+		if status.LastStatus != StatusOK || status.LastFailure.After(cutoff) {
+			report.RecentlyFailed = append(report.RecentlyFailed, *status)
+			healthy = false
+		}
+		if status.LastAudited.Before(cutoff) {
+			report.Overdue = append(report.Overdue, *status)
+			healthy = false
+		}
+		if healthy {
+			report.Healthy++
+		}
+	}
+	sort.Slice(report.Overdue, func(i, j int) bool {
+		return report.Overdue[i].LastAudited.Before(report.Overdue[j].LastAudited)
+	})
+	sort.Slice(report.RecentlyFailed, func(i, j int) bool {
+		return report.RecentlyFailed[i].LastFailure.After(report.RecentlyFailed[j].LastFailure)
+	})
+
+	runs, err := l.runs(`WHERE r.library = ? ORDER BY r.id DESC LIMIT 1`, library)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) > 0 {
+		report.LastRun = runs[0]
+	}
+	return report, nil
+}
+
+// statuses returns the latest result and latest failure of every audited
+// capsule in the library.
+func (l *Log) statuses(library string) (map[string]*CapsuleStatus, error) {
+	rows, err := l.db.Query(`SELECT capsule, checked_at, status, failures FROM audit_results
+		WHERE library = ? ORDER BY id`, library)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := make(map[string]*CapsuleStatus)
+	for rows.Next() {
+		var name, checkedAt, status, failures string
+		if err := rows.Scan(&name, &checkedAt, &status, &failures); err != nil {
+			return nil, err
+		}
+		at, _ := time.Parse(timeLayout, checkedAt)
+
+		s, ok := statuses[name]
+		if !ok {
+			s = &CapsuleStatus{Capsule: name}
+			statuses[name] = s
+		}
+		s.LastAudited, s.LastStatus = at, status
+		if status != StatusOK {
+			s.LastFailure = at
+			s.Detail = failures
+		}
+	}
+	return statuses, rows.Err()
+}
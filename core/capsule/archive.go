@@ -0,0 +1,489 @@
+package capsule
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// Injectable functions for testing
+var (
+	zipOpenReader = zip.OpenReader
+)
+
+// packZip writes the capsule as a zip container.
+// manifest.json is written first, followed by every file under blobs/.
+// Each entry is individually deflated so a single blob can be read
+// without decompressing the rest of the archive.
+func (c *Capsule) packZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+
+	manifestData, err := manifestToJSONPack(c.Manifest)
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+	if err := writeToZip(zw, "manifest.json", manifestData); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	blobsDir := filepath.Join(c.root, "blobs")
+	if _, err := os.Stat(blobsDir); err == nil {
+		if err := filepathWalk(blobsDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			relPath, err := filepathRel(c.root, path)
+			if err != nil {
+				return err
+			}
+
+			data, err := osReadFileWalk(path)
+			if err != nil {
+				return err
+			}
+
+			return writeToZip(zw, filepath.ToSlash(relPath), data)
+		}); err != nil {
+			return fmt.Errorf("failed to write blobs: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finalize zip: %w", err)
+	}
+	return nil
+}
+
+// writeToZip writes a single deflated entry to the zip archive.
+func writeToZip(zw *zip.Writer, name string, data []byte) error {
+	header := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+	header.SetMode(0644)
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// unpackZip unpacks a zip capsule to the given directory.
+func unpackZip(archivePath, destDir string) (*Capsule, error) {
+	zr, err := zipOpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer zr.Close()
+
+	var manifest *Manifest
+	for _, f := range zr.File {
+		cleanPath := filepath.Clean(f.Name)
+		if strings.HasPrefix(cleanPath, "..") || filepath.IsAbs(cleanPath) {
+			continue // Skip potentially malicious paths
+		}
+		destPath := filepath.Join(destDir, cleanPath)
+
+		if f.FileInfo().IsDir() {
+			if err := osMkdirAllUnpack(destPath, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create directory: %w", err)
+			}
+			continue
+		}
+
+		if err := osMkdirAllUnpack(filepath.Dir(destPath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create parent directory: %w", err)
+		}
+
+		data, err := readZipFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file data: %w", err)
+		}
+
+		if err := osWriteFileUnpack(destPath, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write file: %w", err)
+		}
+
+		if f.Name == "manifest.json" {
+			manifest, err = ParseManifest(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse manifest: %w", err)
+			}
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("archive does not contain manifest.json")
+	}
+
+	store, err := casNewStoreUnpack(destDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %w", err)
+	}
+
+	return &Capsule{
+		root:       destDir,
+		Manifest:   manifest,
+		store:      store,
+		identities: getDefaultIdentities(),
+	}, nil
+}
+
+// readZipFile reads the full contents of a zip entry.
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioReadAllUnpack(rc)
+}
+
+// ArchiveReader reads individual files and blobs from a packed capsule
+// without unpacking it to disk.
+//
+// Zip capsules are read with random access: only the requested entry is
+// decompressed. Tar capsules (tar.xz, tar.gz) remain supported but each
+// read streams the archive until the requested entry is found.
+type ArchiveReader struct {
+	path        string
+	compression CompressionType
+	manifest    *Manifest
+
+	// zip state (nil for tar archives)
+	zipReader *zip.ReadCloser
+	zipIndex  map[string]*zip.File
+
+	// identities decrypt blobs of encrypted capsules
+	identities []age.Identity
+}
+
+// OpenArchive opens a packed capsule for reading and parses its manifest.
+func OpenArchive(archivePath string) (*ArchiveReader, error) {
+	r, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := r.ReadFile("manifest.json")
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	manifest, err := ParseManifest(data)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	r.manifest = manifest
+
+	return r, nil
+}
+
+// openArchive opens an archive without reading a manifest, for archives
+// that may not be CAS capsules.
+func openArchive(archivePath string) (*ArchiveReader, error) {
+	compression, err := DetectCompression(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect compression: %w", err)
+	}
+
+	r := &ArchiveReader{
+		path:        archivePath,
+		compression: compression,
+		identities:  getDefaultIdentities(),
+	}
+
+	if compression == CompressionZip {
+		zr, err := zipOpenReader(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open archive: %w", err)
+		}
+		r.zipReader = zr
+		r.zipIndex = make(map[string]*zip.File, len(zr.File))
+		for _, f := range zr.File {
+			r.zipIndex[f.Name] = f
+		}
+	}
+	return r, nil
+}
+
+// Manifest returns the capsule manifest.
+func (r *ArchiveReader) Manifest() *Manifest {
+	return r.manifest
+}
+
+// Compression returns the container format of the archive.
+func (r *ArchiveReader) Compression() CompressionType {
+	return r.compression
+}
+
+// RandomAccess reports whether individual entries can be read without
+// decompressing preceding entries.
+func (r *ArchiveReader) RandomAccess() bool {
+	return r.zipReader != nil
+}
+
+// ReadFile reads a single entry from the archive by its path.
+func (r *ArchiveReader) ReadFile(name string) ([]byte, error) {
+	if r.zipReader != nil {
+		f, ok := r.zipIndex[name]
+		if !ok {
+			return nil, errors.NewNotFound("archive entry", name)
+		}
+		return readZipFile(f)
+	}
+	return r.readTarFile(name)
+}
+
+// readTarFile streams a tar capsule until the named entry is found.
+func (r *ArchiveReader) readTarFile(name string) ([]byte, error) {
+	var data []byte
+	found := false
+	err := r.streamTar(func(entry string, tr io.Reader) (bool, error) {
+		if entry != name {
+			return false, nil
+		}
+		var err error
+		data, err = ioReadAllUnpack(tr)
+		found = true
+		return true, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.NewNotFound("archive entry", name)
+	}
+	return data, nil
+}
+
+// WalkFiles calls fn for every regular file in the archive, in archive
+// order. Tar capsules are read in a single pass, so this is the efficient
+// way to visit every blob.
+func (r *ArchiveReader) WalkFiles(fn func(name string, data []byte) error) error {
+	return r.walkFiles(nil, fn)
+}
+
+// walkFiles calls fn for the regular files whose name satisfies match
+// (all files if match is nil); other entries are skipped unread.
+func (r *ArchiveReader) walkFiles(match func(name string) bool, fn func(name string, data []byte) error) error {
+	if r.zipReader != nil {
+		for _, f := range r.zipReader.File {
+			if f.FileInfo().IsDir() || (match != nil && !match(f.Name)) {
+				continue
+			}
+			data, err := readZipFile(f)
+			if err != nil {
+				return err
+			}
+			if err := fn(f.Name, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return r.streamTar(func(name string, tr io.Reader) (bool, error) {
+		if match != nil && !match(name) {
+			return false, nil
+		}
+		data, err := ioReadAllUnpack(tr)
+		if err != nil {
+			return true, err
+		}
+		return false, fn(name, data)
+	})
+}
+
+// streamTar calls visit for each regular file of a tar capsule until it
+// returns stop or an error.
+func (r *ArchiveReader) streamTar(visit func(name string, tr io.Reader) (stop bool, err error)) error {
+	file, err := osOpenUnpack(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	var decompressReader io.Reader
+	switch r.compression {
+	case CompressionGzip:
+		gzReader, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		defer gzReader.Close()
+		decompressReader = gzReader
+	case CompressionXZ:
+		xzReader, err := xzNewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to create xz reader: %w", err)
+		}
+		decompressReader = xzReader
+	default:
+		return fmt.Errorf("unsupported compression: %s", r.compression)
+	}
+
+	tarReader := tar.NewReader(decompressReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		stop, err := visit(filepath.ToSlash(filepath.Clean(header.Name)), tarReader)
+		if stop || err != nil {
+			return err
+		}
+	}
+}
+
+// SetIdentities sets the identities used to decrypt blobs of an
+// encrypted capsule.
+func (r *ArchiveReader) SetIdentities(ids []age.Identity) {
+	r.identities = ids
+}
+
+// ReadBlob reads a blob by its SHA-256 hash and verifies its content.
+// Encrypted blobs are decrypted with the reader's identities.
+func (r *ArchiveReader) ReadBlob(sha256Hash string) ([]byte, error) {
+	blobPath := fmt.Sprintf("blobs/sha256/%s/%s", safePrefix(sha256Hash), sha256Hash)
+	record, ok := r.manifest.Blobs.BySHA256[sha256Hash]
+	if ok && record.Path != "" {
+		blobPath = record.Path
+	}
+
+	data, err := r.ReadFile(blobPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && record.Encryption != nil {
+		return decryptBlob(sha256Hash, data, r.identities)
+	}
+
+	if actual := cas.Hash(data); actual != sha256Hash {
+		return nil, errors.NewValidation("blob", fmt.Sprintf("hash mismatch for %s: got %s", sha256Hash, actual))
+	}
+	return data, nil
+}
+
+// VerifyBlob checks the integrity of a blob. Encrypted blobs are checked
+// against their recorded ciphertext hash when no identities are set.
+func (r *ArchiveReader) VerifyBlob(sha256Hash string) error {
+	record, ok := r.manifest.Blobs.BySHA256[sha256Hash]
+	if !ok || record.Encryption == nil || len(r.identities) > 0 {
+		_, err := r.ReadBlob(sha256Hash)
+		return err
+	}
+
+	data, err := r.ReadFile(record.Path)
+	if err != nil {
+		return err
+	}
+	if cas.Hash(data) != record.Encryption.CiphertextSHA256 {
+		return errors.NewValidation("blob", fmt.Sprintf("ciphertext hash mismatch for %s", sha256Hash))
+	}
+	return nil
+}
+
+// ReadBlobByBLAKE3 reads a blob by its BLAKE3 hash.
+// The manifest blob index is consulted first, falling back to the
+// pointer files stored under blobs/blake3.
+func (r *ArchiveReader) ReadBlobByBLAKE3(blake3Hash string) ([]byte, error) {
+	for sha, record := range r.manifest.Blobs.BySHA256 {
+		if record.BLAKE3 == blake3Hash {
+			return r.ReadBlob(sha)
+		}
+	}
+
+	pointerPath := fmt.Sprintf("blobs/blake3/%s/%s.json", safePrefix(blake3Hash), blake3Hash)
+	pointerData, err := r.ReadFile(pointerPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var pointer struct {
+		SHA256 string `json:"sha256"`
+	}
+	if err := jsonUnmarshalCapsule(pointerData, &pointer); err != nil {
+		return nil, errors.NewParse("blake3 pointer", pointerPath, err.Error())
+	}
+	return r.ReadBlob(pointer.SHA256)
+}
+
+// ReadArtifact reads the primary blob of an artifact.
+func (r *ArchiveReader) ReadArtifact(artifactID string) ([]byte, error) {
+	artifact, ok := r.manifest.Artifacts[artifactID]
+	if !ok {
+		return nil, errors.NewNotFound("artifact", artifactID)
+	}
+	return r.ReadBlob(artifact.PrimaryBlobSHA256)
+}
+
+// Close releases resources held by the reader.
+func (r *ArchiveReader) Close() error {
+	if r.zipReader != nil {
+		return r.zipReader.Close()
+	}
+	return nil
+}
+
+// safePrefix returns the two-character fan-out prefix for a hash.
+func safePrefix(hash string) string {
+	if len(hash) < 2 {
+		return hash
+	}
+	return hash[:2]
+}
+
+// Repack converts a packed capsule to a different container format.
+// The manifest and all blobs are carried over unchanged, so blob hashes
+// are identical in the source and destination archives.
+func Repack(srcPath, dstPath string, opts *PackOptions) error {
+	tempDir, err := osMkdirTemp("", "capsule-repack-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer osRemoveAll(tempDir)
+
+	c, err := Unpack(srcPath, tempDir)
+	if err != nil {
+		return fmt.Errorf("failed to unpack capsule: %w", err)
+	}
+
+	return c.PackWithOptions(dstPath, opts)
+}
+
+// ArchiveExtension returns the conventional file extension for a capsule
+// packed with the given container format.
+func ArchiveExtension(compression CompressionType) string {
+	switch compression {
+	case CompressionGzip:
+		return ".capsule.tar.gz"
+	case CompressionZip:
+		return ".capsule.zip"
+	default:
+		return ".capsule.tar.xz"
+	}
+}
@@ -0,0 +1,249 @@
+package capsule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+)
+
+// newArchiveTestCapsule creates a capsule with two ingested files.
+func newArchiveTestCapsule(t *testing.T) (*Capsule, *Artifact, *Artifact) {
+	t.Helper()
+	tempDir := t.TempDir()
+
+	fileA := filepath.Join(tempDir, "a.txt")
+	fileB := filepath.Join(tempDir, "b.txt")
+	if err := os.WriteFile(fileA, []byte("first artifact content"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	if err := os.WriteFile(fileB, []byte("second artifact content"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+
+	c, err := New(filepath.Join(tempDir, "capsule"))
+	if err != nil {
+		t.Fatalf("failed to create capsule: %v", err)
+	}
+	a, err := c.IngestFile(fileA)
+	if err != nil {
+		t.Fatalf("failed to ingest file: %v", err)
+	}
+	b, err := c.IngestFile(fileB)
+	if err != nil {
+		t.Fatalf("failed to ingest file: %v", err)
+	}
+	return c, a, b
+}
+
+// TestPackZipAndUnpack tests the zip container round trip.
+func TestPackZipAndUnpack(t *testing.T) {
+	c, a, b := newArchiveTestCapsule(t)
+
+	archivePath := filepath.Join(t.TempDir(), "test.capsule.zip")
+	if err := c.PackWithOptions(archivePath, &PackOptions{Compression: CompressionZip}); err != nil {
+		t.Fatalf("failed to pack: %v", err)
+	}
+
+	compression, err := DetectCompression(archivePath)
+	if err != nil {
+		t.Fatalf("failed to detect compression: %v", err)
+	}
+	if compression != CompressionZip {
+		t.Errorf("expected zip compression, got %s", compression)
+	}
+
+	unpacked, err := Unpack(archivePath, t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to unpack: %v", err)
+	}
+
+	for _, artifact := range []*Artifact{a, b} {
+		data, err := unpacked.GetStore().Retrieve(artifact.PrimaryBlobSHA256)
+		if err != nil {
+			t.Fatalf("failed to retrieve blob: %v", err)
+		}
+		if cas.Hash(data) != artifact.Hashes.SHA256 {
+			t.Errorf("hash mismatch for %s", artifact.ID)
+		}
+	}
+}
+
+// TestArchiveReaderZip tests random-access reads from a zip capsule.
+func TestArchiveReaderZip(t *testing.T) {
+	c, a, b := newArchiveTestCapsule(t)
+
+	archivePath := filepath.Join(t.TempDir(), "test.capsule.zip")
+	if err := c.PackWithOptions(archivePath, &PackOptions{Compression: CompressionZip}); err != nil {
+		t.Fatalf("failed to pack: %v", err)
+	}
+
+	r, err := OpenArchive(archivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer r.Close()
+
+	if !r.RandomAccess() {
+		t.Error("zip archive should support random access")
+	}
+	if len(r.Manifest().Artifacts) != 2 {
+		t.Errorf("expected 2 artifacts, got %d", len(r.Manifest().Artifacts))
+	}
+
+	data, err := r.ReadBlob(b.Hashes.SHA256)
+	if err != nil {
+		t.Fatalf("ReadBlob failed: %v", err)
+	}
+	if string(data) != "second artifact content" {
+		t.Errorf("unexpected blob content: %q", data)
+	}
+
+	data, err = r.ReadBlobByBLAKE3(a.Hashes.BLAKE3)
+	if err != nil {
+		t.Fatalf("ReadBlobByBLAKE3 failed: %v", err)
+	}
+	if string(data) != "first artifact content" {
+		t.Errorf("unexpected blob content: %q", data)
+	}
+
+	data, err = r.ReadArtifact(a.ID)
+	if err != nil {
+		t.Fatalf("ReadArtifact failed: %v", err)
+	}
+	if cas.Hash(data) != a.Hashes.SHA256 {
+		t.Error("artifact hash mismatch")
+	}
+}
+
+// TestArchiveReaderTarFallback tests that tar capsules remain readable.
+func TestArchiveReaderTarFallback(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+
+	for _, compression := range []CompressionType{CompressionXZ, CompressionGzip} {
+		t.Run(string(compression), func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), "test"+ArchiveExtension(compression))
+			if err := c.PackWithOptions(archivePath, &PackOptions{Compression: compression}); err != nil {
+				t.Fatalf("failed to pack: %v", err)
+			}
+
+			r, err := OpenArchive(archivePath)
+			if err != nil {
+				t.Fatalf("failed to open archive: %v", err)
+			}
+			defer r.Close()
+
+			if r.RandomAccess() {
+				t.Error("tar archive should not report random access")
+			}
+
+			data, err := r.ReadArtifact(a.ID)
+			if err != nil {
+				t.Fatalf("ReadArtifact failed: %v", err)
+			}
+			if string(data) != "first artifact content" {
+				t.Errorf("unexpected blob content: %q", data)
+			}
+		})
+	}
+}
+
+// TestArchiveReaderWalkFiles tests visiting every entry of zip and tar capsules.
+func TestArchiveReaderWalkFiles(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+
+	for _, compression := range []CompressionType{CompressionZip, CompressionXZ} {
+		t.Run(string(compression), func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), "test"+ArchiveExtension(compression))
+			if err := c.PackWithOptions(archivePath, &PackOptions{Compression: compression}); err != nil {
+				t.Fatalf("failed to pack: %v", err)
+			}
+			r, err := OpenArchive(archivePath)
+			if err != nil {
+				t.Fatalf("failed to open archive: %v", err)
+			}
+			defer r.Close()
+
+			files := make(map[string][]byte)
+			if err := r.WalkFiles(func(name string, data []byte) error {
+				files[name] = data
+				return nil
+			}); err != nil {
+				t.Fatalf("WalkFiles failed: %v", err)
+			}
+			if _, ok := files["manifest.json"]; !ok {
+				t.Error("expected manifest.json entry")
+			}
+			path := c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256].Path
+			if string(files[path]) != "first artifact content" {
+				t.Errorf("unexpected content for %s: %q", path, files[path])
+			}
+		})
+	}
+}
+
+// TestArchiveReaderMissingBlob tests reading a blob that is not in the archive.
+func TestArchiveReaderMissingBlob(t *testing.T) {
+	c, _, _ := newArchiveTestCapsule(t)
+
+	archivePath := filepath.Join(t.TempDir(), "test.capsule.zip")
+	if err := c.PackWithOptions(archivePath, &PackOptions{Compression: CompressionZip}); err != nil {
+		t.Fatalf("failed to pack: %v", err)
+	}
+
+	r, err := OpenArchive(archivePath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer r.Close()
+
+	if _, err := r.ReadBlob(cas.Hash([]byte("not stored"))); err == nil {
+		t.Error("expected error for missing blob")
+	}
+	if _, err := r.ReadArtifact("nonexistent"); err == nil {
+		t.Error("expected error for missing artifact")
+	}
+}
+
+// TestRepack tests converting a tar.xz capsule to zip preserves hashes.
+func TestRepack(t *testing.T) {
+	c, a, b := newArchiveTestCapsule(t)
+
+	tempDir := t.TempDir()
+	srcPath := filepath.Join(tempDir, "test.capsule.tar.xz")
+	if err := c.Pack(srcPath); err != nil {
+		t.Fatalf("failed to pack: %v", err)
+	}
+
+	dstPath := filepath.Join(tempDir, "test.capsule.zip")
+	if err := Repack(srcPath, dstPath, &PackOptions{Compression: CompressionZip}); err != nil {
+		t.Fatalf("Repack failed: %v", err)
+	}
+
+	r, err := OpenArchive(dstPath)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	defer r.Close()
+
+	for _, artifact := range []*Artifact{a, b} {
+		if _, err := r.ReadBlob(artifact.Hashes.SHA256); err != nil {
+			t.Errorf("blob for %s missing after repack: %v", artifact.ID, err)
+		}
+	}
+}
+
+// TestArchiveExtension tests the conventional extension for each container.
+func TestArchiveExtension(t *testing.T) {
+	tests := map[CompressionType]string{
+		CompressionXZ:   ".capsule.tar.xz",
+		CompressionGzip: ".capsule.tar.gz",
+		CompressionZip:  ".capsule.zip",
+	}
+	for compression, want := range tests {
+		if got := ArchiveExtension(compression); got != want {
+			t.Errorf("ArchiveExtension(%s) = %q, want %q", compression, got, want)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+package capsule
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// BagIt (RFC 8493) constants.
+const (
+	BagItVersion       = "1.0"
+	bagItDeclaration   = "bagit.txt"
+	bagInfoFile        = "bag-info.txt"
+	bagPayloadDir      = "data"
+	bagTagManifestFile = "tagmanifest-sha512.txt"
+)
+
+// bagManifestAlgorithms are the payload manifests written, strongest first.
+// SHA-256 is included so payload digests line up with capsule blob hashes.
+var bagManifestAlgorithms = []string{"sha512", "sha256"}
+
+// BagItExportOptions configures ExportBagIt.
+type BagItExportOptions struct {
+	// Info holds additional bag-info.txt fields (e.g. Source-Organization,
+	// External-Identifier).
+	Info map[string]string
+}
+
+// bagDigest returns the digest of data for a BagIt manifest algorithm.
+func bagDigest(algorithm string, data []byte) string {
+	if algorithm == "sha256" {
+		return cas.Hash(data)
+	}
+	return sha512Hex(data)
+}
+
+// ExportBagIt writes the capsule as a BagIt bag at bagDir. The packed
+// capsule files (manifest.json and blobs/) form the payload under data/,
+// with SHA-512 and SHA-256 payload manifests, bag-info.txt describing the
+// capsule, and a SHA-512 tag manifest.
+func (c *Capsule) ExportBagIt(bagDir string, opts BagItExportOptions) error {
+	if err := ensureEmptyDir(bagDir); err != nil {
+		return err
+	}
+
+	files, err := c.payloadFiles()
+	if err != nil {
+		return err
+	}
+
+	manifests := make(map[string]*bytes.Buffer, len(bagManifestAlgorithms))
+	for _, alg := range bagManifestAlgorithms {
+		manifests[alg] = &bytes.Buffer{}
+	}
+	var octets int64
+	for _, f := range files {
+		data, err := f.read()
+		if err != nil {
+			return errors.NewIO("read", f.logical, err)
+		}
+		payloadPath := bagPayloadDir + "/" + f.logical
+		if err := writePreservationFile(bagDir, payloadPath, data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", payloadPath, err)
+		}
+		for _, alg := range bagManifestAlgorithms {
+			fmt.Fprintf(manifests[alg], "%s  %s\n", bagDigest(alg, data), bagEncodePath(payloadPath))
+		}
+		octets += int64(len(data))
+	}
+
+	manifestHash, err := ManifestHash(c.Manifest)
+	if err != nil {
+		return err
+	}
+	info := map[string]string{
+		"Bag-Software-Agent":      fmt.Sprintf("capsule %s", Version),
+		"Bagging-Date":            time.Now().UTC().Format("2006-01-02"),
+		"Payload-Oxum":            fmt.Sprintf("%d.%d", octets, len(files)),
+		"Capsule-Version":         c.Manifest.CapsuleVersion,
+		"Capsule-Revision":        strconv.Itoa(c.Manifest.CurrentRevision()),
+		"Capsule-Manifest-SHA256": manifestHash,
+	}
+	for k, v := range opts.Info {
+		info[k] = v
+	}
+
+	tagFiles := map[string][]byte{
+		bagItDeclaration: []byte(fmt.Sprintf("BagIt-Version: %s\nTag-File-Character-Encoding: UTF-8\n", BagItVersion)),
+		bagInfoFile:      formatBagInfo(info),
+	}
+	for _, alg := range bagManifestAlgorithms {
+		tagFiles["manifest-"+alg+".txt"] = manifests[alg].Bytes()
+	}
+
+	names := make([]string, 0, len(tagFiles))
+	for name := range tagFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var tagManifest bytes.Buffer
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(bagDir, name), tagFiles[name], 0644); err != nil {
+			return errors.NewIO("write", name, err)
+		}
+		fmt.Fprintf(&tagManifest, "%s  %s\n", sha512Hex(tagFiles[name]), name)
+	}
+	if err := os.WriteFile(filepath.Join(bagDir, bagTagManifestFile), tagManifest.Bytes(), 0644); err != nil {
+		return errors.NewIO("write", bagTagManifestFile, err)
+	}
+	return nil
+}
+
+// formatBagInfo renders bag-info.txt with labels in sorted order.
+func formatBagInfo(info map[string]string) []byte {
+	labels := make([]string, 0, len(info))
+	for label := range info {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var buf bytes.Buffer
+	for _, label := range labels {
+		fmt.Fprintf(&buf, "%s: %s\n", label, info[label])
+	}
+	return buf.Bytes()
+}
+
+// ReadBagInfo parses the bag-info.txt of a bag. Continuation lines
+// (starting with whitespace) are folded into the preceding value.
+func ReadBagInfo(bagDir string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(bagDir, bagInfoFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, errors.NewIO("read", bagInfoFile, err)
+	}
+
+	info := make(map[string]string)
+	var last string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && last != "" {
+			info[last] += " " + strings.TrimSpace(line)
+			continue
+		}
+		label, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, errors.NewParse("bag-info", bagInfoFile, fmt.Sprintf("malformed line %q", line))
+		}
+		last = strings.TrimSpace(label)
+		info[last] = strings.TrimSpace(value)
+	}
+	return info, nil
+}
+
+// ImportBagIt validates the bag at bagDir and materialises its payload as
+// a capsule in destDir. Tag and payload manifests must match, the payload
+// must contain exactly the listed files, and Payload-Oxum (if present)
+// must agree with the payload.
+func ImportBagIt(bagDir, destDir string) (*Capsule, error) {
+	if _, err := os.Stat(filepath.Join(bagDir, bagItDeclaration)); err != nil {
+		return nil, errors.NewNotFound("bagit declaration", filepath.Join(bagDir, bagItDeclaration))
+	}
+
+	// Tag manifest covers bagit.txt, bag-info.txt and payload manifests
+	if _, err := os.Stat(filepath.Join(bagDir, bagTagManifestFile)); err == nil {
+		if _, err := verifyBagManifest(bagDir, bagTagManifestFile, "sha512"); err != nil {
+			return nil, err
+		}
+	}
+
+	var listed map[string]bool
+	for _, alg := range bagManifestAlgorithms {
+		name := "manifest-" + alg + ".txt"
+		if _, err := os.Stat(filepath.Join(bagDir, name)); err != nil {
+			continue
+		}
+		paths, err := verifyBagManifest(bagDir, name, alg)
+		if err != nil {
+			return nil, err
+		}
+		if listed == nil {
+			listed = paths
+		}
+	}
+	if listed == nil {
+		return nil, errors.NewValidation("bagit", "bag has no supported payload manifest")
+	}
+
+	// Every payload file must be listed
+	var octets int64
+	count := 0
+	payloadRoot := filepath.Join(bagDir, bagPayloadDir)
+	if err := filepathWalk(payloadRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepathRel(bagDir, path)
+		if err != nil {
+			return err
+		}
+		if !listed[filepath.ToSlash(rel)] {
+			return errors.NewValidation("bagit payload", fmt.Sprintf("%s is not in the payload manifest", filepath.ToSlash(rel)))
+		}
+		octets += info.Size()
+		count++
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	info, err := ReadBagInfo(bagDir)
+	if err != nil {
+		return nil, err
+	}
+	if oxum, ok := info["Payload-Oxum"]; ok && oxum != fmt.Sprintf("%d.%d", octets, count) {
+		return nil, errors.NewValidation("bagit", fmt.Sprintf("Payload-Oxum %s does not match payload %d.%d", oxum, octets, count))
+	}
+
+	if err := ensureEmptyDir(destDir); err != nil {
+		return nil, err
+	}
+	for payloadPath := range listed {
+		data, err := os.ReadFile(filepath.Join(bagDir, filepath.FromSlash(payloadPath)))
+		if err != nil {
+			return nil, errors.NewIO("read", payloadPath, err)
+		}
+		if err := writePreservationFile(destDir, strings.TrimPrefix(payloadPath, bagPayloadDir+"/"), data); err != nil {
+			return nil, err
+		}
+	}
+
+	return openCapsuleDir(destDir)
+}
+
+// verifyBagManifest checks every entry of a BagIt manifest file and
+// returns the set of paths it lists.
+func verifyBagManifest(bagDir, name, algorithm string) (map[string]bool, error) {
+	data, err := os.ReadFile(filepath.Join(bagDir, name))
+	if err != nil {
+		return nil, errors.NewIO("read", name, err)
+	}
+
+	paths := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		digest, encoded, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, errors.NewParse("bagit manifest", name, fmt.Sprintf("malformed line %q", line))
+		}
+		rel := bagDecodePath(strings.TrimLeft(encoded, " "))
+		clean, err := cleanRelPath(rel)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := os.ReadFile(filepath.Join(bagDir, clean))
+		if err != nil {
+			return nil, errors.NewIO("read", rel, err)
+		}
+		if !strings.EqualFold(bagDigest(algorithm, content), digest) {
+			return nil, errors.NewValidation("bagit", fmt.Sprintf("%s digest mismatch for %s", algorithm, rel))
+		}
+		paths[filepath.ToSlash(clean)] = true
+	}
+	return paths, nil
+}
+
+// bagEncodePath percent-encodes the characters RFC 8493 requires in
+// manifest paths.
+func bagEncodePath(p string) string {
+	return strings.NewReplacer("%", "%25", "\n", "%0A", "\r", "%0D").Replace(p)
+}
+
+// bagDecodePath reverses bagEncodePath.
+func bagDecodePath(p string) string {
+	return strings.NewReplacer("%0A", "\n", "%0a", "\n", "%0D", "\r", "%0d", "\r", "%25", "%").Replace(p)
+}
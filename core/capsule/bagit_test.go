@@ -0,0 +1,114 @@
+package capsule
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestBagItRoundTrip tests capsule -> BagIt -> capsule reproduces the manifest and blobs.
+func TestBagItRoundTrip(t *testing.T) {
+	c := newRevisedTestCapsule(t)
+	bagDir := filepath.Join(t.TempDir(), "bag")
+
+	if err := c.ExportBagIt(bagDir, BagItExportOptions{Info: map[string]string{"Source-Organization": "Juniper"}}); err != nil {
+		t.Fatalf("ExportBagIt failed: %v", err)
+	}
+	for _, name := range []string{"bagit.txt", "bag-info.txt", "manifest-sha512.txt", "manifest-sha256.txt", "tagmanifest-sha512.txt", "data/manifest.json"} {
+		if _, err := os.Stat(filepath.Join(bagDir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+
+	info, err := ReadBagInfo(bagDir)
+	if err != nil {
+		t.Fatalf("ReadBagInfo failed: %v", err)
+	}
+	if info["Source-Organization"] != "Juniper" || info["Capsule-Revision"] != "1" {
+		t.Errorf("unexpected bag-info: %v", info)
+	}
+	hash, _ := ManifestHash(c.Manifest)
+	if info["Capsule-Manifest-SHA256"] != hash {
+		t.Error("bag-info manifest hash mismatch")
+	}
+
+	imported, err := ImportBagIt(bagDir, filepath.Join(t.TempDir(), "imported"))
+	if err != nil {
+		t.Fatalf("ImportBagIt failed: %v", err)
+	}
+	want, _ := c.Manifest.ToJSON()
+	got, _ := imported.Manifest.ToJSON()
+	if !bytes.Equal(want, got) {
+		t.Error("imported manifest differs from original")
+	}
+	if !reflect.DeepEqual(blobHashes(c.Manifest), blobHashes(imported.Manifest)) {
+		t.Error("imported blob index differs from original")
+	}
+}
+
+// TestImportBagItDetectsTampering tests payload and tag manifest validation.
+func TestImportBagItDetectsTampering(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+
+	newBag := func() string {
+		bagDir := filepath.Join(t.TempDir(), "bag")
+		if err := c.ExportBagIt(bagDir, BagItExportOptions{}); err != nil {
+			t.Fatalf("ExportBagIt failed: %v", err)
+		}
+		return bagDir
+	}
+
+	t.Run("payload", func(t *testing.T) {
+		bagDir := newBag()
+		blob := filepath.Join(bagDir, "data", filepath.FromSlash(c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256].Path))
+		if err := os.WriteFile(blob, []byte("tampered"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ImportBagIt(bagDir, filepath.Join(t.TempDir(), "out")); err == nil {
+			t.Error("expected digest mismatch")
+		}
+	})
+
+	t.Run("unlisted file", func(t *testing.T) {
+		bagDir := newBag()
+		if err := os.WriteFile(filepath.Join(bagDir, "data", "extra.txt"), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ImportBagIt(bagDir, filepath.Join(t.TempDir(), "out")); err == nil {
+			t.Error("expected error for unlisted payload file")
+		}
+	})
+
+	t.Run("bag-info", func(t *testing.T) {
+		bagDir := newBag()
+		f, err := os.OpenFile(filepath.Join(bagDir, "bag-info.txt"), os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("Contact-Name: someone\n")
+		f.Close()
+		if _, err := ImportBagIt(bagDir, filepath.Join(t.TempDir(), "out")); err == nil {
+			t.Error("expected tag manifest mismatch")
+		}
+	})
+
+	t.Run("not a bag", func(t *testing.T) {
+		if _, err := ImportBagIt(t.TempDir(), filepath.Join(t.TempDir(), "out")); err == nil {
+			t.Error("expected error for directory without bagit.txt")
+		}
+	})
+}
+
+// TestExportBagItRequiresEmptyDir tests that an existing bag is not overwritten.
+func TestExportBagItRequiresEmptyDir(t *testing.T) {
+	c, _, _ := newArchiveTestCapsule(t)
+	bagDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bagDir, "existing"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ExportBagIt(bagDir, BagItExportOptions{}); err == nil {
+		t.Error("expected error for non-empty directory")
+	}
+}
@@ -0,0 +1,181 @@
+package capsule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// BlobSource is somewhere Fsck can recover blobs from: another capsule, a
+// shared CAS store or a mirror directory.
+type BlobSource interface {
+	// Name identifies the source in fsck reports.
+	Name() string
+
+	// FindBlob returns the plaintext of a blob by SHA-256, falling back to
+	// the BLAKE3 hash when blake3Hash is set. Callers verify the result.
+	FindBlob(sha256Hash, blake3Hash string) ([]byte, error)
+
+	// ReadFile returns a file by its capsule-relative path, e.g. an
+	// encrypted blob under blobs/age.
+	ReadFile(rel string) ([]byte, error)
+
+	// Close releases the source.
+	Close() error
+}
+
+// storeSource reads blobs from a directory with the CAS layout: an
+// unpacked capsule, a shared store, or the content directory of an OCFL
+// version or BagIt payload. It never writes to the directory.
+type storeSource struct {
+	root string
+}
+
+// NewStoreSource returns a blob source for a directory containing blobs/.
+func NewStoreSource(root string) (BlobSource, error) {
+	if _, err := os.Stat(filepath.Join(root, "blobs")); err != nil {
+		return nil, errors.NewNotFound("blob store", root)
+	}
+	return &storeSource{root: root}, nil
+}
+
+func (s *storeSource) Name() string { return s.root }
+
+func (s *storeSource) FindBlob(sha256Hash, blake3Hash string) ([]byte, error) {
+	data, err := s.ReadFile(plainBlobPath(sha256Hash))
+	if err == nil || blake3Hash == "" {
+		return data, err
+	}
+
+	pointerData, err := s.ReadFile(fmt.Sprintf("blobs/blake3/%s/%s.json", safePrefix(blake3Hash), blake3Hash))
+	if err != nil {
+		return nil, err
+	}
+	var pointer struct {
+		SHA256 string `json:"sha256"`
+	}
+	if err := json.Unmarshal(pointerData, &pointer); err != nil {
+		return nil, errors.NewParse("blake3 pointer", blake3Hash, err.Error())
+	}
+	return s.ReadFile(plainBlobPath(pointer.SHA256))
+}
+
+func (s *storeSource) ReadFile(rel string) ([]byte, error) {
+	clean, err := cleanRelPath(rel)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(s.root, clean))
+}
+
+func (s *storeSource) Close() error { return nil }
+
+// archiveSource reads blobs from a packed capsule. Encrypted blobs are
+// decrypted with the default identities.
+type archiveSource struct {
+	r *ArchiveReader
+}
+
+// NewArchiveSource returns a blob source for a packed capsule.
+func NewArchiveSource(path string) (BlobSource, error) {
+	r, err := OpenArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	return &archiveSource{r: r}, nil
+}
+
+func (s *archiveSource) Name() string { return s.r.path }
+
+func (s *archiveSource) FindBlob(sha256Hash, blake3Hash string) ([]byte, error) {
+	data, err := s.r.ReadBlob(sha256Hash)
+	if err == nil || blake3Hash == "" {
+		return data, err
+	}
+	return s.r.ReadBlobByBLAKE3(blake3Hash)
+}
+
+func (s *archiveSource) ReadFile(rel string) ([]byte, error) {
+	return s.r.ReadFile(rel)
+}
+
+func (s *archiveSource) Close() error { return s.r.Close() }
+
+// OpenBlobSources opens blob sources for the given paths. A file is opened
+// as a packed capsule and a directory containing blobs/ as a store. Any
+// other directory is treated as a directory of mirrors: every capsule
+// (*.capsule.*) and store found beneath it becomes a source, including
+// OCFL objects and BagIt bags written by ExportOCFL and ExportBagIt.
+func OpenBlobSources(paths []string) ([]BlobSource, error) {
+	var sources []BlobSource
+	closeAll := func() {
+		for _, src := range sources {
+			src.Close()
+		}
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			closeAll()
+			return nil, errors.NewIO("stat", path, err)
+		}
+		if !info.IsDir() {
+			src, err := NewArchiveSource(path)
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("failed to open %s: %w", path, err)
+			}
+			sources = append(sources, src)
+			continue
+		}
+
+		found, err := findMirrorSources(path)
+		sources = append(sources, found...)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// findMirrorSources walks dir for stores and packed capsules.
+func findMirrorSources(dir string) ([]BlobSource, error) {
+	var sources []BlobSource
+	err := filepathWalk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == "blobs" {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "blobs")); err == nil {
+				sources = append(sources, &storeSource{root: path})
+			}
+			return nil
+		}
+		if !strings.Contains(info.Name(), ".capsule.") {
+			return nil
+		}
+		src, err := NewArchiveSource(path)
+		if err != nil {
+			// Not every *.capsule.* file need be a readable capsule
+			return nil
+		}
+		sources = append(sources, src)
+		return nil
+	})
+	if err != nil {
+		for _, src := range sources {
+			src.Close()
+		}
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return sources, nil
+}
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"filippo.io/age"
 	"github.com/FocuswithJustin/JuniperBible/core/cas"
 	"github.com/FocuswithJustin/JuniperBible/core/errors"
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
@@ -82,6 +83,9 @@ const (
 	CompressionXZ CompressionType = "xz"
 	// CompressionGzip uses gzip compression (stdlib, faster).
 	CompressionGzip CompressionType = "gzip"
+	// CompressionZip uses a zip container with per-entry deflate compression.
+	// Unlike the tar variants it supports random access to individual blobs.
+	CompressionZip CompressionType = "zip"
 )
 
 // PackOptions configures capsule packing behavior.
@@ -99,9 +103,10 @@ func DefaultPackOptions() *PackOptions {
 
 // Capsule represents an in-memory capsule with its manifest and blob store.
 type Capsule struct {
-	root     string
-	Manifest *Manifest
-	store    *cas.Store
+	root       string
+	Manifest   *Manifest
+	store      *cas.Store
+	identities []age.Identity
 }
 
 // New creates a new empty capsule at the given root directory.
@@ -116,9 +121,10 @@ func New(root string) (*Capsule, error) {
 	}
 
 	return &Capsule{
-		root:     root,
-		Manifest: NewManifest(),
-		store:    store,
+		root:       root,
+		Manifest:   NewManifest(),
+		store:      store,
+		identities: getDefaultIdentities(),
 	}, nil
 }
 
@@ -199,6 +205,13 @@ func (c *Capsule) PackWithOptions(archivePath string, opts *PackOptions) error {
 		opts = DefaultPackOptions()
 	}
 
+	// Blobs added to an encrypted capsule must not be packed in the clear
+	if c.IsEncrypted() {
+		if err := c.encryptPending(); err != nil {
+			return err
+		}
+	}
+
 	// Create the archive file
 	file, err := os.Create(archivePath)
 	if err != nil {
@@ -206,6 +219,11 @@ func (c *Capsule) PackWithOptions(archivePath string, opts *PackOptions) error {
 	}
 	defer file.Close()
 
+	// Zip capsules use their own container layout
+	if opts.Compression == CompressionZip {
+		return c.packZip(file)
+	}
+
 	// Create compression writer based on options
 	var compressWriter io.WriteCloser
 	switch opts.Compression {
@@ -293,6 +311,11 @@ func DetectCompression(archivePath string) (CompressionType, error) {
 		return CompressionGzip, nil
 	}
 
+	// Check for zip magic (50 4b 03 04)
+	if n >= 4 && magic[0] == 'P' && magic[1] == 'K' && magic[2] == 0x03 && magic[3] == 0x04 {
+		return CompressionZip, nil
+	}
+
 	// Check for XZ magic (fd 37 7a 58 5a 00)
 	if n >= 6 && magic[0] == 0xfd && magic[1] == 0x37 && magic[2] == 0x7a &&
 		magic[3] == 0x58 && magic[4] == 0x5a && magic[5] == 0x00 {
@@ -303,7 +326,7 @@ func DetectCompression(archivePath string) (CompressionType, error) {
 }
 
 // Unpack unpacks a capsule archive to the given directory.
-// Auto-detects compression format (XZ or gzip).
+// Auto-detects compression format (XZ, gzip or zip).
 func Unpack(archivePath, destDir string) (*Capsule, error) {
 	// Create destination directory
 	if err := osMkdirAllUnpack(destDir, 0755); err != nil {
@@ -316,6 +339,10 @@ func Unpack(archivePath, destDir string) (*Capsule, error) {
 		return nil, fmt.Errorf("failed to detect compression: %w", err)
 	}
 
+	if compression == CompressionZip {
+		return unpackZip(archivePath, destDir)
+	}
+
 	// Open the archive
 	file, err := osOpenUnpack(archivePath)
 	if err != nil {
@@ -409,9 +436,10 @@ func Unpack(archivePath, destDir string) (*Capsule, error) {
 	}
 
 	return &Capsule{
-		root:     destDir,
-		Manifest: manifest,
-		store:    store,
+		root:       destDir,
+		Manifest:   manifest,
+		store:      store,
+		identities: getDefaultIdentities(),
 	}, nil
 }
 
@@ -505,7 +533,7 @@ func (c *Capsule) GetTranscript(runID string) ([]byte, error) {
 		return nil, errors.NewValidation("transcript", fmt.Sprintf("run %s has no transcript", runID))
 	}
 
-	return c.store.Retrieve(run.Outputs.TranscriptBlobSHA256)
+	return c.ReadBlob(run.Outputs.TranscriptBlobSHA256)
 }
 
 // GetRoot returns the root directory of the capsule.
@@ -618,7 +646,7 @@ func (c *Capsule) LoadIR(artifactID string) (*ir.Corpus, error) {
 	}
 
 	// Retrieve the blob
-	data, err := storeRetrieve(c.store, artifact.PrimaryBlobSHA256)
+	data, err := c.ReadBlob(artifact.PrimaryBlobSHA256)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to retrieve IR blob")
 	}
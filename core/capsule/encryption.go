@@ -0,0 +1,326 @@
+package capsule
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// EncryptionSchemeAge identifies blobs encrypted with age X25519 recipients
+// (X25519 key agreement, ChaCha20-Poly1305 payload).
+const EncryptionSchemeAge = "age-x25519"
+
+// ErrEncrypted is returned when an encrypted blob is read without a matching identity.
+var ErrEncrypted = errors.NewValidation("blob", "blob is encrypted and no matching identity was provided")
+
+// EncryptionInfo describes how a capsule's blobs are encrypted at rest.
+// The manifest itself is never encrypted so capsules stay browsable.
+type EncryptionInfo struct {
+	// Scheme is the encryption scheme (see EncryptionSchemeAge).
+	Scheme string `json:"scheme"`
+
+	// Recipients are the public keys that can decrypt the blobs.
+	Recipients []string `json:"recipients"`
+
+	// Attributes contains additional metadata.
+	Attributes Attributes `json:"attributes,omitempty"`
+}
+
+// BlobEncryption records the ciphertext of an encrypted blob so integrity
+// can be verified without keys.
+type BlobEncryption struct {
+	Scheme           string `json:"scheme"`
+	CiphertextSHA256 string `json:"ciphertext_sha256"`
+	CiphertextSize   int64  `json:"ciphertext_size"`
+}
+
+// defaultIdentities are attached to capsules opened after SetDefaultIdentities.
+var (
+	defaultIdentitiesMu sync.RWMutex
+	defaultIdentities   []age.Identity
+)
+
+// SetDefaultIdentities sets the identities used to decrypt capsules opened
+// with New, Unpack and OpenArchive. This lets callers such as the CLI and
+// the web server decrypt transparently given an identity file.
+func SetDefaultIdentities(ids []age.Identity) {
+	defaultIdentitiesMu.Lock()
+	defer defaultIdentitiesMu.Unlock()
+	defaultIdentities = ids
+}
+
+// getDefaultIdentities returns the identities set by SetDefaultIdentities.
+func getDefaultIdentities() []age.Identity {
+	defaultIdentitiesMu.RLock()
+	defer defaultIdentitiesMu.RUnlock()
+	return defaultIdentities
+}
+
+// GenerateIdentity creates a new X25519 identity and returns the secret
+// identity string (AGE-SECRET-KEY-1...) and its public recipient (age1...).
+func GenerateIdentity() (identity, recipient string, err error) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate identity: %w", err)
+	}
+	return id.String(), id.Recipient().String(), nil
+}
+
+// ParseRecipients parses age X25519 public keys.
+func ParseRecipients(keys []string) ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(keys))
+	for _, key := range keys {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(key))
+		if err != nil {
+			return nil, errors.NewValidation("recipient", err.Error())
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// LoadIdentities reads an age identity file.
+func LoadIdentities(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.NewIO("open", path, err)
+	}
+	defer f.Close()
+
+	ids, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, errors.NewParse("identity file", path, err.Error())
+	}
+	return ids, nil
+}
+
+// SetIdentities sets the identities used to decrypt this capsule's blobs.
+func (c *Capsule) SetIdentities(ids []age.Identity) {
+	c.identities = ids
+}
+
+// HasIdentities reports whether identities are available to decrypt blobs.
+func (c *Capsule) HasIdentities() bool {
+	return len(c.identities) > 0
+}
+
+// IsEncrypted returns true if the capsule's blobs are encrypted at rest.
+func (c *Capsule) IsEncrypted() bool {
+	return c.Manifest.Encryption != nil
+}
+
+// encryptedBlobPath returns the capsule-relative path of an encrypted blob.
+func encryptedBlobPath(sha256Hash string) string {
+	return fmt.Sprintf("blobs/age/%s/%s.age", safePrefix(sha256Hash), sha256Hash)
+}
+
+// Encrypt encrypts every blob in the capsule to the given recipients.
+// If the capsule is already encrypted its blobs are first decrypted with
+// the capsule's identities, so Encrypt also rotates recipients.
+func (c *Capsule) Encrypt(recipientKeys []string) error {
+	if len(recipientKeys) == 0 {
+		return errors.NewValidation("recipients", "at least one recipient is required")
+	}
+	if _, err := ParseRecipients(recipientKeys); err != nil {
+		return err
+	}
+
+	if c.IsEncrypted() {
+		if err := c.Decrypt(); err != nil {
+			return fmt.Errorf("failed to decrypt for re-encryption: %w", err)
+		}
+	}
+
+	keys := append([]string(nil), recipientKeys...)
+	sort.Strings(keys)
+	c.Manifest.Encryption = &EncryptionInfo{
+		Scheme:     EncryptionSchemeAge,
+		Recipients: keys,
+	}
+	return c.encryptPending()
+}
+
+// encryptPending encrypts every plaintext blob under blobs/sha256 to the
+// manifest recipients and removes the plaintext. It is called by Encrypt
+// and before packing so blobs added to an encrypted capsule never leave
+// it in the clear.
+func (c *Capsule) encryptPending() error {
+	recipients, err := ParseRecipients(c.Manifest.Encryption.Recipients)
+	if err != nil {
+		return err
+	}
+
+	plainDir := filepath.Join(c.root, "blobs", "sha256")
+	if _, err := os.Stat(plainDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	var hashes []string
+	if err := filepathWalk(plainDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			hashes = append(hashes, info.Name())
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to scan blobs: %w", err)
+	}
+
+	for _, hash := range hashes {
+		if err := c.encryptBlob(hash, recipients); err != nil {
+			return fmt.Errorf("failed to encrypt blob %s: %w", hash, err)
+		}
+	}
+	return nil
+}
+
+// encryptBlob encrypts a single plaintext blob and updates its blob record.
+func (c *Capsule) encryptBlob(hash string, recipients []age.Recipient) error {
+	plaintext, err := c.store.Retrieve(hash)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipients...)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	ciphertext := buf.Bytes()
+
+	relPath := encryptedBlobPath(hash)
+	absPath := filepath.Join(c.root, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(absPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(absPath, ciphertext, 0644); err != nil {
+		return err
+	}
+
+	record, ok := c.Manifest.Blobs.BySHA256[hash]
+	if !ok {
+		record = &BlobRecord{
+			SHA256:    hash,
+			BLAKE3:    cas.Blake3Hash(plaintext),
+			SizeBytes: int64(len(plaintext)),
+		}
+		c.Manifest.Blobs.BySHA256[hash] = record
+	}
+	record.Path = relPath
+	record.Encryption = &BlobEncryption{
+		Scheme:           EncryptionSchemeAge,
+		CiphertextSHA256: cas.Hash(ciphertext),
+		CiphertextSize:   int64(len(ciphertext)),
+	}
+
+	plainPath := filepath.Join(c.root, "blobs", "sha256", safePrefix(hash), hash)
+	return os.Remove(plainPath)
+}
+
+// Decrypt decrypts every blob with the capsule's identities, restores the
+// plaintext CAS layout and removes the encryption metadata.
+func (c *Capsule) Decrypt() error {
+	if !c.IsEncrypted() {
+		return nil
+	}
+
+	for hash, record := range c.Manifest.Blobs.BySHA256 {
+		if record.Encryption == nil {
+			continue
+		}
+		plaintext, err := c.ReadBlob(hash)
+		if err != nil {
+			return err
+		}
+		if _, err := c.store.Store(plaintext); err != nil {
+			return fmt.Errorf("failed to store blob %s: %w", hash, err)
+		}
+		if err := os.Remove(filepath.Join(c.root, filepath.FromSlash(record.Path))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove ciphertext: %w", err)
+		}
+		record.Path = fmt.Sprintf("blobs/sha256/%s/%s", safePrefix(hash), hash)
+		record.Encryption = nil
+	}
+
+	c.Manifest.Encryption = nil
+	return nil
+}
+
+// ReadBlob returns the plaintext of a blob, decrypting it if necessary.
+// The plaintext is always checked against its SHA-256.
+func (c *Capsule) ReadBlob(sha256Hash string) ([]byte, error) {
+	record, ok := c.Manifest.Blobs.BySHA256[sha256Hash]
+	if !ok || record.Encryption == nil {
+		return storeRetrieve(c.store, sha256Hash)
+	}
+
+	ciphertext, err := os.ReadFile(filepath.Join(c.root, filepath.FromSlash(record.Path)))
+	if err != nil {
+		return nil, errors.NewIO("read", record.Path, err)
+	}
+	return decryptBlob(sha256Hash, ciphertext, c.identities)
+}
+
+// decryptBlob decrypts a blob and verifies its plaintext hash.
+func decryptBlob(sha256Hash string, ciphertext []byte, identities []age.Identity) ([]byte, error) {
+	if len(identities) == 0 {
+		return nil, ErrEncrypted
+	}
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt blob %s: %w", sha256Hash, err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt blob %s: %w", sha256Hash, err)
+	}
+	if actual := cas.Hash(plaintext); actual != sha256Hash {
+		return nil, errors.NewValidation("blob", fmt.Sprintf("hash mismatch for %s: got %s", sha256Hash, actual))
+	}
+	return plaintext, nil
+}
+
+// VerifyBlob checks the integrity of a blob. Plaintext blobs are hashed
+// directly. Encrypted blobs are decrypted when identities are available;
+// otherwise the ciphertext is checked against the hash recorded at
+// encryption time, so integrity can be verified without keys.
+func (c *Capsule) VerifyBlob(sha256Hash string) error {
+	record, ok := c.Manifest.Blobs.BySHA256[sha256Hash]
+	if !ok || record.Encryption == nil || c.HasIdentities() {
+		data, err := c.ReadBlob(sha256Hash)
+		if err != nil {
+			return err
+		}
+		if cas.Hash(data) != sha256Hash {
+			return errors.NewValidation("blob", "hash mismatch")
+		}
+		return nil
+	}
+
+	ciphertext, err := os.ReadFile(filepath.Join(c.root, filepath.FromSlash(record.Path)))
+	if err != nil {
+		return errors.NewIO("read", record.Path, err)
+	}
+	if cas.Hash(ciphertext) != record.Encryption.CiphertextSHA256 {
+		return errors.NewValidation("blob", "ciphertext hash mismatch")
+	}
+	return nil
+}
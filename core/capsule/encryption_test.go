@@ -0,0 +1,218 @@
+package capsule
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// newTestIdentity generates an identity and returns it with its recipient.
+func newTestIdentity(t *testing.T) ([]age.Identity, string) {
+	t.Helper()
+	identity, recipient, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	ids, err := age.ParseIdentities(strings.NewReader(identity))
+	if err != nil {
+		t.Fatalf("failed to parse identity: %v", err)
+	}
+	return ids, recipient
+}
+
+// TestEncryptPackUnpack tests that encrypted blobs round-trip and that the
+// manifest stays readable without keys.
+func TestEncryptPackUnpack(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+	ids, recipient := newTestIdentity(t)
+
+	if err := c.Encrypt([]string{recipient}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	record := c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256]
+	if record.Encryption == nil || !strings.HasPrefix(record.Path, "blobs/age/") {
+		t.Fatalf("expected encrypted blob record, got %+v", record)
+	}
+	if _, err := os.Stat(filepath.Join(c.GetRoot(), "blobs", "sha256", a.PrimaryBlobSHA256[:2], a.PrimaryBlobSHA256)); !os.IsNotExist(err) {
+		t.Error("expected plaintext blob to be removed")
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "enc.capsule.tar.xz")
+	if err := c.Pack(archivePath); err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+
+	// Without keys: manifest readable, content protected, ciphertext verifiable
+	locked, err := Unpack(archivePath, t.TempDir())
+	if err != nil {
+		t.Fatalf("Unpack failed: %v", err)
+	}
+	if !locked.IsEncrypted() || locked.Manifest.Artifacts[a.ID] == nil {
+		t.Fatal("expected readable manifest of encrypted capsule")
+	}
+	if _, err := locked.ReadBlob(a.PrimaryBlobSHA256); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("expected ErrEncrypted, got %v", err)
+	}
+	if err := locked.VerifyBlob(a.PrimaryBlobSHA256); err != nil {
+		t.Errorf("VerifyBlob without keys failed: %v", err)
+	}
+
+	// With keys: transparent decryption
+	locked.SetIdentities(ids)
+	data, err := locked.ReadBlob(a.PrimaryBlobSHA256)
+	if err != nil {
+		t.Fatalf("ReadBlob failed: %v", err)
+	}
+	if string(data) != "first artifact content" {
+		t.Errorf("unexpected plaintext %q", data)
+	}
+}
+
+// TestVerifyBlobDetectsTamperedCiphertext tests keyless integrity checks.
+func TestVerifyBlobDetectsTamperedCiphertext(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+	_, recipient := newTestIdentity(t)
+	if err := c.Encrypt([]string{recipient}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	path := filepath.Join(c.GetRoot(), c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256].Path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read ciphertext: %v", err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write ciphertext: %v", err)
+	}
+
+	if err := c.VerifyBlob(a.PrimaryBlobSHA256); err == nil {
+		t.Error("expected ciphertext hash mismatch")
+	}
+}
+
+// TestEncryptedCapsuleWrongIdentity tests that a non-recipient cannot decrypt.
+func TestEncryptedCapsuleWrongIdentity(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+	_, recipient := newTestIdentity(t)
+	other, _ := newTestIdentity(t)
+	if err := c.Encrypt([]string{recipient}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	c.SetIdentities(other)
+	if _, err := c.ReadBlob(a.PrimaryBlobSHA256); err == nil {
+		t.Error("expected decryption to fail with wrong identity")
+	}
+}
+
+// TestPackEncryptsNewBlobs tests that blobs added after encryption are
+// encrypted before packing.
+func TestPackEncryptsNewBlobs(t *testing.T) {
+	c, _, _ := newArchiveTestCapsule(t)
+	ids, recipient := newTestIdentity(t)
+	if err := c.Encrypt([]string{recipient}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	record, err := c.StoreBlob([]byte("added later"), "text/plain")
+	if err != nil {
+		t.Fatalf("StoreBlob failed: %v", err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "enc.capsule.zip")
+	if err := c.PackWithOptions(archivePath, &PackOptions{Compression: CompressionZip}); err != nil {
+		t.Fatalf("PackWithOptions failed: %v", err)
+	}
+	if c.Manifest.Blobs.BySHA256[record.SHA256].Encryption == nil {
+		t.Fatal("expected new blob to be encrypted")
+	}
+
+	r, err := OpenArchive(archivePath)
+	if err != nil {
+		t.Fatalf("OpenArchive failed: %v", err)
+	}
+	defer r.Close()
+
+	if err := r.VerifyBlob(record.SHA256); err != nil {
+		t.Errorf("VerifyBlob failed: %v", err)
+	}
+	if _, err := r.ReadBlob(record.SHA256); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("expected ErrEncrypted, got %v", err)
+	}
+	r.SetIdentities(ids)
+	data, err := r.ReadBlob(record.SHA256)
+	if err != nil || string(data) != "added later" {
+		t.Errorf("ReadBlob = %q, %v", data, err)
+	}
+}
+
+// TestDecryptRestoresPlaintext tests removing encryption from a capsule.
+func TestDecryptRestoresPlaintext(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+	ids, recipient := newTestIdentity(t)
+	if err := c.Encrypt([]string{recipient}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	if err := c.Decrypt(); err == nil {
+		t.Fatal("expected Decrypt to fail without identities")
+	}
+
+	c.SetIdentities(ids)
+	if err := c.Decrypt(); err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if c.IsEncrypted() {
+		t.Error("expected encryption metadata to be removed")
+	}
+	record := c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256]
+	if record.Encryption != nil || !strings.HasPrefix(record.Path, "blobs/sha256/") {
+		t.Errorf("unexpected blob record %+v", record)
+	}
+	if _, err := c.GetStore().Retrieve(a.PrimaryBlobSHA256); err != nil {
+		t.Errorf("expected plaintext blob in store: %v", err)
+	}
+}
+
+// TestEncryptRejectsInvalidRecipient tests recipient validation.
+func TestEncryptRejectsInvalidRecipient(t *testing.T) {
+	c, _, _ := newArchiveTestCapsule(t)
+	if err := c.Encrypt(nil); err == nil {
+		t.Error("expected error without recipients")
+	}
+	if err := c.Encrypt([]string{"not-a-key"}); err == nil {
+		t.Error("expected error for invalid recipient")
+	}
+	if c.IsEncrypted() {
+		t.Error("capsule should not be marked encrypted after failure")
+	}
+}
+
+// TestLoadIdentities tests reading an identity file.
+func TestLoadIdentities(t *testing.T) {
+	identity, _, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(path, []byte("# comment\n"+identity+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write identity: %v", err)
+	}
+
+	ids, err := LoadIdentities(path)
+	if err != nil {
+		t.Fatalf("LoadIdentities failed: %v", err)
+	}
+	if len(ids) != 1 {
+		t.Errorf("expected 1 identity, got %d", len(ids))
+	}
+
+	if _, err := LoadIdentities(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
 	"github.com/FocuswithJustin/JuniperBible/core/errors"
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
@@ -57,7 +59,7 @@ func (c *Capsule) Export(artifactID string, mode ExportMode, destPath string) er
 // exportIdentity exports an artifact's original bytes verbatim.
 func (c *Capsule) exportIdentity(artifact *Artifact, destPath string) error {
 	// Retrieve the blob using the primary SHA-256 hash
-	data, err := c.store.Retrieve(artifact.PrimaryBlobSHA256)
+	data, err := c.ReadBlob(artifact.PrimaryBlobSHA256)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve blob: %w", err)
 	}
@@ -85,7 +87,7 @@ func (c *Capsule) ExportToBytes(artifactID string, mode ExportMode) ([]byte, err
 
 	switch mode {
 	case ExportModeIdentity:
-		return c.store.Retrieve(artifact.PrimaryBlobSHA256)
+		return c.ReadBlob(artifact.PrimaryBlobSHA256)
 	case ExportModeDerived:
 		return nil, errors.NewUnsupported("export mode", "DERIVED export mode not yet implemented")
 	default:
@@ -106,6 +108,16 @@ type DerivedExportOptions struct {
 
 	// TargetPlugin overrides automatic target plugin detection.
 	TargetPlugin *plugins.Plugin
+
+	// ExtractOptions and EmitOptions are the options of the extract-ir and
+	// emit-native calls. They are validated against the options the
+	// plugins declare; values may be typed or strings.
+	ExtractOptions map[string]interface{}
+	EmitOptions    map[string]interface{}
+
+	// RecordProvenance stores the derived output, the IR, an export record
+	// and an in-toto provenance statement in the capsule.
+	RecordProvenance bool
 }
 
 // DerivedExportResult contains the results of a derived export.
@@ -121,11 +133,36 @@ type DerivedExportResult struct {
 
 	// IRBlobSHA256 is the hash of the intermediate IR (if preserved).
 	IRBlobSHA256 string
+
+	// OutputSHA256 is the hash of the derived output.
+	OutputSHA256 string
+
+	// SourcePlugin and TargetPlugin are the plugins that performed the conversion.
+	SourcePlugin *plugins.Plugin
+	TargetPlugin *plugins.Plugin
+
+	// SourceBinarySHA256 and TargetBinarySHA256 are the hashes of the
+	// external plugin executables that ran, empty for embedded plugins.
+	SourceBinarySHA256 string
+	TargetBinarySHA256 string
+
+	// ExtractOptions and EmitOptions are the options the plugins ran
+	// with, including their defaults.
+	ExtractOptions plugins.Options
+	EmitOptions    plugins.Options
+
+	// ExportID is the export record created when RecordProvenance is set.
+	ExportID string
+
+	// Provenance is the provenance record created when RecordProvenance is set.
+	Provenance *ProvenanceRecord
 }
 
 // ExportDerived exports an artifact to a different format via the IR.
 // The conversion flow is: Source Format -> extract-ir -> IR -> emit-native -> Target Format.
 func (c *Capsule) ExportDerived(artifactID string, opts DerivedExportOptions, destPath string) (*DerivedExportResult, error) {
+	startedAt := time.Now()
+
 	// Validate options
 	if opts.PluginLoader == nil && (opts.SourcePlugin == nil || opts.TargetPlugin == nil) {
 		return nil, errors.NewValidation("DerivedExportOptions", "requires PluginLoader or both SourcePlugin and TargetPlugin")
@@ -154,7 +191,7 @@ func (c *Capsule) ExportDerived(artifactID string, opts DerivedExportOptions, de
 	}
 
 	sourcePath := filepath.Join(tempDir, "source")
-	sourceData, err := c.store.Retrieve(artifact.PrimaryBlobSHA256)
+	sourceData, err := c.ReadBlob(artifact.PrimaryBlobSHA256)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve source blob: %w", err)
 	}
@@ -180,13 +217,22 @@ func (c *Capsule) ExportDerived(artifactID string, opts DerivedExportOptions, de
 		}
 	}
 
+	extractOpts, err := resolvePluginOptions(sourcePlugin, "extract-ir", opts.ExtractOptions)
+	if err != nil {
+		return nil, err
+	}
+	emitOpts, err := resolvePluginOptions(targetPlugin, "emit-native", opts.EmitOptions)
+	if err != nil {
+		return nil, err
+	}
+
 	// Step 1: Extract IR from source
 	irDir := filepath.Join(tempDir, "ir")
 	if err := osMkdirAllExport(irDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create IR dir: %w", err)
 	}
 
-	extractResult, extractLoss, err := extractIRFromPlugin(sourcePlugin, sourcePath, irDir)
+	extractResult, extractLoss, err := extractIRFromPlugin(sourcePlugin, sourcePath, irDir, extractOpts)
 	if err != nil {
 		return nil, fmt.Errorf("extract-ir failed: %w", err)
 	}
@@ -197,7 +243,7 @@ func (c *Capsule) ExportDerived(artifactID string, opts DerivedExportOptions, de
 		return nil, fmt.Errorf("failed to create output dir: %w", err)
 	}
 
-	emitResult, emitLoss, err := emitNativeFromPlugin(targetPlugin, extractResult.IRPath, outputDir)
+	emitResult, emitLoss, err := emitNativeFromPlugin(targetPlugin, extractResult.IRPath, outputDir, emitOpts)
 	if err != nil {
 		return nil, fmt.Errorf("emit-native failed: %w", err)
 	}
@@ -226,11 +272,94 @@ func (c *Capsule) ExportDerived(artifactID string, opts DerivedExportOptions, de
 
 	combinedClass := combineLossClasses(lossReports)
 
-	return &DerivedExportResult{
-		OutputPath:        destPath,
-		LossReports:       lossReports,
-		CombinedLossClass: combinedClass,
-	}, nil
+	result := &DerivedExportResult{
+		OutputPath:         destPath,
+		LossReports:        lossReports,
+		CombinedLossClass:  combinedClass,
+		OutputSHA256:       cas.Hash(outputData),
+		SourcePlugin:       sourcePlugin,
+		TargetPlugin:       targetPlugin,
+		SourceBinarySHA256: extractResult.BinarySHA256,
+		TargetBinarySHA256: emitResult.BinarySHA256,
+		ExtractOptions:     extractOpts,
+		EmitOptions:        emitOpts,
+	}
+
+	irData, irErr := osReadFileExport(extractResult.IRPath)
+	if irErr == nil {
+		result.IRBlobSHA256 = cas.Hash(irData)
+	}
+
+	if opts.RecordProvenance {
+		if irErr != nil {
+			return nil, fmt.Errorf("failed to read IR for provenance: %w", irErr)
+		}
+		if err := c.recordDerivedExport(artifact, opts.TargetFormat, outputData, irData, result, startedAt); err != nil {
+			return nil, fmt.Errorf("failed to record provenance: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// recordDerivedExport stores a derived output and the IR it was emitted
+// from, adds a DERIVED export record and attaches a provenance statement.
+func (c *Capsule) recordDerivedExport(artifact *Artifact, targetFormat string, outputData, irData []byte, result *DerivedExportResult, startedAt time.Time) error {
+	if _, err := c.StoreBlob(irData, "application/json"); err != nil {
+		return fmt.Errorf("failed to store IR: %w", err)
+	}
+	if _, err := c.StoreBlob(outputData, ""); err != nil {
+		return fmt.Errorf("failed to store output: %w", err)
+	}
+
+	// The output hash keeps exports of one artifact to one format apart,
+	// e.g. when they were emitted with different options.
+	export := &Export{
+		ID:               fmt.Sprintf("%s-%s-%s", artifact.ID, targetFormat, result.OutputSHA256[:12]),
+		Mode:             string(ExportModeDerived),
+		ArtifactID:       artifact.ID,
+		ResultBlobSHA256: result.OutputSHA256,
+		Attributes: Attributes{
+			"target_format": targetFormat,
+			"loss_class":    string(result.CombinedLossClass),
+		},
+	}
+
+	stmt, err := NewDerivedProvenance(DerivationInputs{
+		SubjectName:        filepath.Base(result.OutputPath),
+		Output:             outputData,
+		Source:             artifact,
+		IRSHA256:           result.IRBlobSHA256,
+		IRBLAKE3:           cas.Blake3Hash(irData),
+		TargetFormat:       targetFormat,
+		SourcePlugin:       result.SourcePlugin,
+		TargetPlugin:       result.TargetPlugin,
+		SourceBinarySHA256: result.SourceBinarySHA256,
+		TargetBinarySHA256: result.TargetBinarySHA256,
+		ExtractOptions:     result.ExtractOptions,
+		EmitOptions:        result.EmitOptions,
+		LossReports:        result.LossReports,
+		InvocationID:       export.ID,
+		StartedAt:          startedAt,
+		FinishedAt:         time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	record, err := c.AddProvenance(stmt, export.ID)
+	if err != nil {
+		return err
+	}
+
+	if c.Manifest.Exports == nil {
+		c.Manifest.Exports = make(map[string]*Export)
+	}
+	c.Manifest.Exports[export.ID] = export
+
+	result.ExportID = export.ID
+	result.Provenance = record
+	return nil
 }
 
 // findPluginForFormat finds a plugin that supports the given format.
@@ -255,9 +384,21 @@ func findPluginForFormat(loader *plugins.Loader, format string) (*plugins.Plugin
 	return nil, errors.NewNotFound("plugin", format)
 }
 
+// resolvePluginOptions validates the options of a call to command against
+// the options the plugin declares.
+func resolvePluginOptions(plugin *plugins.Plugin, command string, values map[string]interface{}) (plugins.Options, error) {
+	if plugin.Manifest == nil {
+		if len(values) > 0 {
+			return nil, errors.NewValidation("options", "plugin has no manifest declaring options")
+		}
+		return nil, nil
+	}
+	return plugin.Manifest.ResolveOptions(command, values)
+}
+
 // extractIRFromPlugin calls extract-ir on a plugin and returns the result.
-func extractIRFromPlugin(plugin *plugins.Plugin, sourcePath, outputDir string) (*plugins.ExtractIRResult, *ir.LossReport, error) {
-	req := plugins.NewExtractIRRequest(sourcePath, outputDir)
+func extractIRFromPlugin(plugin *plugins.Plugin, sourcePath, outputDir string, opts plugins.Options) (*plugins.ExtractIRResult, *ir.LossReport, error) {
+	req := plugins.NewExtractIRRequest(sourcePath, outputDir).WithOptions(opts)
 	resp, err := pluginsExecutePlugin(plugin, req)
 	if err != nil {
 		return nil, nil, err
@@ -277,8 +418,8 @@ func extractIRFromPlugin(plugin *plugins.Plugin, sourcePath, outputDir string) (
 }
 
 // emitNativeFromPlugin calls emit-native on a plugin and returns the result.
-func emitNativeFromPlugin(plugin *plugins.Plugin, irPath, outputDir string) (*plugins.EmitNativeResult, *ir.LossReport, error) {
-	req := plugins.NewEmitNativeRequest(irPath, outputDir)
+func emitNativeFromPlugin(plugin *plugins.Plugin, irPath, outputDir string, opts plugins.Options) (*plugins.EmitNativeResult, *ir.LossReport, error) {
+	req := plugins.NewEmitNativeRequest(irPath, outputDir).WithOptions(opts)
 	resp, err := pluginsExecutePlugin(plugin, req)
 	if err != nil {
 		return nil, nil, err
@@ -297,6 +438,11 @@ func emitNativeFromPlugin(plugin *plugins.Plugin, irPath, outputDir string) (*pl
 	return result, lossReport, nil
 }
 
+// LossReportFromIPC converts a loss report returned by a plugin to an IR loss report.
+func LossReportFromIPC(ipc *plugins.LossReportIPC) *ir.LossReport {
+	return convertIPCLossReport(ipc)
+}
+
 // convertIPCLossReport converts an IPC loss report to an IR loss report.
 func convertIPCLossReport(ipc *plugins.LossReportIPC) *ir.LossReport {
 	report := &ir.LossReport{
@@ -589,7 +589,7 @@ func TestExtractIRFromPluginError(t *testing.T) {
 		t.Fatalf("failed to create output dir: %v", err)
 	}
 
-	_, _, err = extractIRFromPlugin(plugin, sourcePath, outputDir)
+	_, _, err = extractIRFromPlugin(plugin, sourcePath, outputDir, nil)
 	if err == nil {
 		t.Error("extractIRFromPlugin() expected error for invalid plugin, got nil")
 	}
@@ -627,7 +627,7 @@ func TestEmitNativeFromPluginError(t *testing.T) {
 		t.Fatalf("failed to create output dir: %v", err)
 	}
 
-	_, _, err = emitNativeFromPlugin(plugin, irPath, outputDir)
+	_, _, err = emitNativeFromPlugin(plugin, irPath, outputDir, nil)
 	if err == nil {
 		t.Error("emitNativeFromPlugin() expected error for invalid plugin, got nil")
 	}
@@ -764,7 +764,7 @@ func TestExtractIRFromPluginExecuteError(t *testing.T) {
 	}
 	defer func() { pluginsExecutePlugin = origExecute }()
 
-	_, _, err := extractIRFromPlugin(&plugins.Plugin{}, "/source", "/output")
+	_, _, err := extractIRFromPlugin(&plugins.Plugin{}, "/source", "/output", nil)
 	if err == nil {
 		t.Error("expected error for plugin execution failure")
 	}
@@ -787,7 +787,7 @@ func TestExtractIRFromPluginParseError(t *testing.T) {
 		pluginsParseExtractIRResult = origParse
 	}()
 
-	_, _, err := extractIRFromPlugin(&plugins.Plugin{}, "/source", "/output")
+	_, _, err := extractIRFromPlugin(&plugins.Plugin{}, "/source", "/output", nil)
 	if err == nil {
 		t.Error("expected error for parse failure")
 	}
@@ -819,7 +819,7 @@ func TestExtractIRFromPluginSuccessWithLoss(t *testing.T) {
 		pluginsParseExtractIRResult = origParse
 	}()
 
-	result, loss, err := extractIRFromPlugin(&plugins.Plugin{}, "/source", "/output")
+	result, loss, err := extractIRFromPlugin(&plugins.Plugin{}, "/source", "/output", nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -843,7 +843,7 @@ func TestEmitNativeFromPluginExecuteError(t *testing.T) {
 	}
 	defer func() { pluginsExecutePlugin = origExecute }()
 
-	_, _, err := emitNativeFromPlugin(&plugins.Plugin{}, "/ir", "/output")
+	_, _, err := emitNativeFromPlugin(&plugins.Plugin{}, "/ir", "/output", nil)
 	if err == nil {
 		t.Error("expected error for plugin execution failure")
 	}
@@ -866,7 +866,7 @@ func TestEmitNativeFromPluginParseError(t *testing.T) {
 		pluginsParseEmitNativeResult = origParse
 	}()
 
-	_, _, err := emitNativeFromPlugin(&plugins.Plugin{}, "/ir", "/output")
+	_, _, err := emitNativeFromPlugin(&plugins.Plugin{}, "/ir", "/output", nil)
 	if err == nil {
 		t.Error("expected error for parse failure")
 	}
@@ -898,7 +898,7 @@ func TestEmitNativeFromPluginSuccessWithLoss(t *testing.T) {
 		pluginsParseEmitNativeResult = origParse
 	}()
 
-	result, loss, err := emitNativeFromPlugin(&plugins.Plugin{}, "/ir", "/output")
+	result, loss, err := emitNativeFromPlugin(&plugins.Plugin{}, "/ir", "/output", nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -0,0 +1,622 @@
+package capsule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// Fsck issue kinds.
+const (
+	// FsckMissingBlob is an indexed blob whose bytes are not stored.
+	FsckMissingBlob = "missing_blob"
+
+	// FsckCorruptBlob is a stored blob whose bytes do not match its hash.
+	FsckCorruptBlob = "corrupt_blob"
+
+	// FsckRecordMismatch is a blob record whose SHA-256, path, size or
+	// BLAKE3 disagrees with the index key or the stored bytes.
+	FsckRecordMismatch = "record_mismatch"
+
+	// FsckUnindexedBlob is a blob referenced by the manifest (artifact,
+	// run, IR, export or provenance) that has no blob record.
+	FsckUnindexedBlob = "unindexed_blob"
+
+	// FsckOrphanBlob is a stored blob that is not in the blob index.
+	FsckOrphanBlob = "orphan_blob"
+
+	// FsckMissingPointer is an indexed BLAKE3 hash without a pointer file.
+	FsckMissingPointer = "missing_blake3_pointer"
+
+	// FsckBadPointer is a BLAKE3 pointer file that is unreadable or does
+	// not point at the blob indexed under that BLAKE3 hash.
+	FsckBadPointer = "bad_blake3_pointer"
+
+	// FsckArtifactMismatch is an artifact whose recorded hashes disagree
+	// with its primary blob. It is reported but never repaired.
+	FsckArtifactMismatch = "artifact_hash_mismatch"
+)
+
+// FsckIssue is a single inconsistency between the manifest, the blob index
+// and the stored bytes.
+type FsckIssue struct {
+	Kind   string `json:"kind"`
+	SHA256 string `json:"sha256,omitempty"`
+	BLAKE3 string `json:"blake3,omitempty"`
+	Path   string `json:"path,omitempty"`
+
+	// Refs lists the manifest entries referring to the blob
+	// (e.g. "artifact:main", "run:run-1").
+	Refs []string `json:"refs,omitempty"`
+
+	Detail string `json:"detail"`
+
+	// Repaired is true when the issue was fixed; Source names the blob
+	// source the bytes were recovered from, if any.
+	Repaired bool   `json:"repaired,omitempty"`
+	Source   string `json:"source,omitempty"`
+}
+
+// FsckReport is the machine-readable result of Fsck.
+type FsckReport struct {
+	CheckedAt    string       `json:"checked_at"`
+	BlobsChecked int          `json:"blobs_checked"`
+	Repair       bool         `json:"repair"`
+	Issues       []*FsckIssue `json:"issues"`
+}
+
+// Unrepaired returns the number of issues that remain.
+func (r *FsckReport) Unrepaired() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// OK returns true if no unrepaired issues remain.
+func (r *FsckReport) OK() bool {
+	return r.Unrepaired() == 0
+}
+
+// FsckOptions configures Fsck.
+type FsckOptions struct {
+	// Repair fixes issues in place: blobs are recovered from Sources, and
+	// the blob index and BLAKE3 pointers are rebuilt from verified bytes.
+	Repair bool
+
+	// Sources are searched, in order, for missing or corrupt blobs.
+	Sources []BlobSource
+}
+
+// Fsck checks every blob record against the stored bytes, every manifest
+// reference against the blob index, and every stored blob and BLAKE3
+// pointer against the index. With opts.Repair set, fixable issues are
+// repaired in the unpacked capsule; call SaveManifest and Pack afterwards
+// to write the repaired capsule.
+func (c *Capsule) Fsck(opts FsckOptions) (*FsckReport, error) {
+	// A manifest without blobs.by_sha256 has its index rebuilt from the store
+	if c.Manifest.Blobs.BySHA256 == nil {
+		c.Manifest.Blobs.BySHA256 = make(map[string]*BlobRecord)
+	}
+	f := &fsck{
+		c:      c,
+		opts:   opts,
+		refs:   c.Manifest.blobReferences(),
+		report: &FsckReport{CheckedAt: time.Now().UTC().Format(time.RFC3339), Repair: opts.Repair, Issues: []*FsckIssue{}},
+	}
+
+	hashes := make([]string, 0, len(c.Manifest.Blobs.BySHA256))
+	for hash := range c.Manifest.Blobs.BySHA256 {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		if err := f.checkRecord(hash, c.Manifest.Blobs.BySHA256[hash]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.checkReferences(); err != nil {
+		return nil, err
+	}
+	if err := f.checkStoredBlobs(); err != nil {
+		return nil, err
+	}
+	if err := f.checkPointers(); err != nil {
+		return nil, err
+	}
+	f.checkArtifacts()
+
+	return f.report, nil
+}
+
+// fsck holds the state of a single Fsck run.
+type fsck struct {
+	c      *Capsule
+	opts   FsckOptions
+	refs   map[string][]string
+	report *FsckReport
+}
+
+// add records an issue and returns it so the caller can mark it repaired.
+func (f *fsck) add(issue *FsckIssue) *FsckIssue {
+	if issue.SHA256 != "" {
+		issue.Refs = f.refs[issue.SHA256]
+	}
+	f.report.Issues = append(f.report.Issues, issue)
+	return issue
+}
+
+// blobReferences maps each blob hash referenced by the manifest to the
+// entries referring to it.
+func (m *Manifest) blobReferences() map[string][]string {
+	refs := make(map[string][]string)
+	add := func(hash, ref string) {
+		if hash != "" {
+			refs[hash] = append(refs[hash], ref)
+		}
+	}
+	for id, a := range m.Artifacts {
+		add(a.PrimaryBlobSHA256, "artifact:"+id)
+	}
+	for id, run := range m.Runs {
+		if run.Command != nil {
+			add(run.Command.RequestBlobSHA256, "run:"+id)
+		}
+		if run.Outputs != nil {
+			add(run.Outputs.TranscriptBlobSHA256, "run:"+id)
+			add(run.Outputs.StdoutBlobSHA256, "run:"+id)
+			add(run.Outputs.StderrBlobSHA256, "run:"+id)
+			add(run.Outputs.EngineTranscriptBlobSHA256, "run:"+id)
+			for _, hash := range run.Outputs.Files {
+				add(hash, "run:"+id)
+			}
+		}
+	}
+	for id, rec := range m.IRExtractions {
+		add(rec.IRBlobSHA256, "ir:"+id)
+	}
+	for id, export := range m.Exports {
+		add(export.ResultBlobSHA256, "export:"+id)
+	}
+	for id, rec := range m.Provenance {
+		add(rec.StatementBlobSHA256, "provenance:"+id)
+	}
+	for _, list := range refs {
+		sort.Strings(list)
+	}
+	return refs
+}
+
+// plainBlobPath returns the capsule-relative path of a plaintext blob.
+func plainBlobPath(sha256Hash string) string {
+	return fmt.Sprintf("blobs/sha256/%s/%s", safePrefix(sha256Hash), sha256Hash)
+}
+
+// abs returns the absolute path of a capsule-relative path.
+func (f *fsck) abs(rel string) string {
+	return filepath.Join(f.c.root, filepath.FromSlash(rel))
+}
+
+// checkRecord checks one blob record against the stored bytes.
+func (f *fsck) checkRecord(hash string, record *BlobRecord) error {
+	f.report.BlobsChecked++
+
+	if record.SHA256 != hash {
+		issue := f.add(&FsckIssue{Kind: FsckRecordMismatch, SHA256: hash, Detail: fmt.Sprintf("record sha256 is %q", record.SHA256)})
+		if f.opts.Repair {
+			record.SHA256 = hash
+			issue.Repaired = true
+		}
+	}
+
+	expected := plainBlobPath(hash)
+	if record.Encryption != nil {
+		expected = encryptedBlobPath(hash)
+	}
+	if record.Path != expected {
+		issue := f.add(&FsckIssue{Kind: FsckRecordMismatch, SHA256: hash, Path: record.Path, Detail: fmt.Sprintf("record path should be %s", expected)})
+		if f.opts.Repair {
+			record.Path = expected
+			issue.Repaired = true
+		}
+	}
+
+	var data []byte
+	var err error
+	if record.Encryption != nil {
+		data, err = f.checkEncrypted(hash, record)
+	} else {
+		data, err = f.checkPlain(hash, record)
+	}
+	if err != nil || data == nil {
+		return err
+	}
+
+	if int64(len(data)) != record.SizeBytes {
+		issue := f.add(&FsckIssue{Kind: FsckRecordMismatch, SHA256: hash, Detail: fmt.Sprintf("record size %d, stored %d bytes", record.SizeBytes, len(data))})
+		if f.opts.Repair {
+			record.SizeBytes = int64(len(data))
+			issue.Repaired = true
+		}
+	}
+	if b3 := cas.Blake3Hash(data); record.BLAKE3 != "" && record.BLAKE3 != b3 {
+		issue := f.add(&FsckIssue{Kind: FsckRecordMismatch, SHA256: hash, BLAKE3: record.BLAKE3, Detail: fmt.Sprintf("record blake3 should be %s", b3)})
+		if f.opts.Repair {
+			record.BLAKE3 = b3
+			issue.Repaired = true
+		}
+	}
+	return nil
+}
+
+// checkPlain verifies a plaintext blob, recovering it when repairing.
+// It returns the verified bytes, or nil if they are unavailable.
+func (f *fsck) checkPlain(hash string, record *BlobRecord) ([]byte, error) {
+	rel := plainBlobPath(hash)
+	data, err := os.ReadFile(f.abs(rel))
+	var issue *FsckIssue
+	switch {
+	case os.IsNotExist(err):
+		issue = f.add(&FsckIssue{Kind: FsckMissingBlob, SHA256: hash, BLAKE3: record.BLAKE3, Path: rel, Detail: "blob is not stored"})
+	case err != nil:
+		return nil, errors.NewIO("read", rel, err)
+	case cas.Hash(data) != hash:
+		issue = f.add(&FsckIssue{Kind: FsckCorruptBlob, SHA256: hash, BLAKE3: record.BLAKE3, Path: rel, Detail: fmt.Sprintf("stored bytes hash to %s", cas.Hash(data))})
+	default:
+		return data, nil
+	}
+
+	if !f.opts.Repair {
+		return nil, nil
+	}
+	data, source := f.recover(hash, record.BLAKE3)
+	if data == nil {
+		return nil, nil
+	}
+	if err := f.restorePlain(hash, data); err != nil {
+		return nil, err
+	}
+	issue.Repaired, issue.Source = true, source
+	return data, nil
+}
+
+// checkEncrypted verifies an encrypted blob's ciphertext (and plaintext,
+// when identities are set), recovering it when repairing. It returns the
+// verified plaintext, or nil if it is unavailable.
+func (f *fsck) checkEncrypted(hash string, record *BlobRecord) ([]byte, error) {
+	ciphertext, err := os.ReadFile(f.abs(record.Path))
+	var issue *FsckIssue
+	switch {
+	case os.IsNotExist(err):
+		issue = f.add(&FsckIssue{Kind: FsckMissingBlob, SHA256: hash, BLAKE3: record.BLAKE3, Path: record.Path, Detail: "encrypted blob is not stored"})
+	case err != nil:
+		return nil, errors.NewIO("read", record.Path, err)
+	case cas.Hash(ciphertext) != record.Encryption.CiphertextSHA256:
+		issue = f.add(&FsckIssue{Kind: FsckCorruptBlob, SHA256: hash, BLAKE3: record.BLAKE3, Path: record.Path, Detail: "ciphertext hash mismatch"})
+	case !f.c.HasIdentities():
+		return nil, nil
+	default:
+		data, err := decryptBlob(hash, ciphertext, f.c.identities)
+		if err == nil {
+			return data, nil
+		}
+		issue = f.add(&FsckIssue{Kind: FsckCorruptBlob, SHA256: hash, BLAKE3: record.BLAKE3, Path: record.Path, Detail: err.Error()})
+	}
+
+	if !f.opts.Repair {
+		return nil, nil
+	}
+
+	// An identical ciphertext (e.g. from a mirror of this capsule) keeps
+	// the record unchanged and needs no keys.
+	for _, src := range f.opts.Sources {
+		data, err := src.ReadFile(record.Path)
+		if err != nil || cas.Hash(data) != record.Encryption.CiphertextSHA256 {
+			continue
+		}
+		if err := writePreservationFile(f.c.root, record.Path, data); err != nil {
+			return nil, err
+		}
+		issue.Repaired, issue.Source = true, src.Name()
+		return nil, nil
+	}
+
+	// Otherwise re-encrypt recovered plaintext to the capsule recipients.
+	if !f.c.IsEncrypted() {
+		return nil, nil
+	}
+	data, source := f.recover(hash, record.BLAKE3)
+	if data == nil {
+		return nil, nil
+	}
+	if err := f.restoreEncrypted(hash, data); err != nil {
+		return nil, err
+	}
+	issue.Repaired, issue.Source = true, source
+	return data, nil
+}
+
+// recover searches the blob sources for a blob and returns the first copy
+// whose SHA-256 verifies, with the source's name.
+func (f *fsck) recover(sha256Hash, blake3Hash string) ([]byte, string) {
+	for _, src := range f.opts.Sources {
+		data, err := src.FindBlob(sha256Hash, blake3Hash)
+		if err != nil || cas.Hash(data) != sha256Hash {
+			continue
+		}
+		return data, src.Name()
+	}
+	return nil, ""
+}
+
+// restorePlain replaces a missing or corrupt plaintext blob.
+func (f *fsck) restorePlain(hash string, data []byte) error {
+	if err := os.Remove(f.abs(plainBlobPath(hash))); err != nil && !os.IsNotExist(err) {
+		return errors.NewIO("remove", plainBlobPath(hash), err)
+	}
+	if _, err := f.c.store.Store(data); err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", hash, err)
+	}
+	return nil
+}
+
+// restoreEncrypted re-encrypts recovered plaintext to the capsule
+// recipients, updating the blob record.
+func (f *fsck) restoreEncrypted(hash string, data []byte) error {
+	recipients, err := ParseRecipients(f.c.Manifest.Encryption.Recipients)
+	if err != nil {
+		return err
+	}
+	if err := f.restorePlain(hash, data); err != nil {
+		return err
+	}
+	return f.c.encryptBlob(hash, recipients)
+}
+
+// index adds a blob record for verified bytes, encrypting the blob if the
+// capsule is encrypted.
+func (f *fsck) index(data []byte) error {
+	record, err := f.c.StoreBlob(data, "")
+	if err != nil {
+		return err
+	}
+	if !f.c.IsEncrypted() {
+		return nil
+	}
+	recipients, err := ParseRecipients(f.c.Manifest.Encryption.Recipients)
+	if err != nil {
+		return err
+	}
+	return f.c.encryptBlob(record.SHA256, recipients)
+}
+
+// checkReferences reports manifest references to blobs that are not in
+// the blob index, rebuilding their records when repairing.
+func (f *fsck) checkReferences() error {
+	hashes := make([]string, 0, len(f.refs))
+	for hash := range f.refs {
+		if _, ok := f.c.Manifest.Blobs.BySHA256[hash]; !ok {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+
+	for _, hash := range hashes {
+		issue := f.add(&FsckIssue{Kind: FsckUnindexedBlob, SHA256: hash, Detail: "referenced blob has no blob record"})
+		if !f.opts.Repair {
+			continue
+		}
+
+		data, source := f.localBlob(hash), ""
+		if data == nil {
+			if data, source = f.recover(hash, ""); data == nil {
+				continue
+			}
+		}
+		if err := f.index(data); err != nil {
+			return fmt.Errorf("failed to index blob %s: %w", hash, err)
+		}
+		issue.Repaired, issue.Source = true, source
+	}
+	return nil
+}
+
+// localBlob returns the verified plaintext of a blob stored in the capsule
+// but not necessarily indexed, or nil.
+func (f *fsck) localBlob(hash string) []byte {
+	if data, err := os.ReadFile(f.abs(plainBlobPath(hash))); err == nil && cas.Hash(data) == hash {
+		return data
+	}
+	if ciphertext, err := os.ReadFile(f.abs(encryptedBlobPath(hash))); err == nil {
+		if data, err := decryptBlob(hash, ciphertext, f.c.identities); err == nil {
+			return data
+		}
+	}
+	return nil
+}
+
+// checkStoredBlobs reports stored blobs that are not indexed. Intact ones
+// are indexed when repairing; unverifiable plaintext is removed.
+func (f *fsck) checkStoredBlobs() error {
+	stored, err := f.walk("blobs/sha256")
+	if err != nil {
+		return err
+	}
+	for _, rel := range stored {
+		hash := filepath.Base(rel)
+		if _, ok := f.c.Manifest.Blobs.BySHA256[hash]; ok && rel == plainBlobPath(hash) {
+			continue
+		}
+		data, err := os.ReadFile(f.abs(rel))
+		if err != nil {
+			return errors.NewIO("read", rel, err)
+		}
+
+		if actual := cas.Hash(data); actual != hash || rel != plainBlobPath(hash) {
+			issue := f.add(&FsckIssue{Kind: FsckCorruptBlob, Path: rel, Detail: fmt.Sprintf("unindexed file hashes to %s", actual)})
+			if f.opts.Repair {
+				if err := os.Remove(f.abs(rel)); err != nil {
+					return errors.NewIO("remove", rel, err)
+				}
+				issue.Repaired = true
+			}
+			continue
+		}
+
+		issue := f.add(&FsckIssue{Kind: FsckOrphanBlob, SHA256: hash, Path: rel, Detail: "stored blob is not in the blob index"})
+		if f.opts.Repair {
+			if err := f.index(data); err != nil {
+				return fmt.Errorf("failed to index blob %s: %w", hash, err)
+			}
+			issue.Repaired = true
+		}
+	}
+
+	encrypted, err := f.walk("blobs/age")
+	if err != nil {
+		return err
+	}
+	for _, rel := range encrypted {
+		hash := strings.TrimSuffix(filepath.Base(rel), ".age")
+		if record, ok := f.c.Manifest.Blobs.BySHA256[hash]; ok && record.Path == rel {
+			continue
+		}
+		issue := f.add(&FsckIssue{Kind: FsckOrphanBlob, SHA256: hash, Path: rel, Detail: "encrypted blob is not in the blob index"})
+		if !f.opts.Repair || !f.c.IsEncrypted() {
+			continue
+		}
+		if data := f.localBlob(hash); data != nil {
+			if err := f.index(data); err != nil {
+				return fmt.Errorf("failed to index blob %s: %w", hash, err)
+			}
+			issue.Repaired = true
+		}
+	}
+	return nil
+}
+
+// checkPointers reports missing, unreadable and stale BLAKE3 pointers,
+// rebuilding them from the blob index when repairing.
+func (f *fsck) checkPointers() error {
+	byBLAKE3 := make(map[string]string)
+	for hash, record := range f.c.Manifest.Blobs.BySHA256 {
+		if record.BLAKE3 != "" {
+			byBLAKE3[record.BLAKE3] = hash
+		}
+	}
+
+	pointers, err := f.walk("blobs/blake3")
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(pointers))
+	for _, rel := range pointers {
+		b3 := strings.TrimSuffix(filepath.Base(rel), ".json")
+		seen[b3] = true
+
+		var pointer struct {
+			SHA256 string `json:"sha256"`
+		}
+		data, err := os.ReadFile(f.abs(rel))
+		if err != nil {
+			return errors.NewIO("read", rel, err)
+		}
+		hash, indexed := byBLAKE3[b3]
+		if json.Unmarshal(data, &pointer) == nil && indexed && pointer.SHA256 == hash {
+			continue
+		}
+
+		issue := f.add(&FsckIssue{Kind: FsckBadPointer, BLAKE3: b3, Path: rel, Detail: fmt.Sprintf("pointer does not match blob index (points at %q)", pointer.SHA256)})
+		if !f.opts.Repair {
+			continue
+		}
+		if indexed {
+			err = f.c.store.LinkBlake3(b3, hash)
+		} else {
+			err = os.Remove(f.abs(rel))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to repair pointer %s: %w", b3, err)
+		}
+		issue.Repaired = true
+	}
+
+	var missing []string
+	for b3 := range byBLAKE3 {
+		if !seen[b3] {
+			missing = append(missing, b3)
+		}
+	}
+	sort.Strings(missing)
+	for _, b3 := range missing {
+		hash := byBLAKE3[b3]
+		issue := f.add(&FsckIssue{Kind: FsckMissingPointer, SHA256: hash, BLAKE3: b3, Detail: "blake3 pointer file is missing"})
+		if f.opts.Repair {
+			if err := f.c.store.LinkBlake3(b3, hash); err != nil {
+				return fmt.Errorf("failed to rebuild pointer %s: %w", b3, err)
+			}
+			issue.Repaired = true
+		}
+	}
+	return nil
+}
+
+// checkArtifacts reports artifacts whose recorded hashes disagree with
+// their primary blob record.
+func (f *fsck) checkArtifacts() {
+	ids := make([]string, 0, len(f.c.Manifest.Artifacts))
+	for id := range f.c.Manifest.Artifacts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		a := f.c.Manifest.Artifacts[id]
+		if a.Hashes.SHA256 != a.PrimaryBlobSHA256 {
+			f.add(&FsckIssue{Kind: FsckArtifactMismatch, SHA256: a.PrimaryBlobSHA256, Detail: fmt.Sprintf("artifact %s records sha256 %s", id, a.Hashes.SHA256)})
+			continue
+		}
+		record, ok := f.c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256]
+		if ok && a.Hashes.BLAKE3 != "" && record.BLAKE3 != "" && a.Hashes.BLAKE3 != record.BLAKE3 {
+			f.add(&FsckIssue{Kind: FsckArtifactMismatch, SHA256: a.PrimaryBlobSHA256, BLAKE3: a.Hashes.BLAKE3, Detail: fmt.Sprintf("artifact %s blake3 differs from blob record", id)})
+		}
+	}
+}
+
+// walk returns the capsule-relative paths of the files under dir, sorted.
+func (f *fsck) walk(dir string) ([]string, error) {
+	root := f.abs(dir)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
+	}
+	var files []string
+	if err := filepathWalk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepathRel(f.c.root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,232 @@
+package capsule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// issueKinds counts the issues of a report by kind.
+func issueKinds(r *FsckReport) map[string]int {
+	kinds := make(map[string]int)
+	for _, issue := range r.Issues {
+		kinds[issue.Kind]++
+	}
+	return kinds
+}
+
+// TestFsckCleanCapsule tests that an intact capsule has no issues.
+func TestFsckCleanCapsule(t *testing.T) {
+	c := newRevisedTestCapsule(t)
+
+	report, err := c.Fsck(FsckOptions{})
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("expected no issues, got %+v", report.Issues)
+	}
+	if report.BlobsChecked != len(c.Manifest.Blobs.BySHA256) {
+		t.Errorf("BlobsChecked = %d, want %d", report.BlobsChecked, len(c.Manifest.Blobs.BySHA256))
+	}
+}
+
+// TestFsckRepairFromArchive tests recovering corrupt and missing blobs
+// from another copy of the capsule.
+func TestFsckRepairFromArchive(t *testing.T) {
+	c, a, b := newArchiveTestCapsule(t)
+	mirror := filepath.Join(t.TempDir(), "mirror.capsule.zip")
+	if err := c.PackWithOptions(mirror, &PackOptions{Compression: CompressionZip}); err != nil {
+		t.Fatalf("PackWithOptions failed: %v", err)
+	}
+
+	pathA := filepath.Join(c.GetRoot(), filepath.FromSlash(plainBlobPath(a.PrimaryBlobSHA256)))
+	if err := os.WriteFile(pathA, []byte("bit rot"), 0644); err != nil {
+		t.Fatalf("failed to corrupt blob: %v", err)
+	}
+	if err := os.Remove(filepath.Join(c.GetRoot(), filepath.FromSlash(plainBlobPath(b.PrimaryBlobSHA256)))); err != nil {
+		t.Fatalf("failed to remove blob: %v", err)
+	}
+
+	report, err := c.Fsck(FsckOptions{})
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	kinds := issueKinds(report)
+	if kinds[FsckCorruptBlob] != 1 || kinds[FsckMissingBlob] != 1 || report.OK() {
+		t.Fatalf("unexpected report: %+v", report.Issues)
+	}
+	for _, issue := range report.Issues {
+		if len(issue.Refs) != 1 {
+			t.Errorf("expected artifact reference for %s, got %v", issue.SHA256, issue.Refs)
+		}
+	}
+
+	sources, err := OpenBlobSources([]string{mirror})
+	if err != nil {
+		t.Fatalf("OpenBlobSources failed: %v", err)
+	}
+	defer sources[0].Close()
+
+	report, err = c.Fsck(FsckOptions{Repair: true, Sources: sources})
+	if err != nil {
+		t.Fatalf("Fsck repair failed: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("expected all issues repaired: %+v", report.Issues)
+	}
+	if report.Issues[0].Source != mirror {
+		t.Errorf("Source = %q, want %q", report.Issues[0].Source, mirror)
+	}
+	data, err := c.ReadBlob(a.PrimaryBlobSHA256)
+	if err != nil || string(data) != "first artifact content" {
+		t.Errorf("ReadBlob = %q, %v", data, err)
+	}
+}
+
+// TestFsckRebuildsIndexAndPointers tests rebuilding a lost blob index and
+// BLAKE3 pointers from the stored bytes.
+func TestFsckRebuildsIndexAndPointers(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+	want := *c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256]
+
+	c.Manifest.Blobs.BySHA256 = make(map[string]*BlobRecord)
+	if err := os.RemoveAll(filepath.Join(c.GetRoot(), "blobs", "blake3")); err != nil {
+		t.Fatalf("failed to remove pointers: %v", err)
+	}
+
+	report, err := c.Fsck(FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	if kinds := issueKinds(report); kinds[FsckUnindexedBlob] != 2 || !report.OK() {
+		t.Fatalf("unexpected report: %+v", report.Issues)
+	}
+
+	got := c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256]
+	if got == nil || got.BLAKE3 != want.BLAKE3 || got.SizeBytes != want.SizeBytes || got.Path != want.Path {
+		t.Errorf("rebuilt record = %+v, want %+v", got, want)
+	}
+	if _, err := c.GetStore().LookupBlake3(want.BLAKE3); err != nil {
+		t.Errorf("expected rebuilt BLAKE3 pointer: %v", err)
+	}
+
+	report, err = c.Fsck(FsckOptions{})
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("expected clean capsule after repair, got %+v", report.Issues)
+	}
+}
+
+// TestFsckRebuildsMissingIndex tests a manifest without blobs.by_sha256.
+func TestFsckRebuildsMissingIndex(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+	c.Manifest.Blobs.BySHA256 = nil
+
+	report, err := c.Fsck(FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	if kinds := issueKinds(report); kinds[FsckUnindexedBlob] != 2 || !report.OK() {
+		t.Fatalf("unexpected report: %+v", report.Issues)
+	}
+	if c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256] == nil {
+		t.Error("expected the index to be rebuilt")
+	}
+}
+
+// TestFsckPointersAndRecords tests stale pointers and record mismatches.
+func TestFsckPointersAndRecords(t *testing.T) {
+	c, a, b := newArchiveTestCapsule(t)
+	recordA := c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256]
+	recordB := c.Manifest.Blobs.BySHA256[b.PrimaryBlobSHA256]
+
+	if err := c.GetStore().LinkBlake3(recordA.BLAKE3, b.PrimaryBlobSHA256); err != nil {
+		t.Fatalf("LinkBlake3 failed: %v", err)
+	}
+	if err := os.Remove(filepath.Join(c.GetRoot(), "blobs", "blake3", recordB.BLAKE3[:2], recordB.BLAKE3+".json")); err != nil {
+		t.Fatalf("failed to remove pointer: %v", err)
+	}
+	recordB.SizeBytes = 1
+
+	report, err := c.Fsck(FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	kinds := issueKinds(report)
+	if kinds[FsckBadPointer] != 1 || kinds[FsckMissingPointer] != 1 || kinds[FsckRecordMismatch] != 1 || !report.OK() {
+		t.Fatalf("unexpected report: %+v", report.Issues)
+	}
+	if sha, _ := c.GetStore().LookupBlake3(recordA.BLAKE3); sha != a.PrimaryBlobSHA256 {
+		t.Errorf("pointer not repaired: %s", sha)
+	}
+	if recordB.SizeBytes != int64(len("second artifact content")) {
+		t.Errorf("size not repaired: %d", recordB.SizeBytes)
+	}
+}
+
+// TestFsckEncryptedFromMirrorDirectory tests recovering ciphertext from a
+// directory of mirrors without keys.
+func TestFsckEncryptedFromMirrorDirectory(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+	_, recipient := newTestIdentity(t)
+	if err := c.Encrypt([]string{recipient}); err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	c.SetIdentities(nil)
+
+	mirrors := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(mirrors, "site-b"), 0755); err != nil {
+		t.Fatalf("failed to create mirror dir: %v", err)
+	}
+	if err := c.Pack(filepath.Join(mirrors, "site-b", "copy.capsule.tar.xz")); err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+
+	record := c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256]
+	if err := os.Remove(filepath.Join(c.GetRoot(), filepath.FromSlash(record.Path))); err != nil {
+		t.Fatalf("failed to remove ciphertext: %v", err)
+	}
+
+	sources, err := OpenBlobSources([]string{mirrors})
+	if err != nil {
+		t.Fatalf("OpenBlobSources failed: %v", err)
+	}
+	if len(sources) != 1 {
+		t.Fatalf("expected 1 mirror source, got %d", len(sources))
+	}
+	defer sources[0].Close()
+
+	report, err := c.Fsck(FsckOptions{Repair: true, Sources: sources})
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	if kinds := issueKinds(report); kinds[FsckMissingBlob] != 1 || !report.OK() {
+		t.Fatalf("unexpected report: %+v", report.Issues)
+	}
+	if err := c.VerifyBlob(a.PrimaryBlobSHA256); err != nil {
+		t.Errorf("VerifyBlob failed after repair: %v", err)
+	}
+}
+
+// TestFsckUnrepairable tests that issues without a source stay unrepaired.
+func TestFsckUnrepairable(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+	if err := os.Remove(filepath.Join(c.GetRoot(), filepath.FromSlash(plainBlobPath(a.PrimaryBlobSHA256)))); err != nil {
+		t.Fatalf("failed to remove blob: %v", err)
+	}
+	c.Manifest.Artifacts[a.ID].Hashes.SHA256 = "0000"
+
+	report, err := c.Fsck(FsckOptions{Repair: true})
+	if err != nil {
+		t.Fatalf("Fsck failed: %v", err)
+	}
+	if report.Unrepaired() != 2 {
+		t.Errorf("expected 2 unrepaired issues, got %+v", report.Issues)
+	}
+	if kinds := issueKinds(report); kinds[FsckArtifactMismatch] != 1 {
+		t.Errorf("expected artifact mismatch, got %v", kinds)
+	}
+}
@@ -0,0 +1,105 @@
+package capsule
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/license"
+)
+
+// ReadLicense returns the license of a packed capsule. The manifest's
+// license record is preferred; capsules without one (such as SWORD module
+// capsules) fall back to the license declared in their SWORD conf. If
+// neither is present the license is NOASSERTION.
+func ReadLicense(archivePath string) (*license.Info, error) {
+	r, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var fromManifest, fromConf *license.Info
+	err = r.walkFiles(isLicenseSource, func(name string, data []byte) error {
+		if path.Base(name) == "manifest.json" {
+			var m struct {
+				License *license.Info `json:"license"`
+			}
+			if json.Unmarshal(data, &m) == nil && m.License != nil && fromManifest == nil {
+				fromManifest = m.License
+			}
+			return nil
+		}
+		if fromConf == nil {
+			fromConf = license.FromConf(license.ParseConf(data))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case fromManifest != nil:
+		return fromManifest, nil
+	case fromConf != nil:
+		return fromConf, nil
+	default:
+		return &license.Info{ID: license.NoAssertion}, nil
+	}
+}
+
+// licenseCacheEntry is a license read from a capsule file of a given
+// modification time and size.
+type licenseCacheEntry struct {
+	modTime time.Time
+	size    int64
+	info    *license.Info
+	err     error
+}
+
+// licenseCache holds licenses read by CachedLicense, keyed by path.
+var licenseCache = struct {
+	sync.Mutex
+	entries map[string]licenseCacheEntry
+}{entries: make(map[string]licenseCacheEntry)}
+
+// CachedLicense is ReadLicense for servers that look up the same capsules
+// on every request. The result is reused until the file's modification
+// time or size changes.
+func CachedLicense(archivePath string) (*license.Info, error) {
+	stat, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	licenseCache.Lock()
+	entry, ok := licenseCache.entries[archivePath]
+	licenseCache.Unlock()
+	if ok && entry.modTime.Equal(stat.ModTime()) && entry.size == stat.Size() {
+		return entry.info, entry.err
+	}
+
+	info, err := ReadLicense(archivePath)
+	licenseCache.Lock()
+	licenseCache.entries[archivePath] = licenseCacheEntry{modTime: stat.ModTime(), size: stat.Size(), info: info, err: err}
+	licenseCache.Unlock()
+	return info, err
+}
+
+// isLicenseSource reports whether an archive entry can declare a license:
+// a manifest or a SWORD conf, at the top level or below one directory.
+func isLicenseSource(name string) bool {
+	parts := strings.Split(name, "/")
+	if len(parts) > 3 {
+		return false
+	}
+	base := parts[len(parts)-1]
+	if base == "manifest.json" {
+		return len(parts) <= 2
+	}
+	return strings.HasSuffix(base, ".conf") && len(parts) >= 2 && parts[len(parts)-2] == "mods.d"
+}
@@ -0,0 +1,107 @@
+package capsule
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/license"
+)
+
+// TestReadLicenseFromManifest tests the license record of a CAS capsule.
+func TestReadLicenseFromManifest(t *testing.T) {
+	c, _, _ := newArchiveTestCapsule(t)
+	c.Manifest.License = &license.Info{ID: license.CopyrightedCrossWire, Raw: "Copyrighted; Permission to distribute granted to CrossWire"}
+	path := filepath.Join(t.TempDir(), "licensed.capsule.zip")
+	if err := c.PackWithOptions(path, &PackOptions{Compression: CompressionZip}); err != nil {
+		t.Fatalf("PackWithOptions failed: %v", err)
+	}
+
+	info, err := ReadLicense(path)
+	if err != nil {
+		t.Fatalf("ReadLicense failed: %v", err)
+	}
+	if info.ID != license.CopyrightedCrossWire {
+		t.Errorf("ID = %q, want %q", info.ID, license.CopyrightedCrossWire)
+	}
+}
+
+// TestReadLicenseFromSWORDConf tests falling back to the conf of a SWORD
+// module capsule, and NOASSERTION for capsules without either.
+func TestReadLicenseFromSWORDConf(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, files map[string]string) string {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("failed to create archive: %v", err)
+		}
+		defer f.Close()
+		gw := gzip.NewWriter(f)
+		defer gw.Close()
+		tw := tar.NewWriter(gw)
+		defer tw.Close()
+		for entry, content := range files {
+			tw.WriteHeader(&tar.Header{Name: entry, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+			tw.Write([]byte(content))
+		}
+		return path
+	}
+
+	sword := write("kjv.capsule.tar.gz", map[string]string{
+		"capsule/manifest.json":   `{"module_type":"bible","id":"KJV"}`,
+		"capsule/mods.d/kjv.conf": "[KJV]\nDistributionLicense=Public Domain\n",
+		"capsule/modules/texts/x": "data",
+	})
+	info, err := ReadLicense(sword)
+	if err != nil {
+		t.Fatalf("ReadLicense failed: %v", err)
+	}
+	if info.ID != license.PublicDomain || info.Raw != "Public Domain" {
+		t.Errorf("unexpected license %+v", info)
+	}
+
+	bare := write("bare.capsule.tar.gz", map[string]string{"capsule/manifest.json": `{}`})
+	if info, err := ReadLicense(bare); err != nil || info.ID != license.NoAssertion {
+		t.Errorf("expected NOASSERTION, got %+v, %v", info, err)
+	}
+}
+
+// TestCachedLicense tests that a license is reused while the file's
+// modification time and size are unchanged, and re-read otherwise.
+func TestCachedLicense(t *testing.T) {
+	c, _, _ := newArchiveTestCapsule(t)
+	c.Manifest.License = &license.Info{ID: license.PublicDomain}
+	path := filepath.Join(t.TempDir(), "cached.capsule.zip")
+	if err := c.PackWithOptions(path, &PackOptions{Compression: CompressionZip}); err != nil {
+		t.Fatalf("PackWithOptions failed: %v", err)
+	}
+	if info, err := CachedLicense(path); err != nil || info.ID != license.PublicDomain {
+		t.Fatalf("expected %s, got %+v, %v", license.PublicDomain, info, err)
+	}
+
+	// Same size and modification time: the archive is not read again.
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if err := os.WriteFile(path, make([]byte, stat.Size()), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := os.Chtimes(path, stat.ModTime(), stat.ModTime()); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if info, err := CachedLicense(path); err != nil || info.ID != license.PublicDomain {
+		t.Errorf("expected cached %s, got %+v, %v", license.PublicDomain, info, err)
+	}
+
+	// A different size is a different file.
+	if err := os.WriteFile(path, []byte("not a capsule"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := CachedLicense(path); err == nil {
+		t.Error("expected an error after the file changed")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/license"
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
 )
 
 // Version is the current capsule format version.
@@ -16,19 +18,26 @@ const Version = "1.0.0"
 // ArtifactKindIR is the artifact kind for IR extractions.
 const ArtifactKindIR = "ir"
 
+// ManifestMIME is the MIME type of manifest JSON stored as a blob.
+const ManifestMIME = "application/vnd.juniper.capsule-manifest+json"
+
 // Manifest represents the capsule manifest (manifest.json).
 type Manifest struct {
-	CapsuleVersion string                `json:"capsule_version"`
-	CreatedAt      string                `json:"created_at"`
-	Tool           ToolInfo              `json:"tool"`
-	Blobs          BlobIndex             `json:"blobs"`
-	Artifacts      map[string]*Artifact  `json:"artifacts"`
-	Runs           map[string]*Run       `json:"runs"`
-	IRExtractions  map[string]*IRRecord  `json:"ir_extractions,omitempty"`
-	RoundtripPlans map[string]*Plan      `json:"roundtrip_plans,omitempty"`
-	SelfChecks     map[string]*SelfCheck `json:"self_checks,omitempty"`
-	Exports        map[string]*Export    `json:"exports,omitempty"`
-	Attributes     Attributes            `json:"attributes,omitempty"`
+	CapsuleVersion string                       `json:"capsule_version"`
+	CreatedAt      string                       `json:"created_at"`
+	Tool           ToolInfo                     `json:"tool"`
+	Blobs          BlobIndex                    `json:"blobs"`
+	Artifacts      map[string]*Artifact         `json:"artifacts"`
+	Runs           map[string]*Run              `json:"runs"`
+	IRExtractions  map[string]*IRRecord         `json:"ir_extractions,omitempty"`
+	RoundtripPlans map[string]*Plan             `json:"roundtrip_plans,omitempty"`
+	SelfChecks     map[string]*SelfCheck        `json:"self_checks,omitempty"`
+	Exports        map[string]*Export           `json:"exports,omitempty"`
+	Provenance     map[string]*ProvenanceRecord `json:"provenance,omitempty"`
+	Encryption     *EncryptionInfo              `json:"encryption,omitempty"`
+	License        *license.Info                `json:"license,omitempty"`
+	Revisions      []*Revision                  `json:"revisions,omitempty"`
+	Attributes     Attributes                   `json:"attributes,omitempty"`
 }
 
 // ToolInfo describes the tool that created this capsule.
@@ -47,12 +56,13 @@ type BlobIndex struct {
 
 // BlobRecord describes a blob in the capsule.
 type BlobRecord struct {
-	SHA256     string     `json:"sha256"`
-	BLAKE3     string     `json:"blake3,omitempty"`
-	SizeBytes  int64      `json:"size_bytes"`
-	Path       string     `json:"path"`
-	MIME       string     `json:"mime,omitempty"`
-	Attributes Attributes `json:"attributes,omitempty"`
+	SHA256     string          `json:"sha256"`
+	BLAKE3     string          `json:"blake3,omitempty"`
+	SizeBytes  int64           `json:"size_bytes"`
+	Path       string          `json:"path"`
+	MIME       string          `json:"mime,omitempty"`
+	Attributes Attributes      `json:"attributes,omitempty"`
+	Encryption *BlobEncryption `json:"encryption,omitempty"`
 }
 
 // Artifact represents a stored artifact.
@@ -90,15 +100,16 @@ type ArtifactComponent struct {
 
 // Run describes a tool run.
 type Run struct {
-	ID         string      `json:"id"`
-	Engine     *Engine     `json:"engine"`
-	Plugin     *PluginInfo `json:"plugin"`
-	Inputs     []RunInput  `json:"inputs"`
-	Command    *Command    `json:"command,omitempty"`
-	Outputs    *RunOutputs `json:"outputs"`
-	Status     string      `json:"status"`
-	Errors     []string    `json:"errors,omitempty"`
-	Attributes Attributes  `json:"attributes,omitempty"`
+	ID         string          `json:"id"`
+	Engine     *Engine         `json:"engine"`
+	Plugin     *PluginInfo     `json:"plugin"`
+	Inputs     []RunInput      `json:"inputs"`
+	Command    *Command        `json:"command,omitempty"`
+	Outputs    *RunOutputs     `json:"outputs"`
+	Status     string          `json:"status"`
+	Errors     []string        `json:"errors,omitempty"`
+	Attributes Attributes      `json:"attributes,omitempty"`
+	Resources  *resource.Usage `json:"resources,omitempty"` // Measured by the engine
 }
 
 // Engine describes the execution environment.
@@ -127,10 +138,17 @@ type EnvConfig struct {
 
 // PluginInfo describes a plugin.
 type PluginInfo struct {
-	PluginID      string     `json:"plugin_id"`
-	PluginVersion string     `json:"plugin_version"`
-	Kind          string     `json:"kind"`
-	Attributes    Attributes `json:"attributes,omitempty"`
+	PluginID      string `json:"plugin_id"`
+	PluginVersion string `json:"plugin_version"`
+	Kind          string `json:"kind"`
+	// BinarySHA256 is the hash of the plugin executable used by the run
+	// (the host binary for embedded plugins).
+	BinarySHA256 string `json:"binary_sha256,omitempty"`
+	// Signature and Publisher record the plugin's signature status when
+	// the run was made (see plugins.SignatureStatus).
+	Signature  string     `json:"signature,omitempty"`
+	Publisher  string     `json:"publisher,omitempty"`
+	Attributes Attributes `json:"attributes,omitempty"`
 }
 
 // RunInput describes an input to a run.
@@ -142,18 +160,24 @@ type RunInput struct {
 
 // Command describes a command invocation.
 type Command struct {
-	Argv       []string   `json:"argv,omitempty"`
-	Profile    string     `json:"profile,omitempty"`
-	Attributes Attributes `json:"attributes,omitempty"`
+	Argv    []string `json:"argv,omitempty"`
+	Profile string   `json:"profile,omitempty"`
+	// RequestBlobSHA256 is the blob holding the canonical tool request; its
+	// hash identifies the request when runs are replayed.
+	RequestBlobSHA256 string     `json:"request_blob_sha256,omitempty"`
+	Attributes        Attributes `json:"attributes,omitempty"`
 }
 
 // RunOutputs describes the outputs of a run.
 type RunOutputs struct {
-	TranscriptBlobSHA256 string              `json:"transcript_blob_sha256"`
-	Artifacts            []RunOutputArtifact `json:"artifacts,omitempty"`
-	StdoutBlobSHA256     string              `json:"stdout_blob_sha256,omitempty"`
-	StderrBlobSHA256     string              `json:"stderr_blob_sha256,omitempty"`
-	Attributes           Attributes          `json:"attributes,omitempty"`
+	TranscriptBlobSHA256       string              `json:"transcript_blob_sha256"`
+	EngineTranscriptBlobSHA256 string              `json:"engine_transcript_blob_sha256,omitempty"` // Engine events, kept out of the tool transcript
+	Artifacts                  []RunOutputArtifact `json:"artifacts,omitempty"`
+	StdoutBlobSHA256           string              `json:"stdout_blob_sha256,omitempty"`
+	StderrBlobSHA256           string              `json:"stderr_blob_sha256,omitempty"`
+	Files                      map[string]string   `json:"files,omitempty"` // Output file name to blob SHA-256
+	ExitCode                   int                 `json:"exit_code,omitempty"`
+	Attributes                 Attributes          `json:"attributes,omitempty"`
 }
 
 // RunOutputArtifact describes an output artifact from a run.
@@ -235,6 +259,56 @@ type Export struct {
 	Attributes       Attributes `json:"attributes,omitempty"`
 }
 
+// ProvenanceRecord indexes a stored provenance statement in the manifest.
+type ProvenanceRecord struct {
+	// ID is the provenance record identifier.
+	ID string `json:"id"`
+
+	// SubjectSHA256 is the hash of the derived artifact the statement describes.
+	SubjectSHA256 string `json:"subject_sha256"`
+
+	// StatementBlobSHA256 is the hash of the stored statement JSON.
+	StatementBlobSHA256 string `json:"statement_blob_sha256"`
+
+	// ExportID links to the export record of the derived artifact, if any.
+	ExportID string `json:"export_id,omitempty"`
+
+	// Attributes contains additional metadata.
+	Attributes Attributes `json:"attributes,omitempty"`
+}
+
+// Revision records one entry in a capsule's append-only revision history.
+// Revision 0 is the original capsule and is implicit; each amendment
+// appends a new Revision linked to the manifest it was derived from.
+type Revision struct {
+	// Number is the revision number, starting at 1 for the first amendment.
+	Number int `json:"number"`
+
+	// ParentManifestSHA256 is the SHA-256 of the parent manifest JSON.
+	ParentManifestSHA256 string `json:"parent_manifest_sha256"`
+
+	// CreatedAt is when the revision was made (RFC 3339).
+	CreatedAt string `json:"created_at"`
+
+	// Producer identifies who or what made the revision.
+	Producer RevisionProducer `json:"producer"`
+
+	// Message is a human description of the revision.
+	Message string `json:"message,omitempty"`
+
+	// Changes lists the artifacts, runs and IR records added, removed or changed.
+	Changes *ManifestDiff `json:"changes,omitempty"`
+
+	// Attributes contains additional metadata.
+	Attributes Attributes `json:"attributes,omitempty"`
+}
+
+// RevisionProducer identifies the person and tool that produced a revision.
+type RevisionProducer struct {
+	Author string   `json:"author,omitempty"`
+	Tool   ToolInfo `json:"tool"`
+}
+
 // Attributes is a map of arbitrary key-value pairs.
 type Attributes map[string]interface{}
 
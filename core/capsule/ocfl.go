@@ -0,0 +1,347 @@
+package capsule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// OCFL (Oxford Common File Layout) object constants, per OCFL 1.1.
+const (
+	OCFLObjectDeclaration = "0=ocfl_object_1.1"
+	OCFLInventoryType     = "https://ocfl.io/1.1/spec/#inventory"
+	ocflDigestAlgorithm   = "sha512"
+	ocflContentDirectory  = "content"
+	ocflInventoryFile     = "inventory.json"
+)
+
+// OCFLInventory is an OCFL inventory.json document.
+type OCFLInventory struct {
+	ID               string                         `json:"id"`
+	Type             string                         `json:"type"`
+	DigestAlgorithm  string                         `json:"digestAlgorithm"`
+	Head             string                         `json:"head"`
+	ContentDirectory string                         `json:"contentDirectory,omitempty"`
+	Manifest         map[string][]string            `json:"manifest"`
+	Versions         map[string]*OCFLVersion        `json:"versions"`
+	Fixity           map[string]map[string][]string `json:"fixity,omitempty"`
+}
+
+// OCFLVersion is one version block of an OCFL inventory.
+type OCFLVersion struct {
+	Created string              `json:"created"`
+	Message string              `json:"message,omitempty"`
+	User    *OCFLUser           `json:"user,omitempty"`
+	State   map[string][]string `json:"state"`
+}
+
+// OCFLUser identifies who made an OCFL version.
+type OCFLUser struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+}
+
+// OCFLExportOptions configures ExportOCFL.
+type OCFLExportOptions struct {
+	// ObjectID is the OCFL object identifier (required).
+	ObjectID string
+}
+
+// OCFLExportResult describes the outcome of ExportOCFL.
+type OCFLExportResult struct {
+	ObjectID      string
+	Head          string
+	VersionsAdded int
+
+	// SkippedRevisions lists capsule revisions whose manifest is not
+	// retained and therefore could not become an OCFL version.
+	SkippedRevisions []int
+}
+
+// ocflVersionName returns the OCFL version directory name for n (v1, v2...).
+func ocflVersionName(n int) string {
+	return fmt.Sprintf("v%d", n)
+}
+
+// ocflVersionNumber parses a version directory name.
+func ocflVersionNumber(name string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(name, "v"))
+	if err != nil || !strings.HasPrefix(name, "v") || n < 1 {
+		return 0, errors.NewValidation("ocfl version", name)
+	}
+	return n, nil
+}
+
+// ExportOCFL writes the capsule as an OCFL object at objectRoot. Each
+// capsule revision becomes an OCFL version, in order, whose state is the
+// manifest and blobs as of that revision, so the object records the
+// capsule's history. Content is deduplicated by SHA-512 across
+// versions and SHA-256 fixity is recorded for every content file.
+//
+// If objectRoot already holds an object for this capsule, only revisions
+// not yet exported are appended as new versions.
+func (c *Capsule) ExportOCFL(objectRoot string, opts OCFLExportOptions) (*OCFLExportResult, error) {
+	if opts.ObjectID == "" {
+		return nil, errors.NewValidation("object id", "an OCFL object ID is required")
+	}
+
+	inv, err := readOCFLInventory(objectRoot)
+	switch {
+	case errors.Is(err, errors.ErrNotFound):
+		if err := ensureEmptyDir(objectRoot); err != nil {
+			return nil, err
+		}
+		inv = &OCFLInventory{
+			ID:              opts.ObjectID,
+			Type:            OCFLInventoryType,
+			DigestAlgorithm: ocflDigestAlgorithm,
+			Manifest:        make(map[string][]string),
+			Versions:        make(map[string]*OCFLVersion),
+			Fixity:          map[string]map[string][]string{"sha256": {}},
+		}
+	case err != nil:
+		return nil, err
+	case inv.ID != opts.ObjectID:
+		return nil, errors.NewValidation("object id", fmt.Sprintf("object at %s has id %s, not %s", objectRoot, inv.ID, opts.ObjectID))
+	}
+
+	// Manifest digests already exported, so re-exports only append
+	exported := make(map[string]bool)
+	for _, v := range inv.Versions {
+		for digest, paths := range v.State {
+			for _, p := range paths {
+				if p == "manifest.json" {
+					exported[digest] = true
+				}
+			}
+		}
+	}
+	lineage := len(inv.Versions) == 0
+
+	result := &OCFLExportResult{ObjectID: inv.ID}
+	current := c.Manifest.CurrentRevision()
+	for rev := 0; rev <= current; rev++ {
+		manifestData, err := c.RevisionManifest(rev)
+		if err != nil {
+			result.SkippedRevisions = append(result.SkippedRevisions, rev)
+			continue
+		}
+		if exported[sha512Hex(manifestData)] {
+			lineage = true
+			continue
+		}
+
+		var files []preservationFile
+		if rev == current {
+			files, err = c.payloadFiles()
+		} else {
+			files, err = c.revisionFiles(manifestData)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := inv.addVersion(objectRoot, files, c.revisionVersionInfo(rev)); err != nil {
+			return nil, err
+		}
+		result.VersionsAdded++
+	}
+
+	if !lineage {
+		return nil, errors.NewValidation("ocfl object", fmt.Sprintf("object %s does not contain a revision of this capsule", inv.ID))
+	}
+	if result.VersionsAdded > 0 {
+		if err := os.WriteFile(filepath.Join(objectRoot, OCFLObjectDeclaration), []byte("ocfl_object_1.1\n"), 0644); err != nil {
+			return nil, errors.NewIO("write", OCFLObjectDeclaration, err)
+		}
+		if err := writeOCFLInventory(objectRoot, inv); err != nil {
+			return nil, err
+		}
+	}
+	result.Head = inv.Head
+	return result, nil
+}
+
+// revisionVersionInfo returns the OCFL version metadata for a revision.
+func (c *Capsule) revisionVersionInfo(rev int) *OCFLVersion {
+	if rev == 0 {
+		return &OCFLVersion{
+			Created: c.Manifest.CreatedAt,
+			Message: "capsule created",
+			User:    &OCFLUser{Name: fmt.Sprintf("%s %s", c.Manifest.Tool.Name, c.Manifest.Tool.Version)},
+		}
+	}
+
+	r := c.Manifest.Revisions[rev-1]
+	user := r.Producer.Author
+	if user == "" {
+		user = fmt.Sprintf("%s %s", r.Producer.Tool.Name, r.Producer.Tool.Version)
+	}
+	message := fmt.Sprintf("capsule revision %d", r.Number)
+	if r.Message != "" {
+		message += ": " + r.Message
+	}
+	return &OCFLVersion{
+		Created: r.CreatedAt,
+		Message: message,
+		User:    &OCFLUser{Name: user},
+	}
+}
+
+// addVersion appends a version with the given files, copying only content
+// not already in the object, and writes the version's inventory.
+func (inv *OCFLInventory) addVersion(objectRoot string, files []preservationFile, version *OCFLVersion) error {
+	name := ocflVersionName(len(inv.Versions) + 1)
+	version.State = make(map[string][]string)
+
+	for _, f := range files {
+		data, err := f.read()
+		if err != nil {
+			return errors.NewIO("read", f.logical, err)
+		}
+		digest := sha512Hex(data)
+		version.State[digest] = append(version.State[digest], f.logical)
+
+		if _, ok := inv.Manifest[digest]; ok {
+			continue
+		}
+		contentPath := path.Join(name, ocflContentDirectory, f.logical)
+		if err := writePreservationFile(objectRoot, contentPath, data); err != nil {
+			return fmt.Errorf("failed to write %s: %w", contentPath, err)
+		}
+		inv.Manifest[digest] = []string{contentPath}
+		inv.Fixity["sha256"][cas.Hash(data)] = append(inv.Fixity["sha256"][cas.Hash(data)], contentPath)
+	}
+
+	inv.Versions[name] = version
+	inv.Head = name
+	return writeOCFLInventory(filepath.Join(objectRoot, name), inv)
+}
+
+// writeOCFLInventory writes inventory.json and its SHA-512 sidecar to dir.
+func writeOCFLInventory(dir string, inv *OCFLInventory) error {
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize inventory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.NewIO("create directory", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ocflInventoryFile), data, 0644); err != nil {
+		return errors.NewIO("write", ocflInventoryFile, err)
+	}
+	sidecar := fmt.Sprintf("%s %s\n", sha512Hex(data), ocflInventoryFile)
+	return os.WriteFile(filepath.Join(dir, ocflInventoryFile+"."+ocflDigestAlgorithm), []byte(sidecar), 0644)
+}
+
+// readOCFLInventory reads and validates the root inventory of an OCFL
+// object. It returns a not-found error if objectRoot holds no object.
+func readOCFLInventory(objectRoot string) (*OCFLInventory, error) {
+	if _, err := os.Stat(filepath.Join(objectRoot, OCFLObjectDeclaration)); err != nil {
+		return nil, errors.NewNotFound("ocfl object", objectRoot)
+	}
+
+	data, err := os.ReadFile(filepath.Join(objectRoot, ocflInventoryFile))
+	if err != nil {
+		return nil, errors.NewIO("read", ocflInventoryFile, err)
+	}
+	sidecar, err := os.ReadFile(filepath.Join(objectRoot, ocflInventoryFile+"."+ocflDigestAlgorithm))
+	if err != nil {
+		return nil, errors.NewIO("read", ocflInventoryFile+"."+ocflDigestAlgorithm, err)
+	}
+	if fields := strings.Fields(string(sidecar)); len(fields) == 0 || fields[0] != sha512Hex(data) {
+		return nil, errors.NewValidation("ocfl inventory", "inventory digest does not match sidecar")
+	}
+
+	var inv OCFLInventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, errors.NewParse("ocfl inventory", objectRoot, err.Error())
+	}
+	if inv.Type != OCFLInventoryType {
+		return nil, errors.NewUnsupported("ocfl inventory type", inv.Type)
+	}
+	if inv.DigestAlgorithm != ocflDigestAlgorithm {
+		return nil, errors.NewUnsupported("ocfl digest algorithm", inv.DigestAlgorithm)
+	}
+	if _, ok := inv.Versions[inv.Head]; !ok {
+		return nil, errors.NewValidation("ocfl inventory", fmt.Sprintf("head %s has no version block", inv.Head))
+	}
+	if inv.Fixity == nil {
+		inv.Fixity = make(map[string]map[string][]string)
+	}
+	if inv.Fixity["sha256"] == nil {
+		inv.Fixity["sha256"] = make(map[string][]string)
+	}
+	return &inv, nil
+}
+
+// OCFLVersions lists the versions of the OCFL object at objectRoot in order.
+func OCFLVersions(objectRoot string) ([]string, *OCFLInventory, error) {
+	inv, err := readOCFLInventory(objectRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	names := make([]string, 0, len(inv.Versions))
+	for name := range inv.Versions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, _ := ocflVersionNumber(names[i])
+		b, _ := ocflVersionNumber(names[j])
+		return a < b
+	})
+	return names, inv, nil
+}
+
+// ImportOCFL materialises a version of the OCFL object at objectRoot
+// (the head if version is empty) as a capsule in destDir. Every file is
+// checked against its SHA-512 digest, and the resulting capsule's blobs
+// are verified.
+func ImportOCFL(objectRoot, destDir, version string) (*Capsule, error) {
+	inv, err := readOCFLInventory(objectRoot)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version = inv.Head
+	}
+	v, ok := inv.Versions[version]
+	if !ok {
+		return nil, errors.NewNotFound("ocfl version", version)
+	}
+
+	if err := ensureEmptyDir(destDir); err != nil {
+		return nil, err
+	}
+	for digest, logicalPaths := range v.State {
+		contentPaths := inv.Manifest[digest]
+		if len(contentPaths) == 0 {
+			return nil, errors.NewValidation("ocfl inventory", fmt.Sprintf("digest %s missing from manifest", digest))
+		}
+		contentPath, err := cleanRelPath(contentPaths[0])
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(objectRoot, contentPath))
+		if err != nil {
+			return nil, errors.NewIO("read", contentPaths[0], err)
+		}
+		if sha512Hex(data) != digest {
+			return nil, errors.NewValidation("ocfl content", fmt.Sprintf("fixity check failed for %s", contentPaths[0]))
+		}
+		for _, logical := range logicalPaths {
+			if err := writePreservationFile(destDir, logical, data); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %w", logical, err)
+			}
+		}
+	}
+
+	return openCapsuleDir(destDir)
+}
@@ -0,0 +1,172 @@
+package capsule
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newRevisedTestCapsule returns a capsule with one amendment adding a blob.
+func newRevisedTestCapsule(t *testing.T) *Capsule {
+	t.Helper()
+	c, _, _ := newArchiveTestCapsule(t)
+	if _, err := c.Amend(AmendOptions{Author: "archivist", Message: "add notes"}, func(c *Capsule) error {
+		file := filepath.Join(t.TempDir(), "notes.txt")
+		if err := os.WriteFile(file, []byte("revision one notes"), 0644); err != nil {
+			return err
+		}
+		_, err := c.IngestFile(file)
+		return err
+	}); err != nil {
+		t.Fatalf("Amend failed: %v", err)
+	}
+	return c
+}
+
+// blobHashes returns the sorted SHA-256 keys of the blob index.
+func blobHashes(m *Manifest) map[string]string {
+	hashes := make(map[string]string, len(m.Blobs.BySHA256))
+	for hash, record := range m.Blobs.BySHA256 {
+		hashes[hash] = record.Path
+	}
+	return hashes
+}
+
+// TestOCFLRoundTrip tests capsule -> OCFL -> capsule reproduces the manifest and blobs.
+func TestOCFLRoundTrip(t *testing.T) {
+	c := newRevisedTestCapsule(t)
+	objectRoot := filepath.Join(t.TempDir(), "object")
+
+	result, err := c.ExportOCFL(objectRoot, OCFLExportOptions{ObjectID: "urn:juniper:test"})
+	if err != nil {
+		t.Fatalf("ExportOCFL failed: %v", err)
+	}
+	if result.VersionsAdded != 2 || result.Head != "v2" {
+		t.Fatalf("expected v1 and v2, got %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(objectRoot, OCFLObjectDeclaration)); err != nil {
+		t.Errorf("missing object declaration: %v", err)
+	}
+
+	imported, err := ImportOCFL(objectRoot, filepath.Join(t.TempDir(), "imported"), "")
+	if err != nil {
+		t.Fatalf("ImportOCFL failed: %v", err)
+	}
+	want, _ := c.Manifest.ToJSON()
+	got, _ := imported.Manifest.ToJSON()
+	if !bytes.Equal(want, got) {
+		t.Error("imported manifest differs from original")
+	}
+	if !reflect.DeepEqual(blobHashes(c.Manifest), blobHashes(imported.Manifest)) {
+		t.Error("imported blob index differs from original")
+	}
+	for hash := range imported.Manifest.Blobs.BySHA256 {
+		if err := imported.VerifyBlob(hash); err != nil {
+			t.Errorf("blob %s: %v", hash, err)
+		}
+	}
+}
+
+// TestOCFLRevisionsAsVersions tests that v1 holds the original revision.
+func TestOCFLRevisionsAsVersions(t *testing.T) {
+	c := newRevisedTestCapsule(t)
+	objectRoot := filepath.Join(t.TempDir(), "object")
+	if _, err := c.ExportOCFL(objectRoot, OCFLExportOptions{ObjectID: "urn:juniper:test"}); err != nil {
+		t.Fatalf("ExportOCFL failed: %v", err)
+	}
+
+	versions, inv, err := OCFLVersions(objectRoot)
+	if err != nil {
+		t.Fatalf("OCFLVersions failed: %v", err)
+	}
+	if !reflect.DeepEqual(versions, []string{"v1", "v2"}) {
+		t.Fatalf("versions = %v", versions)
+	}
+	if v2 := inv.Versions["v2"]; !strings.Contains(v2.Message, "add notes") || v2.User.Name != "archivist" {
+		t.Errorf("unexpected v2 metadata: %+v", v2)
+	}
+
+	original, err := ImportOCFL(objectRoot, filepath.Join(t.TempDir(), "v1"), "v1")
+	if err != nil {
+		t.Fatalf("ImportOCFL v1 failed: %v", err)
+	}
+	if original.Manifest.CurrentRevision() != 0 || len(original.Manifest.Artifacts) != 2 {
+		t.Errorf("expected original 2-artifact manifest, got revision %d with %d artifacts",
+			original.Manifest.CurrentRevision(), len(original.Manifest.Artifacts))
+	}
+}
+
+// TestOCFLIncrementalExport tests that re-exporting appends only new revisions.
+func TestOCFLIncrementalExport(t *testing.T) {
+	c := newRevisedTestCapsule(t)
+	objectRoot := filepath.Join(t.TempDir(), "object")
+	opts := OCFLExportOptions{ObjectID: "urn:juniper:test"}
+	if _, err := c.ExportOCFL(objectRoot, opts); err != nil {
+		t.Fatalf("ExportOCFL failed: %v", err)
+	}
+
+	result, err := c.ExportOCFL(objectRoot, opts)
+	if err != nil {
+		t.Fatalf("re-export failed: %v", err)
+	}
+	if result.VersionsAdded != 0 || result.Head != "v2" {
+		t.Errorf("expected no new versions, got %+v", result)
+	}
+
+	if _, err := c.Amend(AmendOptions{Message: "more"}, func(c *Capsule) error {
+		_, err := c.StoreBlob([]byte("export"), "text/plain")
+		if err != nil {
+			return err
+		}
+		c.Manifest.Exports = map[string]*Export{"e": {ID: "e", ResultBlobSHA256: "x"}}
+		return nil
+	}); err != nil {
+		t.Fatalf("Amend failed: %v", err)
+	}
+	result, err = c.ExportOCFL(objectRoot, opts)
+	if err != nil {
+		t.Fatalf("incremental export failed: %v", err)
+	}
+	if result.VersionsAdded != 1 || result.Head != "v3" {
+		t.Errorf("expected v3 appended, got %+v", result)
+	}
+
+	// Unchanged blobs are not copied into the new version
+	if _, err := os.Stat(filepath.Join(objectRoot, "v3", "content", "blobs", "sha256")); err != nil {
+		t.Fatalf("expected new blobs in v3: %v", err)
+	}
+	for _, a := range c.Manifest.Artifacts {
+		p := filepath.Join(objectRoot, "v3", "content", filepath.FromSlash(c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256].Path))
+		if _, err := os.Stat(p); err == nil {
+			t.Errorf("artifact %s blob duplicated in v3", a.ID)
+		}
+	}
+
+	if _, err := c.ExportOCFL(objectRoot, OCFLExportOptions{ObjectID: "urn:other"}); err == nil {
+		t.Error("expected error for mismatched object id")
+	}
+}
+
+// TestImportOCFLDetectsCorruption tests the SHA-512 fixity check.
+func TestImportOCFLDetectsCorruption(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+	objectRoot := filepath.Join(t.TempDir(), "object")
+	if _, err := c.ExportOCFL(objectRoot, OCFLExportOptions{ObjectID: "urn:juniper:test"}); err != nil {
+		t.Fatalf("ExportOCFL failed: %v", err)
+	}
+
+	blob := filepath.Join(objectRoot, "v1", "content", filepath.FromSlash(c.Manifest.Blobs.BySHA256[a.PrimaryBlobSHA256].Path))
+	if err := os.WriteFile(blob, []byte("corrupted"), 0644); err != nil {
+		t.Fatalf("failed to corrupt blob: %v", err)
+	}
+	if _, err := ImportOCFL(objectRoot, filepath.Join(t.TempDir(), "imported"), ""); err == nil {
+		t.Error("expected fixity failure")
+	}
+
+	if _, err := ImportOCFL(t.TempDir(), filepath.Join(t.TempDir(), "imported"), ""); err == nil {
+		t.Error("expected error for directory without an OCFL object")
+	}
+}
@@ -0,0 +1,181 @@
+package capsule
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// preservationFile is a capsule file mapped into a preservation package
+// (OCFL object or BagIt bag). Its content comes from data when set, or
+// from the file at src.
+type preservationFile struct {
+	logical string
+	src     string
+	data    []byte
+}
+
+// read returns the content of the file.
+func (f preservationFile) read() ([]byte, error) {
+	if f.data != nil {
+		return f.data, nil
+	}
+	return os.ReadFile(f.src)
+}
+
+// payloadFiles returns every file making up the packed capsule: the
+// manifest followed by all files under blobs/, sorted by path. This is
+// the same set of files Pack writes.
+func (c *Capsule) payloadFiles() ([]preservationFile, error) {
+	manifestData, err := c.Manifest.ToJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+	files := []preservationFile{{logical: "manifest.json", data: manifestData}}
+
+	blobsDir := filepath.Join(c.root, "blobs")
+	if _, err := os.Stat(blobsDir); os.IsNotExist(err) {
+		return files, nil
+	}
+	var blobs []preservationFile
+	if err := filepathWalk(blobsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepathRel(c.root, path)
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, preservationFile{logical: filepath.ToSlash(rel), src: path})
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to scan blobs: %w", err)
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].logical < blobs[j].logical })
+
+	return append(files, blobs...), nil
+}
+
+// revisionFiles returns the files of the capsule as of an earlier revision:
+// its manifest plus the blobs (and BLAKE3 pointers) that manifest indexes.
+// Blobs that are no longer present on disk are omitted.
+func (c *Capsule) revisionFiles(manifestData []byte) ([]preservationFile, error) {
+	m, err := ParseManifest(manifestData)
+	if err != nil {
+		return nil, err
+	}
+	files := []preservationFile{{logical: "manifest.json", data: manifestData}}
+
+	var blobs []preservationFile
+	for _, record := range m.Blobs.BySHA256 {
+		path := filepath.Join(c.root, filepath.FromSlash(record.Path))
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		blobs = append(blobs, preservationFile{logical: record.Path, src: path})
+
+		if record.BLAKE3 == "" {
+			continue
+		}
+		pointer := fmt.Sprintf("blobs/blake3/%s/%s.json", safePrefix(record.BLAKE3), record.BLAKE3)
+		pointerPath := filepath.Join(c.root, filepath.FromSlash(pointer))
+		if _, err := os.Stat(pointerPath); err == nil {
+			blobs = append(blobs, preservationFile{logical: pointer, src: pointerPath})
+		}
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].logical < blobs[j].logical })
+
+	return append(files, blobs...), nil
+}
+
+// openCapsuleDir opens a capsule already laid out on disk at root and
+// checks that every indexed blob is present and intact.
+func openCapsuleDir(root string) (*Capsule, error) {
+	data, err := os.ReadFile(filepath.Join(root, "manifest.json"))
+	if err != nil {
+		return nil, errors.NewIO("read", "manifest.json", err)
+	}
+	manifest, err := ParseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	store, err := casNewStoreUnpack(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %w", err)
+	}
+	c := &Capsule{
+		root:       root,
+		Manifest:   manifest,
+		store:      store,
+		identities: getDefaultIdentities(),
+	}
+
+	hashes := make([]string, 0, len(manifest.Blobs.BySHA256))
+	for hash := range manifest.Blobs.BySHA256 {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		if err := c.VerifyBlob(hash); err != nil {
+			return nil, fmt.Errorf("blob %s: %w", hash, err)
+		}
+	}
+	return c, nil
+}
+
+// cleanRelPath validates a slash-separated path from a preservation
+// package and returns it as an OS path. Absolute paths and paths that
+// escape the package are rejected.
+func cleanRelPath(rel string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errors.NewValidation("path", fmt.Sprintf("unsafe path %q", rel))
+	}
+	return clean, nil
+}
+
+// writePreservationFile writes data to root/rel, creating directories.
+func writePreservationFile(root, rel string, data []byte) error {
+	clean, err := cleanRelPath(rel)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(root, clean)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// sha512Hex returns the hex SHA-512 digest of data.
+func sha512Hex(data []byte) string {
+	h := sha512.Sum512(data)
+	return hex.EncodeToString(h[:])
+}
+
+// ensureEmptyDir creates dir, failing if it exists and is not empty.
+func ensureEmptyDir(dir string) error {
+	f, err := os.Open(dir)
+	if os.IsNotExist(err) {
+		return os.MkdirAll(dir, 0755)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Readdirnames(1); err != io.EOF {
+		return errors.NewValidation("directory", fmt.Sprintf("%s is not empty", dir))
+	}
+	return nil
+}
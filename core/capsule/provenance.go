@@ -0,0 +1,506 @@
+package capsule
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+)
+
+// Provenance statement constants (in-toto Statement v1 with SLSA Provenance v1 predicate).
+const (
+	// InTotoStatementType is the _type of an in-toto v1 statement.
+	InTotoStatementType = "https://in-toto.io/Statement/v1"
+
+	// SLSAProvenancePredicateType is the predicate type for SLSA v1 provenance.
+	SLSAProvenancePredicateType = "https://slsa.dev/provenance/v1"
+
+	// DerivedExportBuildType identifies a source -> IR -> target conversion.
+	DerivedExportBuildType = "https://github.com/FocuswithJustin/JuniperBible/buildtypes/derived-export/v1"
+
+	// ProvenanceMIME is the media type of stored provenance statements.
+	ProvenanceMIME = "application/vnd.in-toto+json"
+
+	// pluginURIPrefix prefixes plugin references in provenance statements.
+	pluginURIPrefix = "juniper-plugin:"
+)
+
+// ProvenanceStatement is an in-toto v1 statement carrying SLSA provenance.
+type ProvenanceStatement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     *ProvenancePredicate `json:"predicate"`
+}
+
+// ResourceDescriptor identifies an artifact by name and digest.
+type ResourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	MediaType   string            `json:"mediaType,omitempty"`
+	Annotations Attributes        `json:"annotations,omitempty"`
+}
+
+// ProvenancePredicate is the SLSA v1 provenance predicate.
+type ProvenancePredicate struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition describes the inputs to a derivation.
+type BuildDefinition struct {
+	BuildType string `json:"buildType"`
+
+	// ExternalParameters are the user-controlled parameters (e.g., target format).
+	ExternalParameters Attributes `json:"externalParameters"`
+
+	// InternalParameters are set by the builder; the loss report lives here.
+	InternalParameters *ProvenanceInternalParameters `json:"internalParameters,omitempty"`
+
+	// ResolvedDependencies are the materials: source artifact and IR.
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies"`
+}
+
+// ProvenanceInternalParameters records builder-determined details of a derivation.
+type ProvenanceInternalParameters struct {
+	SourcePlugin string         `json:"sourcePlugin,omitempty"`
+	LossClass    ir.LossClass   `json:"lossClass,omitempty"`
+	LossReport   *ir.LossReport `json:"lossReport,omitempty"`
+}
+
+// RunDetails describes the builder and the invocation.
+type RunDetails struct {
+	Builder  ProvenanceBuilder `json:"builder"`
+	Metadata *BuildMetadata    `json:"metadata,omitempty"`
+}
+
+// ProvenanceBuilder identifies the plugin that produced the subject.
+// BuilderDependencies lists every plugin involved with its binary hash.
+type ProvenanceBuilder struct {
+	ID                  string               `json:"id"`
+	Version             map[string]string    `json:"version,omitempty"`
+	BuilderDependencies []ResourceDescriptor `json:"builderDependencies,omitempty"`
+}
+
+// BuildMetadata records invocation timing.
+type BuildMetadata struct {
+	InvocationID string `json:"invocationId,omitempty"`
+	StartedOn    string `json:"startedOn,omitempty"`
+	FinishedOn   string `json:"finishedOn,omitempty"`
+}
+
+// DerivationInputs describes a single derivation for NewDerivedProvenance.
+type DerivationInputs struct {
+	// SubjectName is a human name for the derived artifact.
+	SubjectName string
+
+	// Output is the derived artifact bytes.
+	Output []byte
+
+	// Source is the original artifact the derivation started from.
+	Source *Artifact
+
+	// IRSHA256 and IRBLAKE3 identify the intermediate IR, if any.
+	IRSHA256 string
+	IRBLAKE3 string
+
+	// TargetFormat is the requested output format.
+	TargetFormat string
+
+	// SourcePlugin extracted the IR; TargetPlugin emitted the output.
+	SourcePlugin *plugins.Plugin
+	TargetPlugin *plugins.Plugin
+
+	// SourceBinarySHA256 and TargetBinarySHA256 are the hashes of the
+	// plugin executables that ran, as reported with their responses. When
+	// empty, the plugin's executable is hashed.
+	SourceBinarySHA256 string
+	TargetBinarySHA256 string
+
+	// ExtractOptions and EmitOptions are the options the plugins ran with.
+	ExtractOptions plugins.Options
+	EmitOptions    plugins.Options
+
+	// LossReports are the loss reports of each conversion step.
+	LossReports []*ir.LossReport
+
+	// InvocationID, StartedAt and FinishedAt describe the run.
+	InvocationID string
+	StartedAt    time.Time
+	FinishedAt   time.Time
+}
+
+// NewDerivedProvenance builds a provenance statement for a derived artifact.
+// The target plugin is the builder; both plugins are listed with their
+// binary hashes as builder dependencies.
+func NewDerivedProvenance(in DerivationInputs) (*ProvenanceStatement, error) {
+	if in.TargetPlugin == nil || in.TargetPlugin.Manifest == nil {
+		return nil, errors.NewValidation("TargetPlugin", "is required")
+	}
+
+	subject := ResourceDescriptor{
+		Name: in.SubjectName,
+		Digest: map[string]string{
+			"sha256": cas.Hash(in.Output),
+			"blake3": cas.Blake3Hash(in.Output),
+		},
+	}
+
+	var materials []ResourceDescriptor
+	if in.Source != nil {
+		materials = append(materials, ResourceDescriptor{
+			Name:   in.Source.ID,
+			Digest: artifactDigest(in.Source),
+			Annotations: Attributes{
+				"role":          "source",
+				"original_name": in.Source.OriginalName,
+			},
+		})
+	}
+	if in.IRSHA256 != "" {
+		digest := map[string]string{"sha256": in.IRSHA256}
+		if in.IRBLAKE3 != "" {
+			digest["blake3"] = in.IRBLAKE3
+		}
+		materials = append(materials, ResourceDescriptor{
+			Name:        "ir",
+			Digest:      digest,
+			MediaType:   "application/json",
+			Annotations: Attributes{"role": "ir"},
+		})
+	}
+
+	var deps []ResourceDescriptor
+	for i, p := range []*plugins.Plugin{in.SourcePlugin, in.TargetPlugin} {
+		if p == nil || p.Manifest == nil {
+			continue
+		}
+		binaryHash := in.SourceBinarySHA256
+		if i == 1 {
+			binaryHash = in.TargetBinarySHA256
+		}
+		dep, err := pluginDescriptor(p, binaryHash)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, dep)
+	}
+
+	internal := &ProvenanceInternalParameters{}
+	if in.SourcePlugin != nil && in.SourcePlugin.Manifest != nil {
+		internal.SourcePlugin = in.SourcePlugin.Manifest.PluginID
+	}
+	if len(in.LossReports) > 0 {
+		internal.LossReport = CombinedLossReport(in.LossReports)
+		internal.LossClass = internal.LossReport.LossClass
+	}
+
+	external := Attributes{"target_format": in.TargetFormat}
+	if len(in.ExtractOptions) > 0 {
+		external["extract_options"] = map[string]interface{}(in.ExtractOptions)
+	}
+	if len(in.EmitOptions) > 0 {
+		external["emit_options"] = map[string]interface{}(in.EmitOptions)
+	}
+
+	stmt := &ProvenanceStatement{
+		Type:          InTotoStatementType,
+		Subject:       []ResourceDescriptor{subject},
+		PredicateType: SLSAProvenancePredicateType,
+		Predicate: &ProvenancePredicate{
+			BuildDefinition: BuildDefinition{
+				BuildType:            DerivedExportBuildType,
+				ExternalParameters:   external,
+				InternalParameters:   internal,
+				ResolvedDependencies: materials,
+			},
+			RunDetails: RunDetails{
+				Builder: ProvenanceBuilder{
+					ID: pluginURIPrefix + in.TargetPlugin.Manifest.PluginID,
+					Version: map[string]string{
+						in.TargetPlugin.Manifest.PluginID: in.TargetPlugin.Manifest.Version,
+						"capsule":                         Version,
+					},
+					BuilderDependencies: deps,
+				},
+				Metadata: &BuildMetadata{
+					InvocationID: in.InvocationID,
+					StartedOn:    formatProvenanceTime(in.StartedAt),
+					FinishedOn:   formatProvenanceTime(in.FinishedAt),
+				},
+			},
+		},
+	}
+	return stmt, nil
+}
+
+// artifactDigest returns the digest set of an artifact.
+func artifactDigest(a *Artifact) map[string]string {
+	digest := map[string]string{"sha256": a.PrimaryBlobSHA256}
+	if a.Hashes.BLAKE3 != "" {
+		digest["blake3"] = a.Hashes.BLAKE3
+	}
+	return digest
+}
+
+// pluginDescriptor describes a plugin and the hash of its executable,
+// which is computed when binaryHash is empty.
+func pluginDescriptor(p *plugins.Plugin, binaryHash string) (ResourceDescriptor, error) {
+	if binaryHash == "" {
+		var err error
+		if binaryHash, err = p.BinarySHA256(); err != nil {
+			return ResourceDescriptor{}, fmt.Errorf("failed to hash plugin %s: %w", p.Manifest.PluginID, err)
+		}
+	}
+	return ResourceDescriptor{
+		Name:   p.Manifest.PluginID,
+		URI:    fmt.Sprintf("%s%s@%s", pluginURIPrefix, p.Manifest.PluginID, p.Manifest.Version),
+		Digest: map[string]string{"sha256": binaryHash},
+		Annotations: Attributes{
+			"version":  p.Manifest.Version,
+			"embedded": p.IsEmbedded(),
+		},
+	}, nil
+}
+
+// NewPluginInfo describes a plugin for a run record, with the hash of its
+// executable and its signature status.
+func NewPluginInfo(p *plugins.Plugin) (*PluginInfo, error) {
+	binaryHash, err := p.BinarySHA256()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash plugin %s: %w", p.Manifest.PluginID, err)
+	}
+	status := p.VerifySignature()
+	info := &PluginInfo{
+		PluginID:      p.Manifest.PluginID,
+		PluginVersion: p.Manifest.Version,
+		Kind:          p.Manifest.Kind,
+		BinarySHA256:  binaryHash,
+		Signature:     status.State,
+	}
+	if status.State == plugins.SignatureValid {
+		info.Publisher = status.Publisher
+	}
+	return info, nil
+}
+
+// formatProvenanceTime formats a timestamp as RFC 3339, or "" if unset.
+func formatProvenanceTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// AddProvenance stores a provenance statement in the CAS and indexes it in
+// the manifest. exportID may be empty when the subject is not an export.
+func (c *Capsule) AddProvenance(stmt *ProvenanceStatement, exportID string) (*ProvenanceRecord, error) {
+	if len(stmt.Subject) == 0 || stmt.Subject[0].Digest["sha256"] == "" {
+		return nil, errors.NewValidation("provenance", "statement has no sha256 subject")
+	}
+
+	data, err := json.MarshalIndent(stmt, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize provenance: %w", err)
+	}
+
+	blob, err := c.StoreBlob(data, ProvenanceMIME)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store provenance: %w", err)
+	}
+
+	// The ID is derived from the statement, so attesting the same subject
+	// twice, e.g. with other options, adds a second record.
+	subject := stmt.Subject[0].Digest["sha256"]
+	record := &ProvenanceRecord{
+		ID:                  "prov-" + blob.SHA256[:12],
+		SubjectSHA256:       subject,
+		StatementBlobSHA256: blob.SHA256,
+		ExportID:            exportID,
+	}
+
+	if c.Manifest.Provenance == nil {
+		c.Manifest.Provenance = make(map[string]*ProvenanceRecord)
+	}
+	c.Manifest.Provenance[record.ID] = record
+
+	return record, nil
+}
+
+// StoreBlob stores data in the CAS and records it in the manifest blob index.
+func (c *Capsule) StoreBlob(data []byte, mime string) (*BlobRecord, error) {
+	result, err := storeStoreWithBlake3(c.store, data)
+	if err != nil {
+		return nil, err
+	}
+	record := &BlobRecord{
+		SHA256:    result.SHA256,
+		BLAKE3:    result.BLAKE3,
+		SizeBytes: int64(len(data)),
+		Path:      fmt.Sprintf("blobs/sha256/%s/%s", result.SHA256[:2], result.SHA256),
+		MIME:      mime,
+	}
+	if c.Manifest.Blobs.BySHA256 == nil {
+		c.Manifest.Blobs.BySHA256 = make(map[string]*BlobRecord)
+	}
+	c.Manifest.Blobs.BySHA256[result.SHA256] = record
+	return record, nil
+}
+
+// GetProvenance loads the provenance statement for a record.
+func (c *Capsule) GetProvenance(recordID string) (*ProvenanceStatement, error) {
+	record, ok := c.Manifest.Provenance[recordID]
+	if !ok {
+		return nil, errors.NewNotFound("provenance", recordID)
+	}
+	data, err := c.ReadBlob(record.StatementBlobSHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve provenance statement: %w", err)
+	}
+	return ParseProvenance(data)
+}
+
+// ParseProvenance parses a provenance statement from JSON.
+func ParseProvenance(data []byte) (*ProvenanceStatement, error) {
+	var stmt ProvenanceStatement
+	if err := json.Unmarshal(data, &stmt); err != nil {
+		return nil, errors.NewParse("provenance", "statement", err.Error())
+	}
+	return &stmt, nil
+}
+
+// ProvenanceVerification is the result of verifying one provenance record.
+type ProvenanceVerification struct {
+	RecordID      string   `json:"record_id"`
+	SubjectSHA256 string   `json:"subject_sha256"`
+	Builder       string   `json:"builder,omitempty"`
+	Materials     []string `json:"materials,omitempty"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+// OK returns true if the record verified without errors.
+func (v *ProvenanceVerification) OK() bool {
+	return len(v.Errors) == 0
+}
+
+// VerifyProvenance checks every provenance record in the capsule.
+// A record verifies when its statement is intact and well-formed, the
+// subject is stored in the capsule with the attested hash, and every
+// material with a sha256 digest is present in the capsule.
+func (c *Capsule) VerifyProvenance() []*ProvenanceVerification {
+	ids := make([]string, 0, len(c.Manifest.Provenance))
+	for id := range c.Manifest.Provenance {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	results := make([]*ProvenanceVerification, 0, len(ids))
+	for _, id := range ids {
+		results = append(results, c.verifyProvenanceRecord(c.Manifest.Provenance[id]))
+	}
+	return results
+}
+
+// verifyProvenanceRecord verifies a single provenance record.
+func (c *Capsule) verifyProvenanceRecord(record *ProvenanceRecord) *ProvenanceVerification {
+	v := &ProvenanceVerification{
+		RecordID:      record.ID,
+		SubjectSHA256: record.SubjectSHA256,
+	}
+	fail := func(format string, args ...interface{}) {
+		v.Errors = append(v.Errors, fmt.Sprintf(format, args...))
+	}
+
+	data, err := c.ReadBlob(record.StatementBlobSHA256)
+	if err != nil {
+		fail("statement blob missing: %v", err)
+		return v
+	}
+	if cas.Hash(data) != record.StatementBlobSHA256 {
+		fail("statement blob hash mismatch")
+		return v
+	}
+	stmt, err := ParseProvenance(data)
+	if err != nil {
+		fail("statement invalid: %v", err)
+		return v
+	}
+
+	if stmt.Type != InTotoStatementType {
+		fail("unexpected statement type %q", stmt.Type)
+	}
+	if stmt.PredicateType != SLSAProvenancePredicateType {
+		fail("unexpected predicate type %q", stmt.PredicateType)
+	}
+	if stmt.Predicate == nil {
+		fail("statement has no predicate")
+		return v
+	}
+	v.Builder = stmt.Predicate.RunDetails.Builder.ID
+
+	subjectFound := false
+	for _, s := range stmt.Subject {
+		if s.Digest["sha256"] == record.SubjectSHA256 {
+			subjectFound = true
+			c.verifyDescriptor("subject", s, fail)
+		}
+	}
+	if !subjectFound {
+		fail("statement does not attest subject %s", record.SubjectSHA256)
+	}
+
+	if record.ExportID != "" {
+		export, ok := c.Manifest.Exports[record.ExportID]
+		if !ok {
+			fail("export %s not found", record.ExportID)
+		} else if export.ResultBlobSHA256 != record.SubjectSHA256 {
+			fail("export %s result does not match subject", record.ExportID)
+		}
+	}
+
+	for _, m := range stmt.Predicate.BuildDefinition.ResolvedDependencies {
+		v.Materials = append(v.Materials, fmt.Sprintf("%s sha256:%s", m.Name, m.Digest["sha256"]))
+		c.verifyDescriptor("material "+m.Name, m, fail)
+	}
+
+	return v
+}
+
+// verifyDescriptor checks that a descriptor's blob is stored with matching digests.
+func (c *Capsule) verifyDescriptor(role string, d ResourceDescriptor, fail func(string, ...interface{})) {
+	sha := d.Digest["sha256"]
+	if sha == "" {
+		fail("%s has no sha256 digest", role)
+		return
+	}
+	data, err := c.ReadBlob(sha)
+	if err != nil {
+		fail("%s not found in capsule: %v", role, err)
+		return
+	}
+	if cas.Hash(data) != sha {
+		fail("%s sha256 mismatch", role)
+	}
+	if b3 := d.Digest["blake3"]; b3 != "" && cas.Blake3Hash(data) != b3 {
+		fail("%s blake3 mismatch", role)
+	}
+}
+
+// FindProvenanceBySubject returns the records attesting the given bytes.
+// This traces a published file back to the statement that produced it.
+func (c *Capsule) FindProvenanceBySubject(data []byte) []*ProvenanceRecord {
+	hash := cas.Hash(data)
+	var records []*ProvenanceRecord
+	for _, r := range c.Manifest.Provenance {
+		if r.SubjectSHA256 == hash {
+			records = append(records, r)
+		}
+	}
+	return records
+}
@@ -0,0 +1,313 @@
+package capsule
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+)
+
+// mockDerivedPlugins makes every plugin call succeed, writing irData and
+// outData to files under dir. The emit call reports mockEmitBinarySHA256
+// as its binary hash. The returned function restores the originals.
+func mockDerivedPlugins(t *testing.T, dir string, irData, outData []byte) func() {
+	t.Helper()
+	irPath := filepath.Join(dir, "mock-ir.json")
+	outPath := filepath.Join(dir, "mock-out.txt")
+	if err := os.WriteFile(irPath, irData, 0644); err != nil {
+		t.Fatalf("failed to write IR: %v", err)
+	}
+	if err := os.WriteFile(outPath, outData, 0644); err != nil {
+		t.Fatalf("failed to write output: %v", err)
+	}
+
+	origExecute := pluginsExecutePlugin
+	origParseExtract := pluginsParseExtractIRResult
+	origParseEmit := pluginsParseEmitNativeResult
+
+	pluginsExecutePlugin = func(p *plugins.Plugin, req *plugins.IPCRequest) (*plugins.IPCResponse, error) {
+		return &plugins.IPCResponse{Status: "success"}, nil
+	}
+	pluginsParseExtractIRResult = func(resp *plugins.IPCResponse) (*plugins.ExtractIRResult, error) {
+		return &plugins.ExtractIRResult{
+			IRPath:     irPath,
+			LossReport: &plugins.LossReportIPC{SourceFormat: "osis", TargetFormat: "ir", LossClass: "L0"},
+		}, nil
+	}
+	pluginsParseEmitNativeResult = func(resp *plugins.IPCResponse) (*plugins.EmitNativeResult, error) {
+		return &plugins.EmitNativeResult{
+			OutputPath:   outPath,
+			LossReport:   &plugins.LossReportIPC{SourceFormat: "ir", TargetFormat: "usfm", LossClass: "L1"},
+			BinarySHA256: mockEmitBinarySHA256,
+		}, nil
+	}
+
+	return func() {
+		pluginsExecutePlugin = origExecute
+		pluginsParseExtractIRResult = origParseExtract
+		pluginsParseEmitNativeResult = origParseEmit
+	}
+}
+
+// mockEmitBinarySHA256 is the binary hash reported by the mocked emit call.
+var mockEmitBinarySHA256 = strings.Repeat("e", 64)
+
+// newProvenanceTestExport runs a derived export that records provenance.
+func newProvenanceTestExport(t *testing.T) (*Capsule, *Artifact, *DerivedExportResult) {
+	t.Helper()
+	c, a, _ := newArchiveTestCapsule(t)
+	a.Detected = &DetectionResult{FormatID: "osis"}
+
+	loader, loaderDir := setupTestPluginLoader(t, []string{"format-osis", "format-usfm"})
+	t.Cleanup(func() { os.RemoveAll(loaderDir) })
+
+	dir := t.TempDir()
+	t.Cleanup(mockDerivedPlugins(t, dir, []byte(`{"id":"ir"}`), []byte("\\id GEN derived")))
+
+	result, err := c.ExportDerived(a.ID, DerivedExportOptions{
+		TargetFormat:     "usfm",
+		PluginLoader:     loader,
+		RecordProvenance: true,
+	}, filepath.Join(dir, "out", "derived.usfm"))
+	if err != nil {
+		t.Fatalf("ExportDerived failed: %v", err)
+	}
+	return c, a, result
+}
+
+// TestExportDerivedRecordsProvenance tests that a derived export stores a statement.
+func TestExportDerivedRecordsProvenance(t *testing.T) {
+	c, a, result := newProvenanceTestExport(t)
+
+	if result.Provenance == nil {
+		t.Fatal("expected provenance record")
+	}
+	if result.OutputSHA256 != cas.Hash([]byte("\\id GEN derived")) {
+		t.Errorf("unexpected output hash %s", result.OutputSHA256)
+	}
+
+	export, ok := c.Manifest.Exports[result.ExportID]
+	if !ok {
+		t.Fatalf("export %s not recorded", result.ExportID)
+	}
+	if export.Mode != string(ExportModeDerived) || export.ResultBlobSHA256 != result.OutputSHA256 {
+		t.Errorf("unexpected export record: %+v", export)
+	}
+
+	stmt, err := c.GetProvenance(result.Provenance.ID)
+	if err != nil {
+		t.Fatalf("GetProvenance failed: %v", err)
+	}
+	if stmt.Type != InTotoStatementType || stmt.PredicateType != SLSAProvenancePredicateType {
+		t.Errorf("unexpected statement types: %s, %s", stmt.Type, stmt.PredicateType)
+	}
+	if stmt.Subject[0].Digest["sha256"] != result.OutputSHA256 {
+		t.Error("subject digest does not match output")
+	}
+
+	builder := stmt.Predicate.RunDetails.Builder
+	if builder.ID != "juniper-plugin:format-usfm" {
+		t.Errorf("builder ID = %s", builder.ID)
+	}
+	if builder.Version["format-usfm"] != "1.0.0" {
+		t.Errorf("builder version = %v", builder.Version)
+	}
+	if len(builder.BuilderDependencies) != 2 {
+		t.Fatalf("expected 2 builder dependencies, got %d", len(builder.BuilderDependencies))
+	}
+	for _, dep := range builder.BuilderDependencies {
+		if len(dep.Digest["sha256"]) != 64 {
+			t.Errorf("plugin %s has no binary hash", dep.Name)
+		}
+	}
+	// The target plugin is recorded with the binary that ran it
+	if got := builder.BuilderDependencies[1].Digest["sha256"]; got != mockEmitBinarySHA256 {
+		t.Errorf("target plugin hash = %s, want the reported %s", got, mockEmitBinarySHA256)
+	}
+
+	materials := stmt.Predicate.BuildDefinition.ResolvedDependencies
+	if len(materials) != 2 {
+		t.Fatalf("expected source and IR materials, got %d", len(materials))
+	}
+	if materials[0].Digest["sha256"] != a.Hashes.SHA256 {
+		t.Error("source material does not match artifact hash")
+	}
+	if materials[1].Digest["sha256"] != result.IRBlobSHA256 {
+		t.Error("IR material does not match IR hash")
+	}
+
+	internal := stmt.Predicate.BuildDefinition.InternalParameters
+	if internal.LossReport == nil || internal.LossClass != "L1" {
+		t.Errorf("expected combined L1 loss report, got %+v", internal)
+	}
+}
+
+// TestVerifyProvenance tests verification of intact and tampered statements.
+func TestVerifyProvenance(t *testing.T) {
+	c, _, result := newProvenanceTestExport(t)
+
+	results := c.VerifyProvenance()
+	if len(results) != 1 || !results[0].OK() {
+		t.Fatalf("expected provenance to verify, got %+v", results[0])
+	}
+
+	// Point the record at a subject the statement does not attest.
+	c.Manifest.Provenance[result.Provenance.ID].SubjectSHA256 = cas.Hash([]byte("other"))
+	results = c.VerifyProvenance()
+	if results[0].OK() {
+		t.Fatal("expected verification to fail for unattested subject")
+	}
+}
+
+// TestVerifyProvenanceMissingMaterial tests that a missing source blob is reported.
+func TestVerifyProvenanceMissingMaterial(t *testing.T) {
+	c, a, _ := newProvenanceTestExport(t)
+
+	blobPath := filepath.Join(c.GetRoot(), "blobs", "sha256", a.Hashes.SHA256[:2], a.Hashes.SHA256)
+	if err := os.Remove(blobPath); err != nil {
+		t.Fatalf("failed to remove blob: %v", err)
+	}
+
+	results := c.VerifyProvenance()
+	if results[0].OK() {
+		t.Fatal("expected verification to fail")
+	}
+	if !strings.Contains(strings.Join(results[0].Errors, ";"), "material "+a.ID) {
+		t.Errorf("expected missing material error, got %v", results[0].Errors)
+	}
+}
+
+// TestFindProvenanceBySubject tests tracing published bytes to a record.
+func TestFindProvenanceBySubject(t *testing.T) {
+	c, _, result := newProvenanceTestExport(t)
+
+	records := c.FindProvenanceBySubject([]byte("\\id GEN derived"))
+	if len(records) != 1 || records[0].ID != result.Provenance.ID {
+		t.Errorf("expected record %s, got %+v", result.Provenance.ID, records)
+	}
+	if len(c.FindProvenanceBySubject([]byte("unrelated"))) != 0 {
+		t.Error("expected no records for unrelated bytes")
+	}
+}
+
+// TestAddProvenanceSameSubject tests that two statements attesting the same
+// subject get distinct records.
+func TestAddProvenanceSameSubject(t *testing.T) {
+	c, _, result := newProvenanceTestExport(t)
+
+	stmt, err := c.GetProvenance(result.Provenance.ID)
+	if err != nil {
+		t.Fatalf("GetProvenance failed: %v", err)
+	}
+	stmt.Predicate.BuildDefinition.ExternalParameters["emit_options"] = map[string]interface{}{"layout": "chapter"}
+	record, err := c.AddProvenance(stmt, "")
+	if err != nil {
+		t.Fatalf("AddProvenance failed: %v", err)
+	}
+	if record.ID == result.Provenance.ID {
+		t.Fatalf("expected a new record ID, got %s twice", record.ID)
+	}
+	if len(c.Manifest.Provenance) != 2 {
+		t.Errorf("expected 2 provenance records, got %d", len(c.Manifest.Provenance))
+	}
+}
+
+// TestExportDerivedTwice tests that two exports of one artifact to the same
+// format keep separate export records that both verify.
+func TestExportDerivedTwice(t *testing.T) {
+	c, a, first := newProvenanceTestExport(t)
+
+	dir := t.TempDir()
+	t.Cleanup(mockDerivedPlugins(t, dir, []byte(`{"id":"ir"}`), []byte("\\id GEN derived again")))
+	loader, loaderDir := setupTestPluginLoader(t, []string{"format-osis", "format-usfm"})
+	t.Cleanup(func() { os.RemoveAll(loaderDir) })
+
+	second, err := c.ExportDerived(a.ID, DerivedExportOptions{
+		TargetFormat:     "usfm",
+		PluginLoader:     loader,
+		RecordProvenance: true,
+	}, filepath.Join(dir, "out", "derived.usfm"))
+	if err != nil {
+		t.Fatalf("ExportDerived failed: %v", err)
+	}
+
+	if second.ExportID == first.ExportID {
+		t.Fatalf("expected distinct export IDs, got %s twice", first.ExportID)
+	}
+	if len(c.Manifest.Exports) != 2 {
+		t.Errorf("expected 2 export records, got %d", len(c.Manifest.Exports))
+	}
+	for _, v := range c.VerifyProvenance() {
+		if !v.OK() {
+			t.Errorf("expected provenance to verify, got %+v", v)
+		}
+	}
+}
+
+// TestNewDerivedProvenanceRequiresTarget tests validation of the builder.
+func TestNewDerivedProvenanceRequiresTarget(t *testing.T) {
+	if _, err := NewDerivedProvenance(DerivationInputs{Output: []byte("x")}); err == nil {
+		t.Error("expected error without target plugin")
+	}
+}
+
+// TestExportDerivedRecordsOptions tests that emit options are validated,
+// passed to the plugin and recorded in the provenance statement.
+func TestExportDerivedRecordsOptions(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+	a.Detected = &DetectionResult{FormatID: "osis"}
+
+	loader, loaderDir := setupTestPluginLoader(t, []string{"format-osis", "format-usfm"})
+	t.Cleanup(func() { os.RemoveAll(loaderDir) })
+	target, err := loader.GetPlugin("format-usfm")
+	if err != nil {
+		t.Fatalf("GetPlugin failed: %v", err)
+	}
+	target.Manifest.Options = []plugins.OptionSpec{
+		{Name: "markers", Type: plugins.OptionEnum, Values: []string{"full", "minimal"}, Default: "full"},
+	}
+
+	dir := t.TempDir()
+	t.Cleanup(mockDerivedPlugins(t, dir, []byte(`{"id":"ir"}`), []byte("\\id GEN derived")))
+	var sent plugins.Options
+	pluginsExecutePlugin = func(p *plugins.Plugin, req *plugins.IPCRequest) (*plugins.IPCResponse, error) {
+		if req.Command == "emit-native" {
+			sent = plugins.OptionsFromArgs(req.Args)
+		}
+		return &plugins.IPCResponse{Status: "success"}, nil
+	}
+
+	opts := DerivedExportOptions{
+		TargetFormat:     "usfm",
+		PluginLoader:     loader,
+		EmitOptions:      map[string]interface{}{"markers": "bogus"},
+		RecordProvenance: true,
+	}
+	if _, err := c.ExportDerived(a.ID, opts, filepath.Join(dir, "bad.usfm")); err == nil {
+		t.Fatal("expected error for invalid option value")
+	}
+
+	opts.EmitOptions = map[string]interface{}{"markers": "minimal"}
+	result, err := c.ExportDerived(a.ID, opts, filepath.Join(dir, "derived.usfm"))
+	if err != nil {
+		t.Fatalf("ExportDerived failed: %v", err)
+	}
+	if sent.String("markers", "") != "minimal" {
+		t.Errorf("plugin got options %v", sent)
+	}
+
+	stmt, err := c.GetProvenance(result.Provenance.ID)
+	if err != nil {
+		t.Fatalf("GetProvenance failed: %v", err)
+	}
+	emitOpts, ok := stmt.Predicate.BuildDefinition.ExternalParameters["emit_options"].(map[string]interface{})
+	if !ok || emitOpts["markers"] != "minimal" {
+		t.Errorf("emit_options = %v", stmt.Predicate.BuildDefinition.ExternalParameters["emit_options"])
+	}
+	if _, ok := stmt.Predicate.BuildDefinition.ExternalParameters["extract_options"]; ok {
+		t.Error("extract_options should be omitted when empty")
+	}
+}
@@ -0,0 +1,242 @@
+package capsule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// KeyDiff lists the keys added, removed and changed between two manifest maps.
+type KeyDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// Empty returns true if no keys differ.
+func (d KeyDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// ManifestDiff describes how two manifests differ.
+type ManifestDiff struct {
+	Artifacts     KeyDiff `json:"artifacts"`
+	Runs          KeyDiff `json:"runs"`
+	IRExtractions KeyDiff `json:"ir_extractions"`
+	SelfChecks    KeyDiff `json:"self_checks"`
+	Exports       KeyDiff `json:"exports"`
+	Provenance    KeyDiff `json:"provenance"`
+
+	// Encryption is "enabled", "disabled" or "rekeyed" when blob
+	// encryption changed, and empty otherwise.
+	Encryption string `json:"encryption,omitempty"`
+}
+
+// Empty returns true if the manifests have identical contents.
+func (d *ManifestDiff) Empty() bool {
+	return d.Artifacts.Empty() && d.Runs.Empty() && d.IRExtractions.Empty() &&
+		d.SelfChecks.Empty() && d.Exports.Empty() && d.Provenance.Empty() &&
+		d.Encryption == ""
+}
+
+// ManifestHash returns the SHA-256 of the manifest's canonical JSON.
+// This is the value recorded as ParentManifestSHA256 in child revisions.
+func ManifestHash(m *Manifest) (string, error) {
+	data, err := m.ToJSON()
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+	return cas.Hash(data), nil
+}
+
+// DiffManifests compares the artifacts, runs, IR extractions, self-checks,
+// exports, provenance records and encryption of two manifests. Entries are
+// matched by ID and considered changed when the content they reference differs.
+func DiffManifests(a, b *Manifest) *ManifestDiff {
+	return &ManifestDiff{
+		Artifacts: diffKeys(a.Artifacts, b.Artifacts, func(x, y *Artifact) bool {
+			return x.PrimaryBlobSHA256 == y.PrimaryBlobSHA256 && x.Kind == y.Kind
+		}),
+		Runs: diffKeys(a.Runs, b.Runs, func(x, y *Run) bool {
+			return x.Status == y.Status && runTranscript(x) == runTranscript(y)
+		}),
+		IRExtractions: diffKeys(a.IRExtractions, b.IRExtractions, func(x, y *IRRecord) bool {
+			return x.IRBlobSHA256 == y.IRBlobSHA256 && x.LossClass == y.LossClass
+		}),
+		SelfChecks: diffKeys(a.SelfChecks, b.SelfChecks, func(x, y *SelfCheck) bool {
+			return x.Status == y.Status && x.ReportBlobSHA256 == y.ReportBlobSHA256
+		}),
+		Exports: diffKeys(a.Exports, b.Exports, func(x, y *Export) bool {
+			return x.ResultBlobSHA256 == y.ResultBlobSHA256
+		}),
+		Provenance: diffKeys(a.Provenance, b.Provenance, func(x, y *ProvenanceRecord) bool {
+			return x.StatementBlobSHA256 == y.StatementBlobSHA256
+		}),
+		Encryption: diffEncryption(a.Encryption, b.Encryption),
+	}
+}
+
+// diffEncryption describes how blob encryption changed between manifests.
+func diffEncryption(a, b *EncryptionInfo) string {
+	switch {
+	case a == nil && b == nil:
+		return ""
+	case a == nil:
+		return "enabled"
+	case b == nil:
+		return "disabled"
+	case a.Scheme != b.Scheme || strings.Join(a.Recipients, ",") != strings.Join(b.Recipients, ","):
+		return "rekeyed"
+	}
+	return ""
+}
+
+// runTranscript returns the transcript hash of a run, or "" if it has none.
+func runTranscript(r *Run) string {
+	if r.Outputs == nil {
+		return ""
+	}
+	return r.Outputs.TranscriptBlobSHA256
+}
+
+// diffKeys compares two maps by key using equal to detect changed entries.
+func diffKeys[T any](a, b map[string]*T, equal func(x, y *T) bool) KeyDiff {
+	var d KeyDiff
+	for id, x := range a {
+		y, ok := b[id]
+		if !ok {
+			d.Removed = append(d.Removed, id)
+			continue
+		}
+		if !equal(x, y) {
+			d.Changed = append(d.Changed, id)
+		}
+	}
+	for id := range b {
+		if _, ok := a[id]; !ok {
+			d.Added = append(d.Added, id)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+// IsAncestor reports whether manifest a is an ancestor of manifest b,
+// i.e. one of b's revisions was derived from a manifest identical to a.
+func IsAncestor(a, b *Manifest) (bool, error) {
+	hash, err := ManifestHash(a)
+	if err != nil {
+		return false, err
+	}
+	for _, rev := range b.Revisions {
+		if rev.ParentManifestSHA256 == hash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AmendOptions describes who or what is producing a new revision.
+type AmendOptions struct {
+	// Author is the person responsible for the revision.
+	Author string
+
+	// Tool identifies the tool producing the revision.
+	// Defaults to the capsule tool at the current version.
+	Tool *ToolInfo
+
+	// Message is a human description of the revision.
+	Message string
+}
+
+// Amend applies mutate to the capsule and records the result as a new
+// revision. The revision links to the hash of the manifest before the
+// change and records what was added, removed or changed.
+//
+// If mutate returns an error the manifest is restored and no revision is
+// recorded. Blobs stored by mutate remain in the CAS but are unreferenced.
+func (c *Capsule) Amend(opts AmendOptions, mutate func(*Capsule) error) (*Revision, error) {
+	parentData, err := c.Manifest.ToJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+	parent, err := ParseManifest(parentData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot manifest: %w", err)
+	}
+
+	if err := mutate(c); err != nil {
+		c.Manifest = parent
+		return nil, err
+	}
+
+	changes := DiffManifests(parent, c.Manifest)
+	if changes.Empty() {
+		c.Manifest = parent
+		return nil, errors.NewValidation("revision", "amendment made no changes")
+	}
+
+	tool := ToolInfo{Name: "capsule", Version: Version}
+	if opts.Tool != nil {
+		tool = *opts.Tool
+	}
+
+	rev := &Revision{
+		Number:               len(parent.Revisions) + 1,
+		ParentManifestSHA256: cas.Hash(parentData),
+		CreatedAt:            time.Now().UTC().Format(time.RFC3339),
+		Producer: RevisionProducer{
+			Author: opts.Author,
+			Tool:   tool,
+		},
+		Message: opts.Message,
+		Changes: changes,
+	}
+
+	// History is append-only: always rebuild from the parent so mutate
+	// cannot rewrite earlier revisions.
+	c.Manifest.Revisions = append(parent.Revisions, rev)
+
+	// Keep the parent manifest addressable by its hash so earlier
+	// revisions can be materialised (e.g. as OCFL versions).
+	if _, err := c.StoreBlob(parentData, ManifestMIME); err != nil {
+		return nil, fmt.Errorf("failed to store parent manifest: %w", err)
+	}
+
+	return rev, nil
+}
+
+// RevisionManifest returns the manifest JSON as of the given revision
+// number (0 is the original manifest). The current revision is serialized
+// from the in-memory manifest; earlier revisions are read from the parent
+// manifests retained by Amend and fail with a not-found error for
+// revisions made before parents were retained.
+func (c *Capsule) RevisionManifest(number int) ([]byte, error) {
+	current := c.Manifest.CurrentRevision()
+	if number < 0 || number > current {
+		return nil, errors.NewNotFound("revision", fmt.Sprintf("%d", number))
+	}
+	if number == current {
+		return c.Manifest.ToJSON()
+	}
+
+	hash := c.Manifest.Revisions[number].ParentManifestSHA256
+	if _, ok := c.Manifest.Blobs.BySHA256[hash]; !ok {
+		return nil, errors.NewNotFound("revision manifest", fmt.Sprintf("%d", number))
+	}
+	return c.ReadBlob(hash)
+}
+
+// CurrentRevision returns the revision number of the manifest (0 if never amended).
+func (m *Manifest) CurrentRevision() int {
+	if len(m.Revisions) == 0 {
+		return 0
+	}
+	return m.Revisions[len(m.Revisions)-1].Number
+}
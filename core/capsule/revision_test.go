@@ -0,0 +1,232 @@
+package capsule
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+)
+
+// TestAmendRecordsRevision tests that Amend links to the parent manifest and logs changes.
+func TestAmendRecordsRevision(t *testing.T) {
+	c, a, _ := newArchiveTestCapsule(t)
+
+	parentHash, err := ManifestHash(c.Manifest)
+	if err != nil {
+		t.Fatalf("ManifestHash failed: %v", err)
+	}
+
+	rev, err := c.Amend(AmendOptions{Author: "tester", Message: "add transcript"}, func(c *Capsule) error {
+		return c.AddRun(&Run{ID: "run-1", Status: "success"}, []byte(`{"event":"done"}`))
+	})
+	if err != nil {
+		t.Fatalf("Amend failed: %v", err)
+	}
+
+	if rev.Number != 1 {
+		t.Errorf("expected revision 1, got %d", rev.Number)
+	}
+	if rev.ParentManifestSHA256 != parentHash {
+		t.Errorf("parent hash = %s, want %s", rev.ParentManifestSHA256, parentHash)
+	}
+	if rev.Producer.Author != "tester" || rev.Producer.Tool.Name != "capsule" {
+		t.Errorf("unexpected producer: %+v", rev.Producer)
+	}
+	if len(rev.Changes.Runs.Added) != 1 || rev.Changes.Runs.Added[0] != "run-1" {
+		t.Errorf("expected run-1 added, got %+v", rev.Changes.Runs)
+	}
+	if len(rev.Changes.Artifacts.Added) != 0 {
+		t.Errorf("expected no artifact changes, got %+v", rev.Changes.Artifacts)
+	}
+	if c.Manifest.CurrentRevision() != 1 {
+		t.Errorf("CurrentRevision = %d, want 1", c.Manifest.CurrentRevision())
+	}
+	if _, ok := c.Manifest.Artifacts[a.ID]; !ok {
+		t.Error("existing artifacts should be preserved")
+	}
+}
+
+// TestAmendChain tests that successive amendments form an append-only chain.
+func TestAmendChain(t *testing.T) {
+	c, _, _ := newArchiveTestCapsule(t)
+	dir := t.TempDir()
+
+	for i, name := range []string{"one.txt", "two.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		before, _ := ManifestHash(c.Manifest)
+		rev, err := c.Amend(AmendOptions{}, func(c *Capsule) error {
+			_, err := c.IngestFile(path)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("Amend %d failed: %v", i, err)
+		}
+		if rev.Number != i+1 {
+			t.Errorf("revision number = %d, want %d", rev.Number, i+1)
+		}
+		if rev.ParentManifestSHA256 != before {
+			t.Errorf("revision %d parent hash mismatch", rev.Number)
+		}
+	}
+
+	if len(c.Manifest.Revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(c.Manifest.Revisions))
+	}
+}
+
+// TestAmendErrorRestoresManifest tests that a failed mutation leaves no trace.
+func TestAmendErrorRestoresManifest(t *testing.T) {
+	c, _, _ := newArchiveTestCapsule(t)
+	before, _ := ManifestHash(c.Manifest)
+
+	wantErr := errors.New("boom")
+	_, err := c.Amend(AmendOptions{}, func(c *Capsule) error {
+		c.Manifest.Artifacts["bogus"] = &Artifact{ID: "bogus"}
+		return wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("expected mutate error, got %v", err)
+	}
+
+	after, _ := ManifestHash(c.Manifest)
+	if before != after {
+		t.Error("manifest should be restored after failed amendment")
+	}
+}
+
+// TestAmendNoChanges tests that an empty amendment is rejected.
+func TestAmendNoChanges(t *testing.T) {
+	c, _, _ := newArchiveTestCapsule(t)
+	if _, err := c.Amend(AmendOptions{}, func(*Capsule) error { return nil }); err == nil {
+		t.Error("expected error for amendment without changes")
+	}
+	if len(c.Manifest.Revisions) != 0 {
+		t.Error("no revision should be recorded")
+	}
+}
+
+// TestAmendSurvivesPack tests that revision history is preserved through pack/unpack.
+func TestAmendSurvivesPack(t *testing.T) {
+	c, _, _ := newArchiveTestCapsule(t)
+	original, err := ParseManifest(mustJSON(t, c.Manifest))
+	if err != nil {
+		t.Fatalf("failed to copy manifest: %v", err)
+	}
+
+	if _, err := c.Amend(AmendOptions{Message: "transcript"}, func(c *Capsule) error {
+		return c.AddRun(&Run{ID: "run-1"}, []byte("{}"))
+	}); err != nil {
+		t.Fatalf("Amend failed: %v", err)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "rev.capsule.tar.xz")
+	if err := c.Pack(archivePath); err != nil {
+		t.Fatalf("failed to pack: %v", err)
+	}
+	unpacked, err := Unpack(archivePath, t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to unpack: %v", err)
+	}
+
+	if unpacked.Manifest.CurrentRevision() != 1 {
+		t.Errorf("CurrentRevision = %d, want 1", unpacked.Manifest.CurrentRevision())
+	}
+	ok, err := IsAncestor(original, unpacked.Manifest)
+	if err != nil {
+		t.Fatalf("IsAncestor failed: %v", err)
+	}
+	if !ok {
+		t.Error("original manifest should be an ancestor of the amended one")
+	}
+}
+
+// TestDiffManifests tests detection of added, removed and changed entries.
+func TestDiffManifests(t *testing.T) {
+	a := NewManifest()
+	b := NewManifest()
+
+	a.Artifacts["same"] = &Artifact{ID: "same", PrimaryBlobSHA256: "aa"}
+	b.Artifacts["same"] = &Artifact{ID: "same", PrimaryBlobSHA256: "aa"}
+	a.Artifacts["changed"] = &Artifact{ID: "changed", PrimaryBlobSHA256: "aa"}
+	b.Artifacts["changed"] = &Artifact{ID: "changed", PrimaryBlobSHA256: "bb"}
+	a.Artifacts["removed"] = &Artifact{ID: "removed"}
+	b.Artifacts["added"] = &Artifact{ID: "added"}
+
+	a.Runs["run"] = &Run{ID: "run", Outputs: &RunOutputs{TranscriptBlobSHA256: "t1"}}
+	b.Runs["run"] = &Run{ID: "run", Outputs: &RunOutputs{TranscriptBlobSHA256: "t2"}}
+
+	b.IRExtractions = map[string]*IRRecord{"ir-x": {ID: "ir-x"}}
+	a.SelfChecks = map[string]*SelfCheck{"sc": {ID: "sc", Status: "pass"}}
+	b.SelfChecks = map[string]*SelfCheck{"sc": {ID: "sc", Status: "fail"}}
+	b.Provenance = map[string]*ProvenanceRecord{"prov-x": {ID: "prov-x"}}
+
+	d := DiffManifests(a, b)
+	if d.Empty() {
+		t.Fatal("diff should not be empty")
+	}
+	if len(d.Artifacts.Added) != 1 || d.Artifacts.Added[0] != "added" {
+		t.Errorf("Artifacts.Added = %v", d.Artifacts.Added)
+	}
+	if len(d.Artifacts.Removed) != 1 || d.Artifacts.Removed[0] != "removed" {
+		t.Errorf("Artifacts.Removed = %v", d.Artifacts.Removed)
+	}
+	if len(d.Artifacts.Changed) != 1 || d.Artifacts.Changed[0] != "changed" {
+		t.Errorf("Artifacts.Changed = %v", d.Artifacts.Changed)
+	}
+	if len(d.Runs.Changed) != 1 {
+		t.Errorf("Runs.Changed = %v", d.Runs.Changed)
+	}
+	if len(d.IRExtractions.Added) != 1 {
+		t.Errorf("IRExtractions.Added = %v", d.IRExtractions.Added)
+	}
+	if len(d.SelfChecks.Changed) != 1 {
+		t.Errorf("SelfChecks.Changed = %v", d.SelfChecks.Changed)
+	}
+	if len(d.Provenance.Added) != 1 {
+		t.Errorf("Provenance.Added = %v", d.Provenance.Added)
+	}
+
+	if !DiffManifests(a, a).Empty() {
+		t.Error("diff of a manifest with itself should be empty")
+	}
+}
+
+func mustJSON(t *testing.T, m *Manifest) []byte {
+	t.Helper()
+	data, err := m.ToJSON()
+	if err != nil {
+		t.Fatalf("failed to serialize manifest: %v", err)
+	}
+	return data
+}
+
+// TestRevisionManifest tests that Amend retains the parent manifest.
+func TestRevisionManifest(t *testing.T) {
+	c := newRevisedTestCapsule(t)
+
+	parent, err := c.RevisionManifest(0)
+	if err != nil {
+		t.Fatalf("RevisionManifest(0) failed: %v", err)
+	}
+	if cas.Hash(parent) != c.Manifest.Revisions[0].ParentManifestSHA256 {
+		t.Error("retained manifest does not match parent hash")
+	}
+
+	current, err := c.RevisionManifest(1)
+	if err != nil {
+		t.Fatalf("RevisionManifest(1) failed: %v", err)
+	}
+	want, _ := c.Manifest.ToJSON()
+	if string(current) != string(want) {
+		t.Error("current revision should serialize the in-memory manifest")
+	}
+
+	if _, err := c.RevisionManifest(2); err == nil {
+		t.Error("expected error for future revision")
+	}
+}
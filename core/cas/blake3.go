@@ -94,6 +94,21 @@ func (s *Store) createBlake3Pointer(blake3Hash, sha256Hash string) error {
 	return nil
 }
 
+// LinkBlake3 writes the pointer file mapping blake3Hash to sha256Hash,
+// replacing any existing pointer. It is used to rebuild pointers that are
+// missing or point at the wrong blob.
+func (s *Store) LinkBlake3(blake3Hash, sha256Hash string) error {
+	if !isValidHash(blake3Hash) || !isValidHash(sha256Hash) {
+		return ErrInvalidHash
+	}
+
+	pointerPath := filepath.Join(s.root, "blobs", "blake3", blake3Hash[:2], blake3Hash+".json")
+	if err := os.Remove(pointerPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove pointer: %w", err)
+	}
+	return s.createBlake3Pointer(blake3Hash, sha256Hash)
+}
+
 // LookupBlake3 looks up a SHA-256 hash by its corresponding BLAKE3 hash.
 // Returns ErrBlobNotFound if no pointer file exists for the BLAKE3 hash.
 func (s *Store) LookupBlake3(blake3Hash string) (string, error) {
@@ -151,3 +151,38 @@ func hashToHex(b []byte) string {
 	}
 	return string(result)
 }
+
+// TestBlake3LinkReplacesPointer tests rebuilding a pointer that points at
+// the wrong blob.
+func TestBlake3LinkReplacesPointer(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "cas-blake3-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	store, err := NewStore(tempDir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	first, err := store.StoreWithBlake3([]byte("first"))
+	if err != nil {
+		t.Fatalf("failed to store with BLAKE3: %v", err)
+	}
+	second, err := store.StoreWithBlake3([]byte("second"))
+	if err != nil {
+		t.Fatalf("failed to store with BLAKE3: %v", err)
+	}
+
+	if err := store.LinkBlake3(first.BLAKE3, second.SHA256); err != nil {
+		t.Fatalf("LinkBlake3 failed: %v", err)
+	}
+	if got, _ := store.LookupBlake3(first.BLAKE3); got != second.SHA256 {
+		t.Errorf("pointer not replaced: got %s", got)
+	}
+
+	if err := store.LinkBlake3("bad", second.SHA256); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, got %v", err)
+	}
+}
@@ -0,0 +1,866 @@
+// Package conformance runs a standard test suite against a format plugin:
+// detection of positive and negative fixtures, ingest hashing, enumeration,
+// IR validity, emit/re-extract stability, declared versus measured loss,
+// timeout behavior and malformed input handling. When a plugin is both
+// embedded and installed as an external binary, both implementations are
+// tested and their outputs compared.
+package conformance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+	"github.com/FocuswithJustin/JuniperBible/core/reporter"
+	"github.com/FocuswithJustin/JuniperBible/core/selfcheck"
+)
+
+// Implementations of a plugin.
+const (
+	ImplAuto     = "auto"
+	ImplEmbedded = "embedded"
+	ImplExternal = "external"
+)
+
+// DefaultFixtures is the fixture directory used when a kit names none. It
+// holds a subdirectory of sample inputs per format, named after the plugin
+// ID without its kind prefix ("osis" for "format.osis").
+const DefaultFixtures = "testdata/fixtures/inputs"
+
+// StopGrace is how long a plugin may keep running after its deadline
+// before the timeout check fails.
+const StopGrace = 5 * time.Second
+
+// probeDeadline is the deadline of the call made by the timeout check.
+const probeDeadline = time.Millisecond
+
+// Kit is a conformance suite for one plugin.
+type Kit struct {
+	// PluginID is the ID of the plugin under test.
+	PluginID string
+
+	// Manifest is the manifest the plugin's capabilities and declared
+	// loss class are read from.
+	Manifest *plugins.PluginManifest
+
+	// Embedded is true when the embedded implementation is tested.
+	Embedded bool
+
+	// External is the installed plugin whose entrypoint is tested, or nil.
+	External *plugins.Plugin
+
+	// Fixtures is the fixture directory; DefaultFixtures when empty.
+	Fixtures string
+
+	// Positive and Negative override the fixtures the plugin must and must
+	// not detect. By default the positive fixtures are those of the
+	// plugin's format and the negative fixtures those of every other
+	// format, plus the files at the top of the fixture directory.
+	Positive []string
+	Negative []string
+
+	// Timeout bounds each plugin call; plugins.DefaultTimeout when zero.
+	Timeout time.Duration
+}
+
+// New creates a kit for a format plugin, testing its embedded
+// implementation and the external plugin of the same ID in pluginDir when
+// they exist. Capabilities are read from the manifest in pluginDir when
+// there is one. The ID may omit the "format." prefix.
+func New(pluginID, pluginDir string) (*Kit, error) {
+	if plugins.GetEmbeddedPlugin(pluginID) == nil && !strings.Contains(pluginID, ".") {
+		if plugins.GetEmbeddedPlugin("format."+pluginID) != nil {
+			pluginID = "format." + pluginID
+		}
+	}
+
+	k := &Kit{PluginID: pluginID}
+	if ep := plugins.GetEmbeddedPlugin(pluginID); ep != nil && ep.Format != nil {
+		k.Embedded = true
+		k.Manifest = ep.Manifest
+	}
+	if pluginDir != "" {
+		p, err := plugins.FindPlugin(pluginDir, pluginID)
+		if err != nil {
+			return nil, err
+		}
+		// The installed manifest declares the plugin's IR support even
+		// when its entrypoint has not been built
+		if p != nil {
+			k.PluginID = p.Manifest.PluginID
+			k.Manifest = p.Manifest
+			if _, err := os.Stat(p.EntrypointPath()); err == nil {
+				k.External = p
+			}
+		}
+	}
+
+	if k.Manifest == nil {
+		return nil, fmt.Errorf("plugin %s not found", pluginID)
+	}
+	if k.Manifest.Kind != "format" {
+		return nil, fmt.Errorf("plugin %s is a %s plugin; the conformance suite tests format plugins", k.PluginID, k.Manifest.Kind)
+	}
+	return k, nil
+}
+
+// Select restricts the kit to one implementation: ImplEmbedded,
+// ImplExternal or ImplAuto for every implementation found.
+func (k *Kit) Select(impl string) error {
+	switch impl {
+	case ImplAuto, "":
+	case ImplEmbedded:
+		if !k.Embedded {
+			return fmt.Errorf("plugin %s has no embedded implementation", k.PluginID)
+		}
+		k.External = nil
+	case ImplExternal:
+		if k.External == nil {
+			return fmt.Errorf("plugin %s has no external implementation", k.PluginID)
+		}
+		k.Embedded = false
+	default:
+		return fmt.Errorf("unknown implementation %q (want auto, embedded or external)", impl)
+	}
+	return nil
+}
+
+// Run runs the suite against each implementation of the plugin and, when
+// there are two, compares their outputs. Plugin failures are reported as
+// failed cases; an error is returned only when the suite cannot run.
+func (k *Kit) Run(ctx context.Context) (*reporter.Suite, error) {
+	positive, negative, err := k.fixtures()
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "capsule-conformance-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	suite := reporter.NewSuite("conformance " + k.PluginID)
+	var runs []*run
+	for _, impl := range k.impls() {
+		r := &run{
+			kit:     k,
+			impl:    impl,
+			tmp:     filepath.Join(tmp, impl),
+			suite:   suite,
+			outputs: make(map[string]string),
+		}
+		if err := os.MkdirAll(r.tmp, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create temp dir: %w", err)
+		}
+		r.checks(ctx, positive, negative)
+		runs = append(runs, r)
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("plugin %s has no implementation to test", k.PluginID)
+	}
+	if len(runs) == 2 {
+		k.compare(suite, runs[0], runs[1])
+	}
+	return suite, nil
+}
+
+// impls returns the implementations under test.
+func (k *Kit) impls() []string {
+	var impls []string
+	if k.Embedded {
+		impls = append(impls, ImplEmbedded)
+	}
+	if k.External != nil {
+		impls = append(impls, ImplExternal)
+	}
+	return impls
+}
+
+// plugin returns the plugin under test as the loader would describe it.
+func (k *Kit) plugin() *plugins.Plugin {
+	return &plugins.Plugin{Manifest: k.Manifest, Path: "(embedded)"}
+}
+
+// fixtures returns the positive and negative fixtures.
+func (k *Kit) fixtures() (positive, negative []string, err error) {
+	root := k.Fixtures
+	if root == "" {
+		root = DefaultFixtures
+	}
+	positive, negative = k.Positive, k.Negative
+	format := strings.TrimPrefix(k.PluginID, "format.")
+
+	if positive == nil {
+		positive, err = formatFixtures(filepath.Join(root, format))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if negative == nil {
+		entries, err := os.ReadDir(root)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("failed to read fixtures: %w", err)
+		}
+		for _, e := range entries {
+			path := filepath.Join(root, e.Name())
+			switch {
+			case !e.IsDir():
+				negative = append(negative, path)
+			case e.Name() != format:
+				files, err := formatFixtures(path)
+				if err != nil {
+					return nil, nil, err
+				}
+				negative = append(negative, files...)
+			}
+		}
+	}
+	return positive, negative, nil
+}
+
+// formatFixtures returns the fixtures of a format: the files in its
+// directory, or the directory itself when it holds only directories, as
+// for formats read from a directory tree.
+func formatFixtures(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	if len(files) == 0 && len(entries) > 0 {
+		return []string{dir}, nil
+	}
+	return files, nil
+}
+
+// run is the suite run against one implementation.
+type run struct {
+	kit   *Kit
+	impl  string
+	tmp   string
+	suite *reporter.Suite
+	seq   int
+
+	// outputs holds the normalized result of each call on a positive
+	// fixture, keyed by command and fixture, for comparing implementations.
+	outputs map[string]string
+}
+
+// call sends a request to the implementation under test.
+func (r *run) call(ctx context.Context, req *plugins.IPCRequest) (*plugins.IPCResponse, error) {
+	timeout := r.kit.Timeout
+	if timeout <= 0 {
+		timeout = plugins.DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if r.impl == ImplExternal {
+		return plugins.ExecuteExternalPluginContext(ctx, r.kit.External, req)
+	}
+	resp, err := plugins.ExecuteEmbeddedPluginContext(ctx, r.kit.PluginID, req)
+	if resp == nil && err == nil {
+		err = fmt.Errorf("plugin %s is not embedded", r.kit.PluginID)
+	}
+	return resp, err
+}
+
+// dir creates a fresh output directory.
+func (r *run) dir(name string) string {
+	r.seq++
+	dir := filepath.Join(r.tmp, fmt.Sprintf("%03d-%s", r.seq, name))
+	os.MkdirAll(dir, 0755)
+	return dir
+}
+
+// add records a case.
+func (r *run) add(name string, c reporter.Case) {
+	c.Name = name
+	c.ClassName = "conformance." + r.kit.PluginID + "." + r.impl
+	if c.Status == "" {
+		c.Status = reporter.StatusPass
+	}
+	r.suite.Add(c)
+}
+
+// checks runs every check of the suite.
+func (r *run) checks(ctx context.Context, positive, negative []string) {
+	root := r.kit.Fixtures
+	if root == "" {
+		root = DefaultFixtures
+	}
+	if len(positive) == 0 {
+		r.add("fixtures", reporter.Case{
+			Status:  reporter.StatusSkipped,
+			Message: fmt.Sprintf("no fixtures for %s in %s", r.kit.PluginID, root),
+		})
+	}
+
+	p := r.kit.plugin()
+	for _, f := range positive {
+		name := fixtureName(root, f)
+		r.add("detect positive "+name, r.detect(ctx, f, true))
+		r.add("ingest "+name, r.ingest(ctx, f))
+		r.add("enumerate "+name, r.enumerate(ctx, f))
+
+		if !p.CanExtractIR() {
+			continue
+		}
+		c, extracted := r.extract(ctx, f, "extract-ir "+f)
+		r.add("extract-ir "+name, c)
+		if extracted == nil {
+			continue
+		}
+		if p.CanEmitIR() {
+			c, roundtrip := r.roundtrip(ctx, f, extracted)
+			r.add("emit-native roundtrip "+name, c)
+			if roundtrip != nil {
+				extracted = roundtrip
+			}
+		}
+		r.add("loss class "+name, r.loss(extracted))
+	}
+	for _, f := range negative {
+		r.add("detect negative "+fixtureName(root, f), r.detect(ctx, f, false))
+	}
+
+	if len(positive) > 0 {
+		r.add("timeout", r.timeout(ctx, positive[0]))
+	}
+	for _, input := range r.malformedInputs(positive) {
+		r.add("malformed input "+input.name, r.malformed(ctx, input))
+	}
+}
+
+// fixtureName names a fixture by its path in the fixture directory.
+func fixtureName(root, path string) string {
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.Base(path)
+}
+
+// errorCase returns a case for a call that failed without a response.
+func errorCase(err error) reporter.Case {
+	return reporter.Case{Status: reporter.StatusError, Message: err.Error()}
+}
+
+// failCase returns a failed case.
+func failCase(format string, args ...interface{}) reporter.Case {
+	return reporter.Case{Status: reporter.StatusFail, Message: fmt.Sprintf(format, args...)}
+}
+
+// detect checks that a fixture is, or is not, detected.
+func (r *run) detect(ctx context.Context, path string, want bool) reporter.Case {
+	resp, err := r.call(ctx, plugins.NewDetectRequest(path))
+	if err != nil {
+		return errorCase(err)
+	}
+	result, err := plugins.ParseDetectResult(resp)
+	if err != nil {
+		return failCase("%v", err)
+	}
+	if want {
+		r.record("detect "+path, result)
+	}
+	switch {
+	case want && !result.Detected:
+		return failCase("not detected: %s", result.Reason)
+	case !want && result.Detected:
+		return failCase("detected as %s: %s", result.Format, result.Reason)
+	}
+	return reporter.Case{Output: result.Reason}
+}
+
+// ingest checks that the blob stored for a fixture has the reported hash
+// and size and, for a file, matches the file.
+func (r *run) ingest(ctx context.Context, path string) reporter.Case {
+	out := r.dir("ingest")
+	resp, err := r.call(ctx, plugins.NewIngestRequest(path, out))
+	if err != nil {
+		return errorCase(err)
+	}
+	result, err := plugins.ParseIngestResult(resp)
+	if err != nil {
+		return failCase("%v", err)
+	}
+	r.record("ingest "+path, result)
+
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		sum, err := hashPath(path)
+		if err != nil {
+			return errorCase(err)
+		}
+		if result.BlobSHA256 != sum {
+			return failCase("blob_sha256 is %s, the file hashes to %s", result.BlobSHA256, sum)
+		}
+		if result.SizeBytes != info.Size() {
+			return failCase("size_bytes is %d, the file is %d bytes", result.SizeBytes, info.Size())
+		}
+	}
+
+	blob, size, err := findBlob(out, result.BlobSHA256)
+	if err != nil {
+		return errorCase(err)
+	}
+	if blob == "" {
+		return failCase("no blob with sha256 %s stored in the output directory", result.BlobSHA256)
+	}
+	if size != result.SizeBytes {
+		return failCase("size_bytes is %d, the stored blob is %d bytes", result.SizeBytes, size)
+	}
+	return reporter.Case{Output: fmt.Sprintf("blob %s (%d bytes)", result.BlobSHA256, size)}
+}
+
+// findBlob returns the path and size of a file under dir with the given
+// SHA-256, or an empty path if there is none.
+func findBlob(dir, sum string) (string, int64, error) {
+	var found string
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || found != "" {
+			return err
+		}
+		h, err := hashPath(path)
+		if err != nil {
+			return err
+		}
+		if h == sum {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			found, size = path, info.Size()
+		}
+		return nil
+	})
+	return found, size, err
+}
+
+// enumerate checks that a fixture lists at least one named entry.
+func (r *run) enumerate(ctx context.Context, path string) reporter.Case {
+	resp, err := r.call(ctx, plugins.NewEnumerateRequest(path))
+	if err != nil {
+		return errorCase(err)
+	}
+	result, err := plugins.ParseEnumerateResult(resp)
+	if err != nil {
+		return failCase("%v", err)
+	}
+	r.record("enumerate "+path, result)
+	if len(result.Entries) == 0 {
+		return failCase("no entries")
+	}
+	for i, e := range result.Entries {
+		if e.Path == "" {
+			return failCase("entry %d has no path", i)
+		}
+	}
+	return reporter.Case{Output: fmt.Sprintf("%d entries", len(result.Entries))}
+}
+
+// extraction is IR extracted from a fixture and the losses measured on the
+// way.
+type extraction struct {
+	irPath string
+	corpus *ir.Corpus
+	loss   ir.LossClass
+	reason string
+}
+
+// extract checks that the IR extracted from path is valid, recording the
+// result under key.
+func (r *run) extract(ctx context.Context, path, key string) (reporter.Case, *extraction) {
+	resp, err := r.call(ctx, plugins.NewExtractIRRequest(path, r.dir("ir")))
+	if err != nil {
+		return errorCase(err), nil
+	}
+	result, err := plugins.ParseExtractIRResult(resp)
+	if err != nil {
+		return failCase("%v", err), nil
+	}
+	corpus, msg := readIR(result.IRPath)
+	if msg != "" {
+		return failCase("%s", msg), nil
+	}
+	r.record(key, result)
+
+	class, err := reportedLoss(result.LossClass, result.LossReport)
+	if err != nil {
+		return failCase("%v", err), nil
+	}
+	return reporter.Case{Output: fmt.Sprintf("%d documents", len(corpus.Documents))},
+		&extraction{irPath: result.IRPath, corpus: corpus, loss: class, reason: "extract-ir reported " + string(class)}
+}
+
+// readIR reads and validates an IR file, describing the problem if any.
+func readIR(path string) (*ir.Corpus, string) {
+	if path == "" {
+		return nil, "no ir_path in result"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Sprintf("failed to read IR: %v", err)
+	}
+	var corpus ir.Corpus
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		return nil, fmt.Sprintf("IR is not a corpus: %v", err)
+	}
+	if errs := ir.Validate(&corpus); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, e := range errs {
+			msgs[i] = e.Error()
+		}
+		return nil, fmt.Sprintf("invalid IR: %s", strings.Join(msgs, "; "))
+	}
+	return &corpus, ""
+}
+
+// reportedLoss returns the loss class a plugin reported, L0 when it
+// reported none.
+func reportedLoss(class string, report *plugins.LossReportIPC) (ir.LossClass, error) {
+	if class == "" && report != nil {
+		class = report.LossClass
+	}
+	if class == "" {
+		return ir.LossL0, nil
+	}
+	if !ir.LossClass(class).IsValid() {
+		return "", fmt.Errorf("invalid loss class %q", class)
+	}
+	return ir.LossClass(class), nil
+}
+
+// emit emits IR and returns the output path and reported loss class.
+func (r *run) emit(ctx context.Context, irPath string) (string, ir.LossClass, error) {
+	resp, err := r.call(ctx, plugins.NewEmitNativeRequest(irPath, r.dir("native")))
+	if err != nil {
+		return "", "", err
+	}
+	result, err := plugins.ParseEmitNativeResult(resp)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := os.Stat(result.OutputPath); err != nil {
+		return "", "", fmt.Errorf("emitted output: %w", err)
+	}
+	class, err := reportedLoss(result.LossClass, result.LossReport)
+	return result.OutputPath, class, err
+}
+
+// roundtrip emits the extracted IR, re-extracts the output and emits it
+// again. The second emit must reproduce the first byte for byte. Once the
+// output is re-extracted, the returned extraction carries the worst loss
+// seen on the way, even if the roundtrip is unstable.
+func (r *run) roundtrip(ctx context.Context, path string, first *extraction) (reporter.Case, *extraction) {
+	native, emitLoss, err := r.emit(ctx, first.irPath)
+	if err != nil {
+		return failCase("emit-native: %v", err), nil
+	}
+	c, second := r.extract(ctx, native, "re-extract-ir "+path)
+	if second == nil {
+		c.Message = "re-extract-ir: " + c.Message
+		return c, nil
+	}
+	measured := *first
+	for _, step := range []struct {
+		class  ir.LossClass
+		reason string
+	}{
+		{emitLoss, "emit-native reported " + string(emitLoss)},
+		{second.loss, "re-extract-ir reported " + string(second.loss)},
+	} {
+		if step.class.Level() > measured.loss.Level() {
+			measured.loss, measured.reason = step.class, step.reason
+		}
+	}
+	if measured.loss == ir.LossL0 && !sameDocuments(first.corpus, second.corpus) {
+		measured.loss, measured.reason = ir.LossL1, "re-extracted IR differs from the original"
+	}
+
+	again, _, err := r.emit(ctx, second.irPath)
+	if err != nil {
+		return failCase("second emit-native: %v", err), &measured
+	}
+	h1, err := hashPath(native)
+	if err != nil {
+		return errorCase(err), &measured
+	}
+	h2, err := hashPath(again)
+	if err != nil {
+		return errorCase(err), &measured
+	}
+	r.outputs["emit-native "+path] = h1
+	if h1 != h2 {
+		return reporter.Case{
+			Status:  reporter.StatusFail,
+			Message: "emitted output changed after re-extracting it",
+			Output:  fmt.Sprintf("first emit sha256 %s, second emit sha256 %s", h1, h2),
+		}, &measured
+	}
+	return reporter.Case{Output: "native sha256 " + h1}, &measured
+}
+
+// sameDocuments reports whether two corpora hold the same documents.
+func sameDocuments(a, b *ir.Corpus) bool {
+	if len(a.Documents) != len(b.Documents) {
+		return false
+	}
+	for i := range a.Documents {
+		ha, errA := ir.HashDocument(a.Documents[i])
+		hb, errB := ir.HashDocument(b.Documents[i])
+		if errA != nil || errB != nil || ha != hb {
+			return false
+		}
+	}
+	return true
+}
+
+// loss checks the measured loss class against the declared one.
+func (r *run) loss(measured *extraction) reporter.Case {
+	declared := selfcheck.DeclaredLossClass(r.kit.plugin())
+	output := fmt.Sprintf("measured %s, declared %s", measured.loss, declared)
+	if measured.loss.Level() > declared.Level() {
+		return reporter.Case{
+			Status:  reporter.StatusFail,
+			Message: fmt.Sprintf("loss exceeds the declared %s: %s", declared, measured.reason),
+			Output:  output,
+			Findings: []reporter.Finding{{
+				RuleID:  "LOSS_EXCEEDS_DECLARED",
+				Level:   reporter.LevelError,
+				Message: fmt.Sprintf("%s: measured %s, declared %s", r.kit.PluginID, measured.loss, declared),
+			}},
+		}
+	}
+	return reporter.Case{Output: output}
+}
+
+// timeout checks that a call is abandoned promptly once its deadline
+// passes and that the plugin still answers afterwards.
+func (r *run) timeout(ctx context.Context, path string) reporter.Case {
+	req := plugins.NewDetectRequest(path)
+	if r.kit.plugin().CanExtractIR() {
+		req = plugins.NewExtractIRRequest(path, r.dir("timeout"))
+	}
+	probe, cancel := context.WithTimeout(ctx, probeDeadline)
+	start := time.Now()
+	_, err := r.call(probe, req)
+	elapsed := time.Since(start)
+	cancel()
+
+	if elapsed > probeDeadline+StopGrace {
+		return failCase("%s ran for %s after a %s deadline", req.Command, elapsed.Round(time.Millisecond), probeDeadline)
+	}
+	output := fmt.Sprintf("%s stopped after %s", req.Command, elapsed.Round(time.Millisecond))
+	if err == nil {
+		output = fmt.Sprintf("%s completed in %s, before its deadline took effect", req.Command, elapsed.Round(time.Millisecond))
+	}
+
+	resp, err := r.call(ctx, plugins.NewDetectRequest(path))
+	if err != nil {
+		return failCase("plugin did not answer after a timeout: %v", err)
+	}
+	if _, err := plugins.ParseDetectResult(resp); err != nil {
+		return failCase("plugin did not answer after a timeout: %v", err)
+	}
+	return reporter.Case{Output: output}
+}
+
+// malformedInput is an input the plugin must reject without crashing.
+type malformedInput struct {
+	name    string
+	path    string
+	missing bool
+}
+
+// malformedInputs writes random bytes and a truncated copy of the first
+// positive fixture, named like it so detection by extension passes them
+// on to the parser, and names a path that does not exist.
+func (r *run) malformedInputs(positive []string) []malformedInput {
+	ext := ".bin"
+	var sample []byte
+	if len(positive) > 0 {
+		if info, err := os.Stat(positive[0]); err == nil && !info.IsDir() {
+			ext = filepath.Ext(positive[0])
+			sample, _ = os.ReadFile(positive[0])
+		}
+	}
+
+	dir := r.dir("malformed")
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+	inputs := []malformedInput{{name: "random bytes", path: filepath.Join(dir, "random"+ext)}}
+	os.WriteFile(inputs[0].path, random, 0644)
+	if len(sample) > 1 {
+		truncated := malformedInput{name: "truncated fixture", path: filepath.Join(dir, "truncated"+ext)}
+		os.WriteFile(truncated.path, sample[:len(sample)/2], 0644)
+		inputs = append(inputs, truncated)
+	}
+	return append(inputs, malformedInput{name: "missing file", path: filepath.Join(dir, "missing"+ext), missing: true})
+}
+
+// malformed checks that every command answers a malformed input with an
+// error or a valid result rather than crashing, and that commands reading
+// a missing file report an error.
+func (r *run) malformed(ctx context.Context, input malformedInput) reporter.Case {
+	commands := []*plugins.IPCRequest{
+		plugins.NewDetectRequest(input.path),
+		plugins.NewIngestRequest(input.path, r.dir("malformed-ingest")),
+		plugins.NewEnumerateRequest(input.path),
+	}
+	if r.kit.plugin().CanExtractIR() {
+		commands = append(commands, plugins.NewExtractIRRequest(input.path, r.dir("malformed-ir")))
+	}
+
+	var failures, lines []string
+	for _, req := range commands {
+		resp, err := r.call(ctx, req)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", req.Command, err))
+			continue
+		}
+		if resp.Status == "error" {
+			lines = append(lines, fmt.Sprintf("%s: error: %s", req.Command, resp.Error))
+			continue
+		}
+		lines = append(lines, req.Command+": ok")
+
+		switch req.Command {
+		case "detect":
+			result, err := plugins.ParseDetectResult(resp)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("detect: %v", err))
+			} else if input.missing && result.Detected {
+				failures = append(failures, "detect: missing file detected")
+			}
+		case "extract-ir":
+			if input.missing {
+				failures = append(failures, "extract-ir: no error for a missing file")
+				break
+			}
+			result, err := plugins.ParseExtractIRResult(resp)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("extract-ir: %v", err))
+			} else if _, msg := readIR(result.IRPath); msg != "" {
+				failures = append(failures, "extract-ir: "+msg)
+			}
+		default:
+			if input.missing {
+				failures = append(failures, req.Command+": no error for a missing file")
+			}
+		}
+	}
+
+	c := reporter.Case{Output: strings.Join(lines, "\n")}
+	if len(failures) > 0 {
+		c.Status = reporter.StatusFail
+		c.Message = strings.Join(failures, "; ")
+	}
+	return c
+}
+
+// record keeps the normalized result of a call on a positive fixture,
+// keyed by command and fixture. IR is compared by content, since each
+// implementation writes to its own directory.
+func (r *run) record(key string, result interface{}) {
+	if x, ok := result.(*plugins.ExtractIRResult); ok {
+		normalized := *x
+		if sum, err := hashPath(x.IRPath); err == nil {
+			normalized.IRPath = "sha256:" + sum
+		}
+		result = &normalized
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	r.outputs[key] = string(data)
+}
+
+// compare adds a case per call whose result differs between two
+// implementations.
+func (k *Kit) compare(suite *reporter.Suite, a, b *run) {
+	keys := make(map[string]bool)
+	for key := range a.outputs {
+		keys[key] = true
+	}
+	for key := range b.outputs {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	root := k.Fixtures
+	if root == "" {
+		root = DefaultFixtures
+	}
+	for _, key := range sorted {
+		command, path, _ := strings.Cut(key, " ")
+		c := reporter.Case{
+			Name:      fmt.Sprintf("%s vs %s: %s %s", a.impl, b.impl, command, fixtureName(root, path)),
+			ClassName: "conformance." + k.PluginID + ".compare",
+			Status:    reporter.StatusPass,
+		}
+		outA, okA := a.outputs[key]
+		outB, okB := b.outputs[key]
+		switch {
+		case !okA || !okB:
+			c.Status = reporter.StatusFail
+			c.Message = "only one implementation succeeded"
+		case outA != outB:
+			c.Status = reporter.StatusFail
+			c.Message = "implementations differ"
+			c.Output = fmt.Sprintf("%s: %s\n%s: %s", a.impl, outA, b.impl, outB)
+		}
+		suite.Add(c)
+	}
+}
+
+// hashPath returns the SHA-256 of a file, or of the relative paths and
+// contents of the files in a directory.
+func hashPath(path string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if p != path {
+			rel, _ := filepath.Rel(path, p)
+			fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,336 @@
+package conformance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+	"github.com/FocuswithJustin/JuniperBible/core/reporter"
+)
+
+// externalPluginEnv makes the test binary serve one request as the
+// external implementation of the test plugins. Its value is the
+// behavior: "same" or "differ".
+const externalPluginEnv = "CONFORMANCE_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	plugins.RegisterEmbeddedPlugin(linesPlugin("format.lines", &linesHandler{}))
+	plugins.RegisterEmbeddedPlugin(linesPlugin("format.lossy", &linesHandler{dropLast: true}))
+	if mode := os.Getenv(externalPluginEnv); mode != "" {
+		serveExternal(mode == "differ")
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// serveExternal answers one request on stdin with the embedded handler.
+func serveExternal(differ bool) {
+	var req plugins.IPCRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		os.Exit(1)
+	}
+	resp, err := plugins.ExecuteEmbeddedPlugin(os.Args[0][strings.LastIndex(os.Args[0], "/")+1:], &req)
+	if err != nil {
+		os.Exit(1)
+	}
+	if differ && req.Command == "enumerate" && resp.Status == "ok" {
+		resp.Result = &plugins.EnumerateResult{Entries: []plugins.EnumerateEntry{{Path: "other"}}}
+	}
+	json.NewEncoder(os.Stdout).Encode(resp)
+}
+
+func linesPlugin(id string, h *linesHandler) *plugins.EmbeddedPlugin {
+	return &plugins.EmbeddedPlugin{
+		Manifest: &plugins.PluginManifest{
+			PluginID:   id,
+			Version:    "1.0.0",
+			Kind:       "format",
+			Entrypoint: id,
+			IRSupport: &plugins.IRCapabilities{
+				CanExtract: true,
+				CanEmit:    true,
+				LossClass:  "L0",
+			},
+		},
+		Format: h,
+	}
+}
+
+// linesHandler reads files of a "LINES" header followed by one verse per
+// line. With dropLast it loses the last verse when emitting.
+type linesHandler struct {
+	dropLast bool
+}
+
+const linesHeader = "LINES\n"
+
+func (h *linesHandler) Detect(path string) (*plugins.DetectResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasPrefix(string(data), linesHeader) {
+		return &plugins.DetectResult{Detected: false, Reason: "no LINES header"}, nil
+	}
+	return &plugins.DetectResult{Detected: true, Format: "lines", Reason: "LINES header"}, nil
+}
+
+func (h *linesHandler) Ingest(path, outputDir string) (*plugins.IngestResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if err := os.WriteFile(filepath.Join(outputDir, hash), data, 0644); err != nil {
+		return nil, err
+	}
+	return &plugins.IngestResult{ArtifactID: "lines", BlobSHA256: hash, SizeBytes: int64(len(data))}, nil
+}
+
+func (h *linesHandler) Enumerate(path string) (*plugins.EnumerateResult, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &plugins.EnumerateResult{Entries: []plugins.EnumerateEntry{{Path: filepath.Base(path), SizeBytes: info.Size()}}}, nil
+}
+
+func (h *linesHandler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	text, ok := strings.CutPrefix(string(data), linesHeader)
+	if !ok {
+		return nil, fmt.Errorf("no LINES header")
+	}
+	doc := &ir.Document{ID: "lines"}
+	for i, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		doc.ContentBlocks = append(doc.ContentBlocks, &ir.ContentBlock{ID: fmt.Sprintf("cb-%d", i), Sequence: i, Text: line})
+	}
+	data, err = json.Marshal(&ir.Corpus{ID: "lines", Version: "1.0.0", Documents: []*ir.Document{doc}})
+	if err != nil {
+		return nil, err
+	}
+	irPath := filepath.Join(outputDir, "lines.ir.json")
+	if err := os.WriteFile(irPath, data, 0644); err != nil {
+		return nil, err
+	}
+	return &plugins.ExtractIRResult{IRPath: irPath, LossClass: "L0"}, nil
+}
+
+func (h *linesHandler) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResult, error) {
+	data, err := os.ReadFile(irPath)
+	if err != nil {
+		return nil, err
+	}
+	var corpus ir.Corpus
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.WriteString(linesHeader)
+	blocks := corpus.Documents[0].ContentBlocks
+	if h.dropLast && len(blocks) > 1 {
+		blocks = blocks[:len(blocks)-1]
+	}
+	for _, cb := range blocks {
+		sb.WriteString(cb.Text + "\n")
+	}
+	outPath := filepath.Join(outputDir, "lines.txt")
+	if err := os.WriteFile(outPath, []byte(sb.String()), 0644); err != nil {
+		return nil, err
+	}
+	return &plugins.EmitNativeResult{OutputPath: outPath, Format: "lines", LossClass: "L0"}, nil
+}
+
+// writeFixtures creates a fixture directory with a lines sample and a
+// sample of another format.
+func writeFixtures(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"lines/sample.lines": linesHeader + "In the beginning\nAnd the earth\nAnd God said\n",
+		"other/sample.txt":   "plain text\n",
+		"notes.txt":          "not a fixture of any format\n",
+	} {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// externalPlugin returns an external implementation of a test plugin whose
+// entrypoint is the test binary.
+func externalPlugin(t *testing.T, id, mode string) *plugins.Plugin {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("external test plugin needs symlinks")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(exe, filepath.Join(dir, id)); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(externalPluginEnv, mode)
+	return &plugins.Plugin{Manifest: plugins.GetEmbeddedPlugin(id).Manifest, Path: dir}
+}
+
+// failed returns the names of the cases that did not pass or skip.
+func failed(suite *reporter.Suite) []string {
+	var names []string
+	for _, c := range suite.Cases {
+		if c.Status == reporter.StatusFail || c.Status == reporter.StatusError {
+			names = append(names, c.Name+": "+c.Message)
+		}
+	}
+	return names
+}
+
+func TestRunEmbedded(t *testing.T) {
+	k, err := New("lines", "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	k.Fixtures = writeFixtures(t)
+	suite, err := k.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if names := failed(suite); len(names) > 0 {
+		t.Errorf("unexpected failures:\n%s", strings.Join(names, "\n"))
+	}
+
+	want := []string{
+		"detect positive lines/sample.lines",
+		"ingest lines/sample.lines",
+		"enumerate lines/sample.lines",
+		"extract-ir lines/sample.lines",
+		"emit-native roundtrip lines/sample.lines",
+		"loss class lines/sample.lines",
+		"detect negative notes.txt",
+		"detect negative other/sample.txt",
+		"timeout",
+		"malformed input random bytes",
+		"malformed input truncated fixture",
+		"malformed input missing file",
+	}
+	got := make(map[string]bool)
+	for _, c := range suite.Cases {
+		got[c.Name] = true
+	}
+	for _, name := range want {
+		if !got[name] {
+			t.Errorf("missing case %q", name)
+		}
+	}
+}
+
+func TestRunReportsFailures(t *testing.T) {
+	k, err := New("format.lossy", "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	k.Fixtures = writeFixtures(t)
+	sample := filepath.Join(k.Fixtures, "lines", "sample.lines")
+	k.Positive = []string{sample}
+	k.Negative = []string{sample}
+	suite, err := k.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	status := make(map[string]reporter.Case)
+	for _, c := range suite.Cases {
+		status[c.Name] = c
+	}
+	if c := status["detect negative lines/sample.lines"]; c.Status != reporter.StatusFail {
+		t.Errorf("negative fixture detected but case is %s", c.Status)
+	}
+	if c := status["loss class lines/sample.lines"]; c.Status != reporter.StatusFail || len(c.Findings) != 1 {
+		t.Errorf("undeclared loss not reported: %+v", c)
+	}
+	if c := status["emit-native roundtrip lines/sample.lines"]; c.Status != reporter.StatusFail {
+		t.Errorf("unstable roundtrip not reported: %+v", c)
+	}
+	if c := status["ingest lines/sample.lines"]; c.Status != reporter.StatusPass {
+		t.Errorf("ingest failed: %s", c.Message)
+	}
+}
+
+func TestRunComparesImplementations(t *testing.T) {
+	for _, mode := range []string{"same", "differ"} {
+		t.Run(mode, func(t *testing.T) {
+			k, err := New("format.lines", "")
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			k.Fixtures = writeFixtures(t)
+			k.External = externalPlugin(t, "format.lines", mode)
+			suite, err := k.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			var compared int
+			var differ []string
+			for _, c := range suite.Cases {
+				if !strings.HasSuffix(c.ClassName, ".compare") {
+					continue
+				}
+				compared++
+				if c.Status != reporter.StatusPass {
+					differ = append(differ, c.Name)
+				}
+			}
+			if compared == 0 {
+				t.Fatal("no comparison cases")
+			}
+			switch {
+			case mode == "same" && len(differ) > 0:
+				t.Errorf("identical implementations differ: %v", differ)
+			case mode == "differ" && (len(differ) != 1 || !strings.Contains(differ[0], "enumerate")):
+				t.Errorf("expected an enumerate difference, got %v", differ)
+			}
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	k, err := New("format.lines", "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := k.Select(ImplExternal); err == nil {
+		t.Error("selected a missing external implementation")
+	}
+	if err := k.Select("native"); err == nil {
+		t.Error("accepted an unknown implementation")
+	}
+	k.External = &plugins.Plugin{Manifest: k.Manifest, Path: t.TempDir()}
+	if err := k.Select(ImplExternal); err != nil || k.Embedded {
+		t.Errorf("Select(external) = %v, embedded %v", err, k.Embedded)
+	}
+	if err := k.Select(ImplEmbedded); err == nil {
+		t.Error("selected a deselected embedded implementation")
+	}
+
+	if _, err := New("format.missing", ""); err == nil {
+		t.Error("New accepted an unknown plugin")
+	}
+}
@@ -0,0 +1,275 @@
+package detect
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+)
+
+// Container formats.
+const (
+	ContainerZip   = "zip"
+	ContainerTar   = "tar"
+	ContainerTarGz = "tar.gz"
+	ContainerTarXz = "tar.xz"
+	ContainerDir   = "dir"
+)
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// ContainerFormat returns the container format of path: one of the
+// Container constants, or "unknown". Archives are identified by their
+// content when path can be read and by their extension otherwise.
+func ContainerFormat(path string) string {
+	if format := containerFormat(path); format != "" {
+		return format
+	}
+	if _, err := os.Stat(path); err == nil {
+		return "unknown"
+	}
+	switch {
+	case strings.HasSuffix(path, ".tar.xz"):
+		return ContainerTarXz
+	case strings.HasSuffix(path, ".tar.gz"):
+		return ContainerTarGz
+	case strings.HasSuffix(path, ".tar"):
+		return ContainerTar
+	case strings.HasSuffix(path, ".zip"):
+		return ContainerZip
+	default:
+		return "unknown"
+	}
+}
+
+// containerFormat identifies a container by its content, returning "" if
+// path is not one.
+func containerFormat(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return ContainerDir
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	head, _ := r.Peek(len(xzMagic))
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return ContainerZip
+	case bytes.HasPrefix(head, gzipMagic):
+		if gz, err := gzip.NewReader(r); err == nil && isTar(gz) {
+			return ContainerTarGz
+		}
+	case bytes.HasPrefix(head, xzMagic):
+		if xr, err := xz.NewReader(r); err == nil && isTar(xr) {
+			return ContainerTarXz
+		}
+	case isTar(r):
+		return ContainerTar
+	}
+	return ""
+}
+
+// isTar reports whether r starts with a POSIX tar header.
+func isTar(r io.Reader) bool {
+	header := make([]byte, 512)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return false
+	}
+	return bytes.HasPrefix(header[257:], []byte("ustar"))
+}
+
+// scratch is where the archive entries of one detection are extracted,
+// with the bytes that may still be extracted.
+type scratch struct {
+	dir       string
+	remaining int64
+}
+
+// errEntriesDone stops the iteration over a container's entries once no
+// more are detected.
+var errEntriesDone = errors.New("no more entries detected")
+
+// entries detects the entries of a container, extracting archive entries
+// to tmp. It returns whether entries were left out. The entry limit, the
+// extraction budget and ctx are checked before each entry is extracted.
+func (d *Detector) entries(ctx context.Context, path, container string, depth int, tmp *scratch) ([]*Result, bool, error) {
+	var nested []*Result
+	truncated := false
+	next := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(nested) >= d.MaxEntries {
+			truncated = true
+			return errEntriesDone
+		}
+		return nil
+	}
+	add := func(file, name string) error {
+		r, err := d.detect(ctx, file, name, depth+1, tmp)
+		if err != nil {
+			return err
+		}
+		nested = append(nested, r)
+		return nil
+	}
+	// addEntry extracts an archive entry of the given size and detects it.
+	addEntry := func(r io.Reader, name string, size int64) error {
+		if err := next(); err != nil {
+			return err
+		}
+		if size > tmp.remaining {
+			truncated = true
+			return errEntriesDone
+		}
+		file, n, err := extract(r, tmp.dir, name, min(d.MaxEntrySize, tmp.remaining))
+		tmp.remaining -= n
+		if err != nil {
+			return err
+		}
+		return add(file, name)
+	}
+
+	var err error
+	switch container {
+	case ContainerDir:
+		err = filepath.WalkDir(path, func(p string, e fs.DirEntry, err error) error {
+			if err != nil || !e.Type().IsRegular() {
+				return err
+			}
+			if err := next(); err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(path, p)
+			return add(p, filepath.ToSlash(rel))
+		})
+	case ContainerZip:
+		err = d.zipEntries(path, addEntry)
+	default:
+		err = d.tarEntries(path, container, addEntry)
+	}
+	if err != nil && !errors.Is(err, errEntriesDone) {
+		return nil, false, err
+	}
+	return nested, truncated, nil
+}
+
+// zipEntries passes the files of a zip archive to add, with their
+// uncompressed sizes, until add returns an error.
+func (d *Detector) zipEntries(path string, add func(r io.Reader, name string, size int64) error) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || int64(f.UncompressedSize64) > d.MaxEntrySize {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s in %s: %w", f.Name, path, err)
+		}
+		err = add(rc, f.Name, int64(f.UncompressedSize64))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tarEntries passes the regular files of a tar archive, compressed as
+// container says, to add, with their sizes, until add returns an error.
+func (d *Detector) tarEntries(path, container string, add func(r io.Reader, name string, size int64) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	switch container {
+	case ContainerTarGz:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	case ContainerTarXz:
+		xr, err := xz.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		r = xr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if header.Typeflag != tar.TypeReg || header.Size > d.MaxEntrySize {
+			continue
+		}
+		if err := add(tr, header.Name, header.Size); err != nil {
+			return err
+		}
+	}
+}
+
+// extract writes at most limit bytes of an archive entry to a new
+// directory under tmp, keeping its base name so plugins that detect by
+// extension still match it. It returns the file and the bytes written.
+func extract(r io.Reader, tmp, name string, limit int64) (string, int64, error) {
+	dir, err := os.MkdirTemp(tmp, "entry-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	base := filepath.Base(filepath.FromSlash(name))
+	if base == "." || base == ".." || base == string(filepath.Separator) {
+		base = "entry"
+	}
+	file := filepath.Join(dir, base)
+	out, err := os.Create(file)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	n, err := io.Copy(out, io.LimitReader(r, limit))
+	if err != nil {
+		out.Close()
+		return "", n, fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	if err := out.Close(); err != nil {
+		return "", n, fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	return file, n, nil
+}
@@ -0,0 +1,226 @@
+// Package detect identifies the format of a file or directory by asking
+// every format plugin and ranking the plugins that claim it, so that a
+// specific format such as OSIS or USX wins over the generic XML, zip or
+// file plugins that also match. Containers (zip and tar archives and
+// directories) are opened and their entries detected as well.
+package detect
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+	"github.com/FocuswithJustin/JuniperBible/internal/logging"
+)
+
+// Defaults for a Detector.
+const (
+	DefaultDepth        = 2
+	DefaultMaxEntries   = 100
+	DefaultMaxEntrySize = 64 << 20
+	DefaultMaxTotalSize = 512 << 20
+)
+
+// Candidate is a plugin that detected an input.
+type Candidate struct {
+	PluginID    string  `json:"plugin_id"`
+	Format      string  `json:"format"` // Plugin ID without its "format." prefix
+	Confidence  float64 `json:"confidence"`
+	Specificity int     `json:"specificity"`
+	Reason      string  `json:"reason,omitempty"`
+
+	// Plugin is the plugin that detected the input.
+	Plugin *plugins.Plugin `json:"-"`
+}
+
+// Result is the detection of one file or directory.
+type Result struct {
+	// Path is the path detected; for an entry of a container, its path
+	// within the container.
+	Path string `json:"path"`
+
+	// Container is the container format of the input ("zip", "tar",
+	// "tar.gz", "tar.xz" or "dir"), if it is one.
+	Container string `json:"container,omitempty"`
+
+	// Candidates are the plugins that detected the input, best first.
+	Candidates []Candidate `json:"candidates"`
+
+	// Nested are the detections of a container's entries.
+	Nested []*Result `json:"nested,omitempty"`
+
+	// Truncated is true when a container has more entries than were
+	// detected, because of the entry limit or the extraction budget.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Best returns the highest ranked candidate, or nil if no plugin detected
+// the input.
+func (r *Result) Best() *Candidate {
+	if len(r.Candidates) == 0 {
+		return nil
+	}
+	return &r.Candidates[0]
+}
+
+// Format returns the format of the best candidate, or "" if no plugin
+// detected the input.
+func (r *Result) Format() string {
+	if best := r.Best(); best != nil {
+		return best.Format
+	}
+	return ""
+}
+
+// Detector detects formats with a set of format plugins.
+type Detector struct {
+	plugins []*plugins.Plugin
+
+	// Depth is how many levels of nested containers are opened; zero
+	// detects only the input itself.
+	Depth int
+
+	// MaxEntries bounds the entries detected in each container.
+	MaxEntries int
+
+	// MaxEntrySize is the size of the largest archive entry extracted for
+	// detection; larger entries are skipped.
+	MaxEntrySize int64
+
+	// MaxTotalSize bounds the bytes extracted from archives over one
+	// detection, nested archives included. Once it is reached, the
+	// remaining entries are left out.
+	MaxTotalSize int64
+
+	// Workers is the number of plugins run at once.
+	Workers int
+}
+
+// New creates a detector using the loader's format plugins.
+func New(loader *plugins.Loader) *Detector {
+	return NewWithPlugins(loader.GetPluginsByKind("format"))
+}
+
+// NewWithPlugins creates a detector using the given plugins.
+func NewWithPlugins(formatPlugins []*plugins.Plugin) *Detector {
+	return &Detector{
+		plugins:      formatPlugins,
+		Depth:        DefaultDepth,
+		MaxEntries:   DefaultMaxEntries,
+		MaxEntrySize: DefaultMaxEntrySize,
+		MaxTotalSize: DefaultMaxTotalSize,
+		Workers:      runtime.NumCPU(),
+	}
+}
+
+// Detect detects the format of path and, up to the detector's depth, of
+// the entries of the containers it holds.
+func (d *Detector) Detect(ctx context.Context, path string) (*Result, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("cannot detect %s: %w", path, err)
+	}
+	tmp, err := os.MkdirTemp("", "capsule-detect-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	return d.detect(ctx, path, path, 0, &scratch{dir: tmp, remaining: d.MaxTotalSize})
+}
+
+// detect detects the file at path, reported as name.
+func (d *Detector) detect(ctx context.Context, path, name string, depth int, tmp *scratch) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result := &Result{
+		Path:       name,
+		Container:  containerFormat(path),
+		Candidates: d.candidates(ctx, path),
+	}
+	if result.Container != "" && depth < d.Depth {
+		nested, truncated, err := d.entries(ctx, path, result.Container, depth, tmp)
+		if err != nil {
+			return nil, err
+		}
+		result.Nested, result.Truncated = nested, truncated
+	}
+	return result, nil
+}
+
+// candidates runs every plugin's detect command on path and returns the
+// plugins that detected it, ranked.
+func (d *Detector) candidates(ctx context.Context, path string) []Candidate {
+	workers := d.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var found []Candidate
+	for _, p := range d.plugins {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p *plugins.Plugin) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp, err := plugins.ExecutePluginContext(ctx, p, plugins.NewDetectRequest(path))
+			if err != nil {
+				logging.Debug("detect failed", "plugin_id", p.Manifest.PluginID, "path", path, "error", err)
+				return
+			}
+			result, err := plugins.ParseDetectResult(resp)
+			if err != nil || !result.Detected {
+				return
+			}
+			c := newCandidate(p, result)
+			mu.Lock()
+			found = append(found, c)
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+	Rank(found)
+	return found
+}
+
+// newCandidate creates a candidate from a plugin's detect result, filling
+// in the defaults for a plugin that reports no confidence or specificity.
+func newCandidate(p *plugins.Plugin, result *plugins.DetectResult) Candidate {
+	c := Candidate{
+		PluginID:    p.Manifest.PluginID,
+		Format:      strings.TrimPrefix(p.Manifest.PluginID, "format."),
+		Confidence:  result.Confidence,
+		Specificity: result.Specificity,
+		Reason:      result.Reason,
+		Plugin:      p,
+	}
+	if c.Confidence <= 0 || c.Confidence > 1 {
+		c.Confidence = 1
+	}
+	if c.Specificity < plugins.SpecificityAny || c.Specificity > plugins.SpecificityFormat {
+		c.Specificity = plugins.SpecificityFormat
+	}
+	return c
+}
+
+// Rank orders candidates best first: a more specific format outranks a
+// generic one that also matched, and among equally specific formats the
+// more confident plugin wins. Ties are broken by plugin ID.
+func Rank(candidates []Candidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Specificity != b.Specificity {
+			return a.Specificity > b.Specificity
+		}
+		if a.Confidence != b.Confidence {
+			return a.Confidence > b.Confidence
+		}
+		return a.PluginID < b.PluginID
+	})
+}
@@ -0,0 +1,303 @@
+package detect
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+)
+
+// fakeFormat is a format plugin detecting with a function.
+type fakeFormat struct {
+	detect func(path string, data []byte) *plugins.DetectResult
+}
+
+func (f *fakeFormat) Detect(path string) (*plugins.DetectResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &plugins.DetectResult{Reason: err.Error()}, nil
+	}
+	if r := f.detect(path, data); r != nil {
+		r.Detected = true
+		return r, nil
+	}
+	return &plugins.DetectResult{Reason: "no match"}, nil
+}
+
+func (f *fakeFormat) Ingest(path, outputDir string) (*plugins.IngestResult, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeFormat) Enumerate(path string) (*plugins.EnumerateResult, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeFormat) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeFormat) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResult, error) {
+	return nil, errors.New("not supported")
+}
+
+// testDetector returns a detector with a catch-all file plugin, a generic
+// XML plugin that matches by extension, a generic zip plugin and a
+// specific OSIS plugin.
+func testDetector(t *testing.T) *Detector {
+	t.Helper()
+	formats := map[string]func(path string, data []byte) *plugins.DetectResult{
+		"format.test-file": func(path string, data []byte) *plugins.DetectResult {
+			return &plugins.DetectResult{Specificity: plugins.SpecificityAny}
+		},
+		"format.test-xml": func(path string, data []byte) *plugins.DetectResult {
+			if filepath.Ext(path) != ".xml" && !bytes.HasPrefix(data, []byte("<?xml")) {
+				return nil
+			}
+			return &plugins.DetectResult{Confidence: plugins.ExtensionConfidence, Specificity: plugins.SpecificityGeneric}
+		},
+		"format.test-zip": func(path string, data []byte) *plugins.DetectResult {
+			if !bytes.HasPrefix(data, zipMagic) {
+				return nil
+			}
+			return &plugins.DetectResult{Specificity: plugins.SpecificityGeneric}
+		},
+		"format.test-osis": func(path string, data []byte) *plugins.DetectResult {
+			if !bytes.HasPrefix(data, []byte("<?xml")) || !bytes.Contains(data, []byte("<osis")) {
+				return nil
+			}
+			return &plugins.DetectResult{Reason: "OSIS XML"}
+		},
+	}
+	var list []*plugins.Plugin
+	for id, fn := range formats {
+		manifest := &plugins.PluginManifest{PluginID: id, Version: "1.0.0", Kind: "format"}
+		plugins.RegisterEmbeddedPlugin(&plugins.EmbeddedPlugin{Manifest: manifest, Format: &fakeFormat{detect: fn}})
+		list = append(list, &plugins.Plugin{Manifest: manifest, Path: "(embedded)"})
+	}
+	return NewWithPlugins(list)
+}
+
+const osisDoc = `<?xml version="1.0"?><osis><osisText osisIDWork="KJV"/></osis>`
+
+func writeFile(t *testing.T, path string, data []byte) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func zipOf(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzOf(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(data)
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func formats(candidates []Candidate) string {
+	var ids []string
+	for _, c := range candidates {
+		ids = append(ids, c.Format)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestDetectRanksSpecificFormatsFirst(t *testing.T) {
+	d := testDetector(t)
+	path := writeFile(t, filepath.Join(t.TempDir(), "kjv.xml"), []byte(osisDoc))
+
+	result, err := d.Detect(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	if got := formats(result.Candidates); got != "test-osis,test-xml,test-file" {
+		t.Errorf("candidates = %s, want test-osis,test-xml,test-file", got)
+	}
+	best := result.Best()
+	if best.PluginID != "format.test-osis" || best.Confidence != 1 || best.Specificity != plugins.SpecificityFormat {
+		t.Errorf("best = %+v", best)
+	}
+	if result.Container != "" || len(result.Nested) != 0 {
+		t.Errorf("plain file reported as container %q", result.Container)
+	}
+}
+
+func TestDetectRecursesIntoContainers(t *testing.T) {
+	d := testDetector(t)
+	inner := tarGzOf(t, map[string][]byte{"notes/readme.xml": []byte("<?xml version=\"1.0\"?><notes/>")})
+	archive := zipOf(t, map[string][]byte{
+		"bible/kjv.osis": []byte(osisDoc),
+		"extra.tar.gz":   inner,
+	})
+	path := writeFile(t, filepath.Join(t.TempDir(), "bundle.zip"), archive)
+
+	result, err := d.Detect(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	if result.Container != ContainerZip || result.Format() != "test-zip" {
+		t.Fatalf("container %q detected as %q", result.Container, result.Format())
+	}
+	nested := make(map[string]*Result)
+	for _, r := range result.Nested {
+		nested[r.Path] = r
+	}
+	if r := nested["bible/kjv.osis"]; r == nil || r.Format() != "test-osis" {
+		t.Errorf("nested OSIS not detected: %+v", r)
+	}
+	tgz := nested["extra.tar.gz"]
+	if tgz == nil || tgz.Container != ContainerTarGz || len(tgz.Nested) != 1 {
+		t.Fatalf("nested tar.gz not opened: %+v", tgz)
+	}
+	if r := tgz.Nested[0]; r.Path != "notes/readme.xml" || r.Format() != "test-xml" {
+		t.Errorf("entry of nested tar.gz = %s detected as %q", r.Path, r.Format())
+	}
+
+	d.Depth = 1
+	result, err = d.Detect(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	for _, r := range result.Nested {
+		if len(r.Nested) != 0 {
+			t.Errorf("%s opened beyond depth 1", r.Path)
+		}
+	}
+}
+
+func TestDetectDirectoryLimitsEntries(t *testing.T) {
+	d := testDetector(t)
+	dir := t.TempDir()
+	for _, name := range []string{"a.xml", "b.xml", "sub/c.xml"} {
+		writeFile(t, filepath.Join(dir, name), []byte("<?xml?>"))
+	}
+	d.MaxEntries = 2
+
+	result, err := d.Detect(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	if result.Container != ContainerDir || len(result.Nested) != 2 || !result.Truncated {
+		t.Errorf("container %q, %d entries, truncated %v", result.Container, len(result.Nested), result.Truncated)
+	}
+	if result.Best() != nil {
+		t.Errorf("directory detected as %s", result.Format())
+	}
+
+	if _, err := d.Detect(context.Background(), filepath.Join(dir, "missing")); err == nil {
+		t.Error("Detect of a missing path succeeded")
+	}
+}
+
+func TestDetectArchiveLimits(t *testing.T) {
+	d := testDetector(t)
+	files := map[string][]byte{}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		files[name] = bytes.Repeat([]byte("x"), 10)
+	}
+	path := writeFile(t, filepath.Join(t.TempDir(), "bundle.tar.gz"), tarGzOf(t, files))
+
+	d.MaxEntries = 2
+	result, err := d.Detect(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	if len(result.Nested) != 2 || !result.Truncated {
+		t.Errorf("entry limit: %d entries, truncated %v", len(result.Nested), result.Truncated)
+	}
+
+	// The extraction budget covers the whole detection
+	d.MaxEntries = DefaultMaxEntries
+	d.MaxTotalSize = 25
+	result, err = d.Detect(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	if len(result.Nested) != 2 || !result.Truncated {
+		t.Errorf("extraction budget: %d entries, truncated %v", len(result.Nested), result.Truncated)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := d.Detect(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled detection, got %v", err)
+	}
+}
+
+func TestContainerFormat(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		path string
+		want string
+	}{
+		{writeFile(t, filepath.Join(dir, "a.bin"), zipOf(t, map[string][]byte{"x": []byte("x")})), ContainerZip},
+		{writeFile(t, filepath.Join(dir, "b.bin"), tarGzOf(t, map[string][]byte{"x": []byte("x")})), ContainerTarGz},
+		{writeFile(t, filepath.Join(dir, "fake.zip"), []byte("not a zip")), "unknown"},
+		{dir, ContainerDir},
+		{"missing.capsule.tar.xz", ContainerTarXz},
+		{"missing.tar.gz", ContainerTarGz},
+		{"missing.tar", ContainerTar},
+		{"missing.zip", ContainerZip},
+		{"missing.rar", "unknown"},
+	}
+	for _, tt := range tests {
+		if got := ContainerFormat(tt.path); got != tt.want {
+			t.Errorf("ContainerFormat(%s) = %q, want %q", filepath.Base(tt.path), got, tt.want)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	candidates := []Candidate{
+		{PluginID: "format.b", Confidence: 1, Specificity: plugins.SpecificityGeneric},
+		{PluginID: "format.d", Confidence: 0.5, Specificity: plugins.SpecificityFormat},
+		{PluginID: "format.c", Confidence: 0.9, Specificity: plugins.SpecificityFormat},
+		{PluginID: "format.a", Confidence: 0.9, Specificity: plugins.SpecificityFormat},
+		{PluginID: "format.e", Confidence: 1, Specificity: plugins.SpecificityAny},
+	}
+	Rank(candidates)
+	var ids []string
+	for _, c := range candidates {
+		ids = append(ids, c.PluginID)
+	}
+	if got := strings.Join(ids, " "); got != "format.a format.c format.d format.b format.e" {
+		t.Errorf("ranked %s", got)
+	}
+}
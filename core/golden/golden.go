@@ -0,0 +1,221 @@
+// Package golden manages golden transcript hashes and their change
+// ledger. A golden file holds the expected SHA-256 of a run transcript;
+// its ledger, a JSON Lines file next to it, records every hash the golden
+// has had, who changed it, with which commit and tool version, and why.
+// The project rule is that a changed hash must be explained, so a golden
+// whose hash is not the latest ledger entry fails its check.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
+)
+
+// File name suffixes of the ledger and of the transcript store of a golden.
+const (
+	LedgerSuffix      = ".ledger.jsonl"
+	TranscriptsSuffix = ".transcripts"
+)
+
+// Entry is a ledger entry recording a golden hash change.
+type Entry struct {
+	Time        time.Time `json:"time"`
+	SHA256      string    `json:"sha256"`
+	Previous    string    `json:"previous_sha256,omitempty"`
+	Reason      string    `json:"reason"`
+	User        string    `json:"user,omitempty"`
+	Commit      string    `json:"commit,omitempty"`
+	ToolVersion string    `json:"tool_version,omitempty"`
+	Engine      string    `json:"engine,omitempty"` // Engine that produced the transcript
+	RunID       string    `json:"run_id,omitempty"`
+	Plugin      string    `json:"plugin,omitempty"`
+	Profile     string    `json:"profile,omitempty"`
+	// Resources used by the run, the baseline for resource regressions
+	Resources *resource.Usage `json:"resources,omitempty"`
+}
+
+// Golden is a golden hash file and its ledger.
+type Golden struct {
+	Path   string
+	SHA256 string  // Hash in the golden file; empty if it does not exist.
+	Ledger []Entry // Oldest first.
+}
+
+// LedgerPath returns the ledger path of a golden file.
+func LedgerPath(goldenPath string) string {
+	return goldenPath + LedgerSuffix
+}
+
+// TranscriptPath returns where the transcript with the given hash is kept
+// for a golden file.
+func TranscriptPath(goldenPath, sha256 string) string {
+	return filepath.Join(goldenPath+TranscriptsSuffix, sha256+".jsonl")
+}
+
+// Load reads a golden file and its ledger. A missing golden file or
+// ledger is not an error.
+func Load(path string) (*Golden, error) {
+	g := &Golden{Path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.NewIO("read", path, err)
+	}
+	g.SHA256 = strings.TrimSpace(string(data))
+
+	ledgerPath := LedgerPath(path)
+	data, err = os.ReadFile(ledgerPath)
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, errors.NewIO("read", ledgerPath, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var e Entry
+		if err := dec.Decode(&e); err != nil {
+			return nil, errors.NewParse("JSON", ledgerPath, err.Error())
+		}
+		g.Ledger = append(g.Ledger, e)
+	}
+	return g, nil
+}
+
+// Latest returns the most recent ledger entry, or nil if there is none.
+func (g *Golden) Latest() *Entry {
+	if len(g.Ledger) == 0 {
+		return nil
+	}
+	return &g.Ledger[len(g.Ledger)-1]
+}
+
+// Problems checks that the golden hash is explained by its ledger: the
+// latest entry must record the current hash, every entry must follow the
+// one before it, and every entry must give a reason.
+func (g *Golden) Problems() []string {
+	var problems []string
+	latest := g.Latest()
+	switch {
+	case g.SHA256 == "":
+		problems = append(problems, "golden file is missing or empty")
+	case latest == nil:
+		problems = append(problems, fmt.Sprintf("no ledger entry explains golden hash %s", g.SHA256))
+	case latest.SHA256 != g.SHA256:
+		problems = append(problems, fmt.Sprintf("golden hash changed to %s without a ledger entry (ledger records %s)", g.SHA256, latest.SHA256))
+	}
+	for i, e := range g.Ledger {
+		if strings.TrimSpace(e.Reason) == "" {
+			problems = append(problems, fmt.Sprintf("ledger entry %d (%s) has no reason", i+1, e.SHA256))
+		}
+		if i > 0 && e.Previous != g.Ledger[i-1].SHA256 {
+			problems = append(problems, fmt.Sprintf("ledger entry %d changes %s, but entry %d recorded %s", i+1, e.Previous, i, g.Ledger[i-1].SHA256))
+		}
+	}
+	return problems
+}
+
+// Update sets the golden hash to sha256 and appends entry to the ledger.
+// The entry's reason is required. If transcript is not nil it must hash to
+// sha256; it is kept next to the golden, so later checks can diff against it.
+func Update(path, sha256 string, transcript []byte, entry Entry) (*Entry, error) {
+	if sha256 == "" {
+		return nil, errors.NewValidation("sha256", "golden hash is required")
+	}
+	if strings.TrimSpace(entry.Reason) == "" {
+		return nil, errors.NewValidation("reason", "a golden change must be explained")
+	}
+	if transcript != nil && cas.Hash(transcript) != sha256 {
+		return nil, errors.NewValidation("transcript", fmt.Sprintf("transcript does not hash to %s", sha256))
+	}
+	g, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if latest := g.Latest(); latest != nil && latest.SHA256 == sha256 && g.SHA256 == sha256 {
+		return nil, errors.NewValidation("sha256", fmt.Sprintf("golden already records %s", sha256))
+	}
+
+	entry.SHA256 = sha256
+	// Chain to the last explained hash, so an unexplained edit of the
+	// golden file stays visible in the ledger
+	if latest := g.Latest(); latest != nil {
+		entry.Previous = latest.SHA256
+	} else {
+		entry.Previous = g.SHA256
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	if entry.User == "" {
+		entry.User = currentUser()
+	}
+
+	if transcript != nil {
+		transcriptPath := TranscriptPath(path, sha256)
+		if err := os.MkdirAll(filepath.Dir(transcriptPath), 0755); err != nil {
+			return nil, errors.NewIO("create", filepath.Dir(transcriptPath), err)
+		}
+		if err := os.WriteFile(transcriptPath, transcript, 0644); err != nil {
+			return nil, errors.NewIO("write", transcriptPath, err)
+		}
+	}
+
+	// Write the golden before the ledger: if the ledger write fails, the
+	// golden is left changed without an entry and its check fails.
+	if err := os.WriteFile(path, []byte(entry.SHA256+"\n"), 0644); err != nil {
+		return nil, errors.NewIO("write", path, err)
+	}
+	if err := appendEntry(LedgerPath(path), &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Transcript returns the kept transcript with the given hash.
+func Transcript(path, sha256 string) ([]byte, error) {
+	transcriptPath := TranscriptPath(path, sha256)
+	data, err := os.ReadFile(transcriptPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.NewNotFound("transcript", sha256)
+		}
+		return nil, errors.NewIO("read", transcriptPath, err)
+	}
+	return data, nil
+}
+
+// appendEntry appends an entry to a ledger.
+func appendEntry(ledgerPath string, entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(ledgerPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.NewIO("open", ledgerPath, err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return errors.NewIO("write", ledgerPath, err)
+	}
+	return f.Close()
+}
+
+// currentUser returns the name of the user running the process.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,109 @@
+package golden
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+)
+
+func TestUpdateAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kjv.sha256")
+	first := []byte(`{"t":"start"}` + "\n")
+	second := []byte(`{"t":"start"}` + "\n" + `{"t":"end"}` + "\n")
+
+	if _, err := Update(path, cas.Hash(first), first, Entry{Reason: "initial", Commit: "abc"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	entry, err := Update(path, cas.Hash(second), second, Entry{Reason: "tool now reports end"})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if entry.Previous != cas.Hash(first) || entry.Time.IsZero() {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+
+	g, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if g.SHA256 != cas.Hash(second) || len(g.Ledger) != 2 {
+		t.Fatalf("unexpected golden %+v", g)
+	}
+	if g.Ledger[0].Commit != "abc" || g.Latest().Reason != "tool now reports end" {
+		t.Errorf("unexpected ledger %+v", g.Ledger)
+	}
+	if problems := g.Problems(); len(problems) != 0 {
+		t.Errorf("unexpected problems %v", problems)
+	}
+
+	data, err := Transcript(path, cas.Hash(first))
+	if err != nil || string(data) != string(first) {
+		t.Errorf("Transcript = %q, %v", data, err)
+	}
+	if _, err := Transcript(path, "missing"); err == nil {
+		t.Error("expected error for a transcript that was not kept")
+	}
+}
+
+func TestUpdateValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kjv.sha256")
+	if _, err := Update(path, "abc", nil, Entry{Reason: "  "}); err == nil {
+		t.Error("expected error for an update without a reason")
+	}
+	if _, err := Update(path, "abc", []byte("other"), Entry{Reason: "r"}); err == nil {
+		t.Error("expected error for a transcript with a different hash")
+	}
+	if _, err := Update(path, "abc", nil, Entry{Reason: "r"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if _, err := Update(path, "abc", nil, Entry{Reason: "again"}); err == nil {
+		t.Error("expected error for an update that changes nothing")
+	}
+}
+
+func TestProblems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kjv.sha256")
+	if err := os.WriteFile(path, []byte("abc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, _ := Load(path)
+	if problems := g.Problems(); len(problems) != 1 || !strings.Contains(problems[0], "no ledger entry") {
+		t.Errorf("unexpected problems without a ledger: %v", problems)
+	}
+
+	if _, err := Update(path, "abc", nil, Entry{Reason: "adopt existing golden"}); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if g, _ := Load(path); g.Ledger[0].Previous != "abc" {
+		t.Errorf("expected the first entry to chain to the existing hash, got %q", g.Ledger[0].Previous)
+	}
+
+	// Overwrite the golden without going through Update
+	if err := os.WriteFile(path, []byte("def\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, _ = Load(path)
+	if problems := g.Problems(); len(problems) != 1 || !strings.Contains(problems[0], "without a ledger entry") {
+		t.Errorf("unexpected problems after an unexplained change: %v", problems)
+	}
+
+	// A hand-edited ledger with a broken chain and no reason
+	ledger := `{"sha256":"abc","reason":"initial"}` + "\n" + `{"sha256":"def","previous_sha256":"xyz"}` + "\n"
+	if err := os.WriteFile(LedgerPath(path), []byte(ledger), 0644); err != nil {
+		t.Fatal(err)
+	}
+	g, _ = Load(path)
+	if problems := g.Problems(); len(problems) != 2 {
+		t.Errorf("expected missing reason and broken chain, got %v", problems)
+	}
+
+	if err := os.WriteFile(LedgerPath(path), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for a corrupt ledger")
+	}
+}
@@ -0,0 +1,38 @@
+package ir
+
+import "testing"
+
+func TestVerseTableKJV(t *testing.T) {
+	books, ok := VerseTable(VersificationKJV)
+	if !ok {
+		t.Fatal("expected a KJV verse table")
+	}
+	if len(books) != 66 {
+		t.Errorf("expected 66 books, got %d", len(books))
+	}
+	total := 0
+	for _, b := range books {
+		for _, n := range b.Chapters {
+			total += n
+		}
+	}
+	if total != 31102 {
+		t.Errorf("expected 31102 verses, got %d", total)
+	}
+
+	if _, ok := VerseTable(VersificationLXX); ok {
+		t.Error("expected no verse table for LXX")
+	}
+}
+
+func TestOSISBookFromUSFM(t *testing.T) {
+	tests := map[string]string{"GEN": "Gen", "SNG": "Song", "1JN": "1John", "REV": "Rev"}
+	for code, want := range tests {
+		if got, ok := OSISBookFromUSFM(code); !ok || got != want {
+			t.Errorf("OSISBookFromUSFM(%q) = %q, %v; want %q", code, got, ok, want)
+		}
+	}
+	if _, ok := OSISBookFromUSFM("XXX"); ok {
+		t.Error("expected unknown code to fail")
+	}
+}
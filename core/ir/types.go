@@ -238,6 +238,10 @@ const (
 	SpanEmphasis   SpanType = "EMPHASIS"
 	SpanForeign    SpanType = "FOREIGN"
 	SpanSelah      SpanType = "SELAH"
+	SpanWord       SpanType = "WORD"
+	SpanCharStyle  SpanType = "CHAR_STYLE"
+	SpanMilestone  SpanType = "MILESTONE"
+	SpanTableCell  SpanType = "TABLE_CELL"
 )
 
 // validSpanTypes is the set of valid span types.
@@ -256,6 +260,10 @@ var validSpanTypes = map[SpanType]bool{
 	SpanEmphasis:   true,
 	SpanForeign:    true,
 	SpanSelah:      true,
+	SpanWord:       true,
+	SpanCharStyle:  true,
+	SpanMilestone:  true,
+	SpanTableCell:  true,
 }
 
 // IsValid returns true if the span type is valid.
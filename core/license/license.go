@@ -0,0 +1,175 @@
+// Package license normalizes the license metadata of Bible texts to
+// SPDX-like identifiers and decides which redistribution actions a
+// license permits.
+//
+// SWORD modules declare their terms in free-form conf fields
+// (DistributionLicense, Copyright, ShortCopyright). Normalize maps the
+// DistributionLicense values in use to SPDX identifiers where one exists
+// and to LicenseRef-* identifiers for the SWORD-specific grants, so that a
+// Policy can be written against stable names.
+package license
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Well-known identifiers produced by Normalize.
+const (
+	// NoAssertion is the SPDX identifier for missing license information.
+	NoAssertion = "NOASSERTION"
+
+	PublicDomain = "CC-PDDC"
+
+	// Copyrighted texts without any redistribution grant.
+	Copyrighted = "LicenseRef-Copyrighted"
+
+	// Copyrighted texts that may be freely redistributed.
+	CopyrightedFree = "LicenseRef-Copyrighted-Free"
+
+	// Copyrighted texts that may be redistributed non-commercially.
+	CopyrightedNonCommercial = "LicenseRef-Copyrighted-NonCommercial"
+
+	// Copyrighted texts that may be redistributed non-commercially, and
+	// only as a SWORD module.
+	CopyrightedSWORD = "LicenseRef-Copyrighted-SWORD-NonCommercial"
+
+	// Copyrighted texts that only CrossWire may redistribute.
+	CopyrightedCrossWire = "LicenseRef-Copyrighted-CrossWire"
+
+	// Other is used for license text that could not be recognized.
+	Other = "LicenseRef-Other"
+)
+
+// Info is the normalized license metadata of a text.
+type Info struct {
+	// ID is the SPDX or LicenseRef identifier of the license.
+	ID string `json:"id"`
+
+	// Raw is the license statement as declared by the source.
+	Raw string `json:"raw,omitempty"`
+
+	Copyright      string `json:"copyright,omitempty"`
+	ShortCopyright string `json:"short_copyright,omitempty"`
+	Notes          string `json:"notes,omitempty"`
+}
+
+// spdxPattern matches strings that are already license identifiers.
+var spdxPattern = regexp.MustCompile(`^(LicenseRef-)?[A-Za-z0-9][A-Za-z0-9.+-]*$`)
+
+// Normalize converts a license statement, such as a SWORD
+// DistributionLicense value, to an SPDX-like identifier.
+func Normalize(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	lower := strings.ToLower(trimmed)
+
+	switch {
+	case lower == "" || lower == "-" || lower == "unknown":
+		return NoAssertion
+	case strings.Contains(lower, "public domain"):
+		return PublicDomain
+	case lower == "unrestricted":
+		return "Unlicense"
+	case lower == "gpl":
+		return "GPL-3.0-or-later"
+	case lower == "gfdl":
+		return "GFDL-1.3-or-later"
+	case strings.Contains(lower, "general public license for distribution for any purpose"):
+		return CopyrightedFree
+
+	// Creative Commons variants
+	case strings.Contains(lower, "cc0"):
+		return "CC0-1.0"
+	case strings.Contains(lower, "creative commons") || strings.HasPrefix(lower, "cc "):
+		return creativeCommons(lower)
+
+	// SWORD copyright grants, most specific first
+	case strings.Contains(lower, "crosswire"):
+		return CopyrightedCrossWire
+	case strings.Contains(lower, "sword format"):
+		return CopyrightedSWORD
+	case strings.Contains(lower, "non-commercial") || strings.Contains(lower, "noncommercial"):
+		return CopyrightedNonCommercial
+	case strings.Contains(lower, "freely distributable") || strings.Contains(lower, "free distribution"):
+		return CopyrightedFree
+	case strings.HasPrefix(lower, "copyright"):
+		return Copyrighted
+
+	case spdxPattern.MatchString(trimmed):
+		return trimmed
+	default:
+		return Other
+	}
+}
+
+// creativeCommons maps a Creative Commons license statement such as
+// "Creative Commons: by-nc-nd 4.0" to its SPDX identifier.
+func creativeCommons(lower string) string {
+	version := "3.0"
+	for _, v := range []string{"4.0", "3.0", "2.5", "2.0", "1.0"} {
+		if strings.Contains(lower, v) {
+			version = v
+			break
+		}
+	}
+	for _, variant := range []string{"by-nc-nd", "by-nc-sa", "by-nc", "by-nd", "by-sa"} {
+		if strings.Contains(lower, variant) {
+			return "CC-" + strings.ToUpper(variant) + "-" + version
+		}
+	}
+	if strings.Contains(lower, "by") || strings.Contains(lower, "attribution") {
+		return "CC-BY-" + version
+	}
+	return Other
+}
+
+// FromConf builds license metadata from the properties of a SWORD conf.
+func FromConf(props map[string]string) *Info {
+	raw := props["DistributionLicense"]
+	return &Info{
+		ID:             Normalize(raw),
+		Raw:            raw,
+		Copyright:      props["Copyright"],
+		ShortCopyright: props["ShortCopyright"],
+		Notes:          props["DistributionLicenseNotes"],
+	}
+}
+
+// ParseConf reads the key=value properties of a SWORD conf file.
+// Continuation lines ending in a backslash are joined, and the first
+// value of a repeated key wins.
+func ParseConf(data []byte) map[string]string {
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var key, value string
+	continued := false
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if continued {
+			value += "\n" + strings.TrimSuffix(line, "\\")
+		} else {
+			idx := strings.Index(line, "=")
+			if idx <= 0 || strings.HasPrefix(line, "[") || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key = strings.TrimSpace(line[:idx])
+			value = strings.TrimSuffix(strings.TrimSpace(line[idx+1:]), "\\")
+		}
+		continued = strings.HasSuffix(line, "\\")
+		if !continued {
+			if _, ok := props[key]; !ok {
+				props[key] = strings.TrimSpace(value)
+			}
+		}
+	}
+	if continued {
+		if _, ok := props[key]; !ok {
+			props[key] = strings.TrimSpace(value)
+		}
+	}
+	return props
+}
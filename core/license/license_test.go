@@ -0,0 +1,48 @@
+package license
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"", NoAssertion},
+		{"Public Domain", PublicDomain},
+		{"Copyrighted; Permission to distribute granted to CrossWire", CopyrightedCrossWire},
+		{"Copyrighted; Free non-commercial distribution", CopyrightedNonCommercial},
+		{"Copyrighted; Permission granted to distribute non-commercially in SWORD format", CopyrightedSWORD},
+		{"Copyrighted; Freely distributable", CopyrightedFree},
+		{"Copyrighted", Copyrighted},
+		{"GPL", "GPL-3.0-or-later"},
+		{"Creative Commons: BY-SA 4.0", "CC-BY-SA-4.0"},
+		{"Creative Commons: by-nc-nd", "CC-BY-NC-ND-3.0"},
+		{"Creative Commons: BY 4.0", "CC-BY-4.0"},
+		{"MIT", "MIT"},
+		{"ask the publisher", Other},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.raw); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParseConf(t *testing.T) {
+	conf := []byte(`[KJV]
+DataPath=./modules/texts/ztext/kjv/
+DistributionLicense=Copyrighted; Permission to distribute granted to CrossWire
+Copyright=Copyright 2001 Example Publisher
+DistributionLicenseNotes=First line\
+second line
+ShortCopyright=(c) 2001
+`)
+	props := ParseConf(conf)
+	info := FromConf(props)
+	if info.ID != CopyrightedCrossWire || info.Copyright != "Copyright 2001 Example Publisher" || info.ShortCopyright != "(c) 2001" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if info.Notes != "First line\nsecond line" {
+		t.Errorf("Notes = %q", info.Notes)
+	}
+}
@@ -0,0 +1,205 @@
+package license
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// DefaultOverrideLogName is the file name of the override log.
+const DefaultOverrideLogName = "license-overrides.jsonl"
+
+// Override is an exception to a blocking decision. Every use of an
+// override is appended to the override log, so it can be audited later.
+type Override struct {
+	// Subject is a path.Match pattern over subjects (capsule or module
+	// names); empty matches any subject.
+	Subject string `json:"subject,omitempty"`
+
+	// License is a path.Match pattern over license identifiers; empty
+	// matches any license.
+	License string `json:"license,omitempty"`
+
+	// Action is the overridden action; empty matches any action.
+	Action string `json:"action,omitempty"`
+
+	Reason    string    `json:"reason"`
+	GrantedBy string    `json:"granted_by,omitempty"`
+	Expires   time.Time `json:"expires,omitempty"`
+}
+
+// matches reports whether the override applies to a verdict at time now.
+func (o *Override) matches(v *Verdict, now time.Time) bool {
+	if !o.Expires.IsZero() && now.After(o.Expires) {
+		return false
+	}
+	if o.Action != "" && o.Action != v.Action {
+		return false
+	}
+	if o.License != "" {
+		if ok, _ := path.Match(o.License, v.License); !ok {
+			return false
+		}
+	}
+	if o.Subject != "" {
+		if ok, _ := path.Match(o.Subject, v.Subject); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// OverrideRecord is an entry of the override log.
+type OverrideRecord struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user,omitempty"`
+	Subject  string    `json:"subject"`
+	License  string    `json:"license"`
+	Action   string    `json:"action"`
+	Decision string    `json:"decision"`
+	Reason   string    `json:"reason"`
+
+	// Standing is set when the override came from the policy rather than
+	// being requested for this action.
+	Standing bool `json:"standing,omitempty"`
+}
+
+// DefaultOverrideLog returns the per-user override log path.
+func DefaultOverrideLog() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "juniper", DefaultOverrideLogName)
+}
+
+// Enforcer applies a policy and records overrides.
+type Enforcer struct {
+	Policy *Policy
+
+	// LogPath is the JSON Lines override log. Overrides are refused when
+	// it is empty, since they could not be audited.
+	LogPath string
+
+	mu sync.Mutex
+}
+
+// NewEnforcer loads the policy at policyPath, or the default policy if
+// policyPath is empty.
+func NewEnforcer(policyPath, logPath string) (*Enforcer, error) {
+	policy := DefaultPolicy()
+	if policyPath != "" {
+		var err error
+		if policy, err = LoadPolicy(policyPath); err != nil {
+			return nil, err
+		}
+	}
+	return &Enforcer{Policy: policy, LogPath: logPath}, nil
+}
+
+// Enforce evaluates action on subject. A blocked action proceeds only if
+// a standing override in the policy matches or overrideReason is given;
+// either way the override is logged. The returned error is a permission
+// error when the action stays blocked.
+func (e *Enforcer) Enforce(subject string, info *Info, action, overrideReason string) (*Verdict, error) {
+	v := e.Policy.Evaluate(subject, info, action)
+	if v.Decision != DecisionBlock {
+		return v, nil
+	}
+
+	now := time.Now().UTC()
+	standing := false
+	for i := range e.Policy.Overrides {
+		if e.Policy.Overrides[i].matches(v, now) {
+			o := e.Policy.Overrides[i]
+			v.Override = &o
+			standing = true
+			break
+		}
+	}
+	if v.Override == nil && overrideReason != "" {
+		v.Override = &Override{Subject: subject, Action: action, Reason: overrideReason, GrantedBy: currentUser()}
+	}
+	if v.Override == nil {
+		return v, errors.NewPermission(action, subject, fmt.Sprintf("%s (license %s)", v.Reason, v.License))
+	}
+
+	record := OverrideRecord{
+		Time:     now,
+		User:     currentUser(),
+		Subject:  subject,
+		License:  v.License,
+		Action:   action,
+		Decision: v.Decision,
+		Reason:   v.Override.Reason,
+		Standing: standing,
+	}
+	if err := e.appendLog(record); err != nil {
+		v.Override = nil
+		return v, err
+	}
+	return v, nil
+}
+
+// appendLog appends a record to the override log.
+func (e *Enforcer) appendLog(record OverrideRecord) error {
+	if e.LogPath == "" {
+		return errors.NewValidation("override", "no override log configured; overrides cannot be recorded")
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(e.LogPath), 0700); err != nil {
+		return errors.NewIO("create", filepath.Dir(e.LogPath), err)
+	}
+	f, err := os.OpenFile(e.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.NewIO("open", e.LogPath, err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return errors.NewIO("write", e.LogPath, err)
+	}
+	return f.Close()
+}
+
+// ReadOverrideLog returns the records of an override log, oldest first.
+func ReadOverrideLog(logPath string) ([]OverrideRecord, error) {
+	data, err := os.ReadFile(logPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.NewIO("read", logPath, err)
+	}
+	var records []OverrideRecord
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var r OverrideRecord
+		if err := dec.Decode(&r); err != nil {
+			return nil, errors.NewParse("JSON", logPath, err.Error())
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// currentUser returns the name of the user running the process.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,192 @@
+package license
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Actions that a policy governs.
+const (
+	// ActionDisplay is showing the text, e.g. in the web reader.
+	ActionDisplay = "display"
+
+	// ActionExport is writing the text out of a capsule in its stored
+	// or an interchange format.
+	ActionExport = "export"
+
+	// ActionDownload is serving the text to a remote client.
+	ActionDownload = "download"
+
+	// ActionPublish is generating a publication from the text, such as
+	// an EPUB, a static site or Hugo data.
+	ActionPublish = "publish"
+)
+
+// Decisions a policy can reach.
+const (
+	DecisionAllow = "allow"
+	DecisionWarn  = "warn"
+	DecisionBlock = "block"
+)
+
+// publishFormats are target formats whose output is a publication.
+var publishFormats = map[string]bool{
+	"epub":     true,
+	"html":     true,
+	"markdown": true,
+	"hugo":     true,
+}
+
+// ActionForFormat returns the action of converting a text to format.
+func ActionForFormat(format string) string {
+	if publishFormats[strings.ToLower(format)] {
+		return ActionPublish
+	}
+	return ActionExport
+}
+
+// Rule maps licenses and actions to a decision.
+type Rule struct {
+	// License is a path.Match pattern over license identifiers.
+	License string `json:"license"`
+
+	// Actions the rule applies to; empty means all actions.
+	Actions []string `json:"actions,omitempty"`
+
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// matches reports whether the rule applies to a license and action.
+func (r *Rule) matches(id, action string) bool {
+	if ok, _ := path.Match(r.License, id); !ok {
+		return false
+	}
+	if len(r.Actions) == 0 {
+		return true
+	}
+	for _, a := range r.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy decides which actions each license permits. Rules are evaluated
+// in order and the first match wins; actions no rule matches are allowed.
+type Policy struct {
+	Rules []Rule `json:"rules"`
+
+	// Overrides are standing exceptions to blocking decisions.
+	Overrides []Override `json:"overrides,omitempty"`
+}
+
+// DefaultPolicy returns the built-in policy for the SWORD license grants.
+func DefaultPolicy() *Policy {
+	return &Policy{Rules: []Rule{
+		{License: NoAssertion, Decision: DecisionWarn,
+			Reason: "no license information; verify redistribution rights"},
+		{License: Other, Decision: DecisionWarn,
+			Reason: "unrecognized license; verify redistribution rights"},
+
+		{License: CopyrightedCrossWire, Actions: []string{ActionDisplay}, Decision: DecisionAllow},
+		{License: CopyrightedCrossWire, Decision: DecisionBlock,
+			Reason: "permission to distribute is granted to CrossWire only"},
+
+		{License: CopyrightedSWORD, Actions: []string{ActionDisplay}, Decision: DecisionAllow},
+		{License: CopyrightedSWORD, Actions: []string{ActionPublish}, Decision: DecisionBlock,
+			Reason: "distribution is permitted in SWORD format only"},
+		{License: CopyrightedSWORD, Decision: DecisionWarn,
+			Reason: "non-commercial distribution in SWORD format only"},
+
+		{License: CopyrightedNonCommercial, Actions: []string{ActionExport, ActionDownload, ActionPublish}, Decision: DecisionWarn,
+			Reason: "non-commercial distribution only"},
+
+		{License: Copyrighted, Actions: []string{ActionDisplay}, Decision: DecisionWarn,
+			Reason: "copyrighted text without a redistribution grant"},
+		{License: Copyrighted, Decision: DecisionBlock,
+			Reason: "copyrighted text without a redistribution grant"},
+
+		{License: "CC-BY-NC*", Actions: []string{ActionExport, ActionDownload, ActionPublish}, Decision: DecisionWarn,
+			Reason: "non-commercial use only"},
+		{License: "CC-BY-*ND-*", Actions: []string{ActionPublish}, Decision: DecisionWarn,
+			Reason: "no-derivatives license; a converted publication may be an adaptation"},
+	}}
+}
+
+// LoadPolicy reads a policy from a JSON file.
+func LoadPolicy(policyPath string) (*Policy, error) {
+	data, err := os.ReadFile(policyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read license policy: %w", err)
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse license policy: %w", err)
+	}
+	for i, r := range p.Rules {
+		switch r.Decision {
+		case DecisionAllow, DecisionWarn, DecisionBlock:
+		default:
+			return nil, fmt.Errorf("license policy rule %d: invalid decision %q", i+1, r.Decision)
+		}
+		if _, err := path.Match(r.License, ""); err != nil {
+			return nil, fmt.Errorf("license policy rule %d: invalid license pattern %q", i+1, r.License)
+		}
+	}
+	for i, o := range p.Overrides {
+		if o.Reason == "" {
+			return nil, fmt.Errorf("license policy override %d: a reason is required", i+1)
+		}
+	}
+	return &p, nil
+}
+
+// Verdict is the outcome of evaluating an action against a policy.
+type Verdict struct {
+	Subject  string    `json:"subject"`
+	License  string    `json:"license"`
+	Action   string    `json:"action"`
+	Decision string    `json:"decision"`
+	Reason   string    `json:"reason,omitempty"`
+	Override *Override `json:"override,omitempty"`
+}
+
+// Allowed reports whether the action may proceed.
+func (v *Verdict) Allowed() bool {
+	return v.Decision != DecisionBlock || v.Override != nil
+}
+
+// String describes the verdict for display.
+func (v *Verdict) String() string {
+	s := fmt.Sprintf("%s of %s (%s): %s", v.Action, v.Subject, v.License, v.Decision)
+	if v.Reason != "" {
+		s += " - " + v.Reason
+	}
+	if v.Override != nil {
+		s += " (overridden: " + v.Override.Reason + ")"
+	}
+	return s
+}
+
+// Evaluate decides whether action is permitted on subject under the
+// given license. A nil info is treated as NOASSERTION.
+func (p *Policy) Evaluate(subject string, info *Info, action string) *Verdict {
+	id := NoAssertion
+	if info != nil && info.ID != "" {
+		id = info.ID
+	}
+	v := &Verdict{Subject: subject, License: id, Action: action, Decision: DecisionAllow}
+	for i := range p.Rules {
+		if p.Rules[i].matches(id, action) {
+			v.Decision = p.Rules[i].Decision
+			v.Reason = p.Rules[i].Reason
+			break
+		}
+	}
+	return v
+}
@@ -0,0 +1,107 @@
+package license
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	p := DefaultPolicy()
+	tests := []struct {
+		id     string
+		action string
+		want   string
+	}{
+		{PublicDomain, ActionPublish, DecisionAllow},
+		{CopyrightedCrossWire, ActionDisplay, DecisionAllow},
+		{CopyrightedCrossWire, ActionPublish, DecisionBlock},
+		{CopyrightedCrossWire, ActionDownload, DecisionBlock},
+		{CopyrightedSWORD, ActionPublish, DecisionBlock},
+		{CopyrightedSWORD, ActionExport, DecisionWarn},
+		{CopyrightedNonCommercial, ActionPublish, DecisionWarn},
+		{CopyrightedNonCommercial, ActionDisplay, DecisionAllow},
+		{Copyrighted, ActionExport, DecisionBlock},
+		{"CC-BY-ND-4.0", ActionPublish, DecisionWarn},
+		{"CC-BY-SA-4.0", ActionPublish, DecisionAllow},
+		{NoAssertion, ActionDisplay, DecisionWarn},
+	}
+	for _, tt := range tests {
+		v := p.Evaluate("KJV", &Info{ID: tt.id}, tt.action)
+		if v.Decision != tt.want {
+			t.Errorf("%s of %s = %s, want %s", tt.action, tt.id, v.Decision, tt.want)
+		}
+	}
+	if v := p.Evaluate("KJV", nil, ActionExport); v.License != NoAssertion {
+		t.Errorf("expected nil info to be NOASSERTION, got %s", v.License)
+	}
+}
+
+func TestActionForFormat(t *testing.T) {
+	if ActionForFormat("EPUB") != ActionPublish || ActionForFormat("osis") != ActionExport {
+		t.Error("unexpected action for format")
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "policy.json")
+	os.WriteFile(good, []byte(`{"rules":[{"license":"LicenseRef-*","actions":["publish"],"decision":"block"}]}`), 0644)
+	p, err := LoadPolicy(good)
+	if err != nil {
+		t.Fatalf("LoadPolicy failed: %v", err)
+	}
+	if v := p.Evaluate("X", &Info{ID: CopyrightedFree}, ActionPublish); v.Decision != DecisionBlock {
+		t.Errorf("expected block, got %s", v.Decision)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"rules":[{"license":"*","decision":"maybe"}]}`), 0644)
+	if _, err := LoadPolicy(bad); err == nil {
+		t.Error("expected error for invalid decision")
+	}
+	noReason := filepath.Join(dir, "noreason.json")
+	os.WriteFile(noReason, []byte(`{"overrides":[{"subject":"KJV"}]}`), 0644)
+	if _, err := LoadPolicy(noReason); err == nil {
+		t.Error("expected error for override without reason")
+	}
+}
+
+func TestEnforceOverrides(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "overrides.jsonl")
+	e := &Enforcer{Policy: DefaultPolicy(), LogPath: logPath}
+	info := &Info{ID: CopyrightedCrossWire}
+
+	v, err := e.Enforce("KJV", info, ActionPublish, "")
+	if !errors.Is(err, errors.ErrUnauthorized) || v.Allowed() {
+		t.Fatalf("expected blocked publish, got %v, %v", v, err)
+	}
+
+	v, err = e.Enforce("KJV", info, ActionPublish, "publisher granted EPUB rights by letter")
+	if err != nil || !v.Allowed() {
+		t.Fatalf("expected override to allow publish, got %v, %v", v, err)
+	}
+
+	e.Policy.Overrides = []Override{{Subject: "ESV*", Action: ActionDownload, Reason: "licensed mirror"}}
+	if _, err := e.Enforce("ESV2011", info, ActionDownload, ""); err != nil {
+		t.Fatalf("expected standing override to apply: %v", err)
+	}
+	if _, err := e.Enforce("KJV", info, ActionDownload, ""); err == nil {
+		t.Error("expected standing override not to match other subjects")
+	}
+
+	records, err := ReadOverrideLog(logPath)
+	if err != nil {
+		t.Fatalf("ReadOverrideLog failed: %v", err)
+	}
+	if len(records) != 2 || records[0].Standing || !records[1].Standing || records[0].Reason == "" {
+		t.Errorf("unexpected override log %+v", records)
+	}
+
+	unlogged := &Enforcer{Policy: DefaultPolicy()}
+	if _, err := unlogged.Enforce("KJV", info, ActionPublish, "no log"); err == nil {
+		t.Error("expected override without a log to be refused")
+	}
+}
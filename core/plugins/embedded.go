@@ -3,6 +3,11 @@
 // compiled directly into the binary instead of running as subprocesses.
 package plugins
 
+import (
+	"context"
+	"fmt"
+)
+
 // EmbeddedFormatHandler defines the interface for embedded format plugins.
 // This mirrors the IPC commands that format plugins handle.
 type EmbeddedFormatHandler interface {
@@ -28,6 +33,56 @@ type EmbeddedToolHandler interface {
 	Execute(command string, args map[string]interface{}) (interface{}, error)
 }
 
+// Context-aware variants of the handler methods. A handler implementing
+// one of them is called through it, so it can stop when the call is
+// cancelled and report progress with ReportProgress. Other handlers run
+// to completion, and their result is discarded when the call has been
+// cancelled meanwhile. The options variants also receive the call's
+// options, already validated against the manifest by the host.
+type (
+	// DetectorContext is implemented by format handlers that can cancel Detect.
+	DetectorContext interface {
+		DetectContext(ctx context.Context, path string) (*DetectResult, error)
+	}
+
+	// IngesterContext is implemented by format handlers that can cancel Ingest.
+	IngesterContext interface {
+		IngestContext(ctx context.Context, path, outputDir string) (*IngestResult, error)
+	}
+
+	// EnumeratorContext is implemented by format handlers that can cancel Enumerate.
+	EnumeratorContext interface {
+		EnumerateContext(ctx context.Context, path string) (*EnumerateResult, error)
+	}
+
+	// IRExtractorContext is implemented by format handlers that can cancel ExtractIR.
+	IRExtractorContext interface {
+		ExtractIRContext(ctx context.Context, path, outputDir string) (*ExtractIRResult, error)
+	}
+
+	// NativeEmitterContext is implemented by format handlers that can cancel EmitNative.
+	NativeEmitterContext interface {
+		EmitNativeContext(ctx context.Context, irPath, outputDir string) (*EmitNativeResult, error)
+	}
+
+	// IRExtractorOptions is implemented by format handlers that take
+	// options for ExtractIR. It is preferred over IRExtractorContext.
+	IRExtractorOptions interface {
+		ExtractIROptions(ctx context.Context, path, outputDir string, opts Options) (*ExtractIRResult, error)
+	}
+
+	// NativeEmitterOptions is implemented by format handlers that take
+	// options for EmitNative. It is preferred over NativeEmitterContext.
+	NativeEmitterOptions interface {
+		EmitNativeOptions(ctx context.Context, irPath, outputDir string, opts Options) (*EmitNativeResult, error)
+	}
+
+	// ToolExecutorContext is implemented by tool handlers that can cancel Execute.
+	ToolExecutorContext interface {
+		ExecuteContext(ctx context.Context, command string, args map[string]interface{}) (interface{}, error)
+	}
+)
+
 // EmbeddedPlugin wraps an embedded handler with its manifest.
 type EmbeddedPlugin struct {
 	Manifest *PluginManifest
@@ -73,78 +128,110 @@ func ClearEmbeddedRegistry() {
 // ExecuteEmbeddedPlugin executes an embedded plugin with the given request.
 // Returns nil, nil if the plugin doesn't exist or isn't embedded.
 func ExecuteEmbeddedPlugin(pluginID string, req *IPCRequest) (*IPCResponse, error) {
+	return ExecuteEmbeddedPluginContext(context.Background(), pluginID, req)
+}
+
+// ExecuteEmbeddedPluginContext executes an embedded plugin like
+// ExecuteEmbeddedPlugin. When ctx is cancelled, handlers implementing the
+// context-aware variants are stopped and an error is returned.
+func ExecuteEmbeddedPluginContext(ctx context.Context, pluginID string, req *IPCRequest) (*IPCResponse, error) {
 	ep := GetEmbeddedPlugin(pluginID)
 	if ep == nil {
 		return nil, nil // Not an embedded plugin
 	}
+	if ep.Format == nil && ep.Tool == nil {
+		return nil, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("plugin execution cancelled: %w", err)
+	}
 
-	// Handle format plugins
+	var resp *IPCResponse
+	var err error
 	if ep.Format != nil {
-		return executeEmbeddedFormat(ep.Format, req)
+		resp, err = executeEmbeddedFormat(ctx, ep.Format, req)
+	} else {
+		resp, err = executeEmbeddedTool(ctx, ep.Tool, req)
 	}
 
-	// Handle tool plugins
-	if ep.Tool != nil {
-		return executeEmbeddedTool(ep.Tool, req)
+	// The caller has given up on a result that arrived too late
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("plugin execution cancelled: %w", ctx.Err())
 	}
-
-	return nil, nil
+	return resp, err
 }
 
 // executeEmbeddedFormat executes a format plugin request.
-func executeEmbeddedFormat(h EmbeddedFormatHandler, req *IPCRequest) (*IPCResponse, error) {
+func executeEmbeddedFormat(ctx context.Context, h EmbeddedFormatHandler, req *IPCRequest) (*IPCResponse, error) {
+	var result interface{}
+	var err error
 	switch req.Command {
 	case "detect":
 		path, _ := req.Args["path"].(string)
-		result, err := h.Detect(path)
-		if err != nil {
-			return &IPCResponse{Status: "error", Error: err.Error()}, nil
+		if hc, ok := h.(DetectorContext); ok {
+			result, err = hc.DetectContext(ctx, path)
+		} else {
+			result, err = h.Detect(path)
 		}
-		return &IPCResponse{Status: "ok", Result: result}, nil
 
 	case "ingest":
 		path, _ := req.Args["path"].(string)
 		outputDir, _ := req.Args["output_dir"].(string)
-		result, err := h.Ingest(path, outputDir)
-		if err != nil {
-			return &IPCResponse{Status: "error", Error: err.Error()}, nil
+		if hc, ok := h.(IngesterContext); ok {
+			result, err = hc.IngestContext(ctx, path, outputDir)
+		} else {
+			result, err = h.Ingest(path, outputDir)
 		}
-		return &IPCResponse{Status: "ok", Result: result}, nil
 
 	case "enumerate":
 		path, _ := req.Args["path"].(string)
-		result, err := h.Enumerate(path)
-		if err != nil {
-			return &IPCResponse{Status: "error", Error: err.Error()}, nil
+		if hc, ok := h.(EnumeratorContext); ok {
+			result, err = hc.EnumerateContext(ctx, path)
+		} else {
+			result, err = h.Enumerate(path)
 		}
-		return &IPCResponse{Status: "ok", Result: result}, nil
 
 	case "extract-ir":
 		path, _ := req.Args["path"].(string)
 		outputDir, _ := req.Args["output_dir"].(string)
-		result, err := h.ExtractIR(path, outputDir)
-		if err != nil {
-			return &IPCResponse{Status: "error", Error: err.Error()}, nil
+		if ho, ok := h.(IRExtractorOptions); ok {
+			result, err = ho.ExtractIROptions(ctx, path, outputDir, OptionsFromArgs(req.Args))
+		} else if hc, ok := h.(IRExtractorContext); ok {
+			result, err = hc.ExtractIRContext(ctx, path, outputDir)
+		} else {
+			result, err = h.ExtractIR(path, outputDir)
 		}
-		return &IPCResponse{Status: "ok", Result: result}, nil
 
 	case "emit-native":
 		irPath, _ := req.Args["ir_path"].(string)
 		outputDir, _ := req.Args["output_dir"].(string)
-		result, err := h.EmitNative(irPath, outputDir)
-		if err != nil {
-			return &IPCResponse{Status: "error", Error: err.Error()}, nil
+		if ho, ok := h.(NativeEmitterOptions); ok {
+			result, err = ho.EmitNativeOptions(ctx, irPath, outputDir, OptionsFromArgs(req.Args))
+		} else if hc, ok := h.(NativeEmitterContext); ok {
+			result, err = hc.EmitNativeContext(ctx, irPath, outputDir)
+		} else {
+			result, err = h.EmitNative(irPath, outputDir)
 		}
-		return &IPCResponse{Status: "ok", Result: result}, nil
 
 	default:
 		return &IPCResponse{Status: "error", Error: "unknown command: " + req.Command}, nil
 	}
+
+	if err != nil {
+		return &IPCResponse{Status: "error", Error: err.Error()}, nil
+	}
+	return &IPCResponse{Status: "ok", Result: result}, nil
 }
 
 // executeEmbeddedTool executes a tool plugin request.
-func executeEmbeddedTool(h EmbeddedToolHandler, req *IPCRequest) (*IPCResponse, error) {
-	result, err := h.Execute(req.Command, req.Args)
+func executeEmbeddedTool(ctx context.Context, h EmbeddedToolHandler, req *IPCRequest) (*IPCResponse, error) {
+	var result interface{}
+	var err error
+	if hc, ok := h.(ToolExecutorContext); ok {
+		result, err = hc.ExecuteContext(ctx, req.Command, req.Args)
+	} else {
+		result, err = h.Execute(req.Command, req.Args)
+	}
 	if err != nil {
 		return &IPCResponse{Status: "error", Error: err.Error()}, nil
 	}
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
+	"github.com/FocuswithJustin/JuniperBible/internal/logging"
 )
 
 // IPCRequest is the JSON request sent to plugins.
@@ -22,15 +26,43 @@ type IPCResponse struct {
 	Status string      `json:"status"`
 	Result interface{} `json:"result,omitempty"`
 	Error  string      `json:"error,omitempty"`
+
+	// Event is the content of an interim message, whose status is
+	// EventProgress or EventLog. Final responses do not set it.
+	Event *ProgressEvent `json:"event,omitempty"`
+
+	// Usage is the resources an external plugin process used. It is set
+	// by the host, not sent by plugins.
+	Usage *resource.Usage `json:"-"`
+
+	// BinarySHA256 is the SHA-256 of the external plugin entrypoint that
+	// handled the request. It is set by the host.
+	BinarySHA256 string `json:"-"`
 }
 
-// DetectResult is the result of a detect command.
+// DetectResult is the result of a detect command. Confidence and
+// Specificity rank the results of several plugins detecting the same
+// input; a plugin that reports neither is taken to be certain of a
+// specific format.
 type DetectResult struct {
-	Detected bool   `json:"detected"`
-	Format   string `json:"format,omitempty"`
-	Reason   string `json:"reason,omitempty"`
+	Detected    bool    `json:"detected"`
+	Format      string  `json:"format,omitempty"`
+	Reason      string  `json:"reason,omitempty"`
+	Confidence  float64 `json:"confidence,omitempty"`  // 0 to 1
+	Specificity int     `json:"specificity,omitempty"` // One of the Specificity constants
 }
 
+// Detection specificity, from the least to the most specific.
+const (
+	SpecificityAny     = 1 // Matches any file or directory
+	SpecificityGeneric = 2 // A generic syntax or container, such as XML, zip or tar
+	SpecificityFormat  = 3 // A specific format
+)
+
+// ExtensionConfidence is the confidence of a detection based on the file
+// extension alone.
+const ExtensionConfidence = 0.5
+
 // IngestResult is the result of an ingest command.
 type IngestResult struct {
 	ArtifactID string            `json:"artifact_id"`
@@ -64,6 +96,9 @@ type ExtractIRResult struct {
 	IRPath     string         `json:"ir_path"`
 	LossClass  string         `json:"loss_class,omitempty"`
 	LossReport *LossReportIPC `json:"loss_report,omitempty"`
+
+	// BinarySHA256 is copied from the response's BinarySHA256.
+	BinarySHA256 string `json:"-"`
 }
 
 // EmitNativeResult is the result of an emit-native command.
@@ -72,6 +107,9 @@ type EmitNativeResult struct {
 	Format     string         `json:"format"`
 	LossClass  string         `json:"loss_class,omitempty"`
 	LossReport *LossReportIPC `json:"loss_report,omitempty"`
+
+	// BinarySHA256 is copied from the response's BinarySHA256.
+	BinarySHA256 string `json:"-"`
 }
 
 // LossReportIPC represents loss information in IPC messages.
@@ -110,6 +148,22 @@ func DisableExternalPlugins() {
 	externalPluginsEnabled = false
 }
 
+// externalPluginLimits are the resource limits external plugin processes
+// run with.
+var externalPluginLimits = resource.DefaultLimits()
+
+// SetExternalPluginLimits sets the resource limits of external plugin
+// processes. Zero values leave the corresponding limit unchanged.
+func SetExternalPluginLimits(l resource.Limits) {
+	externalPluginLimits = l
+}
+
+// ExternalPluginLimits returns the resource limits of external plugin
+// processes.
+func ExternalPluginLimits() resource.Limits {
+	return externalPluginLimits
+}
+
 // ExternalPluginsEnabled returns whether external plugins are enabled.
 func ExternalPluginsEnabled() bool {
 	return externalPluginsEnabled
@@ -126,6 +180,23 @@ func ExecutePlugin(plugin *Plugin, req *IPCRequest) (*IPCResponse, error) {
 // Priority: external plugin (when enabled and available) > embedded plugin > external fallback.
 // This ensures external plugins override embedded ones when the user has them enabled.
 func ExecutePluginWithTimeout(plugin *Plugin, req *IPCRequest, timeout time.Duration) (*IPCResponse, error) {
+	return executePlugin(context.Background(), plugin, req, timeout)
+}
+
+// ExecutePluginContext executes a plugin like ExecutePlugin, stopping it
+// when ctx is cancelled. External plugins are also stopped after
+// DefaultTimeout. Interim messages are passed to the ProgressFunc set on
+// ctx with WithProgress.
+func ExecutePluginContext(ctx context.Context, plugin *Plugin, req *IPCRequest) (*IPCResponse, error) {
+	return executePlugin(ctx, plugin, req, DefaultTimeout)
+}
+
+func executePlugin(ctx context.Context, plugin *Plugin, req *IPCRequest, timeout time.Duration) (*IPCResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("plugin execution cancelled: %w", err)
+	}
+	ctx = withProgressSource(ctx, plugin.Manifest.PluginID, req.Command)
+
 	// Check if we have an external plugin binary available
 	entrypoint := ""
 	hasExternalBinary := false
@@ -138,26 +209,45 @@ func ExecutePluginWithTimeout(plugin *Plugin, req *IPCRequest, timeout time.Dura
 
 	// When external plugins are enabled and binary exists, prefer external
 	if externalPluginsEnabled && hasExternalBinary {
-		return executeExternalPlugin(plugin, req, entrypoint, timeout)
+		return executeExternalPlugin(ctx, plugin, req, entrypoint, timeout)
 	}
 
 	// Try embedded plugin
-	if resp, err := ExecuteEmbeddedPlugin(plugin.Manifest.PluginID, req); resp != nil || err != nil {
+	if resp, err := ExecuteEmbeddedPluginContext(ctx, plugin.Manifest.PluginID, req); resp != nil || err != nil {
 		// If embedded returns an error indicating "not implemented", try external fallback
 		if resp != nil && resp.Status == "error" && isNotImplementedError(resp.Error) && hasExternalBinary {
-			return executeExternalPlugin(plugin, req, entrypoint, timeout)
+			return executeExternalPlugin(ctx, plugin, req, entrypoint, timeout)
 		}
 		return resp, err
 	}
 
 	// Embedded plugin returned nil, nil - check if we have any fallback
 	if hasExternalBinary {
-		return executeExternalPlugin(plugin, req, entrypoint, timeout)
+		return executeExternalPlugin(ctx, plugin, req, entrypoint, timeout)
 	}
 
 	return nil, fmt.Errorf("plugin %s is not available as an embedded plugin and no external binary found", plugin.Manifest.PluginID)
 }
 
+// ExecuteExternalPluginContext executes a plugin's external entrypoint like
+// ExecutePluginContext, even when an embedded implementation with the same
+// ID exists or external plugins are disabled. It is used to compare both
+// implementations of a plugin.
+func ExecuteExternalPluginContext(ctx context.Context, plugin *Plugin, req *IPCRequest) (*IPCResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("plugin execution cancelled: %w", err)
+	}
+	if plugin.IsEmbedded() {
+		return nil, fmt.Errorf("plugin %s has no external entrypoint", plugin.Manifest.PluginID)
+	}
+	entrypoint := plugin.EntrypointPath()
+	if _, err := os.Stat(entrypoint); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", plugin.Manifest.PluginID, err)
+	}
+	ctx = withProgressSource(ctx, plugin.Manifest.PluginID, req.Command)
+	return executeExternalPlugin(ctx, plugin, req, entrypoint, DefaultTimeout)
+}
+
 // isNotImplementedError checks if an error message indicates an unimplemented feature.
 func isNotImplementedError(msg string) bool {
 	return strings.Contains(msg, "requires external plugin") ||
@@ -165,10 +255,40 @@ func isNotImplementedError(msg string) bool {
 		strings.Contains(msg, "not supported")
 }
 
-// executeExternalPlugin runs a plugin as an external process.
-func executeExternalPlugin(plugin *Plugin, req *IPCRequest, entrypoint string, timeout time.Duration) (*IPCResponse, error) {
+// executeExternalPlugin runs a plugin as an external process. Plugins
+// declaring ProtocolJSONRPC are sent the request over their session,
+// started on first use; when the session cannot be started, or for other
+// plugins, a process is started for the request. WebAssembly plugins run
+// in-process instead. The plugin must pass the trust policy, and the hash
+// of the entrypoint that served the request is recorded in the response.
+func executeExternalPlugin(ctx context.Context, plugin *Plugin, req *IPCRequest, entrypoint string, timeout time.Duration) (*IPCResponse, error) {
+	binaryHash, err := checkEntrypoint(plugin, entrypoint)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := runExternalPlugin(ctx, plugin, req, entrypoint, binaryHash, timeout)
+	if resp != nil && resp.BinarySHA256 == "" {
+		resp.BinarySHA256 = binaryHash
+	}
+	return resp, err
+}
 
-	// Use external plugin execution
+func runExternalPlugin(ctx context.Context, plugin *Plugin, req *IPCRequest, entrypoint, binaryHash string, timeout time.Duration) (*IPCResponse, error) {
+	if plugin.Manifest.IsWASM() {
+		return executeWASMPlugin(ctx, plugin, req, entrypoint, binaryHash, timeout)
+	}
+	if plugin.Manifest.Protocol == ProtocolJSONRPC && persistentPluginsEnabled {
+		session, err := sessionPool.Session(plugin, entrypoint, binaryHash)
+		if err == nil {
+			resp, err := executeSessionRequest(ctx, session, req, timeout)
+			if resp != nil {
+				resp.BinarySHA256 = session.BinarySHA256()
+			}
+			return resp, err
+		}
+		logging.Debug("plugin session unavailable, using one-shot mode",
+			"plugin_id", plugin.Manifest.PluginID, "error", err)
+	}
 
 	// Encode request as JSON
 	reqData, err := json.Marshal(req)
@@ -177,24 +297,47 @@ func executeExternalPlugin(plugin *Plugin, req *IPCRequest, entrypoint string, t
 	}
 
 	// Create context with timeout - this handles cancellation properly
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	// Create command with context - process is killed when context is cancelled
-	cmd := exec.CommandContext(ctx, entrypoint)
+	limits := externalPluginLimits
+	cmd := pluginCommand(runCtx, limits, entrypoint)
 	cmd.Dir = plugin.Path
 
 	// Set up stdin
 	cmd.Stdin = bytes.NewReader(reqData)
 
-	// Capture stdout and stderr
+	// Read stdout as it is written so interim messages are reported while
+	// the plugin runs
+	pr, pw := io.Pipe()
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
+	cmd.Stdout = pw
 	cmd.Stderr = &stderr
+	type decoded struct {
+		resp *IPCResponse
+		err  error
+	}
+	decodeCh := make(chan decoded, 1)
+	go func() {
+		resp, err := decodeResponse(io.TeeReader(pr, &stdout), progressFunc(ctx))
+		io.Copy(io.Discard, pr)
+		decodeCh <- decoded{resp, err}
+	}()
 
 	// Run command - CommandContext handles process cleanup on timeout
+	start := time.Now()
 	err = cmd.Run()
-	if ctx.Err() == context.DeadlineExceeded {
+	pw.Close()
+	out := <-decodeCh
+	usage := resource.Measure(cmd.ProcessState, time.Since(start))
+	usage.Limits = &limits
+	logging.Debug("plugin process finished", "plugin_id", plugin.Manifest.PluginID,
+		"command", req.Command, "usage", usage.String())
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("plugin execution cancelled: %w", ctx.Err())
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
 		return nil, fmt.Errorf("plugin execution timed out after %v", timeout)
 	}
 	if err != nil {
@@ -202,12 +345,31 @@ func executeExternalPlugin(plugin *Plugin, req *IPCRequest, entrypoint string, t
 	}
 
 	// Decode response
-	var resp IPCResponse
-	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w (output: %s)", err, stdout.String())
+	if out.err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w (output: %s)", out.err, stdout.String())
 	}
+	out.resp.Usage = usage
 
-	return &resp, nil
+	return out.resp, nil
+}
+
+// executeSessionRequest sends a request over a plugin session. A request
+// that times out or whose context is cancelled is cancelled in the
+// plugin; the session stays up for later requests.
+func executeSessionRequest(ctx context.Context, session *Session, req *IPCRequest, timeout time.Duration) (*IPCResponse, error) {
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	resp, err := session.Call(callCtx, req)
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("plugin execution cancelled: %w", ctx.Err())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("plugin execution timed out after %v", timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plugin execution failed: %w", err)
+	}
+	return resp, nil
 }
 
 // NewDetectRequest creates a detect request.
@@ -361,6 +523,7 @@ func ParseExtractIRResult(resp *IPCResponse) (*ExtractIRResult, error) {
 	if err := json.Unmarshal(data, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse extract-ir result: %w", err)
 	}
+	result.BinarySHA256 = resp.BinarySHA256
 
 	return &result, nil
 }
@@ -380,6 +543,7 @@ func ParseEmitNativeResult(resp *IPCResponse) (*EmitNativeResult, error) {
 	if err := json.Unmarshal(data, &result); err != nil {
 		return nil, fmt.Errorf("failed to parse emit-native result: %w", err)
 	}
+	result.BinarySHA256 = resp.BinarySHA256
 
 	return &result, nil
 }
@@ -4,11 +4,16 @@
 package plugins
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	apperrors "github.com/FocuswithJustin/JuniperBible/core/errors"
 	"github.com/FocuswithJustin/JuniperBible/internal/logging"
@@ -39,6 +44,27 @@ type PluginManifest struct {
 	// IRSupport describes the plugin's IR extraction/emission capabilities.
 	// Only applicable to format plugins that support the IR pipeline.
 	IRSupport *IRCapabilities `json:"ir_support,omitempty"`
+	// Options are the options the plugin accepts for extract-ir and
+	// emit-native (see ResolveOptions).
+	Options []OptionSpec `json:"options,omitempty"`
+	// Protocol is how the host talks to an external plugin: ProtocolOneShot
+	// (the default) or ProtocolJSONRPC for a persistent session.
+	Protocol string `json:"protocol,omitempty"`
+	// Runtime is what the entrypoint runs on: RuntimeNative (the default)
+	// or RuntimeWASM for a sandboxed WebAssembly module. Entrypoints
+	// ending in .wasm default to RuntimeWASM.
+	Runtime string `json:"runtime,omitempty"`
+	// EntrypointSHA256 pins the entrypoint binary. It is set when the
+	// manifest is signed (see SignPlugin).
+	EntrypointSHA256 string `json:"entrypoint_sha256,omitempty"`
+}
+
+// IsWASM reports whether the plugin's entrypoint is a WebAssembly module.
+func (m *PluginManifest) IsWASM() bool {
+	if m.Runtime != "" {
+		return m.Runtime == RuntimeWASM
+	}
+	return strings.HasSuffix(strings.ToLower(m.Entrypoint), ".wasm")
 }
 
 // Capabilities describes what a plugin can do.
@@ -60,6 +86,11 @@ type IRCapabilities struct {
 type Plugin struct {
 	Manifest *PluginManifest
 	Path     string // Directory containing the plugin
+
+	// signature is the verified signature status, cached by
+	// VerifySignature under signatureMu.
+	signatureMu sync.Mutex
+	signature   *SignatureStatus
 }
 
 // Loader manages plugin discovery and loading.
@@ -108,6 +139,10 @@ func (l *Loader) LoadFromDir(dir string) error {
 			fmt.Fprintf(os.Stderr, "Warning: skipping incompatible plugin %s: %v\n", p.Manifest.PluginID, err)
 			continue
 		}
+		if err := CheckPluginTrust(p); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping plugin: %v\n", err)
+			continue
+		}
 		// External plugins can override embedded ones
 		l.plugins[p.Manifest.PluginID] = p
 		logging.PluginLoading(p.Manifest.PluginID, p.Manifest.Version, p.Manifest.Kind,
@@ -323,6 +358,12 @@ func ParsePluginManifest(path string) (*PluginManifest, error) {
 	if manifest.Entrypoint == "" {
 		return nil, apperrors.NewValidation("entrypoint", "is required")
 	}
+	if manifest.Runtime != "" && manifest.Runtime != RuntimeNative && manifest.Runtime != RuntimeWASM {
+		return nil, apperrors.NewValidation("runtime", "must be native or wasm")
+	}
+	if err := validateOptionSpecs(manifest.Options); err != nil {
+		return nil, err
+	}
 
 	return &manifest, nil
 }
@@ -332,6 +373,36 @@ func (p *Plugin) EntrypointPath() string {
 	return filepath.Join(p.Path, p.Manifest.Entrypoint)
 }
 
+// IsEmbedded returns true if the plugin is compiled into the host binary.
+func (p *Plugin) IsEmbedded() bool {
+	return p.Path == "(embedded)"
+}
+
+// BinarySHA256 returns the SHA-256 of the executable that implements the plugin.
+// For embedded plugins this is the host binary itself.
+func (p *Plugin) BinarySHA256() (string, error) {
+	path := p.EntrypointPath()
+	if p.IsEmbedded() {
+		exe, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate host binary: %w", err)
+		}
+		path = exe
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", apperrors.NewIO("open", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", apperrors.NewIO("read", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // IsFormat returns true if this is a format plugin.
 func (p *Plugin) IsFormat() bool {
 	return p.Manifest.Kind == "format"
@@ -433,6 +504,17 @@ func (l *Loader) AddPlugin(p *Plugin) {
 	l.plugins[p.Manifest.PluginID] = p
 }
 
+// Close shuts down the running sessions of the loader's plugins.
+func (l *Loader) Close() {
+	plugins := make([]*Plugin, 0, len(l.plugins))
+	for _, p := range l.plugins {
+		plugins = append(plugins, p)
+	}
+	if len(plugins) > 0 {
+		sessionPool.Close(plugins...)
+	}
+}
+
 // LoadFromDirAlways loads plugins from a directory regardless of ExternalPluginsEnabled setting.
 // This is useful for testing the plugin discovery mechanism.
 func (l *Loader) LoadFromDirAlways(dir string) error {
@@ -447,6 +529,10 @@ func (l *Loader) LoadFromDirAlways(dir string) error {
 			fmt.Fprintf(os.Stderr, "Warning: skipping incompatible plugin %s: %v\n", p.Manifest.PluginID, err)
 			continue
 		}
+		if err := CheckPluginTrust(p); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping plugin: %v\n", err)
+			continue
+		}
 		l.plugins[p.Manifest.PluginID] = p
 	}
 
@@ -1500,3 +1500,34 @@ func TestDiscoverPluginsMixedValidInvalid(t *testing.T) {
 		t.Error("format.valid-nested plugin not found")
 	}
 }
+
+// TestPluginBinarySHA256 tests hashing of external and embedded plugin binaries.
+func TestPluginBinarySHA256(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "plugin.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("failed to write entrypoint: %v", err)
+	}
+
+	p := &Plugin{Manifest: &PluginManifest{PluginID: "test", Entrypoint: "plugin.sh"}, Path: dir}
+	hash, err := p.BinarySHA256()
+	if err != nil {
+		t.Fatalf("BinarySHA256 failed: %v", err)
+	}
+	// sha256("#!/bin/sh\n")
+	if hash != "a8076d3d28d21e02012b20eaf7dbf75409a6277134439025f282e368e3305abf" {
+		t.Errorf("unexpected hash %s", hash)
+	}
+
+	embedded := &Plugin{Manifest: &PluginManifest{PluginID: "embedded"}, Path: "(embedded)"}
+	if !embedded.IsEmbedded() {
+		t.Error("expected plugin to be embedded")
+	}
+	if _, err := embedded.BinarySHA256(); err != nil {
+		t.Errorf("BinarySHA256 for embedded plugin failed: %v", err)
+	}
+
+	missing := &Plugin{Manifest: &PluginManifest{PluginID: "missing", Entrypoint: "nope"}, Path: dir}
+	if _, err := missing.BinarySHA256(); err == nil {
+		t.Error("expected error for missing entrypoint")
+	}
+}
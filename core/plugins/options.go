@@ -0,0 +1,265 @@
+package plugins
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	apperrors "github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// Option types.
+const (
+	OptionString = "string"
+	OptionBool   = "bool"
+	OptionInt    = "int"
+	OptionEnum   = "enum"
+)
+
+// OptionSpec describes an option a format plugin accepts for extract-ir or
+// emit-native. Plugins declare their options in plugin.json:
+//
+//	"options": [
+//	  {"name": "verse_style", "type": "enum", "values": ["milestone", "container"],
+//	   "default": "milestone", "commands": ["emit-native"]}
+//	]
+type OptionSpec struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"` // One of the Option type constants
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+
+	// Values are the allowed values of an enum option.
+	Values []string `json:"values,omitempty"`
+
+	// Min and Max bound an int option.
+	Min *int `json:"min,omitempty"`
+	Max *int `json:"max,omitempty"`
+
+	// Commands are the commands taking the option: "extract-ir",
+	// "emit-native" or both when empty.
+	Commands []string `json:"commands,omitempty"`
+}
+
+// AppliesTo reports whether the option is taken by command.
+func (s *OptionSpec) AppliesTo(command string) bool {
+	if len(s.Commands) == 0 {
+		return true
+	}
+	for _, c := range s.Commands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+// Options are the options of a call, by name. Values are strings, bools
+// or ints.
+type Options map[string]interface{}
+
+// String returns a string or enum option, or def if it is not set.
+func (o Options) String(name, def string) string {
+	if s, ok := o[name].(string); ok {
+		return s
+	}
+	return def
+}
+
+// Bool returns a bool option, or def if it is not set.
+func (o Options) Bool(name string, def bool) bool {
+	if b, ok := o[name].(bool); ok {
+		return b
+	}
+	return def
+}
+
+// Int returns an int option, or def if it is not set. Numbers decoded
+// from JSON are accepted.
+func (o Options) Int(name string, def int) int {
+	switch v := o[name].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
+// OptionsFromArgs returns the options of an IPC request's args.
+func OptionsFromArgs(args map[string]interface{}) Options {
+	if m, ok := args["options"].(map[string]interface{}); ok {
+		return Options(m)
+	}
+	return nil
+}
+
+// WithOptions sets the options of a request and returns it. A request
+// without options is left unchanged.
+func (r *IPCRequest) WithOptions(opts Options) *IPCRequest {
+	if len(opts) == 0 {
+		return r
+	}
+	if r.Args == nil {
+		r.Args = make(map[string]interface{})
+	}
+	r.Args["options"] = map[string]interface{}(opts)
+	return r
+}
+
+// ResolveOptions validates the options of a call to command against the
+// plugin's declared options and converts them to their types. Values may
+// be typed or strings, as given on a command line. Declared defaults fill
+// in the options not given, so the result records every option the call
+// ran with.
+func (m *PluginManifest) ResolveOptions(command string, values map[string]interface{}) (Options, error) {
+	specs := make(map[string]*OptionSpec)
+	for i := range m.Options {
+		if spec := &m.Options[i]; spec.AppliesTo(command) {
+			specs[spec.Name] = spec
+		}
+	}
+
+	opts := make(Options)
+	for name, value := range values {
+		spec, ok := specs[name]
+		if !ok {
+			return nil, apperrors.NewValidation("options."+name, fmt.Sprintf("is not an option of %s %s%s", m.PluginID, command, optionNames(specs)))
+		}
+		v, err := spec.convert(value)
+		if err != nil {
+			return nil, apperrors.NewValidation("options."+name, err.Error())
+		}
+		opts[name] = v
+	}
+	for name, spec := range specs {
+		if _, ok := opts[name]; ok || spec.Default == nil {
+			continue
+		}
+		v, err := spec.convert(spec.Default)
+		if err != nil {
+			return nil, apperrors.NewValidation("options."+name, "invalid default: "+err.Error())
+		}
+		opts[name] = v
+	}
+	return opts, nil
+}
+
+// convert checks a value against the spec and converts it to the
+// option's type.
+func (s *OptionSpec) convert(value interface{}) (interface{}, error) {
+	str, isString := value.(string)
+	switch s.Type {
+	case OptionString:
+		if !isString {
+			return nil, fmt.Errorf("must be a string")
+		}
+		return str, nil
+
+	case OptionEnum:
+		if !isString {
+			return nil, fmt.Errorf("must be one of %s", strings.Join(s.Values, ", "))
+		}
+		for _, v := range s.Values {
+			if v == str {
+				return str, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not one of %s", str, strings.Join(s.Values, ", "))
+
+	case OptionBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		if isString {
+			if b, err := strconv.ParseBool(str); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("must be true or false")
+
+	case OptionInt:
+		var n int
+		switch v := value.(type) {
+		case int:
+			n = v
+		case int64:
+			n = int(v)
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, fmt.Errorf("must be an integer")
+			}
+			n = int(v)
+		case string:
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("must be an integer")
+			}
+			n = i
+		default:
+			return nil, fmt.Errorf("must be an integer")
+		}
+		if s.Min != nil && n < *s.Min {
+			return nil, fmt.Errorf("must be at least %d", *s.Min)
+		}
+		if s.Max != nil && n > *s.Max {
+			return nil, fmt.Errorf("must be at most %d", *s.Max)
+		}
+		return n, nil
+
+	default:
+		return nil, fmt.Errorf("has unknown type %q", s.Type)
+	}
+}
+
+// optionNames lists the options a command takes for an error message.
+func optionNames(specs map[string]*OptionSpec) string {
+	if len(specs) == 0 {
+		return " (it takes none)"
+	}
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return " (options: " + strings.Join(names, ", ") + ")"
+}
+
+// validateOptionSpecs checks the option declarations of a manifest.
+func validateOptionSpecs(specs []OptionSpec) error {
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		field := "options." + spec.Name
+		if spec.Name == "" {
+			return apperrors.NewValidation("options", "option name is required")
+		}
+		if seen[spec.Name] {
+			return apperrors.NewValidation(field, "is declared twice")
+		}
+		seen[spec.Name] = true
+		switch spec.Type {
+		case OptionString, OptionBool, OptionInt:
+		case OptionEnum:
+			if len(spec.Values) == 0 {
+				return apperrors.NewValidation(field, "enum option needs values")
+			}
+		default:
+			return apperrors.NewValidation(field, fmt.Sprintf("has unknown type %q", spec.Type))
+		}
+		for _, c := range spec.Commands {
+			if c != "extract-ir" && c != "emit-native" {
+				return apperrors.NewValidation(field, fmt.Sprintf("unknown command %q", c))
+			}
+		}
+		if spec.Default != nil {
+			if _, err := spec.convert(spec.Default); err != nil {
+				return apperrors.NewValidation(field, "invalid default: "+err.Error())
+			}
+		}
+	}
+	return nil
+}
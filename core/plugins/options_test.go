@@ -0,0 +1,193 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func intPtr(n int) *int { return &n }
+
+func optionsManifest() *PluginManifest {
+	return &PluginManifest{
+		PluginID: "format.test",
+		Options: []OptionSpec{
+			{Name: "verse_style", Type: OptionEnum, Values: []string{"milestone", "container"}, Default: "milestone", Commands: []string{"emit-native"}},
+			{Name: "block_size", Type: OptionInt, Default: float64(4096), Min: intPtr(512), Commands: []string{"emit-native"}},
+			{Name: "strict", Type: OptionBool, Commands: []string{"extract-ir"}},
+			{Name: "title", Type: OptionString},
+		},
+	}
+}
+
+func TestResolveOptions(t *testing.T) {
+	m := optionsManifest()
+
+	opts, err := m.ResolveOptions("emit-native", map[string]interface{}{
+		"verse_style": "container",
+		"block_size":  "8192",
+	})
+	if err != nil {
+		t.Fatalf("ResolveOptions failed: %v", err)
+	}
+	if got := opts.String("verse_style", ""); got != "container" {
+		t.Errorf("verse_style = %q, want container", got)
+	}
+	if got := opts.Int("block_size", 0); got != 8192 {
+		t.Errorf("block_size = %d, want 8192", got)
+	}
+	if _, ok := opts["strict"]; ok {
+		t.Error("extract-ir option should not be resolved for emit-native")
+	}
+}
+
+func TestResolveOptionsDefaults(t *testing.T) {
+	m := optionsManifest()
+
+	opts, err := m.ResolveOptions("emit-native", nil)
+	if err != nil {
+		t.Fatalf("ResolveOptions failed: %v", err)
+	}
+	if got := opts.String("verse_style", ""); got != "milestone" {
+		t.Errorf("verse_style = %q, want default milestone", got)
+	}
+	if got, ok := opts["block_size"].(int); !ok || got != 4096 {
+		t.Errorf("block_size = %v, want default 4096 as int", opts["block_size"])
+	}
+	if _, ok := opts["title"]; ok {
+		t.Error("option without default should not be set")
+	}
+
+	opts, err = m.ResolveOptions("extract-ir", map[string]interface{}{"strict": "true"})
+	if err != nil {
+		t.Fatalf("ResolveOptions failed: %v", err)
+	}
+	if !opts.Bool("strict", false) {
+		t.Error("strict should be true")
+	}
+}
+
+func TestResolveOptionsErrors(t *testing.T) {
+	m := optionsManifest()
+
+	tests := []struct {
+		name    string
+		command string
+		values  map[string]interface{}
+		want    string
+	}{
+		{"unknown option", "emit-native", map[string]interface{}{"layout": "single"}, "is not an option"},
+		{"wrong command", "extract-ir", map[string]interface{}{"verse_style": "milestone"}, "is not an option"},
+		{"enum value", "emit-native", map[string]interface{}{"verse_style": "inline"}, "is not one of"},
+		{"int syntax", "emit-native", map[string]interface{}{"block_size": "big"}, "must be an integer"},
+		{"int fraction", "emit-native", map[string]interface{}{"block_size": 1.5}, "must be an integer"},
+		{"int minimum", "emit-native", map[string]interface{}{"block_size": 100}, "at least 512"},
+		{"bool syntax", "extract-ir", map[string]interface{}{"strict": "maybe"}, "true or false"},
+		{"string type", "emit-native", map[string]interface{}{"title": 3}, "must be a string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.ResolveOptions(tt.command, tt.values)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithOptions(t *testing.T) {
+	req := NewEmitNativeRequest("/ir.json", "/out")
+	req.WithOptions(nil)
+	if _, ok := req.Args["options"]; ok {
+		t.Error("empty options should not be set")
+	}
+
+	req.WithOptions(Options{"verse_style": "container"})
+	if got := OptionsFromArgs(req.Args).String("verse_style", ""); got != "container" {
+		t.Errorf("verse_style = %q, want container", got)
+	}
+
+	// Options survive the JSON encoding of the IPC protocol
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	var decoded IPCRequest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if got := OptionsFromArgs(decoded.Args).String("verse_style", ""); got != "container" {
+		t.Errorf("decoded verse_style = %q, want container", got)
+	}
+}
+
+func TestParsePluginManifestOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		wantErr bool
+	}{
+		{"valid", `[{"name": "layout", "type": "enum", "values": ["single", "chapter"], "default": "single"}]`, false},
+		{"unknown type", `[{"name": "layout", "type": "list"}]`, true},
+		{"enum without values", `[{"name": "layout", "type": "enum"}]`, true},
+		{"duplicate", `[{"name": "a", "type": "bool"}, {"name": "a", "type": "bool"}]`, true},
+		{"unknown command", `[{"name": "a", "type": "bool", "commands": ["ingest"]}]`, true},
+		{"bad default", `[{"name": "a", "type": "int", "default": "many"}]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "plugin.json")
+			manifest := `{"plugin_id": "format.test", "version": "1.0.0", "kind": "format",
+				"entrypoint": "format-test", "options": ` + tt.options + `}`
+			if err := os.WriteFile(path, []byte(manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ParsePluginManifest(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePluginManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// optionsFormatHandler records the options it is called with.
+type optionsFormatHandler struct {
+	mockFormatHandler
+	got Options
+}
+
+func (h *optionsFormatHandler) EmitNativeOptions(ctx context.Context, irPath, outputDir string, opts Options) (*EmitNativeResult, error) {
+	h.got = opts
+	return &EmitNativeResult{OutputPath: filepath.Join(outputDir, "out.osis")}, nil
+}
+
+func TestExecuteEmbeddedPluginOptions(t *testing.T) {
+	ClearEmbeddedRegistry()
+	defer ClearEmbeddedRegistry()
+
+	h := &optionsFormatHandler{}
+	RegisterEmbeddedPlugin(&EmbeddedPlugin{
+		Manifest: &PluginManifest{PluginID: "format.test", Kind: "format"},
+		Format:   h,
+	})
+
+	req := NewEmitNativeRequest("/ir.json", "/out").WithOptions(Options{"verse_style": "container"})
+	resp, err := ExecuteEmbeddedPlugin("format.test", req)
+	if err != nil {
+		t.Fatalf("ExecuteEmbeddedPlugin failed: %v", err)
+	}
+	if resp.Status != "ok" {
+		t.Fatalf("status = %q, error %q", resp.Status, resp.Error)
+	}
+	if got := h.got.String("verse_style", ""); got != "container" {
+		t.Errorf("handler got verse_style %q, want container", got)
+	}
+}
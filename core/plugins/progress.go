@@ -0,0 +1,121 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Kinds of interim messages a plugin sends before its final response.
+const (
+	EventProgress = "progress"
+	EventLog      = "log"
+)
+
+// ProgressEvent is an interim message from a plugin: a progress update or
+// a log line. One-shot plugins write it to stdout as a line of the form
+// {"status":"progress","event":{...}} before their final response;
+// session plugins send it as a $/progress notification naming the
+// request ID.
+type ProgressEvent struct {
+	Kind    string `json:"kind,omitempty"`    // EventProgress or EventLog
+	Stage   string `json:"stage,omitempty"`   // Short name of the current step
+	Message string `json:"message,omitempty"` // Human-readable status
+	Level   string `json:"level,omitempty"`   // Log level: debug, info, warn, error
+	Done    int64  `json:"done,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+
+	// PluginID and Command identify the call. They are set by the host.
+	PluginID string `json:"plugin_id,omitempty"`
+	Command  string `json:"command,omitempty"`
+}
+
+// Percent returns Done as a percentage of Total, or -1 when the total is
+// unknown.
+func (e ProgressEvent) Percent() int {
+	if e.Total <= 0 {
+		return -1
+	}
+	pct := int(e.Done * 100 / e.Total)
+	return min(max(pct, 0), 100)
+}
+
+// ProgressFunc receives the interim messages of plugin calls. It is
+// called from the goroutine reading the plugin's output and must not
+// block.
+type ProgressFunc func(ProgressEvent)
+
+type progressKey struct{}
+
+// WithProgress returns a context whose plugin calls report their interim
+// messages to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress sends an event to the ProgressFunc of ctx, if any.
+// Embedded handlers use it to report progress like external plugins do.
+func ReportProgress(ctx context.Context, ev ProgressEvent) {
+	if fn := progressFunc(ctx); fn != nil {
+		if ev.Kind == "" {
+			ev.Kind = EventProgress
+		}
+		fn(ev)
+	}
+}
+
+func progressFunc(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// withProgressSource returns a context whose ProgressFunc fills in the
+// plugin ID and command of events that do not name them.
+func withProgressSource(ctx context.Context, pluginID, command string) context.Context {
+	fn := progressFunc(ctx)
+	if fn == nil {
+		return ctx
+	}
+	return WithProgress(ctx, func(ev ProgressEvent) {
+		if ev.PluginID == "" {
+			ev.PluginID = pluginID
+		}
+		if ev.Command == "" {
+			ev.Command = command
+		}
+		fn(ev)
+	})
+}
+
+// isInterim reports whether a one-shot response line is an interim
+// message rather than the final response.
+func isInterim(status string) bool {
+	return status == EventProgress || status == EventLog
+}
+
+// decodeResponse reads the output of a one-shot plugin: any number of
+// interim messages, which are passed to fn, followed by the final
+// response.
+func decodeResponse(r io.Reader, fn ProgressFunc) (*IPCResponse, error) {
+	dec := json.NewDecoder(r)
+	for {
+		var resp IPCResponse
+		if err := dec.Decode(&resp); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("no response from plugin")
+			}
+			return nil, err
+		}
+		if !isInterim(resp.Status) {
+			resp.Event = nil
+			return &resp, nil
+		}
+		if fn != nil && resp.Event != nil {
+			ev := *resp.Event
+			ev.Kind = resp.Status
+			fn(ev)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// progressRecorder collects the events passed to its ProgressFunc.
+type progressRecorder struct {
+	mu     sync.Mutex
+	events []ProgressEvent
+}
+
+func (r *progressRecorder) record(ev ProgressEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+}
+
+func (r *progressRecorder) get() []ProgressEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ProgressEvent(nil), r.events...)
+}
+
+func TestDecodeResponseInterimMessages(t *testing.T) {
+	out := `{"status":"progress","event":{"stage":"parse","done":1,"total":4}}
+{"status":"log","event":{"level":"warn","message":"odd marker"}}
+{"status":"ok","result":{"detected":true}}
+`
+	var rec progressRecorder
+	resp, err := decodeResponse(strings.NewReader(out), rec.record)
+	if err != nil {
+		t.Fatalf("decodeResponse failed: %v", err)
+	}
+	if resp.Status != "ok" || resp.Event != nil {
+		t.Errorf("unexpected final response %+v", resp)
+	}
+	events := rec.get()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[0].Kind != EventProgress || events[0].Percent() != 25 {
+		t.Errorf("unexpected progress event %+v", events[0])
+	}
+	if events[1].Kind != EventLog || events[1].Level != "warn" {
+		t.Errorf("unexpected log event %+v", events[1])
+	}
+
+	if _, err := decodeResponse(strings.NewReader(`{"status":"progress","event":{}}`), nil); err == nil {
+		t.Error("expected an error for output without a final response")
+	}
+}
+
+func TestProgressEventPercent(t *testing.T) {
+	tests := []struct {
+		done, total int64
+		want        int
+	}{
+		{0, 0, -1},
+		{5, 0, -1},
+		{0, 10, 0},
+		{3, 4, 75},
+		{12, 10, 100},
+	}
+	for _, tt := range tests {
+		if got := (ProgressEvent{Done: tt.done, Total: tt.total}).Percent(); got != tt.want {
+			t.Errorf("Percent(%d/%d) = %d, want %d", tt.done, tt.total, got, tt.want)
+		}
+	}
+}
+
+// contextHandler is a format handler whose ExtractIR supports
+// cancellation and progress.
+type contextHandler struct {
+	mockFormatHandler
+}
+
+func (h *contextHandler) ExtractIRContext(ctx context.Context, path, outputDir string) (*ExtractIRResult, error) {
+	for i := int64(1); i <= 2; i++ {
+		ReportProgress(ctx, ProgressEvent{Stage: "extract", Done: i, Total: 2})
+	}
+	if path == "block" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &ExtractIRResult{IRPath: "ctx.ir.json"}, nil
+}
+
+func TestExecutePluginContextEmbedded(t *testing.T) {
+	ClearEmbeddedRegistry()
+	defer ClearEmbeddedRegistry()
+	RegisterEmbeddedPlugin(&EmbeddedPlugin{
+		Manifest: &PluginManifest{PluginID: "format.ctx", Kind: "format"},
+		Format:   &contextHandler{},
+	})
+	plugin := &Plugin{Manifest: &PluginManifest{PluginID: "format.ctx"}, Path: "(embedded)"}
+
+	var rec progressRecorder
+	ctx := WithProgress(context.Background(), rec.record)
+	resp, err := ExecutePluginContext(ctx, plugin, NewExtractIRRequest("in.osis", t.TempDir()))
+	if err != nil || resp.Status != "ok" {
+		t.Fatalf("extract-ir failed: %v %+v", err, resp)
+	}
+	events := rec.get()
+	if len(events) != 2 || events[1].Percent() != 100 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if events[0].PluginID != "format.ctx" || events[0].Command != "extract-ir" || events[0].Kind != EventProgress {
+		t.Errorf("event not attributed to the call: %+v", events[0])
+	}
+
+	// A cancelled call stops the handler
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := ExecutePluginContext(ctx, plugin, NewExtractIRRequest("block", t.TempDir())); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancellation error, got %v", err)
+	}
+
+	// Handlers without context variants are not started once cancelled
+	if _, err := ExecutePluginContext(ctx, plugin, NewDetectRequest("in.osis")); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancellation error, got %v", err)
+	}
+}
+
+func TestExecutePluginContextExternalProgress(t *testing.T) {
+	plugin := sessionPlugin(t)
+	EnableExternalPlugins()
+	defer DisableExternalPlugins()
+	defer ShutdownPluginSessions()
+
+	for _, persistent := range []bool{true, false} {
+		if !persistent {
+			DisablePersistentPlugins()
+			defer EnablePersistentPlugins()
+		}
+		var rec progressRecorder
+		ctx := WithProgress(context.Background(), rec.record)
+		resp, err := ExecutePluginContext(ctx, plugin, &IPCRequest{Command: "progress"})
+		if err != nil || resp.Status != "ok" || resp.Result != "done" {
+			t.Fatalf("persistent=%v: unexpected response %+v, %v", persistent, resp, err)
+		}
+		events := rec.get()
+		if len(events) != 4 {
+			t.Fatalf("persistent=%v: got %d events, want 4: %+v", persistent, len(events), events)
+		}
+		if events[2].Percent() != 100 || events[2].Message != "step 3" || events[2].PluginID != "test.session" {
+			t.Errorf("persistent=%v: unexpected progress event %+v", persistent, events[2])
+		}
+		if events[3].Kind != EventLog || events[3].Message != "all steps done" {
+			t.Errorf("persistent=%v: unexpected log event %+v", persistent, events[3])
+		}
+
+		// Cancelling the context stops the request
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		start := time.Now()
+		if _, err := ExecutePluginContext(ctx, plugin, &IPCRequest{Command: "block"}); !errors.Is(err, context.Canceled) {
+			t.Errorf("persistent=%v: expected a cancellation error, got %v", persistent, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("persistent=%v: cancellation took %v", persistent, elapsed)
+		}
+	}
+}
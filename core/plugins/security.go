@@ -25,6 +25,17 @@ type SecurityConfig struct {
 	// RestrictToKnownKinds enforces that plugin kinds must be in PluginKinds list.
 	// Default: true
 	RestrictToKnownKinds bool
+
+	// TrustPolicy decides which external plugins are loaded and run by
+	// their signatures. Empty means TrustAll.
+	TrustPolicy TrustPolicy
+
+	// TrustedPublishers are the publishers TrustPublishers allows, by
+	// their trust store names.
+	TrustedPublishers []string
+
+	// TrustStore holds the publisher keys signatures are verified with.
+	TrustStore *TrustStore
 }
 
 var (
@@ -0,0 +1,609 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
+	"github.com/FocuswithJustin/JuniperBible/internal/logging"
+)
+
+// Plugin protocols, declared by the protocol field of plugin.json.
+const (
+	// ProtocolOneShot starts a process per request, which reads one JSON
+	// request on stdin and writes one JSON response on stdout. Plugins
+	// that declare no protocol use it.
+	ProtocolOneShot = "oneshot"
+
+	// ProtocolJSONRPC keeps a process running per plugin. Started with
+	// SessionFlag, the plugin exchanges newline-framed JSON-RPC 2.0
+	// messages: an initialize handshake, then command requests that may
+	// overlap, $/cancel notifications, and shutdown followed by exit.
+	ProtocolJSONRPC = "jsonrpc"
+)
+
+// SessionFlag is the argument a plugin is started with to serve a session.
+const SessionFlag = "--jsonrpc"
+
+// SessionProtocolVersion is the session protocol version the host speaks.
+const SessionProtocolVersion = 1
+
+// Session timeouts.
+const (
+	SessionStartTimeout    = 10 * time.Second
+	SessionShutdownTimeout = 5 * time.Second
+)
+
+// JSON-RPC error codes.
+const (
+	RPCParseError       = -32700
+	RPCInvalidRequest   = -32600
+	RPCMethodNotFound   = -32601
+	RPCInvalidParams    = -32602
+	RPCInternalError    = -32603
+	RPCRequestCancelled = -32800
+)
+
+// ErrSessionClosed is returned for requests on a session whose plugin
+// process has exited.
+var ErrSessionClosed = errors.New("plugin session closed")
+
+// rpcMessage is a JSON-RPC 2.0 request, response or notification.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is the error of a JSON-RPC response.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// ProgressParams are the parameters of a $/progress notification, which
+// a plugin sends to report an interim message for a running request.
+type ProgressParams struct {
+	ID int64 `json:"id"`
+	ProgressEvent
+}
+
+// SessionInfo is the plugin's answer to the initialize handshake.
+type SessionInfo struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	PluginID        string   `json:"plugin_id"`
+	Version         string   `json:"version"`
+	Methods         []string `json:"methods,omitempty"`
+}
+
+// Session is a running plugin process serving JSON-RPC requests. Requests
+// may be made concurrently; responses and $/progress notifications are
+// matched to them by ID.
+type Session struct {
+	Info SessionInfo
+
+	plugin       *Plugin
+	binarySHA256 string // Hash of the entrypoint the process was started from
+	cmd          *exec.Cmd
+	stdin        io.WriteCloser
+	stderr       *lockedBuffer
+	started      time.Time
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]*pendingCall
+	done    chan struct{} // Closed when the process has exited
+	err     error         // Why the session ended
+}
+
+// StartSession starts a plugin process in session mode and performs the
+// initialize handshake. A plugin that does not answer the handshake within
+// SessionStartTimeout is stopped.
+func StartSession(plugin *Plugin, entrypoint string) (*Session, error) {
+	binaryHash, err := entrypointSHA256(entrypoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash plugin entrypoint: %w", err)
+	}
+	return startSession(plugin, entrypoint, binaryHash)
+}
+
+// startSession is StartSession for an entrypoint whose SHA-256 the caller
+// has just computed.
+func startSession(plugin *Plugin, entrypoint, binaryHash string) (*Session, error) {
+	// The CPU limit would accumulate over every request the session
+	// serves; requests are bounded by their timeout instead.
+	limits := externalPluginLimits
+	limits.CPUSeconds = 0
+	cmd := pluginCommand(context.Background(), limits, entrypoint, SessionFlag)
+	cmd.Dir = plugin.Path
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	s := &Session{
+		plugin:       plugin,
+		binarySHA256: binaryHash,
+		cmd:          cmd,
+		stdin:        stdin,
+		stderr:       &lockedBuffer{},
+		pending:      make(map[int64]*pendingCall),
+		done:         make(chan struct{}),
+	}
+	cmd.Stderr = s.stderr
+	s.started = time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin: %w", err)
+	}
+	go s.read(stdout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), SessionStartTimeout)
+	defer cancel()
+	result, err := s.call(ctx, "initialize", map[string]interface{}{
+		"protocol_version": SessionProtocolVersion,
+	})
+	if err == nil {
+		err = json.Unmarshal(result, &s.Info)
+	}
+	if err == nil && s.Info.ProtocolVersion != SessionProtocolVersion {
+		err = fmt.Errorf("unsupported protocol version %d", s.Info.ProtocolVersion)
+	}
+	if err != nil {
+		s.kill()
+		return nil, fmt.Errorf("plugin %s handshake failed: %w", plugin.Manifest.PluginID, err)
+	}
+	return s, nil
+}
+
+// Call sends a command request and waits for its response. Interim
+// messages are passed to the ProgressFunc of ctx. When ctx ends first,
+// the request is cancelled with a $/cancel notification and the
+// context's error is returned. Errors the plugin reports, including
+// JSON-RPC errors such as an unknown method, are returned as error
+// responses like those of one-shot plugins.
+func (s *Session) Call(ctx context.Context, req *IPCRequest) (*IPCResponse, error) {
+	args := req.Args
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	result, err := s.call(ctx, req.Command, args)
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return &IPCResponse{Status: "error", Error: rpcErr.Message}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resp IPCResponse
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &resp, nil
+}
+
+// BinarySHA256 returns the SHA-256 of the entrypoint the process was
+// started from, which may since have been replaced on disk.
+func (s *Session) BinarySHA256() string {
+	return s.binarySHA256
+}
+
+// Alive reports whether the plugin process is running.
+func (s *Session) Alive() bool {
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Close shuts the session down: it sends shutdown, which the plugin
+// answers once its running requests are done, then exit, and waits for
+// the process. A plugin that does not exit within timeout is killed.
+func (s *Session) Close(timeout time.Duration) error {
+	if !s.Alive() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := s.call(ctx, "shutdown", nil); err == nil {
+		s.notify("exit", nil)
+	}
+	s.stdin.Close()
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		s.kill()
+	}
+	return nil
+}
+
+// call sends a request and waits for its result.
+func (s *Session) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	s.mu.Lock()
+	if !s.Alive() {
+		s.mu.Unlock()
+		return nil, s.closedError()
+	}
+	s.nextID++
+	id := s.nextID
+	ch := make(chan *rpcMessage, 1)
+	s.pending[id] = &pendingCall{ch: ch, progress: progressFunc(ctx)}
+	s.mu.Unlock()
+
+	if err := s.send(&id, method, params); err != nil {
+		s.forget(id)
+		return nil, err
+	}
+
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Result, nil
+	case <-ctx.Done():
+		s.forget(id)
+		s.notify("$/cancel", map[string]int64{"id": id})
+		return nil, ctx.Err()
+	case <-s.done:
+		return nil, s.closedError()
+	}
+}
+
+// notify sends a notification, which has no response.
+func (s *Session) notify(method string, params interface{}) {
+	s.send(nil, method, params)
+}
+
+func (s *Session) send(id *int64, method string, params interface{}) error {
+	msg := rpcMessage{JSONRPC: "2.0", ID: id, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		msg.Params = data
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("%w: %v", ErrSessionClosed, err)
+	}
+	return nil
+}
+
+func (s *Session) forget(id int64) {
+	s.mu.Lock()
+	delete(s.pending, id)
+	s.mu.Unlock()
+}
+
+// read delivers responses to their requests until the plugin's stdout
+// ends, then waits for the process and ends the session.
+func (s *Session) read(stdout io.Reader) {
+	br := bufio.NewReader(stdout)
+	var readErr error
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var msg rpcMessage
+			if jsonErr := json.Unmarshal(line, &msg); jsonErr != nil {
+				readErr = fmt.Errorf("invalid message from plugin: %w", jsonErr)
+				s.cmd.Process.Kill()
+				break
+			}
+			s.deliver(&msg)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	waitErr := s.cmd.Wait()
+	usage := resource.Measure(s.cmd.ProcessState, time.Since(s.started))
+	logging.Debug("plugin session ended", "plugin_id", s.plugin.Manifest.PluginID, "usage", usage.String())
+
+	s.mu.Lock()
+	switch {
+	case readErr != nil:
+		s.err = readErr
+	case waitErr != nil:
+		s.err = fmt.Errorf("plugin exited: %w (stderr: %s)", waitErr, s.stderr.String())
+	}
+	close(s.done)
+	s.mu.Unlock()
+}
+
+// deliver passes a response to the request waiting for it, and a
+// $/progress notification to the request's ProgressFunc. Requests from
+// the plugin are not part of the protocol and are ignored.
+func (s *Session) deliver(msg *rpcMessage) {
+	if msg.Method == "$/progress" && msg.ID == nil {
+		s.progress(msg.Params)
+		return
+	}
+	if msg.ID == nil || msg.Method != "" {
+		return
+	}
+	s.mu.Lock()
+	call, ok := s.pending[*msg.ID]
+	delete(s.pending, *msg.ID)
+	s.mu.Unlock()
+	if ok {
+		call.ch <- msg
+	}
+}
+
+func (s *Session) progress(data json.RawMessage) {
+	var params ProgressParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return
+	}
+	s.mu.Lock()
+	call, ok := s.pending[params.ID]
+	s.mu.Unlock()
+	if ok && call.progress != nil {
+		ev := params.ProgressEvent
+		if ev.Kind == "" {
+			ev.Kind = EventProgress
+		}
+		call.progress(ev)
+	}
+}
+
+// pendingCall is a request waiting for its response.
+type pendingCall struct {
+	ch       chan *rpcMessage
+	progress ProgressFunc
+}
+
+func (s *Session) kill() {
+	s.cmd.Process.Kill()
+	<-s.done
+}
+
+func (s *Session) closedError() error {
+	if s.err != nil {
+		return fmt.Errorf("%w: %v", ErrSessionClosed, s.err)
+	}
+	return ErrSessionClosed
+}
+
+// SessionPool keeps one session per plugin entrypoint, restarting it when
+// the entrypoint's hash changes. Plugins whose session fails to start are
+// remembered and run in one-shot mode until their entrypoint changes or
+// FailedSessionRetry has passed.
+type SessionPool struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	starting map[string]*sessionStart
+	failed   map[string]*failedStart
+}
+
+// FailedSessionRetry is how long a plugin whose session failed to start
+// runs in one-shot mode before a session is tried again.
+var FailedSessionRetry = 10 * time.Minute
+
+// sessionStart is a session being started. Callers asking for the same
+// entrypoint wait on done instead of starting a second process.
+type sessionStart struct {
+	binarySHA256 string
+	done         chan struct{}
+	session      *Session
+	err          error
+}
+
+// failedStart records why a session failed to start and which version of
+// the entrypoint failed.
+type failedStart struct {
+	err          error
+	at           time.Time
+	binarySHA256 string
+}
+
+// stale reports whether the failure no longer applies because it is old
+// or the entrypoint now has another hash.
+func (f *failedStart) stale(binaryHash string) bool {
+	return time.Since(f.at) >= FailedSessionRetry || f.binarySHA256 != binaryHash
+}
+
+// NewSessionPool creates an empty session pool.
+func NewSessionPool() *SessionPool {
+	return &SessionPool{
+		sessions: make(map[string]*Session),
+		starting: make(map[string]*sessionStart),
+		failed:   make(map[string]*failedStart),
+	}
+}
+
+// Session returns the running session of a plugin whose entrypoint has
+// the SHA-256 binaryHash, starting one when the plugin has none, its
+// process has exited or it was started from another binary. A replaced
+// session is shut down once its running requests are done. Session returns
+// the handshake error for plugins whose session failed to start before.
+// The pool is not locked during the handshake, so other plugins are served
+// meanwhile.
+func (p *SessionPool) Session(plugin *Plugin, entrypoint, binaryHash string) (*Session, error) {
+	p.mu.Lock()
+	for {
+		start, ok := p.starting[entrypoint]
+		if !ok {
+			break
+		}
+		p.mu.Unlock()
+		<-start.done
+		if start.binarySHA256 == binaryHash {
+			return start.session, start.err
+		}
+		p.mu.Lock()
+	}
+	if f, ok := p.failed[entrypoint]; ok {
+		if !f.stale(binaryHash) {
+			p.mu.Unlock()
+			return nil, f.err
+		}
+		delete(p.failed, entrypoint)
+	}
+	if s, ok := p.sessions[entrypoint]; ok {
+		if s.Alive() && s.binarySHA256 == binaryHash {
+			p.mu.Unlock()
+			return s, nil
+		}
+		delete(p.sessions, entrypoint)
+		go s.Close(SessionShutdownTimeout)
+	}
+	start := &sessionStart{binarySHA256: binaryHash, done: make(chan struct{})}
+	p.starting[entrypoint] = start
+	p.mu.Unlock()
+
+	start.session, start.err = startSession(plugin, entrypoint, binaryHash)
+
+	p.mu.Lock()
+	delete(p.starting, entrypoint)
+	if start.err != nil {
+		p.failed[entrypoint] = &failedStart{err: start.err, at: time.Now(), binarySHA256: binaryHash}
+	} else {
+		p.sessions[entrypoint] = start.session
+	}
+	p.mu.Unlock()
+	close(start.done)
+	return start.session, start.err
+}
+
+// Len returns the number of running sessions.
+func (p *SessionPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, s := range p.sessions {
+		if s.Alive() {
+			n++
+		}
+	}
+	return n
+}
+
+// Close shuts down the sessions of the given plugins, or of all plugins
+// when none are given.
+func (p *SessionPool) Close(plugins ...*Plugin) {
+	p.mu.Lock()
+	var closing []*Session
+	for entrypoint, s := range p.sessions {
+		if len(plugins) > 0 && !containsEntrypoint(plugins, entrypoint) {
+			continue
+		}
+		closing = append(closing, s)
+		delete(p.sessions, entrypoint)
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range closing {
+		wg.Add(1)
+		go func(s *Session) {
+			defer wg.Done()
+			s.Close(SessionShutdownTimeout)
+		}(s)
+	}
+	wg.Wait()
+}
+
+func containsEntrypoint(plugins []*Plugin, entrypoint string) bool {
+	for _, p := range plugins {
+		if !p.IsEmbedded() && p.EntrypointPath() == entrypoint {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionPool holds the sessions of plugins declaring ProtocolJSONRPC.
+var sessionPool = NewSessionPool()
+
+// persistentPluginsEnabled controls whether plugins declaring
+// ProtocolJSONRPC are kept running between requests.
+var persistentPluginsEnabled = true
+
+// EnablePersistentPlugins keeps plugins that support sessions running
+// between requests. This is the default.
+func EnablePersistentPlugins() {
+	persistentPluginsEnabled = true
+}
+
+// DisablePersistentPlugins runs every external plugin in one-shot mode.
+// Running sessions are shut down.
+func DisablePersistentPlugins() {
+	persistentPluginsEnabled = false
+	sessionPool.Close()
+}
+
+// PersistentPluginsEnabled returns whether plugin sessions are used.
+func PersistentPluginsEnabled() bool {
+	return persistentPluginsEnabled
+}
+
+// ShutdownPluginSessions shuts down all running plugin sessions. Hosts
+// call it before exiting.
+func ShutdownPluginSessions() {
+	sessionPool.Close()
+}
+
+// pluginCommand returns the command running a plugin entrypoint with the
+// given limits. On Unix the plugin is started by a shell that applies the
+// limits and then execs it, so the measured usage is the plugin's.
+func pluginCommand(ctx context.Context, limits resource.Limits, entrypoint string, args ...string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, entrypoint, args...)
+	}
+	shArgs := append([]string{"-c", limits.ShellPrefix() + `exec "$0" "$@"`, entrypoint}, args...)
+	return exec.CommandContext(ctx, "/bin/sh", shArgs...)
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent use, keeping at most
+// the last 64 KiB written.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	const max = 64 << 10
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > max {
+		b.buf = b.buf[len(b.buf)-max:]
+	}
+	return len(p), nil
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,324 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	pluginipc "github.com/FocuswithJustin/JuniperBible/plugins/ipc"
+)
+
+// The test binary doubles as a session plugin when this variable is set.
+const sessionPluginEnv = "JUNIPER_TEST_SESSION_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(sessionPluginEnv) == "1" {
+		runSessionPlugin()
+		os.Exit(0)
+	}
+	code := m.Run()
+	if wasmPluginPath != "" {
+		os.RemoveAll(filepath.Dir(wasmPluginPath))
+	}
+	os.Exit(code)
+}
+
+// runSessionPlugin serves commands reporting the process ID, sleeping,
+// reporting progress and blocking until cancelled.
+func runSessionPlugin() {
+	pluginipc.Main(pluginipc.ServerInfo{PluginID: "test.session", Version: "1.0.0"},
+		func(ctx context.Context, req *pluginipc.Request) (interface{}, error) {
+			switch req.Command {
+			case "pid":
+				return map[string]int{"pid": os.Getpid()}, nil
+			case "sleep":
+				time.Sleep(200 * time.Millisecond)
+				return "slept", nil
+			case "progress":
+				for i := int64(1); i <= 3; i++ {
+					pluginipc.Progress(ctx, "step", fmt.Sprintf("step %d", i), i, 3)
+				}
+				pluginipc.Log(ctx, "info", "all steps done")
+				return "done", nil
+			case "block":
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("unknown command: %s", req.Command)
+		})
+}
+
+// sessionPlugin returns a plugin whose entrypoint is the test binary.
+func sessionPlugin(t *testing.T) *Plugin {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("session test plugin needs a POSIX shell")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(exe, filepath.Join(dir, "session-plugin")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(sessionPluginEnv, "1")
+	return &Plugin{
+		Manifest: &PluginManifest{
+			PluginID:   "test.session",
+			Version:    "1.0.0",
+			Kind:       "format",
+			Entrypoint: "session-plugin",
+			Protocol:   ProtocolJSONRPC,
+		},
+		Path: dir,
+	}
+}
+
+// entrypointHash returns the SHA-256 of a plugin entrypoint.
+func entrypointHash(t *testing.T, path string) string {
+	t.Helper()
+	hash, err := fileSHA256(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestSessionConcurrentRequestsAndCancel(t *testing.T) {
+	plugin := sessionPlugin(t)
+	s, err := StartSession(plugin, plugin.EntrypointPath())
+	if err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	defer s.Close(SessionShutdownTimeout)
+	if s.Info.PluginID != "test.session" || s.Info.ProtocolVersion != SessionProtocolVersion {
+		t.Errorf("unexpected handshake %+v", s.Info)
+	}
+
+	// Overlapping requests run concurrently in the one process
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := s.Call(context.Background(), &IPCRequest{Command: "sleep"})
+			if err != nil || resp.Status != "ok" {
+				t.Errorf("sleep failed: %v %+v", err, resp)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Errorf("5 requests of 200ms took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := s.Call(ctx, &IPCRequest{Command: "block"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+
+	// The session outlives a cancelled request, and plugin errors are
+	// error responses
+	resp, err := s.Call(context.Background(), &IPCRequest{Command: "nope"})
+	if err != nil || resp.Status != "error" || resp.Error != "unknown command: nope" {
+		t.Errorf("unexpected response %+v, %v", resp, err)
+	}
+
+	if err := s.Close(SessionShutdownTimeout); err != nil || s.Alive() {
+		t.Errorf("session not closed: %v", err)
+	}
+	if _, err := s.Call(context.Background(), &IPCRequest{Command: "pid"}); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("expected ErrSessionClosed, got %v", err)
+	}
+}
+
+func TestExecutePluginReusesSession(t *testing.T) {
+	plugin := sessionPlugin(t)
+	EnableExternalPlugins()
+	defer DisableExternalPlugins()
+	defer ShutdownPluginSessions()
+
+	pid := func() float64 {
+		resp, err := ExecutePlugin(plugin, &IPCRequest{Command: "pid"})
+		if err != nil || resp.Status != "ok" {
+			t.Fatalf("pid failed: %v %+v", err, resp)
+		}
+		return resp.Result.(map[string]interface{})["pid"].(float64)
+	}
+	first := pid()
+	if second := pid(); second != first {
+		t.Errorf("requests ran in different processes %v and %v", first, second)
+	}
+	if n := sessionPool.Len(); n != 1 {
+		t.Errorf("pool has %d sessions, want 1", n)
+	}
+
+	// Without sessions each request starts a process
+	DisablePersistentPlugins()
+	defer EnablePersistentPlugins()
+	if sessionPool.Len() != 0 {
+		t.Error("sessions left running")
+	}
+	if a, b := pid(), pid(); a == b {
+		t.Errorf("one-shot requests shared process %v", a)
+	}
+}
+
+func TestExecutePluginRestartsReplacedSession(t *testing.T) {
+	plugin := sessionPlugin(t)
+	EnableExternalPlugins()
+	defer DisableExternalPlugins()
+	defer ShutdownPluginSessions()
+	entrypoint := plugin.EntrypointPath()
+
+	run := func() (float64, string) {
+		resp, err := ExecutePlugin(plugin, &IPCRequest{Command: "pid"})
+		if err != nil || resp.Status != "ok" {
+			t.Fatalf("pid failed: %v %+v", err, resp)
+		}
+		return resp.Result.(map[string]interface{})["pid"].(float64), resp.BinarySHA256
+	}
+	firstPID, firstHash := run()
+	if want := entrypointHash(t, entrypoint); firstHash != want {
+		t.Errorf("BinarySHA256 = %q, want %q", firstHash, want)
+	}
+
+	// Replace the entrypoint with a script running the same plugin
+	exe, _ := os.Readlink(entrypoint)
+	if err := os.Remove(entrypoint); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(entrypoint, []byte("#!/bin/sh\nexec "+exe+" \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next request runs in a session of the new binary and records it
+	secondPID, secondHash := run()
+	if want := entrypointHash(t, entrypoint); secondHash != want || secondHash == firstHash {
+		t.Errorf("BinarySHA256 = %q, want the new binary's %q", secondHash, want)
+	}
+	if secondPID == firstPID {
+		t.Error("request ran in the session of the replaced binary")
+	}
+	if n := sessionPool.Len(); n != 1 {
+		t.Errorf("pool has %d sessions, want 1", n)
+	}
+}
+
+func TestSessionPoolFallsBackToOneShot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	// A plugin declaring sessions that only speaks the one-shot protocol
+	dir := t.TempDir()
+	script := "#!/bin/sh\nread input\necho '{\"status\":\"ok\",\"result\":{\"oneshot\":true}}'\n"
+	if err := os.WriteFile(filepath.Join(dir, "old-plugin"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	plugin := &Plugin{
+		Manifest: &PluginManifest{PluginID: "test.old", Version: "1.0.0", Kind: "format", Entrypoint: "old-plugin", Protocol: ProtocolJSONRPC},
+		Path:     dir,
+	}
+	EnableExternalPlugins()
+	defer DisableExternalPlugins()
+
+	for i := 0; i < 2; i++ {
+		resp, err := ExecutePlugin(plugin, &IPCRequest{Command: "detect"})
+		if err != nil || resp.Status != "ok" {
+			t.Fatalf("request %d failed: %v %+v", i, err, resp)
+		}
+	}
+	if _, err := sessionPool.Session(plugin, plugin.EntrypointPath(), entrypointHash(t, plugin.EntrypointPath())); err == nil {
+		t.Error("expected the failed handshake to be remembered")
+	}
+}
+
+func TestSessionPoolRetriesFailedStart(t *testing.T) {
+	plugin := sessionPlugin(t)
+	entrypoint := plugin.EntrypointPath()
+	exe, _ := os.Readlink(entrypoint)
+
+	// An entrypoint that exits without a handshake
+	if err := os.Remove(entrypoint); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(entrypoint, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pool := NewSessionPool()
+	defer pool.Close()
+	if _, err := pool.Session(plugin, entrypoint, entrypointHash(t, entrypoint)); err == nil {
+		t.Fatal("expected the handshake to fail")
+	}
+	if _, err := pool.Session(plugin, entrypoint, entrypointHash(t, entrypoint)); err == nil {
+		t.Fatal("expected the failure to be remembered")
+	}
+
+	// Replacing the entrypoint clears the failure
+	if err := os.Remove(entrypoint); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(exe, entrypoint); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.Session(plugin, entrypoint, entrypointHash(t, entrypoint)); err != nil {
+		t.Fatalf("expected a session from the new entrypoint: %v", err)
+	}
+
+	// So does the retry interval passing
+	pool.Close()
+	pool.failed[entrypoint] = &failedStart{
+		err:          errors.New("old failure"),
+		at:           time.Now().Add(-FailedSessionRetry),
+		binarySHA256: entrypointHash(t, entrypoint),
+	}
+	if _, err := pool.Session(plugin, entrypoint, entrypointHash(t, entrypoint)); err != nil {
+		t.Fatalf("expected an expired failure to be retried: %v", err)
+	}
+}
+
+func TestSessionPoolStartsOutsideLock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	// A plugin that takes a second to fail its handshake
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "slow-plugin"), []byte("#!/bin/sh\nsleep 1\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	plugin := &Plugin{
+		Manifest: &PluginManifest{PluginID: "test.slow", Version: "1.0.0", Kind: "format", Entrypoint: "slow-plugin", Protocol: ProtocolJSONRPC},
+		Path:     dir,
+	}
+	pool := NewSessionPool()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = pool.Session(plugin, plugin.EntrypointPath(), entrypointHash(t, plugin.EntrypointPath()))
+		}(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	pool.Len()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("pool was locked during the handshake for %v", elapsed)
+	}
+	wg.Wait()
+	if errs[0] == nil || errs[1] == nil || errs[0].Error() != errs[1].Error() {
+		t.Errorf("expected both callers to get the one handshake error, got %v and %v", errs[0], errs[1])
+	}
+}
@@ -0,0 +1,487 @@
+package plugins
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/FocuswithJustin/JuniperBible/core/errors"
+)
+
+// SignatureFileName is the detached signature of a plugin's plugin.json,
+// stored next to it.
+const SignatureFileName = "plugin.json.sig"
+
+// DefaultTrustStoreName is the file name of the per-user trust store.
+const DefaultTrustStoreName = "plugin-trust.json"
+
+// SignatureAlgorithm is the only supported signature algorithm.
+const SignatureAlgorithm = "ed25519"
+
+// ErrUntrustedPlugin is returned when the trust policy rejects a plugin.
+var ErrUntrustedPlugin = errors.New("untrusted plugin")
+
+// TrustPolicy decides which external plugins may be loaded and run.
+type TrustPolicy string
+
+const (
+	// TrustAll allows every plugin. It is the default.
+	TrustAll TrustPolicy = "all"
+	// TrustSigned allows plugins with a valid signature by a publisher in
+	// the trust store.
+	TrustSigned TrustPolicy = "signed"
+	// TrustPublishers allows plugins with a valid signature by one of the
+	// publishers listed in SecurityConfig.TrustedPublishers.
+	TrustPublishers TrustPolicy = "publishers"
+)
+
+// ParseTrustPolicy parses a trust policy name. The empty string is
+// TrustAll.
+func ParseTrustPolicy(s string) (TrustPolicy, error) {
+	switch p := TrustPolicy(s); p {
+	case "":
+		return TrustAll, nil
+	case TrustAll, TrustSigned, TrustPublishers:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown plugin trust policy %q (want all, signed or publishers)", s)
+}
+
+// Signature states of a plugin.
+const (
+	SignatureEmbedded   = "embedded"    // Compiled into the host binary
+	SignatureUnsigned   = "unsigned"    // No signature file
+	SignatureValid      = "valid"       // Signed by a trusted publisher, binary matches
+	SignatureUnknownKey = "unknown-key" // Signed with a key not in the trust store
+	SignatureInvalid    = "invalid"     // Bad signature, or the binary does not match
+)
+
+// SignatureStatus is the result of verifying a plugin's signature.
+type SignatureStatus struct {
+	State string `json:"state"`
+	// Publisher is the trust store name of the signing key. For
+	// SignatureUnknownKey it is the unverified name in the signature.
+	Publisher string `json:"publisher,omitempty"`
+	KeyID     string `json:"key_id,omitempty"`
+	// EntrypointSHA256 is the entrypoint hash covered by a valid signature.
+	EntrypointSHA256 string `json:"entrypoint_sha256,omitempty"`
+	Reason           string `json:"reason,omitempty"`
+}
+
+// String describes the status for listings.
+func (s *SignatureStatus) String() string {
+	switch s.State {
+	case SignatureValid:
+		return fmt.Sprintf("signed by %s (key %s)", s.Publisher, s.KeyID)
+	case SignatureUnknownKey:
+		return fmt.Sprintf("signed with unknown key %s (claims %q)", s.KeyID, s.Publisher)
+	case SignatureInvalid:
+		return "invalid signature: " + s.Reason
+	}
+	return s.State
+}
+
+// ManifestSignature is the content of a plugin.json.sig file: an Ed25519
+// signature over the exact bytes of plugin.json, whose entrypoint_sha256
+// field pins the entrypoint binary.
+type ManifestSignature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	Publisher string `json:"publisher"`
+	Signature string `json:"signature"` // Base64
+}
+
+// Publisher is a trusted signing key.
+type Publisher struct {
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"` // Base64 Ed25519 public key
+}
+
+// TrustStore holds the publisher keys plugin signatures are checked
+// against.
+type TrustStore struct {
+	Publishers []Publisher `json:"publishers"`
+}
+
+// KeyID returns the short identifier of a public key: the first 16 hex
+// digits of its SHA-256.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// DefaultTrustStore returns the per-user trust store path, or "" if there
+// is no user config directory.
+func DefaultTrustStore() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "juniper", DefaultTrustStoreName)
+}
+
+// LoadTrustStore reads a trust store. A missing file is an empty store.
+func LoadTrustStore(path string) (*TrustStore, error) {
+	store := &TrustStore{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, apperrors.NewIO("read", path, err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, apperrors.NewParse("JSON", path, err.Error())
+	}
+	for _, p := range store.Publishers {
+		if _, err := decodePublicKey(p.PublicKey); err != nil {
+			return nil, fmt.Errorf("trust store %s: publisher %s: %w", path, p.Name, err)
+		}
+	}
+	return store, nil
+}
+
+// Save writes the trust store, creating its directory if needed.
+func (s *TrustStore) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return apperrors.NewIO("create", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return apperrors.NewIO("write", path, err)
+	}
+	return nil
+}
+
+// Add trusts a publisher key, replacing any key of the same ID.
+func (s *TrustStore) Add(name string, pub ed25519.PublicKey) {
+	id := KeyID(pub)
+	s.Publishers = slices.DeleteFunc(s.Publishers, func(p Publisher) bool {
+		key, err := decodePublicKey(p.PublicKey)
+		return err == nil && KeyID(key) == id
+	})
+	s.Publishers = append(s.Publishers, Publisher{
+		Name:      name,
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+	})
+}
+
+// Lookup returns the publisher and key with the given key ID.
+func (s *TrustStore) Lookup(keyID string) (*Publisher, ed25519.PublicKey) {
+	if s == nil {
+		return nil, nil
+	}
+	for i, p := range s.Publishers {
+		key, err := decodePublicKey(p.PublicKey)
+		if err == nil && KeyID(key) == keyID {
+			return &s.Publishers[i], key
+		}
+	}
+	return nil, nil
+}
+
+func decodePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// GenerateSigningKey creates a publisher key pair.
+func GenerateSigningKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// MarshalSigningKey encodes a private key for a key file.
+func MarshalSigningKey(key ed25519.PrivateKey) []byte {
+	return []byte(base64.StdEncoding.EncodeToString(key.Seed()) + "\n")
+}
+
+// ParseSigningKey decodes a key file written with MarshalSigningKey.
+func ParseSigningKey(data []byte) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing key")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	return decodePublicKey(s)
+}
+
+// SignPlugin signs the plugin in pluginDir: it records the SHA-256 of the
+// entrypoint in plugin.json as entrypoint_sha256 and writes the signature
+// of the resulting manifest to plugin.json.sig. Other manifest fields are
+// kept.
+func SignPlugin(pluginDir, publisher string, key ed25519.PrivateKey) (*ManifestSignature, error) {
+	manifestPath := filepath.Join(pluginDir, "plugin.json")
+	manifest, err := ParsePluginManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(manifest.Entrypoint, "..") {
+		return nil, fmt.Errorf("entrypoint contains path traversal")
+	}
+	hash, err := fileSHA256(filepath.Join(pluginDir, manifest.Entrypoint))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, apperrors.NewIO("read", manifestPath, err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, apperrors.NewParse("JSON", manifestPath, err.Error())
+	}
+	fields["entrypoint_sha256"], _ = json.Marshal(hash)
+	data, err = json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return nil, apperrors.NewIO("write", manifestPath, err)
+	}
+
+	pub := key.Public().(ed25519.PublicKey)
+	sig := &ManifestSignature{
+		Algorithm: SignatureAlgorithm,
+		KeyID:     KeyID(pub),
+		Publisher: publisher,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)),
+	}
+	sigData, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	sigPath := filepath.Join(pluginDir, SignatureFileName)
+	if err := os.WriteFile(sigPath, append(sigData, '\n'), 0644); err != nil {
+		return nil, apperrors.NewIO("write", sigPath, err)
+	}
+	return sig, nil
+}
+
+// VerifyPlugin checks a plugin's signature against a trust store and the
+// signed entrypoint hash against the binary on disk.
+func VerifyPlugin(p *Plugin, store *TrustStore) *SignatureStatus {
+	if p.IsEmbedded() {
+		return &SignatureStatus{State: SignatureEmbedded}
+	}
+	sigPath := filepath.Join(p.Path, SignatureFileName)
+	sigData, err := os.ReadFile(sigPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return &SignatureStatus{State: SignatureUnsigned}
+	}
+	invalid := func(format string, args ...interface{}) *SignatureStatus {
+		return &SignatureStatus{State: SignatureInvalid, Reason: fmt.Sprintf(format, args...)}
+	}
+	if err != nil {
+		return invalid("cannot read signature: %v", err)
+	}
+	var sig ManifestSignature
+	if err := json.Unmarshal(sigData, &sig); err != nil {
+		return invalid("malformed signature file: %v", err)
+	}
+	if sig.Algorithm != SignatureAlgorithm {
+		return invalid("unsupported algorithm %q", sig.Algorithm)
+	}
+
+	publisher, key := store.Lookup(sig.KeyID)
+	if publisher == nil {
+		return &SignatureStatus{State: SignatureUnknownKey, Publisher: sig.Publisher, KeyID: sig.KeyID}
+	}
+	status := &SignatureStatus{Publisher: publisher.Name, KeyID: sig.KeyID}
+	fail := func(format string, args ...interface{}) *SignatureStatus {
+		status.State = SignatureInvalid
+		status.Reason = fmt.Sprintf(format, args...)
+		return status
+	}
+
+	manifestPath := filepath.Join(p.Path, "plugin.json")
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fail("cannot read manifest: %v", err)
+	}
+	sigBytes, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil || !ed25519.Verify(key, data, sigBytes) {
+		return fail("signature does not match plugin.json")
+	}
+	var signed PluginManifest
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return fail("malformed manifest: %v", err)
+	}
+	if signed.EntrypointSHA256 == "" {
+		return fail("manifest has no entrypoint_sha256")
+	}
+	if p.Manifest == nil || signed.Entrypoint != p.Manifest.Entrypoint || signed.EntrypointSHA256 != p.Manifest.EntrypointSHA256 {
+		return fail("loaded manifest differs from the signed one")
+	}
+	hash, err := entrypointSHA256(p.EntrypointPath())
+	if err != nil {
+		return fail("cannot hash entrypoint: %v", err)
+	}
+	if hash != signed.EntrypointSHA256 {
+		return fail("entrypoint does not match entrypoint_sha256")
+	}
+	status.State = SignatureValid
+	status.EntrypointSHA256 = hash
+	return status
+}
+
+// VerifySignature returns the signature status of the plugin, verifying
+// it against the configured trust store on first use. It is safe for
+// concurrent use.
+func (p *Plugin) VerifySignature() *SignatureStatus {
+	p.signatureMu.Lock()
+	defer p.signatureMu.Unlock()
+	if p.signature == nil {
+		p.signature = VerifyPlugin(p, globalSecurityConfig.TrustStore)
+	}
+	return p.signature
+}
+
+// CheckPluginTrust applies the configured trust policy to a plugin.
+// Embedded plugins are always trusted.
+func CheckPluginTrust(p *Plugin) error {
+	cfg := globalSecurityConfig
+	if !enforcingTrust() || p.IsEmbedded() {
+		return nil
+	}
+	status := p.VerifySignature()
+	if status.State != SignatureValid {
+		return fmt.Errorf("%w %s: %s", ErrUntrustedPlugin, p.Manifest.PluginID, status)
+	}
+	if cfg.TrustPolicy == TrustPublishers && !slices.Contains(cfg.TrustedPublishers, status.Publisher) {
+		return fmt.Errorf("%w %s: publisher %s is not allowed", ErrUntrustedPlugin, p.Manifest.PluginID, status.Publisher)
+	}
+	return nil
+}
+
+// enforcingTrust reports whether the trust policy restricts which
+// plugins may run.
+func enforcingTrust() bool {
+	policy := globalSecurityConfig.TrustPolicy
+	return policy != "" && policy != TrustAll
+}
+
+// checkEntrypoint checks that an external plugin may run and returns the
+// SHA-256 of its entrypoint. For a trusted signed plugin the entrypoint
+// must still be the binary that was signed.
+func checkEntrypoint(p *Plugin, entrypoint string) (string, error) {
+	hash, err := entrypointSHA256(entrypoint)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash plugin entrypoint: %w", err)
+	}
+	if err := CheckPluginTrust(p); err != nil {
+		return "", err
+	}
+	p.signatureMu.Lock()
+	s := p.signature
+	p.signatureMu.Unlock()
+	if s != nil && s.State == SignatureValid && s.EntrypointSHA256 != hash {
+		return "", fmt.Errorf("%w %s: entrypoint changed after it was signed", ErrUntrustedPlugin, p.Manifest.PluginID)
+	}
+	return hash, nil
+}
+
+// entrypointSHA256 returns the SHA-256 of an entrypoint. Cached hashes are
+// keyed by modification time and size, which whoever can write the file
+// can restore, so the file is hashed again while a trust policy is
+// enforced.
+func entrypointSHA256(path string) (string, error) {
+	if enforcingTrust() {
+		return fileSHA256(path)
+	}
+	return entrypointHashes.hash(path)
+}
+
+// hashCache remembers entrypoint hashes until the file changes.
+type hashCache struct {
+	mu     sync.Mutex
+	hashes map[string]cachedHash
+}
+
+type cachedHash struct {
+	modTime time.Time
+	size    int64
+	sha256  string
+}
+
+var entrypointHashes = &hashCache{hashes: make(map[string]cachedHash)}
+
+func (c *hashCache) hash(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	cached, ok := c.hashes[path]
+	c.mu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.sha256, nil
+	}
+	hash, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	c.hashes[path] = cachedHash{modTime: info.ModTime(), size: info.Size(), sha256: hash}
+	c.mu.Unlock()
+	return hash, nil
+}
+
+// fileSHA256 returns the hex SHA-256 of a file.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", apperrors.NewIO("open", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", apperrors.NewIO("read", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FindPlugin discovers the plugins in dir and returns the one with the
+// given ID, or nil if there is none. The ID may omit the kind prefix, as
+// tool IDs do ("libsword" for "tools.libsword"). A plugin rejected by the
+// trust policy is an error.
+func FindPlugin(dir, id string) (*Plugin, error) {
+	found, err := DiscoverPlugins(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range found {
+		pid := p.Manifest.PluginID
+		if pid != id && !strings.HasSuffix(pid, "."+id) {
+			continue
+		}
+		if err := CheckPluginTrust(p); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	return nil, nil
+}
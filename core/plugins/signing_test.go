@@ -0,0 +1,255 @@
+package plugins
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// signedPlugin writes a shell plugin to a new directory, signs it and
+// returns the directory with a trust store holding the signing key.
+func signedPlugin(t *testing.T, publisher string) (string, *TrustStore) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nread input\necho '{\"status\":\"ok\",\"result\":\"hi\"}'\n"
+	if err := os.WriteFile(filepath.Join(dir, "format-signed"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `{"plugin_id": "format.signed", "version": "1.0.0", "kind": "format", "entrypoint": "format-signed", "description": "kept"}`
+	if err := os.WriteFile(filepath.Join(dir, "plugin.json"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pub, key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SignPlugin(dir, publisher, key); err != nil {
+		t.Fatalf("SignPlugin failed: %v", err)
+	}
+	store := &TrustStore{}
+	store.Add(publisher, pub)
+	return dir, store
+}
+
+// withTrust sets the trust configuration for the duration of a test.
+func withTrust(t *testing.T, policy TrustPolicy, store *TrustStore, publishers ...string) {
+	t.Helper()
+	saved := GetSecurityConfig()
+	t.Cleanup(func() { SetSecurityConfig(saved) })
+	cfg := saved
+	cfg.TrustPolicy = policy
+	cfg.TrustStore = store
+	cfg.TrustedPublishers = publishers
+	SetSecurityConfig(cfg)
+}
+
+func TestVerifyPlugin(t *testing.T) {
+	dir, store := signedPlugin(t, "acme")
+	load := func() *Plugin {
+		p, err := loadPluginFromDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	p := load()
+	if p.Manifest.EntrypointSHA256 == "" {
+		t.Fatal("entrypoint_sha256 not recorded")
+	}
+	if s := VerifyPlugin(p, store); s.State != SignatureValid || s.Publisher != "acme" {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if s := VerifyPlugin(p, &TrustStore{}); s.State != SignatureUnknownKey || s.Publisher != "acme" {
+		t.Errorf("unexpected status with an empty store %+v", s)
+	}
+
+	// Changing the binary or the manifest invalidates the signature
+	os.WriteFile(filepath.Join(dir, "format-signed"), []byte("#!/bin/sh\necho evil\n"), 0755)
+	if s := VerifyPlugin(load(), store); s.State != SignatureInvalid {
+		t.Errorf("modified binary verified: %+v", s)
+	}
+	manifestPath := filepath.Join(dir, "plugin.json")
+	data, _ := os.ReadFile(manifestPath)
+	os.WriteFile(manifestPath, append(data, ' '), 0644)
+	if s := VerifyPlugin(load(), store); s.State != SignatureInvalid {
+		t.Errorf("modified manifest verified: %+v", s)
+	}
+
+	os.Remove(filepath.Join(dir, SignatureFileName))
+	if s := VerifyPlugin(load(), store); s.State != SignatureUnsigned {
+		t.Errorf("unexpected status without a signature %+v", s)
+	}
+}
+
+func TestTrustPolicy(t *testing.T) {
+	dir, store := signedPlugin(t, "acme")
+	unsignedDir := t.TempDir()
+	os.WriteFile(filepath.Join(unsignedDir, "plugin.json"),
+		[]byte(`{"plugin_id": "format.unsigned", "version": "1.0.0", "kind": "format", "entrypoint": "x"}`), 0644)
+
+	tests := []struct {
+		name       string
+		policy     TrustPolicy
+		publishers []string
+		dir        string
+		want       bool
+	}{
+		{"all allows unsigned", TrustAll, nil, unsignedDir, true},
+		{"signed rejects unsigned", TrustSigned, nil, unsignedDir, false},
+		{"signed allows signed", TrustSigned, nil, dir, true},
+		{"publishers allows listed", TrustPublishers, []string{"acme"}, dir, true},
+		{"publishers rejects others", TrustPublishers, []string{"other"}, dir, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTrust(t, tt.policy, store, tt.publishers...)
+			p, err := loadPluginFromDir(tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = CheckPluginTrust(p)
+			if (err == nil) != tt.want {
+				t.Errorf("CheckPluginTrust() = %v, want allowed %v", err, tt.want)
+			}
+			if err != nil && !errors.Is(err, ErrUntrustedPlugin) {
+				t.Errorf("error %v is not ErrUntrustedPlugin", err)
+			}
+		})
+	}
+
+	// Rejected plugins are not loaded
+	withTrust(t, TrustSigned, store)
+	kinds := t.TempDir()
+	os.MkdirAll(filepath.Join(kinds, "format"), 0755)
+	if err := os.Rename(dir, filepath.Join(kinds, "format", "signed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(unsignedDir, filepath.Join(kinds, "format", "unsigned")); err != nil {
+		t.Fatal(err)
+	}
+	l := &Loader{plugins: make(map[string]*Plugin)}
+	if err := l.LoadFromDirAlways(kinds); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.GetPlugin("format.signed"); err != nil {
+		t.Error("signed plugin not loaded")
+	}
+	if _, err := l.GetPlugin("format.unsigned"); err == nil {
+		t.Error("unsigned plugin loaded")
+	}
+}
+
+func TestExecuteRecordsAndChecksBinary(t *testing.T) {
+	dir, store := signedPlugin(t, "acme")
+	withTrust(t, TrustSigned, store)
+	p, err := loadPluginFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPluginTrust(p); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := ExecutePlugin(p, &IPCRequest{Command: "detect"})
+	if err != nil || resp.Status != "ok" {
+		t.Fatalf("execute failed: %v %+v", err, resp)
+	}
+	if resp.BinarySHA256 != p.Manifest.EntrypointSHA256 {
+		t.Errorf("BinarySHA256 = %q, want %q", resp.BinarySHA256, p.Manifest.EntrypointSHA256)
+	}
+
+	// A binary replaced after loading is refused
+	os.WriteFile(filepath.Join(dir, "format-signed"), []byte("#!/bin/sh\necho '{\"status\":\"ok\"}'\n"), 0755)
+	if _, err := ExecutePlugin(p, &IPCRequest{Command: "detect"}); !errors.Is(err, ErrUntrustedPlugin) {
+		t.Errorf("expected ErrUntrustedPlugin, got %v", err)
+	}
+}
+
+func TestExecuteRefusesDisguisedReplacement(t *testing.T) {
+	dir, store := signedPlugin(t, "acme")
+	withTrust(t, TrustSigned, store)
+	p, err := loadPluginFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExecutePlugin(p, &IPCRequest{Command: "detect"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A binary of the same size with its modification time restored
+	entrypoint := filepath.Join(dir, "format-signed")
+	info, err := os.Stat(entrypoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\nread input\necho '{\"status\":\"ok\",\"result\":\"ho\"}'\n"
+	if err := os.WriteFile(entrypoint, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(entrypoint, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExecutePlugin(p, &IPCRequest{Command: "detect"}); !errors.Is(err, ErrUntrustedPlugin) {
+		t.Errorf("expected ErrUntrustedPlugin, got %v", err)
+	}
+}
+
+func TestVerifySignatureConcurrent(t *testing.T) {
+	dir, store := signedPlugin(t, "acme")
+	withTrust(t, TrustSigned, store)
+	p, err := loadPluginFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	statuses := make([]*SignatureStatus, 8)
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = p.VerifySignature()
+		}()
+	}
+	wg.Wait()
+	for _, s := range statuses {
+		if s != statuses[0] || s.State != SignatureValid {
+			t.Fatalf("unexpected status %+v", s)
+		}
+	}
+}
+
+func TestTrustStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trust", DefaultTrustStoreName)
+	store, err := LoadTrustStore(path)
+	if err != nil || len(store.Publishers) != 0 {
+		t.Fatalf("missing store: %+v %v", store, err)
+	}
+	pub, key, _ := GenerateSigningKey()
+	store.Add("acme", pub)
+	store.Add("acme-renamed", pub)
+	if err := store.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTrustStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := loaded.Lookup(KeyID(pub)); p == nil || p.Name != "acme-renamed" || len(loaded.Publishers) != 1 {
+		t.Errorf("unexpected store %+v", loaded)
+	}
+
+	parsed, err := ParseSigningKey(MarshalSigningKey(key))
+	if err != nil || !parsed.Equal(key) {
+		t.Errorf("signing key did not round-trip: %v", err)
+	}
+	if _, err := ParseTrustPolicy("bogus"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,72 @@
+// Command wasmplugin is a WebAssembly plugin for the runtime tests. It is
+// built with GOOS=wasip1 GOARCH=wasm.
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+type request struct {
+	Command string            `json:"command"`
+	Args    map[string]string `json:"args"`
+}
+
+var sink [][]byte
+
+func main() {
+	var req request
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		respond("error", err.Error(), nil)
+		return
+	}
+	switch req.Command {
+	case "read":
+		// Read any path, including ones the host did not grant
+		data, err := os.ReadFile(req.Args["file"])
+		if err != nil {
+			respond("error", err.Error(), nil)
+			return
+		}
+		respond("ok", "", string(data))
+	case "copy":
+		data, err := os.ReadFile(req.Args["path"])
+		if err == nil {
+			err = os.WriteFile(filepath.Join(req.Args["output_dir"], "copy.txt"), data, 0644)
+		}
+		if err != nil {
+			respond("error", err.Error(), nil)
+			return
+		}
+		respond("ok", "", "copied")
+	case "progress":
+		json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+			"status": "progress",
+			"event":  map[string]interface{}{"stage": "work", "done": 1, "total": 2},
+		})
+		respond("ok", "", "done")
+	case "alloc":
+		for {
+			sink = append(sink, make([]byte, 1<<20))
+		}
+	case "spin":
+		n := 0
+		for {
+			n = step(n)
+		}
+	default:
+		respond("error", "unknown command: "+req.Command, nil)
+	}
+}
+
+//go:noinline
+func step(n int) int { return n + 1 }
+
+func respond(status, msg string, result interface{}) {
+	json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+		"status": status,
+		"error":  msg,
+		"result": result,
+	})
+}
@@ -0,0 +1,465 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
+	"github.com/FocuswithJustin/JuniperBible/internal/logging"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Runtimes an external plugin's entrypoint can target.
+const (
+	// RuntimeNative runs the entrypoint as a host executable. It is the
+	// default.
+	RuntimeNative = "native"
+
+	// RuntimeWASM runs the entrypoint, a WebAssembly module built for
+	// WASI preview 1 (GOOS=wasip1 GOARCH=wasm), in-process. The module
+	// speaks the one-shot protocol over its stdin and stdout and sees
+	// only the files named by its request.
+	RuntimeWASM = "wasm"
+)
+
+// wasmPageSize is the size of a WebAssembly memory page.
+const wasmPageSize = 64 * 1024
+
+// WASMLimits bounds the resources of a WebAssembly plugin call.
+type WASMLimits struct {
+	// MemoryBytes caps the linear memory of the module. It is rounded
+	// down to whole 64 KiB pages, up to 4 GiB.
+	MemoryBytes uint64 `json:"memory_bytes"`
+	// CallBudget is the number of function calls a request may make
+	// before it is stopped, or 0 for no limit. It counts calls, not
+	// instructions, so a long loop without calls is bounded only by the
+	// timeout.
+	CallBudget uint64 `json:"call_budget"`
+}
+
+// DefaultWASMLimits returns the limits WebAssembly plugins run with unless
+// changed with SetWASMLimits.
+func DefaultWASMLimits() WASMLimits {
+	return WASMLimits{
+		MemoryBytes: 256 << 20,
+		CallBudget:  1 << 31,
+	}
+}
+
+// memoryPages returns the memory limit in pages.
+func (l WASMLimits) memoryPages() uint32 {
+	pages := l.MemoryBytes / wasmPageSize
+	if pages == 0 || pages > 65536 {
+		return 65536
+	}
+	return uint32(pages)
+}
+
+// ErrWASMCallBudgetExhausted is returned when a WebAssembly plugin makes
+// more function calls than its call budget allows.
+var ErrWASMCallBudgetExhausted = errors.New("wasm plugin exceeded its call budget")
+
+// wasmHost compiles and runs WebAssembly plugins. The runtime is created
+// on first use with the current limits, and compiled modules are kept
+// until the limits change or the entrypoint's hash does. A runtime or
+// module that is replaced while calls still use it is closed when the last
+// of them finishes.
+type wasmHost struct {
+	mu       sync.Mutex
+	limits   WASMLimits
+	runtime  *wasmRuntime
+	compiled map[string]*wasmModule
+}
+
+// wasmRuntime is a runtime and the number of calls using it.
+type wasmRuntime struct {
+	runtime wazero.Runtime
+	limits  WASMLimits
+	users   int
+	retired bool // Replaced; close when users reaches 0
+}
+
+// wasmModule is a compiled entrypoint and the number of calls using it.
+type wasmModule struct {
+	module  wazero.CompiledModule
+	rt      *wasmRuntime
+	sha256  string // Hash of the bytes that were compiled
+	users   int
+	retired bool // Replaced; close when users reaches 0
+}
+
+var wasmPlugins = &wasmHost{limits: DefaultWASMLimits()}
+
+// SetWASMLimits sets the limits WebAssembly plugins run with. Modules
+// compiled under the previous limits are discarded once no call uses them.
+func SetWASMLimits(l WASMLimits) {
+	wasmPlugins.mu.Lock()
+	defer wasmPlugins.mu.Unlock()
+	wasmPlugins.limits = l
+	wasmPlugins.reset()
+}
+
+// GetWASMLimits returns the limits WebAssembly plugins run with.
+func GetWASMLimits() WASMLimits {
+	wasmPlugins.mu.Lock()
+	defer wasmPlugins.mu.Unlock()
+	return wasmPlugins.limits
+}
+
+// reset retires the runtime and its compiled modules, which closes them
+// when no call uses them. The caller holds mu.
+func (h *wasmHost) reset() {
+	if h.runtime != nil {
+		h.runtime.retired = true
+		h.runtime.closeIfUnused()
+	}
+	h.runtime = nil
+	h.compiled = nil
+}
+
+// closeIfUnused closes a retired runtime, and with it its modules, once
+// no call uses it. The caller holds the host's mu.
+func (r *wasmRuntime) closeIfUnused() {
+	if r.retired && r.users == 0 {
+		r.runtime.Close(context.Background())
+	}
+}
+
+// module returns the compiled module of an entrypoint whose SHA-256 is
+// sha, compiling it if it is new or has changed. The bytes compiled must
+// be the ones that were hashed. The module and its runtime stay open until
+// the caller passes it to release.
+func (h *wasmHost) module(entrypoint, sha string) (*wasmModule, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if m, ok := h.compiled[entrypoint]; ok && m.sha256 == sha {
+		h.acquire(m)
+		return m, nil
+	}
+
+	ctx := context.Background()
+	if h.runtime == nil {
+		cfg := wazero.NewRuntimeConfig().
+			WithMemoryLimitPages(h.limits.memoryPages()).
+			WithCloseOnContextDone(true)
+		r := wazero.NewRuntimeWithConfig(ctx, cfg)
+		if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+			r.Close(ctx)
+			return nil, fmt.Errorf("failed to start wasm runtime: %w", err)
+		}
+		h.runtime = &wasmRuntime{runtime: r, limits: h.limits}
+		h.compiled = make(map[string]*wasmModule)
+	}
+
+	code, err := os.ReadFile(entrypoint)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(code)
+	if got := hex.EncodeToString(sum[:]); got != sha {
+		return nil, fmt.Errorf("entrypoint changed after it was checked: sha256 %s, expected %s", got, sha)
+	}
+	if h.limits.CallBudget > 0 {
+		ctx = experimental.WithFunctionListenerFactory(ctx, callListenerFactory)
+	}
+	compiled, err := h.runtime.runtime.CompileModule(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile wasm module: %w", err)
+	}
+	if old, ok := h.compiled[entrypoint]; ok {
+		old.retired = true
+		old.closeIfUnused()
+	}
+	m := &wasmModule{module: compiled, rt: h.runtime, sha256: sha}
+	h.compiled[entrypoint] = m
+	h.acquire(m)
+	return m, nil
+}
+
+// acquire records a call using m. The caller holds mu.
+func (h *wasmHost) acquire(m *wasmModule) {
+	m.users++
+	m.rt.users++
+}
+
+// release ends a call using m, closing m or its runtime if they were
+// replaced meanwhile and no other call uses them.
+func (h *wasmHost) release(m *wasmModule) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m.users--
+	m.rt.users--
+	m.closeIfUnused()
+	m.rt.closeIfUnused()
+}
+
+// closeIfUnused closes a retired module once no call uses it. Modules of
+// a closed runtime are already closed. The caller holds the host's mu.
+func (m *wasmModule) closeIfUnused() {
+	if m.retired && m.users == 0 && !m.rt.retired {
+		m.module.Close(context.Background())
+	}
+}
+
+// callBudget counts the function calls of one request. When the budget
+// runs out, the request's context is cancelled, which stops the module.
+type callBudget struct {
+	remaining atomic.Int64
+	empty     atomic.Bool
+	cancel    context.CancelFunc
+}
+
+type callBudgetKey struct{}
+
+// callListenerFactory charges each function call to the callBudget of
+// the calling context.
+var callListenerFactory = experimental.FunctionListenerFactoryFunc(
+	func(api.FunctionDefinition) experimental.FunctionListener {
+		return callListener
+	})
+
+var callListener = experimental.FunctionListenerFunc(
+	func(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+		tank, _ := ctx.Value(callBudgetKey{}).(*callBudget)
+		if tank != nil && tank.remaining.Add(-1) < 0 && !tank.empty.Swap(true) {
+			tank.cancel()
+		}
+	})
+
+// executeWASMPlugin runs a request through a WebAssembly plugin. The
+// module reads the request from stdin and writes its interim messages and
+// response to stdout, like a one-shot plugin. It can read the path and
+// ir_path arguments and write to output_dir, which are mounted at the
+// same paths in the guest, and nothing else. The module is compiled from
+// bytes whose SHA-256 is binaryHash, the hash the entrypoint was checked
+// with.
+func executeWASMPlugin(ctx context.Context, plugin *Plugin, req *IPCRequest, entrypoint, binaryHash string, timeout time.Duration) (*IPCResponse, error) {
+	reqData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	fsConfig, err := wasmMounts(req)
+	if err != nil {
+		return nil, err
+	}
+	m, err := wasmPlugins.module(entrypoint, binaryHash)
+	if err != nil {
+		return nil, fmt.Errorf("plugin execution failed: %w", err)
+	}
+	defer wasmPlugins.release(m)
+	limits := m.rt.limits
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	tank := &callBudget{cancel: cancel}
+	tank.remaining.Store(int64(min(limits.CallBudget, 1<<62)))
+	if limits.CallBudget > 0 {
+		runCtx = context.WithValue(runCtx, callBudgetKey{}, tank)
+	}
+
+	pr, pw := io.Pipe()
+	var stdout, stderr bytes.Buffer
+	type decoded struct {
+		resp *IPCResponse
+		err  error
+	}
+	decodeCh := make(chan decoded, 1)
+	go func() {
+		resp, err := decodeResponse(io.TeeReader(pr, &stdout), progressFunc(ctx))
+		io.Copy(io.Discard, pr)
+		decodeCh <- decoded{resp, err}
+	}()
+
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(filepath.Base(entrypoint)).
+		WithStdin(bytes.NewReader(reqData)).
+		WithStdout(pw).
+		WithStderr(&stderr).
+		WithFSConfig(fsConfig).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+
+	start := time.Now()
+	exitCode := 0
+	mod, err := m.rt.runtime.InstantiateModule(runCtx, m.module, cfg)
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = int(exitErr.ExitCode())
+		if exitCode == 0 {
+			err = nil
+		}
+	}
+	usage := &resource.Usage{WallMs: time.Since(start).Milliseconds(), ExitCode: exitCode}
+	if mod != nil {
+		if mem := mod.Memory(); mem != nil {
+			usage.MaxRSSBytes = int64(mem.Size())
+		}
+		mod.Close(context.Background())
+	}
+	pw.Close()
+	out := <-decodeCh
+	logging.Debug("wasm plugin finished", "plugin_id", plugin.Manifest.PluginID,
+		"command", req.Command, "usage", usage.String())
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("plugin execution cancelled: %w", ctx.Err())
+	}
+	if tank.empty.Load() {
+		return nil, fmt.Errorf("%w after %d calls", ErrWASMCallBudgetExhausted, limits.CallBudget)
+	}
+	if runCtx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("plugin execution timed out after %v", timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plugin execution failed: %w (stderr: %s)", err, stderr.String())
+	}
+	if out.err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w (output: %s)", out.err, stdout.String())
+	}
+	out.resp.Usage = usage
+	return out.resp, nil
+}
+
+// wasmMounts returns the filesystem a WebAssembly plugin sees for a
+// request: the path and ir_path arguments read-only and output_dir
+// read-write, each at its host path. An input file is mounted alone, not
+// with the rest of its directory. The output directory is created if
+// needed.
+func wasmMounts(req *IPCRequest) (wazero.FSConfig, error) {
+	cfg := wazero.NewFSConfig()
+	outputDir := ""
+	if dir, _ := req.Args["output_dir"].(string); dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(abs, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+		outputDir = abs
+		cfg = cfg.WithDirMount(abs, guestPath(abs))
+	}
+
+	mounted := map[string]bool{}
+	for _, key := range []string{"path", "ir_path"} {
+		p, _ := req.Args[key].(string)
+		if p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			continue // Let the plugin report the missing input
+		}
+		if outputDir != "" && isWithin(abs, outputDir) {
+			continue
+		}
+		if info.IsDir() {
+			if !mounted[abs] {
+				cfg = cfg.WithReadOnlyDirMount(abs, guestPath(abs))
+				mounted[abs] = true
+			}
+			continue
+		}
+		dir := filepath.Dir(abs)
+		if mounted[dir] {
+			return nil, fmt.Errorf("wasm plugins cannot be given two input files in %s", dir)
+		}
+		cfg = cfg.WithFSMount(fileFS{path: abs, name: filepath.Base(abs)}, guestPath(dir))
+		mounted[dir] = true
+	}
+	return cfg, nil
+}
+
+// guestPath returns the guest path a host path is mounted at.
+func guestPath(hostPath string) string {
+	p := filepath.ToSlash(hostPath)
+	if vol := filepath.VolumeName(hostPath); vol != "" {
+		p = strings.TrimPrefix(p, filepath.ToSlash(vol))
+	}
+	return path.Clean("/" + p)
+}
+
+// isWithin reports whether path is dir or inside it.
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// fileFS is a read-only filesystem holding a single file.
+type fileFS struct {
+	path string // Host path of the file
+	name string // Name of the file in the filesystem
+}
+
+func (f fileFS) Open(name string) (fs.File, error) {
+	switch name {
+	case f.name:
+		return os.Open(f.path)
+	case ".":
+		info, err := os.Stat(f.path)
+		if err != nil {
+			return nil, err
+		}
+		return &fileFSRoot{entry: fs.FileInfoToDirEntry(info)}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// fileFSRoot is the root directory of a fileFS.
+type fileFSRoot struct {
+	entry fs.DirEntry
+	read  bool
+}
+
+func (d *fileFSRoot) Stat() (fs.FileInfo, error) { return fileFSRootInfo{}, nil }
+func (d *fileFSRoot) Close() error               { return nil }
+
+func (d *fileFSRoot) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: errors.New("is a directory")}
+}
+
+func (d *fileFSRoot) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.read {
+		if n > 0 {
+			return nil, io.EOF
+		}
+		return nil, nil
+	}
+	d.read = true
+	return []fs.DirEntry{d.entry}, nil
+}
+
+type fileFSRootInfo struct{}
+
+func (fileFSRootInfo) Name() string       { return "." }
+func (fileFSRootInfo) Size() int64        { return 0 }
+func (fileFSRootInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (fileFSRootInfo) ModTime() time.Time { return time.Time{} }
+func (fileFSRootInfo) IsDir() bool        { return true }
+func (fileFSRootInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,222 @@
+package plugins
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+var (
+	wasmPluginOnce sync.Once
+	wasmPluginPath string
+	wasmPluginErr  error
+)
+
+// wasmTestPlugin builds testdata/wasmplugin for WASI and returns a plugin
+// running it.
+func wasmTestPlugin(t *testing.T) *Plugin {
+	t.Helper()
+	wasmPluginOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "wasmplugin")
+		if err != nil {
+			wasmPluginErr = err
+			return
+		}
+		wasmPluginPath = filepath.Join(dir, "plugin.wasm")
+		cmd := exec.Command("go", "build", "-o", wasmPluginPath, "./testdata/wasmplugin")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			wasmPluginErr = errors.New(string(out))
+		}
+	})
+	if wasmPluginErr != nil {
+		t.Skipf("cannot build wasm test plugin: %v", wasmPluginErr)
+	}
+	return &Plugin{
+		Manifest: &PluginManifest{
+			PluginID:   "test.wasm",
+			Version:    "1.0.0",
+			Kind:       "format",
+			Entrypoint: filepath.Base(wasmPluginPath),
+		},
+		Path: filepath.Dir(wasmPluginPath),
+	}
+}
+
+func TestManifestIsWASM(t *testing.T) {
+	tests := []struct {
+		runtime, entrypoint string
+		want                bool
+	}{
+		{"", "format-x", false},
+		{"", "format-x.wasm", true},
+		{RuntimeWASM, "format-x", true},
+		{RuntimeNative, "format-x.wasm", false},
+	}
+	for _, tt := range tests {
+		m := &PluginManifest{Runtime: tt.runtime, Entrypoint: tt.entrypoint}
+		if got := m.IsWASM(); got != tt.want {
+			t.Errorf("IsWASM(%q, %q) = %v, want %v", tt.runtime, tt.entrypoint, got, tt.want)
+		}
+	}
+}
+
+func TestWASMPluginFilesystem(t *testing.T) {
+	plugin := wasmTestPlugin(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in", "input.txt")
+	secret := filepath.Join(dir, "in", "secret.txt")
+	outDir := filepath.Join(dir, "out")
+	os.MkdirAll(filepath.Dir(input), 0755)
+	os.WriteFile(input, []byte("hello"), 0644)
+	os.WriteFile(secret, []byte("secret"), 0644)
+
+	// The input file can be read and the output directory written
+	resp, err := ExecutePlugin(plugin, &IPCRequest{Command: "copy", Args: map[string]interface{}{
+		"path": input, "output_dir": outDir,
+	}})
+	if err != nil || resp.Status != "ok" {
+		t.Fatalf("copy failed: %v %+v", err, resp)
+	}
+	if data, _ := os.ReadFile(filepath.Join(outDir, "copy.txt")); string(data) != "hello" {
+		t.Errorf("copy.txt = %q", data)
+	}
+	if resp.Usage == nil {
+		t.Error("usage not recorded")
+	}
+
+	// Its siblings and anything else cannot
+	for _, file := range []string{secret, "/etc/passwd"} {
+		resp, err := ExecutePlugin(plugin, &IPCRequest{Command: "read", Args: map[string]interface{}{
+			"path": input, "file": file,
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != "error" {
+			t.Errorf("plugin read %s: %+v", file, resp)
+		}
+	}
+}
+
+func TestWASMPluginProgress(t *testing.T) {
+	plugin := wasmTestPlugin(t)
+	var events []ProgressEvent
+	ctx := WithProgress(t.Context(), func(ev ProgressEvent) {
+		events = append(events, ev)
+	})
+	resp, err := ExecutePluginContext(ctx, plugin, &IPCRequest{Command: "progress"})
+	if err != nil || resp.Status != "ok" {
+		t.Fatalf("progress failed: %v %+v", err, resp)
+	}
+	if len(events) != 1 || events[0].Stage != "work" || events[0].PluginID != "test.wasm" {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestWASMPluginLimits(t *testing.T) {
+	plugin := wasmTestPlugin(t)
+	defer SetWASMLimits(DefaultWASMLimits())
+
+	SetWASMLimits(WASMLimits{MemoryBytes: 64 << 20, CallBudget: 5_000_000})
+	_, err := ExecutePluginWithTimeout(plugin, &IPCRequest{Command: "spin"}, time.Minute)
+	if !errors.Is(err, ErrWASMCallBudgetExhausted) {
+		t.Errorf("expected call budget exhaustion, got %v", err)
+	}
+
+	_, err = ExecutePluginWithTimeout(plugin, &IPCRequest{Command: "alloc"}, time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "out of memory") {
+		t.Errorf("expected an out of memory failure, got %v", err)
+	}
+
+	SetWASMLimits(WASMLimits{MemoryBytes: 64 << 20})
+	_, err = ExecutePluginWithTimeout(plugin, &IPCRequest{Command: "spin"}, 500*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestWASMHostKeepsModulesInUse(t *testing.T) {
+	plugin := wasmTestPlugin(t)
+	code, err := os.ReadFile(filepath.Join(plugin.Path, plugin.Manifest.Entrypoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entrypoint := filepath.Join(t.TempDir(), "plugin.wasm")
+	write := func(code []byte) string {
+		if err := os.WriteFile(entrypoint, code, 0644); err != nil {
+			t.Fatal(err)
+		}
+		hash, err := fileSHA256(entrypoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+	h := &wasmHost{limits: DefaultWASMLimits()}
+
+	run := func(m *wasmModule) error {
+		var stdout bytes.Buffer
+		cfg := wazero.NewModuleConfig().
+			WithName("").
+			WithStdin(strings.NewReader(`{"command":"progress"}`)).
+			WithStdout(&stdout)
+		mod, err := m.rt.runtime.InstantiateModule(t.Context(), m.module, cfg)
+		if mod != nil {
+			mod.Close(t.Context())
+		}
+		var exitErr *sys.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 0 {
+			err = nil
+		}
+		if err == nil && !strings.Contains(stdout.String(), `"status":"ok"`) {
+			err = fmt.Errorf("unexpected output %q", stdout.String())
+		}
+		return err
+	}
+
+	first, err := h.module(entrypoint, write(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A modified entrypoint is recompiled while the first module is in
+	// use. The modification is a custom section named "t".
+	second, err := h.module(entrypoint, write(append(code, 0, 3, 1, 't', 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatal("expected the modified entrypoint to be recompiled")
+	}
+	// New limits retire the runtime both modules use
+	h.mu.Lock()
+	h.reset()
+	h.mu.Unlock()
+
+	for _, m := range []*wasmModule{first, second} {
+		if err := run(m); err != nil {
+			t.Errorf("module closed while in use: %v", err)
+		}
+	}
+	h.release(first)
+	h.release(second)
+
+	// Bytes that do not have the hash the entrypoint was checked with are
+	// not compiled
+	if _, err := h.module(entrypoint, strings.Repeat("0", 64)); err == nil {
+		t.Error("expected a module with another hash to be refused")
+	}
+	if err := run(second); err == nil {
+		t.Error("expected the retired runtime to be closed after its last call")
+	}
+}
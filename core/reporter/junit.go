@@ -0,0 +1,111 @@
+package reporter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// junitTestSuites is the root element of a JUnit XML report, in the
+// dialect understood by Jenkins, GitLab and GitHub test reporters.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes suites as a JUnit XML report.
+func WriteJUnit(w io.Writer, suites ...*Suite) error {
+	root := junitTestSuites{}
+	var total time.Duration
+	for _, s := range suites {
+		js := junitTestSuite{
+			Name:     s.Name,
+			Tests:    len(s.Cases),
+			Failures: s.Count(StatusFail),
+			Errors:   s.Count(StatusError),
+			Skipped:  s.Count(StatusSkipped),
+			Time:     junitSeconds(s.Duration()),
+		}
+		if !s.Timestamp.IsZero() {
+			js.Timestamp = s.Timestamp.Format("2006-01-02T15:04:05")
+		}
+		for _, c := range s.Cases {
+			jc := junitTestCase{
+				Name:      c.Name,
+				ClassName: c.ClassName,
+				Time:      junitSeconds(c.Duration),
+			}
+			if jc.ClassName == "" {
+				jc.ClassName = s.Name
+			}
+			msg := &junitMessage{Message: c.Message, Body: c.Output}
+			switch c.Status {
+			case StatusFail:
+				jc.Failure = msg
+			case StatusError:
+				jc.Error = msg
+			case StatusSkipped:
+				jc.Skipped = msg
+			default:
+				jc.SystemOut = c.Output
+			}
+			js.Cases = append(js.Cases, jc)
+		}
+
+		root.Suites = append(root.Suites, js)
+		root.Tests += js.Tests
+		root.Failures += js.Failures
+		root.Errors += js.Errors
+		root.Skipped += js.Skipped
+		total += s.Duration()
+	}
+	root.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return fmt.Errorf("failed to encode JUnit report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitSeconds formats a duration as JUnit's decimal seconds.
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
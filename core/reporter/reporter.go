@@ -0,0 +1,127 @@
+// Package reporter renders test and verification results in the standard
+// formats consumed by CI pipelines and review tooling: JUnit XML and TAP
+// for pass/fail results, and SARIF for findings that carry file and line
+// locations.
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Output formats.
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+	FormatTAP   = "tap"
+	FormatSARIF = "sarif"
+)
+
+// Case status values.
+const (
+	StatusPass    = "pass"
+	StatusFail    = "fail"
+	StatusSkipped = "skipped"
+	StatusError   = "error"
+)
+
+// Finding levels, as defined by SARIF.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+)
+
+// Suite is a named group of test cases, such as the checks of a selfcheck
+// plan or the fixtures of a golden test run.
+type Suite struct {
+	Name      string
+	Timestamp time.Time
+	Cases     []Case
+}
+
+// Case is the result of a single test.
+type Case struct {
+	Name      string
+	ClassName string
+	Status    string
+	Message   string // Short failure reason.
+	Output    string // Longer detail, such as expected and actual hashes.
+	Duration  time.Duration
+	Findings  []Finding
+}
+
+// Finding is a validation or lint finding. File, Line and Column locate it
+// when known; Line and Column are 1-based and zero when unknown.
+type Finding struct {
+	RuleID  string
+	Level   string
+	Message string
+	File    string
+	Line    int
+	Column  int
+}
+
+// NewSuite creates an empty suite timestamped now.
+func NewSuite(name string) *Suite {
+	return &Suite{Name: name, Timestamp: time.Now().UTC()}
+}
+
+// Add appends a case to the suite.
+func (s *Suite) Add(c Case) {
+	s.Cases = append(s.Cases, c)
+}
+
+// Count returns the number of cases with the given status.
+func (s *Suite) Count(status string) int {
+	n := 0
+	for _, c := range s.Cases {
+		if c.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Duration returns the total duration of the suite's cases.
+func (s *Suite) Duration() time.Duration {
+	var d time.Duration
+	for _, c := range s.Cases {
+		d += c.Duration
+	}
+	return d
+}
+
+// Findings returns the findings of every case of the suites, in order.
+func Findings(suites ...*Suite) []Finding {
+	var findings []Finding
+	for _, s := range suites {
+		for _, c := range s.Cases {
+			findings = append(findings, c.Findings...)
+		}
+	}
+	return findings
+}
+
+// Write renders suites in a structured format: junit, tap or sarif. tool
+// and version identify the producer in SARIF output.
+func Write(w io.Writer, format, tool, version string, suites ...*Suite) error {
+	switch format {
+	case FormatJUnit:
+		return WriteJUnit(w, suites...)
+	case FormatTAP:
+		return WriteTAP(w, suites...)
+	case FormatSARIF:
+		return WriteSARIF(w, tool, version, Findings(suites...))
+	default:
+		return fmt.Errorf("unsupported report format: %s", format)
+	}
+}
+
+// IsStructured reports whether format is rendered by this package rather
+// than by a command's own text or JSON output.
+func IsStructured(format string) bool {
+	return format == FormatJUnit || format == FormatTAP || format == FormatSARIF
+}
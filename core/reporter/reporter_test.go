@@ -0,0 +1,128 @@
+package reporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSuite() *Suite {
+	s := NewSuite("selfcheck identity-bytes")
+	s.Add(Case{Name: "step 1: export", ClassName: "steps", Status: StatusPass, Duration: 1500 * time.Millisecond})
+	s.Add(Case{
+		Name:    "bytes # equal",
+		Status:  StatusFail,
+		Message: "hash mismatch",
+		Output:  "expected abc\nactual def",
+		Findings: []Finding{{
+			RuleID:  "SCHEMA_VALID/osis",
+			Level:   LevelError,
+			Message: "not well-formed",
+			File:    "kjv.osis.xml",
+			Line:    3,
+			Column:  7,
+		}, {
+			RuleID:  "fixity",
+			Message: "hash mismatch",
+		}},
+	})
+	s.Add(Case{Name: "skipped step", Status: StatusSkipped, Message: "step 1 fail"})
+	return s
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, testSuite()); err != nil {
+		t.Fatalf("WriteJUnit failed: %v", err)
+	}
+
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid XML: %v\n%s", err, buf.String())
+	}
+	if got.Tests != 3 || got.Failures != 1 || got.Skipped != 1 || len(got.Suites) != 1 {
+		t.Errorf("unexpected totals: %+v", got)
+	}
+	cases := got.Suites[0].Cases
+	if cases[0].Time != "1.500" || cases[0].Failure != nil {
+		t.Errorf("unexpected passing case %+v", cases[0])
+	}
+	if cases[1].Failure == nil || cases[1].Failure.Message != "hash mismatch" || !strings.Contains(cases[1].Failure.Body, "actual def") {
+		t.Errorf("unexpected failing case %+v", cases[1])
+	}
+	if cases[1].ClassName != "selfcheck identity-bytes" {
+		t.Errorf("classname = %q, want the suite name", cases[1].ClassName)
+	}
+	if cases[2].Skipped == nil {
+		t.Error("expected skipped element")
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteTAP(&buf, testSuite()); err != nil {
+		t.Fatalf("WriteTAP failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"TAP version 13\n1..3\n",
+		"ok 1 - step 1: export\n",
+		"not ok 2 - bytes \\# equal\n  ---\n  severity: fail\n  message: \"hash mismatch\"\n  data: |\n    expected abc\n    actual def\n  ...\n",
+		"ok 3 - skipped step # SKIP step 1 fail\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("TAP output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, FormatSARIF, "capsule", "1.0", testSuite()); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var got sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if got.Version != "2.1.0" || len(got.Runs) != 1 {
+		t.Fatalf("unexpected log %+v", got)
+	}
+	run := got.Runs[0]
+	if run.Tool.Driver.Name != "capsule" || len(run.Tool.Driver.Rules) != 2 {
+		t.Errorf("unexpected driver %+v", run.Tool.Driver)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(run.Results))
+	}
+	loc := run.Results[0].Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "kjv.osis.xml" || loc.Region == nil || loc.Region.StartLine != 3 || loc.Region.StartColumn != 7 {
+		t.Errorf("unexpected location %+v", loc)
+	}
+	if run.Results[1].Level != LevelError || run.Results[1].Locations != nil {
+		t.Errorf("unexpected unlocated result %+v", run.Results[1])
+	}
+}
+
+func TestWriteSARIFNoFindings(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, "capsule", "", nil); err != nil {
+		t.Fatalf("WriteSARIF failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"results": []`) {
+		t.Errorf("expected an empty results array:\n%s", buf.String())
+	}
+}
+
+func TestWriteUnsupportedFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, FormatText, "capsule", "", testSuite()); err == nil {
+		t.Error("expected error for text format")
+	}
+	if IsStructured(FormatJSON) || !IsStructured(FormatTAP) {
+		t.Error("IsStructured misclassifies formats")
+	}
+}
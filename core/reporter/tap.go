@@ -0,0 +1,64 @@
+package reporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteTAP writes suites as a TAP version 13 stream. Every case is a test
+// point; failures carry a YAML diagnostic block with their message and
+// output.
+func WriteTAP(w io.Writer, suites ...*Suite) error {
+	bw := bufio.NewWriter(w)
+	total := 0
+	for _, s := range suites {
+		total += len(s.Cases)
+	}
+	fmt.Fprintln(bw, "TAP version 13")
+	fmt.Fprintf(bw, "1..%d\n", total)
+
+	n := 0
+	for _, s := range suites {
+		if len(suites) > 1 {
+			fmt.Fprintf(bw, "# %s\n", tapEscape(s.Name))
+		}
+		for _, c := range s.Cases {
+			n++
+			status := "ok"
+			if c.Status == StatusFail || c.Status == StatusError {
+				status = "not ok"
+			}
+			fmt.Fprintf(bw, "%s %d - %s", status, n, tapEscape(c.Name))
+			if c.Status == StatusSkipped {
+				fmt.Fprintf(bw, " # SKIP %s", tapEscape(c.Message))
+			}
+			fmt.Fprintln(bw)
+
+			if status == "not ok" {
+				fmt.Fprintln(bw, "  ---")
+				fmt.Fprintf(bw, "  severity: %s\n", c.Status)
+				if c.Message != "" {
+					fmt.Fprintf(bw, "  message: %q\n", c.Message)
+				}
+				if c.Output != "" {
+					fmt.Fprintln(bw, "  data: |")
+					for _, line := range strings.Split(strings.TrimRight(c.Output, "\n"), "\n") {
+						fmt.Fprintf(bw, "    %s\n", line)
+					}
+				}
+				fmt.Fprintln(bw, "  ...")
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// tapEscape keeps a description on one line and escapes the characters
+// TAP gives meaning to.
+func tapEscape(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "#", "\\#")
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,195 @@
+// Package resource measures the resources used by tool and plugin
+// processes and describes the limits they run with.
+package resource
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Limits are resource limits applied to a tool or plugin process. Zero
+// values leave the corresponding limit unchanged.
+type Limits struct {
+	CPUSeconds   uint64 `json:"cpu_seconds,omitempty"`
+	AddressSpace uint64 `json:"address_space_bytes,omitempty"`
+	FileSize     uint64 `json:"file_size_bytes,omitempty"`
+	OpenFiles    uint64 `json:"open_files,omitempty"`
+}
+
+// DefaultLimits returns the limits tools and plugins run with by default.
+func DefaultLimits() Limits {
+	return Limits{
+		CPUSeconds:   300,
+		AddressSpace: 4 << 30,
+		FileSize:     1 << 30,
+		OpenFiles:    256,
+	}
+}
+
+// String returns the limits in a stable form for engine specs.
+func (l Limits) String() string {
+	return fmt.Sprintf("cpu=%ds as=%d fsize=%d nofile=%d", l.CPUSeconds, l.AddressSpace, l.FileSize, l.OpenFiles)
+}
+
+// IsZero reports whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// ShellPrefix returns ulimit commands applying the limits in a POSIX
+// shell, followed by "; " so a command can be appended. Core dumps are
+// always disabled. Sizes are rounded down to the shell's units: KiB for
+// the address space and 512-byte blocks for the file size.
+func (l Limits) ShellPrefix() string {
+	cmds := []string{"ulimit -c 0"}
+	if l.CPUSeconds > 0 {
+		cmds = append(cmds, fmt.Sprintf("ulimit -t %d", l.CPUSeconds))
+	}
+	if l.AddressSpace > 0 {
+		cmds = append(cmds, fmt.Sprintf("ulimit -v %d", max(l.AddressSpace/1024, 1)))
+	}
+	if l.FileSize > 0 {
+		cmds = append(cmds, fmt.Sprintf("ulimit -f %d", max(l.FileSize/512, 1)))
+	}
+	if l.OpenFiles > 0 {
+		cmds = append(cmds, fmt.Sprintf("ulimit -n %d", l.OpenFiles))
+	}
+	return strings.Join(cmds, " && ") + "; "
+}
+
+// Usage is the resources used by one process run, including the children
+// it waited for. Read and written bytes count block I/O, so reads served
+// from the page cache are not included.
+type Usage struct {
+	UserCPUMs   int64   `json:"user_cpu_ms"`
+	SystemCPUMs int64   `json:"system_cpu_ms"`
+	WallMs      int64   `json:"wall_ms"`
+	MaxRSSBytes int64   `json:"max_rss_bytes"`
+	ReadBytes   int64   `json:"read_bytes"`
+	WriteBytes  int64   `json:"write_bytes"`
+	ExitCode    int     `json:"exit_code"`
+	Limits      *Limits `json:"limits,omitempty"`
+}
+
+// Measure returns the usage of a finished process. ps may be nil when the
+// process could not be started, in which case only the wall time is set.
+func Measure(ps *os.ProcessState, wall time.Duration) *Usage {
+	u := &Usage{WallMs: wall.Milliseconds()}
+	if ps == nil {
+		return u
+	}
+	u.UserCPUMs = ps.UserTime().Milliseconds()
+	u.SystemCPUMs = ps.SystemTime().Milliseconds()
+	u.ExitCode = ps.ExitCode()
+	measureSys(ps, u)
+	return u
+}
+
+// CPUMs returns the user and system CPU time in milliseconds.
+func (u *Usage) CPUMs() int64 {
+	return u.UserCPUMs + u.SystemCPUMs
+}
+
+// Attributes returns the usage as transcript event attributes.
+func (u *Usage) Attributes() map[string]interface{} {
+	attrs := map[string]interface{}{
+		"user_cpu_ms":   u.UserCPUMs,
+		"system_cpu_ms": u.SystemCPUMs,
+		"wall_ms":       u.WallMs,
+		"max_rss_bytes": u.MaxRSSBytes,
+		"read_bytes":    u.ReadBytes,
+		"write_bytes":   u.WriteBytes,
+		"exit_code":     u.ExitCode,
+	}
+	if u.Limits != nil {
+		attrs["limits"] = u.Limits.String()
+	}
+	return attrs
+}
+
+// String summarizes the usage on one line.
+func (u *Usage) String() string {
+	return fmt.Sprintf("cpu %s (user %s, sys %s), wall %s, rss %s, read %s, write %s, exit %d",
+		formatMs(u.CPUMs()), formatMs(u.UserCPUMs), formatMs(u.SystemCPUMs), formatMs(u.WallMs),
+		FormatBytes(u.MaxRSSBytes), FormatBytes(u.ReadBytes), FormatBytes(u.WriteBytes), u.ExitCode)
+}
+
+// Regression thresholds below which growth is not reported, since small
+// measurements vary more than the factor between runs.
+const (
+	MinCPUMs    = 100
+	MinRSSBytes = 16 << 20
+	MinIOBytes  = 1 << 20
+)
+
+// Regression is a measurement that grew beyond the allowed factor.
+type Regression struct {
+	Metric   string  `json:"metric"`
+	Baseline int64   `json:"baseline"`
+	Current  int64   `json:"current"`
+	Factor   float64 `json:"factor"`
+}
+
+// String describes the regression as "metric: baseline -> current (Nx)".
+func (r Regression) String() string {
+	if r.Metric == "exit_code" {
+		return fmt.Sprintf("exit_code: %d -> %d", r.Baseline, r.Current)
+	}
+	format := FormatBytes
+	if r.Metric == "cpu" {
+		format = formatMs
+	}
+	return fmt.Sprintf("%s: %s -> %s (%.1fx)", r.Metric, format(r.Baseline), format(r.Current), r.Factor)
+}
+
+// Compare returns the measurements of current that exceed baseline by
+// more than factor, and a change from a zero exit status to a non-zero
+// one. Wall time is not compared because it depends on machine load.
+func Compare(baseline, current *Usage, factor float64) []Regression {
+	if baseline == nil || current == nil {
+		return nil
+	}
+	var regressions []Regression
+	check := func(metric string, base, cur, floor int64) {
+		if cur < floor || float64(cur) <= float64(base)*factor {
+			return
+		}
+		r := Regression{Metric: metric, Baseline: base, Current: cur}
+		if base > 0 {
+			r.Factor = float64(cur) / float64(base)
+		}
+		regressions = append(regressions, r)
+	}
+	check("cpu", baseline.CPUMs(), current.CPUMs(), MinCPUMs)
+	check("max_rss", baseline.MaxRSSBytes, current.MaxRSSBytes, MinRSSBytes)
+	check("read", baseline.ReadBytes, current.ReadBytes, MinIOBytes)
+	check("write", baseline.WriteBytes, current.WriteBytes, MinIOBytes)
+	if baseline.ExitCode == 0 && current.ExitCode != 0 {
+		regressions = append(regressions, Regression{
+			Metric:   "exit_code",
+			Baseline: int64(baseline.ExitCode),
+			Current:  int64(current.ExitCode),
+		})
+	}
+	return regressions
+}
+
+// FormatBytes formats a byte count with a binary unit.
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatMs(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).String()
+}
@@ -0,0 +1,88 @@
+package resource
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellPrefix(t *testing.T) {
+	l := Limits{CPUSeconds: 10, AddressSpace: 1 << 30, FileSize: 1 << 20, OpenFiles: 64}
+	want := "ulimit -c 0 && ulimit -t 10 && ulimit -v 1048576 && ulimit -f 2048 && ulimit -n 64; "
+	if got := l.ShellPrefix(); got != want {
+		t.Errorf("ShellPrefix() = %q, want %q", got, want)
+	}
+	if got := (Limits{}).ShellPrefix(); got != "ulimit -c 0; " {
+		t.Errorf("ShellPrefix() of no limits = %q", got)
+	}
+}
+
+func TestShellPrefixEnforced(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no POSIX shell")
+	}
+	l := Limits{FileSize: 4096}
+	cmd := exec.Command("/bin/sh", "-c", l.ShellPrefix()+"ulimit -f")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("shell failed: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "8" {
+		t.Errorf("file size limit = %q blocks, want 8", out)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no POSIX shell")
+	}
+	cmd := exec.Command("/bin/sh", "-c", "exit 3")
+	start := time.Now()
+	cmd.Run()
+	u := Measure(cmd.ProcessState, time.Since(start))
+	if u.ExitCode != 3 {
+		t.Errorf("exit code = %d, want 3", u.ExitCode)
+	}
+	if runtime.GOOS == "linux" && u.MaxRSSBytes == 0 {
+		t.Errorf("max RSS not measured")
+	}
+
+	if u := Measure(nil, time.Second); u.WallMs != 1000 || u.ExitCode != 0 {
+		t.Errorf("unexpected usage of unstarted process %+v", u)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	baseline := &Usage{UserCPUMs: 400, SystemCPUMs: 100, MaxRSSBytes: 32 << 20, WriteBytes: 4 << 20}
+	current := &Usage{UserCPUMs: 1500, SystemCPUMs: 100, MaxRSSBytes: 40 << 20, WriteBytes: 4 << 20, ExitCode: 137}
+
+	regressions := Compare(baseline, current, 2)
+	if len(regressions) != 2 {
+		t.Fatalf("got %v, want cpu and exit_code regressions", regressions)
+	}
+	if r := regressions[0]; r.Metric != "cpu" || r.Current != 1600 || r.String() != "cpu: 500ms -> 1.6s (3.2x)" {
+		t.Errorf("unexpected cpu regression %s", r)
+	}
+	if r := regressions[1]; r.String() != "exit_code: 0 -> 137" {
+		t.Errorf("unexpected exit regression %s", r)
+	}
+
+	// Growth of small measurements is noise
+	small := &Usage{UserCPUMs: 10, MaxRSSBytes: 1 << 20}
+	if r := Compare(small, &Usage{UserCPUMs: 90, MaxRSSBytes: 8 << 20}, 2); len(r) != 0 {
+		t.Errorf("unexpected regressions %v", r)
+	}
+	if r := Compare(nil, current, 2); r != nil {
+		t.Errorf("regressions without a baseline: %v", r)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1536: "1.5 KiB", 5 << 30: "5.0 GiB"} {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package resource
+
+import "os"
+
+// measureSys does nothing where processes have no rusage; only CPU times
+// and the exit status are measured.
+func measureSys(ps *os.ProcessState, u *Usage) {}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package resource
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// measureSys fills the measurements only the rusage of a process has.
+func measureSys(ps *os.ProcessState, u *Usage) {
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok || ru == nil {
+		return
+	}
+	// ru_maxrss is in bytes on Darwin and in KiB elsewhere
+	u.MaxRSSBytes = int64(ru.Maxrss)
+	if runtime.GOOS != "darwin" && runtime.GOOS != "ios" {
+		u.MaxRSSBytes *= 1024
+	}
+	u.ReadBytes = int64(ru.Inblock) * 512
+	u.WriteBytes = int64(ru.Oublock) * 512
+}
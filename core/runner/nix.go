@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
 	"github.com/FocuswithJustin/JuniperBible/internal/fileutil"
 )
 
@@ -48,6 +49,7 @@ var (
 // NixExecutor runs tools in a Nix-based deterministic environment.
 type NixExecutor struct {
 	FlakePath string // Path to the flake directory
+	Limits    Limits // Applied to the tool command with ulimit
 	Timeout   time.Duration
 }
 
@@ -55,10 +57,24 @@ type NixExecutor struct {
 func NewNixExecutor(flakePath string) *NixExecutor {
 	return &NixExecutor{
 		FlakePath: flakePath,
+		Limits:    DefaultLimits(),
 		Timeout:   5 * time.Minute,
 	}
 }
 
+// EngineSpec describes the Nix engine. The flake lock hash identifies the
+// tool versions the engine provides.
+func (e *NixExecutor) EngineSpec() *EngineSpec {
+	spec := NewEngineSpec("nix")
+	if data, err := os.ReadFile(filepath.Join(e.FlakePath, "flake.lock")); err == nil {
+		spec.Nix.FlakeLockSHA256 = cas.Hash(data)
+	}
+	if !e.Limits.IsZero() {
+		spec.Attributes = map[string]string{"limits": e.Limits.String()}
+	}
+	return spec
+}
+
 // ExecuteRequest runs a tool request and returns the result with transcript.
 func (e *NixExecutor) ExecuteRequest(ctx context.Context, req *Request, inputPaths []string) (*ExecutionResult, error) {
 	// SECURITY: Validate plugin ID and profile to prevent shell injection
@@ -71,57 +87,12 @@ func (e *NixExecutor) ExecuteRequest(ctx context.Context, req *Request, inputPat
 		}
 	}
 
-	// Create work directory
-	workDir, err := osMkdirTemp("", "capsule-run-*")
+	workDir, inDir, outDir, err := stageRequest(req, inputPaths)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create work dir: %w", err)
+		return nil, err
 	}
 	defer os.RemoveAll(workDir)
 
-	inDir := filepath.Join(workDir, "in")
-	outDir := filepath.Join(workDir, "out")
-
-	if err := osMkdirAll(inDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create in dir: %w", err)
-	}
-	if err := osMkdirAll(outDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create out dir: %w", err)
-	}
-
-	// Copy input files/directories
-	for i, path := range inputPaths {
-		info, err := os.Stat(path)
-		if err != nil {
-			return nil, fmt.Errorf("failed to stat input %d: %w", i, err)
-		}
-
-		if info.IsDir() {
-			// Copy directory recursively
-			if err := copyDir(path, inDir); err != nil {
-				return nil, fmt.Errorf("failed to copy input dir %d: %w", i, err)
-			}
-		} else {
-			// Copy single file
-			data, err := osReadFile(path)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read input %d: %w", i, err)
-			}
-			dest := filepath.Join(inDir, filepath.Base(path))
-			if err := osWriteFile(dest, data, 0644); err != nil {
-				return nil, fmt.Errorf("failed to write input %d: %w", i, err)
-			}
-		}
-	}
-
-	// Write request
-	reqData, err := req.ToJSON()
-	if err != nil {
-		return nil, fmt.Errorf("failed to serialize request: %w", err)
-	}
-	if err := osWriteFile(filepath.Join(inDir, "request.json"), reqData, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write request: %w", err)
-	}
-
 	// Build the command based on plugin type
 	var cmd *exec.Cmd
 	if ctx == nil {
@@ -138,7 +109,7 @@ func (e *NixExecutor) ExecuteRequest(ctx context.Context, req *Request, inputPat
 	}
 
 	// Determine tool command based on plugin
-	toolCmd := e.buildToolCommand(req, inDir, outDir)
+	toolCmd := e.Limits.ShellPrefix() + e.buildToolCommand(req, inDir, outDir)
 	nixArgs = append(nixArgs, "sh", "-c", toolCmd)
 
 	cmd = exec.CommandContext(ctxWithTimeout, "nix", nixArgs...)
@@ -170,12 +141,84 @@ func (e *NixExecutor) ExecuteRequest(ctx context.Context, req *Request, inputPat
 		}
 	}
 
-	// Build result
+	result := collectResult(outDir, exitCode, duration, stdout.Bytes(), stderr.Bytes())
+	result.Engine = e.EngineSpec()
+	// The usage includes nix itself, which execs the tool shell
+	result.Usage = resource.Measure(cmd.ProcessState, duration)
+	limits := e.Limits
+	result.Usage.Limits = &limits
+	return result, nil
+}
+
+// stageRequest creates a work directory with the inputs and request.json
+// in its in/ directory and an empty out/ directory. The caller removes
+// workDir.
+func stageRequest(req *Request, inputPaths []string) (workDir, inDir, outDir string, err error) {
+	// Create work directory
+	workDir, err = osMkdirTemp("", "capsule-run-*")
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to create work dir: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(workDir)
+		}
+	}()
+
+	inDir = filepath.Join(workDir, "in")
+	outDir = filepath.Join(workDir, "out")
+
+	if err := osMkdirAll(inDir, 0755); err != nil {
+		return "", "", "", fmt.Errorf("failed to create in dir: %w", err)
+	}
+	if err := osMkdirAll(outDir, 0755); err != nil {
+		return "", "", "", fmt.Errorf("failed to create out dir: %w", err)
+	}
+
+	// Copy input files/directories
+	for i, path := range inputPaths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to stat input %d: %w", i, err)
+		}
+
+		if info.IsDir() {
+			// Copy directory recursively
+			if err := copyDir(path, inDir); err != nil {
+				return "", "", "", fmt.Errorf("failed to copy input dir %d: %w", i, err)
+			}
+		} else {
+			// Copy single file
+			data, err := osReadFile(path)
+			if err != nil {
+				return "", "", "", fmt.Errorf("failed to read input %d: %w", i, err)
+			}
+			dest := filepath.Join(inDir, filepath.Base(path))
+			if err := osWriteFile(dest, data, 0644); err != nil {
+				return "", "", "", fmt.Errorf("failed to write input %d: %w", i, err)
+			}
+		}
+	}
+
+	// Write request
+	reqData, err := req.ToJSON()
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to serialize request: %w", err)
+	}
+	if err := osWriteFile(filepath.Join(inDir, "request.json"), reqData, 0644); err != nil {
+		return "", "", "", fmt.Errorf("failed to write request: %w", err)
+	}
+
+	return workDir, inDir, outDir, nil
+}
+
+// collectResult builds the result of a run from its out/ directory.
+func collectResult(outDir string, exitCode int, duration time.Duration, stdout, stderr []byte) *ExecutionResult {
 	result := &ExecutionResult{
 		ExitCode:  exitCode,
 		Duration:  duration,
-		Stdout:    stdout.Bytes(),
-		Stderr:    stderr.Bytes(),
+		Stdout:    stdout,
+		Stderr:    stderr,
 		OutputDir: outDir,
 	}
 
@@ -203,15 +246,26 @@ func (e *NixExecutor) ExecuteRequest(ctx context.Context, req *Request, inputPat
 		}
 	}
 
-	return result, nil
+	return result
 }
 
 // buildToolCommand builds the shell command for a given plugin.
 func (e *NixExecutor) buildToolCommand(req *Request, inDir, outDir string) string {
+	return toolCommand(req, inDir, outDir)
+}
+
+// buildSwordCommand builds the command for libsword operations.
+func (e *NixExecutor) buildSwordCommand(req *Request, inDir, outDir string) string {
+	return swordCommand(req, inDir, outDir)
+}
+
+// toolCommand builds the shell command for a given plugin. Every engine
+// runs the same command, so their transcripts can be compared.
+func toolCommand(req *Request, inDir, outDir string) string {
 	// Read request to get profile and args
 	switch req.PluginID {
 	case "libsword":
-		return e.buildSwordCommand(req, inDir, outDir)
+		return swordCommand(req, inDir, outDir)
 	default:
 		// Generic plugin execution
 		return fmt.Sprintf(`
@@ -222,8 +276,8 @@ func (e *NixExecutor) buildToolCommand(req *Request, inDir, outDir string) strin
 	}
 }
 
-// buildSwordCommand builds the command for libsword operations.
-func (e *NixExecutor) buildSwordCommand(req *Request, inDir, outDir string) string {
+// swordCommand builds the command for libsword operations.
+func swordCommand(req *Request, inDir, outDir string) string {
 	transcriptPath := filepath.Join(outDir, "transcript.jsonl")
 
 	// Base command that sets up SWORD environment
@@ -312,6 +366,9 @@ type ExecutionResult struct {
 	TranscriptHash string
 	OutputDir      string
 	OutputBlobs    map[string][]byte
+	Engine         *EngineSpec     // Engine that produced the result
+	Usage          *resource.Usage // Resources used, when measured
+	PluginSHA256   string          // Hash of the plugin executable, when a plugin ran the request
 }
 
 // ToRunOutputs converts the result to manifest run outputs.
@@ -0,0 +1,429 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+)
+
+// EngineTypeReplay is the type of the replay engine.
+const EngineTypeReplay = "replay"
+
+// RequestRecord is the canonical form of a tool request. Inputs are
+// identified by name and content rather than by path, so the same request
+// made from different work directories has the same record and hash.
+type RequestRecord struct {
+	PluginID      string                 `json:"plugin_id"`
+	PluginVersion string                 `json:"plugin_version,omitempty"`
+	Profile       string                 `json:"profile"`
+	Inputs        []RequestInput         `json:"inputs"`
+	Args          map[string]interface{} `json:"args,omitempty"`
+	Env           EnvConfig              `json:"env"`
+}
+
+// RequestInput is an input file of a request. Files of an input directory
+// are named by their path relative to the directory's parent.
+type RequestInput struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// NewRequestRecord builds the canonical record of a request run on the
+// given inputs.
+func NewRequestRecord(req *Request, inputPaths []string) (*RequestRecord, error) {
+	record := &RequestRecord{
+		PluginID:      req.PluginID,
+		PluginVersion: req.PluginVersion,
+		Profile:       req.Profile,
+		Inputs:        []RequestInput{},
+		Args:          req.Args,
+		Env:           req.Env,
+	}
+	for _, path := range inputPaths {
+		base := filepath.Dir(path)
+		err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(base, p)
+			if err != nil {
+				return err
+			}
+			record.Inputs = append(record.Inputs, RequestInput{Name: filepath.ToSlash(rel), SHA256: cas.Hash(data)})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash input %s: %w", path, err)
+		}
+	}
+	sort.Slice(record.Inputs, func(i, j int) bool { return record.Inputs[i].Name < record.Inputs[j].Name })
+	return record, nil
+}
+
+// JSON returns the canonical JSON encoding of the record.
+func (r *RequestRecord) JSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Hash returns the request hash, the SHA-256 of the canonical JSON.
+func (r *RequestRecord) Hash() (string, error) {
+	data, err := r.JSON()
+	if err != nil {
+		return "", err
+	}
+	return cas.Hash(data), nil
+}
+
+// Diff describes how other differs from r, one line per difference.
+func (r *RequestRecord) Diff(other *RequestRecord) []string {
+	var diffs []string
+	field := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			diffs = append(diffs, fmt.Sprintf("%s: recorded %v, requested %v", name, b, a))
+		}
+	}
+	field("plugin_id", r.PluginID, other.PluginID)
+	field("plugin_version", r.PluginVersion, other.PluginVersion)
+	field("profile", r.Profile, other.Profile)
+	field("args", r.Args, other.Args)
+	field("env", r.Env, other.Env)
+
+	recorded := make(map[string]string, len(other.Inputs))
+	for _, in := range other.Inputs {
+		recorded[in.Name] = in.SHA256
+	}
+	for _, in := range r.Inputs {
+		hash, ok := recorded[in.Name]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("input %s: not in the recorded request", in.Name))
+		case hash != in.SHA256:
+			diffs = append(diffs, fmt.Sprintf("input %s: recorded sha256 %s, requested %s", in.Name, hash, in.SHA256))
+		}
+		delete(recorded, in.Name)
+	}
+	var missing []string
+	for name := range recorded {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		diffs = append(diffs, fmt.Sprintf("input %s: recorded but not requested", name))
+	}
+	return diffs
+}
+
+// ToolPluginInfo describes the tool plugin of a run. When the tool has a
+// plugin in pluginDir, its binary hash and signature status are recorded;
+// a plugin rejected by the trust policy is an error, so the run is not
+// made. Otherwise only the tool ID is recorded.
+func ToolPluginInfo(pluginDir, toolID string) (*capsule.PluginInfo, error) {
+	if pluginDir != "" {
+		p, err := plugins.FindPlugin(pluginDir, toolID)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			return capsule.NewPluginInfo(p)
+		}
+	}
+	return &capsule.PluginInfo{PluginID: toolID, Kind: "tool"}, nil
+}
+
+// CheckToolPluginInfo checks, after a run, that the tool plugin described
+// by info before it still has the recorded binary hash, so a plugin
+// replaced while the run was made is not recorded under the old hash.
+func CheckToolPluginInfo(pluginDir string, info *capsule.PluginInfo) error {
+	if info == nil || info.BinarySHA256 == "" {
+		return nil
+	}
+	p, err := plugins.FindPlugin(pluginDir, info.PluginID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("tool plugin %s was removed during the run", info.PluginID)
+	}
+	binaryHash, err := p.BinarySHA256()
+	if err != nil {
+		return err
+	}
+	if binaryHash != info.BinarySHA256 {
+		return fmt.Errorf("tool plugin %s changed during the run", info.PluginID)
+	}
+	return nil
+}
+
+// RecordRun stores the result of a request as a run in a capsule. The
+// canonical request, transcript, stdout, stderr and output files are
+// stored as blobs, so the run can be replayed. run.Outputs and the request
+// blob of run.Command are filled in. Measured resources are recorded in
+// run.Resources and as an ENGINE_RESOURCES event in the engine transcript,
+// which is separate from the tool transcript because measurements differ
+// between otherwise identical runs. When a plugin ran the request, its
+// binary hash is recorded in run.Plugin.
+func RecordRun(cap *capsule.Capsule, run *capsule.Run, req *Request, inputPaths []string, result *ExecutionResult) error {
+	record, err := NewRequestRecord(req, inputPaths)
+	if err != nil {
+		return err
+	}
+	requestData, err := record.JSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize request: %w", err)
+	}
+	requestBlob, err := cap.StoreBlob(requestData, "application/json")
+	if err != nil {
+		return fmt.Errorf("failed to store request: %w", err)
+	}
+	if run.Command == nil {
+		run.Command = &capsule.Command{Profile: req.Profile}
+	}
+	run.Command.RequestBlobSHA256 = requestBlob.SHA256
+	if result.PluginSHA256 != "" && run.Plugin != nil {
+		run.Plugin.BinarySHA256 = result.PluginSHA256
+	}
+
+	outputs := &capsule.RunOutputs{ExitCode: result.ExitCode}
+	if len(result.Stdout) > 0 {
+		blob, err := cap.StoreBlob(result.Stdout, "text/plain")
+		if err != nil {
+			return fmt.Errorf("failed to store stdout: %w", err)
+		}
+		outputs.StdoutBlobSHA256 = blob.SHA256
+	}
+	if len(result.Stderr) > 0 {
+		blob, err := cap.StoreBlob(result.Stderr, "text/plain")
+		if err != nil {
+			return fmt.Errorf("failed to store stderr: %w", err)
+		}
+		outputs.StderrBlobSHA256 = blob.SHA256
+	}
+	for name, data := range result.OutputBlobs {
+		if err := ValidateOutputName(name); err != nil {
+			return err
+		}
+		blob, err := cap.StoreBlob(data, "application/octet-stream")
+		if err != nil {
+			return fmt.Errorf("failed to store output %s: %w", name, err)
+		}
+		if outputs.Files == nil {
+			outputs.Files = make(map[string]string)
+		}
+		outputs.Files[name] = blob.SHA256
+	}
+	if result.Usage != nil {
+		data, err := EngineTranscript(req, result)
+		if err != nil {
+			return err
+		}
+		blob, err := cap.StoreBlob(data, "application/x-ndjson")
+		if err != nil {
+			return fmt.Errorf("failed to store engine transcript: %w", err)
+		}
+		outputs.EngineTranscriptBlobSHA256 = blob.SHA256
+		run.Resources = result.Usage
+	}
+	run.Outputs = outputs
+
+	return cap.AddRun(run, result.TranscriptData)
+}
+
+// ValidateOutputName checks the name of a tool output file. Names are
+// slash-separated paths relative to the output directory; absolute names
+// and names with ".." elements are rejected, so a recorded run cannot
+// write outside the directory it is replayed into.
+func ValidateOutputName(name string) error {
+	if name == "" || path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		return fmt.Errorf("invalid output name %q", name)
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(name), "/") {
+		if elem == ".." {
+			return fmt.Errorf("invalid output name %q", name)
+		}
+	}
+	return nil
+}
+
+// EngineTranscript returns the engine transcript of a result, holding its
+// ENGINE_RESOURCES event.
+func EngineTranscript(req *Request, result *ExecutionResult) ([]byte, error) {
+	event := TranscriptEvent{
+		Type:          EventEngineResources,
+		PluginID:      req.PluginID,
+		PluginVersion: req.PluginVersion,
+		Profile:       req.Profile,
+		Attributes:    result.Usage.Attributes(),
+	}
+	if result.Engine != nil {
+		event.EngineID = result.Engine.EngineID
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize engine transcript: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ReplayExecutor serves recorded runs instead of running tools. A request
+// is answered with the transcript, stdout, stderr and output files of the
+// recorded run with the same request hash. Any difference fails the
+// request: an unrecorded request is reported with how it differs from the
+// recorded runs of the same plugin and profile, and every replayed blob
+// must match its recorded hash.
+type ReplayExecutor struct {
+	capsules []*capsule.Capsule
+}
+
+// NewReplayExecutor creates a replay executor serving the runs recorded in
+// the given capsules. Earlier capsules take precedence.
+func NewReplayExecutor(caps ...*capsule.Capsule) *ReplayExecutor {
+	return &ReplayExecutor{capsules: caps}
+}
+
+// EngineSpec describes the replay engine.
+func (e *ReplayExecutor) EngineSpec() *EngineSpec {
+	spec := NewEngineSpec("replay")
+	spec.Type = EngineTypeReplay
+	spec.Nix = NixConfig{}
+	return spec
+}
+
+// ExecuteRequest replays the recorded run of a request.
+func (e *ReplayExecutor) ExecuteRequest(ctx context.Context, req *Request, inputPaths []string) (*ExecutionResult, error) {
+	record, err := NewRequestRecord(req, inputPaths)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := record.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash request: %w", err)
+	}
+
+	cap, run := e.find(hash)
+	if run == nil {
+		return nil, e.mismatch(record, hash)
+	}
+
+	read := func(what, sha256 string) ([]byte, error) {
+		if sha256 == "" {
+			return nil, nil
+		}
+		data, err := cap.ReadBlob(sha256)
+		if err != nil {
+			return nil, fmt.Errorf("replay of run %s: failed to read %s: %w", run.ID, what, err)
+		}
+		if cas.Hash(data) != sha256 {
+			return nil, fmt.Errorf("replay of run %s: %s does not match its recorded hash %s", run.ID, what, sha256)
+		}
+		return data, nil
+	}
+
+	result := &ExecutionResult{
+		ExitCode:       run.Outputs.ExitCode,
+		TranscriptHash: run.Outputs.TranscriptBlobSHA256,
+		OutputBlobs:    make(map[string][]byte),
+		Engine:         e.EngineSpec(),
+	}
+	if result.TranscriptData, err = read("transcript", run.Outputs.TranscriptBlobSHA256); err != nil {
+		return nil, err
+	}
+	if result.Stdout, err = read("stdout", run.Outputs.StdoutBlobSHA256); err != nil {
+		return nil, err
+	}
+	if result.Stderr, err = read("stderr", run.Outputs.StderrBlobSHA256); err != nil {
+		return nil, err
+	}
+	for name, sha256 := range run.Outputs.Files {
+		if err := ValidateOutputName(name); err != nil {
+			return nil, fmt.Errorf("replay of run %s: %w", run.ID, err)
+		}
+		data, err := read("output "+name, sha256)
+		if err != nil {
+			return nil, err
+		}
+		result.OutputBlobs[name] = data
+	}
+
+	result.Engine.Attributes = map[string]string{"replayed_run": run.ID}
+	if run.Engine != nil {
+		result.Engine.Attributes["recorded_engine"] = run.Engine.Type + "/" + run.Engine.EngineID
+	}
+	return result, nil
+}
+
+// find returns the recorded run with the given request hash. Runs that
+// were themselves replayed are not sources.
+func (e *ReplayExecutor) find(hash string) (*capsule.Capsule, *capsule.Run) {
+	for _, cap := range e.capsules {
+		for _, id := range sortedRunIDs(cap) {
+			run := cap.Manifest.Runs[id]
+			if run.Command == nil || run.Command.RequestBlobSHA256 != hash || run.Outputs == nil {
+				continue
+			}
+			if run.Engine != nil && run.Engine.Type == EngineTypeReplay {
+				continue
+			}
+			return cap, run
+		}
+	}
+	return nil, nil
+}
+
+// mismatch explains why no recorded run matches a request.
+func (e *ReplayExecutor) mismatch(record *RequestRecord, hash string) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "replay: no recorded run for request %s (plugin %s, profile %s)", hash, record.PluginID, record.Profile)
+	candidates := 0
+	for _, cap := range e.capsules {
+		for _, id := range sortedRunIDs(cap) {
+			run := cap.Manifest.Runs[id]
+			if run.Command == nil || run.Command.RequestBlobSHA256 == "" {
+				continue
+			}
+			data, err := cap.ReadBlob(run.Command.RequestBlobSHA256)
+			if err != nil {
+				continue
+			}
+			var recorded RequestRecord
+			if err := json.Unmarshal(data, &recorded); err != nil {
+				continue
+			}
+			if recorded.PluginID != record.PluginID || recorded.Profile != record.Profile {
+				continue
+			}
+			candidates++
+			fmt.Fprintf(&sb, "\n  run %s differs:", id)
+			for _, d := range record.Diff(&recorded) {
+				fmt.Fprintf(&sb, "\n    %s", d)
+			}
+		}
+	}
+	if candidates == 0 {
+		sb.WriteString("; no recorded runs of this plugin and profile")
+	}
+	return fmt.Errorf("%s", sb.String())
+}
+
+// sortedRunIDs returns the run IDs of a capsule in a stable order.
+func sortedRunIDs(cap *capsule.Capsule) []string {
+	ids := make([]string, 0, len(cap.Manifest.Runs))
+	for id := range cap.Manifest.Runs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,228 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
+)
+
+func TestRequestRecordIgnoresPaths(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	for _, dir := range []string{dirA, dirB} {
+		if err := os.WriteFile(filepath.Join(dir, "input.txt"), []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := NewRequest("test-plugin", "test-profile")
+	a, err := NewRequestRecord(req, []string{filepath.Join(dirA, "input.txt")})
+	if err != nil {
+		t.Fatalf("NewRequestRecord failed: %v", err)
+	}
+	b, _ := NewRequestRecord(req, []string{filepath.Join(dirB, "input.txt")})
+	hashA, _ := a.Hash()
+	hashB, _ := b.Hash()
+	if hashA != hashB {
+		t.Errorf("same request from different directories hashed differently: %s, %s", hashA, hashB)
+	}
+
+	os.WriteFile(filepath.Join(dirB, "input.txt"), []byte("changed"), 0644)
+	b, _ = NewRequestRecord(NewRequest("test-plugin", "other"), []string{filepath.Join(dirB, "input.txt")})
+	diffs := b.Diff(a)
+	if len(diffs) != 2 || !strings.HasPrefix(diffs[0], "profile") || !strings.HasPrefix(diffs[1], "input input.txt") {
+		t.Errorf("unexpected diff %v", diffs)
+	}
+}
+
+func TestReplayExecutor(t *testing.T) {
+	cap, err := capsule.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := NewRequest("test-plugin", "test-profile")
+	recorded := &ExecutionResult{
+		ExitCode:       0,
+		Stdout:         []byte("out"),
+		TranscriptData: []byte(`{"event":"start"}` + "\n"),
+		OutputBlobs:    map[string][]byte{"result.txt": []byte("result")},
+		Engine:         NewEngineSpec("sandbox"),
+	}
+	run := &capsule.Run{
+		ID:     "run-1",
+		Engine: recorded.Engine.CapsuleEngine(),
+		Plugin: &capsule.PluginInfo{PluginID: "test-plugin", Kind: "tool"},
+		Status: "completed",
+	}
+	if err := RecordRun(cap, run, req, []string{input}, recorded); err != nil {
+		t.Fatalf("RecordRun failed: %v", err)
+	}
+
+	e := NewReplayExecutor(cap)
+	result, err := e.ExecuteRequest(context.Background(), req, []string{input})
+	if err != nil {
+		t.Fatalf("ExecuteRequest failed: %v", err)
+	}
+	if string(result.TranscriptData) != string(recorded.TranscriptData) || string(result.Stdout) != "out" {
+		t.Errorf("unexpected replay %+v", result)
+	}
+	if string(result.OutputBlobs["result.txt"]) != "result" {
+		t.Errorf("output not replayed: %v", result.OutputBlobs)
+	}
+	if result.Engine.Type != EngineTypeReplay || result.Engine.Attributes["replayed_run"] != "run-1" {
+		t.Errorf("unexpected engine %+v", result.Engine)
+	}
+
+	// A changed input is a mismatch that names the difference
+	os.WriteFile(input, []byte("changed"), 0644)
+	_, err = e.ExecuteRequest(context.Background(), req, []string{input})
+	if err == nil || !strings.Contains(err.Error(), "run run-1 differs") || !strings.Contains(err.Error(), "input input.txt") {
+		t.Errorf("expected a mismatch naming the input, got %v", err)
+	}
+
+	// A corrupted blob fails the replay
+	os.WriteFile(input, []byte("hello"), 0644)
+	blob := filepath.Join(cap.GetRoot(), "blobs", "sha256", run.Outputs.StdoutBlobSHA256[:2], run.Outputs.StdoutBlobSHA256)
+	if err := os.WriteFile(blob, []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.ExecuteRequest(context.Background(), req, []string{input})
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected a hash mismatch, got %v", err)
+	}
+}
+
+func TestReplayRejectsUnsafeOutputNames(t *testing.T) {
+	for _, name := range []string{"", "/etc/passwd", "../escape.txt", "out/../../escape.txt"} {
+		if err := ValidateOutputName(name); err == nil {
+			t.Errorf("expected %q to be rejected", name)
+		}
+	}
+	if err := ValidateOutputName("out/result.txt"); err != nil {
+		t.Errorf("expected a nested name to be accepted: %v", err)
+	}
+
+	cap, err := capsule.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := NewRequest("test-plugin", "test-profile")
+	result := &ExecutionResult{OutputBlobs: map[string][]byte{"../escape.txt": []byte("x")}}
+	if err := RecordRun(cap, &capsule.Run{ID: "run-1", Status: "completed"}, req, nil, result); err == nil {
+		t.Error("expected RecordRun to reject an unsafe output name")
+	}
+
+	// A manifest edited to hold an unsafe name is not replayed
+	result.OutputBlobs = map[string][]byte{"result.txt": []byte("x")}
+	run := &capsule.Run{ID: "run-2", Status: "completed"}
+	if err := RecordRun(cap, run, req, nil, result); err != nil {
+		t.Fatalf("RecordRun failed: %v", err)
+	}
+	run.Outputs.Files = map[string]string{"../escape.txt": run.Outputs.Files["result.txt"]}
+	_, err = NewReplayExecutor(cap).ExecuteRequest(context.Background(), req, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid output name") {
+		t.Errorf("expected replay to reject an unsafe output name, got %v", err)
+	}
+}
+
+func TestRecordRunResources(t *testing.T) {
+	cap, err := capsule.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := NewRequest("test-plugin", "test-profile")
+	result := &ExecutionResult{
+		TranscriptData: []byte(`{"event":"start"}` + "\n"),
+		Engine:         NewEngineSpec("sandbox"),
+		Usage:          &resource.Usage{UserCPUMs: 120, MaxRSSBytes: 8 << 20, ExitCode: 0},
+	}
+	run := &capsule.Run{ID: "run-1", Status: "completed"}
+	if err := RecordRun(cap, run, req, nil, result); err != nil {
+		t.Fatalf("RecordRun failed: %v", err)
+	}
+
+	if run.Resources == nil || run.Resources.UserCPUMs != 120 {
+		t.Errorf("resources not recorded: %+v", run.Resources)
+	}
+	// The measurements stay out of the tool transcript
+	if run.Outputs.TranscriptBlobSHA256 != cas.Hash(result.TranscriptData) {
+		t.Errorf("tool transcript changed")
+	}
+	data, err := cap.ReadBlob(run.Outputs.EngineTranscriptBlobSHA256)
+	if err != nil {
+		t.Fatalf("engine transcript not stored: %v", err)
+	}
+	events, err := ParseTranscriptData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != EventEngineResources || events[0].EngineID != "sandbox" ||
+		events[0].Attributes["max_rss_bytes"] != float64(8<<20) {
+		t.Errorf("unexpected engine transcript %+v", events)
+	}
+}
+
+func TestToolPluginInfo(t *testing.T) {
+	dir := t.TempDir()
+	toolDir := filepath.Join(dir, "tool", "libsword")
+	os.MkdirAll(toolDir, 0755)
+	os.WriteFile(filepath.Join(toolDir, "plugin.json"),
+		[]byte(`{"plugin_id": "tools.libsword", "version": "1.2.0", "kind": "tool", "entrypoint": "tool-libsword"}`), 0644)
+	os.WriteFile(filepath.Join(toolDir, "tool-libsword"), []byte("binary"), 0755)
+
+	info, err := ToolPluginInfo(dir, "libsword")
+	if err != nil {
+		t.Fatalf("ToolPluginInfo failed: %v", err)
+	}
+	// sha256("binary")
+	if info.PluginID != "tools.libsword" || info.PluginVersion != "1.2.0" ||
+		info.BinarySHA256 != "9a3a45d01531a20e89ac6ae10b0b0beb0492acd7216a368aa062d1a5fecaf9cd" ||
+		info.Signature != plugins.SignatureUnsigned {
+		t.Errorf("unexpected plugin info %+v", info)
+	}
+
+	if err := CheckToolPluginInfo(dir, info); err != nil {
+		t.Errorf("CheckToolPluginInfo failed for an unchanged plugin: %v", err)
+	}
+	os.WriteFile(filepath.Join(toolDir, "tool-libsword"), []byte("replaced"), 0755)
+	if err := CheckToolPluginInfo(dir, info); err == nil {
+		t.Error("expected an error for a plugin replaced during the run")
+	}
+
+	info, err = ToolPluginInfo(dir, "unknown")
+	if err != nil || info.PluginID != "unknown" || info.BinarySHA256 != "" {
+		t.Errorf("unexpected info for a tool without a plugin: %+v %v", info, err)
+	}
+	if err := CheckToolPluginInfo(dir, info); err != nil {
+		t.Errorf("CheckToolPluginInfo failed for a tool without a plugin: %v", err)
+	}
+}
+
+func TestRecordRunPluginBinary(t *testing.T) {
+	cap, err := capsule.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &ExecutionResult{
+		TranscriptData: []byte(`{"event":"start"}` + "\n"),
+		Engine:         NewEngineSpec("plugin"),
+		PluginSHA256:   "abc123",
+	}
+	run := &capsule.Run{ID: "run-1", Status: "completed", Plugin: &capsule.PluginInfo{PluginID: "tools.test", BinarySHA256: "stale"}}
+	if err := RecordRun(cap, run, NewRequest("test", "default"), nil, result); err != nil {
+		t.Fatalf("RecordRun failed: %v", err)
+	}
+	if run.Plugin.BinarySHA256 != "abc123" {
+		t.Errorf("BinarySHA256 = %q, want the hash of the plugin that ran", run.Plugin.BinarySHA256)
+	}
+}
@@ -3,10 +3,28 @@
 package runner
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+)
+
+// Executor runs tool requests in an execution engine. All engines run the
+// same tool commands and produce transcripts in the same format, so
+// transcripts from different engines can be compared; the engine spec
+// tells which engine produced a run.
+type Executor interface {
+	ExecuteRequest(ctx context.Context, req *Request, inputPaths []string) (*ExecutionResult, error)
+	EngineSpec() *EngineSpec
+}
+
+// Engine types.
+const (
+	EngineTypeNix     = "nixos-vm"
+	EngineTypeSandbox = "linux-sandbox"
 )
 
 // Request represents a tool run request sent to the VM.
@@ -47,10 +65,11 @@ func (r *Request) ToJSON() ([]byte, error) {
 
 // EngineSpec describes the execution environment specification.
 type EngineSpec struct {
-	EngineID string    `json:"engine_id"`
-	Type     string    `json:"type"`
-	Nix      NixConfig `json:"nix"`
-	Env      EnvConfig `json:"env"`
+	EngineID   string            `json:"engine_id"`
+	Type       string            `json:"type"`
+	Nix        NixConfig         `json:"nix"`
+	Env        EnvConfig         `json:"env"`
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 // NixConfig contains Nix-specific configuration.
@@ -64,7 +83,7 @@ type NixConfig struct {
 func NewEngineSpec(engineID string) *EngineSpec {
 	return &EngineSpec{
 		EngineID: engineID,
-		Type:     "nixos-vm",
+		Type:     EngineTypeNix,
 		Nix: NixConfig{
 			System:      "x86_64-linux",
 			Derivations: []string{},
@@ -77,6 +96,38 @@ func NewEngineSpec(engineID string) *EngineSpec {
 	}
 }
 
+// String returns the engine identity, "<type>/<engine id>".
+func (s *EngineSpec) String() string {
+	return s.Type + "/" + s.EngineID
+}
+
+// CapsuleEngine converts the spec to the engine record of a capsule run.
+func (s *EngineSpec) CapsuleEngine() *capsule.Engine {
+	engine := &capsule.Engine{
+		EngineID: s.EngineID,
+		Type:     s.Type,
+		Env: &capsule.EnvConfig{
+			TZ:    s.Env.TZ,
+			LCALL: s.Env.LCALL,
+			LANG:  s.Env.LANG,
+		},
+	}
+	if s.Type == EngineTypeNix {
+		engine.Nix = &capsule.NixConfig{
+			FlakeLockSHA256: s.Nix.FlakeLockSHA256,
+			System:          s.Nix.System,
+			Derivations:     s.Nix.Derivations,
+		}
+	}
+	if len(s.Attributes) > 0 {
+		engine.Attributes = make(capsule.Attributes, len(s.Attributes))
+		for k, v := range s.Attributes {
+			engine.Attributes[k] = v
+		}
+	}
+	return engine
+}
+
 // PrepareWorkDir prepares the work directory structure for VM execution.
 // Creates:
 //   - <workDir>/in/request.json
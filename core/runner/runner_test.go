@@ -221,6 +221,31 @@ func TestEngineSpec(t *testing.T) {
 	}
 }
 
+// TestEngineSpecCapsuleEngine tests that engines stay distinguishable in
+// capsule run records.
+func TestEngineSpecCapsuleEngine(t *testing.T) {
+	flakeDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(flakeDir, "flake.lock"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	nix := NewNixExecutor(flakeDir).EngineSpec().CapsuleEngine()
+	if nix.Type != EngineTypeNix || nix.Nix == nil || nix.Nix.FlakeLockSHA256 == "" {
+		t.Errorf("unexpected Nix engine %+v", nix)
+	}
+
+	sandbox := NewSandboxExecutor("/opt/tools").EngineSpec()
+	engine := sandbox.CapsuleEngine()
+	if engine.Type != EngineTypeSandbox || engine.Nix != nil || engine.Env.TZ != "UTC" {
+		t.Errorf("unexpected sandbox engine %+v", engine)
+	}
+	if engine.Attributes["tool_root"] != "/opt/tools" || engine.Attributes["limits"] == "" {
+		t.Errorf("unexpected sandbox attributes %v", engine.Attributes)
+	}
+	if sandbox.String() != "linux-sandbox/sandbox" {
+		t.Errorf("String() = %q", sandbox.String())
+	}
+}
+
 // helper function
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsAt(s, substr, 0))
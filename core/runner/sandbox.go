@@ -0,0 +1,202 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
+)
+
+// Paths inside the sandbox.
+const (
+	sandboxToolDir = "/tool"
+	sandboxWorkDir = "/work"
+	sandboxInDir   = "/work/in"
+	sandboxOutDir  = "/work/out"
+	sandboxHome    = "/tmp"
+)
+
+// DefaultSystemDirs are the host directories a sandbox mounts read-only
+// so tool commands find a shell and the basic utilities. Directories that
+// do not exist on the host are skipped.
+var DefaultSystemDirs = []string{"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64"}
+
+// Limits are resource limits applied to a tool. Zero values leave the
+// corresponding limit unchanged.
+type Limits = resource.Limits
+
+// DefaultLimits returns the limits tools run with by default.
+func DefaultLimits() Limits {
+	return resource.DefaultLimits()
+}
+
+// SandboxExecutor runs tools on the local machine in a Linux sandbox as an
+// alternative to the Nix engine. Each run gets new user, mount, PID, IPC,
+// UTS and network namespaces; the network namespace has no interfaces
+// besides loopback. The root file system holds only read-only bind mounts
+// of the tool root and the host system directories, the run's inputs at
+// /work/in (read-only) and its outputs at /work/out. Tools run with a fixed
+// environment and resource limits.
+//
+// The executor runs the same tool commands as NixExecutor, so transcripts
+// from both engines are comparable; EngineSpec tells their runs apart.
+type SandboxExecutor struct {
+	ToolRoot   string       // Directory with bin/ and lib/, mounted at /tool
+	Tool       *ToolArchive // Extracted for each run when ToolRoot is empty
+	SystemDirs []string     // Host directories mounted at the same path
+	Limits     Limits
+	Timeout    time.Duration
+}
+
+// NewSandboxExecutor creates a sandbox executor using the tools in
+// toolRoot. An empty toolRoot runs tools from the host system directories
+// only.
+func NewSandboxExecutor(toolRoot string) *SandboxExecutor {
+	return &SandboxExecutor{
+		ToolRoot:   toolRoot,
+		SystemDirs: DefaultSystemDirs,
+		Limits:     DefaultLimits(),
+		Timeout:    5 * time.Minute,
+	}
+}
+
+// NewSandboxExecutorForTool creates a sandbox executor whose tool root is
+// extracted from a tool archive.
+func NewSandboxExecutorForTool(tool *ToolArchive) *SandboxExecutor {
+	e := NewSandboxExecutor("")
+	e.Tool = tool
+	return e
+}
+
+// EngineSpec describes the sandbox engine. The attributes identify the
+// tool archive and limits, since the sandbox has no flake lock.
+func (e *SandboxExecutor) EngineSpec() *EngineSpec {
+	spec := NewEngineSpec("sandbox")
+	spec.Type = EngineTypeSandbox
+	spec.Nix = NixConfig{}
+	spec.Attributes = map[string]string{
+		"limits": e.Limits.String(),
+	}
+	if e.Tool != nil {
+		spec.Attributes["tool_id"] = e.Tool.ToolID
+		spec.Attributes["tool_version"] = e.Tool.Version
+		spec.Attributes["tool_platform"] = e.Tool.Platform
+	} else if e.ToolRoot != "" {
+		spec.Attributes["tool_root"] = e.ToolRoot
+	}
+	return spec
+}
+
+// ExecuteRequest runs a tool request in the sandbox and returns the result
+// with transcript.
+func (e *SandboxExecutor) ExecuteRequest(ctx context.Context, req *Request, inputPaths []string) (*ExecutionResult, error) {
+	// SECURITY: Validate plugin ID and profile to prevent shell injection
+	if err := validateIdentifier(req.PluginID, "plugin ID"); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	if req.Profile != "" {
+		if err := validateIdentifier(req.Profile, "profile"); err != nil {
+			return nil, fmt.Errorf("invalid request: %w", err)
+		}
+	}
+
+	workDir, inDir, outDir, err := stageRequest(req, inputPaths)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workDir)
+
+	cfg, err := e.config(req, workDir, inDir, outDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	startTime := time.Now()
+	exitCode, ps, err := runSandbox(ctxWithTimeout, cfg, &stdout, &stderr)
+	duration := time.Since(startTime)
+	if err != nil {
+		return nil, err
+	}
+
+	result := collectResult(outDir, exitCode, duration, stdout.Bytes(), stderr.Bytes())
+	result.Engine = e.EngineSpec()
+	result.Usage = resource.Measure(ps, duration)
+	result.Usage.Limits = &cfg.Limits
+	return result, nil
+}
+
+// sandboxConfig is passed to the sandbox init process.
+type sandboxConfig struct {
+	Root    string         `json:"root"`
+	Mounts  []sandboxMount `json:"mounts"`
+	Command []string       `json:"command"`
+	Env     []string       `json:"env"`
+	Dir     string         `json:"dir"`
+	Limits  Limits         `json:"limits"`
+}
+
+// sandboxMount is a bind mount of a host path into the sandbox.
+type sandboxMount struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// config builds the sandbox configuration of a run.
+func (e *SandboxExecutor) config(req *Request, workDir, inDir, outDir string) (*sandboxConfig, error) {
+	toolRoot := e.ToolRoot
+	if toolRoot == "" && e.Tool != nil {
+		toolRoot = filepath.Join(workDir, "tool")
+		if err := e.Tool.ExtractTo(toolRoot); err != nil {
+			return nil, fmt.Errorf("failed to extract tool %s: %w", e.Tool.ToolID, err)
+		}
+	}
+
+	cfg := &sandboxConfig{
+		Root:    filepath.Join(workDir, "root"),
+		Command: []string{"/bin/sh", "-c", toolCommand(req, sandboxInDir, sandboxOutDir)},
+		Env: []string{
+			"TZ=UTC",
+			"LC_ALL=C.UTF-8",
+			"LANG=C.UTF-8",
+			"HOME=" + sandboxHome,
+			"PATH=" + sandboxToolDir + "/bin:/usr/bin:/bin",
+		},
+		Dir:    sandboxWorkDir,
+		Limits: e.Limits,
+	}
+
+	for _, dir := range e.SystemDirs {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		cfg.Mounts = append(cfg.Mounts, sandboxMount{Source: dir, Target: dir, ReadOnly: true})
+	}
+	if toolRoot != "" {
+		abs, err := filepath.Abs(toolRoot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve tool root: %w", err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, fmt.Errorf("tool root not found: %w", err)
+		}
+		cfg.Mounts = append(cfg.Mounts, sandboxMount{Source: abs, Target: sandboxToolDir, ReadOnly: true})
+		cfg.Env = append(cfg.Env, "LD_LIBRARY_PATH="+sandboxToolDir+"/lib")
+	}
+	cfg.Mounts = append(cfg.Mounts,
+		sandboxMount{Source: inDir, Target: sandboxInDir, ReadOnly: true},
+		sandboxMount{Source: outDir, Target: sandboxOutDir},
+	)
+	return cfg, nil
+}
@@ -0,0 +1,268 @@
+//go:build linux
+
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// sandboxInitName is the argv[0] the sandbox init process is started
+// with. The sandbox re-executes the current binary under this name inside
+// the new namespaces; init below then sets up the file system and execs
+// the tool command, before any other code of the binary runs.
+const sandboxInitName = "juniper-sandbox-init"
+
+// sandboxInitExitCode is the exit status of a sandbox init that failed
+// before running the tool.
+const sandboxInitExitCode = 125
+
+// sandboxInitErrFD is the file descriptor the init process reports setup
+// errors on. It is closed on exec, so it reads empty once the tool runs.
+const sandboxInitErrFD = 3
+
+func init() {
+	if len(os.Args) == 2 && os.Args[0] == sandboxInitName {
+		sandboxInit(os.Args[1])
+	}
+}
+
+// runSandbox runs the command of cfg in new namespaces and returns its
+// exit code and process state. The init process execs the tool, so the
+// state's resource usage is the tool's.
+func runSandbox(ctx context.Context, cfg *sandboxConfig, stdout, stderr io.Writer) (int, *os.ProcessState, error) {
+	cfgData, err := json.Marshal(cfg)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to serialize sandbox config: %w", err)
+	}
+
+	errRead, errWrite, err := os.Pipe()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create pipe: %w", err)
+	}
+	defer errRead.Close()
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Args = []string{sandboxInitName, string(cfgData)}
+	cmd.Env = cfg.Env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.ExtraFiles = []*os.File{errWrite}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+
+	if err := cmd.Start(); err != nil {
+		errWrite.Close()
+		return 0, nil, fmt.Errorf("failed to start sandbox: %w", err)
+	}
+	errWrite.Close()
+	initErr, _ := io.ReadAll(errRead)
+	runErr := cmd.Wait()
+
+	if len(initErr) > 0 {
+		return 0, nil, fmt.Errorf("failed to set up sandbox: %s", bytes.TrimSpace(initErr))
+	}
+	if runErr != nil {
+		if exitErr, ok := runErr.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), cmd.ProcessState, nil
+		}
+		return 0, nil, fmt.Errorf("failed to run command: %w", runErr)
+	}
+	return 0, cmd.ProcessState, nil
+}
+
+// sandboxInit runs in the init process of a new sandbox. It never returns.
+func sandboxInit(cfgData string) {
+	errPipe := os.NewFile(sandboxInitErrFD, "sandbox-init-errors")
+	syscall.CloseOnExec(sandboxInitErrFD)
+	fail := func(err error) {
+		fmt.Fprintf(errPipe, "%v", err)
+		os.Exit(sandboxInitExitCode)
+	}
+
+	var cfg sandboxConfig
+	if err := json.Unmarshal([]byte(cfgData), &cfg); err != nil {
+		fail(fmt.Errorf("invalid sandbox config: %w", err))
+	}
+	if len(cfg.Command) == 0 {
+		fail(fmt.Errorf("no command"))
+	}
+	if err := setupSandbox(&cfg); err != nil {
+		fail(err)
+	}
+	err := syscall.Exec(cfg.Command[0], cfg.Command, cfg.Env)
+	fail(fmt.Errorf("exec %s: %w", cfg.Command[0], err))
+}
+
+// setupSandbox builds the root file system of the sandbox, pivots into it
+// and applies the resource limits.
+func setupSandbox(cfg *sandboxConfig) error {
+	root := cfg.Root
+
+	// Keep mount changes out of the host mount namespace
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount(root, root, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind root: %w", err)
+	}
+
+	for _, m := range cfg.Mounts {
+		if err := bindMount(m.Source, filepath.Join(root, m.Target), m.ReadOnly); err != nil {
+			return err
+		}
+	}
+
+	for _, dev := range []string{"/dev/null", "/dev/zero", "/dev/random", "/dev/urandom"} {
+		if err := bindMount(dev, filepath.Join(root, dev), false); err != nil {
+			return err
+		}
+	}
+
+	tmp := filepath.Join(root, "tmp")
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", tmp, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount /tmp: %w", err)
+	}
+
+	// /proc can only be mounted where the host does not mask parts of it,
+	// which container runtimes do; tools that need it fail visibly there
+	proc := filepath.Join(root, "proc")
+	if err := os.MkdirAll(proc, 0555); err != nil {
+		return err
+	}
+	syscall.Mount("proc", proc, "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, "")
+
+	oldRoot := filepath.Join(root, ".oldroot")
+	if err := os.MkdirAll(oldRoot, 0700); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("pivot root: %w", err)
+	}
+	if err := syscall.Chdir("/"); err != nil {
+		return err
+	}
+	if err := syscall.Unmount("/.oldroot", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmount old root: %w", err)
+	}
+	if err := os.Remove("/.oldroot"); err != nil {
+		return err
+	}
+	if err := remountReadOnly("/"); err != nil {
+		return err
+	}
+
+	if err := syscall.Sethostname([]byte("sandbox")); err != nil {
+		return fmt.Errorf("set hostname: %w", err)
+	}
+	if err := setLimits(cfg.Limits); err != nil {
+		return err
+	}
+	return syscall.Chdir(cfg.Dir)
+}
+
+// bindMount bind-mounts source at target, creating target first.
+func bindMount(source, target string, readOnly bool) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", source, err)
+	}
+	if readOnly {
+		return remountReadOnly(target)
+	}
+	return nil
+}
+
+// remountReadOnly makes a bind mount read-only. A user namespace may not
+// clear flags its mounts were locked with, so they are carried over.
+func remountReadOnly(target string) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(target, &st); err != nil {
+		return fmt.Errorf("statfs %s: %w", target, err)
+	}
+	const (
+		stNoSuid     = 0x2
+		stNoDev      = 0x4
+		stNoExec     = 0x8
+		stNoAtime    = 0x400
+		stNoDirAtime = 0x800
+		stRelAtime   = 0x1000
+	)
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	for flag, ms := range map[int64]uintptr{
+		stNoSuid:     syscall.MS_NOSUID,
+		stNoDev:      syscall.MS_NODEV,
+		stNoExec:     syscall.MS_NOEXEC,
+		stNoAtime:    syscall.MS_NOATIME,
+		stNoDirAtime: syscall.MS_NODIRATIME,
+		stRelAtime:   syscall.MS_RELATIME,
+	} {
+		if int64(st.Flags)&flag != 0 {
+			flags |= ms
+		}
+	}
+	if err := syscall.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", target, err)
+	}
+	return nil
+}
+
+// setLimits applies resource limits to the current process.
+func setLimits(l Limits) error {
+	limits := []struct {
+		resource int
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, l.CPUSeconds},
+		{syscall.RLIMIT_AS, l.AddressSpace},
+		{syscall.RLIMIT_FSIZE, l.FileSize},
+		{syscall.RLIMIT_NOFILE, l.OpenFiles},
+		{syscall.RLIMIT_CORE, 0},
+	}
+	for _, lim := range limits {
+		if lim.value == 0 && lim.resource != syscall.RLIMIT_CORE {
+			continue
+		}
+		rl := syscall.Rlimit{Cur: lim.value, Max: lim.value}
+		if err := syscall.Setrlimit(lim.resource, &rl); err != nil {
+			return fmt.Errorf("set rlimit %d: %w", lim.resource, err)
+		}
+	}
+	return nil
+}
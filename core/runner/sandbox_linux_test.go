@@ -0,0 +1,136 @@
+//go:build linux
+
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// requireSandbox skips tests on hosts that do not allow unprivileged user
+// namespaces.
+func requireSandbox(t *testing.T) {
+	t.Helper()
+	cfg := &sandboxConfig{
+		Root:    filepath.Join(t.TempDir(), "root"),
+		Command: []string{"/bin/sh", "-c", "true"},
+		Dir:     "/",
+	}
+	for _, dir := range DefaultSystemDirs {
+		if _, err := os.Stat(dir); err == nil {
+			cfg.Mounts = append(cfg.Mounts, sandboxMount{Source: dir, Target: dir, ReadOnly: true})
+		}
+	}
+	if _, _, err := runSandbox(context.Background(), cfg, nil, nil); err != nil {
+		t.Skipf("sandbox not available: %v", err)
+	}
+}
+
+func TestSandboxExecutorTranscript(t *testing.T) {
+	requireSandbox(t)
+
+	input := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewSandboxExecutor("")
+	req := NewRequest("test-plugin", "test-profile")
+	result, err := e.ExecuteRequest(context.Background(), req, []string{input})
+	if err != nil {
+		t.Fatalf("ExecuteRequest failed: %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code %d, stderr: %s", result.ExitCode, result.Stderr)
+	}
+
+	// The transcript is the one the Nix engine produces for the same request
+	want := `{"event":"start","plugin":"test-plugin","profile":"test-profile"}` + "\n" + `{"event":"end","exit_code":0}` + "\n"
+	if string(result.TranscriptData) != want {
+		t.Errorf("transcript = %q, want %q", result.TranscriptData, want)
+	}
+	if result.Engine == nil || result.Engine.Type != EngineTypeSandbox {
+		t.Errorf("unexpected engine %+v", result.Engine)
+	}
+	if result.Usage == nil || result.Usage.Limits == nil || *result.Usage.Limits != DefaultLimits() {
+		t.Errorf("unexpected usage %+v", result.Usage)
+	}
+}
+
+func TestSandboxExecutorIsolation(t *testing.T) {
+	requireSandbox(t)
+
+	toolRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(toolRoot, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tool := "#!/bin/sh\necho tool-ran\n"
+	if err := os.WriteFile(filepath.Join(toolRoot, "bin", "probe-tool"), []byte(tool), 0755); err != nil {
+		t.Fatal(err)
+	}
+	e := NewSandboxExecutor(toolRoot)
+	workDir := t.TempDir()
+	inDir := filepath.Join(workDir, "in")
+	outDir := filepath.Join(workDir, "out")
+	os.MkdirAll(inDir, 0755)
+	os.MkdirAll(outDir, 0755)
+	os.WriteFile(filepath.Join(inDir, "input.txt"), []byte("hello"), 0644)
+
+	cfg, err := e.config(NewRequest("probe", ""), workDir, inDir, outDir)
+	if err != nil {
+		t.Fatalf("config failed: %v", err)
+	}
+	cfg.Command = []string{"/bin/sh", "-c", strings.Join([]string{
+		"probe-tool",
+		"echo TZ=$TZ HOME=$HOME",
+		"uname -n",
+		"if touch /tool/x 2>/dev/null; then echo tool-writable; fi",
+		"if touch /work/in/x 2>/dev/null; then echo in-writable; fi",
+		"if touch /usr/x 2>/dev/null; then echo usr-writable; fi",
+		"touch /work/out/ok && echo out-writable",
+		"cat /work/in/input.txt; echo",
+		"ulimit -n",
+	}, "; ")}
+
+	var stdout, stderr strings.Builder
+	exitCode, _, err := runSandbox(context.Background(), cfg, &stdout, &stderr)
+	if err != nil {
+		t.Fatalf("runSandbox failed: %v", err)
+	}
+	if exitCode != 0 {
+		t.Fatalf("exit code %d, stderr: %s", exitCode, stderr.String())
+	}
+
+	out := stdout.String()
+	for _, want := range []string{"tool-ran", "TZ=UTC HOME=/tmp", "sandbox", "out-writable", "hello", "256"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"tool-writable", "in-writable", "usr-writable"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("sandbox should not allow %s:\n%s", unwanted, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "ok")); err != nil {
+		t.Errorf("output not written to the host out dir: %v", err)
+	}
+}
+
+func TestSandboxExecutorSetupError(t *testing.T) {
+	requireSandbox(t)
+
+	cfg := &sandboxConfig{
+		Root:    filepath.Join(t.TempDir(), "root"),
+		Mounts:  []sandboxMount{{Source: "/nonexistent-tool-root", Target: "/tool", ReadOnly: true}},
+		Command: []string{"/bin/sh", "-c", "true"},
+		Dir:     "/",
+	}
+	_, _, err := runSandbox(context.Background(), cfg, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "failed to set up sandbox") {
+		t.Errorf("expected a setup error, got %v", err)
+	}
+}
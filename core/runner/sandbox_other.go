@@ -0,0 +1,15 @@
+//go:build !linux
+
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runSandbox reports that sandboxes need Linux namespaces.
+func runSandbox(ctx context.Context, cfg *sandboxConfig, stdout, stderr io.Writer) (int, *os.ProcessState, error) {
+	return 0, nil, fmt.Errorf("sandbox executor requires Linux")
+}
@@ -78,7 +78,7 @@ func LoadToolArchive(capsulePath string) (*ToolArchive, error) {
 		return nil, fmt.Errorf("tool archive missing tool-manifest artifact")
 	}
 
-	manifestData, err := cap.GetStore().Retrieve(manifestArtifact.PrimaryBlobSHA256)
+	manifestData, err := cap.ReadBlob(manifestArtifact.PrimaryBlobSHA256)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve tool manifest: %w", err)
 	}
@@ -141,7 +141,7 @@ func (t *ToolArchive) extractArtifact(artifactID, destPath string, mode os.FileM
 		return fmt.Errorf("artifact not found: %s", artifactID)
 	}
 
-	data, err := t.capsule.GetStore().Retrieve(artifact.PrimaryBlobSHA256)
+	data, err := t.capsule.ReadBlob(artifact.PrimaryBlobSHA256)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve artifact: %w", err)
 	}
@@ -36,6 +36,7 @@ type TranscriptEvent struct {
 // Known event types
 const (
 	EventEngineInfo       = "ENGINE_INFO"
+	EventEngineResources  = "ENGINE_RESOURCES"
 	EventModuleDiscovered = "MODULE_DISCOVERED"
 	EventKeyEnum          = "KEY_ENUM"
 	EventEntryRendered    = "ENTRY_RENDERED"
@@ -0,0 +1,369 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// DefaultVolatileAttributes are attributes that change between otherwise
+// identical runs and are ignored when transcripts are compared.
+var DefaultVolatileAttributes = []string{"timestamp", "time", "duration_ms", "elapsed_ms"}
+
+// Kinds of event changes.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// DiffOptions configures a transcript comparison.
+type DiffOptions struct {
+	// IgnoreAttributes lists attribute names whose values are not
+	// compared.
+	IgnoreAttributes []string
+}
+
+// DefaultDiffOptions returns options ignoring DefaultVolatileAttributes.
+func DefaultDiffOptions() DiffOptions {
+	return DiffOptions{IgnoreAttributes: DefaultVolatileAttributes}
+}
+
+// TranscriptDiff is the semantic difference between two transcripts.
+// Events are aligned by type, module and key rather than by position, so
+// an inserted event shows as one addition instead of misaligning every
+// event after it.
+type TranscriptDiff struct {
+	Identical bool                    `json:"identical"`
+	EventsA   int                     `json:"events_a"`
+	EventsB   int                     `json:"events_b"`
+	Summary   DiffSummary             `json:"summary"`
+	ByType    map[string]*DiffSummary `json:"by_type,omitempty"`
+	Changes   []EventChange           `json:"changes,omitempty"`
+	Ignored   []string                `json:"ignored_attributes,omitempty"`
+}
+
+// DiffSummary counts aligned events by outcome.
+type DiffSummary struct {
+	Added     int `json:"added"`
+	Removed   int `json:"removed"`
+	Changed   int `json:"changed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// EventChange is an event added, removed or changed between transcripts.
+// Occurrence numbers events with the same type, module and key, from 1.
+type EventChange struct {
+	Kind       string           `json:"kind"`
+	Type       string           `json:"type"`
+	Module     string           `json:"module,omitempty"`
+	Key        string           `json:"key,omitempty"`
+	Occurrence int              `json:"occurrence,omitempty"`
+	A          *TranscriptEvent `json:"a,omitempty"`
+	B          *TranscriptEvent `json:"b,omitempty"`
+	Fields     []FieldChange    `json:"fields,omitempty"`
+}
+
+// FieldChange is a field of an aligned event whose value changed.
+// Attributes are named "attributes.<name>".
+type FieldChange struct {
+	Field string      `json:"field"`
+	A     interface{} `json:"a,omitempty"`
+	B     interface{} `json:"b,omitempty"`
+}
+
+// eventID is the alignment key of an event.
+type eventID struct {
+	typ, module, key string
+	n                int
+}
+
+// ParseTranscriptData parses transcript JSONL. Both transcript forms are
+// accepted: events with a "t" type, and the Nix engine's events with an
+// "event" type and a "ref" key, whose other fields become attributes.
+func ParseTranscriptData(data []byte) ([]TranscriptEvent, error) {
+	var events []TranscriptEvent
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var event TranscriptEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, fmt.Errorf("failed to parse line %d: %w", i+1, err)
+		}
+		if event.Type == "" {
+			var fields map[string]interface{}
+			if err := json.Unmarshal(line, &fields); err != nil {
+				return nil, fmt.Errorf("failed to parse line %d: %w", i+1, err)
+			}
+			normalizeNixEvent(&event, fields)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// normalizeNixEvent fills a TranscriptEvent from the fields of a Nix
+// engine event.
+func normalizeNixEvent(event *TranscriptEvent, fields map[string]interface{}) {
+	known := map[string]bool{
+		"t": true, "seq": true, "engine_id": true, "plugin_id": true, "plugin_version": true,
+		"module": true, "key": true, "profile": true, "sha256": true, "blake3": true,
+		"bytes": true, "message": true, "attributes": true,
+	}
+	for name, value := range fields {
+		s, isString := value.(string)
+		switch {
+		case name == "event" && isString:
+			event.Type = s
+		case name == "ref" && isString && event.Key == "":
+			event.Key = s
+		case name == "plugin" && isString && event.PluginID == "":
+			event.PluginID = s
+		case !known[name]:
+			if event.Attributes == nil {
+				event.Attributes = make(map[string]interface{})
+			}
+			event.Attributes[name] = value
+		}
+	}
+}
+
+// DiffTranscripts compares two transcripts event by event.
+func DiffTranscripts(a, b []byte, opts DiffOptions) (*TranscriptDiff, error) {
+	eventsA, err := ParseTranscriptData(a)
+	if err != nil {
+		return nil, fmt.Errorf("transcript A: %w", err)
+	}
+	eventsB, err := ParseTranscriptData(b)
+	if err != nil {
+		return nil, fmt.Errorf("transcript B: %w", err)
+	}
+	return DiffEvents(eventsA, eventsB, opts), nil
+}
+
+// DiffEvents compares two event lists. Changed and removed events are
+// listed in the order of A, followed by added events in the order of B.
+func DiffEvents(a, b []TranscriptEvent, opts DiffOptions) *TranscriptDiff {
+	ignore := make(map[string]bool, len(opts.IgnoreAttributes))
+	for _, name := range opts.IgnoreAttributes {
+		ignore[name] = true
+	}
+
+	d := &TranscriptDiff{
+		EventsA: len(a),
+		EventsB: len(b),
+		ByType:  make(map[string]*DiffSummary),
+		Ignored: opts.IgnoreAttributes,
+	}
+	count := func(typ string, f func(*DiffSummary)) {
+		f(&d.Summary)
+		s, ok := d.ByType[typ]
+		if !ok {
+			s = &DiffSummary{}
+			d.ByType[typ] = s
+		}
+		f(s)
+	}
+
+	idsA := alignIDs(a)
+	idsB := alignIDs(b)
+	indexB := make(map[eventID]int, len(b))
+	for i, id := range idsB {
+		indexB[id] = i
+	}
+	matched := make([]bool, len(b))
+
+	for i := range a {
+		id := idsA[i]
+		j, ok := indexB[id]
+		if !ok {
+			count(id.typ, func(s *DiffSummary) { s.Removed++ })
+			d.Changes = append(d.Changes, newEventChange(ChangeRemoved, id, &a[i], nil))
+			continue
+		}
+		matched[j] = true
+		fields := diffEventFields(&a[i], &b[j], ignore)
+		if len(fields) == 0 {
+			count(id.typ, func(s *DiffSummary) { s.Unchanged++ })
+			continue
+		}
+		count(id.typ, func(s *DiffSummary) { s.Changed++ })
+		change := newEventChange(ChangeChanged, id, &a[i], &b[j])
+		change.Fields = fields
+		d.Changes = append(d.Changes, change)
+	}
+	for j := range b {
+		if !matched[j] {
+			id := idsB[j]
+			count(id.typ, func(s *DiffSummary) { s.Added++ })
+			d.Changes = append(d.Changes, newEventChange(ChangeAdded, id, nil, &b[j]))
+		}
+	}
+
+	d.Identical = len(d.Changes) == 0
+	return d
+}
+
+// alignIDs returns the alignment key of each event.
+func alignIDs(events []TranscriptEvent) []eventID {
+	seen := make(map[eventID]int)
+	ids := make([]eventID, len(events))
+	for i, e := range events {
+		base := eventID{typ: e.Type, module: e.Module, key: e.Key}
+		seen[base]++
+		base.n = seen[base]
+		ids[i] = base
+	}
+	return ids
+}
+
+func newEventChange(kind string, id eventID, a, b *TranscriptEvent) EventChange {
+	return EventChange{
+		Kind:       kind,
+		Type:       id.typ,
+		Module:     id.module,
+		Key:        id.key,
+		Occurrence: id.n,
+		A:          a,
+		B:          b,
+	}
+}
+
+// diffEventFields lists the fields that differ between two aligned
+// events. The sequence number is positional and never compared.
+func diffEventFields(a, b *TranscriptEvent, ignore map[string]bool) []FieldChange {
+	var fields []FieldChange
+	field := func(name string, va, vb interface{}) {
+		if !reflect.DeepEqual(va, vb) {
+			fields = append(fields, FieldChange{Field: name, A: va, B: vb})
+		}
+	}
+	field("sha256", a.SHA256, b.SHA256)
+	field("bytes", a.Bytes, b.Bytes)
+	field("blake3", a.BLAKE3, b.BLAKE3)
+	field("engine_id", a.EngineID, b.EngineID)
+	field("plugin_id", a.PluginID, b.PluginID)
+	field("plugin_version", a.PluginVersion, b.PluginVersion)
+	field("profile", a.Profile, b.Profile)
+	field("message", a.Message, b.Message)
+
+	names := make(map[string]bool)
+	for name := range a.Attributes {
+		names[name] = true
+	}
+	for name := range b.Attributes {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		if !ignore[name] {
+			sorted = append(sorted, name)
+		}
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		field("attributes."+name, a.Attributes[name], b.Attributes[name])
+	}
+	return fields
+}
+
+// Keys returns the number of distinct keys added, removed and changed.
+func (d *TranscriptDiff) Keys() (added, removed, changed int) {
+	kinds := map[string]map[string]bool{
+		ChangeAdded:   {},
+		ChangeRemoved: {},
+		ChangeChanged: {},
+	}
+	for _, c := range d.Changes {
+		if c.Key != "" {
+			kinds[c.Kind][c.Module+"\x00"+c.Key] = true
+		}
+	}
+	return len(kinds[ChangeAdded]), len(kinds[ChangeRemoved]), len(kinds[ChangeChanged])
+}
+
+// WriteText writes a readable report of the diff, listing at most limit
+// changes; limit 0 lists all.
+func (d *TranscriptDiff) WriteText(w io.Writer, labelA, labelB string, limit int) {
+	fmt.Fprintf(w, "Event counts: %s=%d, %s=%d\n", labelA, d.EventsA, labelB, d.EventsB)
+	if d.Identical {
+		fmt.Fprintln(w, "No semantic differences.")
+		return
+	}
+	fmt.Fprintf(w, "Events: %d added, %d removed, %d changed, %d unchanged\n",
+		d.Summary.Added, d.Summary.Removed, d.Summary.Changed, d.Summary.Unchanged)
+	if added, removed, changed := d.Keys(); added+removed+changed > 0 {
+		fmt.Fprintf(w, "Keys: %d added, %d removed, %d changed\n", added, removed, changed)
+	}
+
+	types := make([]string, 0, len(d.ByType))
+	for typ, s := range d.ByType {
+		if s.Added+s.Removed+s.Changed > 0 {
+			types = append(types, typ)
+		}
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		s := d.ByType[typ]
+		fmt.Fprintf(w, "  %-20s +%d -%d ~%d\n", typ, s.Added, s.Removed, s.Changed)
+	}
+	fmt.Fprintln(w)
+
+	for i, c := range d.Changes {
+		if limit > 0 && i == limit {
+			fmt.Fprintf(w, "... %d more changes\n", len(d.Changes)-limit)
+			break
+		}
+		fmt.Fprintln(w, c.String())
+		for _, f := range c.Fields {
+			fmt.Fprintf(w, "    %s\n", f)
+		}
+	}
+}
+
+// String describes the change on one line.
+func (c EventChange) String() string {
+	sign := map[string]string{ChangeAdded: "+", ChangeRemoved: "-", ChangeChanged: "~"}[c.Kind]
+	s := sign + " " + c.Type
+	if c.Module != "" {
+		s += " " + c.Module
+	}
+	if c.Key != "" {
+		s += " " + c.Key
+	}
+	if c.Occurrence > 1 {
+		s += fmt.Sprintf(" #%d", c.Occurrence)
+	}
+	event := c.B
+	if event == nil {
+		event = c.A
+	}
+	if c.Kind != ChangeChanged && event.SHA256 != "" {
+		s += fmt.Sprintf(" (sha256 %s, %d bytes)", event.SHA256, event.Bytes)
+	}
+	return s
+}
+
+// String describes the field change as "field: a -> b".
+func (f FieldChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", f.Field, formatFieldValue(f.A), formatFieldValue(f.B))
+}
+
+func formatFieldValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	if s, ok := v.(string); ok {
+		if s == "" {
+			return "(none)"
+		}
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffTranscriptsAlignsInsertedEvent(t *testing.T) {
+	a := `{"t":"ENGINE_INFO","seq":0,"engine_id":"nix","attributes":{"timestamp":"1"}}
+{"t":"ENTRY_RENDERED","seq":1,"module":"KJV","key":"Gen.1.1","sha256":"aa","bytes":10}
+{"t":"ENTRY_RENDERED","seq":2,"module":"KJV","key":"Gen.1.2","sha256":"bb","bytes":20}
+{"t":"ENTRY_RENDERED","seq":3,"module":"KJV","key":"Gen.1.3","sha256":"cc","bytes":30}
+`
+	b := `{"t":"ENGINE_INFO","seq":0,"engine_id":"nix","attributes":{"timestamp":"2"}}
+{"t":"ENTRY_RENDERED","seq":1,"module":"KJV","key":"Gen.1.1","sha256":"aa","bytes":10}
+{"t":"ENTRY_RENDERED","seq":2,"module":"KJV","key":"Gen.1.1a","sha256":"ff","bytes":5}
+{"t":"ENTRY_RENDERED","seq":3,"module":"KJV","key":"Gen.1.2","sha256":"bb","bytes":20}
+{"t":"ENTRY_RENDERED","seq":4,"module":"KJV","key":"Gen.1.3","sha256":"dd","bytes":31}
+`
+	d, err := DiffTranscripts([]byte(a), []byte(b), DefaultDiffOptions())
+	if err != nil {
+		t.Fatalf("DiffTranscripts failed: %v", err)
+	}
+	if d.Identical {
+		t.Fatal("expected differences")
+	}
+	want := DiffSummary{Added: 1, Changed: 1, Unchanged: 3}
+	if d.Summary != want {
+		t.Errorf("summary = %+v, want %+v", d.Summary, want)
+	}
+	if len(d.Changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", d.Changes)
+	}
+	changed := d.Changes[0]
+	if changed.Kind != ChangeChanged || changed.Key != "Gen.1.3" || len(changed.Fields) != 2 {
+		t.Errorf("unexpected change %+v", changed)
+	}
+	if changed.Fields[0].Field != "sha256" || changed.Fields[1].Field != "bytes" {
+		t.Errorf("unexpected fields %+v", changed.Fields)
+	}
+	if added := d.Changes[1]; added.Kind != ChangeAdded || added.Key != "Gen.1.1a" {
+		t.Errorf("unexpected change %+v", added)
+	}
+	if added, removed, changed := d.Keys(); added != 1 || removed != 0 || changed != 1 {
+		t.Errorf("Keys() = %d, %d, %d", added, removed, changed)
+	}
+
+	var sb strings.Builder
+	d.WriteText(&sb, "A", "B", 0)
+	for _, line := range []string{"1 added, 0 removed, 1 changed", "~ ENTRY_RENDERED KJV Gen.1.3", "sha256: cc -> dd", "+ ENTRY_RENDERED KJV Gen.1.1a (sha256 ff, 5 bytes)"} {
+		if !strings.Contains(sb.String(), line) {
+			t.Errorf("report missing %q:\n%s", line, sb.String())
+		}
+	}
+}
+
+func TestDiffTranscriptsIgnoresAttributes(t *testing.T) {
+	a := `{"event":"start","timestamp":"2024-01-01T00:00:00Z","plugin":"p"}
+{"event":"end","exit_code":0}`
+	b := `{"event":"start","timestamp":"2024-01-01T00:00:09Z","plugin":"p"}
+{"event":"end","exit_code":1}`
+
+	d, err := DiffTranscripts([]byte(a), []byte(b), DefaultDiffOptions())
+	if err != nil {
+		t.Fatalf("DiffTranscripts failed: %v", err)
+	}
+	if len(d.Changes) != 1 || d.Changes[0].Type != "end" || d.Changes[0].Fields[0].Field != "attributes.exit_code" {
+		t.Fatalf("unexpected changes %+v", d.Changes)
+	}
+
+	opts := DefaultDiffOptions()
+	opts.IgnoreAttributes = append(opts.IgnoreAttributes, "exit_code")
+	d, _ = DiffTranscripts([]byte(a), []byte(b), opts)
+	if !d.Identical {
+		t.Errorf("expected no differences with exit_code ignored, got %+v", d.Changes)
+	}
+
+	if _, err := DiffTranscripts([]byte("{bad"), []byte(b), opts); err == nil {
+		t.Error("expected error for an invalid transcript")
+	}
+}
+
+func TestDiffTranscriptsRepeatedEvents(t *testing.T) {
+	a := `{"t":"WARN","message":"one"}
+{"t":"WARN","message":"two"}`
+	b := `{"t":"WARN","message":"one"}`
+	d, _ := DiffTranscripts([]byte(a), []byte(b), DefaultDiffOptions())
+	if len(d.Changes) != 1 || d.Changes[0].Kind != ChangeRemoved || d.Changes[0].Occurrence != 2 {
+		t.Errorf("unexpected changes %+v", d.Changes)
+	}
+}
@@ -0,0 +1,410 @@
+package selfcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/xml"
+)
+
+// Text normalizations for TEXT_EQUAL checks.
+const (
+	NormalizeWhitespace  = "whitespace"  // collapse runs of whitespace and trim
+	NormalizeNFC         = "nfc"         // Unicode NFC composition
+	NormalizePunctuation = "punctuation" // remove punctuation
+	NormalizeCase        = "case"        // fold to lower case
+	NormalizeMarkup      = "markup"      // strip XML/HTML tags and entities
+)
+
+// maxEvidenceItems caps the lists of items reported as check evidence.
+const maxEvidenceItems = 100
+
+// TextEqualDef defines a normalized text equality check. A and B are step
+// output keys or artifact IDs.
+type TextEqualDef struct {
+	A         string   `json:"a"`
+	B         string   `json:"b"`
+	Normalize []string `json:"normalize,omitempty"`
+}
+
+// TextEqualEvidence is the evidence of a TEXT_EQUAL check.
+type TextEqualEvidence struct {
+	Normalize  []string        `json:"normalize,omitempty"`
+	LengthA    int             `json:"length_a"`
+	LengthB    int             `json:"length_b"`
+	Difference *TextDifference `json:"first_difference,omitempty"`
+}
+
+// TextDifference locates the first difference between two texts, in
+// runes of the normalized texts.
+type TextDifference struct {
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// XPathAssertDef defines an XPath assertion on an XML output. Without
+// expectations, node sets must be non-empty, booleans true, numbers
+// non-zero and strings non-empty.
+type XPathAssertDef struct {
+	Input    string  `json:"input"`
+	XPath    string  `json:"xpath"`
+	Count    *int    `json:"count,omitempty"`
+	MinCount *int    `json:"min_count,omitempty"`
+	MaxCount *int    `json:"max_count,omitempty"`
+	Equals   *string `json:"equals,omitempty"`
+}
+
+// XPathEvidence is the evidence of an XPATH_ASSERT check.
+type XPathEvidence struct {
+	XPath      string   `json:"xpath"`
+	ResultType string   `json:"result_type"`
+	Value      string   `json:"value,omitempty"`
+	Count      *int     `json:"count,omitempty"`
+	Samples    []string `json:"samples,omitempty"`
+	Failures   []string `json:"failures,omitempty"`
+}
+
+// LossBudgetDef defines a loss budget check on a step output. The loss
+// reports of the plugins that produced the output are merged; without
+// any, a loss_class recorded in the output itself is used.
+type LossBudgetDef struct {
+	Input  string     `json:"input"`
+	Budget LossBudget `json:"budget"`
+
+	// StepOnly checks only the loss reported by the step that produced
+	// Input rather than its whole derivation chain, attributing the loss
+	// to that step's plugin.
+	StepOnly bool `json:"step_only,omitempty"`
+}
+
+// LossBudgetEvidence is the evidence of a LOSS_BUDGET check.
+type LossBudgetEvidence struct {
+	// Source is where the loss report came from: "plugin", "output" or
+	// "none".
+	Source string `json:"source"`
+	*LossBudgetResult
+}
+
+// readInput returns the contents of a step output or capsule artifact.
+func (e *Executor) readInput(key string) ([]byte, error) {
+	if path, ok := e.output(key); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read output %q: %w", key, err)
+		}
+		return data, nil
+	}
+	if artifact, ok := e.capsule.Manifest.Artifacts[key]; ok {
+		e.capMu.RLock()
+		defer e.capMu.RUnlock()
+		data, err := e.capsule.ReadBlob(artifact.PrimaryBlobSHA256)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve artifact %q: %w", key, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("artifact/output not found: %s", key)
+}
+
+// executeTextEqualCheck executes a normalized text equality check.
+func (e *Executor) executeTextEqualCheck(check *PlanCheck) (*CheckResult, error) {
+	def := check.TextEqual
+	if def == nil {
+		return nil, fmt.Errorf("missing %s definition", check.Type)
+	}
+
+	dataA, err := e.readInput(def.A)
+	if err != nil {
+		return nil, err
+	}
+	dataB, err := e.readInput(def.B)
+	if err != nil {
+		return nil, err
+	}
+	textA, err := normalizeText(string(dataA), def.Normalize)
+	if err != nil {
+		return nil, err
+	}
+	textB, err := normalizeText(string(dataB), def.Normalize)
+	if err != nil {
+		return nil, err
+	}
+
+	evidence := &TextEqualEvidence{
+		Normalize:  def.Normalize,
+		LengthA:    len([]rune(textA)),
+		LengthB:    len([]rune(textB)),
+		Difference: firstDifference(textA, textB),
+	}
+	return &CheckResult{
+		CheckType: CheckTextEqual,
+		Label:     check.Label,
+		Pass:      evidence.Difference == nil,
+		Expected:  &HashInfo{SHA256: cas.Hash([]byte(textA))},
+		Actual:    &HashInfo{SHA256: cas.Hash([]byte(textB))},
+		Details:   evidence,
+	}, nil
+}
+
+// markupPattern matches XML and HTML tags.
+var markupPattern = regexp.MustCompile(`<[^>]*>`)
+
+// normalizeText applies normalizations to a text. Markup is stripped
+// first and whitespace collapsed last, whatever their order in opts.
+func normalizeText(text string, opts []string) (string, error) {
+	enabled := make(map[string]bool)
+	for _, opt := range opts {
+		switch opt {
+		case NormalizeWhitespace, NormalizeNFC, NormalizePunctuation, NormalizeCase, NormalizeMarkup:
+			enabled[opt] = true
+		default:
+			return "", fmt.Errorf("unknown text normalization: %s", opt)
+		}
+	}
+
+	if enabled[NormalizeMarkup] {
+		text = html.UnescapeString(markupPattern.ReplaceAllString(text, " "))
+	}
+	if enabled[NormalizeNFC] {
+		text = norm.NFC.String(text)
+	}
+	if enabled[NormalizeCase] {
+		text = strings.ToLower(text)
+	}
+	if enabled[NormalizePunctuation] {
+		text = strings.Map(func(r rune) rune {
+			if unicode.IsPunct(r) {
+				return -1
+			}
+			return r
+		}, text)
+	}
+	if enabled[NormalizeWhitespace] {
+		text = strings.Join(strings.Fields(text), " ")
+	}
+	return text, nil
+}
+
+// firstDifference returns the first difference between two texts, or nil
+// if they are equal.
+func firstDifference(a, b string) *TextDifference {
+	if a == b {
+		return nil
+	}
+	ra, rb := []rune(a), []rune(b)
+	offset, line, column := 0, 1, 1
+	for offset < len(ra) && offset < len(rb) && ra[offset] == rb[offset] {
+		if ra[offset] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+		offset++
+	}
+	return &TextDifference{
+		Offset:   offset,
+		Line:     line,
+		Column:   column,
+		Expected: excerpt(ra, offset),
+		Actual:   excerpt(rb, offset),
+	}
+}
+
+// excerpt returns the text around offset.
+func excerpt(r []rune, offset int) string {
+	const context = 20
+	start := max(offset-context, 0)
+	end := min(offset+context, len(r))
+	if start > end {
+		return ""
+	}
+	return string(r[start:end])
+}
+
+// executeXPathAssertCheck executes an XPath assertion on an XML output.
+func (e *Executor) executeXPathAssertCheck(check *PlanCheck) (*CheckResult, error) {
+	def := check.XPathAssert
+	if def == nil {
+		return nil, fmt.Errorf("missing %s definition", check.Type)
+	}
+
+	data, err := e.readInput(def.Input)
+	if err != nil {
+		return nil, err
+	}
+	evidence := &XPathEvidence{XPath: def.XPath}
+	result := &CheckResult{CheckType: CheckXPathAssert, Label: check.Label, Details: evidence}
+
+	doc, err := xml.Parse(data)
+	if err != nil {
+		evidence.Failures = append(evidence.Failures, err.Error())
+		return result, nil
+	}
+	value, err := doc.Evaluate(def.XPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fail := func(format string, args ...interface{}) {
+		evidence.Failures = append(evidence.Failures, fmt.Sprintf(format, args...))
+	}
+	defaultAssertion := def.Count == nil && def.MinCount == nil && def.MaxCount == nil && def.Equals == nil
+
+	switch v := value.(type) {
+	case []*xml.Node:
+		count := len(v)
+		evidence.ResultType = "nodeset"
+		evidence.Count = &count
+		for _, n := range v[:min(len(v), 5)] {
+			evidence.Samples = append(evidence.Samples, n.Text())
+		}
+		if len(v) > 0 {
+			evidence.Value = v[0].Text()
+		}
+		checkCount(def, float64(count), fail)
+		if def.Equals != nil && evidence.Value != *def.Equals {
+			fail("first node is %q, expected %q", evidence.Value, *def.Equals)
+		}
+		if defaultAssertion && count == 0 {
+			fail("no nodes matched")
+		}
+	case bool:
+		evidence.ResultType = "boolean"
+		evidence.Value = strconv.FormatBool(v)
+		if def.Equals != nil && evidence.Value != *def.Equals {
+			fail("value is %s, expected %s", evidence.Value, *def.Equals)
+		}
+		if defaultAssertion && !v {
+			fail("expression is false")
+		}
+	case float64:
+		evidence.ResultType = "number"
+		evidence.Value = strconv.FormatFloat(v, 'f', -1, 64)
+		checkCount(def, v, fail)
+		if def.Equals != nil && evidence.Value != *def.Equals {
+			fail("value is %s, expected %s", evidence.Value, *def.Equals)
+		}
+		if defaultAssertion && v == 0 {
+			fail("value is 0")
+		}
+	case string:
+		evidence.ResultType = "string"
+		evidence.Value = v
+		if def.Equals != nil && v != *def.Equals {
+			fail("value is %q, expected %q", v, *def.Equals)
+		}
+		if defaultAssertion && v == "" {
+			fail("value is empty")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported XPath result type %T", value)
+	}
+
+	result.Pass = len(evidence.Failures) == 0
+	return result, nil
+}
+
+// checkCount applies the count assertions of an XPath check to n.
+func checkCount(def *XPathAssertDef, n float64, fail func(string, ...interface{})) {
+	if def.Count != nil && n != float64(*def.Count) {
+		fail("count is %v, expected %d", n, *def.Count)
+	}
+	if def.MinCount != nil && n < float64(*def.MinCount) {
+		fail("count is %v, expected at least %d", n, *def.MinCount)
+	}
+	if def.MaxCount != nil && n > float64(*def.MaxCount) {
+		fail("count is %v, expected at most %d", n, *def.MaxCount)
+	}
+}
+
+// executeLossBudgetCheck checks the loss of an output against a budget.
+func (e *Executor) executeLossBudgetCheck(check *PlanCheck) (*CheckResult, error) {
+	def := check.LossBudget
+	if def == nil {
+		return nil, fmt.Errorf("missing %s definition", check.Type)
+	}
+	if _, ok := e.output(def.Input); !ok {
+		return nil, fmt.Errorf("output not found: %s", def.Input)
+	}
+
+	report, source := e.lossReport(def.Input)
+	if def.StepOnly {
+		report, source = e.stepLossReport(def.Input)
+	}
+	budget := def.Budget.Check(report)
+	return &CheckResult{
+		CheckType: CheckLossBudget,
+		Label:     check.Label,
+		Pass:      budget.WithinBudget,
+		Details:   &LossBudgetEvidence{Source: source, LossBudgetResult: budget},
+	}, nil
+}
+
+// lossReport returns the loss of a step output and its source. Plugin
+// reports along the derivation chain are merged; otherwise a loss class
+// recorded in the output, such as the loss_class of an IR, is used.
+func (e *Executor) lossReport(key string) (*ir.LossReport, string) {
+	e.mu.Lock()
+	chain := e.losses[key]
+	e.mu.Unlock()
+	if len(chain) > 0 {
+		return mergeLossReports(chain), "plugin"
+	}
+
+	path, ok := e.output(key)
+	if !ok {
+		return nil, "none"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "none"
+	}
+	var report ir.LossReport
+	if err := json.Unmarshal(data, &report); err != nil || report.LossClass == "" {
+		return nil, "none"
+	}
+	return &report, "output"
+}
+
+// stepLossReport returns the loss reported by the plugin of the step that
+// produced an output, if any.
+func (e *Executor) stepLossReport(key string) (*ir.LossReport, string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if report, ok := e.stepLoss[key]; ok {
+		return report, "plugin"
+	}
+	return nil, "none"
+}
+
+// mergeLossReports combines the loss reports of a derivation chain. The
+// merged class is the worst in the chain.
+func mergeLossReports(chain []*ir.LossReport) *ir.LossReport {
+	merged := &ir.LossReport{
+		SourceFormat: chain[0].SourceFormat,
+		TargetFormat: chain[len(chain)-1].TargetFormat,
+		LossClass:    ir.LossL0,
+	}
+	for _, r := range chain {
+		if r.LossClass.Level() > merged.LossClass.Level() {
+			merged.LossClass = r.LossClass
+		}
+		merged.LostElements = append(merged.LostElements, r.LostElements...)
+		merged.Warnings = append(merged.Warnings, r.Warnings...)
+	}
+	return merged
+}
@@ -0,0 +1,324 @@
+package selfcheck
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+	"github.com/FocuswithJustin/JuniperBible/core/epub"
+)
+
+// newChecksTestCapsule creates a capsule holding the given files and
+// returns it with the artifact ID of each file name.
+func newChecksTestCapsule(t *testing.T, files map[string][]byte) (*capsule.Capsule, map[string]string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	cap, err := capsule.New(filepath.Join(tempDir, "capsule"))
+	if err != nil {
+		t.Fatalf("failed to create capsule: %v", err)
+	}
+	ids := make(map[string]string)
+	for name, data := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		artifact, err := cap.IngestFile(path)
+		if err != nil {
+			t.Fatalf("failed to ingest %s: %v", name, err)
+		}
+		ids[name] = artifact.ID
+	}
+	return cap, ids
+}
+
+// runCheck executes a plan holding a single check and returns its result.
+func runCheck(t *testing.T, cap *capsule.Capsule, steps []PlanStep, check PlanCheck) CheckResult {
+	t.Helper()
+	report, err := NewExecutor(cap).Execute(&Plan{ID: "checks", Steps: steps, Checks: []PlanCheck{check}})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	return report.Results[0]
+}
+
+func TestTextEqualCheck(t *testing.T) {
+	cap, ids := newChecksTestCapsule(t, map[string][]byte{
+		"a.txt": []byte("In the beginning,  God created\nthe heaven."),
+		"b.txt": []byte("<p>in the BEGINNING God created the heaven</p>"),
+		"c.txt": []byte("Café"),
+		"d.txt": []byte("Café"),
+	})
+
+	tests := []struct {
+		name      string
+		a, b      string
+		normalize []string
+		pass      bool
+	}{
+		{"raw texts differ", "a.txt", "b.txt", nil, false},
+		{"normalized texts equal", "a.txt", "b.txt", []string{"markup", "case", "punctuation", "whitespace"}, true},
+		{"decomposed differs without NFC", "c.txt", "d.txt", nil, false},
+		{"NFC composes", "c.txt", "d.txt", []string{"nfc"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCheck(t, cap, nil, PlanCheck{
+				Type:      CheckTextEqual,
+				TextEqual: &TextEqualDef{A: ids[tt.a], B: ids[tt.b], Normalize: tt.normalize},
+			})
+			if result.Pass != tt.pass {
+				t.Errorf("pass = %v, want %v (details %+v)", result.Pass, tt.pass, result.Details)
+			}
+			evidence := result.Details.(*TextEqualEvidence)
+			if !tt.pass && evidence.Difference == nil {
+				t.Error("expected the first difference in the evidence")
+			}
+		})
+	}
+
+	t.Run("difference location", func(t *testing.T) {
+		diff := firstDifference("line one\nline two", "line one\nline 2")
+		if diff == nil || diff.Line != 2 || diff.Column != 6 || diff.Offset != 14 {
+			t.Errorf("unexpected difference %+v", diff)
+		}
+	})
+
+	t.Run("unknown normalization", func(t *testing.T) {
+		_, err := NewExecutor(cap).Execute(&Plan{Checks: []PlanCheck{{
+			Type:      CheckTextEqual,
+			TextEqual: &TextEqualDef{A: ids["a.txt"], B: ids["b.txt"], Normalize: []string{"soundex"}},
+		}}})
+		if err == nil {
+			t.Error("expected error for unknown normalization")
+		}
+	})
+}
+
+const testOSIS = `<?xml version="1.0" encoding="UTF-8"?>
+<osis xmlns="http://www.bibletechnologies.net/2003/OSIS/namespace">
+  <osisText osisIDWork="KJV">
+    <header><work osisWork="KJV"><title>KJV</title></work></header>
+    <div type="book" osisID="Obad">
+      <chapter osisID="Obad.1">
+        <verse osisID="Obad.1.1">The vision of Obadiah.</verse>
+        <verse sID="Obad.1.2" osisID="Obad.1.2-Obad.1.20"/>Behold...<verse eID="Obad.1.2"/>
+        <verse osisID="Obad.1.22">Extra.</verse>
+      </chapter>
+    </div>
+  </osisText>
+</osis>`
+
+const testUSX = `<?xml version="1.0" encoding="UTF-8"?>
+<usx version="3.0">
+  <book code="JUD" style="id">Jude</book>
+  <chapter number="1" style="c" sid="JUD 1"/>
+  <para style="p">
+    <verse number="1" style="v" sid="JUD 1:1"/>Jude, the servant<verse eid="JUD 1:1"/>
+    <verse number="2-25" style="v" sid="JUD 1:2-25"/>Mercy<verse eid="JUD 1:2-25"/>
+  </para>
+  <chapter eid="JUD 1"/>
+</usx>`
+
+func TestVerseCoverageCheck(t *testing.T) {
+	cap, ids := newChecksTestCapsule(t, map[string][]byte{
+		"obad.osis.xml": []byte(testOSIS),
+		"jude.usx":      []byte(testUSX),
+	})
+
+	t.Run("OSIS missing and extra verses", func(t *testing.T) {
+		result := runCheck(t, cap, nil, PlanCheck{
+			Type:          CheckVerseCoverage,
+			VerseCoverage: &VerseCoverageDef{Input: ids["obad.osis.xml"]},
+		})
+		if result.Pass {
+			t.Error("expected coverage check to fail")
+		}
+		evidence := result.Details.(*VerseCoverageEvidence)
+		if evidence.Expected != 21 || evidence.MissingCount != 1 || evidence.ExtraCount != 1 {
+			t.Errorf("unexpected evidence %+v", evidence)
+		}
+		if len(evidence.Missing) != 1 || evidence.Missing[0] != "Obad.1.21" {
+			t.Errorf("missing = %v, want [Obad.1.21]", evidence.Missing)
+		}
+		if len(evidence.Extra) != 1 || evidence.Extra[0] != "Obad.1.22" {
+			t.Errorf("extra = %v, want [Obad.1.22]", evidence.Extra)
+		}
+	})
+
+	t.Run("USX complete book", func(t *testing.T) {
+		result := runCheck(t, cap, nil, PlanCheck{
+			Type:          CheckVerseCoverage,
+			VerseCoverage: &VerseCoverageDef{Input: ids["jude.usx"]},
+		})
+		if !result.Pass {
+			t.Errorf("expected coverage check to pass: %+v", result.Details)
+		}
+	})
+
+	t.Run("required books", func(t *testing.T) {
+		result := runCheck(t, cap, nil, PlanCheck{
+			Type:          CheckVerseCoverage,
+			VerseCoverage: &VerseCoverageDef{Input: ids["jude.usx"], Books: []string{"3John", "Jude"}},
+		})
+		evidence := result.Details.(*VerseCoverageEvidence)
+		if result.Pass || evidence.MissingCount != 14 || evidence.Missing[0] != "3John.1.1-14" {
+			t.Errorf("expected 3 John to be missing: %+v", evidence)
+		}
+	})
+
+	t.Run("unknown versification", func(t *testing.T) {
+		result := runCheck(t, cap, nil, PlanCheck{
+			Type:          CheckVerseCoverage,
+			VerseCoverage: &VerseCoverageDef{Input: ids["jude.usx"], Versification: "LXX"},
+		})
+		if result.Pass || result.Details.(*VerseCoverageEvidence).Error == "" {
+			t.Error("expected failure for versification without a verse table")
+		}
+	})
+}
+
+func TestXPathAssertCheck(t *testing.T) {
+	cap, ids := newChecksTestCapsule(t, map[string][]byte{"obad.osis.xml": []byte(testOSIS)})
+	intPtr := func(n int) *int { return &n }
+	strPtr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name string
+		def  XPathAssertDef
+		pass bool
+	}{
+		{"nodes exist", XPathAssertDef{XPath: "//*[local-name()='verse']"}, true},
+		{"no nodes", XPathAssertDef{XPath: "//*[local-name()='note']"}, false},
+		{"node count", XPathAssertDef{XPath: "//*[local-name()='verse'][@osisID]", Count: intPtr(3)}, true},
+		{"min count", XPathAssertDef{XPath: "//*[local-name()='verse']", MinCount: intPtr(10)}, false},
+		{"count expression", XPathAssertDef{XPath: "count(//*[local-name()='chapter'])", Equals: strPtr("1")}, true},
+		{"boolean expression", XPathAssertDef{XPath: "boolean(//*[local-name()='header'])"}, true},
+		{"first node text", XPathAssertDef{XPath: "//*[local-name()='title']", Equals: strPtr("KJV")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := tt.def
+			def.Input = ids["obad.osis.xml"]
+			result := runCheck(t, cap, nil, PlanCheck{Type: CheckXPathAssert, XPathAssert: &def})
+			if result.Pass != tt.pass {
+				t.Errorf("pass = %v, want %v (evidence %+v)", result.Pass, tt.pass, result.Details)
+			}
+			evidence := result.Details.(*XPathEvidence)
+			if !tt.pass && len(evidence.Failures) == 0 {
+				t.Error("expected failures in the evidence")
+			}
+		})
+	}
+}
+
+// buildTestEPUB returns a minimal EPUB built by core/epub.
+func buildTestEPUB(t *testing.T) []byte {
+	t.Helper()
+	book := epub.New()
+	book.SetTitle("Test")
+	book.SetLanguage("en")
+	book.SetIdentifier("urn:uuid:test")
+	book.AddChapter("Genesis", "<p>In the beginning</p>")
+	data, err := book.Build()
+	if err != nil {
+		t.Fatalf("failed to build EPUB: %v", err)
+	}
+	return data
+}
+
+func TestSchemaValidCheck(t *testing.T) {
+	var broken bytes.Buffer
+	zw := zip.NewWriter(&broken)
+	w, _ := zw.Create("META-INF/container.xml")
+	w.Write([]byte(`<container><rootfiles/></container>`))
+	zw.Close()
+
+	cap, ids := newChecksTestCapsule(t, map[string][]byte{
+		"obad.osis.xml": []byte(testOSIS),
+		"bad.osis.xml":  []byte(`<osis><osisText><verse sID="Gen.1.1" osisID="Gen.1.x"/></osisText></osis>`),
+		"jude.usx":      []byte(testUSX),
+		"bad.usx":       []byte(`<usx><para>no book</para></usx>`),
+		"book.epub":     buildTestEPUB(t),
+		"broken.epub":   broken.Bytes(),
+	})
+
+	tests := []struct {
+		input  string
+		schema string
+		pass   bool
+	}{
+		{"obad.osis.xml", "", true},
+		{"bad.osis.xml", SchemaOSIS, false},
+		{"jude.usx", "", true},
+		{"bad.usx", "", false},
+		{"book.epub", "", true},
+		{"broken.epub", SchemaEPUB, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result := runCheck(t, cap, nil, PlanCheck{
+				Type:        CheckSchemaValid,
+				SchemaValid: &SchemaValidDef{Input: ids[tt.input], Schema: tt.schema},
+			})
+			evidence := result.Details.(*SchemaEvidence)
+			if result.Pass != tt.pass {
+				t.Errorf("pass = %v, want %v (errors %v)", result.Pass, tt.pass, evidence.Errors)
+			}
+			if !tt.pass && evidence.ErrorCount == 0 {
+				t.Error("expected errors in the evidence")
+			}
+		})
+	}
+
+	t.Run("broken OSIS reports each violation", func(t *testing.T) {
+		result := runCheck(t, cap, nil, PlanCheck{
+			Type:        CheckSchemaValid,
+			SchemaValid: &SchemaValidDef{Input: ids["bad.osis.xml"]},
+		})
+		errors := strings.Join(result.Details.(*SchemaEvidence).Errors, "\n")
+		for _, want := range []string{"namespace", "osisIDWork", "invalid osisID", "no matching eID"} {
+			if !strings.Contains(errors, want) {
+				t.Errorf("expected an error mentioning %q, got:\n%s", want, errors)
+			}
+		}
+	})
+}
+
+func TestLossBudgetPlanCheck(t *testing.T) {
+	cap, ids := newChecksTestCapsule(t, map[string][]byte{
+		"lossy.ir.json": []byte(`{"id": "KJV", "loss_class": "L2"}`),
+	})
+	steps := []PlanStep{
+		{Type: StepExport, Export: &ExportStep{Mode: "IDENTITY", ArtifactID: ids["lossy.ir.json"], OutputKey: "lossy"}},
+		{Type: StepExtractIR, ExtractIR: &ExtractIRStep{SourceArtifactID: ids["lossy.ir.json"], OutputKey: "placeholder"}},
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		budget LossBudget
+		pass   bool
+		source string
+	}{
+		{"within budget", "lossy", LossBudget{MaxLossClass: "L2"}, true, "output"},
+		{"over budget", "lossy", LossBudget{MaxLossClass: "L1"}, false, "output"},
+		{"no loss report", "placeholder", LossBudget{MaxLossClass: "L0"}, true, "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := runCheck(t, cap, steps, PlanCheck{
+				Type:       CheckLossBudget,
+				LossBudget: &LossBudgetDef{Input: tt.input, Budget: tt.budget},
+			})
+			evidence := result.Details.(*LossBudgetEvidence)
+			if result.Pass != tt.pass || evidence.Source != tt.source {
+				t.Errorf("pass = %v source = %q, want %v %q (evidence %+v)", result.Pass, evidence.Source, tt.pass, tt.source, evidence.LossBudgetResult)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package selfcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/xml"
+)
+
+// VerseCoverageDef defines a verse coverage check of an IR, OSIS or USX
+// output against a versification.
+type VerseCoverageDef struct {
+	Input string `json:"input"`
+
+	// Versification defaults to the versification of an IR input, or KJV.
+	Versification string `json:"versification,omitempty"`
+
+	// Books lists the OSIS IDs of the books expected; empty means the
+	// books present in the input.
+	Books []string `json:"books,omitempty"`
+}
+
+// VerseCoverageEvidence is the evidence of a VERSE_COVERAGE check. Missing
+// and extra verses are listed as OSIS references with consecutive verses
+// collapsed into ranges.
+type VerseCoverageEvidence struct {
+	Versification string   `json:"versification"`
+	Books         []string `json:"books"`
+	Expected      int      `json:"expected_verses"`
+	Found         int      `json:"found_verses"`
+	MissingCount  int      `json:"missing_count"`
+	ExtraCount    int      `json:"extra_count"`
+	Missing       []string `json:"missing,omitempty"`
+	Extra         []string `json:"extra,omitempty"`
+	Truncated     bool     `json:"truncated,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// verseKey identifies a verse.
+type verseKey struct {
+	book           string
+	chapter, verse int
+}
+
+// executeVerseCoverageCheck executes a verse coverage check.
+func (e *Executor) executeVerseCoverageCheck(check *PlanCheck) (*CheckResult, error) {
+	def := check.VerseCoverage
+	if def == nil {
+		return nil, fmt.Errorf("missing %s definition", check.Type)
+	}
+
+	data, err := e.readInput(def.Input)
+	if err != nil {
+		return nil, err
+	}
+	evidence := &VerseCoverageEvidence{}
+	result := &CheckResult{CheckType: CheckVerseCoverage, Label: check.Label, Details: evidence}
+
+	found, versification, err := extractVerses(data)
+	if err != nil {
+		evidence.Error = err.Error()
+		return result, nil
+	}
+	if def.Versification != "" {
+		versification = def.Versification
+	}
+	if versification == "" {
+		versification = string(ir.VersificationKJV)
+	}
+	evidence.Versification = versification
+
+	table, ok := ir.VerseTable(ir.VersificationID(versification))
+	if !ok {
+		evidence.Error = fmt.Sprintf("no verse table for versification %q", versification)
+		return result, nil
+	}
+
+	books := def.Books
+	if len(books) == 0 {
+		present := make(map[string]bool)
+		for v := range found {
+			present[v.book] = true
+		}
+		for _, b := range table {
+			if present[b.OSIS] {
+				books = append(books, b.OSIS)
+			}
+		}
+	}
+	evidence.Books = books
+
+	expected := make(map[verseKey]bool)
+	var missing []verseKey
+	for _, b := range table {
+		if !containsString(books, b.OSIS) {
+			continue
+		}
+		for c, n := range b.Chapters {
+			for v := 1; v <= n; v++ {
+				key := verseKey{b.OSIS, c + 1, v}
+				expected[key] = true
+				if !found[key] {
+					missing = append(missing, key)
+				}
+			}
+		}
+	}
+
+	var extra []verseKey
+	for v := range found {
+		if !expected[v] && (len(def.Books) == 0 || containsString(books, v.book)) {
+			extra = append(extra, v)
+		}
+	}
+	order := bookOrder(table)
+	sort.Slice(extra, func(i, j int) bool { return verseLess(order, extra[i], extra[j]) })
+
+	evidence.Expected = len(expected)
+	evidence.Found = len(found)
+	evidence.MissingCount = len(missing)
+	evidence.ExtraCount = len(extra)
+	evidence.Missing, evidence.Truncated = verseRanges(missing)
+	var truncated bool
+	evidence.Extra, truncated = verseRanges(extra)
+	evidence.Truncated = evidence.Truncated || truncated
+
+	result.Pass = len(missing) == 0 && len(extra) == 0
+	return result, nil
+}
+
+// extractVerses returns the verses of an IR corpus, OSIS or USX document
+// and the versification it declares, if any.
+func extractVerses(data []byte) (map[verseKey]bool, string, error) {
+	found := make(map[verseKey]bool)
+
+	var corpus ir.Corpus
+	if err := json.Unmarshal(data, &corpus); err == nil {
+		for _, doc := range corpus.Documents {
+			for _, block := range doc.ContentBlocks {
+				for _, anchor := range block.Anchors {
+					for _, span := range anchor.Spans {
+						if span.Type == ir.SpanVerse && span.Ref != nil {
+							addRef(found, span.Ref)
+						}
+					}
+				}
+			}
+		}
+		return found, corpus.Versification, nil
+	}
+
+	doc, err := xml.Parse(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("input is neither IR JSON nor XML: %w", err)
+	}
+	switch root := doc.Root(); {
+	case root != nil && root.Name() == "osis":
+		return found, "", osisVerses(doc, found)
+	case root != nil && root.Name() == "usx":
+		return found, "", usxVerses(doc, found)
+	default:
+		return nil, "", fmt.Errorf("unsupported XML document")
+	}
+}
+
+// osisVerses collects the verses of an OSIS document from the osisID of
+// its verse elements, or the sID of milestoned verses.
+func osisVerses(doc *xml.Document, found map[verseKey]bool) error {
+	nodes, err := doc.XPath("//*[local-name()='verse']")
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		ids := n.Attr("osisID")
+		if ids == "" {
+			ids = n.Attr("sID")
+		}
+		for _, id := range strings.Fields(ids) {
+			start, end, _ := strings.Cut(id, "-")
+			ref, err := ir.ParseRef(stripWork(start))
+			if err != nil || ref.Verse == 0 {
+				continue
+			}
+			if end != "" {
+				if endRef, err := ir.ParseRef(stripWork(end)); err == nil && endRef.Book == ref.Book && endRef.Chapter == ref.Chapter {
+					ref.VerseEnd = endRef.Verse
+				}
+			}
+			addRef(found, ref)
+		}
+	}
+	return nil
+}
+
+// stripWork removes the work prefix of an osisID such as "Bible.KJV:Gen.1.1".
+func stripWork(id string) string {
+	if i := strings.Index(id, ":"); i >= 0 {
+		return id[i+1:]
+	}
+	return id
+}
+
+// usxVerses collects the verses of a USX document, from the sid of USX 3
+// verse milestones or from chapter and verse numbers.
+func usxVerses(doc *xml.Document, found map[verseKey]bool) error {
+	book := ""
+	if n, err := doc.XPathFirst("//book"); err == nil && n != nil {
+		book, _ = ir.OSISBookFromUSFM(n.Attr("code"))
+	}
+	nodes, err := doc.XPath("//chapter | //verse")
+	if err != nil {
+		return err
+	}
+	chapter := 0
+	for _, n := range nodes {
+		if n.Attr("eid") != "" {
+			continue
+		}
+		if n.Name() == "chapter" {
+			fmt.Sscanf(n.Attr("number"), "%d", &chapter)
+			continue
+		}
+		var code string
+		var c, v, vEnd int
+		if sid := n.Attr("sid"); sid != "" {
+			if _, err := fmt.Sscanf(sid, "%s %d:%d", &code, &c, &v); err != nil {
+				continue
+			}
+			b, ok := ir.OSISBookFromUSFM(code)
+			if !ok {
+				continue
+			}
+			_, rng, _ := strings.Cut(sid, "-")
+			fmt.Sscanf(rng, "%d", &vEnd)
+			addRef(found, &ir.Ref{Book: b, Chapter: c, Verse: v, VerseEnd: vEnd})
+			continue
+		}
+		number := n.Attr("number")
+		if _, err := fmt.Sscanf(number, "%d", &v); err != nil || book == "" || chapter == 0 {
+			continue
+		}
+		_, rng, _ := strings.Cut(number, "-")
+		fmt.Sscanf(rng, "%d", &vEnd)
+		addRef(found, &ir.Ref{Book: book, Chapter: chapter, Verse: v, VerseEnd: vEnd})
+	}
+	return nil
+}
+
+// addRef adds the verses of a reference, expanding verse ranges.
+func addRef(found map[verseKey]bool, ref *ir.Ref) {
+	end := ref.VerseEnd
+	if end < ref.Verse {
+		end = ref.Verse
+	}
+	for v := ref.Verse; v <= end; v++ {
+		found[verseKey{ref.Book, ref.Chapter, v}] = true
+	}
+}
+
+// verseRanges formats sorted verses as OSIS references, collapsing
+// consecutive verses of a chapter into ranges. At most maxEvidenceItems
+// entries are returned.
+func verseRanges(verses []verseKey) ([]string, bool) {
+	var out []string
+	for i := 0; i < len(verses); {
+		j := i
+		for j+1 < len(verses) && verses[j+1].book == verses[i].book &&
+			verses[j+1].chapter == verses[i].chapter && verses[j+1].verse == verses[j].verse+1 {
+			j++
+		}
+		if len(out) == maxEvidenceItems {
+			return out, true
+		}
+		ref := fmt.Sprintf("%s.%d.%d", verses[i].book, verses[i].chapter, verses[i].verse)
+		if j > i {
+			ref += fmt.Sprintf("-%d", verses[j].verse)
+		}
+		out = append(out, ref)
+		i = j + 1
+	}
+	return out, false
+}
+
+// bookOrder maps the OSIS IDs of a verse table to their canonical order.
+func bookOrder(table []ir.BookVerses) map[string]int {
+	order := make(map[string]int, len(table))
+	for i, b := range table {
+		order[b.OSIS] = i
+	}
+	return order
+}
+
+// verseLess orders verses canonically; unknown books sort last by name.
+func verseLess(order map[string]int, a, b verseKey) bool {
+	if a.book != b.book {
+		oa, okA := order[a.book]
+		ob, okB := order[b.book]
+		switch {
+		case okA && okB:
+			return oa < ob
+		case okA != okB:
+			return okA
+		default:
+			return a.book < b.book
+		}
+	}
+	if a.chapter != b.chapter {
+		return a.chapter < b.chapter
+	}
+	return a.verse < b.verse
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
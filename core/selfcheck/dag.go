@@ -0,0 +1,208 @@
+package selfcheck
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+)
+
+// stepGraph is the dependency DAG of the steps of a plan. A step depends
+// on the earlier steps that produce the keys it reads, so any plan whose
+// steps run correctly in sequence is acyclic.
+type stepGraph struct {
+	deps     [][]int        // step index -> indices of the steps it reads
+	producer map[string]int // output key -> index of the producing step
+}
+
+// newStepGraph infers the dependencies of the steps of a plan from their
+// input and output keys.
+func newStepGraph(plan *Plan) (*stepGraph, error) {
+	g := &stepGraph{
+		deps:     make([][]int, len(plan.Steps)),
+		producer: make(map[string]int),
+	}
+	for i := range plan.Steps {
+		inputs, outputs, err := plan.Steps[i].keys()
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+
+		seen := make(map[int]bool)
+		for _, key := range inputs {
+			if j, ok := g.producer[key]; ok && !seen[j] {
+				seen[j] = true
+				g.deps[i] = append(g.deps[i], j)
+			}
+		}
+		for _, key := range outputs {
+			if key == "" {
+				continue
+			}
+			if j, ok := g.producer[key]; ok {
+				return nil, fmt.Errorf("step %d: output key %q is already produced by step %d", i+1, key, j+1)
+			}
+			g.producer[key] = i
+		}
+	}
+	return g, nil
+}
+
+// keys returns the keys a step reads and the keys it produces.
+func (s *PlanStep) keys() (inputs, outputs []string, err error) {
+	switch s.Type {
+	case StepExport:
+		if s.Export != nil {
+			return nil, []string{s.Export.OutputKey}, nil
+		}
+	case StepRunTool:
+		if s.RunTool != nil {
+			return s.RunTool.Inputs, []string{s.RunTool.OutputKey, s.RunTool.OutputKey + "_transcript"}, nil
+		}
+	case StepExtractIR:
+		if s.ExtractIR != nil {
+			return []string{s.ExtractIR.SourceArtifactID}, []string{s.ExtractIR.OutputKey}, nil
+		}
+	case StepEmitNative:
+		if s.EmitNative != nil {
+			return []string{s.EmitNative.IRInputKey}, []string{s.EmitNative.OutputKey}, nil
+		}
+	case StepCompareIR:
+		if s.CompareIR != nil {
+			return []string{s.CompareIR.IRAKey, s.CompareIR.IRBKey}, []string{s.CompareIR.OutputKey}, nil
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown step type: %s", s.Type)
+	}
+	return nil, nil, fmt.Errorf("missing %s definition", s.Type)
+}
+
+// inputKeys returns the step output keys a check may read.
+func (c *PlanCheck) inputKeys() []string {
+	switch {
+	case c.Type == CheckByteEqual && c.ByteEqual != nil:
+		return []string{c.ByteEqual.ArtifactB}
+	case c.Type == CheckTranscriptEqual && c.TranscriptEqual != nil:
+		return []string{c.TranscriptEqual.RunA, c.TranscriptEqual.RunB}
+	case c.Type == CheckIRStructureEqual && c.IRStructureEqual != nil:
+		return []string{c.IRStructureEqual.IRA, c.IRStructureEqual.IRB}
+	case c.Type == CheckIRFidelity && c.IRFidelity != nil:
+		return []string{c.IRFidelity.IRKey}
+	case c.Type == CheckTextEqual && c.TextEqual != nil:
+		return []string{c.TextEqual.A, c.TextEqual.B}
+	case c.Type == CheckVerseCoverage && c.VerseCoverage != nil:
+		return []string{c.VerseCoverage.Input}
+	case c.Type == CheckXPathAssert && c.XPathAssert != nil:
+		return []string{c.XPathAssert.Input}
+	case c.Type == CheckSchemaValid && c.SchemaValid != nil:
+		return []string{c.SchemaValid.Input}
+	case c.Type == CheckLossBudget && c.LossBudget != nil:
+		return []string{c.LossBudget.Input}
+	}
+	return nil
+}
+
+// failedInput returns a failing result for a check that reads the output
+// of a step that did not pass, or nil if all its inputs were produced.
+func (g *stepGraph) failedInput(check *PlanCheck, steps []StepResult) *CheckResult {
+	for _, key := range check.inputKeys() {
+		i, ok := g.producer[key]
+		if !ok || steps[i].Status == StatusPass {
+			continue
+		}
+		return &CheckResult{
+			CheckType: check.Type,
+			Label:     check.Label,
+			Pass:      false,
+			Details: map[string]string{
+				"error": fmt.Sprintf("input %q was not produced: step %d %s", key, i+1, steps[i].Status),
+				"cause": steps[i].Error,
+			},
+		}
+	}
+	return nil
+}
+
+// runSteps executes the steps of a plan, each as soon as the steps it
+// depends on have passed, with at most Workers steps running at once.
+// Steps whose dependencies did not pass are skipped.
+func (e *Executor) runSteps(plan *Plan, g *stepGraph) []StepResult {
+	workers := e.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	sem := make(chan struct{}, workers)
+
+	results := make([]StepResult, len(plan.Steps))
+	done := make([]chan struct{}, len(plan.Steps))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for i := range plan.Steps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+
+			step := &plan.Steps[i]
+			r := &results[i]
+			*r = StepResult{Index: i + 1, Type: step.Type, Label: step.Label}
+			for _, j := range g.deps[i] {
+				r.DependsOn = append(r.DependsOn, j+1)
+			}
+
+			for _, j := range g.deps[i] {
+				<-done[j]
+				if results[j].Status != StatusPass {
+					r.Status = StatusSkipped
+					r.Error = fmt.Sprintf("step %d %s", j+1, results[j].Status)
+					return
+				}
+			}
+
+			sem <- struct{}{}
+			startedAt := time.Now()
+			err := e.executeStep(step)
+			r.DurationMS = time.Since(startedAt).Milliseconds()
+			if run := e.toolRun(step); run != nil {
+				r.Resources = run.Usage
+				r.PluginSHA256 = run.PluginSHA256
+			}
+			<-sem
+
+			r.Status = StatusPass
+			if err != nil {
+				r.Status = StatusFail
+				r.Error = err.Error()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// output returns the path of a step output.
+func (e *Executor) output(key string) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	path, ok := e.outputs[key]
+	return path, ok
+}
+
+// setOutput records the path of a step output.
+func (e *Executor) setOutput(key, path string) {
+	e.mu.Lock()
+	e.outputs[key] = path
+	e.mu.Unlock()
+}
+
+// export exports a capsule artifact to path.
+func (e *Executor) export(artifactID string, mode capsule.ExportMode, path string) error {
+	e.capMu.RLock()
+	defer e.capMu.RUnlock()
+	return e.capsule.Export(artifactID, mode, path)
+}
@@ -0,0 +1,171 @@
+package selfcheck
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+)
+
+// failedStep returns the error of the first failed step of a report, or
+// nil if every step passed. Step failures no longer abort a run, so an
+// error from Execute itself fails the test.
+func failedStep(t *testing.T, report *Report, err error) error {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("step failures should not abort the run: %v", err)
+	}
+	for _, step := range report.Steps {
+		if step.Status == StatusFail {
+			if report.Status != StatusFail {
+				t.Errorf("expected report status %q with a failed step, got %q", StatusFail, report.Status)
+			}
+			return errors.New(step.Error)
+		}
+	}
+	return nil
+}
+
+// newDAGTestCapsule creates a capsule with a single artifact.
+func newDAGTestCapsule(t *testing.T) (*capsule.Capsule, string) {
+	t.Helper()
+	tempDir := t.TempDir()
+	cap, err := capsule.New(filepath.Join(tempDir, "capsule"))
+	if err != nil {
+		t.Fatalf("failed to create capsule: %v", err)
+	}
+	testPath := filepath.Join(tempDir, "test.txt")
+	if err := os.WriteFile(testPath, []byte("DAG test content"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	artifact, err := cap.IngestFile(testPath)
+	if err != nil {
+		t.Fatalf("failed to ingest file: %v", err)
+	}
+	return cap, artifact.ID
+}
+
+func TestNewStepGraph(t *testing.T) {
+	plan := &Plan{Steps: []PlanStep{
+		{Type: StepExport, Export: &ExportStep{ArtifactID: "a", OutputKey: "x"}},
+		{Type: StepExtractIR, ExtractIR: &ExtractIRStep{SourceArtifactID: "x", OutputKey: "ir_x"}},
+		{Type: StepExtractIR, ExtractIR: &ExtractIRStep{SourceArtifactID: "a", OutputKey: "ir_a"}},
+		{Type: StepCompareIR, CompareIR: &CompareIRStep{IRAKey: "ir_x", IRBKey: "ir_a", OutputKey: "cmp"}},
+	}}
+
+	g, err := newStepGraph(plan)
+	if err != nil {
+		t.Fatalf("newStepGraph failed: %v", err)
+	}
+	want := [][]int{nil, {0}, nil, {1, 2}}
+	if !reflect.DeepEqual(g.deps, want) {
+		t.Errorf("deps = %v, want %v", g.deps, want)
+	}
+
+	t.Run("duplicate output key", func(t *testing.T) {
+		plan := &Plan{Steps: []PlanStep{
+			{Type: StepExport, Export: &ExportStep{ArtifactID: "a", OutputKey: "x"}},
+			{Type: StepExport, Export: &ExportStep{ArtifactID: "b", OutputKey: "x"}},
+		}}
+		if _, err := newStepGraph(plan); err == nil {
+			t.Error("expected error for duplicate output key")
+		}
+	})
+
+	t.Run("missing definition", func(t *testing.T) {
+		plan := &Plan{Steps: []PlanStep{{Type: StepEmitNative}}}
+		if _, err := newStepGraph(plan); err == nil {
+			t.Error("expected error for step without definition")
+		}
+	})
+}
+
+func TestExecuteFailedStepFailsDependents(t *testing.T) {
+	cap, artifactID := newDAGTestCapsule(t)
+
+	plan := &Plan{
+		ID: "dag-failure",
+		Steps: []PlanStep{
+			{Type: StepExport, Export: &ExportStep{Mode: "IDENTITY", ArtifactID: artifactID, OutputKey: "exported"}},
+			{Type: StepExport, Export: &ExportStep{Mode: "IDENTITY", ArtifactID: "missing", OutputKey: "broken"}},
+			{Type: StepExtractIR, ExtractIR: &ExtractIRStep{SourceArtifactID: "broken", OutputKey: "ir_broken"}},
+			{Type: StepExtractIR, ExtractIR: &ExtractIRStep{SourceArtifactID: "exported", OutputKey: "ir_exported"}},
+		},
+		Checks: []PlanCheck{
+			{Type: CheckByteEqual, Label: "export", ByteEqual: &ByteEqualDef{ArtifactA: artifactID, ArtifactB: "exported"}},
+			{Type: CheckIRFidelity, Label: "broken", IRFidelity: &IRFidelityDef{IRKey: "ir_broken", MaxLossClass: "L4"}},
+			{Type: CheckIRFidelity, Label: "exported", IRFidelity: &IRFidelityDef{IRKey: "ir_exported", MaxLossClass: "L4"}},
+		},
+	}
+
+	report, err := NewExecutor(cap).Execute(plan)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if report.Status != StatusFail {
+		t.Errorf("expected status %q, got %q", StatusFail, report.Status)
+	}
+
+	var statuses []string
+	for _, step := range report.Steps {
+		statuses = append(statuses, step.Status)
+	}
+	wantStatuses := []string{StatusPass, StatusFail, StatusSkipped, StatusPass}
+	if !reflect.DeepEqual(statuses, wantStatuses) {
+		t.Errorf("step statuses = %v, want %v", statuses, wantStatuses)
+	}
+	if got := report.Steps[2].DependsOn; !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("step 3 depends on %v, want [2]", got)
+	}
+
+	var passes []bool
+	for _, result := range report.Results {
+		passes = append(passes, result.Pass)
+	}
+	if want := []bool{true, false, true}; !reflect.DeepEqual(passes, want) {
+		t.Errorf("check results = %v, want %v", passes, want)
+	}
+}
+
+func TestExecuteParallelSteps(t *testing.T) {
+	cap, artifactID := newDAGTestCapsule(t)
+
+	plan := &Plan{ID: "dag-parallel"}
+	for i := 0; i < 20; i++ {
+		exported := fmt.Sprintf("exported_%d", i)
+		ir := fmt.Sprintf("ir_%d", i)
+		plan.Steps = append(plan.Steps,
+			PlanStep{Type: StepExport, Export: &ExportStep{Mode: "IDENTITY", ArtifactID: artifactID, OutputKey: exported}},
+			PlanStep{Type: StepExtractIR, ExtractIR: &ExtractIRStep{SourceArtifactID: artifactID, OutputKey: ir}},
+		)
+		plan.Checks = append(plan.Checks, PlanCheck{
+			Type:      CheckByteEqual,
+			Label:     exported,
+			ByteEqual: &ByteEqualDef{ArtifactA: artifactID, ArtifactB: exported},
+		})
+	}
+
+	for _, workers := range []int{1, 4, 0} {
+		executor := NewExecutor(cap)
+		executor.Workers = workers
+		report, err := executor.Execute(plan)
+		if err != nil {
+			t.Fatalf("Execute with %d workers failed: %v", workers, err)
+		}
+		if report.Status != StatusPass {
+			t.Errorf("expected pass with %d workers, got %q", workers, report.Status)
+		}
+		if len(report.Steps) != len(plan.Steps) {
+			t.Fatalf("expected %d step results, got %d", len(plan.Steps), len(report.Steps))
+		}
+		for i, step := range report.Steps {
+			if step.Index != i+1 || step.Status != StatusPass {
+				t.Errorf("step %d: index %d status %q (%s)", i+1, step.Index, step.Status, step.Error)
+			}
+		}
+	}
+}
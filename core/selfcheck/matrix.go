@@ -0,0 +1,413 @@
+package selfcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+)
+
+// Output keys of the steps of a roundtrip plan.
+const (
+	roundtripIRKey          = "ir"
+	roundtripEmittedKey     = "emitted"
+	roundtripReextractedKey = "reextracted"
+	roundtripCompareKey     = "compare"
+)
+
+// Roundtrip stages, naming the step a loss violation was measured at.
+const (
+	StageExtract   = "extract"
+	StageEmit      = "emit"
+	StageReextract = "re-extract"
+	StageRoundtrip = "roundtrip"
+)
+
+// Matrix is the measured loss of every source/target plugin pair.
+type Matrix struct {
+	Status     string          `json:"status"`
+	Sources    []string        `json:"sources"`
+	Targets    []string        `json:"targets"`
+	Cells      []MatrixCell    `json:"cells"`
+	Violations []LossViolation `json:"violations,omitempty"`
+}
+
+// MatrixCell is the outcome of the roundtrip plan of one plugin pair.
+type MatrixCell struct {
+	Source     string       `json:"source"`
+	Target     string       `json:"target"`
+	ArtifactID string       `json:"artifact_id"`
+	Declared   ir.LossClass `json:"declared_loss_class"`
+	Measured   ir.LossClass `json:"measured_loss_class,omitempty"`
+	Status     string       `json:"status"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// LossViolation flags a plugin whose measured loss exceeds the loss class
+// it declares.
+type LossViolation struct {
+	PluginID string       `json:"plugin_id"`
+	Source   string       `json:"source"`
+	Target   string       `json:"target"`
+	Stage    string       `json:"stage"`
+	Declared ir.LossClass `json:"declared_loss_class"`
+	Measured ir.LossClass `json:"measured_loss_class,omitempty"`
+	Reason   string       `json:"reason"`
+}
+
+// DeclaredLossClass returns the loss class a plugin declares for its IR
+// support. A plugin that declares none makes no fidelity claim, so L4 is
+// returned.
+func DeclaredLossClass(p *plugins.Plugin) ir.LossClass {
+	if p.Manifest.IRSupport != nil {
+		if class := ir.LossClass(p.Manifest.IRSupport.LossClass); class.IsValid() {
+			return class
+		}
+	}
+	return ir.LossL4
+}
+
+// worseLossClass returns the lossier of two loss classes.
+func worseLossClass(a, b ir.LossClass) ir.LossClass {
+	if b.Level() > a.Level() {
+		return b
+	}
+	return a
+}
+
+// RoundtripPlan creates a plan that extracts IR from an artifact with the
+// source plugin, emits it with the target plugin, re-extracts the emitted
+// output and compares both IRs. The loss of each step is checked against
+// the class its plugin declares; when both plugins declare L0 the IRs must
+// also be identical.
+func RoundtripPlan(artifactID string, source, target *plugins.Plugin) *Plan {
+	sourceID := source.Manifest.PluginID
+	targetID := target.Manifest.PluginID
+	sourceClass := DeclaredLossClass(source)
+	targetClass := DeclaredLossClass(target)
+
+	plan := &Plan{
+		ID:          fmt.Sprintf("roundtrip-%s-to-%s", sourceID, targetID),
+		Description: fmt.Sprintf("Round-trip %s through %s", sourceID, targetID),
+		Steps: []PlanStep{
+			{
+				Type: StepExtractIR,
+				ExtractIR: &ExtractIRStep{
+					SourceArtifactID: artifactID,
+					PluginID:         sourceID,
+					OutputKey:        roundtripIRKey,
+				},
+				Label: fmt.Sprintf("Extract IR with %s", sourceID),
+			},
+			{
+				Type: StepEmitNative,
+				EmitNative: &EmitNativeStep{
+					IRInputKey:   roundtripIRKey,
+					PluginID:     targetID,
+					TargetFormat: strings.TrimPrefix(targetID, "format."),
+					OutputKey:    roundtripEmittedKey,
+				},
+				Label: fmt.Sprintf("Emit native with %s", targetID),
+			},
+		},
+		Checks: []PlanCheck{
+			{
+				Type:  CheckLossBudget,
+				Label: fmt.Sprintf("%s extraction within declared %s", sourceID, sourceClass),
+				LossBudget: &LossBudgetDef{
+					Input:    roundtripIRKey,
+					Budget:   LossBudget{MaxLossClass: sourceClass},
+					StepOnly: true,
+				},
+			},
+			{
+				Type:  CheckLossBudget,
+				Label: fmt.Sprintf("%s emission within declared %s", targetID, targetClass),
+				LossBudget: &LossBudgetDef{
+					Input:    roundtripEmittedKey,
+					Budget:   LossBudget{MaxLossClass: targetClass},
+					StepOnly: true,
+				},
+			},
+		},
+	}
+
+	// Only targets that can read their own output can be re-extracted
+	if !target.CanExtractIR() {
+		return plan
+	}
+	plan.Steps = append(plan.Steps,
+		PlanStep{
+			Type: StepExtractIR,
+			ExtractIR: &ExtractIRStep{
+				SourceArtifactID: roundtripEmittedKey,
+				PluginID:         targetID,
+				OutputKey:        roundtripReextractedKey,
+			},
+			Label: fmt.Sprintf("Re-extract IR with %s", targetID),
+		},
+		PlanStep{
+			Type: StepCompareIR,
+			CompareIR: &CompareIRStep{
+				IRAKey:    roundtripIRKey,
+				IRBKey:    roundtripReextractedKey,
+				OutputKey: roundtripCompareKey,
+			},
+			Label: "Compare extracted and re-extracted IR",
+		},
+	)
+	plan.Checks = append(plan.Checks, PlanCheck{
+		Type:  CheckLossBudget,
+		Label: fmt.Sprintf("%s re-extraction within declared %s", targetID, targetClass),
+		LossBudget: &LossBudgetDef{
+			Input:    roundtripReextractedKey,
+			Budget:   LossBudget{MaxLossClass: targetClass},
+			StepOnly: true,
+		},
+	})
+	return plan
+}
+
+// MatrixPlans creates a roundtrip plan for every IR-capable source plugin
+// with a sample artifact and every emit-capable target plugin. samples
+// maps source plugin IDs to the ID of an artifact in their format.
+func MatrixPlans(loader *plugins.Loader, samples map[string]string) []*Plan {
+	sources, targets := matrixPlugins(loader)
+	var plans []*Plan
+	for _, source := range sources {
+		artifactID, ok := samples[source.Manifest.PluginID]
+		if !ok {
+			continue
+		}
+		for _, target := range targets {
+			plans = append(plans, RoundtripPlan(artifactID, source, target))
+		}
+	}
+	return plans
+}
+
+// matrixPlugins returns the extract-capable and emit-capable plugins,
+// ordered by plugin ID.
+func matrixPlugins(loader *plugins.Loader) (sources, targets []*plugins.Plugin) {
+	capable := loader.GetIRCapablePlugins()
+	sort.Slice(capable, func(i, j int) bool {
+		return capable[i].Manifest.PluginID < capable[j].Manifest.PluginID
+	})
+	for _, p := range capable {
+		if p.CanExtractIR() {
+			sources = append(sources, p)
+		}
+		if p.CanEmitIR() {
+			targets = append(targets, p)
+		}
+	}
+	return sources, targets
+}
+
+// MatrixSamples picks, for each extract-capable plugin, the first artifact
+// of the capsule in its format. An artifact matches a plugin when its
+// detected format is one the plugin declares, or otherwise when the
+// plugin's detect command accepts it.
+func MatrixSamples(cap *capsule.Capsule, loader *plugins.Loader) (map[string]string, error) {
+	sources, _ := matrixPlugins(loader)
+
+	ids := make([]string, 0, len(cap.Manifest.Artifacts))
+	for id := range cap.Manifest.Artifacts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	tempDir, err := os.MkdirTemp("", "selfcheck-matrix-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	samples := make(map[string]string)
+	for _, id := range ids {
+		artifact := cap.Manifest.Artifacts[id]
+		path := ""
+		for _, p := range sources {
+			pluginID := p.Manifest.PluginID
+			if _, ok := samples[pluginID]; ok {
+				continue
+			}
+			if artifact.Detected != nil && artifact.Detected.FormatID != "" {
+				if declaresFormat(p, artifact.Detected.FormatID) {
+					samples[pluginID] = id
+				}
+				continue
+			}
+			if path == "" {
+				path = filepath.Join(tempDir, id)
+				if err := cap.Export(id, capsule.ExportModeIdentity, path); err != nil {
+					return nil, fmt.Errorf("failed to export artifact %s: %w", id, err)
+				}
+			}
+			resp, err := plugins.ExecutePlugin(p, plugins.NewDetectRequest(path))
+			if err != nil {
+				continue
+			}
+			if result, err := plugins.ParseDetectResult(resp); err == nil && result.Detected {
+				samples[pluginID] = id
+			}
+		}
+	}
+	return samples, nil
+}
+
+// declaresFormat reports whether a plugin handles a format, either listed
+// in its IR support or named by its plugin ID.
+func declaresFormat(p *plugins.Plugin, formatID string) bool {
+	formatID = strings.TrimPrefix(strings.ToLower(formatID), "format.")
+	if strings.TrimPrefix(p.Manifest.PluginID, "format.") == formatID {
+		return true
+	}
+	for _, f := range p.Manifest.IRSupport.Formats {
+		if strings.ToLower(f) == formatID {
+			return true
+		}
+	}
+	return false
+}
+
+// RunMatrix runs the roundtrip plans of every plugin pair and measures the
+// loss of each pair against the loss the plugins declare.
+func RunMatrix(cap *capsule.Capsule, loader *plugins.Loader, samples map[string]string, workers int) (*Matrix, error) {
+	sources, targets := matrixPlugins(loader)
+	m := &Matrix{Status: StatusPass}
+	for _, p := range sources {
+		if _, ok := samples[p.Manifest.PluginID]; ok {
+			m.Sources = append(m.Sources, p.Manifest.PluginID)
+		}
+	}
+	for _, p := range targets {
+		m.Targets = append(m.Targets, p.Manifest.PluginID)
+	}
+
+	for _, source := range sources {
+		artifactID, ok := samples[source.Manifest.PluginID]
+		if !ok {
+			continue
+		}
+		for _, target := range targets {
+			plan := RoundtripPlan(artifactID, source, target)
+			executor := NewExecutorWithPlugins(cap, loader)
+			executor.Workers = workers
+			report, err := executor.Execute(plan)
+			if err != nil {
+				return nil, fmt.Errorf("plan %s: %w", plan.ID, err)
+			}
+
+			preserved, compared := executor.roundtripPreserved()
+			cell, violations := matrixCell(plan, report, source, target, preserved, compared)
+			cell.ArtifactID = artifactID
+			m.Cells = append(m.Cells, cell)
+			m.Violations = append(m.Violations, violations...)
+			if cell.Status != StatusPass {
+				m.Status = StatusFail
+			}
+		}
+	}
+	return m, nil
+}
+
+// roundtripPreserved reads the IR compare of a roundtrip plan. ok is false
+// when the plan did not compare the re-extracted IR.
+func (e *Executor) roundtripPreserved() (preserved, ok bool) {
+	path, found := e.output(roundtripCompareKey)
+	if !found {
+		return false, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, false
+	}
+	var result struct {
+		DocumentsMatch *bool `json:"documents_match"`
+	}
+	if json.Unmarshal(data, &result) != nil || result.DocumentsMatch == nil {
+		return false, false
+	}
+	return *result.DocumentsMatch, true
+}
+
+// matrixCell summarizes the report of a roundtrip plan and attributes each
+// failed loss check to the plugin of the step it measured. The measured
+// loss is the worst loss the plugins report, raised to L1 when the IR
+// compare shows the round-trip changed the documents.
+func matrixCell(plan *Plan, report *Report, source, target *plugins.Plugin, preserved, compared bool) (MatrixCell, []LossViolation) {
+	sourceID := source.Manifest.PluginID
+	targetID := target.Manifest.PluginID
+	cell := MatrixCell{
+		Source:   sourceID,
+		Target:   targetID,
+		Declared: worseLossClass(DeclaredLossClass(source), DeclaredLossClass(target)),
+		Status:   report.Status,
+	}
+	for _, step := range report.Steps {
+		if step.Status == StatusFail {
+			cell.Error = fmt.Sprintf("step %d: %s", step.Index, step.Error)
+			return cell, nil
+		}
+	}
+
+	var violations []LossViolation
+	measured := ir.LossL0
+	for i, result := range report.Results {
+		check := plan.Checks[i]
+		evidence, ok := result.Details.(*LossBudgetEvidence)
+		if !ok {
+			continue
+		}
+		measured = worseLossClass(measured, evidence.ActualLossClass)
+		if result.Pass {
+			continue
+		}
+
+		pluginID, stage := targetID, StageEmit
+		switch check.LossBudget.Input {
+		case roundtripIRKey:
+			pluginID, stage = sourceID, StageExtract
+		case roundtripReextractedKey:
+			stage = StageReextract
+		}
+		violations = append(violations, LossViolation{
+			PluginID: pluginID,
+			Source:   sourceID,
+			Target:   targetID,
+			Stage:    stage,
+			Declared: evidence.MaxAllowedClass,
+			Measured: evidence.ActualLossClass,
+			Reason:   fmt.Sprintf("%s loss %s exceeds declared %s", stage, evidence.ActualLossClass, evidence.MaxAllowedClass),
+		})
+	}
+
+	if compared && !preserved {
+		if measured == ir.LossL0 {
+			measured = ir.LossL1
+		}
+		// Neither plugin reported the loss, so it is the target's failure
+		// to read back its own output
+		if cell.Declared == ir.LossL0 {
+			violations = append(violations, LossViolation{
+				PluginID: targetID,
+				Source:   sourceID,
+				Target:   targetID,
+				Stage:    StageRoundtrip,
+				Declared: ir.LossL0,
+				Measured: measured,
+				Reason:   "re-extracted IR differs from the extracted IR",
+			})
+			cell.Status = StatusFail
+		}
+	}
+	cell.Measured = measured
+	return cell, violations
+}
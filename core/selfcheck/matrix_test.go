@@ -0,0 +1,230 @@
+package selfcheck
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+)
+
+// createMatrixTestPlugin creates an IR-capable format plugin declaring
+// declared that reports extractLoss and emitLoss when run. Its detect
+// command accepts files whose content is the plugin's name.
+func createMatrixTestPlugin(t *testing.T, baseDir, name, declared, extractLoss, emitLoss string, canExtract bool) {
+	t.Helper()
+	pluginPath := filepath.Join(baseDir, name)
+	if err := os.MkdirAll(pluginPath, 0755); err != nil {
+		t.Fatalf("failed to create plugin dir: %v", err)
+	}
+
+	manifestData, _ := json.Marshal(map[string]interface{}{
+		"plugin_id":  name,
+		"version":    "1.0.0",
+		"kind":       "format",
+		"entrypoint": "plugin.sh",
+		"ir_support": map[string]interface{}{
+			"can_extract": canExtract,
+			"can_emit":    true,
+			"loss_class":  declared,
+		},
+	})
+	if err := os.WriteFile(filepath.Join(pluginPath, "plugin.json"), manifestData, 0644); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	script := `#!/usr/bin/env sh
+input=$(cat)
+command=$(echo "$input" | grep -o '"command":"[^"]*"' | cut -d'"' -f4)
+output_dir=$(echo "$input" | grep -o '"output_dir":"[^"]*"' | cut -d'"' -f4)
+path=$(echo "$input" | grep -o '"path":"[^"]*"' | cut -d'"' -f4)
+
+if [ "$command" = "detect" ]; then
+    if [ "$(cat "$path")" = "` + name + `" ]; then
+        echo '{"status":"ok","result":{"detected":true}}'
+    else
+        echo '{"status":"ok","result":{"detected":false}}'
+    fi
+elif [ "$command" = "extract-ir" ]; then
+    ir_path="$output_dir/extracted.ir.json"
+    echo '{"id":"test"}' > "$ir_path"
+    echo "{\"status\":\"ok\",\"result\":{\"ir_path\":\"$ir_path\",\"loss_report\":{\"loss_class\":\"` + extractLoss + `\"}}}"
+elif [ "$command" = "emit-native" ]; then
+    output_path="$output_dir/output.txt"
+    echo "` + name + `" > "$output_path"
+    echo "{\"status\":\"ok\",\"result\":{\"output_path\":\"$output_path\",\"format\":\"test\",\"loss_report\":{\"loss_class\":\"` + emitLoss + `\"}}}"
+else
+    echo '{"status":"ok"}'
+fi
+`
+	if err := os.WriteFile(filepath.Join(pluginPath, "plugin.sh"), []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write entrypoint: %v", err)
+	}
+}
+
+// newMatrixTestLoader creates plugins format.alpha, which keeps to its
+// declared L1, format.beta, which declares L0 but emits with L2 loss, and
+// the emit-only format.gamma.
+func newMatrixTestLoader(t *testing.T) *plugins.Loader {
+	t.Helper()
+	pluginDir := t.TempDir()
+	createMatrixTestPlugin(t, pluginDir, "format.alpha", "L1", "L1", "L0", true)
+	createMatrixTestPlugin(t, pluginDir, "format.beta", "L0", "L0", "L2", true)
+	createMatrixTestPlugin(t, pluginDir, "format.gamma", "L3", "L0", "L3", false)
+
+	loader := plugins.NewLoader()
+	if err := loader.LoadFromDirAlways(pluginDir); err != nil {
+		t.Fatalf("failed to load plugins: %v", err)
+	}
+	return loader
+}
+
+func TestRoundtripPlan(t *testing.T) {
+	loader := newMatrixTestLoader(t)
+	alpha, _ := loader.GetPlugin("format.alpha")
+	beta, _ := loader.GetPlugin("format.beta")
+	gamma, _ := loader.GetPlugin("format.gamma")
+
+	plan := RoundtripPlan("sample", alpha, beta)
+	if len(plan.Steps) != 4 || len(plan.Checks) != 3 {
+		t.Fatalf("expected 4 steps and 3 checks, got %d and %d", len(plan.Steps), len(plan.Checks))
+	}
+	if got := plan.Checks[0].LossBudget.Budget.MaxLossClass; got != ir.LossL1 {
+		t.Errorf("extract budget = %s, want the source's declared L1", got)
+	}
+	if got := plan.Checks[1].LossBudget.Budget.MaxLossClass; got != ir.LossL0 {
+		t.Errorf("emit budget = %s, want the target's declared L0", got)
+	}
+	if _, err := newStepGraph(plan); err != nil {
+		t.Errorf("invalid plan: %v", err)
+	}
+
+	if plan := RoundtripPlan("sample", beta, beta); plan.Steps[len(plan.Steps)-1].Type != StepCompareIR {
+		t.Error("expected a re-extractable pair to compare the IR")
+	}
+	if plan := RoundtripPlan("sample", alpha, gamma); len(plan.Steps) != 2 {
+		t.Errorf("expected no re-extraction for an emit-only target, got %d steps", len(plan.Steps))
+	}
+
+	plans := MatrixPlans(loader, map[string]string{"format.alpha": "sample"})
+	if len(plans) != 3 {
+		t.Errorf("expected 3 plans for one source and three targets, got %d", len(plans))
+	}
+}
+
+func TestDeclaredLossClass(t *testing.T) {
+	p := &plugins.Plugin{Manifest: &plugins.PluginManifest{IRSupport: &plugins.IRCapabilities{LossClass: "L2"}}}
+	if got := DeclaredLossClass(p); got != ir.LossL2 {
+		t.Errorf("DeclaredLossClass = %s, want L2", got)
+	}
+	p.Manifest.IRSupport.LossClass = ""
+	if got := DeclaredLossClass(p); got != ir.LossL4 {
+		t.Errorf("DeclaredLossClass without a declaration = %s, want L4", got)
+	}
+}
+
+func TestRunMatrix(t *testing.T) {
+	loader := newMatrixTestLoader(t)
+	cap, ids := newChecksTestCapsule(t, map[string][]byte{
+		"a.txt": []byte("format.alpha"),
+		"b.txt": []byte("format.beta"),
+	})
+	cap.Manifest.Artifacts[ids["b.txt"]].Detected = &capsule.DetectionResult{FormatID: "beta"}
+
+	samples, err := MatrixSamples(cap, loader)
+	if err != nil {
+		t.Fatalf("MatrixSamples failed: %v", err)
+	}
+	if samples["format.alpha"] != ids["a.txt"] || samples["format.beta"] != ids["b.txt"] {
+		t.Fatalf("unexpected samples %v", samples)
+	}
+
+	matrix, err := RunMatrix(cap, loader, samples, 2)
+	if err != nil {
+		t.Fatalf("RunMatrix failed: %v", err)
+	}
+	if len(matrix.Sources) != 2 || len(matrix.Targets) != 3 || len(matrix.Cells) != 6 {
+		t.Fatalf("expected a 2x3 matrix, got %v x %v", matrix.Sources, matrix.Targets)
+	}
+	if matrix.Status != StatusFail {
+		t.Error("expected the matrix to fail")
+	}
+
+	cells := make(map[string]MatrixCell)
+	for _, cell := range matrix.Cells {
+		if cell.Error != "" {
+			t.Errorf("%s -> %s: %s", cell.Source, cell.Target, cell.Error)
+		}
+		cells[cell.Source+" "+cell.Target] = cell
+	}
+	if cell := cells["format.alpha format.gamma"]; cell.Status != StatusPass || cell.Measured != ir.LossL3 || cell.Declared != ir.LossL3 {
+		t.Errorf("unexpected alpha -> gamma cell %+v", cell)
+	}
+	if cell := cells["format.alpha format.beta"]; cell.Status != StatusFail || cell.Measured != ir.LossL2 || cell.Declared != ir.LossL1 {
+		t.Errorf("unexpected alpha -> beta cell %+v", cell)
+	}
+
+	for _, v := range matrix.Violations {
+		if v.PluginID != "format.beta" {
+			t.Errorf("unexpected violation by %s: %s", v.PluginID, v.Reason)
+		}
+		if v.Stage == StageEmit && (v.Declared != ir.LossL0 || v.Measured != ir.LossL2) {
+			t.Errorf("unexpected emit violation %+v", v)
+		}
+	}
+	if len(matrix.Violations) == 0 {
+		t.Error("expected format.beta to be flagged")
+	}
+}
+
+func TestMatrixCellMeasuresIRCompare(t *testing.T) {
+	loader := newMatrixTestLoader(t)
+	beta, _ := loader.GetPlugin("format.beta")
+	plan := RoundtripPlan("sample", beta, beta)
+
+	// Every plugin call reports L0
+	report := &Report{Status: StatusPass}
+	for _, check := range plan.Checks {
+		report.Results = append(report.Results, CheckResult{
+			CheckType: check.Type,
+			Pass:      true,
+			Details: &LossBudgetEvidence{Source: "plugin", LossBudgetResult: &LossBudgetResult{
+				WithinBudget:    true,
+				ActualLossClass: ir.LossL0,
+				MaxAllowedClass: ir.LossL0,
+			}},
+		})
+	}
+
+	cell, violations := matrixCell(plan, report, beta, beta, true, true)
+	if cell.Measured != ir.LossL0 || cell.Status != StatusPass || len(violations) != 0 {
+		t.Errorf("unexpected cell for a preserved round-trip: %+v %+v", cell, violations)
+	}
+
+	cell, violations = matrixCell(plan, report, beta, beta, false, true)
+	if cell.Measured != ir.LossL1 || cell.Status != StatusFail {
+		t.Errorf("expected a changed round-trip to measure L1, got %+v", cell)
+	}
+	if len(violations) != 1 || violations[0].Stage != StageRoundtrip || violations[0].PluginID != "format.beta" {
+		t.Errorf("unexpected violations %+v", violations)
+	}
+}
+
+func TestSameDocuments(t *testing.T) {
+	a := []byte(`{"id":"a","source_format":"OSIS","documents":[{"id":"Gen","order":1}]}`)
+	b := []byte(`{"id":"b","source_format":"USFM","documents":[{"id":"Gen","order":1}]}`)
+	c := []byte(`{"id":"a","documents":[{"id":"Exod","order":2}]}`)
+
+	if match, ok := sameDocuments(a, b); !ok || !match {
+		t.Errorf("expected corpora differing only in metadata to match, got %v %v", match, ok)
+	}
+	if match, ok := sameDocuments(a, c); !ok || match {
+		t.Errorf("expected different documents not to match, got %v %v", match, ok)
+	}
+	if _, ok := sameDocuments(a, []byte("not json")); ok {
+		t.Error("expected non-IR input not to compare")
+	}
+}
@@ -0,0 +1,394 @@
+package selfcheck
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/xml"
+)
+
+// Schemas for SCHEMA_VALID checks.
+const (
+	SchemaOSIS = "osis"
+	SchemaUSX  = "usx"
+	SchemaEPUB = "epub"
+)
+
+// osisNamespace is the OSIS 2.x namespace.
+const osisNamespace = "http://www.bibletechnologies.net/2003/OSIS/namespace"
+
+// SchemaValidDef defines a schema validation check. Schema is one of osis,
+// usx or epub; empty detects it from the content.
+type SchemaValidDef struct {
+	Input  string `json:"input"`
+	Schema string `json:"schema,omitempty"`
+}
+
+// SchemaEvidence is the evidence of a SCHEMA_VALID check.
+type SchemaEvidence struct {
+	Schema     string   `json:"schema"`
+	ErrorCount int      `json:"error_count"`
+	Errors     []string `json:"errors,omitempty"`
+	Truncated  bool     `json:"truncated,omitempty"`
+
+	// Positions locates the errors whose position is known, such as
+	// well-formedness errors.
+	Positions []SchemaPosition `json:"positions,omitempty"`
+}
+
+// SchemaPosition locates an error of a SCHEMA_VALID check. Entry names
+// the container entry holding the error; it is empty for errors in the
+// input itself.
+type SchemaPosition struct {
+	Error  int    `json:"error"`
+	Entry  string `json:"entry,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+}
+
+// schemaErrors collects the violations found by a schema validator.
+type schemaErrors struct {
+	evidence *SchemaEvidence
+}
+
+// addf records a violation.
+func (s *schemaErrors) addf(format string, args ...interface{}) {
+	s.evidence.ErrorCount++
+	if len(s.evidence.Errors) < maxEvidenceItems {
+		s.evidence.Errors = append(s.evidence.Errors, fmt.Sprintf(format, args...))
+	} else {
+		s.evidence.Truncated = true
+	}
+}
+
+// addAt records a violation found at a line and column of entry.
+func (s *schemaErrors) addAt(entry string, line, column int, format string, args ...interface{}) {
+	if len(s.evidence.Errors) < maxEvidenceItems {
+		s.evidence.Positions = append(s.evidence.Positions, SchemaPosition{
+			Error:  len(s.evidence.Errors),
+			Entry:  entry,
+			Line:   line,
+			Column: column,
+		})
+	}
+	s.addf(format, args...)
+}
+
+// executeSchemaValidCheck validates an output against the structural
+// rules of its format.
+func (e *Executor) executeSchemaValidCheck(check *PlanCheck) (*CheckResult, error) {
+	def := check.SchemaValid
+	if def == nil {
+		return nil, fmt.Errorf("missing %s definition", check.Type)
+	}
+
+	data, err := e.readInput(def.Input)
+	if err != nil {
+		return nil, err
+	}
+	schema := strings.ToLower(def.Schema)
+	if schema == "" {
+		schema = detectSchema(data)
+	}
+
+	evidence := &SchemaEvidence{Schema: schema}
+	errs := &schemaErrors{evidence: evidence}
+	switch schema {
+	case SchemaOSIS:
+		validateOSIS(data, errs)
+	case SchemaUSX:
+		validateUSX(data, errs)
+	case SchemaEPUB:
+		validateEPUB(data, errs)
+	case "":
+		errs.addf("could not detect the schema of %s", def.Input)
+	default:
+		return nil, fmt.Errorf("unknown schema: %s", def.Schema)
+	}
+
+	return &CheckResult{
+		CheckType: CheckSchemaValid,
+		Label:     check.Label,
+		Pass:      evidence.ErrorCount == 0,
+		Details:   evidence,
+	}, nil
+}
+
+// detectSchema guesses the schema of a document from its content.
+func detectSchema(data []byte) string {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return SchemaEPUB
+	}
+	doc, err := xml.Parse(data)
+	if err != nil {
+		return ""
+	}
+	if root := doc.Root(); root != nil {
+		switch root.Name() {
+		case "osis":
+			return SchemaOSIS
+		case "usx":
+			return SchemaUSX
+		}
+	}
+	return ""
+}
+
+// parseWellFormed parses an XML document, recording well-formedness
+// errors. entry names the container entry holding the document, if any.
+func parseWellFormed(data []byte, name, entry string, errs *schemaErrors) *xml.Document {
+	if result := xml.Validate(data, nil); !result.Valid {
+		for _, e := range result.Errors {
+			errs.addAt(entry, e.Line, e.Column, "%s: not well-formed: %s", name, e.Message)
+		}
+		return nil
+	}
+	doc, err := xml.Parse(data)
+	if err != nil {
+		errs.addf("%s: %v", name, err)
+		return nil
+	}
+	return doc
+}
+
+// xpathNodes runs an XPath query known to be valid.
+func xpathNodes(doc *xml.Document, expr string) []*xml.Node {
+	nodes, _ := doc.XPath(expr)
+	return nodes
+}
+
+// osisIDPattern matches a single OSIS reference with an optional work
+// prefix and grain, e.g. "Bible.KJV:Gen.1.1" or "Ps.119.1!a".
+var osisIDPattern = regexp.MustCompile(`^([\w.]+:)?[\w]+(\.\d+){0,2}(![\w]+)?(-([\w.]+:)?[\w]+(\.\d+){0,2})?$`)
+
+// validateOSIS checks the required structure of an OSIS 2.x document.
+func validateOSIS(data []byte, errs *schemaErrors) {
+	doc := parseWellFormed(data, "osis", "", errs)
+	if doc == nil {
+		return
+	}
+
+	root := doc.Root()
+	if root == nil || root.Name() != "osis" {
+		errs.addf("root element must be <osis>")
+		return
+	}
+	if ns := root.Attr("xmlns"); ns != osisNamespace {
+		errs.addf("root element must be in the OSIS namespace %s, got %q", osisNamespace, ns)
+	}
+
+	texts := xpathNodes(doc, "/*[local-name()='osis']/*[local-name()='osisText']")
+	if len(texts) != 1 {
+		errs.addf("<osis> must contain exactly one <osisText>, found %d", len(texts))
+		return
+	}
+	work := texts[0].Attr("osisIDWork")
+	if work == "" {
+		errs.addf("<osisText> requires an osisIDWork attribute")
+	}
+	if len(xpathNodes(doc, "//*[local-name()='osisText']/*[local-name()='header']")) == 0 {
+		errs.addf("<osisText> must begin with a <header>")
+	} else if work != "" && len(xpathNodes(doc, fmt.Sprintf("//*[local-name()='header']/*[local-name()='work'][@osisWork=%q]", work))) == 0 {
+		errs.addf("<header> has no <work> declaring osisIDWork %q", work)
+	}
+
+	open := make(map[string]bool)
+	for _, v := range xpathNodes(doc, "//*[local-name()='verse']") {
+		osisID, sID, eID := v.Attr("osisID"), v.Attr("sID"), v.Attr("eID")
+		switch {
+		case eID != "":
+			if !open[eID] {
+				errs.addf("verse eID %q has no matching sID", eID)
+			}
+			delete(open, eID)
+			continue
+		case sID != "":
+			if open[sID] {
+				errs.addf("duplicate verse sID %q", sID)
+			}
+			open[sID] = true
+		}
+		if osisID == "" {
+			errs.addf("verse requires an osisID attribute")
+			continue
+		}
+		for _, id := range strings.Fields(osisID) {
+			if !osisIDPattern.MatchString(id) {
+				errs.addf("invalid osisID %q", id)
+			}
+		}
+	}
+	for sID := range open {
+		errs.addf("verse sID %q has no matching eID", sID)
+	}
+}
+
+// validateUSX checks the required structure of a USX document.
+func validateUSX(data []byte, errs *schemaErrors) {
+	doc := parseWellFormed(data, "usx", "", errs)
+	if doc == nil {
+		return
+	}
+
+	root := doc.Root()
+	if root == nil || root.Name() != "usx" {
+		errs.addf("root element must be <usx>")
+		return
+	}
+	if root.Attr("version") == "" {
+		errs.addf("<usx> requires a version attribute")
+	}
+
+	children := root.Children()
+	if len(children) == 0 || children[0].Name() != "book" {
+		errs.addf("the first element of <usx> must be <book>")
+	} else if code := children[0].Attr("code"); code == "" {
+		errs.addf("<book> requires a code attribute")
+	} else if _, ok := ir.OSISBookFromUSFM(code); !ok {
+		errs.addf("unknown book code %q", code)
+	}
+
+	for _, n := range xpathNodes(doc, "//para | //char | //note") {
+		if n.Attr("style") == "" {
+			errs.addf("<%s> requires a style attribute", n.Name())
+		}
+	}
+	for _, n := range xpathNodes(doc, "//chapter | //verse") {
+		if n.Attr("eid") != "" {
+			continue
+		}
+		if n.Attr("number") == "" {
+			errs.addf("<%s> requires a number attribute", n.Name())
+		}
+	}
+	if len(xpathNodes(doc, "//chapter")) == 0 {
+		errs.addf("document has no <chapter>")
+	}
+}
+
+// validateEPUB checks the container structure of an EPUB 2 or 3 file.
+func validateEPUB(data []byte, errs *schemaErrors) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		errs.addf("not a ZIP container: %v", err)
+		return
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	if len(zr.File) == 0 || zr.File[0].Name != "mimetype" {
+		errs.addf("mimetype must be the first entry of the container")
+	} else {
+		if zr.File[0].Method != zip.Store {
+			errs.addf("mimetype must be stored uncompressed")
+		}
+		if content, _ := readZipFile(zr.File[0]); string(content) != "application/epub+zip" {
+			errs.addf("mimetype must contain application/epub+zip, got %q", content)
+		}
+	}
+
+	container := readXMLEntry(files, "META-INF/container.xml", errs)
+	if container == nil {
+		return
+	}
+	rootfile, _ := container.XPathFirst("//*[local-name()='rootfile'][@media-type='application/oebps-package+xml']")
+	if rootfile == nil || rootfile.Attr("full-path") == "" {
+		errs.addf("container.xml has no OPF rootfile")
+		return
+	}
+	opfPath := rootfile.Attr("full-path")
+	opf := readXMLEntry(files, opfPath, errs)
+	if opf == nil {
+		return
+	}
+
+	pkg := opf.Root()
+	if pkg == nil || pkg.Name() != "package" {
+		errs.addf("%s: root element must be <package>", opfPath)
+		return
+	}
+	version := pkg.Attr("version")
+	if version == "" {
+		errs.addf("%s: <package> requires a version attribute", opfPath)
+	}
+	uid := pkg.Attr("unique-identifier")
+	if uid == "" {
+		errs.addf("%s: <package> requires a unique-identifier attribute", opfPath)
+	} else if len(xpathNodes(opf, fmt.Sprintf("//*[local-name()='identifier'][@id=%q]", uid))) == 0 {
+		errs.addf("%s: unique-identifier %q does not name a dc:identifier", opfPath, uid)
+	}
+	for _, field := range []string{"title", "identifier", "language"} {
+		if len(xpathNodes(opf, fmt.Sprintf("//*[local-name()='metadata']/*[local-name()=%q]", field))) == 0 {
+			errs.addf("%s: metadata requires dc:%s", opfPath, field)
+		}
+	}
+
+	base := path.Dir(opfPath)
+	ids := make(map[string]bool)
+	hasNav := false
+	for _, item := range xpathNodes(opf, "//*[local-name()='manifest']/*[local-name()='item']") {
+		id, href := item.Attr("id"), item.Attr("href")
+		if id == "" || href == "" || item.Attr("media-type") == "" {
+			errs.addf("%s: manifest item %q requires id, href and media-type", opfPath, id)
+			continue
+		}
+		ids[id] = true
+		if _, ok := files[path.Join(base, href)]; !ok && !strings.Contains(href, "://") {
+			errs.addf("%s: manifest item %q refers to missing file %s", opfPath, id, href)
+		}
+		if strings.Contains(" "+item.Attr("properties")+" ", " nav ") {
+			hasNav = true
+		}
+	}
+	if strings.HasPrefix(version, "3") && !hasNav {
+		errs.addf("%s: EPUB 3 requires a navigation document", opfPath)
+	}
+
+	itemrefs := xpathNodes(opf, "//*[local-name()='spine']/*[local-name()='itemref']")
+	if len(itemrefs) == 0 {
+		errs.addf("%s: spine is empty", opfPath)
+	}
+	for _, ref := range itemrefs {
+		if !ids[ref.Attr("idref")] {
+			errs.addf("%s: spine itemref %q is not in the manifest", opfPath, ref.Attr("idref"))
+		}
+	}
+	if strings.HasPrefix(version, "2") {
+		if spine, _ := opf.XPathFirst("//*[local-name()='spine']"); spine != nil && !ids[spine.Attr("toc")] {
+			errs.addf("%s: EPUB 2 spine requires a toc referring to the NCX", opfPath)
+		}
+	}
+}
+
+// readXMLEntry reads and parses an XML entry of a container.
+func readXMLEntry(files map[string]*zip.File, name string, errs *schemaErrors) *xml.Document {
+	f, ok := files[name]
+	if !ok {
+		errs.addf("missing %s", name)
+		return nil
+	}
+	content, err := readZipFile(f)
+	if err != nil {
+		errs.addf("%s: %v", name, err)
+		return nil
+	}
+	return parseWellFormed(content, name, name, errs)
+}
+
+// readZipFile returns the contents of a ZIP entry.
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
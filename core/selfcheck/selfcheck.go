@@ -3,15 +3,21 @@
 package selfcheck
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/FocuswithJustin/JuniperBible/core/capsule"
 	"github.com/FocuswithJustin/JuniperBible/core/cas"
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
+	"github.com/FocuswithJustin/JuniperBible/core/runner"
 )
 
 // Version is the report format version.
@@ -21,6 +27,10 @@ const Version = "1.0.0"
 const (
 	StatusPass = "pass"
 	StatusFail = "fail"
+
+	// StatusSkipped marks a step not run because a step it depends on
+	// failed.
+	StatusSkipped = "skipped"
 )
 
 // Step types.
@@ -39,6 +49,11 @@ const (
 	CheckIRStructureEqual = "IR_STRUCTURE_EQUAL"
 	CheckIRRoundtrip      = "IR_ROUNDTRIP"
 	CheckIRFidelity       = "IR_FIDELITY"
+	CheckTextEqual        = "TEXT_EQUAL"
+	CheckVerseCoverage    = "VERSE_COVERAGE"
+	CheckXPathAssert      = "XPATH_ASSERT"
+	CheckSchemaValid      = "SCHEMA_VALID"
+	CheckLossBudget       = "LOSS_BUDGET"
 )
 
 // Plan defines a round-trip verification plan.
@@ -62,17 +77,19 @@ type PlanStep struct {
 
 // ExtractIRStep defines an IR extraction step.
 type ExtractIRStep struct {
-	SourceArtifactID string `json:"source_artifact_id"`
-	PluginID         string `json:"plugin_id,omitempty"`
-	OutputKey        string `json:"output_key"`
+	SourceArtifactID string                 `json:"source_artifact_id"`
+	PluginID         string                 `json:"plugin_id,omitempty"`
+	OutputKey        string                 `json:"output_key"`
+	Options          map[string]interface{} `json:"options,omitempty"` // Options declared by the plugin
 }
 
 // EmitNativeStep defines an emit-native step.
 type EmitNativeStep struct {
-	IRInputKey   string `json:"ir_input_key"`
-	PluginID     string `json:"plugin_id"`
-	TargetFormat string `json:"target_format"`
-	OutputKey    string `json:"output_key"`
+	IRInputKey   string                 `json:"ir_input_key"`
+	PluginID     string                 `json:"plugin_id"`
+	TargetFormat string                 `json:"target_format"`
+	OutputKey    string                 `json:"output_key"`
+	Options      map[string]interface{} `json:"options,omitempty"` // Options declared by the plugin
 }
 
 // CompareIRStep defines an IR comparison step.
@@ -106,6 +123,11 @@ type PlanCheck struct {
 	IRStructureEqual *IRStructureEqualDef `json:"ir_structure_equal,omitempty"`
 	IRRoundtrip      *IRRoundtripDef      `json:"ir_roundtrip,omitempty"`
 	IRFidelity       *IRFidelityDef       `json:"ir_fidelity,omitempty"`
+	TextEqual        *TextEqualDef        `json:"text_equal,omitempty"`
+	VerseCoverage    *VerseCoverageDef    `json:"verse_coverage,omitempty"`
+	XPathAssert      *XPathAssertDef      `json:"xpath_assert,omitempty"`
+	SchemaValid      *SchemaValidDef      `json:"schema_valid,omitempty"`
+	LossBudget       *LossBudgetDef       `json:"loss_budget,omitempty"`
 }
 
 // IRStructureEqualDef defines an IR structure equality check.
@@ -148,6 +170,14 @@ type Report struct {
 	Engine        *EngineInfo   `json:"engine,omitempty"`
 	Results       []CheckResult `json:"results"`
 	Status        string        `json:"status"`
+
+	// Steps records the outcome and timing of each plan step, in plan
+	// order.
+	Steps []StepResult `json:"steps,omitempty"`
+
+	// Provenance lists the provenance records added to the capsule for
+	// derived artifacts emitted during the run.
+	Provenance []*capsule.ProvenanceRecord `json:"provenance,omitempty"`
 }
 
 // EngineInfo describes the engine used for the check.
@@ -167,6 +197,24 @@ type CheckResult struct {
 	Details   interface{} `json:"details,omitempty"`
 }
 
+// StepResult is the outcome of a single plan step.
+type StepResult struct {
+	Index      int    `json:"index"`
+	Type       string `json:"type"`
+	Label      string `json:"label,omitempty"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DependsOn  []int  `json:"depends_on,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+
+	// Resources is what the tool of a RUN_TOOL step used, when measured.
+	Resources *resource.Usage `json:"resources,omitempty"`
+
+	// PluginSHA256 is the hash of the plugin executable that ran the tool
+	// of a RUN_TOOL step, when an external plugin ran it.
+	PluginSHA256 string `json:"plugin_sha256,omitempty"`
+}
+
 // HashInfo contains hash information for comparison.
 type HashInfo struct {
 	SHA256 string `json:"sha256,omitempty"`
@@ -185,17 +233,67 @@ func (r *Report) Hash() string {
 
 // Executor executes self-check plans.
 type Executor struct {
+	// Workers bounds the number of steps run concurrently. Zero means
+	// the number of CPUs.
+	Workers int
+
+	// Tools runs RUN_TOOL steps through an execution engine (Nix,
+	// sandbox or replay) instead of running tool plugins over IPC.
+	Tools runner.Executor
+
+	// RecordProvenance stores each plugin-emitted output, its IR and a
+	// provenance statement in the capsule. The caller is responsible for
+	// saving the capsule afterwards.
+	RecordProvenance bool
+
 	capsule      *capsule.Capsule
 	pluginLoader *plugins.Loader
 	outputs      map[string]string // key -> file path
 	tempDir      string
+
+	// mu guards outputs, derivations and provenance while steps run
+	// concurrently; capMu guards the capsule, whose blob index is written
+	// when provenance is recorded.
+	mu    sync.Mutex
+	capMu sync.RWMutex
+
+	// derivations tracks how each plugin-extracted IR was produced so
+	// emitted outputs can be attested.
+	derivations map[string]*derivation
+	provenance  []*capsule.ProvenanceRecord
+
+	// losses holds the plugin loss reports along the derivation chain of
+	// each output, for LOSS_BUDGET checks.
+	losses map[string][]*ir.LossReport
+
+	// stepLoss holds the loss report of the step that produced each
+	// output, so loss can be attributed to a single plugin.
+	stepLoss map[string]*ir.LossReport
+
+	// toolRuns holds the result of the tool of each RUN_TOOL output.
+	toolRuns map[string]*runner.ExecutionResult
+}
+
+// derivation records the source, plugin and options of an extracted IR.
+type derivation struct {
+	source  *capsule.Artifact
+	plugin  *plugins.Plugin
+	options plugins.Options
+	loss    *ir.LossReport
+	// binaryHash is the hash of the external plugin executable that
+	// extracted the IR, empty for embedded plugins.
+	binaryHash string
 }
 
 // NewExecutor creates a new plan executor.
 func NewExecutor(cap *capsule.Capsule) *Executor {
 	return &Executor{
-		capsule: cap,
-		outputs: make(map[string]string),
+		capsule:     cap,
+		outputs:     make(map[string]string),
+		derivations: make(map[string]*derivation),
+		losses:      make(map[string][]*ir.LossReport),
+		stepLoss:    make(map[string]*ir.LossReport),
+		toolRuns:    make(map[string]*runner.ExecutionResult),
 	}
 }
 
@@ -205,6 +303,10 @@ func NewExecutorWithPlugins(cap *capsule.Capsule, loader *plugins.Loader) *Execu
 		capsule:      cap,
 		pluginLoader: loader,
 		outputs:      make(map[string]string),
+		derivations:  make(map[string]*derivation),
+		losses:       make(map[string][]*ir.LossReport),
+		stepLoss:     make(map[string]*ir.LossReport),
+		toolRuns:     make(map[string]*runner.ExecutionResult),
 	}
 }
 
@@ -218,39 +320,61 @@ func (e *Executor) Execute(plan *Plan) (*Report, error) {
 	defer os.RemoveAll(tempDir)
 	e.tempDir = tempDir
 
-	// Execute steps
-	for _, step := range plan.Steps {
-		if err := e.executeStep(&step); err != nil {
-			return nil, fmt.Errorf("step failed: %w", err)
-		}
+	graph, err := newStepGraph(plan)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plan: %w", err)
 	}
 
+	// Execute steps; a failed step skips its dependents but not the run
+	steps := e.runSteps(plan, graph)
+
 	// Run checks
 	var results []CheckResult
 	allPass := true
 
 	for _, check := range plan.Checks {
-		result, err := e.executeCheck(&check)
-		if err != nil {
-			return nil, fmt.Errorf("check failed: %w", err)
+		result := graph.failedInput(&check, steps)
+		if result == nil {
+			result, err = e.executeCheck(&check)
+			if err != nil {
+				return nil, fmt.Errorf("check failed: %w", err)
+			}
 		}
 		results = append(results, *result)
 		if !result.Pass {
 			allPass = false
 		}
 	}
+	for _, step := range steps {
+		if step.Status != StatusPass {
+			allPass = false
+		}
+	}
 
 	status := StatusPass
 	if !allPass {
 		status = StatusFail
 	}
 
+	var engine *EngineInfo
+	if e.Tools != nil {
+		spec := e.Tools.EngineSpec()
+		engine = &EngineInfo{
+			EngineID:        spec.String(),
+			FlakeLockSHA256: spec.Nix.FlakeLockSHA256,
+			Derivations:     spec.Nix.Derivations,
+		}
+	}
+
 	return &Report{
 		ReportVersion: Version,
 		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
 		PlanID:        plan.ID,
+		Engine:        engine,
 		Results:       results,
 		Status:        status,
+		Steps:         steps,
+		Provenance:    e.provenance,
 	}, nil
 }
 
@@ -281,11 +405,11 @@ func (e *Executor) executeExportStep(step *ExportStep) error {
 		mode = capsule.ExportModeDerived
 	}
 
-	if err := e.capsule.Export(step.ArtifactID, mode, outputPath); err != nil {
+	if err := e.export(step.ArtifactID, mode, outputPath); err != nil {
 		return fmt.Errorf("export failed: %w", err)
 	}
 
-	e.outputs[step.OutputKey] = outputPath
+	e.setOutput(step.OutputKey, outputPath)
 	return nil
 }
 
@@ -293,18 +417,22 @@ func (e *Executor) executeExportStep(step *ExportStep) error {
 // This runs a tool plugin with the specified profile and inputs,
 // storing the output for use in subsequent steps or checks.
 func (e *Executor) executeRunToolStep(step *RunToolStep) error {
-	if e.pluginLoader == nil {
-		return fmt.Errorf("plugin loader not configured - use NewExecutorWithPlugins")
-	}
+	var plugin *plugins.Plugin
+	if e.Tools == nil {
+		if e.pluginLoader == nil {
+			return fmt.Errorf("plugin loader not configured - use NewExecutorWithPlugins")
+		}
 
-	// Get the tool plugin
-	plugin, err := e.pluginLoader.GetPlugin(step.ToolPluginID)
-	if err != nil {
-		return fmt.Errorf("failed to get tool plugin %q: %w", step.ToolPluginID, err)
-	}
+		// Get the tool plugin
+		var err error
+		plugin, err = e.pluginLoader.GetPlugin(step.ToolPluginID)
+		if err != nil {
+			return fmt.Errorf("failed to get tool plugin %q: %w", step.ToolPluginID, err)
+		}
 
-	if !plugin.IsTool() {
-		return fmt.Errorf("plugin %q is not a tool plugin (kind: %s)", step.ToolPluginID, plugin.Manifest.Kind)
+		if !plugin.IsTool() {
+			return fmt.Errorf("plugin %q is not a tool plugin (kind: %s)", step.ToolPluginID, plugin.Manifest.Kind)
+		}
 	}
 
 	// Prepare input directory
@@ -319,7 +447,7 @@ func (e *Executor) executeRunToolStep(step *RunToolStep) error {
 		var inputPath string
 
 		// Check if it's a previous step output
-		if prevPath, ok := e.outputs[inputKey]; ok {
+		if prevPath, ok := e.output(inputKey); ok {
 			inputPath = prevPath
 		} else if artifact, ok := e.capsule.Manifest.Artifacts[inputKey]; ok {
 			// It's an artifact - export it
@@ -327,7 +455,7 @@ func (e *Executor) executeRunToolStep(step *RunToolStep) error {
 			if inputPath == filepath.Join(inputDir, "") {
 				inputPath = filepath.Join(inputDir, inputKey)
 			}
-			if err := e.capsule.Export(inputKey, capsule.ExportModeIdentity, inputPath); err != nil {
+			if err := e.export(inputKey, capsule.ExportModeIdentity, inputPath); err != nil {
 				return fmt.Errorf("failed to export input %q: %w", inputKey, err)
 			}
 		} else {
@@ -342,6 +470,68 @@ func (e *Executor) executeRunToolStep(step *RunToolStep) error {
 		return fmt.Errorf("failed to create output dir: %w", err)
 	}
 
+	var result *runner.ExecutionResult
+	var err error
+	if e.Tools != nil {
+		if result, err = e.runToolWithEngine(step, inputPaths, outputDir); err != nil {
+			return err
+		}
+	} else if result, err = runToolPlugin(plugin, step, inputPaths, outputDir); err != nil {
+		return err
+	}
+	if err := e.recordUsage(step, result); err != nil {
+		return err
+	}
+
+	// Store the output directory path
+	e.setOutput(step.OutputKey, outputDir)
+
+	// Look for transcript file and store its path specifically
+	transcriptPath := filepath.Join(outputDir, "transcript.jsonl")
+	if _, err := os.Stat(transcriptPath); err == nil {
+		e.setOutput(step.OutputKey+"_transcript", transcriptPath)
+	}
+
+	return nil
+}
+
+// recordUsage keeps the result of a tool for the step report and writes
+// the resources it used as an engine transcript, the
+// <output>_engine_transcript output. The tool transcript is left as is,
+// because measurements differ between otherwise identical runs.
+func (e *Executor) recordUsage(step *RunToolStep, result *runner.ExecutionResult) error {
+	e.mu.Lock()
+	e.toolRuns[step.OutputKey] = result
+	e.mu.Unlock()
+	if result.Usage == nil {
+		return nil
+	}
+	data, err := runner.EngineTranscript(runner.NewRequest(step.ToolPluginID, step.Profile), result)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(e.tempDir, step.OutputKey+"_engine_transcript.jsonl")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write engine transcript: %w", err)
+	}
+	e.setOutput(step.OutputKey+"_engine_transcript", path)
+	return nil
+}
+
+// toolRun returns the tool result of a RUN_TOOL step, if it ran.
+func (e *Executor) toolRun(step *PlanStep) *runner.ExecutionResult {
+	if step.RunTool == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.toolRuns[step.RunTool.OutputKey]
+}
+
+// runToolPlugin runs a tool plugin over IPC, writing its outputs to
+// outputDir. The result holds the resources the plugin process used and
+// the hash of its executable.
+func runToolPlugin(plugin *plugins.Plugin, step *RunToolStep, inputPaths []string, outputDir string) (*runner.ExecutionResult, error) {
 	// Build the IPC request for the tool
 	req := &plugins.IPCRequest{
 		Command: "run",
@@ -355,23 +545,51 @@ func (e *Executor) executeRunToolStep(step *RunToolStep) error {
 	// Execute the tool plugin
 	resp, err := plugins.ExecutePlugin(plugin, req)
 	if err != nil {
-		return fmt.Errorf("tool execution failed: %w", err)
+		return nil, fmt.Errorf("tool execution failed: %w", err)
 	}
 
 	if resp.Status == "error" {
-		return fmt.Errorf("tool returned error: %s", resp.Error)
+		return nil, fmt.Errorf("tool returned error: %s", resp.Error)
 	}
+	result := &runner.ExecutionResult{Usage: resp.Usage, PluginSHA256: resp.BinarySHA256, Engine: runner.NewEngineSpec("plugin")}
+	result.Engine.Nix = runner.NixConfig{}
+	return result, nil
+}
 
-	// Store the output directory path
-	e.outputs[step.OutputKey] = outputDir
-
-	// Look for transcript file and store its path specifically
-	transcriptPath := filepath.Join(outputDir, "transcript.jsonl")
-	if _, err := os.Stat(transcriptPath); err == nil {
-		e.outputs[step.OutputKey+"_transcript"] = transcriptPath
+// runToolWithEngine runs a tool request on the executor's engine and
+// writes the transcript and output files to outputDir, where the plugin
+// would have written them.
+func (e *Executor) runToolWithEngine(step *RunToolStep, inputPaths []string, outputDir string) (*runner.ExecutionResult, error) {
+	req := runner.NewRequest(step.ToolPluginID, step.Profile)
+	result, err := e.Tools.ExecuteRequest(context.Background(), req, inputPaths)
+	if err != nil {
+		return nil, fmt.Errorf("tool execution failed: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("tool exited with code %d: %s", result.ExitCode, strings.TrimSpace(string(result.Stderr)))
 	}
 
-	return nil
+	for name, data := range result.OutputBlobs {
+		if err := runner.ValidateOutputName(name); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(outputDir, filepath.FromSlash(name))
+		if rel, err := filepath.Rel(outputDir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("output %s is outside the output directory", name)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output dir: %w", err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write output %s: %w", name, err)
+		}
+	}
+	if len(result.TranscriptData) > 0 {
+		if err := os.WriteFile(filepath.Join(outputDir, "transcript.jsonl"), result.TranscriptData, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write transcript: %w", err)
+		}
+	}
+	return result, nil
 }
 
 // executeExtractIRStep executes an IR extraction step.
@@ -382,15 +600,16 @@ func (e *Executor) executeExtractIRStep(step *ExtractIRStep) error {
 	artifact, ok := e.capsule.Manifest.Artifacts[step.SourceArtifactID]
 	if !ok {
 		// Check if it's an output from a previous step
-		if prevPath, ok := e.outputs[step.SourceArtifactID]; ok {
+		if prevPath, ok := e.output(step.SourceArtifactID); ok {
 			sourcePath = prevPath
 		} else {
 			return fmt.Errorf("artifact not found: %s", step.SourceArtifactID)
 		}
 	} else {
-		// Export artifact to temp file
-		sourcePath = filepath.Join(e.tempDir, step.SourceArtifactID)
-		if err := e.capsule.Export(step.SourceArtifactID, capsule.ExportModeIdentity, sourcePath); err != nil {
+		// Export artifact to a temp file private to this step, since other
+		// steps may extract the same artifact concurrently
+		sourcePath = filepath.Join(e.tempDir, step.OutputKey+"_source", step.SourceArtifactID)
+		if err := e.export(step.SourceArtifactID, capsule.ExportModeIdentity, sourcePath); err != nil {
 			return fmt.Errorf("failed to export artifact: %w", err)
 		}
 	}
@@ -405,8 +624,13 @@ func (e *Executor) executeExtractIRStep(step *ExtractIRStep) error {
 	if e.pluginLoader != nil && step.PluginID != "" {
 		plugin, err := e.pluginLoader.GetPlugin(step.PluginID)
 		if err == nil && plugin.CanExtractIR() {
+			opts, err := plugin.Manifest.ResolveOptions("extract-ir", step.Options)
+			if err != nil {
+				return err
+			}
+
 			// Call the plugin's extract-ir command
-			req := plugins.NewExtractIRRequest(sourcePath, irOutputDir)
+			req := plugins.NewExtractIRRequest(sourcePath, irOutputDir).WithOptions(opts)
 			resp, err := plugins.ExecutePlugin(plugin, req)
 			if err != nil {
 				return fmt.Errorf("plugin extract-ir failed: %w", err)
@@ -417,7 +641,18 @@ func (e *Executor) executeExtractIRStep(step *ExtractIRStep) error {
 				return fmt.Errorf("failed to parse extract-ir result: %w", err)
 			}
 
-			e.outputs[step.OutputKey] = result.IRPath
+			d := &derivation{source: artifact, plugin: plugin, options: opts, binaryHash: result.BinarySHA256}
+			if result.LossReport != nil {
+				d.loss = capsule.LossReportFromIPC(result.LossReport)
+			}
+			e.mu.Lock()
+			e.derivations[step.OutputKey] = d
+			if d.loss != nil {
+				e.losses[step.OutputKey] = []*ir.LossReport{d.loss}
+				e.stepLoss[step.OutputKey] = d.loss
+			}
+			e.mu.Unlock()
+			e.setOutput(step.OutputKey, result.IRPath)
 			return nil
 		}
 	}
@@ -434,14 +669,14 @@ func (e *Executor) executeExtractIRStep(step *ExtractIRStep) error {
 		return fmt.Errorf("failed to write IR output: %w", err)
 	}
 
-	e.outputs[step.OutputKey] = outputPath
+	e.setOutput(step.OutputKey, outputPath)
 	return nil
 }
 
 // executeEmitNativeStep executes a native format emission step.
 func (e *Executor) executeEmitNativeStep(step *EmitNativeStep) error {
 	// Get IR input
-	irPath, ok := e.outputs[step.IRInputKey]
+	irPath, ok := e.output(step.IRInputKey)
 	if !ok {
 		return fmt.Errorf("IR input not found: %s", step.IRInputKey)
 	}
@@ -456,8 +691,14 @@ func (e *Executor) executeEmitNativeStep(step *EmitNativeStep) error {
 	if e.pluginLoader != nil && step.PluginID != "" {
 		plugin, err := e.pluginLoader.GetPlugin(step.PluginID)
 		if err == nil && plugin.CanEmitIR() {
+			opts, err := plugin.Manifest.ResolveOptions("emit-native", step.Options)
+			if err != nil {
+				return err
+			}
+
 			// Call the plugin's emit-native command
-			req := plugins.NewEmitNativeRequest(irPath, nativeOutputDir)
+			startedAt := time.Now()
+			req := plugins.NewEmitNativeRequest(irPath, nativeOutputDir).WithOptions(opts)
 			resp, err := plugins.ExecutePlugin(plugin, req)
 			if err != nil {
 				return fmt.Errorf("plugin emit-native failed: %w", err)
@@ -468,7 +709,28 @@ func (e *Executor) executeEmitNativeStep(step *EmitNativeStep) error {
 				return fmt.Errorf("failed to parse emit-native result: %w", err)
 			}
 
-			e.outputs[step.OutputKey] = result.OutputPath
+			var emitLoss *ir.LossReport
+			if result.LossReport != nil {
+				emitLoss = capsule.LossReportFromIPC(result.LossReport)
+			}
+			if e.RecordProvenance {
+				if err := e.recordProvenance(step, plugin, result, opts, irPath, emitLoss, startedAt); err != nil {
+					return fmt.Errorf("failed to record provenance: %w", err)
+				}
+			}
+
+			e.mu.Lock()
+			chain := append([]*ir.LossReport(nil), e.losses[step.IRInputKey]...)
+			if emitLoss != nil {
+				chain = append(chain, emitLoss)
+				e.stepLoss[step.OutputKey] = emitLoss
+			}
+			if len(chain) > 0 {
+				e.losses[step.OutputKey] = chain
+			}
+			e.mu.Unlock()
+
+			e.setOutput(step.OutputKey, result.OutputPath)
 			return nil
 		}
 	}
@@ -483,20 +745,87 @@ func (e *Executor) executeEmitNativeStep(step *EmitNativeStep) error {
 		return fmt.Errorf("failed to write native output: %w", err)
 	}
 
-	e.outputs[step.OutputKey] = outputPath
+	e.setOutput(step.OutputKey, outputPath)
+	return nil
+}
+
+// recordProvenance stores an emitted output in the capsule and attaches a
+// provenance statement linking it to the IR and, when the IR was extracted
+// from a capsule artifact, to the source bytes.
+func (e *Executor) recordProvenance(step *EmitNativeStep, plugin *plugins.Plugin, result *plugins.EmitNativeResult, opts plugins.Options, irPath string, emitLoss *ir.LossReport, startedAt time.Time) error {
+	output, err := os.ReadFile(result.OutputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read output: %w", err)
+	}
+	irData, err := os.ReadFile(irPath)
+	if err != nil {
+		return fmt.Errorf("failed to read IR: %w", err)
+	}
+
+	e.capMu.Lock()
+	defer e.capMu.Unlock()
+
+	irBlob, err := e.capsule.StoreBlob(irData, "application/json")
+	if err != nil {
+		return fmt.Errorf("failed to store IR: %w", err)
+	}
+	if _, err := e.capsule.StoreBlob(output, ""); err != nil {
+		return fmt.Errorf("failed to store output: %w", err)
+	}
+
+	in := capsule.DerivationInputs{
+		SubjectName:        step.OutputKey,
+		Output:             output,
+		IRSHA256:           irBlob.SHA256,
+		IRBLAKE3:           irBlob.BLAKE3,
+		TargetFormat:       step.TargetFormat,
+		TargetPlugin:       plugin,
+		TargetBinarySHA256: result.BinarySHA256,
+		EmitOptions:        opts,
+		InvocationID:       step.OutputKey,
+		StartedAt:          startedAt,
+		FinishedAt:         time.Now(),
+	}
+	e.mu.Lock()
+	d, ok := e.derivations[step.IRInputKey]
+	e.mu.Unlock()
+	if ok {
+		in.Source = d.source
+		in.SourcePlugin = d.plugin
+		in.SourceBinarySHA256 = d.binaryHash
+		in.ExtractOptions = d.options
+		if d.loss != nil {
+			in.LossReports = append(in.LossReports, d.loss)
+		}
+	}
+	if emitLoss != nil {
+		in.LossReports = append(in.LossReports, emitLoss)
+	}
+
+	stmt, err := capsule.NewDerivedProvenance(in)
+	if err != nil {
+		return err
+	}
+	record, err := e.capsule.AddProvenance(stmt, "")
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.provenance = append(e.provenance, record)
+	e.mu.Unlock()
 	return nil
 }
 
 // executeCompareIRStep executes an IR comparison step.
 func (e *Executor) executeCompareIRStep(step *CompareIRStep) error {
 	// Get IR A
-	irAPath, ok := e.outputs[step.IRAKey]
+	irAPath, ok := e.output(step.IRAKey)
 	if !ok {
 		return fmt.Errorf("IR A not found: %s", step.IRAKey)
 	}
 
 	// Get IR B
-	irBPath, ok := e.outputs[step.IRBKey]
+	irBPath, ok := e.output(step.IRBKey)
 	if !ok {
 		return fmt.Errorf("IR B not found: %s", step.IRBKey)
 	}
@@ -521,15 +850,39 @@ func (e *Executor) executeCompareIRStep(step *CompareIRStep) error {
 		"ir_b_hash": hashB,
 		"match":     hashA == hashB,
 	}
+	if match, ok := sameDocuments(dataA, dataB); ok {
+		result["documents_match"] = match
+	}
 	resultJSON, _ := json.MarshalIndent(result, "", "  ")
 	if err := os.WriteFile(outputPath, resultJSON, 0644); err != nil {
 		return fmt.Errorf("failed to write comparison result: %w", err)
 	}
 
-	e.outputs[step.OutputKey] = outputPath
+	e.setOutput(step.OutputKey, outputPath)
 	return nil
 }
 
+// sameDocuments reports whether two IR corpora hold the same documents,
+// ignoring corpus metadata such as the source format. ok is false when
+// either is not an IR corpus.
+func sameDocuments(dataA, dataB []byte) (match, ok bool) {
+	var a, b ir.Corpus
+	if json.Unmarshal(dataA, &a) != nil || json.Unmarshal(dataB, &b) != nil {
+		return false, false
+	}
+	if len(a.Documents) != len(b.Documents) {
+		return false, true
+	}
+	for i := range a.Documents {
+		ha, errA := ir.HashDocument(a.Documents[i])
+		hb, errB := ir.HashDocument(b.Documents[i])
+		if errA != nil || errB != nil || ha != hb {
+			return false, true
+		}
+	}
+	return true, true
+}
+
 // executeCheck executes a single check.
 func (e *Executor) executeCheck(check *PlanCheck) (*CheckResult, error) {
 	switch check.Type {
@@ -543,6 +896,16 @@ func (e *Executor) executeCheck(check *PlanCheck) (*CheckResult, error) {
 		return e.executeIRRoundtripCheck(check)
 	case CheckIRFidelity:
 		return e.executeIRFidelityCheck(check)
+	case CheckTextEqual:
+		return e.executeTextEqualCheck(check)
+	case CheckVerseCoverage:
+		return e.executeVerseCoverageCheck(check)
+	case CheckXPathAssert:
+		return e.executeXPathAssertCheck(check)
+	case CheckSchemaValid:
+		return e.executeSchemaValidCheck(check)
+	case CheckLossBudget:
+		return e.executeLossBudgetCheck(check)
 	default:
 		return nil, fmt.Errorf("unknown check type: %s", check.Type)
 	}
@@ -557,7 +920,7 @@ func (e *Executor) executeByteEqualCheck(check *PlanCheck) (*CheckResult, error)
 	if !ok {
 		return nil, fmt.Errorf("artifact not found: %s", def.ArtifactA)
 	}
-	dataA, err := e.capsule.GetStore().Retrieve(artifactA.PrimaryBlobSHA256)
+	dataA, err := e.capsule.ReadBlob(artifactA.PrimaryBlobSHA256)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve artifact A: %w", err)
 	}
@@ -574,7 +937,7 @@ func (e *Executor) executeByteEqualCheck(check *PlanCheck) (*CheckResult, error)
 		hashB = cas.Hash(dataB)
 	} else if artifactB, ok := e.capsule.Manifest.Artifacts[def.ArtifactB]; ok {
 		// It's another artifact
-		dataB, err := e.capsule.GetStore().Retrieve(artifactB.PrimaryBlobSHA256)
+		dataB, err := e.capsule.ReadBlob(artifactB.PrimaryBlobSHA256)
 		if err != nil {
 			return nil, fmt.Errorf("failed to retrieve artifact B: %w", err)
 		}
@@ -763,7 +1126,8 @@ func (e *Executor) executeIRFidelityCheck(check *PlanCheck) (*CheckResult, error
 
 	// Apply loss budget if specified
 	if def.LossBudget != nil {
-		budgetResult := def.LossBudget.Check(nil) // Would pass actual LossReport in full impl
+		report, _ := e.lossReport(def.IRKey)
+		budgetResult := def.LossBudget.Check(report)
 		pass = pass && budgetResult.WithinBudget
 		details["within_budget"] = fmt.Sprintf("%v", budgetResult.WithinBudget)
 	}
@@ -789,7 +1153,7 @@ func (c *ByteEqualCheck) Execute(cap *capsule.Capsule) (*CheckResult, error) {
 		return nil, fmt.Errorf("artifact not found: %s", c.ArtifactA)
 	}
 
-	dataA, err := cap.GetStore().Retrieve(artifact.PrimaryBlobSHA256)
+	dataA, err := cap.ReadBlob(artifact.PrimaryBlobSHA256)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve artifact: %w", err)
 	}
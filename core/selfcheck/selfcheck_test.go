@@ -10,6 +10,8 @@ import (
 	"github.com/FocuswithJustin/JuniperBible/core/capsule"
 	"github.com/FocuswithJustin/JuniperBible/core/cas"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+	"github.com/FocuswithJustin/JuniperBible/core/resource"
+	"github.com/FocuswithJustin/JuniperBible/core/runner"
 )
 
 func init() {
@@ -787,7 +789,8 @@ func TestExportStepWithMissingArtifact(t *testing.T) {
 	}
 
 	executor := NewExecutor(cap)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for export with missing artifact")
 	}
@@ -823,7 +826,8 @@ func TestRunToolStepWithoutPluginLoader(t *testing.T) {
 
 	// Without plugin loader, should fail
 	executor := NewExecutor(cap)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for RUN_TOOL step without plugin loader")
 	}
@@ -863,7 +867,8 @@ func TestRunToolStepPluginNotFound(t *testing.T) {
 	// With empty plugin loader, should fail with plugin not found
 	loader := plugins.NewLoader()
 	executor := NewExecutorWithPlugins(cap, loader)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for missing plugin")
 	}
@@ -903,12 +908,87 @@ func TestRunToolStep(t *testing.T) {
 	}
 
 	executor := NewExecutor(cap)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for RUN_TOOL step (requires plugin loader)")
 	}
 }
 
+// TestRunToolStepWithReplayEngine tests RUN_TOOL steps served by an
+// execution engine instead of plugin IPC.
+func TestRunToolStepWithReplayEngine(t *testing.T) {
+	tempDir := t.TempDir()
+	cap, err := capsule.New(filepath.Join(tempDir, "capsule"))
+	if err != nil {
+		t.Fatalf("failed to create capsule: %v", err)
+	}
+	inputPath := filepath.Join(tempDir, "input", "test.txt")
+	os.MkdirAll(filepath.Dir(inputPath), 0755)
+	if err := os.WriteFile(inputPath, []byte("test content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	artifact, err := cap.IngestFile(inputPath)
+	if err != nil {
+		t.Fatalf("failed to ingest: %v", err)
+	}
+
+	// Record the run the replay engine serves
+	req := runner.NewRequest("test-tool", "default")
+	recorded := &runner.ExecutionResult{
+		TranscriptData: []byte(`{"event":"start","plugin":"test-tool","profile":"default"}` + "\n"),
+		OutputBlobs:    map[string][]byte{"out.txt": []byte("output")},
+		Engine:         runner.NewEngineSpec("nix"),
+	}
+	run := &capsule.Run{ID: "run-1", Engine: recorded.Engine.CapsuleEngine(), Status: "completed"}
+	if err := runner.RecordRun(cap, run, req, []string{inputPath}, recorded); err != nil {
+		t.Fatalf("RecordRun failed: %v", err)
+	}
+
+	plan := &Plan{
+		ID: "replay-test",
+		Steps: []PlanStep{
+			{
+				Type: StepRunTool,
+				RunTool: &RunToolStep{
+					ToolPluginID: "test-tool",
+					Profile:      "default",
+					Inputs:       []string{artifact.ID},
+					OutputKey:    "tool",
+				},
+			},
+		},
+		Checks: []PlanCheck{
+			{
+				Type:            CheckTranscriptEqual,
+				Label:           "replayed transcript",
+				TranscriptEqual: &TranscriptEqualDef{RunA: "run-1", RunB: "tool_transcript"},
+			},
+		},
+	}
+
+	executor := NewExecutor(cap)
+	executor.Tools = runner.NewReplayExecutor(cap)
+	report, err := executor.Execute(plan)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if report.Status != StatusPass {
+		t.Fatalf("expected pass, got %+v", report)
+	}
+	if report.Engine == nil || report.Engine.EngineID != "replay/replay" {
+		t.Errorf("unexpected engine %+v", report.Engine)
+	}
+
+	// An unrecorded profile fails the step loudly
+	plan.Steps[0].RunTool.Profile = "other"
+	report, err = executor.Execute(plan)
+	err = failedStep(t, report, err)
+	if err == nil || !strings.Contains(err.Error(), "no recorded run") {
+		t.Errorf("expected a replay mismatch, got %v", err)
+	}
+}
+
 // TestExtractIRStep tests EXTRACT_IR step with fallback placeholder.
 func TestExtractIRStep(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "selfcheck-test-*")
@@ -990,7 +1070,8 @@ func TestExtractIRStepMissingArtifact(t *testing.T) {
 	}
 
 	executor := NewExecutor(cap)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for missing artifact in EXTRACT_IR step")
 	}
@@ -1085,7 +1166,8 @@ func TestEmitNativeStepMissingIR(t *testing.T) {
 	}
 
 	executor := NewExecutor(cap)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for missing IR input in EMIT_NATIVE step")
 	}
@@ -1188,7 +1270,8 @@ func TestCompareIRStepMissingIR(t *testing.T) {
 	}
 
 	executor := NewExecutor(cap)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for missing IR in COMPARE_IR step")
 	}
@@ -1840,7 +1923,8 @@ func TestExportStepDerivedMode(t *testing.T) {
 	}
 
 	executor := NewExecutor(cap)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for DERIVED mode (not implemented)")
 	}
@@ -2296,7 +2380,8 @@ func TestCompareIRStepReadError(t *testing.T) {
 	}
 
 	executor := NewExecutor(cap)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for missing IR B in compare")
 	}
@@ -2972,7 +3057,8 @@ func TestRunToolStepNotToolPlugin(t *testing.T) {
 	}
 
 	executor := NewExecutorWithPlugins(cap, loader)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for non-tool plugin")
 	}
@@ -3024,7 +3110,8 @@ func TestRunToolStepInputNotFound(t *testing.T) {
 	}
 
 	executor := NewExecutorWithPlugins(cap, loader)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for missing input")
 	}
@@ -3081,7 +3168,49 @@ func TestRunToolStepSuccess(t *testing.T) {
 		t.Fatalf("expected success, got error: %v", err)
 	}
 	if report == nil {
-		t.Error("expected report, got nil")
+		t.Fatal("expected report, got nil")
+	}
+	// The plugin process is measured like an engine run
+	if usage := report.Steps[0].Resources; usage == nil || usage.Limits == nil {
+		t.Errorf("expected the step to record resources, got %+v", usage)
+	}
+	if _, ok := executor.output("tool_output_engine_transcript"); !ok {
+		t.Error("expected an engine transcript output")
+	}
+}
+
+// TestRecordUsageEngineTranscript tests the engine transcript of a tool step.
+func TestRecordUsageEngineTranscript(t *testing.T) {
+	executor := NewExecutor(nil)
+	executor.tempDir = t.TempDir()
+	step := &RunToolStep{ToolPluginID: "tool-plugin", Profile: "test-profile", OutputKey: "tool_output"}
+	result := &runner.ExecutionResult{
+		Usage:        &resource.Usage{UserCPUMs: 42, WallMs: 50},
+		Engine:       runner.NewEngineSpec("plugin"),
+		PluginSHA256: "abc123",
+	}
+	if err := executor.recordUsage(step, result); err != nil {
+		t.Fatalf("recordUsage failed: %v", err)
+	}
+
+	path, ok := executor.output("tool_output_engine_transcript")
+	if !ok {
+		t.Fatal("expected an engine transcript output")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := runner.ParseTranscriptData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != runner.EventEngineResources || events[0].PluginID != "tool-plugin" ||
+		events[0].Attributes["user_cpu_ms"] != float64(42) {
+		t.Errorf("unexpected engine transcript %+v", events)
+	}
+	if got := executor.toolRun(&PlanStep{RunTool: step}); got != result {
+		t.Errorf("toolRun = %+v, want %+v", got, result)
 	}
 }
 
@@ -3367,7 +3496,8 @@ echo '{"status":"error","error":"intentional test error"}'
 	}
 
 	executor := NewExecutorWithPlugins(cap, loader)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for tool error response")
 	}
@@ -3638,7 +3768,8 @@ func TestRunToolStepExportFailure(t *testing.T) {
 	}
 
 	executor := NewExecutorWithPlugins(cap, loader)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for export failure")
 	}
@@ -3713,7 +3844,8 @@ echo '{"status":"ok"}'
 	}
 
 	executor := NewExecutorWithPlugins(cap, loader)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for plugin execution failure")
 	}
@@ -3874,7 +4006,8 @@ func TestExtractIRStepExportFailure(t *testing.T) {
 	}
 
 	executor := NewExecutor(cap)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for export failure")
 	}
@@ -4069,7 +4202,8 @@ func TestExtractIRStepPluginExecutionError(t *testing.T) {
 	}
 
 	executor := NewExecutorWithPlugins(cap, loader)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for plugin execution failure")
 	}
@@ -4160,7 +4294,8 @@ echo '{"status":"error","error":"intentional test error"}'
 	}
 
 	executor := NewExecutorWithPlugins(cap, loader)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for parse failure")
 	}
@@ -4237,6 +4372,92 @@ func TestEmitNativeStepWithPlugin(t *testing.T) {
 	}
 }
 
+// TestEmitNativeStepRecordsProvenance tests that plugin-emitted outputs are attested.
+func TestEmitNativeStepRecordsProvenance(t *testing.T) {
+	tempDir := t.TempDir()
+
+	pluginDir := filepath.Join(tempDir, "plugins")
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		t.Fatalf("failed to create plugins dir: %v", err)
+	}
+	createTestPluginWithIRSupport(t, pluginDir, "ir-plugin", true, true)
+
+	loader := plugins.NewLoader()
+	if err := loader.LoadFromDir(pluginDir); err != nil {
+		t.Fatalf("failed to load plugins: %v", err)
+	}
+
+	cap, err := capsule.New(filepath.Join(tempDir, "capsule"))
+	if err != nil {
+		t.Fatalf("failed to create capsule: %v", err)
+	}
+	testFilePath := filepath.Join(tempDir, "test-input.txt")
+	if err := os.WriteFile(testFilePath, []byte("test content"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	artifact, err := cap.IngestFile(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to ingest artifact: %v", err)
+	}
+
+	plan := &Plan{
+		ID: "provenance-test",
+		Steps: []PlanStep{
+			{
+				Type: StepExtractIR,
+				ExtractIR: &ExtractIRStep{
+					SourceArtifactID: artifact.ID,
+					PluginID:         "ir-plugin",
+					OutputKey:        "ir_output",
+				},
+			},
+			{
+				Type: StepEmitNative,
+				EmitNative: &EmitNativeStep{
+					IRInputKey:   "ir_output",
+					PluginID:     "ir-plugin",
+					TargetFormat: "test",
+					OutputKey:    "native_output",
+				},
+			},
+		},
+	}
+
+	// Provenance is only recorded when asked for
+	report, err := NewExecutorWithPlugins(cap, loader).Execute(plan)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if len(report.Provenance) != 0 || len(cap.Manifest.Provenance) != 0 {
+		t.Fatalf("expected no provenance by default, got %d", len(report.Provenance))
+	}
+
+	executor := NewExecutorWithPlugins(cap, loader)
+	executor.RecordProvenance = true
+	report, err = executor.Execute(plan)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if len(report.Provenance) != 1 {
+		t.Fatalf("expected 1 provenance record, got %d", len(report.Provenance))
+	}
+
+	stmt, err := cap.GetProvenance(report.Provenance[0].ID)
+	if err != nil {
+		t.Fatalf("GetProvenance failed: %v", err)
+	}
+	materials := stmt.Predicate.BuildDefinition.ResolvedDependencies
+	if len(materials) != 2 || materials[0].Digest["sha256"] != artifact.Hashes.SHA256 {
+		t.Errorf("expected source artifact as first material, got %+v", materials)
+	}
+
+	for _, v := range cap.VerifyProvenance() {
+		if !v.OK() {
+			t.Errorf("provenance %s failed verification: %v", v.RecordID, v.Errors)
+		}
+	}
+}
+
 // TestEmitNativeStepMkdirError tests EMIT_NATIVE when output directory creation fails.
 func TestEmitNativeStepMkdirError(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "selfcheck-test-*")
@@ -4461,7 +4682,8 @@ func TestEmitNativeStepPluginExecutionError(t *testing.T) {
 	}
 
 	executor := NewExecutorWithPlugins(cap, loader)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for plugin execution failure")
 	}
@@ -4558,7 +4780,8 @@ echo '{"status":"error","error":"intentional test error"}'
 	}
 
 	executor := NewExecutorWithPlugins(cap, loader)
-	_, err = executor.Execute(plan)
+	report, err := executor.Execute(plan)
+	err = failedStep(t, report, err)
 	if err == nil {
 		t.Error("expected error for parse failure")
 	}
@@ -5331,3 +5554,90 @@ func TestByteEqualCheckValidatorRetrieveError(t *testing.T) {
 		t.Errorf("expected 'failed to retrieve artifact' error, got: %v", err)
 	}
 }
+
+// TestEmitNativeStepOptions tests that step options are validated against
+// the plugin manifest and recorded in provenance.
+func TestEmitNativeStepOptions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	pluginDir := filepath.Join(tempDir, "plugins")
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		t.Fatalf("failed to create plugins dir: %v", err)
+	}
+	createTestPluginWithIRSupport(t, pluginDir, "ir-plugin", true, true)
+
+	loader := plugins.NewLoader()
+	if err := loader.LoadFromDir(pluginDir); err != nil {
+		t.Fatalf("failed to load plugins: %v", err)
+	}
+	plugin, err := loader.GetPlugin("ir-plugin")
+	if err != nil {
+		t.Fatalf("failed to get plugin: %v", err)
+	}
+	plugin.Manifest.Options = []plugins.OptionSpec{
+		{Name: "layout", Type: plugins.OptionEnum, Values: []string{"single", "chapter"}, Default: "single", Commands: []string{"emit-native"}},
+	}
+
+	cap, err := capsule.New(filepath.Join(tempDir, "capsule"))
+	if err != nil {
+		t.Fatalf("failed to create capsule: %v", err)
+	}
+	testFilePath := filepath.Join(tempDir, "test-input.txt")
+	if err := os.WriteFile(testFilePath, []byte("test content"), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	artifact, err := cap.IngestFile(testFilePath)
+	if err != nil {
+		t.Fatalf("failed to ingest artifact: %v", err)
+	}
+
+	newPlan := func(layout string) *Plan {
+		return &Plan{
+			ID: "options-test",
+			Steps: []PlanStep{
+				{
+					Type: StepExtractIR,
+					ExtractIR: &ExtractIRStep{
+						SourceArtifactID: artifact.ID,
+						PluginID:         "ir-plugin",
+						OutputKey:        "ir_output",
+					},
+				},
+				{
+					Type: StepEmitNative,
+					EmitNative: &EmitNativeStep{
+						IRInputKey:   "ir_output",
+						PluginID:     "ir-plugin",
+						TargetFormat: "test",
+						OutputKey:    "native_output",
+						Options:      map[string]interface{}{"layout": layout},
+					},
+				},
+			},
+		}
+	}
+
+	executor := NewExecutorWithPlugins(cap, loader)
+	executor.RecordProvenance = true
+	report, err := executor.Execute(newPlan("chapter"))
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if len(report.Provenance) != 1 {
+		t.Fatalf("expected 1 provenance record, got %d", len(report.Provenance))
+	}
+	stmt, err := cap.GetProvenance(report.Provenance[0].ID)
+	if err != nil {
+		t.Fatalf("GetProvenance failed: %v", err)
+	}
+	emitOpts, ok := stmt.Predicate.BuildDefinition.ExternalParameters["emit_options"].(map[string]interface{})
+	if !ok || emitOpts["layout"] != "chapter" {
+		t.Errorf("expected emit_options with layout chapter, got %v", stmt.Predicate.BuildDefinition.ExternalParameters["emit_options"])
+	}
+
+	report, err = NewExecutorWithPlugins(cap, loader).Execute(newPlan("book"))
+	stepErr := failedStep(t, report, err)
+	if stepErr == nil || !strings.Contains(stepErr.Error(), "layout") {
+		t.Errorf("expected invalid layout to fail the step, got %v", stepErr)
+	}
+}
@@ -0,0 +1,234 @@
+package selfcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/reporter"
+)
+
+// Suite converts a report into a test suite for the JUnit, TAP and SARIF
+// reporters. Each step and check becomes a test case; failed checks with
+// located evidence also carry findings. plan is the plan that produced the
+// report and names the inputs findings are located in; it may be nil.
+func (r *Report) Suite(plan *Plan) *reporter.Suite {
+	suite := reporter.NewSuite("selfcheck " + r.PlanID)
+	if t, err := time.Parse(time.RFC3339, r.CreatedAt); err == nil {
+		suite.Timestamp = t
+	}
+
+	for _, step := range r.Steps {
+		label := step.Label
+		if label == "" {
+			label = step.Type
+		}
+		c := reporter.Case{
+			Name:      fmt.Sprintf("step %d: %s", step.Index, label),
+			ClassName: "selfcheck." + r.PlanID + ".steps",
+			Status:    caseStatus(step.Status),
+			Message:   step.Error,
+			Duration:  time.Duration(step.DurationMS) * time.Millisecond,
+		}
+		suite.Add(c)
+	}
+
+	for i, result := range r.Results {
+		name := result.Label
+		if name == "" {
+			name = result.CheckType
+		}
+		c := reporter.Case{
+			Name:      name,
+			ClassName: "selfcheck." + r.PlanID + "." + result.CheckType,
+			Status:    reporter.StatusPass,
+		}
+		if !result.Pass {
+			c.Status = reporter.StatusFail
+			c.Message = checkMessage(&result)
+			c.Output = checkOutput(&result)
+			var check *PlanCheck
+			if plan != nil && i < len(plan.Checks) {
+				check = &plan.Checks[i]
+			}
+			c.Findings = checkFindings(check, &result)
+		}
+		suite.Add(c)
+	}
+	return suite
+}
+
+// caseStatus maps a step status to a test case status.
+func caseStatus(status string) string {
+	switch status {
+	case StatusPass:
+		return reporter.StatusPass
+	case StatusSkipped:
+		return reporter.StatusSkipped
+	default:
+		return reporter.StatusFail
+	}
+}
+
+// checkMessage summarizes why a check failed.
+func checkMessage(result *CheckResult) string {
+	switch d := result.Details.(type) {
+	case map[string]string:
+		if d["error"] != "" {
+			return d["error"]
+		}
+	case *TextEqualEvidence:
+		if d.Difference != nil {
+			return fmt.Sprintf("texts differ at line %d, column %d", d.Difference.Line, d.Difference.Column)
+		}
+		return "texts differ"
+	case *VerseCoverageEvidence:
+		if d.Error != "" {
+			return d.Error
+		}
+		return fmt.Sprintf("%d missing and %d extra verses", d.MissingCount, d.ExtraCount)
+	case *XPathEvidence:
+		if len(d.Failures) > 0 {
+			return d.Failures[0]
+		}
+	case *SchemaEvidence:
+		return fmt.Sprintf("%d %s schema error(s)", d.ErrorCount, d.Schema)
+	case *LossBudgetEvidence:
+		if len(d.Violations) > 0 {
+			return d.Violations[0]
+		}
+	}
+	if result.Expected != nil && result.Actual != nil {
+		return fmt.Sprintf("expected sha256 %s, got %s", result.Expected.SHA256, result.Actual.SHA256)
+	}
+	return "check failed"
+}
+
+// checkOutput renders the evidence of a failed check.
+func checkOutput(result *CheckResult) string {
+	if result.Details == nil {
+		return ""
+	}
+	data, err := json.MarshalIndent(result.Details, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// checkFindings returns the findings of a failed check that validates an
+// input: schema errors, XPath assertion failures, verse coverage gaps,
+// text differences and loss budget violations.
+func checkFindings(check *PlanCheck, result *CheckResult) []reporter.Finding {
+	finding := func(file, format string, args ...interface{}) reporter.Finding {
+		return reporter.Finding{
+			RuleID:  result.CheckType,
+			Level:   reporter.LevelError,
+			Message: fmt.Sprintf(format, args...),
+			File:    file,
+		}
+	}
+
+	var findings []reporter.Finding
+	switch d := result.Details.(type) {
+	case *SchemaEvidence:
+		var file string
+		if check != nil && check.SchemaValid != nil {
+			file = check.SchemaValid.Input
+		}
+		for i, msg := range d.Errors {
+			f := finding(file, "%s", msg)
+			f.RuleID += "/" + d.Schema
+			for _, pos := range d.Positions {
+				if pos.Error != i {
+					continue
+				}
+				if pos.Entry != "" {
+					f.File = path.Join(file, pos.Entry)
+				}
+				f.Line, f.Column = pos.Line, pos.Column
+			}
+			findings = append(findings, f)
+		}
+	case *XPathEvidence:
+		var file string
+		if check != nil && check.XPathAssert != nil {
+			file = check.XPathAssert.Input
+		}
+		for _, msg := range d.Failures {
+			findings = append(findings, finding(file, "%s: %s", d.XPath, msg))
+		}
+	case *VerseCoverageEvidence:
+		var file string
+		if check != nil && check.VerseCoverage != nil {
+			file = check.VerseCoverage.Input
+		}
+		if d.Error != "" {
+			findings = append(findings, finding(file, "%s", d.Error))
+		}
+		if len(d.Missing) > 0 {
+			findings = append(findings, finding(file, "missing verses: %s", strings.Join(d.Missing, ", ")))
+		}
+		if len(d.Extra) > 0 {
+			findings = append(findings, finding(file, "verses not in %s: %s", d.Versification, strings.Join(d.Extra, ", ")))
+		}
+	case *TextEqualEvidence:
+		var file string
+		if check != nil && check.TextEqual != nil {
+			file = check.TextEqual.B
+		}
+		f := finding(file, "text differs")
+		if diff := d.Difference; diff != nil {
+			f.Message = fmt.Sprintf("text differs: expected %q, got %q", diff.Expected, diff.Actual)
+			// Positions are in the normalized text, so they only locate
+			// the difference in the file when nothing was normalized
+			if len(d.Normalize) == 0 {
+				f.Line, f.Column = diff.Line, diff.Column
+			}
+		}
+		findings = append(findings, f)
+	case *LossBudgetEvidence:
+		var file string
+		if check != nil && check.LossBudget != nil {
+			file = check.LossBudget.Input
+		}
+		for _, msg := range d.Violations {
+			findings = append(findings, finding(file, "%s", msg))
+		}
+	}
+	return findings
+}
+
+// Suite converts a loss matrix into a test suite with a test case per
+// plugin pair and a finding per plugin exceeding its declared loss.
+func (m *Matrix) Suite() *reporter.Suite {
+	suite := reporter.NewSuite("selfcheck matrix")
+	for _, cell := range m.Cells {
+		c := reporter.Case{
+			Name:      fmt.Sprintf("%s -> %s", cell.Source, cell.Target),
+			ClassName: "selfcheck.matrix." + cell.Source,
+			Status:    reporter.StatusPass,
+			Output:    fmt.Sprintf("measured %s, declared %s", cell.Measured, cell.Declared),
+		}
+		if cell.Error != "" {
+			c.Status = reporter.StatusError
+			c.Message = cell.Error
+		} else if cell.Status != StatusPass {
+			c.Status = reporter.StatusFail
+			c.Message = fmt.Sprintf("loss exceeds the declared %s", cell.Declared)
+		}
+		for _, v := range m.Violations {
+			if v.Source == cell.Source && v.Target == cell.Target {
+				c.Findings = append(c.Findings, reporter.Finding{
+					RuleID:  "LOSS_EXCEEDS_DECLARED",
+					Level:   reporter.LevelError,
+					Message: fmt.Sprintf("%s (%s -> %s, %s): %s", v.PluginID, v.Source, v.Target, v.Stage, v.Reason),
+				})
+			}
+		}
+		suite.Add(c)
+	}
+	return suite
+}
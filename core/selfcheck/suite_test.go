@@ -0,0 +1,48 @@
+package selfcheck
+
+import (
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/reporter"
+)
+
+func TestReportSuite(t *testing.T) {
+	cap, ids := newChecksTestCapsule(t, map[string][]byte{
+		"bad.osis.xml": []byte("<osis>\n  <osisText>\n</osis>"),
+		"good.txt":     []byte("text"),
+	})
+	plan := &Plan{
+		ID: "suite",
+		Steps: []PlanStep{
+			{Type: StepExport, Export: &ExportStep{Mode: "IDENTITY", ArtifactID: ids["good.txt"], OutputKey: "copy"}, Label: "Export"},
+		},
+		Checks: []PlanCheck{
+			{Type: CheckByteEqual, Label: "Bytes", ByteEqual: &ByteEqualDef{ArtifactA: ids["good.txt"], ArtifactB: "copy"}},
+			{Type: CheckSchemaValid, Label: "OSIS", SchemaValid: &SchemaValidDef{Input: ids["bad.osis.xml"], Schema: SchemaOSIS}},
+		},
+	}
+	report, err := NewExecutor(cap).Execute(plan)
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+
+	suite := report.Suite(plan)
+	if len(suite.Cases) != 3 {
+		t.Fatalf("expected a case per step and check, got %d", len(suite.Cases))
+	}
+	if suite.Cases[0].Name != "step 1: Export" || suite.Cases[0].Status != reporter.StatusPass {
+		t.Errorf("unexpected step case %+v", suite.Cases[0])
+	}
+	if suite.Cases[1].Status != reporter.StatusPass {
+		t.Errorf("unexpected byte check case %+v", suite.Cases[1])
+	}
+
+	schema := suite.Cases[2]
+	if schema.Status != reporter.StatusFail || len(schema.Findings) == 0 {
+		t.Fatalf("expected failing schema case with findings: %+v", schema)
+	}
+	f := schema.Findings[0]
+	if f.RuleID != "SCHEMA_VALID/osis" || f.File != ids["bad.osis.xml"] || f.Line != 3 {
+		t.Errorf("unexpected finding %+v", f)
+	}
+}
@@ -82,10 +82,11 @@ func Validate(data []byte, schema []byte) ValidationResult {
 			break
 		}
 		if err != nil {
+			line, column := decoder.InputPos()
 			result.Valid = false
 			result.Errors = append(result.Errors, ValidationError{
-				Line:    1, // xml.Decoder doesn't provide line numbers easily
-				Column:  0,
+				Line:    line,
+				Column:  column,
 				Message: err.Error(),
 			})
 			break
@@ -289,6 +290,44 @@ func (d *Document) XPathFirst(expr string) (*Node, error) {
 	return &Node{node: node}, nil
 }
 
+// Evaluate evaluates an XPath expression. The result is a bool, float64
+// or string for scalar expressions such as count(//verse) or
+// boolean(//title), and []*Node for node sets.
+func (d *Document) Evaluate(expr string) (interface{}, error) {
+	compiled, err := xpath.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath: %w", err)
+	}
+
+	switch v := compiled.Evaluate(xmlquery.CreateXPathNavigator(d.root)).(type) {
+	case *xpath.NodeIterator:
+		var nodes []*Node
+		for v.MoveNext() {
+			nav, ok := v.Current().(*xmlquery.NodeNavigator)
+			if !ok {
+				continue
+			}
+			if nav.NodeType() == xpath.AttributeNode {
+				// Attributes have no node of their own; build one as
+				// xmlquery.QueryAll does
+				text := &xmlquery.Node{Type: xmlquery.TextNode, Data: nav.Value()}
+				nodes = append(nodes, &Node{node: &xmlquery.Node{
+					Parent:     nav.Current(),
+					Type:       xmlquery.AttributeNode,
+					Data:       nav.LocalName(),
+					FirstChild: text,
+					LastChild:  text,
+				}})
+				continue
+			}
+			nodes = append(nodes, &Node{node: nav.Current()})
+		}
+		return nodes, nil
+	default:
+		return v, nil
+	}
+}
+
 // Serialize converts the document back to XML bytes.
 func (d *Document) Serialize() []byte {
 	if d.root == nil {
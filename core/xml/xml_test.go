@@ -472,6 +472,18 @@ func TestValidateMalformed(t *testing.T) {
 	}
 }
 
+// TestValidateErrorPosition verifies errors report where parsing stopped.
+func TestValidateErrorPosition(t *testing.T) {
+	malformed := "<root>\n  <a></b>\n</root>"
+	result := Validate([]byte(malformed), nil)
+	if result.Valid || len(result.Errors) != 1 {
+		t.Fatalf("expected one error, got %+v", result)
+	}
+	if result.Errors[0].Line != 2 || result.Errors[0].Column == 0 {
+		t.Errorf("error at line %d column %d, want line 2", result.Errors[0].Line, result.Errors[0].Column)
+	}
+}
+
 // TestFormatDefaultIndent verifies default indentation when none specified.
 func TestFormatDefaultIndent(t *testing.T) {
 	xmlData := `<root><child/></root>`
@@ -1441,3 +1453,33 @@ func TestFormatVeryDeeplyNested(t *testing.T) {
 		t.Error("Formatted XML should preserve deep content")
 	}
 }
+
+func TestEvaluate(t *testing.T) {
+	doc, err := Parse([]byte(`<root><verse n="1">a</verse><verse n="2">b</verse></root>`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if v, err := doc.Evaluate("count(//verse)"); err != nil || v != float64(2) {
+		t.Errorf("count = %v, %v; want 2", v, err)
+	}
+	if v, err := doc.Evaluate("//verse[@n='2'] = 'b'"); err != nil || v != true {
+		t.Errorf("comparison = %v, %v; want true", v, err)
+	}
+	if v, err := doc.Evaluate("string(//verse[1])"); err != nil || v != "a" {
+		t.Errorf("string = %v, %v; want a", v, err)
+	}
+
+	v, err := doc.Evaluate("//verse/@n")
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	nodes, ok := v.([]*Node)
+	if !ok || len(nodes) != 2 || nodes[1].Text() != "2" {
+		t.Errorf("node set = %#v; want two attribute nodes", v)
+	}
+
+	if _, err := doc.Evaluate("//verse["); err == nil {
+		t.Error("expected error for invalid expression")
+	}
+}
@@ -11,6 +11,8 @@ type Config struct {
 	Auth              AuthConfig // Authentication configuration
 	TLS               TLSConfig  // TLS configuration
 	AllowedOrigins    []string   // CORS allowed origins (empty = allow all)
+	LicensePolicy     string     // License policy file (default: built-in policy)
+	LicenseLog        string     // License override log
 }
 
 // TLSConfig holds TLS/HTTPS configuration.
@@ -2,6 +2,7 @@ package api
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
@@ -15,10 +16,18 @@ import (
 
 	"github.com/ulikunitz/xz"
 
+	corecapsule "github.com/FocuswithJustin/JuniperBible/core/capsule"
+	"github.com/FocuswithJustin/JuniperBible/core/detect"
 	"github.com/FocuswithJustin/JuniperBible/core/errors"
+	"github.com/FocuswithJustin/JuniperBible/core/license"
+	"github.com/FocuswithJustin/JuniperBible/internal/archive"
 	"github.com/FocuswithJustin/JuniperBible/internal/validation"
 )
 
+// licenses is the license policy applied to downloads. Start replaces it
+// when a policy or override log is configured.
+var licenses = &license.Enforcer{Policy: license.DefaultPolicy()}
+
 // APIResponse is the standard API response wrapper.
 type APIResponse struct {
 	Success bool        `json:"success"`
@@ -49,6 +58,7 @@ type CapsuleInfo struct {
 	CreatedAt string           `json:"created_at,omitempty"`
 	Manifest  *CapsuleManifest `json:"manifest,omitempty"`
 	Artifacts []ArtifactInfo   `json:"artifacts,omitempty"`
+	License   *license.Info    `json:"license,omitempty"`
 }
 
 // CapsuleManifest is the manifest.json structure.
@@ -90,7 +100,9 @@ type FormatInfo struct {
 	CanEmit     bool     `json:"can_emit"`
 }
 
-// ConvertRequest is the request body for conversion.
+// ConvertRequest is the request body for conversion. Options are the
+// emit-native options of the target format's plugin, as declared in its
+// manifest.
 type ConvertRequest struct {
 	Source       string                 `json:"source"`
 	TargetFormat string                 `json:"target_format"`
@@ -270,7 +282,7 @@ func createCapsuleHandler(w http.ResponseWriter, r *http.Request) {
 		Name:   header.Filename,
 		Path:   safePath,
 		Size:   written,
-		Format: detectFormat(header.Filename),
+		Format: detectFormat(destPath),
 	}
 
 	BroadcastComplete("upload", "Upload completed successfully", map[string]interface{}{
@@ -329,12 +341,23 @@ func getCapsuleHandler(w http.ResponseWriter, r *http.Request, id string) {
 		return
 	}
 
+	capsuleLicense, err := corecapsule.CachedLicense(capsulePath)
+	if err != nil {
+		capsuleLicense = &license.Info{ID: license.NoAssertion}
+	}
+
+	if r.URL.Query().Get("download") == "true" {
+		downloadCapsule(w, id, capsulePath, capsuleLicense)
+		return
+	}
+
 	capsule := CapsuleInfo{
-		ID:     id,
-		Name:   id,
-		Path:   id,
-		Size:   info.Size(),
-		Format: detectFormat(id),
+		ID:      id,
+		Name:    id,
+		Path:    id,
+		Size:    info.Size(),
+		Format:  detectFormat(capsulePath),
+		License: capsuleLicense,
 	}
 
 	// Read manifest and artifacts
@@ -347,6 +370,30 @@ func getCapsuleHandler(w http.ResponseWriter, r *http.Request, id string) {
 	respond(w, http.StatusOK, capsule)
 }
 
+// downloadCapsule streams a capsule archive if its license permits.
+func downloadCapsule(w http.ResponseWriter, id, capsulePath string, info *license.Info) {
+	verdict, err := licenses.Enforce(id, info, license.ActionDownload, "")
+	if err != nil {
+		respondError(w, http.StatusForbidden, "LICENSE_BLOCKED", err.Error())
+		return
+	}
+
+	f, err := os.Open(capsulePath)
+	if err != nil {
+		ioErr := errors.NewIO("open", capsulePath, err)
+		respondError(w, http.StatusInternalServerError, "READ_FAILED", ioErr.Error())
+		return
+	}
+	defer f.Close()
+
+	if verdict.Decision == license.DecisionWarn {
+		w.Header().Set("X-License-Warning", verdict.License+": "+verdict.Reason)
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(capsulePath)))
+	io.Copy(w, f)
+}
+
 func deleteCapsuleHandler(w http.ResponseWriter, r *http.Request, id string) {
 	// Validate and sanitize ID to prevent path traversal
 	// ValidatePath provides comprehensive protection
@@ -391,6 +438,11 @@ func handleConvert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateConvertOptions(req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_OPTIONS", err.Error())
+		return
+	}
+
 	// Conversion not yet implemented via API
 	unsupportedErr := errors.NewUnsupported("API conversion", "not yet implemented via API, use the CLI")
 	respondError(w, http.StatusNotImplemented, "NOT_IMPLEMENTED", unsupportedErr.Error())
@@ -425,7 +477,7 @@ func handleFormats(w http.ResponseWriter, r *http.Request) {
 
 	formats := []FormatInfo{
 		{ID: "osis", Name: "OSIS XML", Extensions: []string{".osis", ".xml"}, LossClass: "L0", Description: "Open Scripture Information Standard", CanExtract: true, CanEmit: true},
-		{ID: "usfm", Name: "USFM", Extensions: []string{".usfm", ".sfm"}, LossClass: "L0", Description: "Unified Standard Format Markers", CanExtract: true, CanEmit: true},
+		{ID: "usfm", Name: "USFM", Extensions: []string{".usfm", ".sfm"}, LossClass: "L1", Description: "Unified Standard Format Markers", CanExtract: true, CanEmit: true},
 		{ID: "usx", Name: "USX", Extensions: []string{".usx"}, LossClass: "L0", Description: "Unified Scripture XML", CanExtract: true, CanEmit: true},
 		{ID: "zefania", Name: "Zefania XML", Extensions: []string{".xml"}, LossClass: "L0", Description: "Zefania Bible format", CanExtract: true, CanEmit: true},
 		{ID: "theword", Name: "TheWord", Extensions: []string{".ont", ".nt", ".twm"}, LossClass: "L0", Description: "TheWord Bible software", CanExtract: true, CanEmit: true},
@@ -466,7 +518,10 @@ func listCapsules() []CapsuleInfo {
 		}
 
 		ext := filepath.Ext(path)
-		if ext == ".xz" || ext == ".gz" || ext == ".tar" {
+		if ext == ".zip" && !archive.IsZipCapsule(path) {
+			return nil
+		}
+		if ext == ".xz" || ext == ".gz" || ext == ".tar" || ext == ".zip" {
 			rel, _ := filepath.Rel(ServerConfig.CapsulesDir, path)
 			capsules = append(capsules, CapsuleInfo{
 				ID:        rel,
@@ -521,20 +576,17 @@ func listPlugins() []PluginInfo {
 	return plugins
 }
 
+// detectFormat returns the archive format of a capsule, read from its
+// content when it exists and from its extension otherwise.
 func detectFormat(path string) string {
-	if strings.HasSuffix(path, ".tar.xz") {
-		return "tar.xz"
-	}
-	if strings.HasSuffix(path, ".tar.gz") {
-		return "tar.gz"
-	}
-	if strings.HasSuffix(path, ".tar") {
-		return "tar"
-	}
-	return "unknown"
+	return detect.ContainerFormat(path)
 }
 
 func readCapsule(path string) (*CapsuleManifest, []ArtifactInfo, error) {
+	if strings.HasSuffix(path, ".zip") {
+		return readZipCapsule(path)
+	}
+
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, nil, err
@@ -592,6 +644,43 @@ func readCapsule(path string) (*CapsuleManifest, []ArtifactInfo, error) {
 	return manifest, artifacts, nil
 }
 
+// readZipCapsule reads the manifest and entry list of a zip capsule.
+func readZipCapsule(path string) (*CapsuleManifest, []ArtifactInfo, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zr.Close()
+
+	var manifest *CapsuleManifest
+	var artifacts []ArtifactInfo
+	for _, f := range zr.File {
+		if f.Name == "manifest.json" {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, nil, err
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, nil, err
+			}
+			manifest = &CapsuleManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, err
+			}
+		} else if !f.FileInfo().IsDir() {
+			artifacts = append(artifacts, ArtifactInfo{
+				ID:   f.Name,
+				Name: filepath.Base(f.Name),
+				Size: int64(f.UncompressedSize64),
+			})
+		}
+	}
+
+	return manifest, artifacts, nil
+}
+
 func respond(w http.ResponseWriter, status int, data interface{}) {
 	response := APIResponse{
 		Success: true,
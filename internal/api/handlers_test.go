@@ -16,6 +16,7 @@ import (
 
 	"github.com/ulikunitz/xz"
 
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 	"github.com/FocuswithJustin/JuniperBible/internal/server"
 )
 
@@ -857,6 +858,47 @@ func TestHandleConvertNotImplemented(t *testing.T) {
 	}
 }
 
+func TestHandleConvertOptions(t *testing.T) {
+	plugins.RegisterEmbeddedPlugin(&plugins.EmbeddedPlugin{
+		Manifest: &plugins.PluginManifest{
+			PluginID: "format.convertoptiontest",
+			Kind:     "format",
+			Options: []plugins.OptionSpec{
+				{Name: "layout", Type: plugins.OptionEnum, Values: []string{"single", "chapter"}, Commands: []string{"emit-native"}},
+			},
+		},
+	})
+
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"valid", `{"source":"test.osis","target_format":"convertoptiontest","options":{"layout":"chapter"}}`, "NOT_IMPLEMENTED"},
+		{"invalid value", `{"source":"test.osis","target_format":"convertoptiontest","options":{"layout":"book"}}`, "INVALID_OPTIONS"},
+		{"unknown option", `{"source":"test.osis","target_format":"convertoptiontest","options":{"width":80}}`, "INVALID_OPTIONS"},
+		{"unknown format", `{"source":"test.osis","target_format":"nosuchformat","options":{"layout":"chapter"}}`, "INVALID_OPTIONS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handleConvert(w, req)
+
+			var apiResp APIResponse
+			if err := json.NewDecoder(w.Result().Body).Decode(&apiResp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if apiResp.Error == nil || apiResp.Error.Code != tt.code {
+				t.Errorf("expected %s error, got %+v", tt.code, apiResp.Error)
+			}
+		})
+	}
+}
+
 func TestDetectFormat(t *testing.T) {
 	tests := []struct {
 		path     string
@@ -865,7 +907,8 @@ func TestDetectFormat(t *testing.T) {
 		{"test.tar.xz", "tar.xz"},
 		{"test.tar.gz", "tar.gz"},
 		{"test.tar", "tar"},
-		{"test.zip", "unknown"},
+		{"test.zip", "zip"},
+		{"test.rar", "unknown"},
 		{"archive.tar.xz", "tar.xz"},
 		{"data.tar.gz", "tar.gz"},
 	}
@@ -1600,3 +1643,81 @@ func createTestCapsuleWithInvalidManifest(t *testing.T, path string) {
 
 	io.Copy(xzWriter, &tarBuf)
 }
+
+// createLicensedCapsuleGZ creates a SWORD-style capsule whose conf declares
+// the given DistributionLicense.
+func createLicensedCapsuleGZ(t *testing.T, path, distributionLicense string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	defer f.Close()
+	gzWriter := gzip.NewWriter(f)
+	defer gzWriter.Close()
+	tw := tar.NewWriter(gzWriter)
+	defer tw.Close()
+
+	files := map[string]string{
+		"capsule/manifest.json":   `{"capsule_version":"1.0","module_type":"bible"}`,
+		"capsule/mods.d/kjv.conf": "[KJV]\nDistributionLicense=" + distributionLicense + "\n",
+	}
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+	}
+}
+
+func TestGetCapsuleHandlerDownloadLicense(t *testing.T) {
+	tmpDir := t.TempDir()
+	createLicensedCapsuleGZ(t, filepath.Join(tmpDir, "kjv.tar.gz"), "Public Domain")
+	createLicensedCapsuleGZ(t, filepath.Join(tmpDir, "nasb.tar.gz"), "Copyrighted; Permission to distribute granted to CrossWire")
+
+	originalDir := ServerConfig.CapsulesDir
+	ServerConfig.CapsulesDir = tmpDir
+	defer func() { ServerConfig.CapsulesDir = originalDir }()
+
+	t.Run("metadata includes license", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/capsules/nasb.tar.gz", nil)
+		w := httptest.NewRecorder()
+		handleCapsuleByID(w, req)
+
+		var apiResp APIResponse
+		if err := json.NewDecoder(w.Body).Decode(&apiResp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		data := apiResp.Data.(map[string]interface{})
+		lic, ok := data["license"].(map[string]interface{})
+		if !ok || lic["id"] != "LicenseRef-Copyrighted-CrossWire" {
+			t.Errorf("unexpected license %v", data["license"])
+		}
+	})
+
+	t.Run("permitted download", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/capsules/kjv.tar.gz?download=true", nil)
+		w := httptest.NewRecorder()
+		handleCapsuleByID(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		if !strings.Contains(w.Header().Get("Content-Disposition"), "kjv.tar.gz") || w.Body.Len() == 0 {
+			t.Error("expected capsule archive as attachment")
+		}
+	})
+
+	t.Run("blocked download", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/capsules/nasb.tar.gz?download=true", nil)
+		w := httptest.NewRecorder()
+		handleCapsuleByID(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("expected status 403, got %d", w.Code)
+		}
+		var apiResp APIResponse
+		if err := json.NewDecoder(w.Body).Decode(&apiResp); err != nil || apiResp.Error == nil || apiResp.Error.Code != "LICENSE_BLOCKED" {
+			t.Errorf("expected LICENSE_BLOCKED error, got %+v, %v", apiResp.Error, err)
+		}
+	})
+}
@@ -9,6 +9,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/FocuswithJustin/JuniperBible/core/errors"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 	"github.com/google/uuid"
 )
 
@@ -25,18 +27,17 @@ const (
 
 // Job represents an asynchronous conversion job.
 type Job struct {
-	ID          string                 `json:"id"`
-	Status      JobStatus              `json:"status"`
-	Progress    int                    `json:"progress"` // 0-100
-	Result      *ConvertResult         `json:"result,omitempty"`
-	Error       string                 `json:"error,omitempty"`
-	CreatedAt   string                 `json:"created_at"`
-	UpdatedAt   string                 `json:"updated_at"`
-	CompletedAt string                 `json:"completed_at,omitempty"`
-	Request     ConvertRequest         `json:"request"`
-	ctx         context.Context        `json:"-"`
-	cancel      context.CancelFunc     `json:"-"`
-	options     map[string]interface{} `json:"-"`
+	ID          string             `json:"id"`
+	Status      JobStatus          `json:"status"`
+	Progress    int                `json:"progress"` // 0-100
+	Result      *ConvertResult     `json:"result,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	CreatedAt   string             `json:"created_at"`
+	UpdatedAt   string             `json:"updated_at"`
+	CompletedAt string             `json:"completed_at,omitempty"`
+	Request     ConvertRequest     `json:"request"`
+	ctx         context.Context    `json:"-"`
+	cancel      context.CancelFunc `json:"-"`
 }
 
 // JobStore manages conversion jobs in memory.
@@ -71,7 +72,6 @@ func (s *JobStore) Create(req ConvertRequest) *Job {
 		Request:   req,
 		ctx:       ctx,
 		cancel:    cancel,
-		options:   req.Options,
 	}
 
 	s.jobs[job.ID] = job
@@ -116,6 +116,18 @@ func (s *JobStore) Update(id string, status JobStatus, progress int, result *Con
 	return nil
 }
 
+// SetProgress updates the progress of a running job. Jobs in other
+// states are left unchanged, so late progress cannot undo a cancellation.
+func (s *JobStore) SetProgress(id string, progress int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, exists := s.jobs[id]; exists && job.Status == JobStatusRunning {
+		job.Progress = progress
+		job.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+}
+
 // Delete removes a job from the store.
 func (s *JobStore) Delete(id string) error {
 	s.mu.Lock()
@@ -174,14 +186,15 @@ func (s *JobStore) Cancel(id string) error {
 	return nil
 }
 
-// runJob executes a conversion job in a goroutine.
+// runJob executes a conversion job in a goroutine. Conversion is not
+// available through the API yet, so the job only steps its progress until
+// it is cancelled or fails; it runs no plugins and reports no plugin
+// progress.
 func runJob(job *Job) {
 	go func() {
 		// Update status to running
 		globalJobStore.Update(job.ID, JobStatusRunning, 10, nil, "")
 
-		// Simulate conversion work with progress updates
-		// In real implementation, this would call actual conversion logic
 		for i := 10; i <= 90; i += 20 {
 			select {
 			case <-job.ctx.Done():
@@ -190,7 +203,7 @@ func runJob(job *Job) {
 				return
 			default:
 				time.Sleep(500 * time.Millisecond)
-				globalJobStore.Update(job.ID, JobStatusRunning, i, nil, "")
+				globalJobStore.SetProgress(job.ID, i)
 			}
 		}
 
@@ -202,9 +215,6 @@ func runJob(job *Job) {
 		default:
 		}
 
-		// For now, return NOT_IMPLEMENTED as the actual conversion is not yet implemented
-		// In a real implementation, this would perform the actual conversion and return the result
-
 		// Mark as failed since conversion is not yet implemented
 		globalJobStore.Update(job.ID, JobStatusFailed, 100, nil,
 			fmt.Sprintf("Conversion from %s to %s not yet implemented via API. Use the CLI.",
@@ -212,6 +222,27 @@ func runJob(job *Job) {
 	}()
 }
 
+// validateConvertOptions checks the options of a conversion against the
+// emit-native options declared by the target format's plugin. A request
+// without options needs no plugin.
+func validateConvertOptions(req ConvertRequest) error {
+	if len(req.Options) == 0 {
+		return nil
+	}
+	loader := plugins.NewLoader()
+	if ServerConfig.PluginsDir != "" {
+		if err := loader.LoadFromDir(ServerConfig.PluginsDir); err != nil {
+			return err
+		}
+	}
+	plugin, err := loader.GetPlugin("format." + req.TargetFormat)
+	if err != nil {
+		return errors.NewValidation("target_format", fmt.Sprintf("no plugin for %q takes options", req.TargetFormat))
+	}
+	_, err = plugin.Manifest.ResolveOptions("emit-native", req.Options)
+	return err
+}
+
 // handleJobs handles POST /jobs - Create new conversion job.
 func handleJobs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -230,6 +261,11 @@ func handleJobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateConvertOptions(req); err != nil {
+		respondError(w, http.StatusBadRequest, "INVALID_OPTIONS", err.Error())
+		return
+	}
+
 	// Create job
 	job := globalJobStore.Create(req)
 
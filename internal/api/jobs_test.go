@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 )
 
 func TestHandleJobsMethodNotAllowed(t *testing.T) {
@@ -150,6 +152,55 @@ func TestHandleJobsCreateSuccess(t *testing.T) {
 	}
 }
 
+func TestHandleJobsOptions(t *testing.T) {
+	globalJobStore = NewJobStore()
+	plugins.RegisterEmbeddedPlugin(&plugins.EmbeddedPlugin{
+		Manifest: &plugins.PluginManifest{
+			PluginID: "format.optiontest",
+			Kind:     "format",
+			Options: []plugins.OptionSpec{
+				{Name: "layout", Type: plugins.OptionEnum, Values: []string{"single", "chapter"}, Commands: []string{"emit-native"}},
+			},
+		},
+	})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"source":"test.osis","target_format":"optiontest","options":{"layout":"chapter"}}`, http.StatusCreated},
+		{"invalid value", `{"source":"test.osis","target_format":"optiontest","options":{"layout":"book"}}`, http.StatusBadRequest},
+		{"unknown option", `{"source":"test.osis","target_format":"optiontest","options":{"width":80}}`, http.StatusBadRequest},
+		{"unknown format", `{"source":"test.osis","target_format":"nosuchformat","options":{"layout":"chapter"}}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			handleJobs(w, req)
+
+			resp := w.Result()
+			if resp.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if tt.status != http.StatusBadRequest {
+				return
+			}
+			var apiResp APIResponse
+			if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if apiResp.Error == nil || apiResp.Error.Code != "INVALID_OPTIONS" {
+				t.Error("expected INVALID_OPTIONS error")
+			}
+		})
+	}
+}
+
 func TestHandleJobByIDMissingID(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/jobs/", nil)
 	w := httptest.NewRecorder()
@@ -701,3 +752,21 @@ func TestJobStoreDeleteRunningJob(t *testing.T) {
 		t.Error("expected job to be deleted")
 	}
 }
+
+func TestJobStoreSetProgressAfterCancel(t *testing.T) {
+	globalJobStore = NewJobStore()
+
+	job := globalJobStore.Create(ConvertRequest{Source: "test.osis", TargetFormat: "usfm"})
+	globalJobStore.Update(job.ID, JobStatusRunning, 10, nil, "")
+	globalJobStore.SetProgress(job.ID, 50)
+	if got, _ := globalJobStore.Get(job.ID); got.Progress != 50 {
+		t.Errorf("expected progress 50, got %d", got.Progress)
+	}
+
+	// Progress does not change a cancelled job
+	globalJobStore.Cancel(job.ID)
+	globalJobStore.SetProgress(job.ID, 70)
+	if got, _ := globalJobStore.Get(job.ID); got.Status != JobStatusCancelled || got.Progress != 50 {
+		t.Errorf("cancelled job changed: %s %d", got.Status, got.Progress)
+	}
+}
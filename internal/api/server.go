@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/FocuswithJustin/JuniperBible/core/license"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 	"github.com/FocuswithJustin/JuniperBible/internal/logging"
 	"github.com/FocuswithJustin/JuniperBible/internal/server"
@@ -34,6 +35,11 @@ func Start(cfg Config) error {
 		}
 	}
 
+	var err error
+	if licenses, err = license.NewEnforcer(cfg.LicensePolicy, cfg.LicenseLog); err != nil {
+		return fmt.Errorf("invalid license policy: %w", err)
+	}
+
 	// Ensure capsules directory exists
 	if err := os.MkdirAll(ServerConfig.CapsulesDir, 0755); err != nil {
 		return fmt.Errorf("failed to create capsules directory: %w", err)
@@ -26,6 +26,7 @@ func ExtractCapsuleID(filename string) string {
 	compoundExts := []string{
 		".capsule.tar.xz",
 		".capsule.tar.gz",
+		".capsule.zip",
 	}
 	for _, ext := range compoundExts {
 		if strings.HasSuffix(id, ext) {
@@ -34,7 +35,7 @@ func ExtractCapsuleID(filename string) string {
 	}
 
 	// Then single extensions
-	singleExts := []string{".tar.xz", ".tar.gz", ".tar"}
+	singleExts := []string{".tar.xz", ".tar.gz", ".tar", ".zip"}
 	for _, ext := range singleExts {
 		if strings.HasSuffix(id, ext) {
 			return strings.TrimSuffix(id, ext)
@@ -147,6 +148,8 @@ func DetectFormat(path string) string {
 		return "tar.gz"
 	case strings.HasSuffix(path, ".tar"):
 		return "tar"
+	case strings.HasSuffix(path, ".zip"):
+		return "zip"
 	default:
 		return "unknown"
 	}
@@ -156,5 +159,6 @@ func DetectFormat(path string) string {
 func IsSupportedFormat(path string) bool {
 	return strings.HasSuffix(path, ".tar.xz") ||
 		strings.HasSuffix(path, ".tar.gz") ||
-		strings.HasSuffix(path, ".tar")
+		strings.HasSuffix(path, ".tar") ||
+		strings.HasSuffix(path, ".zip")
 }
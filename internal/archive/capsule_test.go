@@ -11,6 +11,7 @@ func TestExtractCapsuleID(t *testing.T) {
 	}{
 		{"KJV.capsule.tar.xz", "KJV"},
 		{"KJV.capsule.tar.gz", "KJV"},
+		{"KJV.capsule.zip", "KJV"},
 		{"KJV.tar.xz", "KJV"},
 		{"KJV.tar.gz", "KJV"},
 		{"KJV.tar", "KJV"},
@@ -58,7 +59,8 @@ func TestDetectFormat(t *testing.T) {
 		{"file.tar.xz", "tar.xz"},
 		{"file.tar.gz", "tar.gz"},
 		{"file.tar", "tar"},
-		{"file.zip", "unknown"},
+		{"file.zip", "zip"},
+		{"file.rar", "unknown"},
 		{"file", "unknown"},
 	}
 
@@ -80,7 +82,8 @@ func TestIsSupportedFormat(t *testing.T) {
 		{"file.tar.xz", true},
 		{"file.tar.gz", true},
 		{"file.tar", true},
-		{"file.zip", false},
+		{"file.zip", true},
+		{"file.rar", false},
 		{"file", false},
 		{"file.capsule.tar.xz", true},
 	}
@@ -1,9 +1,11 @@
 // Package archive provides utilities for reading compressed tar archives.
-// It supports tar.gz and tar.xz formats used by capsule files.
+// It supports tar.gz and tar.xz formats used by capsule files, as well as
+// zip capsules which allow random access to individual entries.
 package archive
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -195,7 +197,11 @@ func (r *Reader) Iterate(visitor Visitor) error {
 }
 
 // IterateCapsule opens an archive and iterates through its entries.
+// Zip entries are presented to the visitor as tar headers.
 func IterateCapsule(path string, visitor Visitor) error {
+	if isZipPath(path) {
+		return iterateZip(path, visitor)
+	}
 	r, err := NewReader(path)
 	if err != nil {
 		return err
@@ -218,7 +224,11 @@ func ContainsPath(path string, predicate func(name string) bool) (bool, error) {
 }
 
 // ReadFile reads a specific file from the archive.
+// For zip archives the entry is read directly without scanning.
 func ReadFile(archivePath, filename string) ([]byte, error) {
+	if isZipPath(archivePath) {
+		return readZipEntry(archivePath, filename)
+	}
 	var content []byte
 	err := IterateCapsule(archivePath, func(header *tar.Header, r io.Reader) (bool, error) {
 		// Handle archives with or without leading directory
@@ -263,3 +273,90 @@ func FindFile(archivePath string, predicate func(name string) bool) ([]byte, str
 	}
 	return content, foundName, nil
 }
+
+// isZipPath reports whether the path names a zip capsule.
+func isZipPath(path string) bool {
+	return strings.HasSuffix(path, ".zip")
+}
+
+// iterateZip walks the entries of a zip archive in central directory order.
+func iterateZip(path string, visitor Visitor) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("open archive: %w", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		header, err := tar.FileInfoHeader(f.FileInfo(), "")
+		if err != nil {
+			return fmt.Errorf("read header: %w", err)
+		}
+		header.Name = f.Name
+
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("open entry: %w", err)
+		}
+		stop, err := visitor(header, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if stop {
+			return nil
+		}
+	}
+	return nil
+}
+
+// readZipEntry reads a single entry from a zip archive.
+// Like ReadFile it accepts names with or without a leading directory.
+func readZipEntry(archivePath, filename string) ([]byte, error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		name := f.Name
+		if idx := strings.Index(name, "/"); idx >= 0 {
+			name = name[idx+1:]
+		}
+		if f.Name == filename || name == filename {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("open entry: %w", err)
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+	}
+	return nil, fmt.Errorf("file not found: %s", filename)
+}
+
+// IsZipCapsule reports whether a zip file is a capsule: it is named
+// .capsule.zip or has a manifest.json entry. Other zips, such as raw
+// module downloads, are not capsules.
+func IsZipCapsule(path string) bool {
+	if strings.HasSuffix(path, ".capsule.zip") {
+		return true
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return false
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		name := f.Name
+		if idx := strings.Index(name, "/"); idx >= 0 {
+			name = name[idx+1:]
+		}
+		if name == "manifest.json" {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package archive
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"compress/gzip"
 	"io"
 	"os"
@@ -631,3 +632,102 @@ func TestReaderClose_MultipleTimes(t *testing.T) {
 		t.Logf("Second Close() did not error (may be system-dependent)")
 	}
 }
+
+func createTestZip(t *testing.T, dir string) string {
+	path := filepath.Join(dir, "test.capsule.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	entries := []struct {
+		name, content string
+	}{
+		{"manifest.json", `{"capsule_version": "1.0.0"}`},
+		{"blobs/sha256/ab/abcdef", "blob content"},
+	}
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatalf("write entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return path
+}
+
+func TestIterateCapsule_Zip(t *testing.T) {
+	path := createTestZip(t, t.TempDir())
+
+	var names []string
+	err := IterateCapsule(path, func(header *tar.Header, r io.Reader) (bool, error) {
+		names = append(names, header.Name)
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("IterateCapsule() error = %v", err)
+	}
+	if len(names) != 2 || names[0] != "manifest.json" {
+		t.Errorf("IterateCapsule() names = %v", names)
+	}
+}
+
+func TestReadFile_Zip(t *testing.T) {
+	path := createTestZip(t, t.TempDir())
+
+	got, err := ReadFile(path, "blobs/sha256/ab/abcdef")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(got) != "blob content" {
+		t.Errorf("ReadFile() = %q, want %q", got, "blob content")
+	}
+
+	if _, err := ReadFile(path, "missing.txt"); err == nil {
+		t.Error("ReadFile() expected error for missing entry")
+	}
+}
+
+func TestScanCapsuleFlags_Zip(t *testing.T) {
+	path := createTestZip(t, t.TempDir())
+	ClearTOCCache()
+
+	flags, err := ScanCapsuleFlags(path)
+	if err != nil {
+		t.Fatalf("ScanCapsuleFlags() error = %v", err)
+	}
+	if !flags.IsCAS {
+		t.Error("zip capsule with blobs should be CAS")
+	}
+}
+
+func TestIsZipCapsule(t *testing.T) {
+	dir := t.TempDir()
+
+	capsule := createTestZip(t, dir)
+	renamed := filepath.Join(dir, "renamed.zip")
+	data, _ := os.ReadFile(capsule)
+	os.WriteFile(renamed, data, 0644)
+
+	module := filepath.Join(dir, "module.zip")
+	f, _ := os.Create(module)
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("mods.d/kjv.conf")
+	w.Write([]byte("[KJV]"))
+	zw.Close()
+	f.Close()
+
+	tests := map[string]bool{capsule: true, renamed: true, module: false}
+	for path, want := range tests {
+		if got := IsZipCapsule(path); got != want {
+			t.Errorf("IsZipCapsule(%s) = %v, want %v", filepath.Base(path), got, want)
+		}
+	}
+}
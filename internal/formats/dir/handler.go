@@ -50,9 +50,10 @@ func (h *Handler) Detect(path string) (*plugins.DetectResult, error) {
 	}
 
 	return &plugins.DetectResult{
-		Detected: true,
-		Format:   "directory",
-		Reason:   "is a directory",
+		Detected:    true,
+		Format:      "directory",
+		Reason:      "is a directory",
+		Specificity: plugins.SpecificityAny,
 	}, nil
 }
 
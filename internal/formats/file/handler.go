@@ -53,9 +53,10 @@ func (h *Handler) Detect(path string) (*plugins.DetectResult, error) {
 	}
 
 	return &plugins.DetectResult{
-		Detected: true,
-		Format:   "file",
-		Reason:   "generic file",
+		Detected:    true,
+		Format:      "file",
+		Reason:      "generic file",
+		Specificity: plugins.SpecificityAny,
 	}, nil
 }
 
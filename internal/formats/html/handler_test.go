@@ -1,14 +1,17 @@
 package html
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 )
 
 func TestDetect_ValidHtmlFile(t *testing.T) {
@@ -615,6 +618,77 @@ func TestEmitNative_HtmlEscaping(t *testing.T) {
 	}
 }
 
+func TestEmitNative_ChapterLayout(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	verse := func(chapter, verse int, text string) *ir.ContentBlock {
+		return &ir.ContentBlock{
+			ID:   fmt.Sprintf("cb-%d-%d", chapter, verse),
+			Text: text,
+			Anchors: []*ir.Anchor{
+				{
+					ID: "a",
+					Spans: []*ir.Span{
+						{ID: "s", Type: ir.SpanVerse, Ref: &ir.Ref{Book: "Gen", Chapter: chapter, Verse: verse}},
+					},
+				},
+			},
+		}
+	}
+	corpus := &ir.Corpus{
+		ID:         "test",
+		Title:      "Test Bible",
+		Attributes: map[string]string{"_html_raw": "<html></html>"},
+		Documents: []*ir.Document{
+			{
+				ID:    "Gen",
+				Title: "Genesis",
+				ContentBlocks: []*ir.ContentBlock{
+					verse(1, 1, "In the beginning"),
+					verse(2, 1, "Thus the heavens"),
+				},
+			},
+		},
+	}
+
+	irPath := filepath.Join(tmpDir, "test.ir.json")
+	irData, err := json.Marshal(corpus)
+	if err != nil {
+		t.Fatalf("Failed to marshal IR: %v", err)
+	}
+	if err := os.WriteFile(irPath, irData, 0644); err != nil {
+		t.Fatalf("Failed to write IR: %v", err)
+	}
+
+	h := &Handler{}
+	result, err := h.EmitNativeOptions(context.Background(), irPath, tmpDir, plugins.Options{"layout": LayoutChapter})
+	if err != nil {
+		t.Fatalf("EmitNativeOptions failed: %v", err)
+	}
+	if result.OutputPath != filepath.Join(tmpDir, "test") {
+		t.Errorf("OutputPath = %q, want the corpus directory", result.OutputPath)
+	}
+
+	index, err := os.ReadFile(filepath.Join(result.OutputPath, "index.html"))
+	if err != nil {
+		t.Fatalf("Failed to read index: %v", err)
+	}
+	if !contains(string(index), `href="Gen.1.html"`) || !contains(string(index), `href="Gen.2.html"`) {
+		t.Errorf("index does not link every chapter:\n%s", index)
+	}
+
+	page, err := os.ReadFile(filepath.Join(result.OutputPath, "Gen.1.html"))
+	if err != nil {
+		t.Fatalf("Failed to read chapter page: %v", err)
+	}
+	if !contains(string(page), "In the beginning") || contains(string(page), "Thus the heavens") {
+		t.Errorf("chapter page has the wrong verses:\n%s", page)
+	}
+	if !contains(string(page), `href="Gen.2.html" rel="next"`) {
+		t.Errorf("chapter page does not link the next chapter")
+	}
+}
+
 func TestEmitNative_InvalidIRPath(t *testing.T) {
 	h := &Handler{}
 	_, err := h.EmitNative("/nonexistent/file.ir.json", t.TempDir())
@@ -122,7 +122,7 @@ func FuzzEmitOSISFromIR(f *testing.F) {
 		corpus := createTestCorpus(id, title, lang, versification)
 
 		// The emitter should not panic on any corpus
-		data, err := emitOSISFromIR(corpus)
+		data, err := emitOSISFromIR(corpus, VerseMilestone)
 
 		// If emission succeeds, the output should be valid XML-ish
 		if err == nil && len(data) > 0 {
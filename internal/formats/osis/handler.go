@@ -2,6 +2,7 @@
 package osis
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -29,6 +30,16 @@ func Manifest() *plugins.PluginManifest {
 			Inputs:  []string{"file"},
 			Outputs: []string{"artifact.kind:osis"},
 		},
+		Options: []plugins.OptionSpec{
+			{
+				Name:        "verse_style",
+				Type:        plugins.OptionEnum,
+				Description: "Mark verses with sID/eID milestones or as verse containers",
+				Values:      []string{VerseMilestone, VerseContainer},
+				Default:     VerseMilestone,
+				Commands:    []string{"emit-native"},
+			},
+		},
 	}
 }
 
@@ -142,19 +153,39 @@ func (h *Handler) Enumerate(path string) (*plugins.EnumerateResult, error) {
 
 // ExtractIR implements EmbeddedFormatHandler.ExtractIR.
 func (h *Handler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, error) {
+	return h.ExtractIRContext(context.Background(), path, outputDir)
+}
+
+// ExtractIRContext implements plugins.IRExtractorContext. It reports each
+// stage and stops between stages when ctx is cancelled.
+func (h *Handler) ExtractIRContext(ctx context.Context, path, outputDir string) (*plugins.ExtractIRResult, error) {
 	// Read OSIS file
+	plugins.ReportProgress(ctx, plugins.ProgressEvent{Stage: "read", Message: "Reading OSIS", Done: 0, Total: 3})
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Parse OSIS XML
+	plugins.ReportProgress(ctx, plugins.ProgressEvent{Stage: "parse", Message: "Parsing OSIS", Done: 1, Total: 3})
 	corpus, err := parseOSISToIR(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse OSIS: %w", err)
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Serialize IR to JSON
+	plugins.ReportProgress(ctx, plugins.ProgressEvent{
+		Stage:   "write",
+		Message: fmt.Sprintf("Writing IR for %d books", len(corpus.Documents)),
+		Done:    2,
+		Total:   3,
+	})
 	irData, err := json.MarshalIndent(corpus, "", "  ")
 	if err != nil {
 		return nil, fmt.Errorf("failed to serialize IR: %w", err)
@@ -174,7 +205,19 @@ func (h *Handler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, e
 
 // EmitNative implements EmbeddedFormatHandler.EmitNative.
 func (h *Handler) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResult, error) {
+	return h.EmitNativeContext(context.Background(), irPath, outputDir)
+}
+
+// EmitNativeContext implements plugins.NativeEmitterContext.
+func (h *Handler) EmitNativeContext(ctx context.Context, irPath, outputDir string) (*plugins.EmitNativeResult, error) {
+	return h.EmitNativeOptions(ctx, irPath, outputDir, nil)
+}
+
+// EmitNativeOptions implements plugins.NativeEmitterOptions. It reports
+// each stage and stops between stages when ctx is cancelled.
+func (h *Handler) EmitNativeOptions(ctx context.Context, irPath, outputDir string, opts plugins.Options) (*plugins.EmitNativeResult, error) {
 	// Read IR file
+	plugins.ReportProgress(ctx, plugins.ProgressEvent{Stage: "read", Message: "Reading IR", Done: 0, Total: 3})
 	data, err := os.ReadFile(irPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read IR file: %w", err)
@@ -185,14 +228,27 @@ func (h *Handler) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResul
 	if err := json.Unmarshal(data, &corpus); err != nil {
 		return nil, fmt.Errorf("failed to parse IR: %w", err)
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Convert IR to OSIS
-	osisData, err := emitOSISFromIR(&corpus)
+	plugins.ReportProgress(ctx, plugins.ProgressEvent{
+		Stage:   "emit",
+		Message: fmt.Sprintf("Emitting OSIS for %d books", len(corpus.Documents)),
+		Done:    1,
+		Total:   3,
+	})
+	osisData, err := emitOSISFromIR(&corpus, opts.String("verse_style", VerseMilestone))
 	if err != nil {
 		return nil, fmt.Errorf("failed to emit OSIS: %w", err)
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Write OSIS to output directory
+	plugins.ReportProgress(ctx, plugins.ProgressEvent{Stage: "write", Message: "Writing OSIS", Done: 2, Total: 3})
 	outputPath := filepath.Join(outputDir, corpus.ID+".osis")
 	if err := os.WriteFile(outputPath, osisData, 0644); err != nil {
 		return nil, fmt.Errorf("failed to write OSIS: %w", err)
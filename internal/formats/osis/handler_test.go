@@ -1,12 +1,14 @@
 package osis
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 )
 
 func TestManifest(t *testing.T) {
@@ -444,3 +446,39 @@ func TestRegister(t *testing.T) {
 	// when called again
 	Register()
 }
+
+func TestExtractIRContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	osisFile := filepath.Join(tmpDir, "bible.osis")
+	content := `<?xml version="1.0"?><osis xmlns="http://www.bibletechnologies.net/2003/OSIS/namespace">
+  <osisText osisIDWork="KJV">
+    <div type="book" osisID="Gen"><p>In the beginning</p></div>
+  </osisText>
+</osis>`
+	if err := os.WriteFile(osisFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	h := &Handler{}
+
+	var stages []string
+	ctx := plugins.WithProgress(context.Background(), func(ev plugins.ProgressEvent) {
+		stages = append(stages, ev.Stage)
+	})
+	result, err := h.ExtractIRContext(ctx, osisFile, tmpDir)
+	if err != nil {
+		t.Fatalf("ExtractIRContext failed: %v", err)
+	}
+	if len(stages) != 3 || stages[0] != "read" || stages[2] != "write" {
+		t.Errorf("unexpected stages %v", stages)
+	}
+
+	if _, err := h.EmitNativeContext(ctx, result.IRPath, tmpDir); err != nil {
+		t.Fatalf("EmitNativeContext failed: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.ExtractIRContext(cancelled, osisFile, t.TempDir()); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -296,8 +296,14 @@ func isBookID(osisID string) bool {
 	return books.MatchString(osisID)
 }
 
+// Verse styles of emitted OSIS, chosen with the verse_style option.
+const (
+	VerseMilestone = "milestone" // <verse sID="..."/>text<verse eID="..."/>
+	VerseContainer = "container" // <verse osisID="...">text</verse>
+)
+
 // emitOSISFromIR converts IR Corpus back to OSIS XML
-func emitOSISFromIR(corpus *ir.Corpus) ([]byte, error) {
+func emitOSISFromIR(corpus *ir.Corpus, verseStyle string) ([]byte, error) {
 	// Otherwise, reconstruct OSIS from IR structure
 	var buf bytes.Buffer
 	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
@@ -336,9 +342,9 @@ func emitOSISFromIR(corpus *ir.Corpus) ([]byte, error) {
 
 		// Write content blocks
 		for _, block := range doc.ContentBlocks {
-			// Write as paragraph
+			// Write as paragraph with the block's verses
 			buf.WriteString("      <p>")
-			buf.WriteString(escapeXML(block.Text))
+			writeVerseText(&buf, blockVerseIDs(block), block.Text, verseStyle)
 			buf.WriteString("</p>\n")
 		}
 
@@ -351,6 +357,36 @@ func emitOSISFromIR(corpus *ir.Corpus) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// blockVerseIDs returns the OSIS IDs of the verses in a content block.
+func blockVerseIDs(block *ir.ContentBlock) []string {
+	var ids []string
+	for _, anchor := range block.Anchors {
+		for _, span := range anchor.Spans {
+			if span.Type == ir.SpanVerse && span.Ref != nil {
+				ids = append(ids, span.Ref.String())
+			}
+		}
+	}
+	return ids
+}
+
+// writeVerseText writes text marked up as the verses osisIDs, either
+// between milestones or inside a verse container. A verse spanning
+// several IDs gets them all as its osisID list.
+func writeVerseText(buf *bytes.Buffer, osisIDs []string, text, verseStyle string) {
+	if len(osisIDs) == 0 {
+		buf.WriteString(escapeXML(text))
+		return
+	}
+	osisID := escapeXML(strings.Join(osisIDs, " "))
+	if verseStyle == VerseContainer {
+		fmt.Fprintf(buf, `<verse osisID="%s">%s</verse>`, osisID, escapeXML(text))
+		return
+	}
+	sID := escapeXML(osisIDs[0])
+	fmt.Fprintf(buf, `<verse sID="%s" osisID="%s"/>%s<verse eID="%s"/>`, sID, osisID, escapeXML(text), sID)
+}
+
 // escapeXML escapes special characters for XML
 func escapeXML(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
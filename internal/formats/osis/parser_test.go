@@ -320,7 +320,7 @@ func TestEmitOSISFromIR_Simple(t *testing.T) {
 		},
 	}
 
-	data, err := emitOSISFromIR(corpus)
+	data, err := emitOSISFromIR(corpus, VerseMilestone)
 	if err != nil {
 		t.Fatalf("emitOSISFromIR failed: %v", err)
 	}
@@ -349,7 +349,7 @@ func TestEmitOSISFromIR_WithVersification(t *testing.T) {
 		Versification: "Bible.KJV",
 	}
 
-	data, err := emitOSISFromIR(corpus)
+	data, err := emitOSISFromIR(corpus, VerseMilestone)
 	if err != nil {
 		t.Fatalf("emitOSISFromIR failed: %v", err)
 	}
@@ -365,7 +365,7 @@ func TestEmitOSISFromIR_Empty(t *testing.T) {
 		ID: "Empty",
 	}
 
-	data, err := emitOSISFromIR(corpus)
+	data, err := emitOSISFromIR(corpus, VerseMilestone)
 	if err != nil {
 		t.Fatalf("emitOSISFromIR failed: %v", err)
 	}
@@ -375,6 +375,51 @@ func TestEmitOSISFromIR_Empty(t *testing.T) {
 	}
 }
 
+func TestEmitOSISFromIR_VerseStyle(t *testing.T) {
+	corpus := &ir.Corpus{
+		ID: "KJV",
+		Documents: []*ir.Document{
+			{
+				ID: "Gen",
+				ContentBlocks: []*ir.ContentBlock{
+					{
+						ID:   "cb-1",
+						Text: "In the beginning God created the heaven and the earth.",
+						Anchors: []*ir.Anchor{
+							{
+								ID: "a-1",
+								Spans: []*ir.Span{
+									{ID: "s-1", Type: ir.SpanVerse, Ref: &ir.Ref{Book: "Gen", Chapter: 1, Verse: 1}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		style string
+		want  string
+	}{
+		{VerseMilestone, `<verse sID="Gen.1.1" osisID="Gen.1.1"/>In the beginning God created the heaven and the earth.<verse eID="Gen.1.1"/>`},
+		{VerseContainer, `<verse osisID="Gen.1.1">In the beginning God created the heaven and the earth.</verse>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			data, err := emitOSISFromIR(corpus, tt.style)
+			if err != nil {
+				t.Fatalf("emitOSISFromIR failed: %v", err)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("output does not contain %s:\n%s", tt.want, data)
+			}
+		})
+	}
+}
+
 func TestEscapeXML(t *testing.T) {
 	tests := []struct {
 		input string
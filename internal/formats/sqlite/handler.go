@@ -58,9 +58,11 @@ func (h *Handler) Detect(path string) (*plugins.DetectResult, error) {
 	}
 
 	return &plugins.DetectResult{
-		Detected: true,
-		Format:   "sqlite",
-		Reason:   "SQLite file detected",
+		Detected:    true,
+		Format:      "sqlite",
+		Reason:      "SQLite file detected",
+		Confidence:  plugins.ExtensionConfidence,
+		Specificity: plugins.SpecificityGeneric,
 	}, nil
 }
 
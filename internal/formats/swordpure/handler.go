@@ -2,6 +2,7 @@
 package swordpure
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -27,9 +28,30 @@ func Manifest() *plugins.PluginManifest {
 			Inputs:  []string{"dir"},
 			Outputs: []string{"artifact.kind:sword-module"},
 		},
+		Options: []plugins.OptionSpec{
+			{
+				Name:        "module_driver",
+				Type:        plugins.OptionEnum,
+				Description: "Module driver; zText4 allows verses over 64KB",
+				Values:      []string{ZTextDriver, ZText4Driver},
+				Default:     ZTextDriver,
+				Commands:    []string{"emit-native"},
+			},
+			{
+				Name:        "block_size",
+				Type:        plugins.OptionInt,
+				Description: "Uncompressed size of compressed text blocks, in bytes",
+				Default:     DefaultZTextBlockSize,
+				Min:         &minZTextBlockSize,
+				Commands:    []string{"emit-native"},
+			},
+		},
 	}
 }
 
+// minZTextBlockSize is the smallest block_size option accepted.
+var minZTextBlockSize = 512
+
 // Register registers this plugin with the embedded registry.
 func Register() {
 	plugins.RegisterEmbeddedPlugin(&plugins.EmbeddedPlugin{
@@ -150,6 +172,12 @@ func (h *Handler) Enumerate(path string) (*plugins.EnumerateResult, error) {
 
 // ExtractIR implements EmbeddedFormatHandler.ExtractIR.
 func (h *Handler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, error) {
+	return h.ExtractIRContext(context.Background(), path, outputDir)
+}
+
+// ExtractIRContext implements plugins.IRExtractorContext. It stops
+// between modules when ctx is cancelled.
+func (h *Handler) ExtractIRContext(ctx context.Context, path, outputDir string) (*plugins.ExtractIRResult, error) {
 	// Create output directory
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create output dir: %w", err)
@@ -163,6 +191,10 @@ func (h *Handler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, e
 
 	var results []map[string]interface{}
 	for _, conf := range confs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		// Skip encrypted modules
 		if conf.IsEncrypted() {
 			results = append(results, map[string]interface{}{
@@ -236,6 +268,11 @@ func (h *Handler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, e
 
 // EmitNative implements EmbeddedFormatHandler.EmitNative.
 func (h *Handler) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResult, error) {
+	return h.EmitNativeOptions(context.Background(), irPath, outputDir, nil)
+}
+
+// EmitNativeOptions implements plugins.NativeEmitterOptions.
+func (h *Handler) EmitNativeOptions(ctx context.Context, irPath, outputDir string, opts plugins.Options) (*plugins.EmitNativeResult, error) {
 	// Load IR corpus
 	data, err := os.ReadFile(irPath)
 	if err != nil {
@@ -248,7 +285,10 @@ func (h *Handler) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResul
 	}
 
 	// Use EmitZText for full binary generation
-	_, err = EmitZText(&corpus, outputDir)
+	_, err = EmitZTextWithOptions(&corpus, outputDir, ZTextOptions{
+		Driver:    opts.String("module_driver", ZTextDriver),
+		BlockSize: opts.Int("block_size", DefaultZTextBlockSize),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to emit zText: %w", err)
 	}
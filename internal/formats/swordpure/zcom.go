@@ -215,7 +215,7 @@ func (p *ZComParser) GetEntry(ref Ref) (*CommentaryEntry, error) {
 		return nil, fmt.Errorf("entry data exceeds block size")
 	}
 
-	text := string(blockData[verse.Offset : verse.Offset+uint32(verse.Size)])
+	text := string(blockData[verse.Offset : verse.Offset+verse.Size])
 	text = strings.TrimRight(text, "\x00")
 
 	return &CommentaryEntry{
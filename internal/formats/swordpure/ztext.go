@@ -2,9 +2,10 @@
 // zText is a compressed Bible text format using zlib compression.
 //
 // File structure:
-// - .bzs - Block section index (12 bytes per entry: offset[4], size[4], ucsize[4])
-// - .bzv - Verse index (10 bytes per entry: block[4], offset[4], size[2])
-// - .bzz - Compressed text data (zlib compressed blocks)
+//   - .bzs - Block section index (12 bytes per entry: offset[4], size[4], ucsize[4])
+//   - .bzv - Verse index (10 bytes per entry: block[4], offset[4], size[2];
+//     12 bytes with a 4-byte size for zText4)
+//   - .bzz - Compressed text data (zlib compressed blocks)
 package swordpure
 
 import (
@@ -27,6 +28,10 @@ const (
 	// VerseIndexEntrySize is the size of each entry in .bzv verse index files.
 	// Format: block_num[4 bytes] + offset[4 bytes] + size[2 bytes]
 	VerseIndexEntrySize = 10
+
+	// VerseIndex4EntrySize is the size of each entry in zText4 .bzv files.
+	// Format: block_num[4 bytes] + offset[4 bytes] + size[4 bytes]
+	VerseIndex4EntrySize = 12
 )
 
 // ZTextModule represents a parsed zText SWORD module.
@@ -50,7 +55,7 @@ type BlockEntry struct {
 type VerseEntry struct {
 	BlockNum uint32 // Which block contains this verse
 	Offset   uint32 // Offset within the decompressed block
-	Size     uint16 // Size of verse text
+	Size     uint32 // Size of verse text
 }
 
 // OpenZTextModule opens a zText module for reading.
@@ -69,6 +74,11 @@ func OpenZTextModule(conf *ConfFile, swordPath string) (*ZTextModule, error) {
 		dataPath: dataPath,
 	}
 
+	verseEntrySize := VerseIndexEntrySize
+	if strings.EqualFold(conf.ModDrv, ZText4Driver) {
+		verseEntrySize = VerseIndex4EntrySize
+	}
+
 	// Load OT index files if they exist
 	otBzsPath := filepath.Join(dataPath, "ot.bzs")
 	otBzvPath := filepath.Join(dataPath, "ot.bzv")
@@ -77,7 +87,7 @@ func OpenZTextModule(conf *ConfFile, swordPath string) (*ZTextModule, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to read OT block index: %w", err)
 		}
-		mod.otVerses, err = readVerseIndex(otBzvPath)
+		mod.otVerses, err = readVerseIndexEntries(otBzvPath, verseEntrySize)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read OT verse index: %w", err)
 		}
@@ -91,7 +101,7 @@ func OpenZTextModule(conf *ConfFile, swordPath string) (*ZTextModule, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to read NT block index: %w", err)
 		}
-		mod.ntVerses, err = readVerseIndex(ntBzvPath)
+		mod.ntVerses, err = readVerseIndexEntries(ntBzvPath, verseEntrySize)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read NT verse index: %w", err)
 		}
@@ -128,24 +138,34 @@ func readBlockIndex(path string) ([]BlockEntry, error) {
 
 // readVerseIndex reads a .bzv verse index file.
 func readVerseIndex(path string) ([]VerseEntry, error) {
+	return readVerseIndexEntries(path, VerseIndexEntrySize)
+}
+
+// readVerseIndexEntries reads a .bzv verse index file with entries of
+// VerseIndexEntrySize or VerseIndex4EntrySize bytes.
+func readVerseIndexEntries(path string, entrySize int) ([]VerseEntry, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(data)%VerseIndexEntrySize != 0 {
+	if len(data)%entrySize != 0 {
 		return nil, fmt.Errorf("invalid verse index size: %d", len(data))
 	}
 
-	count := len(data) / VerseIndexEntrySize
+	count := len(data) / entrySize
 	entries := make([]VerseEntry, count)
 
 	for i := 0; i < count; i++ {
-		offset := i * VerseIndexEntrySize
+		offset := i * entrySize
 		entries[i] = VerseEntry{
 			BlockNum: binary.LittleEndian.Uint32(data[offset:]),
 			Offset:   binary.LittleEndian.Uint32(data[offset+4:]),
-			Size:     binary.LittleEndian.Uint16(data[offset+8:]),
+		}
+		if entrySize == VerseIndex4EntrySize {
+			entries[i].Size = binary.LittleEndian.Uint32(data[offset+8:])
+		} else {
+			entries[i].Size = uint32(binary.LittleEndian.Uint16(data[offset+8:]))
 		}
 	}
 
@@ -207,7 +227,7 @@ func (m *ZTextModule) GetVerseText(ref *Ref) (string, error) {
 		return "", fmt.Errorf("verse data exceeds block size")
 	}
 
-	text := string(blockData[verse.Offset : verse.Offset+uint32(verse.Size)])
+	text := string(blockData[verse.Offset : verse.Offset+verse.Size])
 
 	// Clean up the text (remove null terminators, etc.)
 	text = strings.TrimRight(text, "\x00")
@@ -2,9 +2,10 @@
 // This enables round-trip conversion: SWORD → IR → SWORD.
 //
 // zText format:
-// - .bzs - Block section index (12 bytes per entry: offset[4], size[4], ucsize[4])
-// - .bzv - Verse index (10 bytes per entry: block[4], offset[4], size[2])
-// - .bzz - Compressed text data (zlib compressed blocks)
+//   - .bzs - Block section index (12 bytes per entry: offset[4], size[4], ucsize[4])
+//   - .bzv - Verse index (10 bytes per entry: block[4], offset[4], size[2];
+//     12 bytes with a 4-byte size for zText4)
+//   - .bzz - Compressed text data (zlib compressed blocks)
 package swordpure
 
 import (
@@ -16,10 +17,31 @@ import (
 	"path/filepath"
 )
 
+// Module drivers written by ZTextWriter.
+const (
+	ZTextDriver  = "zText"
+	ZText4Driver = "zText4"
+)
+
+// DefaultZTextBlockSize is the uncompressed size at which blocks are
+// compressed and started anew.
+const DefaultZTextBlockSize = 4096
+
+// ZTextOptions controls the layout of a written zText module.
+type ZTextOptions struct {
+	// Driver is ZTextDriver or ZText4Driver. zText4 indexes verses with
+	// 4-byte sizes, so a verse may exceed 64KB.
+	Driver string
+
+	// BlockSize is the uncompressed block size; DefaultZTextBlockSize if 0.
+	BlockSize int
+}
+
 // ZTextWriter writes zText format SWORD modules.
 type ZTextWriter struct {
 	dataPath string
 	vers     *Versification
+	opts     ZTextOptions
 
 	// Block accumulation
 	currentBlock  bytes.Buffer
@@ -34,12 +56,30 @@ type ZTextWriter struct {
 
 // NewZTextWriter creates a new zText writer for the given data path.
 func NewZTextWriter(dataPath string, vers *Versification) *ZTextWriter {
+	return NewZTextWriterWithOptions(dataPath, vers, ZTextOptions{})
+}
+
+// NewZTextWriterWithOptions creates a zText writer with the given driver
+// and block size.
+func NewZTextWriterWithOptions(dataPath string, vers *Versification, opts ZTextOptions) *ZTextWriter {
+	if opts.Driver == "" {
+		opts.Driver = ZTextDriver
+	}
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = DefaultZTextBlockSize
+	}
 	return &ZTextWriter{
 		dataPath: dataPath,
 		vers:     vers,
+		opts:     opts,
 	}
 }
 
+// index4 reports whether verse entries have 4-byte sizes.
+func (w *ZTextWriter) index4() bool {
+	return w.opts.Driver == ZText4Driver
+}
+
 // WriteModule writes a complete zText module from IR corpus.
 // Returns the number of verses written.
 func (w *ZTextWriter) WriteModule(corpus *IRCorpus) (int, error) {
@@ -133,10 +173,13 @@ func (w *ZTextWriter) writeTestament(isNT bool, verseMap map[string]string) (int
 					// Add verse to current block
 					textBytes := []byte(text)
 					offset := w.currentBlockSize
-					size := uint16(len(textBytes))
+					if len(textBytes) > 0xFFFF && !w.index4() {
+						return 0, fmt.Errorf("verse %s is %d bytes, too long for %s (use %s)", ref, len(textBytes), ZTextDriver, ZText4Driver)
+					}
+					size := uint32(len(textBytes))
 
 					w.currentBlock.Write(textBytes)
-					w.currentBlockSize += uint32(size)
+					w.currentBlockSize += size
 					w.addVerseEntry(w.currentBlockNum, offset, size)
 					versesWritten++
 				} else {
@@ -145,8 +188,8 @@ func (w *ZTextWriter) writeTestament(isNT bool, verseMap map[string]string) (int
 				}
 				verseIndex++
 
-				// Flush block if it gets too large (4KB threshold)
-				if w.currentBlock.Len() > 4096 {
+				// Flush block if it gets too large
+				if w.currentBlock.Len() > w.opts.BlockSize {
 					if err := w.flushBlock(); err != nil {
 						return 0, err
 					}
@@ -178,7 +221,7 @@ func (w *ZTextWriter) writeTestament(isNT bool, verseMap map[string]string) (int
 }
 
 // addVerseEntry adds a verse entry to the index.
-func (w *ZTextWriter) addVerseEntry(blockNum, offset uint32, size uint16) {
+func (w *ZTextWriter) addVerseEntry(blockNum, offset, size uint32) {
 	w.verseEntries = append(w.verseEntries, VerseEntry{
 		BlockNum: blockNum,
 		Offset:   offset,
@@ -248,12 +291,20 @@ func (w *ZTextWriter) writeFiles(prefix string) error {
 
 	// Write .bzv (verse index)
 	bzvPath := filepath.Join(w.dataPath, prefix+".bzv")
-	bzvData := make([]byte, len(w.verseEntries)*10)
+	entrySize := VerseIndexEntrySize
+	if w.index4() {
+		entrySize = VerseIndex4EntrySize
+	}
+	bzvData := make([]byte, len(w.verseEntries)*entrySize)
 	for i, entry := range w.verseEntries {
-		offset := i * 10
+		offset := i * entrySize
 		binary.LittleEndian.PutUint32(bzvData[offset:], entry.BlockNum)
 		binary.LittleEndian.PutUint32(bzvData[offset+4:], entry.Offset)
-		binary.LittleEndian.PutUint16(bzvData[offset+8:], entry.Size)
+		if w.index4() {
+			binary.LittleEndian.PutUint32(bzvData[offset+8:], entry.Size)
+		} else {
+			binary.LittleEndian.PutUint16(bzvData[offset+8:], uint16(entry.Size))
+		}
 	}
 	if err := os.WriteFile(bzvPath, bzvData, 0644); err != nil {
 		return fmt.Errorf("failed to write bzv: %w", err)
@@ -265,6 +316,12 @@ func (w *ZTextWriter) writeFiles(prefix string) error {
 // EmitZText writes a complete SWORD module from IR corpus.
 // Creates mods.d/*.conf and modules/texts/ztext/*/ structure.
 func EmitZText(corpus *IRCorpus, outputDir string) (*EmitResult, error) {
+	return EmitZTextWithOptions(corpus, outputDir, ZTextOptions{})
+}
+
+// EmitZTextWithOptions writes a SWORD module like EmitZText, with the
+// given module driver and block size.
+func EmitZTextWithOptions(corpus *IRCorpus, outputDir string, opts ZTextOptions) (*EmitResult, error) {
 	result := &EmitResult{
 		ModuleID: corpus.ID,
 	}
@@ -291,7 +348,7 @@ func EmitZText(corpus *IRCorpus, outputDir string) (*EmitResult, error) {
 	}
 
 	// Write zText data
-	writer := NewZTextWriter(dataPath, vers)
+	writer := NewZTextWriterWithOptions(dataPath, vers, opts)
 	versesWritten, err := writer.WriteModule(corpus)
 	if err != nil {
 		return nil, fmt.Errorf("failed to write zText: %w", err)
@@ -302,6 +359,7 @@ func EmitZText(corpus *IRCorpus, outputDir string) (*EmitResult, error) {
 	confContent := generateConfFromIR(corpus)
 	// Update DataPath to match actual location
 	confContent = updateConfDataPath(confContent, corpus.ID)
+	confContent = updateConfModDrv(confContent, writer.opts.Driver)
 	confPath := filepath.Join(modsDir, stringToLower(corpus.ID)+".conf")
 	if err := os.WriteFile(confPath, []byte(confContent), 0644); err != nil {
 		return nil, fmt.Errorf("failed to write conf: %w", err)
@@ -334,6 +392,17 @@ func updateConfDataPath(conf, moduleID string) string {
 	return joinLines(result)
 }
 
+// updateConfModDrv sets the ModDrv of conf content.
+func updateConfModDrv(conf, modDrv string) string {
+	lines := splitLines(conf)
+	for i, line := range lines {
+		if len(line) > 7 && line[:7] == "ModDrv=" {
+			lines[i] = "ModDrv=" + modDrv
+		}
+	}
+	return joinLines(lines)
+}
+
 // splitLines splits a string into lines.
 func splitLines(s string) []string {
 	var lines []string
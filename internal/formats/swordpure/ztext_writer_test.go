@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -166,6 +167,61 @@ func TestEmitZText(t *testing.T) {
 	}
 }
 
+func TestEmitZTextWithOptions(t *testing.T) {
+	long := strings.Repeat("a", 70000)
+	corpus := &IRCorpus{
+		ID:            "TESTBIBLE",
+		Versification: "KJV",
+		Title:         "Test Bible",
+		Language:      "en",
+		Documents: []*IRDocument{
+			{
+				ID: "Gen",
+				ContentBlocks: []*IRContentBlock{
+					{ID: "Gen.1.1", Text: strings.Repeat("b", 600)},
+					{ID: "Gen.1.2", Text: strings.Repeat("c", 600)},
+					{ID: "Gen.1.3", Text: long},
+				},
+			},
+		},
+	}
+
+	// A verse over 64KB does not fit a zText index
+	if _, err := EmitZText(corpus, t.TempDir()); err == nil {
+		t.Error("EmitZText should fail for a verse over 64KB")
+	}
+
+	tmpDir := t.TempDir()
+	result, err := EmitZTextWithOptions(corpus, tmpDir, ZTextOptions{Driver: ZText4Driver, BlockSize: 512})
+	if err != nil {
+		t.Fatalf("EmitZTextWithOptions failed: %v", err)
+	}
+
+	conf, err := ParseConfFile(result.ConfPath)
+	if err != nil {
+		t.Fatalf("ParseConfFile failed: %v", err)
+	}
+	if conf.ModDrv != ZText4Driver {
+		t.Errorf("ModDrv = %q, want %q", conf.ModDrv, ZText4Driver)
+	}
+
+	mod, err := OpenZTextModule(conf, tmpDir)
+	if err != nil {
+		t.Fatalf("OpenZTextModule failed: %v", err)
+	}
+	// Each 600-byte verse exceeds the block size and closes its block
+	if len(mod.otBlocks) != 3 {
+		t.Errorf("got %d blocks, want 3", len(mod.otBlocks))
+	}
+	text, err := mod.GetVerseText(&Ref{Book: "Gen", Chapter: 1, Verse: 3})
+	if err != nil {
+		t.Fatalf("GetVerseText failed: %v", err)
+	}
+	if text != long {
+		t.Errorf("GetVerseText returned %d bytes, want %d", len(text), len(long))
+	}
+}
+
 func TestUpdateConfDataPath(t *testing.T) {
 	confContent := `[TestModule]
 DataPath=./old/path/
@@ -314,7 +370,7 @@ func TestZTextWriterWriteFiles(t *testing.T) {
 	writer.flushBlock()
 
 	// Add a verse entry
-	writer.addVerseEntry(0, 0, uint16(len(testData)))
+	writer.addVerseEntry(0, 0, uint32(len(testData)))
 
 	// Write files
 	if err := writer.writeFiles("test"); err != nil {
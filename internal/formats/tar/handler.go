@@ -133,9 +133,10 @@ func (h *Handler) Detect(path string) (*plugins.DetectResult, error) {
 	}
 
 	return &plugins.DetectResult{
-		Detected: true,
-		Format:   format,
-		Reason:   fmt.Sprintf("valid %s archive", format),
+		Detected:    true,
+		Format:      format,
+		Reason:      fmt.Sprintf("valid %s archive", format),
+		Specificity: plugins.SpecificityGeneric,
 	}, nil
 }
 
@@ -65,9 +65,11 @@ func (h *Handler) Detect(path string) (*plugins.DetectResult, error) {
 	}
 
 	return &plugins.DetectResult{
-		Detected: true,
-		Format:   "txt",
-		Reason:   "plain text file",
+		Detected:    true,
+		Format:      "txt",
+		Reason:      "plain text file",
+		Confidence:  plugins.ExtensionConfidence,
+		Specificity: plugins.SpecificityGeneric,
 	}, nil
 }
 
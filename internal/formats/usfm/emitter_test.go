@@ -0,0 +1,209 @@
+package usfm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
+)
+
+// paratextSample exercises the USFM 3 features a Paratext project uses.
+const paratextSample = `\id MAT 41MATGNT92.SFM, Good News Translation, June 2003
+\usfm 3.0
+\ide UTF-8
+\rem A remark
+\h Matthew
+\toc1 The Gospel according to Matthew
+\toc2 Matthew
+\toc3 Mat
+\mt2 The Gospel according to
+\mt1 MATTHEW
+\is Introduction
+\ip This is the \bk Gospel\bk* introduction.
+\c 1
+\s1 The Ancestors of Jesus Christ
+\r (Luke 3.23-38)
+\p
+\v 1 This is the list of the ancestors of Jesus Christ,\f + \fr 1.1: \ft Or \fq a descendant\fq* of David.\f* a descendant of David.
+\v 2 From \w Abraham|lemma="avraham" strong="H85" x-morph="N"\w* to King David,\x - \xo 1.2: \xt Gen 21.3\x* the \nd Lord\nd* said \wj Let \+nd there\+nd* be\wj* light.
+\q1
+\v 3 \qt-s |sid="qt_MAT_1:3" who="Pilate"\*Are you the king?\qt-e |eid="qt_MAT_1:3"\* he asked.
+\q2 second line \w grace|grace\w* here.\ts\*
+\v 4-5a Range verse.
+\tr \th1 Tribe \thr2 Number
+\tr \tc1 Reuben \tcr2 46,500
+\periph Title Page|id="title"
+\mt1 A Title
+\c 2
+\cl Chapter Two
+\p
+\v 1 Last \em emphasis\em* verse.
+`
+
+// roundTrip converts USFM to IR, through JSON, and back to USFM.
+func roundTrip(t *testing.T, src string) (*ir.Corpus, string) {
+	t.Helper()
+	corpus, err := parseUSFMToIR([]byte(src))
+	if err != nil {
+		t.Fatalf("parseUSFMToIR failed: %v", err)
+	}
+	data, err := json.Marshal(corpus)
+	if err != nil {
+		t.Fatalf("marshal IR: %v", err)
+	}
+	var decoded ir.Corpus
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal IR: %v", err)
+	}
+	out, err := emitUSFMFromIR(&decoded)
+	if err != nil {
+		t.Fatalf("emitUSFMFromIR failed: %v", err)
+	}
+	return corpus, string(out)
+}
+
+func TestRoundTrip_Paratext(t *testing.T) {
+	_, out := roundTrip(t, paratextSample)
+	if out != paratextSample {
+		t.Errorf("round trip changed the file:\n%s", out)
+	}
+}
+
+func TestRoundTrip_WhitespaceOnly(t *testing.T) {
+	tests := map[string]string{
+		"footnote":   "\\id GEN\n\\c 1\n\\p\n\\v 1 Text\\f  +  \\fr 1.1:  \\ft Note\\f*   more.\n",
+		"cross ref":  "\\id GEN\n\\c 1\n\\p\n\\v 1 Text\\x - \\xo 1.1 \\xt Ps 8.3\\x* more.\n",
+		"word":       "\\id GEN\n\\c 1\n\\p\n\\v 1 \\w In|strong=\"H7225\" lemma=\"reshit\"\\w*\n  the beginning.\n",
+		"heading":    "\\id GEN\n\\c 1\n\\s1   The   Creation\n\\p\n\\v 1 Text.\n",
+		"milestones": "\\id GEN\n\\c 1\n\\p\n\\v 1 \\qt-s |who=\"God\"\\*Light\\qt-e\\*.\n",
+		"nesting":    "\\id GEN\n\\c 1\n\\p\n\\v 1 \\wj Say \\+nd Lord\\+nd*\\wj*\r\n",
+		"poetry":     "\\id PSA\n\\c 1\n\\q1\n\\v 1 Blessed\n\\q2 is the man\n\\b\n",
+		"table":      "\\id NUM\n\\tr \\th1 Tribe \\thr2 Count\n\\tr \\tc1 Reuben \\tcr2 46,500\n",
+		"periph":     "\\id FRT\n\\periph Title Page|id=\"title\"\n\\mt1 Holy Bible\n",
+		"header":     "\\id GEN\n\\rem one\n\\rem two\n\\mt2 The Book of\n\\mt1 Genesis\n\\c 1\n\\p\n\\v 1 Text.\n",
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, out := roundTrip(t, src)
+			if got, want := strings.Fields(out), strings.Fields(src); strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("round trip changed more than whitespace:\n got %q\nwant %q", out, src)
+			}
+		})
+	}
+}
+
+func TestParseUSFMToIR_Structure(t *testing.T) {
+	corpus, _ := roundTrip(t, paratextSample)
+	doc := corpus.Documents[0]
+
+	if corpus.LossClass != ir.LossL1 {
+		t.Errorf("LossClass = %q, want L1", corpus.LossClass)
+	}
+	if doc.Attributes["toc3"] != "Mat" || doc.Attributes["title_order"] != "mt2 mt1" {
+		t.Errorf("header attributes = %v", doc.Attributes)
+	}
+
+	var verse *ir.ContentBlock
+	spans := map[ir.SpanType][]*ir.Span{}
+	for _, b := range doc.ContentBlocks {
+		for _, a := range b.Anchors {
+			for _, s := range a.Spans {
+				spans[s.Type] = append(spans[s.Type], s)
+				if s.Type == ir.SpanVerse && s.Ref.Verse == 2 && s.Ref.Chapter == 1 {
+					verse = b
+				}
+			}
+		}
+	}
+	if verse == nil {
+		t.Fatal("verse 1:2 not found")
+	}
+	if want := "From Abraham to King David, the Lord said Let there be light."; verse.Text != want {
+		t.Errorf("verse text = %q, want %q", verse.Text, want)
+	}
+
+	for _, st := range []ir.SpanType{
+		ir.SpanChapter, ir.SpanSection, ir.SpanNote, ir.SpanCrossRef, ir.SpanWord,
+		ir.SpanDivine, ir.SpanRedLetter, ir.SpanQuotation, ir.SpanMilestone,
+		ir.SpanTableCell, ir.SpanPoetryLine, ir.SpanCharStyle, ir.SpanEmphasis,
+	} {
+		if len(spans[st]) == 0 {
+			t.Errorf("no %s span", st)
+		}
+	}
+	if n := len(spans[ir.SpanTableCell]); n != 4 {
+		t.Errorf("table cells = %d, want 4", n)
+	}
+
+	ranges := spans[ir.SpanVerse]
+	if s := ranges[len(ranges)-2]; s.Ref.Verse != 4 || s.Ref.VerseEnd != 5 || s.Attributes["number"] != "4-5a" {
+		t.Errorf("verse 4-5a = %+v %v", *s.Ref, s.Attributes)
+	}
+
+	anns := map[ir.AnnotationType]interface{}{}
+	for _, a := range doc.Annotations {
+		anns[a.Type] = a.Value
+	}
+	want := map[ir.AnnotationType]interface{}{
+		ir.AnnotationFootnote:   "Or a descendant of David.",
+		ir.AnnotationCrossRef:   "Gen 21.3",
+		ir.AnnotationStrongs:    "H85",
+		ir.AnnotationMorphology: "N",
+	}
+	for k, v := range want {
+		if anns[k] != v {
+			t.Errorf("annotation %s = %v, want %v", k, anns[k], v)
+		}
+	}
+
+	var abraham *ir.Token
+	for _, tok := range verse.Tokens {
+		if tok.Text == "Abraham" {
+			abraham = tok
+		}
+	}
+	if abraham == nil || abraham.Lemma != "avraham" || len(abraham.Strongs) != 1 || abraham.Strongs[0] != "H85" {
+		t.Errorf("Abraham token = %+v", abraham)
+	}
+}
+
+func TestParseUSFMToIR_UnclosedMarkup(t *testing.T) {
+	corpus, out := roundTrip(t, "\\id GEN\n\\c 1\n\\p\n\\v 1 An \\it open style\n\\p\n\\v 2 A note\\f + \\ft never closed\n\\v 3 Next.\n")
+
+	if len(corpus.Documents[0].ContentBlocks) != 3 {
+		t.Errorf("blocks = %d, want 3", len(corpus.Documents[0].ContentBlocks))
+	}
+	if strings.Contains(out, "\\it*") || strings.Contains(out, "\\f*") {
+		t.Errorf("unclosed markup should stay unclosed:\n%s", out)
+	}
+	if !strings.Contains(out, "\\v 3 Next.") {
+		t.Errorf("verse after unclosed note lost:\n%s", out)
+	}
+}
+
+func TestEmitUSFMFromIR_Generic(t *testing.T) {
+	corpus := &ir.Corpus{
+		ID: "GEN",
+		Documents: []*ir.Document{{
+			ID:    "GEN",
+			Title: "Genesis",
+			ContentBlocks: []*ir.ContentBlock{
+				{ID: "cb-1", Text: "In the beginning.", Anchors: []*ir.Anchor{{ID: "a-1", Spans: []*ir.Span{
+					{ID: "s-1", Type: ir.SpanVerse, Ref: &ir.Ref{Book: "GEN", Chapter: 1, Verse: 1}},
+				}}}},
+				{ID: "cb-2", Text: "And the earth.", Anchors: []*ir.Anchor{{ID: "a-2", Spans: []*ir.Span{
+					{ID: "s-2", Type: ir.SpanVerse, Ref: &ir.Ref{Book: "GEN", Chapter: 1, Verse: 2}},
+				}}}},
+			},
+		}},
+	}
+	out, err := emitUSFMFromIR(corpus)
+	if err != nil {
+		t.Fatalf("emitUSFMFromIR failed: %v", err)
+	}
+	want := "\\id GEN\n\\h Genesis\n\\c 1\n\\p\n\\v 1 In the beginning.\n\\v 2 And the earth.\n"
+	if string(out) != want {
+		t.Errorf("output =\n%s\nwant\n%s", out, want)
+	}
+}
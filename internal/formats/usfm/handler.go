@@ -2,6 +2,7 @@
 package usfm
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -28,6 +29,12 @@ func Manifest() *plugins.PluginManifest {
 			Inputs:  []string{"file"},
 			Outputs: []string{"artifact.kind:usfm"},
 		},
+		IRSupport: &plugins.IRCapabilities{
+			CanExtract: true,
+			CanEmit:    true,
+			LossClass:  "L1",
+			Formats:    []string{"USFM", "SFM"},
+		},
 	}
 }
 
@@ -76,9 +83,10 @@ func (h *Handler) Detect(path string) (*plugins.DetectResult, error) {
 	ext := strings.ToLower(filepath.Ext(path))
 	if ext == ".usfm" || ext == ".sfm" || ext == ".ptx" {
 		return &plugins.DetectResult{
-			Detected: true,
-			Format:   "USFM",
-			Reason:   "USFM file extension detected",
+			Detected:   true,
+			Format:     "USFM",
+			Reason:     "USFM file extension detected",
+			Confidence: plugins.ExtensionConfidence,
 		}, nil
 	}
 
@@ -142,6 +150,12 @@ func (h *Handler) Enumerate(path string) (*plugins.EnumerateResult, error) {
 
 // ExtractIR implements EmbeddedFormatHandler.ExtractIR.
 func (h *Handler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, error) {
+	return h.ExtractIRContext(context.Background(), path, outputDir)
+}
+
+// ExtractIRContext implements plugins.IRExtractorContext. Parsing reports
+// its progress and stops at the next chapter when ctx is cancelled.
+func (h *Handler) ExtractIRContext(ctx context.Context, path, outputDir string) (*plugins.ExtractIRResult, error) {
 	// Read USFM file
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -149,7 +163,7 @@ func (h *Handler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, e
 	}
 
 	// Parse USFM
-	corpus, err := parseUSFMToIR(data)
+	corpus, err := parseUSFMToIRContext(ctx, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse USFM: %w", err)
 	}
@@ -169,11 +183,22 @@ func (h *Handler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, e
 	return &plugins.ExtractIRResult{
 		IRPath:    irPath,
 		LossClass: string(corpus.LossClass),
+		LossReport: &plugins.LossReportIPC{
+			SourceFormat: "USFM",
+			TargetFormat: "IR",
+			LossClass:    string(corpus.LossClass),
+		},
 	}, nil
 }
 
 // EmitNative implements EmbeddedFormatHandler.EmitNative.
 func (h *Handler) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResult, error) {
+	return h.EmitNativeContext(context.Background(), irPath, outputDir)
+}
+
+// EmitNativeContext implements plugins.NativeEmitterContext. Emitting
+// reports its progress and stops between blocks when ctx is cancelled.
+func (h *Handler) EmitNativeContext(ctx context.Context, irPath, outputDir string) (*plugins.EmitNativeResult, error) {
 	// Read IR file
 	data, err := os.ReadFile(irPath)
 	if err != nil {
@@ -187,7 +212,7 @@ func (h *Handler) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResul
 	}
 
 	// Convert IR to USFM
-	usfmData, err := emitUSFMFromIR(&corpus)
+	usfmData, err := emitUSFMFromIRContext(ctx, &corpus)
 	if err != nil {
 		return nil, fmt.Errorf("failed to emit USFM: %w", err)
 	}
@@ -202,5 +227,10 @@ func (h *Handler) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResul
 		OutputPath: outputPath,
 		Format:     "USFM",
 		LossClass:  string(corpus.LossClass),
+		LossReport: &plugins.LossReportIPC{
+			SourceFormat: "IR",
+			TargetFormat: "USFM",
+			LossClass:    string(corpus.LossClass),
+		},
 	}, nil
 }
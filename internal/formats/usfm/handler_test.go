@@ -1,12 +1,15 @@
 package usfm
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 )
 
 func TestManifest(t *testing.T) {
@@ -468,3 +471,47 @@ func TestEmitNativeWriteError(t *testing.T) {
 		t.Error("Expected error for non-writable output directory")
 	}
 }
+
+func TestExtractIRContextCancelledPartway(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "gen.usfm")
+	usfm := "\\id GEN\n\\c 1\n\\v 1 In the beginning\n\\c 2\n\\v 1 Thus the heavens\n\\c 3\n\\v 1 Now the serpent\n"
+	if err := os.WriteFile(path, []byte(usfm), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	h := &Handler{}
+
+	result, err := h.ExtractIRContext(context.Background(), path, tmpDir)
+	if err != nil {
+		t.Fatalf("ExtractIRContext failed: %v", err)
+	}
+
+	// Cancel when parsing reaches chapter 2
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chapters := 0
+	ctx = plugins.WithProgress(ctx, func(ev plugins.ProgressEvent) {
+		if chapters++; chapters == 2 {
+			cancel()
+		}
+	})
+	outDir := t.TempDir()
+	if _, err := h.ExtractIRContext(ctx, path, outDir); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from ExtractIRContext, got %v", err)
+	}
+	if chapters != 2 {
+		t.Errorf("expected parsing to stop at chapter 2, saw %d chapters", chapters)
+	}
+
+	// Cancel once emitting has started
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	ctx = plugins.WithProgress(ctx, func(plugins.ProgressEvent) { cancel() })
+	if _, err := h.EmitNativeContext(ctx, result.IRPath, outDir); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from EmitNativeContext, got %v", err)
+	}
+
+	if entries, _ := os.ReadDir(outDir); len(entries) != 0 {
+		t.Errorf("expected no output from cancelled calls, got %d files", len(entries))
+	}
+}
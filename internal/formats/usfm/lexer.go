@@ -0,0 +1,103 @@
+package usfm
+
+// tokenKind is the kind of a USFM token.
+type tokenKind int
+
+const (
+	tokText         tokenKind = iota // Text between markers
+	tokMarker                        // Opening marker: \p, \v, \nd, \+w, \qt-s
+	tokEndMarker                     // Closing marker: \nd*, \+w*, \f*
+	tokMilestoneEnd                  // \* closing a milestone
+	tokAttributes                    // |attributes of a character style or milestone
+)
+
+// token is a lexical unit of USFM source.
+type token struct {
+	kind   tokenKind
+	marker string // Marker name, without backslash, plus sign or star
+	nested bool   // Marker had the + prefix of a nested character style
+	text   string // Text, or the attribute source after the bar
+	raw    string // Source of the token
+}
+
+// isMarkerChar reports whether c may appear in a marker name.
+func isMarkerChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-'
+}
+
+// tokenize splits USFM source into tokens. An opening marker consumes the
+// single space or line break that ends it; all other whitespace is left in
+// the text tokens.
+func tokenize(src string) []token {
+	var tokens []token
+	textStart := -1
+
+	flushText := func(end int) {
+		if textStart >= 0 && end > textStart {
+			tokens = append(tokens, token{kind: tokText, text: src[textStart:end], raw: src[textStart:end]})
+		}
+		textStart = -1
+	}
+
+	i := 0
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == '\\' && i+1 < len(src) && src[i+1] == '*':
+			flushText(i)
+			tokens = append(tokens, token{kind: tokMilestoneEnd, raw: `\*`})
+			i += 2
+
+		case c == '\\':
+			j := i + 1
+			nested := j < len(src) && src[j] == '+'
+			if nested {
+				j++
+			}
+			nameStart := j
+			for j < len(src) && isMarkerChar(src[j]) {
+				j++
+			}
+			if j == nameStart {
+				// A lone backslash is text
+				if textStart < 0 {
+					textStart = i
+				}
+				i++
+				continue
+			}
+			flushText(i)
+			tok := token{kind: tokMarker, marker: src[nameStart:j], nested: nested}
+			if j < len(src) && src[j] == '*' {
+				tok.kind = tokEndMarker
+				j++
+			} else if j < len(src) {
+				switch {
+				case src[j] == '\r' && j+1 < len(src) && src[j+1] == '\n':
+					j += 2
+				case src[j] == ' ' || src[j] == '\t' || src[j] == '\n' || src[j] == '\r':
+					j++
+				}
+			}
+			tok.raw = src[i:j]
+			tokens = append(tokens, tok)
+			i = j
+
+		case c == '|':
+			flushText(i)
+			j := i + 1
+			for j < len(src) && src[j] != '\\' {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokAttributes, text: src[i+1 : j], raw: src[i:j]})
+			i = j
+
+		default:
+			if textStart < 0 {
+				textStart = i
+			}
+			i++
+		}
+	}
+	flushText(len(src))
+	return tokens
+}
@@ -0,0 +1,212 @@
+package usfm
+
+import (
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/core/ir"
+)
+
+// markerKind is how the parser treats a marker.
+type markerKind int
+
+const (
+	kindChar     markerKind = iota // Character style closed with \xx*; also unknown markers
+	kindHeader                     // Identification line or main title, kept as a document attribute
+	kindBlock                      // Paragraph starting its own block: headings, introductions, table rows
+	kindFlow                       // Body paragraph or poetry line, which verses flow through
+	kindChapter                    // \c
+	kindVerse                      // \v
+	kindNote                       // Footnote, endnote or cross-reference
+	kindNoteChar                   // Marker inside a note: \fr, \ft, \xo, \xt
+	kindCell                       // Table cell
+)
+
+// markerInfo describes how a marker maps to the IR.
+type markerInfo struct {
+	kind      markerKind
+	span      ir.SpanType
+	blockType string // Type attribute of the block a paragraph marker starts
+}
+
+// Block types recorded in the "type" attribute of content blocks.
+const (
+	blockVerse     = "verse"
+	blockParagraph = "paragraph"
+	blockPoetry    = "poetry"
+	blockHeading   = "heading"
+	blockTitle     = "title"
+	blockIntro     = "intro"
+	blockTable     = "table"
+	blockPeriph    = "periph"
+	blockRemark    = "remark"
+)
+
+// markers maps USFM 3 marker names, without their number, to the IR.
+var markers = map[string]markerInfo{
+	// Identification and main titles
+	"usfm": {kind: kindHeader}, "ide": {kind: kindHeader}, "sts": {kind: kindHeader},
+	"rem": {kind: kindHeader}, "h": {kind: kindHeader}, "toc": {kind: kindHeader},
+	"toca": {kind: kindHeader}, "mt": {kind: kindHeader},
+
+	// Chapters and verses
+	"c": {kind: kindChapter, span: ir.SpanChapter},
+	"v": {kind: kindVerse, span: ir.SpanVerse},
+
+	// Titles, headings and labels
+	"mte":    {kind: kindBlock, span: ir.SpanTitle, blockType: blockTitle},
+	"ms":     {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"mr":     {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"s":      {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"sr":     {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"r":      {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"d":      {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"sp":     {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"sd":     {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"qa":     {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"cl":     {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"cd":     {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"cp":     {kind: kindBlock, span: ir.SpanSection, blockType: blockHeading},
+	"periph": {kind: kindBlock, span: ir.SpanSection, blockType: blockPeriph},
+
+	// Introductions
+	"imt":  {kind: kindBlock, span: ir.SpanTitle, blockType: blockIntro},
+	"imte": {kind: kindBlock, span: ir.SpanTitle, blockType: blockIntro},
+	"is":   {kind: kindBlock, span: ir.SpanSection, blockType: blockIntro},
+	"iot":  {kind: kindBlock, span: ir.SpanSection, blockType: blockIntro},
+	"ip":   {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"ipi":  {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"im":   {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"imi":  {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"ipq":  {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"imq":  {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"ipr":  {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"ipc":  {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"ib":   {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"ili":  {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"io":   {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"iex":  {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"ie":   {kind: kindBlock, span: ir.SpanParagraph, blockType: blockIntro},
+	"iq":   {kind: kindBlock, span: ir.SpanPoetryLine, blockType: blockIntro},
+
+	// Tables
+	"tr":  {kind: kindBlock, span: ir.SpanParagraph, blockType: blockTable},
+	"th":  {kind: kindCell, span: ir.SpanTableCell},
+	"thr": {kind: kindCell, span: ir.SpanTableCell},
+	"thc": {kind: kindCell, span: ir.SpanTableCell},
+	"tc":  {kind: kindCell, span: ir.SpanTableCell},
+	"tcr": {kind: kindCell, span: ir.SpanTableCell},
+	"tcc": {kind: kindCell, span: ir.SpanTableCell},
+
+	// Body paragraphs and lists
+	"p":   {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"m":   {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"po":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"pr":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"cls": {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"pmo": {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"pm":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"pmc": {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"pmr": {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"pi":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"mi":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"nb":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"pc":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"ph":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"lh":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"li":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"lf":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"lim": {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"lit": {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"b":   {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+	"pb":  {kind: kindFlow, span: ir.SpanParagraph, blockType: blockParagraph},
+
+	// Poetry
+	"q":  {kind: kindFlow, span: ir.SpanPoetryLine, blockType: blockPoetry},
+	"qr": {kind: kindFlow, span: ir.SpanPoetryLine, blockType: blockPoetry},
+	"qc": {kind: kindFlow, span: ir.SpanPoetryLine, blockType: blockPoetry},
+	"qm": {kind: kindFlow, span: ir.SpanPoetryLine, blockType: blockPoetry},
+	"qd": {kind: kindFlow, span: ir.SpanPoetryLine, blockType: blockPoetry},
+
+	// Notes
+	"f":  {kind: kindNote, span: ir.SpanNote},
+	"fe": {kind: kindNote, span: ir.SpanNote},
+	"ef": {kind: kindNote, span: ir.SpanNote},
+	"x":  {kind: kindNote, span: ir.SpanCrossRef},
+	"ex": {kind: kindNote, span: ir.SpanCrossRef},
+
+	// Character styles with a span type of their own; the others are CHAR_STYLE
+	"w":    {kind: kindChar, span: ir.SpanWord},
+	"nd":   {kind: kindChar, span: ir.SpanDivine},
+	"wj":   {kind: kindChar, span: ir.SpanRedLetter},
+	"em":   {kind: kindChar, span: ir.SpanEmphasis},
+	"bd":   {kind: kindChar, span: ir.SpanEmphasis},
+	"it":   {kind: kindChar, span: ir.SpanEmphasis},
+	"bdit": {kind: kindChar, span: ir.SpanEmphasis},
+	"tl":   {kind: kindChar, span: ir.SpanForeign},
+	"qs":   {kind: kindChar, span: ir.SpanSelah},
+	"qt":   {kind: kindChar, span: ir.SpanQuotation},
+}
+
+// noteMarkers are the markers structuring the content of a note.
+var noteMarkers = map[string]bool{
+	"fr": true, "ft": true, "fk": true, "fq": true, "fqa": true, "fl": true,
+	"fw": true, "fp": true, "fv": true, "fdc": true, "fm": true,
+	"xo": true, "xk": true, "xq": true, "xt": true, "xta": true, "xop": true,
+	"xot": true, "xnt": true, "xdc": true,
+}
+
+// defaultAttributes names the attribute given without a name, as in
+// \w grace|grace\w*.
+var defaultAttributes = map[string]string{
+	"w":   "lemma",
+	"rb":  "gloss",
+	"xt":  "link-href",
+	"jmp": "link-href",
+	"ref": "loc",
+}
+
+// baseMarker strips the number of a numbered marker: q2 and th1-2 are q
+// and th.
+func baseMarker(name string) string {
+	if i := strings.LastIndexByte(name, '-'); i > 0 && isDigits(name[i+1:]) {
+		name = name[:i]
+	}
+	return strings.TrimRight(name, "0123456789")
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupMarker returns how a marker maps to the IR. Unknown markers, such
+// as the z markers of a project stylesheet, are character styles.
+func lookupMarker(name string) markerInfo {
+	base := baseMarker(name)
+	if info, ok := markers[base]; ok {
+		return info
+	}
+	if noteMarkers[base] {
+		return markerInfo{kind: kindNoteChar, span: ir.SpanCharStyle}
+	}
+	return markerInfo{kind: kindChar, span: ir.SpanCharStyle}
+}
+
+// milestoneMarker splits a milestone marker such as qt1-s into its name
+// and end: "qt1" and 's'. It returns false for other markers.
+func milestoneMarker(name string) (string, byte, bool) {
+	if len(name) > 2 && name[len(name)-2] == '-' {
+		if end := name[len(name)-1]; end == 's' || end == 'e' {
+			return name[:len(name)-2], end, true
+		}
+	}
+	return "", 0, false
+}
@@ -2,15 +2,16 @@
 package usfm
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 )
 
 // USFM parsing helpers
@@ -18,6 +19,8 @@ var (
 	markerRegex   = regexp.MustCompile(`\\([a-zA-Z0-9]+)\*?(?:\s|$)`)
 	verseNumRegex = regexp.MustCompile(`^(\d+)(?:-(\d+))?`)
 	chapterRegex  = regexp.MustCompile(`^(\d+)`)
+	subVerseRegex = regexp.MustCompile(`^\d+([a-z])`)
+	attrRegex     = regexp.MustCompile(`([A-Za-z0-9_-]+)\s*=\s*"([^"]*)"`)
 )
 
 // Common USFM book IDs
@@ -43,175 +46,792 @@ var bookNames = map[string]string{
 
 // parseUSFMToIR converts USFM text to IR Corpus
 func parseUSFMToIR(data []byte) (*ir.Corpus, error) {
-	corpus := &ir.Corpus{
-		Version:    "1.0.0",
-		ModuleType: ir.ModuleBible,
-		LossClass:  ir.LossL0,
-		Documents:  []*ir.Document{},
+	return parseUSFMToIRContext(context.Background(), data)
+}
+
+// parseUSFMToIRContext is parseUSFMToIR that reports its progress at each
+// chapter and stops there when ctx is cancelled.
+func parseUSFMToIRContext(ctx context.Context, data []byte) (*ir.Corpus, error) {
+	p := &parser{
+		corpus: &ir.Corpus{
+			Version:      "1.0.0",
+			ModuleType:   ir.ModuleBible,
+			SourceFormat: "USFM",
+			LossClass:    ir.LossL1,
+			Documents:    []*ir.Document{},
+		},
+		toks: tokenize(string(data)),
+	}
+	if err := p.parse(ctx); err != nil {
+		return nil, err
+	}
+
+	// A file without a book code still needs a corpus ID
+	if p.corpus.ID == "" {
+		p.corpus.ID = "usfm"
+	}
+
+	// Compute source hash
+	h := sha256.Sum256(data)
+	p.corpus.SourceHash = hex.EncodeToString(h[:])
+
+	return p.corpus, nil
+}
+
+// parser builds the IR of a USFM file.
+//
+// Each verse is a content block; headings, introduction paragraphs and table
+// rows are blocks of their own. Paragraph, poetry, chapter and verse markers
+// become spans anchored where they occur, so a verse flowing over several
+// poetry lines stays one block. Character styles, notes and milestones are
+// spans carrying their marker and attributes, which is what the emitter
+// needs to write the file back.
+type parser struct {
+	corpus *ir.Corpus
+	doc    *ir.Document
+	block  *ir.ContentBlock
+	toks   []token
+	pos    int
+
+	blockSeq int
+	spanSeq  int
+	annSeq   int
+	chapter  int
+	titles   []string // Order of the main title markers
+
+	inBody      bool       // A chapter, verse or paragraph marker has been seen
+	inVerse     bool       // The current block is the current verse
+	pending     []*ir.Span // Chapter and paragraph spans waiting for their content
+	pendingType string     // Block type of the pending paragraph
+	para        *ir.Span   // Paragraph span of the current block, which may carry attributes
+	chars       []*ir.Span // Open character styles, innermost last
+	milestones  []*ir.Span // Open milestones
+}
+
+func (p *parser) parse(ctx context.Context) error {
+	for p.pos < len(p.toks) {
+		tok := p.toks[p.pos]
+		if tok.kind == tokMarker && tok.marker == "c" {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			plugins.ReportProgress(ctx, plugins.ProgressEvent{Stage: "parse", Done: int64(p.pos), Total: int64(len(p.toks))})
+		}
+		p.pos++
+		switch tok.kind {
+		case tokText:
+			if p.doc != nil {
+				p.text(tok.text)
+			}
+		case tokMarker:
+			p.marker(tok)
+		case tokEndMarker:
+			if p.doc != nil {
+				p.endMarker(tok)
+			}
+		case tokAttributes:
+			if p.doc != nil {
+				p.attributes(tok)
+			}
+		}
+	}
+	p.finishDocument()
+	return nil
+}
+
+func (p *parser) marker(tok token) {
+	if tok.marker == "id" {
+		p.startDocument()
+		return
+	}
+	if p.doc == nil {
+		// Content before \id is ignored
+		return
+	}
+	if name, end, ok := milestoneMarker(tok.marker); ok {
+		p.milestone(name, end)
+		return
+	}
+	if p.standalone() {
+		p.milestone(tok.marker, 0)
+		return
+	}
+
+	info := lookupMarker(tok.marker)
+	switch info.kind {
+	case kindHeader:
+		switch base := baseMarker(tok.marker); {
+		case !p.inBody:
+			p.header(tok.marker)
+		case base == "rem":
+			p.blockMarker(tok.marker, markerInfo{span: ir.SpanParagraph, blockType: blockRemark})
+		case base == "mt":
+			p.blockMarker(tok.marker, markerInfo{span: ir.SpanTitle, blockType: blockTitle})
+		default:
+			p.header(tok.marker)
+		}
+	case kindChapter:
+		p.chapterMarker()
+	case kindVerse:
+		p.verseMarker()
+	case kindBlock:
+		p.blockMarker(tok.marker, info)
+	case kindFlow:
+		p.closeChars()
+		p.inBody = true
+		p.pending = append(p.pending, p.newSpan(info.span, tok.marker))
+		p.pendingType = info.blockType
+	case kindNote:
+		p.note(tok.marker, info)
+	case kindCell:
+		p.inline()
+		p.addAnchor(p.newSpan(info.span, tok.marker))
+	default:
+		p.inline()
+		span := p.newSpan(info.span, tok.marker)
+		p.addAnchor(span)
+		p.chars = append(p.chars, span)
+	}
+}
+
+// startDocument starts the book named by an \id line.
+func (p *parser) startDocument() {
+	p.finishDocument()
+
+	fields := strings.Fields(p.readLine())
+	if len(fields) == 0 {
+		return
+	}
+	bookID := strings.ToUpper(fields[0])
+	if p.corpus.ID == "" {
+		p.corpus.ID = bookID
+	}
+	p.doc = &ir.Document{
+		ID:            bookID,
+		Order:         len(p.corpus.Documents) + 1,
+		ContentBlocks: []*ir.ContentBlock{},
+		Attributes:    map[string]string{},
+	}
+	if name, ok := bookNames[bookID]; ok {
+		p.doc.Title = name
+	}
+	if len(fields) > 1 {
+		p.doc.Attributes["id"] = strings.Join(fields[1:], " ")
+	}
+	p.corpus.Documents = append(p.corpus.Documents, p.doc)
+}
+
+// finishDocument completes the current book.
+func (p *parser) finishDocument() {
+	if p.doc == nil {
+		return
+	}
+	p.closeChars()
+	if len(p.pending) > 0 {
+		// Markers without content, such as a closing \b
+		p.startBlock(p.pendingType, p.pendingMarker())
+	}
+	p.finishBlock()
+	if len(p.titles) > 1 {
+		p.doc.Attributes["title_order"] = strings.Join(p.titles, " ")
+	}
+	if len(p.doc.Attributes) == 0 {
+		p.doc.Attributes = nil
+	}
+	p.tokenizeWords()
+
+	p.doc = nil
+	p.chapter = 0
+	p.titles = nil
+	p.inBody, p.inVerse = false, false
+	p.pending, p.para, p.chars, p.milestones = nil, nil, nil, nil
+}
+
+// header stores an identification or main title line as a document
+// attribute named after its marker.
+func (p *parser) header(marker string) {
+	value := p.readLine()
+	if prev, ok := p.doc.Attributes[marker]; ok {
+		value = prev + "\n" + value
+	}
+	p.doc.Attributes[marker] = value
+
+	switch baseMarker(marker) {
+	case "h":
+		if p.doc.Title == "" {
+			p.doc.Title = value
+		}
+	case "mt":
+		p.titles = append(p.titles, marker)
+		if p.corpus.Title == "" {
+			p.corpus.Title = value
+		}
+	}
+}
+
+// readLine consumes the text following a marker.
+func (p *parser) readLine() string {
+	var sb strings.Builder
+	for p.pos < len(p.toks) && p.toks[p.pos].kind == tokText {
+		sb.WriteString(p.toks[p.pos].text)
+		p.pos++
+	}
+	return strings.TrimSpace(normalizeSpace(sb.String()))
+}
+
+// readNumber consumes the number following \c or \v.
+func (p *parser) readNumber() string {
+	if p.pos >= len(p.toks) || p.toks[p.pos].kind != tokText {
+		return ""
+	}
+	tok := &p.toks[p.pos]
+	s := strings.TrimLeft(tok.text, " \t\r\n")
+	end := strings.IndexAny(s, " \t\r\n")
+	if end < 0 {
+		end = len(s)
+	}
+	num, rest := s[:end], s[end:]
+	if rest != "" {
+		// The space ending the number
+		rest = rest[1:]
+	}
+	if strings.TrimSpace(rest) == "" {
+		p.pos++
+	} else {
+		tok.text = rest
+	}
+	return num
+}
+
+func (p *parser) chapterMarker() {
+	p.closeChars()
+	p.inBody, p.inVerse = true, false
+
+	num := p.readNumber()
+	if m := chapterRegex.FindStringSubmatch(num); m != nil {
+		p.chapter, _ = strconv.Atoi(m[1])
+	}
+	span := p.newSpan(ir.SpanChapter, "c")
+	span.SetAttribute("number", num)
+	span.Ref = &ir.Ref{
+		Book:    p.doc.ID,
+		Chapter: p.chapter,
+		OSISID:  fmt.Sprintf("%s.%d", p.doc.ID, p.chapter),
+	}
+	p.pending = append(p.pending, span)
+}
+
+func (p *parser) verseMarker() {
+	p.inBody = true
+
+	num := p.readNumber()
+	span := p.newSpan(ir.SpanVerse, "v")
+	span.SetAttribute("number", num)
+	ref := &ir.Ref{Book: p.doc.ID, Chapter: p.chapter}
+	if m := verseNumRegex.FindStringSubmatch(num); m != nil {
+		ref.Verse, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			ref.VerseEnd, _ = strconv.Atoi(m[2])
+		}
+	}
+	if m := subVerseRegex.FindStringSubmatch(num); m != nil {
+		ref.SubVerse = m[1]
+	}
+	ref.OSISID = fmt.Sprintf("%s.%d.%d", p.doc.ID, ref.Chapter, ref.Verse)
+	span.Ref = ref
+
+	// The verse span leads the anchor so it is found first
+	p.pending = append([]*ir.Span{span}, p.pending...)
+	p.startBlock(blockVerse, "")
+	p.inVerse = true
+}
+
+// blockMarker starts a heading, introduction, table row or other
+// paragraph that is a block of its own.
+func (p *parser) blockMarker(marker string, info markerInfo) {
+	p.closeChars()
+	p.inBody, p.inVerse = true, false
+	span := p.newSpan(info.span, marker)
+	p.pending = append(p.pending, span)
+	p.startBlock(info.blockType, marker)
+	p.para = span
+}
+
+// startBlock starts a content block whose first anchor holds the pending
+// spans.
+func (p *parser) startBlock(blockType, marker string) {
+	p.finishBlock()
+
+	p.blockSeq++
+	if blockType == "" {
+		blockType = blockParagraph
+	}
+	p.block = &ir.ContentBlock{
+		ID:         fmt.Sprintf("cb-%d", p.blockSeq),
+		Sequence:   p.blockSeq,
+		Attributes: map[string]interface{}{"type": blockType},
+	}
+	if marker != "" {
+		p.block.Attributes["marker"] = marker
+	}
+	p.doc.ContentBlocks = append(p.doc.ContentBlocks, p.block)
+
+	p.addAnchor(p.pending...)
+	p.pending = nil
+	p.para = nil
+	p.inVerse = false
+}
+
+// finishBlock trims the text of the current block and computes its hash.
+func (p *parser) finishBlock() {
+	b := p.block
+	if b == nil {
+		return
+	}
+	b.Text = strings.TrimRight(b.Text, " ")
+	for _, a := range b.Anchors {
+		if a.CharOffset > len(b.Text) {
+			a.CharOffset = len(b.Text)
+		}
+	}
+	b.ComputeHash()
+	p.block = nil
+}
+
+// pendingMarker returns the marker of the last pending paragraph.
+func (p *parser) pendingMarker() string {
+	for i := len(p.pending) - 1; i >= 0; i-- {
+		if s := p.pending[i]; s.Type != ir.SpanChapter && s.Type != ir.SpanVerse {
+			m, _ := s.Attributes["marker"].(string)
+			return m
+		}
+	}
+	return ""
+}
+
+// inline makes the current block ready for text or an inline span.
+// Pending paragraph markers either continue the open verse or start a
+// paragraph block.
+func (p *parser) inline() {
+	switch {
+	case len(p.pending) > 0 && p.inVerse:
+		if p.block.Text != "" && !strings.HasSuffix(p.block.Text, " ") {
+			p.block.Text += " "
+		}
+		p.addAnchor(p.pending...)
+		p.pending = nil
+	case len(p.pending) > 0:
+		p.startBlock(p.pendingType, p.pendingMarker())
+	case p.block == nil:
+		p.startBlock(blockParagraph, "")
+	}
+}
+
+func (p *parser) text(s string) {
+	s = normalizeSpace(s)
+	if strings.TrimSpace(s) == "" {
+		// Whitespace between markers only separates words of a block
+		if p.block == nil || len(p.pending) > 0 {
+			return
+		}
+	} else {
+		p.inline()
 	}
+	if p.block.Text == "" || strings.HasSuffix(p.block.Text, " ") {
+		s = strings.TrimLeft(s, " ")
+	}
+	p.block.Text += s
+}
 
-	var currentDoc *ir.Document
-	var blockSeq int
+// normalizeSpace turns line breaks and tabs into spaces and collapses runs
+// of spaces.
+func normalizeSpace(s string) string {
+	var sb strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+func (p *parser) newSpan(spanType ir.SpanType, marker string) *ir.Span {
+	p.spanSeq++
+	return &ir.Span{
+		ID:         fmt.Sprintf("s-%d", p.spanSeq),
+		Type:       spanType,
+		Attributes: map[string]interface{}{"marker": marker},
+	}
+}
 
-	scanner := bufio.NewScanner(bytes.NewReader(data))
-	for scanner.Scan() {
-		line := scanner.Text()
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" {
+// addAnchor adds an anchor at the end of the current block text where the
+// given spans start.
+func (p *parser) addAnchor(spans ...*ir.Span) *ir.Anchor {
+	a := &ir.Anchor{
+		ID:             fmt.Sprintf("a-%d-%d", p.blockSeq, len(p.block.Anchors)),
+		ContentBlockID: p.block.ID,
+		CharOffset:     len(p.block.Text),
+	}
+	if len(spans) > 0 {
+		a.Spans = spans
+	}
+	for _, s := range spans {
+		s.StartAnchorID = a.ID
+	}
+	p.block.Anchors = append(p.block.Anchors, a)
+	return a
+}
+
+// endSpan ends a span at the end of the current block text.
+func (p *parser) endSpan(span *ir.Span) {
+	span.EndAnchorID = p.addAnchor().ID
+}
+
+func (p *parser) endMarker(tok token) {
+	for i := len(p.chars) - 1; i >= 0; i-- {
+		if p.chars[i].Attributes["marker"] != tok.marker {
 			continue
 		}
+		for j := len(p.chars) - 1; j > i; j-- {
+			p.closeChar(p.chars[j], false)
+		}
+		p.closeChar(p.chars[i], true)
+		p.chars = p.chars[:i]
+		return
+	}
+	// A closing marker without its opening marker is dropped
+}
+
+// closeChars closes the open character styles at a paragraph, chapter or
+// book boundary.
+func (p *parser) closeChars() {
+	for i := len(p.chars) - 1; i >= 0; i-- {
+		p.closeChar(p.chars[i], false)
+	}
+	p.chars = nil
+}
 
-		// Parse markers
-		if strings.HasPrefix(trimmed, "\\") {
-			parts := strings.SplitN(trimmed, " ", 2)
-			marker := strings.TrimPrefix(parts[0], "\\")
-			var value string
-			if len(parts) > 1 {
-				value = parts[1]
+func (p *parser) closeChar(span *ir.Span, closed bool) {
+	p.endSpan(span)
+	if !closed {
+		span.SetAttribute("unclosed", true)
+	}
+	if span.Type == ir.SpanWord {
+		p.wordAnnotations(span)
+	}
+}
+
+// attributes attaches |attributes to the innermost open character style or
+// to a paragraph such as \periph.
+func (p *parser) attributes(tok token) {
+	switch {
+	case len(p.chars) > 0:
+		setAttributes(p.chars[len(p.chars)-1], "attributes", tok.text)
+	case p.para != nil && len(p.pending) == 0:
+		setAttributes(p.para, "attributes", tok.text)
+	default:
+		p.text(tok.raw)
+	}
+}
+
+// setAttributes parses attribute source into a span attribute. The
+// attribute order is kept when there is more than one.
+func setAttributes(span *ir.Span, key, src string) {
+	attrs := map[string]interface{}{}
+	var order []interface{}
+	matches := attrRegex.FindAllStringSubmatch(src, -1)
+	if len(matches) == 0 {
+		value := strings.TrimSpace(src)
+		if value == "" {
+			return
+		}
+		marker, _ := span.Attributes["marker"].(string)
+		name := defaultAttributes[baseMarker(marker)]
+		if name == "" {
+			name = "default"
+		}
+		attrs[name] = value
+		span.SetAttribute("default_attribute", true)
+	}
+	for _, m := range matches {
+		attrs[m[1]] = m[2]
+		order = append(order, m[1])
+	}
+	span.SetAttribute(key, attrs)
+	if len(order) > 1 {
+		span.SetAttribute(key+"_order", order)
+	}
+}
+
+// spanAttribute returns a |attribute of a span.
+func spanAttribute(span *ir.Span, name string) string {
+	attrs, _ := span.Attributes["attributes"].(map[string]interface{})
+	v, _ := attrs[name].(string)
+	return v
+}
+
+// standalone reports whether the marker just read is a standalone
+// milestone such as \ts\*.
+func (p *parser) standalone() bool {
+	i := p.pos
+	if i < len(p.toks) && p.toks[i].kind == tokAttributes {
+		i++
+	}
+	return i < len(p.toks) && p.toks[i].kind == tokMilestoneEnd
+}
+
+// milestone handles \qt-s ... \qt-e pairs and standalone milestones. end is
+// 's', 'e' or 0 for a standalone milestone.
+func (p *parser) milestone(name string, end byte) {
+	var attrSrc string
+	if p.pos < len(p.toks) && p.toks[p.pos].kind == tokAttributes {
+		attrSrc = p.toks[p.pos].text
+		p.pos++
+	}
+	if p.pos < len(p.toks) && p.toks[p.pos].kind == tokMilestoneEnd {
+		p.pos++
+	}
+	p.inline()
+
+	if end == 'e' {
+		if start := p.openMilestone(name, attrSrc); start != nil {
+			p.endSpan(start)
+			if attrSrc != "" {
+				setAttributes(start, "end_attributes", attrSrc)
 			}
+			return
+		}
+	}
 
-			switch marker {
-			case "id":
-				// Book ID
-				idParts := strings.Fields(value)
-				if len(idParts) > 0 {
-					bookID := strings.ToUpper(idParts[0])
-					corpus.ID = bookID
-					currentDoc = &ir.Document{
-						ID:            bookID,
-						Order:         len(corpus.Documents) + 1,
-						ContentBlocks: []*ir.ContentBlock{},
-					}
-					if name, ok := bookNames[bookID]; ok {
-						currentDoc.Title = name
-					}
-					corpus.Documents = append(corpus.Documents, currentDoc)
-				}
+	spanType := ir.SpanMilestone
+	if baseMarker(name) == "qt" {
+		spanType = ir.SpanQuotation
+	}
+	span := p.newSpan(spanType, name)
+	switch end {
+	case 's':
+		span.SetAttribute("milestone", "start")
+		p.milestones = append(p.milestones, span)
+	case 'e':
+		// An end without its start
+		span.SetAttribute("milestone", "end")
+	default:
+		span.SetAttribute("milestone", "standalone")
+	}
+	if attrSrc != "" {
+		setAttributes(span, "attributes", attrSrc)
+	}
+	p.addAnchor(span)
+}
 
-			case "h", "toc1", "toc2", "toc3":
-				// Header/TOC entries - stored in document attributes (not used in simplified version)
-				if currentDoc != nil && value != "" {
-					if marker == "h" && currentDoc.Title == "" {
-						currentDoc.Title = value
-					}
-				}
+// openMilestone removes and returns the open milestone an end milestone
+// closes, matching its eid to the start's sid when both are given.
+func (p *parser) openMilestone(name, attrSrc string) *ir.Span {
+	var eid string
+	for _, m := range attrRegex.FindAllStringSubmatch(attrSrc, -1) {
+		if m[1] == "eid" {
+			eid = m[2]
+		}
+	}
+	for i := len(p.milestones) - 1; i >= 0; i-- {
+		s := p.milestones[i]
+		if s.Attributes["marker"] != name {
+			continue
+		}
+		if sid := spanAttribute(s, "sid"); eid != "" && sid != "" && sid != eid {
+			continue
+		}
+		p.milestones = append(p.milestones[:i], p.milestones[i+1:]...)
+		return s
+	}
+	return nil
+}
 
-			case "mt", "mt1", "mt2", "mt3":
-				// Main title
-				if corpus.Title == "" && value != "" {
-					corpus.Title = value
-				}
+// note reads a footnote or cross-reference up to its closing marker. The
+// note becomes a span at its position in the text, keeping each part with
+// its marker, and a stand-off annotation with its plain text.
+func (p *parser) note(marker string, info markerInfo) {
+	p.inline()
 
-			case "c":
-				// Chapter marker (parsing simplified for now)
-				_ = value
-
-			case "v":
-				// Verse
-				if currentDoc != nil {
-					verseText := value
-
-					// Parse verse number
-					if matches := verseNumRegex.FindStringSubmatch(value); len(matches) > 0 {
-						// Extract text after verse number (verse number parsing simplified for now)
-						verseText = strings.TrimSpace(value[len(matches[0]):])
-					}
-
-					if verseText != "" {
-						blockSeq++
-
-						block := &ir.ContentBlock{
-							ID:       fmt.Sprintf("cb-%d", blockSeq),
-							Sequence: blockSeq,
-							Text:     verseText,
-							Anchors: []*ir.Anchor{
-								{
-									ID:             fmt.Sprintf("a-%d-0", blockSeq),
-									ContentBlockID: fmt.Sprintf("cb-%d", blockSeq),
-									CharOffset:     0,
-								},
-							},
-						}
-
-						// Compute hash
-						block.ComputeHash()
-
-						currentDoc.ContentBlocks = append(currentDoc.ContentBlocks, block)
-					}
-				}
+	type notePart struct {
+		marker string
+		text   strings.Builder
+		plain  strings.Builder
+		closed bool
+	}
+	parts := []*notePart{{}}
+	cur := parts[0]
+	closed := false
 
-			case "p", "m", "pi", "mi", "nb":
-				// Paragraph markers - may contain text
-				if currentDoc != nil && value != "" {
-					blockSeq++
-					block := &ir.ContentBlock{
-						ID:       fmt.Sprintf("cb-%d", blockSeq),
-						Sequence: blockSeq,
-						Text:     value,
-					}
-					block.ComputeHash()
-					currentDoc.ContentBlocks = append(currentDoc.ContentBlocks, block)
+loop:
+	for ; p.pos < len(p.toks); p.pos++ {
+		t := p.toks[p.pos]
+		switch t.kind {
+		case tokEndMarker:
+			if t.marker == marker {
+				p.pos++
+				closed = true
+				break loop
+			}
+			if !t.nested && t.marker == cur.marker {
+				cur.closed = true
+				cur = &notePart{}
+				parts = append(parts, cur)
+				continue
+			}
+			cur.text.WriteString(t.raw)
+		case tokMarker:
+			switch lookupMarker(t.marker).kind {
+			case kindNoteChar:
+				if !t.nested {
+					cur = &notePart{marker: t.marker}
+					parts = append(parts, cur)
+					continue
 				}
-
-			case "q", "q1", "q2", "q3", "qr", "qc", "qm":
-				// Poetry markers
-				if currentDoc != nil && value != "" {
-					blockSeq++
-					block := &ir.ContentBlock{
-						ID:       fmt.Sprintf("cb-%d", blockSeq),
-						Sequence: blockSeq,
-						Text:     value,
-					}
-					block.ComputeHash()
-					currentDoc.ContentBlocks = append(currentDoc.ContentBlocks, block)
+			case kindChar, kindCell:
+			default:
+				if _, _, ok := milestoneMarker(t.marker); !ok && !t.nested {
+					// A paragraph, verse or chapter ends an unclosed note
+					break loop
 				}
 			}
+			cur.text.WriteString(t.raw)
+		case tokText:
+			cur.text.WriteString(t.text)
+			cur.plain.WriteString(t.text)
+		default:
+			cur.text.WriteString(t.raw)
 		}
 	}
 
-	// Compute source hash
-	h := sha256.Sum256(data)
-	corpus.SourceHash = hex.EncodeToString(h[:])
+	span := p.newSpan(info.span, marker)
+	lead := strings.TrimLeft(normalizeSpace(parts[0].text.String()), " ")
+	caller, rest, _ := strings.Cut(lead, " ")
+	span.SetAttribute("caller", caller)
 
-	return corpus, nil
+	var content []interface{}
+	var plain []string
+	if rest != "" {
+		content = append(content, map[string]interface{}{"marker": "", "text": rest})
+		plain = append(plain, rest)
+	}
+	for _, part := range parts[1:] {
+		if part.marker == "" && part.text.Len() == 0 {
+			continue
+		}
+		entry := map[string]interface{}{
+			"marker": part.marker,
+			"text":   normalizeSpace(part.text.String()),
+		}
+		if part.closed {
+			entry["closed"] = true
+		}
+		content = append(content, entry)
+
+		base := baseMarker(part.marker)
+		keep := base != "fr" && base != "xo" && base != "fv"
+		if info.span == ir.SpanCrossRef {
+			keep = base == "xt"
+		}
+		if text := strings.TrimSpace(part.plain.String()); keep && text != "" {
+			plain = append(plain, text)
+		}
+	}
+	span.SetAttribute("content", content)
+	if !closed {
+		span.SetAttribute("unclosed", true)
+	}
+	p.addAnchor(span)
+
+	annType := ir.AnnotationFootnote
+	if info.span == ir.SpanCrossRef {
+		annType = ir.AnnotationCrossRef
+	}
+	if value := strings.TrimSpace(normalizeSpace(strings.Join(plain, " "))); value != "" {
+		p.annotate(span, annType, value)
+	}
 }
 
-// emitUSFMFromIR converts IR Corpus back to USFM text
-func emitUSFMFromIR(corpus *ir.Corpus) ([]byte, error) {
-	var buf bytes.Buffer
+func (p *parser) annotate(span *ir.Span, annType ir.AnnotationType, value interface{}) {
+	p.annSeq++
+	p.doc.Annotations = append(p.doc.Annotations, &ir.Annotation{
+		ID:     fmt.Sprintf("ann-%d", p.annSeq),
+		SpanID: span.ID,
+		Type:   annType,
+		Value:  value,
+	})
+}
 
-	for _, doc := range corpus.Documents {
-		// Write book ID
-		buf.WriteString(fmt.Sprintf("\\id %s\n", doc.ID))
+// wordAnnotations adds the Strong's numbers, morphology and gloss of a
+// \w word as stand-off annotations.
+func (p *parser) wordAnnotations(span *ir.Span) {
+	for _, num := range strongsNumbers(span) {
+		p.annotate(span, ir.AnnotationStrongs, num)
+	}
+	if morph := spanAttribute(span, "x-morph"); morph != "" {
+		p.annotate(span, ir.AnnotationMorphology, morph)
+	}
+	if gloss := spanAttribute(span, "gloss"); gloss != "" {
+		p.annotate(span, ir.AnnotationGloss, gloss)
+	}
+}
 
-		// Write header
-		if doc.Title != "" {
-			buf.WriteString(fmt.Sprintf("\\h %s\n", doc.Title))
+// strongsNumbers splits the strong attribute of a \w word.
+func strongsNumbers(span *ir.Span) []string {
+	var nums []string
+	for _, n := range strings.Split(spanAttribute(span, "strong"), ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			nums = append(nums, n)
 		}
+	}
+	return nums
+}
 
-		for _, block := range doc.ContentBlocks {
-			// Check for verse spans to determine chapter/verse
-			hasVerse := false
-			for _, anchor := range block.Anchors {
-				// Simple heuristic: if we have anchors at start, assume verse content
-				if anchor.CharOffset == 0 {
-					// Try to extract chapter/verse from block ID or infer from content
-					// For now, just mark as having verse
-					hasVerse = true
-					break
+// tokenizeWords tokenizes the blocks holding \w words and gives the tokens
+// inside each word its lemma, Strong's numbers and morphology.
+func (p *parser) tokenizeWords() {
+	for _, b := range p.doc.ContentBlocks {
+		offsets := map[string]int{}
+		var words []*ir.Span
+		for _, a := range b.Anchors {
+			offsets[a.ID] = a.CharOffset
+			for _, s := range a.Spans {
+				if s.Type == ir.SpanWord {
+					words = append(words, s)
 				}
 			}
+		}
+		if len(words) == 0 {
+			continue
+		}
 
-			if hasVerse {
-				// Try to infer chapter/verse from text or use simple increment
-				// This is simplified - in real implementation we'd need better tracking
-				buf.WriteString(fmt.Sprintf("\\v %s\n", block.Text))
-			} else {
-				// Non-verse block
-				buf.WriteString(fmt.Sprintf("\\p %s\n", block.Text))
+		b.Tokens = ir.Tokenize(b.Text)
+		for i, t := range b.Tokens {
+			t.ID = fmt.Sprintf("t-%d-%d", b.Sequence, i)
+		}
+		for _, w := range words {
+			start := offsets[w.StartAnchorID]
+			end, ok := offsets[w.EndAnchorID]
+			if !ok {
+				// The word ends in another block
+				end = len(b.Text)
+			}
+			for _, t := range b.Tokens {
+				if !t.IsWord() || t.CharStart < start || t.CharEnd > end {
+					continue
+				}
+				t.Lemma = spanAttribute(w, "lemma")
+				t.Strongs = strongsNumbers(w)
+				t.Morphology = spanAttribute(w, "x-morph")
 			}
 		}
 	}
-
-	return buf.Bytes(), nil
 }
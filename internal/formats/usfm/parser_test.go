@@ -142,6 +142,21 @@ func TestParseUSFMToIR_EmptyInput(t *testing.T) {
 	}
 }
 
+func TestParseUSFMToIR_NoBookID(t *testing.T) {
+	corpus, err := parseUSFMToIR([]byte("\\p Just a paragraph.\n"))
+	if err != nil {
+		t.Fatalf("parseUSFMToIR failed: %v", err)
+	}
+
+	// A corpus needs an ID even when the file has no \id marker
+	if corpus.ID != "usfm" {
+		t.Errorf("Expected fallback ID usfm, got %q", corpus.ID)
+	}
+	if corpus.LossClass != "L1" {
+		t.Errorf("Expected loss class L1, got %s", corpus.LossClass)
+	}
+}
+
 func TestParseUSFMToIR_BlankLines(t *testing.T) {
 	usfm := []byte(`\id GEN
 
@@ -2,6 +2,7 @@
 package usx
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -133,12 +134,18 @@ func (h *Handler) Enumerate(path string) (*plugins.EnumerateResult, error) {
 
 // ExtractIR implements EmbeddedFormatHandler.ExtractIR.
 func (h *Handler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, error) {
+	return h.ExtractIRContext(context.Background(), path, outputDir)
+}
+
+// ExtractIRContext implements plugins.IRExtractorContext. Parsing reports
+// its progress and stops at the next chapter when ctx is cancelled.
+func (h *Handler) ExtractIRContext(ctx context.Context, path, outputDir string) (*plugins.ExtractIRResult, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	corpus, err := parseUSXToIR(data)
+	corpus, err := parseUSXToIRContext(ctx, data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse USX: %w", err)
 	}
@@ -163,6 +170,12 @@ func (h *Handler) ExtractIR(path, outputDir string) (*plugins.ExtractIRResult, e
 
 // EmitNative implements EmbeddedFormatHandler.EmitNative.
 func (h *Handler) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResult, error) {
+	return h.EmitNativeContext(context.Background(), irPath, outputDir)
+}
+
+// EmitNativeContext implements plugins.NativeEmitterContext. Emitting
+// reports its progress and stops between blocks when ctx is cancelled.
+func (h *Handler) EmitNativeContext(ctx context.Context, irPath, outputDir string) (*plugins.EmitNativeResult, error) {
 	data, err := os.ReadFile(irPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read IR file: %w", err)
@@ -176,7 +189,10 @@ func (h *Handler) EmitNative(irPath, outputDir string) (*plugins.EmitNativeResul
 	outputPath := filepath.Join(outputDir, corpus.ID+".usx")
 
 	// Generate USX from IR
-	usxContent := emitUSXFromIR(&corpus)
+	usxContent, err := emitUSXFromIRContext(ctx, &corpus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to emit USX: %w", err)
+	}
 	if err := os.WriteFile(outputPath, []byte(usxContent), 0644); err != nil {
 		return nil, fmt.Errorf("failed to write USX: %w", err)
 	}
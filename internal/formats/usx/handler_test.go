@@ -1,13 +1,16 @@
 package usx
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 )
 
 func TestManifest(t *testing.T) {
@@ -570,3 +573,50 @@ func TestCreateContentBlock(t *testing.T) {
 		t.Error("Hash should not be empty")
 	}
 }
+
+func TestExtractIRContextCancelledPartway(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "gen.usx")
+	usx := `<?xml version="1.0"?><usx version="3.0"><book code="GEN" style="id"/>` +
+		`<chapter number="1" style="c"/><para style="p"><verse number="1" style="v"/>In the beginning</para>` +
+		`<chapter number="2" style="c"/><para style="p"><verse number="1" style="v"/>Thus the heavens</para>` +
+		`<chapter number="3" style="c"/><para style="p"><verse number="1" style="v"/>Now the serpent</para></usx>`
+	if err := os.WriteFile(path, []byte(usx), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	h := &Handler{}
+
+	result, err := h.ExtractIRContext(context.Background(), path, tmpDir)
+	if err != nil {
+		t.Fatalf("ExtractIRContext failed: %v", err)
+	}
+
+	// Cancel when parsing reaches chapter 2
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chapters := 0
+	ctx = plugins.WithProgress(ctx, func(ev plugins.ProgressEvent) {
+		if chapters++; chapters == 2 {
+			cancel()
+		}
+	})
+	outDir := t.TempDir()
+	if _, err := h.ExtractIRContext(ctx, path, outDir); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from ExtractIRContext, got %v", err)
+	}
+	if chapters != 2 {
+		t.Errorf("expected parsing to stop at chapter 2, saw %d chapters", chapters)
+	}
+
+	// Cancel once emitting has started
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	ctx = plugins.WithProgress(ctx, func(plugins.ProgressEvent) { cancel() })
+	if _, err := h.EmitNativeContext(ctx, result.IRPath, outDir); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled from EmitNativeContext, got %v", err)
+	}
+
+	if entries, _ := os.ReadDir(outDir); len(entries) != 0 {
+		t.Errorf("expected no output from cancelled calls, got %d files", len(entries))
+	}
+}
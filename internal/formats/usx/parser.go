@@ -3,6 +3,7 @@ package usx
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/xml"
@@ -12,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 )
 
 // USX XML types
@@ -37,6 +39,12 @@ type USXNode struct {
 }
 
 func parseUSXToIR(data []byte) (*ir.Corpus, error) {
+	return parseUSXToIRContext(context.Background(), data)
+}
+
+// parseUSXToIRContext is parseUSXToIR that reports its progress at each
+// chapter and stops there when ctx is cancelled.
+func parseUSXToIRContext(ctx context.Context, data []byte) (*ir.Corpus, error) {
 	decoder := xml.NewDecoder(bytes.NewReader(data))
 
 	corpus := &ir.Corpus{
@@ -84,6 +92,11 @@ func parseUSXToIR(data []byte) (*ir.Corpus, error) {
 				}
 
 			case "chapter":
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				plugins.ReportProgress(ctx, plugins.ProgressEvent{Stage: "parse", Done: decoder.InputOffset(), Total: int64(len(data))})
+
 				// Flush any pending text
 				if textBuf.Len() > 0 && currentVerse > 0 {
 					sequence++
@@ -169,6 +182,13 @@ func createContentBlock(sequence int, text, book string, chapter, verse int) *ir
 }
 
 func emitUSXFromIR(corpus *ir.Corpus) string {
+	usx, _ := emitUSXFromIRContext(context.Background(), corpus)
+	return usx
+}
+
+// emitUSXFromIRContext is emitUSXFromIR that reports its progress at each
+// book and stops between content blocks when ctx is cancelled.
+func emitUSXFromIRContext(ctx context.Context, corpus *ir.Corpus) (string, error) {
 	var buf strings.Builder
 
 	version := "3.0"
@@ -177,11 +197,15 @@ func emitUSXFromIR(corpus *ir.Corpus) string {
 <usx version="%s">
 `, version))
 
-	for _, doc := range corpus.Documents {
+	for i, doc := range corpus.Documents {
+		plugins.ReportProgress(ctx, plugins.ProgressEvent{Stage: "emit", Message: doc.ID, Done: int64(i), Total: int64(len(corpus.Documents))})
 		buf.WriteString(fmt.Sprintf(`  <book code="%s" style="id">%s</book>
 `, doc.ID, doc.Title))
 
 		for _, cb := range doc.ContentBlocks {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
 			// Simple heuristic: extract chapter/verse from anchors or infer from sequence
 			// This is simplified - in real implementation we'd track chapter/verse properly
 			if len(cb.Anchors) > 0 {
@@ -194,7 +218,7 @@ func emitUSXFromIR(corpus *ir.Corpus) string {
 	}
 
 	buf.WriteString("</usx>\n")
-	return buf.String()
+	return buf.String(), nil
 }
 
 func escapeXML(s string) string {
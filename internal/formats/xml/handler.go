@@ -58,9 +58,11 @@ func (h *Handler) Detect(path string) (*plugins.DetectResult, error) {
 	}
 
 	return &plugins.DetectResult{
-		Detected: true,
-		Format:   "xml",
-		Reason:   "XML file detected",
+		Detected:    true,
+		Format:      "xml",
+		Reason:      "XML file detected",
+		Confidence:  plugins.ExtensionConfidence,
+		Specificity: plugins.SpecificityGeneric,
 	}, nil
 }
 
@@ -98,9 +98,10 @@ func (h *Handler) Detect(path string) (*plugins.DetectResult, error) {
 	}
 
 	return &plugins.DetectResult{
-		Detected: true,
-		Format:   "zip",
-		Reason:   "valid ZIP archive",
+		Detected:    true,
+		Format:      "zip",
+		Reason:      "valid ZIP archive",
+		Specificity: plugins.SpecificityGeneric,
 	}, nil
 }
 
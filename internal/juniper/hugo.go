@@ -14,6 +14,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/FocuswithJustin/JuniperBible/core/license"
 	"github.com/FocuswithJustin/JuniperBible/internal/formats/swordpure"
 )
 
@@ -40,6 +41,10 @@ type HugoConfig struct {
 	Modules []string // Specific modules to export (empty = all)
 	All     bool     // Export all Bible modules
 	Workers int      // Number of parallel workers (0 = sequential)
+
+	// Licenses decides which modules may be published; nil skips the check.
+	Licenses        *license.Enforcer
+	LicenseOverride string // reason for overriding blocked modules (logged)
 }
 
 // HugoBibleMetadata is the structure for bibles.json.
@@ -56,6 +61,7 @@ type HugoBibleEntry struct {
 	Abbrev        string   `json:"abbrev"`
 	Language      string   `json:"language"`
 	License       string   `json:"license"`
+	SPDX          string   `json:"spdx,omitempty"`
 	LicenseText   string   `json:"licenseText,omitempty"`
 	Versification string   `json:"versification"`
 	Features      []string `json:"features"`
@@ -188,6 +194,16 @@ func Hugo(cfg HugoConfig) error {
 			fmt.Printf("Skipping %s: encrypted\n", m.Name)
 			continue
 		}
+		if cfg.Licenses != nil {
+			verdict, err := cfg.Licenses.Enforce(m.Name, m.License, license.ActionPublish, cfg.LicenseOverride)
+			if err != nil {
+				fmt.Printf("Skipping %s: %v\n", m.Name, err)
+				continue
+			}
+			if verdict.Decision != license.DecisionAllow {
+				fmt.Printf("Warning: %s\n", verdict)
+			}
+		}
 
 		wg.Add(1)
 		go func(m *Module, weight int) {
@@ -282,6 +298,7 @@ func exportModuleToHugo(swordPath string, module *Module, weight int) (*HugoBibl
 		Abbrev:        strings.ToUpper(module.Name),
 		Language:      module.Lang,
 		License:       getLicense(conf),
+		SPDX:          license.FromConf(conf.Properties).ID,
 		LicenseText:   getLicenseText(conf, swordPath, module),
 		Versification: conf.Versification,
 		Features:      []string{},
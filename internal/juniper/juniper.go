@@ -13,6 +13,7 @@ import (
 
 	"github.com/FocuswithJustin/JuniperBible/core/capsule"
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/license"
 	"github.com/FocuswithJustin/JuniperBible/internal/archive"
 	"github.com/FocuswithJustin/JuniperBible/internal/fileutil"
 )
@@ -26,6 +27,7 @@ type Module struct {
 	DataPath    string
 	Encrypted   bool
 	ConfPath    string
+	License     *license.Info
 }
 
 // ListConfig holds configuration for listing SWORD modules.
@@ -35,10 +37,11 @@ type ListConfig struct {
 
 // IngestConfig holds configuration for ingesting SWORD modules.
 type IngestConfig struct {
-	Path    string   // SWORD installation path
-	Output  string   // Output directory for capsules
-	Modules []string // Specific modules to ingest
-	All     bool     // Ingest all modules
+	Path       string   // SWORD installation path
+	Output     string   // Output directory for capsules
+	Modules    []string // Specific modules to ingest
+	All        bool     // Ingest all modules
+	Recipients []string // age recipients; encrypted (CipherKey) modules are archived encrypted to these
 }
 
 // CASToSwordConfig holds configuration for CAS-to-SWORD conversion.
@@ -189,15 +192,20 @@ func Ingest(cfg IngestConfig) error {
 	fmt.Printf("Ingesting %d module(s) to %s/\n\n", len(toIngest), cfg.Output)
 
 	for _, m := range toIngest {
+		capsulePath := filepath.Join(cfg.Output, m.Name+".capsule.tar.gz")
+		ingest := func() error { return IngestModule(swordPath, m, capsulePath) }
 		if m.Encrypted {
-			fmt.Printf("Skipping %s (encrypted)\n", m.Name)
-			continue
+			if len(cfg.Recipients) == 0 {
+				fmt.Printf("Skipping %s (encrypted; use --recipient to archive it encrypted)\n", m.Name)
+				continue
+			}
+			capsulePath = filepath.Join(cfg.Output, m.Name+".capsule.tar.xz")
+			ingest = func() error { return IngestEncryptedModule(swordPath, m, capsulePath, cfg.Recipients) }
 		}
 
-		capsulePath := filepath.Join(cfg.Output, m.Name+".capsule.tar.gz")
 		fmt.Printf("Creating %s...\n", capsulePath)
 
-		if err := IngestModule(swordPath, m, capsulePath); err != nil {
+		if err := ingest(); err != nil {
 			fmt.Printf("  Error: %v\n", err)
 			continue
 		}
@@ -271,6 +279,7 @@ func IngestModule(swordPath string, module *Module, outputPath string) error {
 		"title":           module.Description,
 		"language":        module.Lang,
 		"source_format":   "sword",
+		"license":         license.FromConf(license.ParseConf(confData)),
 	}
 	manifestData, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
@@ -284,6 +293,45 @@ func IngestModule(swordPath string, module *Module, outputPath string) error {
 	return archive.CreateCapsuleTarGz(capsuleDir, outputPath)
 }
 
+// IngestEncryptedModule archives a SWORD module that cannot be
+// redistributed in the clear (e.g. a CipherKey module). The module is
+// stored as a single artifact in a CAS capsule whose blobs are encrypted
+// to the given age recipients; the manifest stays readable.
+func IngestEncryptedModule(swordPath string, module *Module, outputPath string, recipients []string) error {
+	tempDir, err := os.MkdirTemp("", "sword-encrypted-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	modulePath := filepath.Join(tempDir, module.Name+".sword.tar.gz")
+	if err := IngestModule(swordPath, module, modulePath); err != nil {
+		return err
+	}
+
+	cap, err := capsule.New(filepath.Join(tempDir, "capsule"))
+	if err != nil {
+		return fmt.Errorf("failed to create capsule: %w", err)
+	}
+	artifact, err := cap.IngestFile(modulePath)
+	if err != nil {
+		return fmt.Errorf("failed to ingest module: %w", err)
+	}
+	artifact.Attributes = capsule.Attributes{
+		"module":        module.Name,
+		"title":         module.Description,
+		"language":      module.Lang,
+		"source_format": "sword",
+		"sword_cipher":  true,
+	}
+	cap.Manifest.License = module.License
+
+	if err := cap.Encrypt(recipients); err != nil {
+		return fmt.Errorf("failed to encrypt capsule: %w", err)
+	}
+	return cap.Pack(outputPath)
+}
+
 // InstallConfig holds configuration for installing SWORD modules as capsules with IR.
 type InstallConfig struct {
 	Path       string   // SWORD installation path
@@ -533,7 +581,7 @@ func CASToSword(cfg CASToSwordConfig) error {
 	}
 
 	// Directly retrieve the IR blob from CAS
-	irBlobData, err := cap.GetStore().Retrieve(irRecord.IRBlobSHA256)
+	irBlobData, err := cap.ReadBlob(irRecord.IRBlobSHA256)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve IR blob: %w", err)
 	}
@@ -617,7 +665,7 @@ func ParseConf(path string) *Module {
 		return nil
 	}
 
-	module := &Module{}
+	module := &Module{License: license.FromConf(license.ParseConf(data))}
 	lines := strings.Split(string(data), "\n")
 
 	for _, line := range lines {
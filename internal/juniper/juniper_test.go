@@ -5,6 +5,10 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"filippo.io/age"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
 )
 
 func TestResolveSwordPath(t *testing.T) {
@@ -663,6 +667,68 @@ DataPath=./modules/texts/ztext/enc/`)
 	_ = err
 }
 
+func TestIngest_EncryptedModuleWithRecipient(t *testing.T) {
+	tempDir := t.TempDir()
+	modsDir := filepath.Join(tempDir, "mods.d")
+	os.MkdirAll(modsDir, 0755)
+
+	encConf := []byte(`[ENC]
+Description=Encrypted Module
+Lang=en
+ModDrv=zText
+CipherKey=secret
+DataPath=./modules/texts/ztext/enc/`)
+	os.WriteFile(filepath.Join(modsDir, "enc.conf"), encConf, 0644)
+
+	dataDir := filepath.Join(tempDir, "modules", "texts", "ztext", "enc")
+	os.MkdirAll(dataDir, 0755)
+	os.WriteFile(filepath.Join(dataDir, "ot.bzs"), []byte("data"), 0644)
+
+	identity, recipient, err := capsule.GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity() error = %v", err)
+	}
+
+	cfg := IngestConfig{
+		Path:       tempDir,
+		Output:     filepath.Join(tempDir, "output"),
+		All:        true,
+		Recipients: []string{recipient},
+	}
+	if err := Ingest(cfg); err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+
+	capsulePath := filepath.Join(tempDir, "output", "ENC.capsule.tar.xz")
+	cap, err := capsule.Unpack(capsulePath, t.TempDir())
+	if err != nil {
+		t.Fatalf("Unpack() error = %v", err)
+	}
+	if !cap.IsEncrypted() {
+		t.Fatal("expected encrypted capsule")
+	}
+
+	var artifact *capsule.Artifact
+	for _, a := range cap.Manifest.Artifacts {
+		artifact = a
+	}
+	if artifact == nil || artifact.Attributes["module"] != "ENC" {
+		t.Fatalf("expected readable module metadata, got %+v", artifact)
+	}
+	if err := cap.VerifyBlob(artifact.PrimaryBlobSHA256); err != nil {
+		t.Errorf("VerifyBlob() without keys error = %v", err)
+	}
+
+	ids, err := age.ParseIdentities(strings.NewReader(identity))
+	if err != nil {
+		t.Fatalf("ParseIdentities() error = %v", err)
+	}
+	cap.SetIdentities(ids)
+	if _, err := cap.ReadBlob(artifact.PrimaryBlobSHA256); err != nil {
+		t.Errorf("ReadBlob() with identity error = %v", err)
+	}
+}
+
 func TestListModules_ReadDirError(t *testing.T) {
 	tempDir := t.TempDir()
 	modsDir := filepath.Join(tempDir, "mods.d")
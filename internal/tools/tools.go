@@ -42,6 +42,7 @@ type ExecuteConfig struct {
 	ToolID      string
 	Profile     string
 	FlakePath   string
+	PluginDir   string // Optional; the tool plugin found here is recorded in the run
 }
 
 // ListResult contains the results of a List operation.
@@ -211,6 +212,11 @@ func Execute(ctx context.Context, cfg ExecuteConfig) (*ExecuteResult, error) {
 		return nil, fmt.Errorf("failed to export artifact: %w", err)
 	}
 
+	pluginInfo, err := runner.ToolPluginInfo(cfg.PluginDir, cfg.ToolID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create runner request
 	req := runner.NewRequest(cfg.ToolID, cfg.Profile)
 	req.Inputs = []string{inputPath}
@@ -221,6 +227,9 @@ func Execute(ctx context.Context, cfg ExecuteConfig) (*ExecuteResult, error) {
 	if err != nil {
 		return nil, fmt.Errorf("tool execution failed: %w", err)
 	}
+	if err := runner.CheckToolPluginInfo(cfg.PluginDir, pluginInfo); err != nil {
+		return nil, err
+	}
 
 	if len(result.TranscriptData) == 0 {
 		return nil, fmt.Errorf("no transcript generated")
@@ -229,11 +238,9 @@ func Execute(ctx context.Context, cfg ExecuteConfig) (*ExecuteResult, error) {
 	// Create run record
 	runID := fmt.Sprintf("run-%s-%s-%d", cfg.ToolID, cfg.Profile, len(cap.Manifest.Runs)+1)
 	run := &capsule.Run{
-		ID: runID,
-		Plugin: &capsule.PluginInfo{
-			PluginID: cfg.ToolID,
-			Kind:     "tool",
-		},
+		ID:     runID,
+		Engine: result.Engine.CapsuleEngine(),
+		Plugin: pluginInfo,
 		Inputs: []capsule.RunInput{
 			{ArtifactID: cfg.ArtifactID},
 		},
@@ -243,8 +250,8 @@ func Execute(ctx context.Context, cfg ExecuteConfig) (*ExecuteResult, error) {
 		Status: "completed",
 	}
 
-	// Add run to capsule
-	if err := cap.AddRun(run, result.TranscriptData); err != nil {
+	// Add run to capsule with its request and outputs, so it can be replayed
+	if err := runner.RecordRun(cap, run, req, []string{inputPath}, result); err != nil {
 		return nil, fmt.Errorf("failed to add run: %w", err)
 	}
 
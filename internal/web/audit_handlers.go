@@ -0,0 +1,121 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/audit"
+)
+
+// AuditData is the data for the fixity audit report page.
+type AuditData struct {
+	PageData
+	Report  *audit.Report
+	Pending bool
+}
+
+// auditLogPath returns the fixity audit log used by the web server.
+func auditLogPath() string {
+	if ServerConfig.AuditDB != "" {
+		return ServerConfig.AuditDB
+	}
+	return filepath.Join(ServerConfig.CapsulesDir, audit.DefaultLogName)
+}
+
+// runAudit audits the capsules directory, resuming an interrupted run if
+// there is one. Cancelling ctx stops the audit between capsules.
+func runAudit(ctx context.Context, sample float64, progress func(done, total int, result *audit.Result)) (map[string]interface{}, error) {
+	l, err := audit.Open(auditLogPath())
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	run, _, err := l.Audit(ctx, ServerConfig.CapsulesDir, audit.Options{
+		Sample:   sample,
+		Resume:   true,
+		Progress: progress,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"run":     run.ID,
+		"checked": run.Checked,
+		"failed":  run.Failed,
+	}, nil
+}
+
+// queueAudit adds an audit task unless one is already queued or running.
+func queueAudit(name string) bool {
+	if taskQueue.HasPending(TaskAudit) {
+		return false
+	}
+	taskQueue.AddTask(TaskAudit, name, map[string]string{
+		"sample": strconv.FormatFloat(ServerConfig.AuditSample, 'f', -1, 64),
+	})
+	return true
+}
+
+// StartAuditScheduler queues a fixity audit every ServerConfig.AuditEvery.
+// It does nothing if no interval is configured.
+func StartAuditScheduler() {
+	if ServerConfig.AuditEvery <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(ServerConfig.AuditEvery)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if queueAudit("Scheduled fixity audit") {
+				log.Println("[AUDIT] Scheduled fixity audit queued")
+			}
+		}
+	}()
+}
+
+// handleAudit shows capsules never audited, recently failed or overdue.
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+	csrfToken := getOrCreateCSRFToken(w, r)
+	data := AuditData{
+		PageData: PageData{Title: "Fixity Audit", CSRFToken: csrfToken},
+		Pending:  taskQueue.HasPending(TaskAudit),
+	}
+	if r.URL.Query().Get("queued") != "" {
+		data.Message = "Fixity audit queued."
+	}
+
+	l, err := audit.Open(auditLogPath())
+	if err != nil {
+		data.Error = fmt.Sprintf("Failed to open audit log: %v", err)
+	} else {
+		defer l.Close()
+		if data.Report, err = l.Report(ServerConfig.CapsulesDir, audit.DefaultInterval); err != nil {
+			data.Error = fmt.Sprintf("Failed to build audit report: %v", err)
+		}
+	}
+
+	if err := Templates.ExecuteTemplate(w, "audit.html", data); err != nil {
+		httpError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// handleAuditRun queues a fixity audit of the capsules directory.
+func handleAuditRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !validateCSRFToken(r) {
+		http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+		return
+	}
+	queueAudit("Fixity audit")
+	http.Redirect(w, r, "/audit?queued=1", http.StatusSeeOther)
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleAudit(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalDir := ServerConfig.CapsulesDir
+	ServerConfig.CapsulesDir = tmpDir
+	defer func() { ServerConfig.CapsulesDir = originalDir }()
+
+	// Render with the real template to catch template errors
+	originalTemplates := Templates
+	Templates = template.Must(template.New("").Funcs(templateFuncs()).ParseFS(templatesFS, "templates/*.html"))
+	defer func() { Templates = originalTemplates }()
+
+	for _, name := range []string{"audited.tar.gz", "later.tar.gz"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("failed to write capsule: %v", err)
+		}
+	}
+	if _, err := runAudit(context.Background(), 0.5, nil); err != nil {
+		t.Fatalf("runAudit failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".fixity-audit.db")); err != nil {
+		t.Errorf("expected audit log in capsules dir: %v", err)
+	}
+
+	t.Run("report page", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/audit", nil)
+		w := httptest.NewRecorder()
+
+		handleAudit(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", w.Code)
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "2 capsules, 1 healthy") {
+			t.Errorf("expected summary in report page")
+		}
+		if !strings.Contains(body, "<li>later.tar.gz</li>") {
+			t.Errorf("expected never-audited capsule in report page")
+		}
+	})
+
+	t.Run("run requires POST", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/audit/run", nil)
+		w := httptest.NewRecorder()
+
+		handleAuditRun(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status 405, got %d", w.Code)
+		}
+	})
+
+	t.Run("run without CSRF token fails", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/audit/run", nil)
+		w := httptest.NewRecorder()
+
+		handleAuditRun(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected status 403, got %d", w.Code)
+		}
+	})
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/FocuswithJustin/JuniperBible/core/ir"
+	"github.com/FocuswithJustin/JuniperBible/core/license"
 	"github.com/FocuswithJustin/JuniperBible/internal/archive"
 )
 
@@ -800,6 +801,7 @@ type ChapterViewData struct {
 	RequestedBook    string // Original book ID requested
 	RequestedChapter int    // Original chapter requested
 	NotFoundMessage  string // Message when content doesn't exist
+	LicenseNotice    string // Warning from the license policy
 }
 
 // SearchData is the data for the search page.
@@ -1360,6 +1362,12 @@ func handleChapterView(w http.ResponseWriter, r *http.Request, capsuleID, bookID
 		return
 	}
 
+	verdict, err := checkLicense(bible.CapsulePath, license.ActionDisplay)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	// Get all bibles for dropdown
 	allBibles := getCachedBibles()
 
@@ -1421,6 +1429,7 @@ func handleChapterView(w http.ResponseWriter, r *http.Request, capsuleID, bookID
 		RequestedBook:    bookID,
 		RequestedChapter: requestedChapter,
 		NotFoundMessage:  notFoundMessage,
+		LicenseNotice:    licenseNotice(verdict),
 	}
 
 	if err := Templates.ExecuteTemplate(w, "bible_chapter.html", data); err != nil {
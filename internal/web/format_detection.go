@@ -1,9 +1,11 @@
 package web
 
 import (
+	"context"
 	"path/filepath"
 	"strings"
 
+	"github.com/FocuswithJustin/JuniperBible/core/detect"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 )
 
@@ -21,19 +23,8 @@ func NewFormatDetector(loader *plugins.Loader) *FormatDetector {
 func (fd *FormatDetector) DetectFileFormat(path string) string {
 	// Try plugin-based detection first
 	if fd.loader != nil {
-		for _, plugin := range fd.loader.GetPluginsByKind("format") {
-			req := plugins.NewDetectRequest(path)
-			resp, err := plugins.ExecutePlugin(plugin, req)
-			if err != nil {
-				continue
-			}
-			result, err := plugins.ParseDetectResult(resp)
-			if err != nil {
-				continue
-			}
-			if result.Detected {
-				return strings.TrimPrefix(plugin.Manifest.PluginID, "format.")
-			}
+		if best := fd.Detect(path); best != nil {
+			return best.Format
 		}
 	}
 
@@ -41,6 +32,21 @@ func (fd *FormatDetector) DetectFileFormat(path string) string {
 	return fd.DetectByExtension(path)
 }
 
+// Detect returns the best ranked plugin detection of a file, or nil if no
+// plugin detected it.
+func (fd *FormatDetector) Detect(path string) *detect.Candidate {
+	if fd.loader == nil {
+		return nil
+	}
+	detector := detect.New(fd.loader)
+	detector.Depth = 0
+	result, err := detector.Detect(context.Background(), path)
+	if err != nil {
+		return nil
+	}
+	return result.Best()
+}
+
 // DetectByExtension detects format based solely on file extension.
 func (fd *FormatDetector) DetectByExtension(path string) string {
 	ext := strings.ToLower(filepath.Ext(path))
@@ -52,16 +58,7 @@ func (fd *FormatDetector) DetectByExtension(path string) string {
 
 // DetectCapsuleFormat detects the compression format of a capsule archive.
 func (fd *FormatDetector) DetectCapsuleFormat(path string) string {
-	switch {
-	case strings.HasSuffix(path, ".tar.xz"):
-		return "tar.xz"
-	case strings.HasSuffix(path, ".tar.gz"):
-		return "tar.gz"
-	case strings.HasSuffix(path, ".tar"):
-		return "tar"
-	default:
-		return "unknown"
-	}
+	return detect.ContainerFormat(path)
 }
 
 // DetectSourceFormat detects the source format from a capsule path.
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/FocuswithJustin/JuniperBible/core/detect"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 )
 
@@ -51,7 +52,8 @@ func TestFormatDetector_DetectCapsuleFormat(t *testing.T) {
 		{"tar.xz without capsule", "test.tar.xz", "tar.xz"},
 		{"tar.gz without capsule", "test.tar.gz", "tar.gz"},
 		{"tar without capsule", "test.tar", "tar"},
-		{"unknown format", "test.zip", "unknown"},
+		{"zip capsule", "test.capsule.zip", "zip"},
+		{"unknown format", "test.rar", "unknown"},
 	}
 
 	detector := NewFormatDetector(nil)
@@ -232,7 +234,8 @@ func TestPackageLevelDetectCapsuleFormat(t *testing.T) {
 		{"test.tar.xz", "tar.xz"},
 		{"test.tar.gz", "tar.gz"},
 		{"test.tar", "tar"},
-		{"test.zip", "unknown"},
+		{"test.zip", "zip"},
+		{"test.rar", "unknown"},
 	}
 
 	for _, tt := range tests {
@@ -269,3 +272,22 @@ func TestPackageLevelDetectSourceFormat(t *testing.T) {
 		t.Errorf("detectSourceFormat(%q) = %q, want %q", "bible.capsule.tar.xz", result, "unknown")
 	}
 }
+
+func TestConfidenceLevel(t *testing.T) {
+	tests := []struct {
+		candidate detect.Candidate
+		expected  string
+	}{
+		{detect.Candidate{Confidence: 1, Specificity: plugins.SpecificityFormat}, "high"},
+		{detect.Candidate{Confidence: plugins.ExtensionConfidence, Specificity: plugins.SpecificityFormat}, "medium"},
+		{detect.Candidate{Confidence: 1, Specificity: plugins.SpecificityGeneric}, "medium"},
+		{detect.Candidate{Confidence: 0.2, Specificity: plugins.SpecificityGeneric}, "low"},
+		{detect.Candidate{Confidence: 1, Specificity: plugins.SpecificityAny}, "low"},
+	}
+
+	for _, tt := range tests {
+		if got := confidenceLevel(&tt.candidate); got != tt.expected {
+			t.Errorf("confidenceLevel(%+v) = %q, want %q", tt.candidate, got, tt.expected)
+		}
+	}
+}
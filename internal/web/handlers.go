@@ -3,6 +3,7 @@ package web
 import (
 	"archive/tar"
 	"compress/gzip"
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
@@ -24,7 +25,11 @@ import (
 
 	"github.com/ulikunitz/xz"
 
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+	"github.com/FocuswithJustin/JuniperBible/core/detect"
+	"github.com/FocuswithJustin/JuniperBible/core/license"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+	"github.com/FocuswithJustin/JuniperBible/core/runner"
 	"github.com/FocuswithJustin/JuniperBible/internal/archive"
 	"github.com/FocuswithJustin/JuniperBible/internal/fileutil"
 	"github.com/FocuswithJustin/JuniperBible/internal/logging"
@@ -413,6 +418,7 @@ func invalidateCapsuleMetadataCache() {
 func trimArchiveSuffix(name string) string {
 	name = strings.TrimSuffix(name, ".capsule.tar.gz")
 	name = strings.TrimSuffix(name, ".capsule.tar.xz")
+	name = strings.TrimSuffix(name, ".capsule.zip")
 	name = strings.TrimSuffix(name, ".tar.gz")
 	name = strings.TrimSuffix(name, ".tar.xz")
 	return name
@@ -884,6 +890,12 @@ func handleArtifact(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	verdict, err := checkLicense(cleanPath, license.ActionDisplay)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	// Extract artifact content
 	content, contentType, err := readArtifactContent(fullPath, artifactID)
 	if err != nil {
@@ -892,6 +904,9 @@ func handleArtifact(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Serve the content
+	if notice := licenseNotice(verdict); notice != "" {
+		w.Header().Set("X-License-Warning", notice)
+	}
 	w.Header().Set("Content-Type", contentType)
 	w.Write([]byte(content))
 }
@@ -1100,6 +1115,12 @@ func handleConvert(w http.ResponseWriter, r *http.Request) {
 // performConversion converts a capsule to a different format.
 // It creates a new capsule with the converted content and renames the original.
 func performConversion(sourcePath, targetFormat string) *ConvertResult {
+	return performConversionContext(context.Background(), sourcePath, targetFormat)
+}
+
+// performConversionContext converts a capsule like performConversion. Its
+// plugin calls are cancelled with ctx and report progress to it.
+func performConversionContext(ctx context.Context, sourcePath, targetFormat string) *ConvertResult {
 	// Sanitize path to prevent path traversal attacks
 	cleanPath, err := validation.SanitizePath(ServerConfig.CapsulesDir, sourcePath)
 	if err != nil {
@@ -1118,6 +1139,13 @@ func performConversion(sourcePath, targetFormat string) *ConvertResult {
 		}
 	}
 
+	if _, err := checkLicense(cleanPath, license.ActionForFormat(targetFormat)); err != nil {
+		return &ConvertResult{
+			Success: false,
+			Message: err.Error(),
+		}
+	}
+
 	// Detect source format
 	sourceFormat := detectSourceFormat(sourcePath)
 
@@ -1176,7 +1204,7 @@ func performConversion(sourcePath, targetFormat string) *ConvertResult {
 	}
 
 	extractReq := plugins.NewExtractIRRequest(contentPath, irDir)
-	extractResp, err := plugins.ExecutePlugin(sourcePlugin, extractReq)
+	extractResp, err := plugins.ExecutePluginContext(ctx, sourcePlugin, extractReq)
 	if err != nil {
 		return &ConvertResult{
 			Success:      false,
@@ -1208,7 +1236,7 @@ func performConversion(sourcePath, targetFormat string) *ConvertResult {
 	os.MkdirAll(emitDir, 0755)
 
 	emitReq := plugins.NewEmitNativeRequest(extractResult.IRPath, emitDir)
-	emitResp, err := plugins.ExecutePlugin(targetPlugin, emitReq)
+	emitResp, err := plugins.ExecutePluginContext(ctx, targetPlugin, emitReq)
 	if err != nil {
 		return &ConvertResult{
 			Success:      false,
@@ -1314,6 +1342,12 @@ func performConversion(sourcePath, targetFormat string) *ConvertResult {
 
 // performIRGeneration generates IR for a capsule that doesn't have one.
 func performIRGeneration(sourcePath string) *ConvertResult {
+	return performIRGenerationContext(context.Background(), sourcePath)
+}
+
+// performIRGenerationContext generates IR like performIRGeneration. Its
+// plugin call is cancelled with ctx and reports progress to it.
+func performIRGenerationContext(ctx context.Context, sourcePath string) *ConvertResult {
 	// Sanitize path to prevent path traversal attacks
 	cleanPath, err := validation.SanitizePath(ServerConfig.CapsulesDir, sourcePath)
 	if err != nil {
@@ -1419,7 +1453,7 @@ func performIRGeneration(sourcePath string) *ConvertResult {
 	}
 
 	extractReq := plugins.NewExtractIRRequest(contentPath, irDir)
-	extractResp, err := plugins.ExecutePlugin(sourcePlugin, extractReq)
+	extractResp, err := plugins.ExecutePluginContext(ctx, sourcePlugin, extractReq)
 	if err != nil {
 		return &ConvertResult{
 			Success:      false,
@@ -2029,6 +2063,19 @@ func combineLossClass(a, b string) string {
 
 // readCapsuleManifest reads the manifest from a capsule archive.
 func readCapsuleManifest(capsulePath string) *CapsuleManifest {
+	// Zip capsules support direct access to manifest.json
+	if strings.HasSuffix(capsulePath, ".zip") {
+		data, err := archive.ReadFile(capsulePath, "manifest.json")
+		if err != nil {
+			return nil
+		}
+		var manifest CapsuleManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil
+		}
+		return &manifest
+	}
+
 	f, err := os.Open(capsulePath)
 	if err != nil {
 		return nil
@@ -2146,7 +2193,10 @@ func listCapsulesUncached() []CapsuleInfo {
 
 		name := entry.Name()
 		ext := filepath.Ext(name)
-		if ext == ".xz" || ext == ".gz" || ext == ".tar" {
+		if ext == ".zip" && !archive.IsZipCapsule(filepath.Join(ServerConfig.CapsulesDir, name)) {
+			continue
+		}
+		if ext == ".xz" || ext == ".gz" || ext == ".tar" || ext == ".zip" {
 			info, err := entry.Info()
 			if err != nil {
 				continue
@@ -2486,6 +2536,9 @@ func readArtifactContent(capsulePath, artifactID string) (string, string, error)
 		}
 
 		if header.Name == artifactID {
+			if strings.HasPrefix(header.Name, "blobs/age/") {
+				return readEncryptedArtifactContent(capsulePath, header.Name)
+			}
 			data, err := io.ReadAll(tarReader)
 			if err != nil {
 				return "", "", err
@@ -2498,10 +2551,41 @@ func readArtifactContent(capsulePath, artifactID string) (string, string, error)
 	return "", "", fmt.Errorf("artifact not found: %s", artifactID)
 }
 
+// readEncryptedArtifactContent decrypts an age-encrypted blob entry using
+// the identities loaded at startup.
+func readEncryptedArtifactContent(capsulePath, entry string) (string, string, error) {
+	r, err := capsule.OpenArchive(capsulePath)
+	if err != nil {
+		return "", "", err
+	}
+	defer r.Close()
+
+	hash := strings.TrimSuffix(filepath.Base(entry), ".age")
+	data, err := r.ReadBlob(hash)
+	if err != nil {
+		return "", "", err
+	}
+	return string(data), detectContentType(blobDisplayName(r.Manifest(), hash), data), nil
+}
+
+// blobDisplayName returns the original name of the artifact stored in a
+// blob, falling back to the hash.
+func blobDisplayName(m *capsule.Manifest, hash string) string {
+	for _, a := range m.Artifacts {
+		if a.PrimaryBlobSHA256 == hash && a.OriginalName != "" {
+			return a.OriginalName
+		}
+	}
+	return hash
+}
+
 func readIRContent(capsulePath string) (map[string]interface{}, error) {
 	// Use semaphore to limit concurrent archive reads
 	acquireArchiveSemaphore()
 	ir, err := archive.ReadIR(capsulePath)
+	if err != nil {
+		ir, err = readCASIRContent(capsulePath)
+	}
 	releaseArchiveSemaphore()
 
 	if err != nil {
@@ -2510,6 +2594,32 @@ func readIRContent(capsulePath string) (map[string]interface{}, error) {
 	return ir, nil
 }
 
+// readCASIRContent reads the IR artifact of a CAS capsule, decrypting it
+// if the capsule is encrypted.
+func readCASIRContent(capsulePath string) (map[string]interface{}, error) {
+	r, err := capsule.OpenArchive(capsulePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	for id, a := range r.Manifest().Artifacts {
+		if a.Kind != capsule.ArtifactKindIR {
+			continue
+		}
+		data, err := r.ReadArtifact(id)
+		if err != nil {
+			return nil, err
+		}
+		var ir map[string]interface{}
+		if err := json.Unmarshal(data, &ir); err != nil {
+			return nil, err
+		}
+		return ir, nil
+	}
+	return nil, fmt.Errorf("no IR artifact in capsule")
+}
+
 func detectContentType(name string, data []byte) string {
 	ext := strings.ToLower(filepath.Ext(name))
 	switch ext {
@@ -2946,24 +3056,12 @@ func performDetect(file io.Reader, filename string) *DetectResult {
 		}
 	}
 
-	// Try each format plugin
-	for _, plugin := range loader.GetPluginsByKind("format") {
-		req := plugins.NewDetectRequest(tempPath)
-		resp, err := plugins.ExecutePlugin(plugin, req)
-		if err != nil {
-			continue
-		}
-		result, err := plugins.ParseDetectResult(resp)
-		if err != nil {
-			continue
-		}
-		if result.Detected {
-			return &DetectResult{
-				Format:     strings.TrimPrefix(plugin.Manifest.PluginID, "format."),
-				PluginID:   plugin.Manifest.PluginID,
-				Confidence: "high",
-				Details:    result.Reason,
-			}
+	if best := NewFormatDetector(loader).Detect(tempPath); best != nil {
+		return &DetectResult{
+			Format:     best.Format,
+			PluginID:   best.PluginID,
+			Confidence: confidenceLevel(best),
+			Details:    best.Reason,
 		}
 	}
 
@@ -2977,6 +3075,20 @@ func performDetect(file io.Reader, filename string) *DetectResult {
 	}
 }
 
+// confidenceLevel describes how sure a detection is: "high" for a plugin
+// that recognized its own format, "medium" for a generic container or
+// extension match, and "low" otherwise.
+func confidenceLevel(c *detect.Candidate) string {
+	switch {
+	case c.Specificity == plugins.SpecificityFormat && c.Confidence >= 0.9:
+		return "high"
+	case c.Specificity >= plugins.SpecificityGeneric && c.Confidence >= plugins.ExtensionConfidence:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
 // handleExport handles capsule artifact export.
 func handleExport(w http.ResponseWriter, r *http.Request) {
 	capsulePath := strings.TrimPrefix(r.URL.Path, "/export/")
@@ -3002,6 +3114,15 @@ func handleExport(w http.ResponseWriter, r *http.Request) {
 	// Check if this is a download request
 	artifactID := r.URL.Query().Get("artifact")
 	if artifactID != "" && r.URL.Query().Get("download") == "true" {
+		verdict, err := checkLicense(cleanPath, license.ActionDownload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if notice := licenseNotice(verdict); notice != "" {
+			w.Header().Set("X-License-Warning", notice)
+		}
+
 		// Stream artifact content as download
 		content, contentType, err := readArtifactContent(fullPath, artifactID)
 		if err != nil {
@@ -3429,7 +3550,7 @@ func getRunsFromCapsule(extractDir string) []RunInfo {
 	var runs []RunInfo
 
 	// Read manifest.json
-	manifestPath := filepath.Join(extractDir, "capsule", "manifest.json")
+	manifestPath := filepath.Join(capsuleContentDir(extractDir), "manifest.json")
 	data, err := os.ReadFile(manifestPath)
 	if err != nil {
 		return runs
@@ -3508,23 +3629,25 @@ type RunsCompareData struct {
 
 // CompareResult is the result of comparing two runs.
 type CompareResult struct {
-	Run1ID      string
-	Run2ID      string
-	Hash1       string
-	Hash2       string
-	Identical   bool
-	EventCount1 int
-	EventCount2 int
-	Differences []DiffEntry
-}
+	Run1ID      string                 `json:"run_a"`
+	Run2ID      string                 `json:"run_b"`
+	Hash1       string                 `json:"hash_a,omitempty"`
+	Hash2       string                 `json:"hash_b,omitempty"`
+	Identical   bool                   `json:"identical"`
+	EventCount1 int                    `json:"events_a"`
+	EventCount2 int                    `json:"events_b"`
+	Diff        *runner.TranscriptDiff `json:"diff,omitempty"`
+	Error       string                 `json:"error,omitempty"`
 
-// DiffEntry is a single difference between transcripts.
-type DiffEntry struct {
-	Index  int
-	Event1 string
-	Event2 string
+	// Changes are the first changes of Diff shown on the page;
+	// MoreChanges counts the rest.
+	Changes     []runner.EventChange `json:"-"`
+	MoreChanges int                  `json:"-"`
 }
 
+// compareChangeLimit is the number of changes the runs compare page lists.
+const compareChangeLimit = 200
+
 // handleRunsCompare handles the runs compare page.
 func handleRunsCompare(w http.ResponseWriter, r *http.Request) {
 	capsulePath := strings.TrimPrefix(r.URL.Path, "/runs/compare/")
@@ -3555,7 +3678,8 @@ func handleRunsCompare(w http.ResponseWriter, r *http.Request) {
 	}
 	defer os.RemoveAll(tempDir)
 
-	if err := extractCapsule(fullPath, tempDir); err != nil {
+	cap, err := capsule.Unpack(fullPath, tempDir)
+	if err != nil {
 		httpError(w, err, http.StatusInternalServerError)
 		return
 	}
@@ -3584,7 +3708,7 @@ func handleRunsCompare(w http.ResponseWriter, r *http.Request) {
 			run1ID := r.FormValue("run1")
 			run2ID := r.FormValue("run2")
 			if run1ID != "" && run2ID != "" {
-				result := performRunsCompare(tempDir, run1ID, run2ID)
+				result := performRunsCompare(cap, run1ID, run2ID, nil)
 				data.Result = result
 			}
 		}
@@ -3596,129 +3720,125 @@ func handleRunsCompare(w http.ResponseWriter, r *http.Request) {
 }
 
 // performRunsCompare compares two runs in a capsule.
-func performRunsCompare(extractDir, run1ID, run2ID string) *CompareResult {
+func performRunsCompare(cap *capsule.Capsule, run1ID, run2ID string, ignore []string) *CompareResult {
 	result := &CompareResult{
 		Run1ID: run1ID,
 		Run2ID: run2ID,
 	}
 
-	// Read manifest.json to get transcript hashes
-	manifestPath := filepath.Join(extractDir, "capsule", "manifest.json")
-	data, err := os.ReadFile(manifestPath)
-	if err != nil {
-		return result
-	}
-
-	var manifest struct {
-		Runs map[string]struct {
-			Outputs *struct {
-				TranscriptBlobSHA256 string `json:"transcript_blob_sha256"`
-			} `json:"outputs"`
-		} `json:"runs"`
-		Blobs map[string]struct {
-			Path string `json:"path"`
-		} `json:"blobs"`
-	}
-
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		return result
-	}
-
-	// Get transcript hashes
-	run1, ok := manifest.Runs[run1ID]
+	run1, ok := cap.Manifest.Runs[run1ID]
 	if !ok {
+		result.Error = "run not found: " + run1ID
 		return result
 	}
-	run2, ok := manifest.Runs[run2ID]
+	run2, ok := cap.Manifest.Runs[run2ID]
 	if !ok {
+		result.Error = "run not found: " + run2ID
 		return result
 	}
-
 	if run1.Outputs != nil {
 		result.Hash1 = run1.Outputs.TranscriptBlobSHA256
 	}
 	if run2.Outputs != nil {
 		result.Hash2 = run2.Outputs.TranscriptBlobSHA256
 	}
-
 	if result.Hash1 == "" || result.Hash2 == "" {
+		result.Error = "both runs need a transcript"
 		return result
 	}
 
-	// Check if identical
-	if result.Hash1 == result.Hash2 {
-		result.Identical = true
+	transcript1, err := cap.GetTranscript(run1ID)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	transcript2, err := cap.GetTranscript(run2ID)
+	if err != nil {
+		result.Error = err.Error()
 		return result
 	}
 
-	// Read transcript contents
-	var transcript1, transcript2 []byte
-	if blob1, ok := manifest.Blobs[result.Hash1]; ok {
-		transcript1 = readTranscriptBlob(extractDir, result.Hash1, blob1.Path)
+	opts := runner.DefaultDiffOptions()
+	opts.IgnoreAttributes = append(opts.IgnoreAttributes, ignore...)
+	diff, err := runner.DiffTranscripts(transcript1, transcript2, opts)
+	if err != nil {
+		result.Error = err.Error()
+		return result
 	}
-	if blob2, ok := manifest.Blobs[result.Hash2]; ok {
-		transcript2 = readTranscriptBlob(extractDir, result.Hash2, blob2.Path)
+	result.Diff = diff
+	result.Identical = diff.Identical
+	result.EventCount1 = diff.EventsA
+	result.EventCount2 = diff.EventsB
+	result.Changes = diff.Changes
+	if len(result.Changes) > compareChangeLimit {
+		result.MoreChanges = len(result.Changes) - compareChangeLimit
+		result.Changes = result.Changes[:compareChangeLimit]
 	}
+	return result
+}
 
-	// Parse transcripts as JSON lines
-	events1 := parseTranscriptEvents(transcript1)
-	events2 := parseTranscriptEvents(transcript2)
-
-	result.EventCount1 = len(events1)
-	result.EventCount2 = len(events2)
-
-	// Find differences
-	maxLen := len(events1)
-	if len(events2) > maxLen {
-		maxLen = len(events2)
+// capsuleContentDir returns the directory of an extracted capsule holding
+// manifest.json: the top level for packed capsules, or a capsule/
+// directory for archives that wrap their contents in one.
+func capsuleContentDir(extractDir string) string {
+	wrapped := filepath.Join(extractDir, "capsule")
+	if _, err := os.Stat(filepath.Join(wrapped, "manifest.json")); err == nil {
+		return wrapped
 	}
+	return extractDir
+}
 
-	for i := 0; i < maxLen; i++ {
-		var e1, e2 string
-		if i < len(events1) {
-			e1 = events1[i]
-		}
-		if i < len(events2) {
-			e2 = events2[i]
-		}
+// handleAPIRunsCompare returns the semantic diff of two runs as JSON.
+// The runs are given by the run_a and run_b query parameters; ignore
+// lists additional attributes to leave out, comma-separated.
+func handleAPIRunsCompare(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-		if e1 != e2 {
-			result.Differences = append(result.Differences, DiffEntry{
-				Index:  i,
-				Event1: e1,
-				Event2: e2,
-			})
-		}
+	capsulePath := strings.TrimPrefix(r.URL.Path, "/api/runs/compare/")
+	run1ID := r.URL.Query().Get("run_a")
+	run2ID := r.URL.Query().Get("run_b")
+	if capsulePath == "" || run1ID == "" || run2ID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "capsule path, run_a and run_b are required"})
+		return
 	}
 
-	return result
-}
-
-// readTranscriptBlob reads a transcript blob from the capsule.
-func readTranscriptBlob(extractDir, hash, blobPath string) []byte {
-	fullPath := filepath.Join(extractDir, "capsule", blobPath)
-	data, err := os.ReadFile(fullPath)
-	if err == nil {
-		return data
+	cleanPath, err := validation.SanitizePath(ServerConfig.CapsulesDir, capsulePath)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid path"})
+		return
+	}
+	fullPath := filepath.Join(ServerConfig.CapsulesDir, cleanPath)
+	if _, err := os.Stat(fullPath); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "capsule not found"})
+		return
 	}
-	return nil
-}
 
-// parseTranscriptEvents parses transcript data into event strings.
-func parseTranscriptEvents(data []byte) []string {
-	if len(data) == 0 {
-		return nil
+	tempDir, err := secureMkdirTemp("", "capsule-compare-*")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to create temp directory"})
+		return
+	}
+	defer os.RemoveAll(tempDir)
+	cap, err := capsule.Unpack(fullPath, tempDir)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "failed to extract capsule"})
+		return
 	}
 
-	var events []string
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			events = append(events, line)
-		}
+	var ignore []string
+	if v := r.URL.Query().Get("ignore"); v != "" {
+		ignore = strings.Split(v, ",")
+	}
+	result := performRunsCompare(cap, run1ID, run2ID, ignore)
+	if result.Error != "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
 	}
-	return events
+	json.NewEncoder(w).Encode(result)
 }
 
 // ToolsData is the data for the tools list page.
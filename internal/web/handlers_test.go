@@ -18,6 +18,7 @@ import (
 
 	"github.com/ulikunitz/xz"
 
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 	"github.com/FocuswithJustin/JuniperBible/internal/archive"
 	"github.com/FocuswithJustin/JuniperBible/internal/fileutil"
@@ -164,7 +165,8 @@ func TestDetectCapsuleFormat(t *testing.T) {
 		{"test.tar.xz", "tar.xz"},
 		{"test.tar.gz", "tar.gz"},
 		{"test.tar", "tar"},
-		{"test.zip", "unknown"},
+		{"test.zip", "zip"},
+		{"test.rar", "unknown"},
 	}
 
 	for _, tc := range tests {
@@ -256,6 +258,8 @@ func TestListCapsules(t *testing.T) {
 	os.WriteFile(filepath.Join(tmpDir, "test1.tar.xz"), []byte("test"), 0644)
 	os.WriteFile(filepath.Join(tmpDir, "test2.tar.gz"), []byte("test"), 0644)
 	os.WriteFile(filepath.Join(tmpDir, "notacapsule.txt"), []byte("test"), 0644)
+	// A raw module zip without a manifest is not a capsule
+	os.WriteFile(filepath.Join(tmpDir, "module.zip"), []byte("test"), 0644)
 
 	// Set ServerConfig to use temp dir
 	originalDir := ServerConfig.CapsulesDir
@@ -2191,3 +2195,75 @@ func TestParseLicenseText(t *testing.T) {
 		})
 	}
 }
+
+func TestRunsCompareSemanticDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalDir := ServerConfig.CapsulesDir
+	ServerConfig.CapsulesDir = tmpDir
+	defer func() { ServerConfig.CapsulesDir = originalDir }()
+
+	cap, err := capsule.New(filepath.Join(tmpDir, "work"))
+	if err != nil {
+		t.Fatalf("failed to create capsule: %v", err)
+	}
+	transcripts := map[string]string{
+		"run-1": `{"t":"ENTRY_RENDERED","module":"KJV","key":"Gen.1.1","sha256":"aa","bytes":1}
+{"t":"ENTRY_RENDERED","module":"KJV","key":"Gen.1.2","sha256":"bb","bytes":2}
+`,
+		"run-2": `{"t":"ENTRY_RENDERED","module":"KJV","key":"Gen.1.0","sha256":"00","bytes":1}
+{"t":"ENTRY_RENDERED","module":"KJV","key":"Gen.1.1","sha256":"aa","bytes":1}
+{"t":"ENTRY_RENDERED","module":"KJV","key":"Gen.1.2","sha256":"cc","bytes":3}
+`,
+	}
+	for id, transcript := range transcripts {
+		if err := cap.AddRun(&capsule.Run{ID: id, Status: "completed"}, []byte(transcript)); err != nil {
+			t.Fatalf("AddRun failed: %v", err)
+		}
+	}
+	if err := cap.Pack(filepath.Join(tmpDir, "runs.capsule.tar.xz")); err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+
+	t.Run("api", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/runs/compare/runs.capsule.tar.xz?run_a=run-1&run_b=run-2", nil)
+		w := httptest.NewRecorder()
+		handleAPIRunsCompare(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var result CompareResult
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		if result.Diff == nil || result.Diff.Summary.Added != 1 || result.Diff.Summary.Changed != 1 || result.Diff.Summary.Unchanged != 1 {
+			t.Errorf("unexpected diff %+v", result.Diff)
+		}
+	})
+
+	t.Run("api requires runs", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/runs/compare/runs.capsule.tar.xz?run_a=run-1", nil)
+		w := httptest.NewRecorder()
+		handleAPIRunsCompare(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("page", func(t *testing.T) {
+		unpacked, err := capsule.Unpack(filepath.Join(tmpDir, "runs.capsule.tar.xz"), t.TempDir())
+		if err != nil {
+			t.Fatalf("Unpack failed: %v", err)
+		}
+		tmpl := template.Must(template.New("").Funcs(templateFuncs()).ParseFS(templatesFS, "templates/*.html"))
+		var sb strings.Builder
+		data := RunsCompareData{Result: performRunsCompare(unpacked, "run-1", "run-2", nil)}
+		if err := tmpl.ExecuteTemplate(&sb, "runs_compare.html", data); err != nil {
+			t.Fatalf("template failed: %v", err)
+		}
+		for _, want := range []string{"1 added, 0 removed, 1 changed", "ENTRY_RENDERED KJV Gen.1.0 (sha256 00, 1 bytes)", "sha256: bb -&gt; cc"} {
+			if !strings.Contains(sb.String(), want) {
+				t.Errorf("page missing %q", want)
+			}
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package web
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+	"github.com/FocuswithJustin/JuniperBible/core/license"
+)
+
+// licenses is the license policy of the web server. Start replaces it
+// when a policy or override log is configured. The web UI cannot request
+// ad-hoc overrides; only standing overrides in the policy apply.
+var licenses = &license.Enforcer{Policy: license.DefaultPolicy()}
+
+// capsuleLicense returns the license of a capsule, relative to the
+// capsules directory. Unreadable capsules are NOASSERTION.
+func capsuleLicense(relPath string) *license.Info {
+	info, err := capsule.CachedLicense(filepath.Join(ServerConfig.CapsulesDir, relPath))
+	if err != nil {
+		log.Printf("[LICENSE] Failed to read license of %s: %v", relPath, err)
+		return &license.Info{ID: license.NoAssertion}
+	}
+	return info
+}
+
+// checkLicense applies the license policy to an action on a capsule. The
+// error is a permission error when the action is blocked.
+func checkLicense(relPath, action string) (*license.Verdict, error) {
+	return licenses.Enforce(relPath, capsuleLicense(relPath), action, "")
+}
+
+// licenseNotice returns the text shown for a discouraged action, or "".
+func licenseNotice(v *license.Verdict) string {
+	if v == nil || v.Decision != license.DecisionWarn {
+		return ""
+	}
+	return "License " + v.License + ": " + v.Reason
+}
@@ -0,0 +1,59 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLicensePolicyEnforcement(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalDir := ServerConfig.CapsulesDir
+	ServerConfig.CapsulesDir = tmpDir
+	defer func() { ServerConfig.CapsulesDir = originalDir }()
+
+	createTestCapsuleTarGz(t, filepath.Join(tmpDir, "nasb.tar.gz"), map[string][]byte{
+		"manifest.json":    []byte(`{"capsule_version":"1.0"}`),
+		"mods.d/nasb.conf": []byte("[NASB]\nDistributionLicense=Copyrighted; Permission to distribute granted to CrossWire\n"),
+	})
+	createTestCapsuleTarGz(t, filepath.Join(tmpDir, "nc.tar.gz"), map[string][]byte{
+		"manifest.json":  []byte(`{"capsule_version":"1.0"}`),
+		"mods.d/nc.conf": []byte("[NC]\nDistributionLicense=Copyrighted; Free non-commercial distribution\n"),
+	})
+
+	t.Run("display permitted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/artifact/nasb.tar.gz?artifact=nasb/mods.d/nasb.conf", nil)
+		w := httptest.NewRecorder()
+		handleArtifact(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("download blocked", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/export/nasb.tar.gz?artifact=nasb/mods.d/nasb.conf&download=true", nil)
+		w := httptest.NewRecorder()
+		handleExport(w, req)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "CrossWire") {
+			t.Errorf("expected 403 naming the license grant, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("download warns", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/export/nc.tar.gz?artifact=nc/mods.d/nc.conf&download=true", nil)
+		w := httptest.NewRecorder()
+		handleExport(w, req)
+		if w.Code != http.StatusOK || w.Header().Get("X-License-Warning") == "" {
+			t.Errorf("expected download with license warning, got %d %q", w.Code, w.Header().Get("X-License-Warning"))
+		}
+	})
+
+	t.Run("EPUB conversion blocked", func(t *testing.T) {
+		result := performConversion("nasb.tar.gz", "epub")
+		if result.Success || !strings.Contains(result.Message, "permission denied") {
+			t.Errorf("expected blocked conversion, got %+v", result)
+		}
+	})
+}
@@ -10,7 +10,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/FocuswithJustin/JuniperBible/core/capsule"
+	"github.com/FocuswithJustin/JuniperBible/core/license"
 	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 	"github.com/FocuswithJustin/JuniperBible/internal/logging"
 	"github.com/FocuswithJustin/JuniperBible/internal/server"
@@ -33,6 +36,14 @@ type Config struct {
 	SwordDir        string
 	PluginsExternal bool
 	TLS             TLSConfig // TLS configuration
+	IdentityFile    string    // age identity file for encrypted capsules
+
+	AuditDB     string        // fixity audit log (default: <CapsulesDir>/.fixity-audit.db)
+	AuditEvery  time.Duration // interval between scheduled fixity audits (0 disables)
+	AuditSample float64       // fraction of the library audited per scheduled run (0 = all)
+
+	LicensePolicy string // license policy file (default: built-in policy)
+	LicenseLog    string // license override log
 }
 
 // TLSConfig holds TLS/HTTPS configuration.
@@ -63,6 +74,15 @@ func Start(cfg Config) error {
 		}
 	}
 
+	// Load identities so encrypted capsules are decrypted transparently
+	if cfg.IdentityFile != "" {
+		ids, err := capsule.LoadIdentities(cfg.IdentityFile)
+		if err != nil {
+			return fmt.Errorf("failed to load identity: %w", err)
+		}
+		capsule.SetDefaultIdentities(ids)
+	}
+
 	// Default SWORD directory to ~/.sword if not specified
 	if ServerConfig.SwordDir == "" {
 		if home, _ := os.UserHomeDir(); home != "" {
@@ -82,6 +102,10 @@ func Start(cfg Config) error {
 		return fmt.Errorf("failed to parse templates: %w", err)
 	}
 
+	if licenses, err = license.NewEnforcer(cfg.LicensePolicy, cfg.LicenseLog); err != nil {
+		return err
+	}
+
 	// Setup routes
 	mux := setupRoutes()
 
@@ -124,6 +148,7 @@ func Start(cfg Config) error {
 	// Pre-warm caches in background and start background refresh
 	PreWarmCaches()
 	StartBackgroundCacheRefresh()
+	StartAuditScheduler()
 
 	// Apply middleware chain: splash -> logging -> timing -> security headers with CSP
 	// Splash middleware serves the splash screen during startup warmup
@@ -217,6 +242,8 @@ func setupRoutes() *http.ServeMux {
 	mux.HandleFunc("/selfcheck/", handleSelfcheck)
 	mux.HandleFunc("/runs/compare/", handleRunsCompare)
 	mux.HandleFunc("/runs/", handleRuns)
+	mux.HandleFunc("/audit/run", handleAuditRun)
+	mux.HandleFunc("/audit", handleAudit)
 	mux.HandleFunc("/tools", handleTools)
 	mux.HandleFunc("/tools/run", handleToolRun)
 
@@ -241,6 +268,7 @@ func setupRoutes() *http.ServeMux {
 	// Task queue API
 	mux.HandleFunc("/api/tasks/add", handleTaskAdd)
 	mux.HandleFunc("/api/tasks/status", handleTaskStatus)
+	mux.HandleFunc("/api/tasks/cancel", handleTaskCancel)
 	mux.HandleFunc("/api/tasks/clear", handleTaskClear)
 
 	// Startup status API (for splash screen)
@@ -251,5 +279,8 @@ func setupRoutes() *http.ServeMux {
 	mux.HandleFunc("/api/bibles/", handleAPIBibles)
 	mux.HandleFunc("/api/bibles", handleAPIBibles)
 
+	// Runs API
+	mux.HandleFunc("/api/runs/compare/", handleAPIRunsCompare)
+
 	return mux
 }
@@ -1,13 +1,18 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/FocuswithJustin/JuniperBible/core/audit"
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
 )
 
 // TaskType identifies the kind of task.
@@ -22,6 +27,7 @@ const (
 	TaskVerify     TaskType = "verify"
 	TaskSelfcheck  TaskType = "selfcheck"
 	TaskToolRun    TaskType = "tool_run"
+	TaskAudit      TaskType = "audit"
 )
 
 // Task represents an async task in the queue.
@@ -29,7 +35,7 @@ type Task struct {
 	ID         string                 `json:"id"`
 	Type       TaskType               `json:"type"`
 	Name       string                 `json:"name"`
-	Status     string                 `json:"status"` // "queued", "running", "completed", "failed"
+	Status     string                 `json:"status"` // "queued", "running", "completed", "failed", "cancelled"
 	Progress   int                    `json:"progress,omitempty"` // 0-100
 	Message    string                 `json:"message,omitempty"`
 	Error      string                 `json:"error,omitempty"`
@@ -38,6 +44,9 @@ type Task struct {
 	QueuedAt   time.Time              `json:"queued_at"`
 	StartedAt  time.Time              `json:"started_at,omitempty"`
 	FinishedAt time.Time              `json:"finished_at,omitempty"`
+
+	ctx    context.Context    // Cancelled by CancelTask
+	cancel context.CancelFunc
 }
 
 // TaskQueue manages async tasks.
@@ -82,6 +91,7 @@ func (q *TaskQueue) AddTask(taskType TaskType, name string, params map[string]st
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
+	ctx, cancel := context.WithCancel(context.Background())
 	task := &Task{
 		ID:       q.generateID(),
 		Type:     taskType,
@@ -89,6 +99,8 @@ func (q *TaskQueue) AddTask(taskType TaskType, name string, params map[string]st
 		Status:   "queued",
 		Params:   params,
 		QueuedAt: time.Now(),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 
 	q.tasks[task.ID] = task
@@ -211,6 +223,70 @@ func (q *TaskQueue) updateTaskProgress(id string, progress int, message string)
 	}
 }
 
+// cancellableWhileRunning reports whether running tasks of a type stop
+// when cancelled. Conversions and exports stop at their next plugin call,
+// or during one when the plugin supports it, and audits between capsules;
+// the other tasks run to completion once started.
+func cancellableWhileRunning(taskType TaskType) bool {
+	switch taskType {
+	case TaskConvert, TaskExport, TaskAudit:
+		return true
+	}
+	return false
+}
+
+// CancelTask cancels a queued task, or a running task whose type honors
+// cancellation.
+func (q *TaskQueue) CancelTask(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	task, ok := q.tasks[id]
+	if !ok {
+		return fmt.Errorf("task not found: %s", id)
+	}
+	switch task.Status {
+	case "queued":
+		task.cancel()
+		task.Status = "cancelled"
+		task.FinishedAt = time.Now()
+		q.moveToHistory(id)
+	case "running":
+		if !cancellableWhileRunning(task.Type) {
+			return fmt.Errorf("running %s tasks cannot be cancelled", task.Type)
+		}
+		task.cancel()
+		task.Message = "Cancelling..."
+	default:
+		return fmt.Errorf("task cannot be cancelled (status: %s)", task.Status)
+	}
+	log.Printf("[TASK QUEUE] Cancelled %s: %s", task.Type, task.Name)
+	return nil
+}
+
+// progressSpan is the part of a task's progress bar a plugin command
+// fills.
+type progressSpan struct {
+	from, to int
+}
+
+// pluginProgress returns a context for the plugin calls of a task: it is
+// cancelled with the task, and the interim messages of commands in spans
+// update the task's progress and message.
+func (q *TaskQueue) pluginProgress(task *Task, spans map[string]progressSpan) context.Context {
+	return plugins.WithProgress(task.ctx, func(ev plugins.ProgressEvent) {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if ev.Message != "" {
+			task.Message = ev.Message
+		}
+		span, ok := spans[ev.Command]
+		if pct := ev.Percent(); ok && pct >= 0 && ev.Kind == plugins.EventProgress {
+			task.Progress = span.from + pct*(span.to-span.from)/100
+		}
+	})
+}
+
 // runTask executes a task based on its type.
 func (q *TaskQueue) runTask(task *Task) {
 	log.Printf("[TASK QUEUE] Starting %s: %s", task.Type, task.Name)
@@ -235,6 +311,8 @@ func (q *TaskQueue) runTask(task *Task) {
 		result, err = q.runSelfcheckTask(task)
 	case TaskToolRun:
 		result, err = q.runToolTask(task)
+	case TaskAudit:
+		result, err = q.runAuditTask(task)
 	default:
 		err = fmt.Errorf("unknown task type: %s", task.Type)
 	}
@@ -242,7 +320,12 @@ func (q *TaskQueue) runTask(task *Task) {
 	q.mu.Lock()
 	task.FinishedAt = time.Now()
 	task.Progress = 100
-	if err != nil {
+	// Work that finished despite a late cancel keeps its real outcome
+	if err != nil && task.ctx.Err() != nil {
+		task.Status = "cancelled"
+		task.Message = "Cancelled"
+		log.Printf("[TASK QUEUE] Cancelled %s: %s", task.Type, task.Name)
+	} else if err != nil {
 		task.Status = "failed"
 		task.Error = err.Error()
 		log.Printf("[TASK QUEUE] Failed %s: %s - %v", task.Type, task.Name, err)
@@ -253,6 +336,7 @@ func (q *TaskQueue) runTask(task *Task) {
 	}
 	q.moveToHistory(task.ID)
 	q.mu.Unlock()
+	task.cancel()
 }
 
 // moveToHistory moves a completed task to history (must hold lock).
@@ -347,7 +431,8 @@ func (q *TaskQueue) runConvertTask(task *Task) (interface{}, error) {
 	switch action {
 	case "generate_ir":
 		q.updateTaskProgress(task.ID, 30, "Generating IR...")
-		result := performIRGeneration(capsule)
+		ctx := q.pluginProgress(task, map[string]progressSpan{"extract-ir": {30, 90}})
+		result := performIRGenerationContext(ctx, capsule)
 		if !result.Success {
 			return nil, fmt.Errorf("%s", result.Message)
 		}
@@ -358,7 +443,7 @@ func (q *TaskQueue) runConvertTask(task *Task) (interface{}, error) {
 	case "export":
 		format := task.Params["format"]
 		q.updateTaskProgress(task.ID, 30, "Exporting to "+format+"...")
-		result := performConversion(capsule, format)
+		result := performConversionContext(q.conversionContext(task), capsule, format)
 		if !result.Success {
 			return nil, fmt.Errorf("%s", result.Message)
 		}
@@ -369,12 +454,21 @@ func (q *TaskQueue) runConvertTask(task *Task) (interface{}, error) {
 	}
 }
 
+// conversionContext returns the plugin context of a conversion, whose
+// IR extraction fills 30-60% of the progress bar and emission 60-90%.
+func (q *TaskQueue) conversionContext(task *Task) context.Context {
+	return q.pluginProgress(task, map[string]progressSpan{
+		"extract-ir":  {30, 60},
+		"emit-native": {60, 90},
+	})
+}
+
 func (q *TaskQueue) runExportTask(task *Task) (interface{}, error) {
 	capsule := task.Params["capsule"]
 	format := task.Params["format"]
 
 	q.updateTaskProgress(task.ID, 30, "Exporting...")
-	result := performConversion(capsule, format)
+	result := performConversionContext(q.conversionContext(task), capsule, format)
 	if !result.Success {
 		return nil, fmt.Errorf("%s", result.Message)
 	}
@@ -432,6 +526,28 @@ func (q *TaskQueue) runToolTask(task *Task) (interface{}, error) {
 	return map[string]string{"tool": tool, "input": input}, nil
 }
 
+func (q *TaskQueue) runAuditTask(task *Task) (interface{}, error) {
+	sample, _ := strconv.ParseFloat(task.Params["sample"], 64)
+
+	q.updateTaskProgress(task.ID, 0, "Starting fixity audit...")
+	return runAudit(task.ctx, sample, func(done, total int, result *audit.Result) {
+		q.updateTaskProgress(task.ID, done*100/total, fmt.Sprintf("Audited %d/%d: %s", done, total, result.Capsule))
+	})
+}
+
+// HasPending reports whether a task of the given type is queued or running.
+func (q *TaskQueue) HasPending(taskType TaskType) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	for _, id := range q.queue {
+		if task, ok := q.tasks[id]; ok && task.Type == taskType {
+			return true
+		}
+	}
+	return false
+}
+
 // ClearHistory removes all completed/failed tasks from history.
 func (q *TaskQueue) ClearHistory() {
 	q.mu.Lock()
@@ -508,6 +624,27 @@ func handleTaskStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(taskQueue.GetStatus())
 }
 
+// handleTaskCancel handles POST requests to cancel a task.
+func handleTaskCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.FormValue("id")
+	if id == "" {
+		jsonErrorTask(w, "Missing required field: id", http.StatusBadRequest)
+		return
+	}
+	if err := taskQueue.CancelTask(id); err != nil {
+		jsonErrorTask(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
 // handleTaskClear handles POST requests to clear history.
 func handleTaskClear(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
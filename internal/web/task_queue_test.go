@@ -0,0 +1,105 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/FocuswithJustin/JuniperBible/core/plugins"
+)
+
+// newTestTaskQueue returns a queue without workers.
+func newTestTaskQueue() *TaskQueue {
+	return &TaskQueue{
+		tasks:    make(map[string]*Task),
+		queue:    make([]string, 0),
+		history:  make([]*Task, 0),
+		maxHist:  50,
+		shutdown: make(chan struct{}),
+	}
+}
+
+func TestTaskQueueCancelTask(t *testing.T) {
+	q := newTestTaskQueue()
+
+	queued := q.AddTask(TaskConvert, "queued", nil)
+	if err := q.CancelTask(queued.ID); err != nil {
+		t.Fatalf("CancelTask failed: %v", err)
+	}
+	if queued.Status != "cancelled" || queued.ctx.Err() == nil || q.HasPending(TaskConvert) {
+		t.Errorf("queued task not cancelled: %+v", queued)
+	}
+	if err := q.CancelTask(queued.ID); err == nil {
+		t.Error("expected an error cancelling a finished task")
+	}
+
+	// A running task that ignores cancellation cannot be cancelled
+	verify := q.AddTask(TaskVerify, "verify", map[string]string{"capsule": "x"})
+	if q.getNextTask() != verify {
+		t.Fatal("expected the running task")
+	}
+	if err := q.CancelTask(verify.ID); err == nil {
+		t.Error("expected an error cancelling a running verify task")
+	}
+	q.runTask(verify)
+	if verify.Status != "completed" {
+		t.Errorf("verify task not completed: %+v", verify)
+	}
+
+	// A running task finishes as cancelled once its work returns
+	running := q.AddTask(TaskConvert, "running", map[string]string{"action": "export", "capsule": "missing.capsule.tar.xz", "format": "osis"})
+	if q.getNextTask() != running {
+		t.Fatal("expected the running task")
+	}
+	if err := q.CancelTask(running.ID); err != nil {
+		t.Fatalf("CancelTask failed: %v", err)
+	}
+	q.runTask(running)
+	if running.Status != "cancelled" || running.Error != "" {
+		t.Errorf("running task not cancelled: %+v", running)
+	}
+
+	// Work that completes despite the cancel reports its outcome: an
+	// audit of an empty library has no capsule to stop before
+	originalDir := ServerConfig.CapsulesDir
+	ServerConfig.CapsulesDir = t.TempDir()
+	defer func() { ServerConfig.CapsulesDir = originalDir }()
+	audited := q.AddTask(TaskAudit, "audit", map[string]string{"sample": "1"})
+	q.getNextTask()
+	if err := q.CancelTask(audited.ID); err != nil {
+		t.Fatalf("CancelTask failed: %v", err)
+	}
+	q.runTask(audited)
+	if audited.Status != "completed" {
+		t.Errorf("expected the finished audit to be completed: %+v", audited)
+	}
+
+	if err := q.CancelTask("missing"); err == nil {
+		t.Error("expected an error for an unknown task")
+	}
+}
+
+func TestTaskQueuePluginProgress(t *testing.T) {
+	q := newTestTaskQueue()
+	task := q.AddTask(TaskConvert, "convert", nil)
+	ctx := q.conversionContext(task)
+
+	plugins.ReportProgress(ctx, plugins.ProgressEvent{Command: "extract-ir", Message: "Parsing OSIS", Done: 1, Total: 2})
+	if task.Progress != 45 || task.Message != "Parsing OSIS" {
+		t.Errorf("after extract-ir: progress %d, message %q", task.Progress, task.Message)
+	}
+	plugins.ReportProgress(ctx, plugins.ProgressEvent{Command: "emit-native", Done: 2, Total: 2})
+	if task.Progress != 90 {
+		t.Errorf("after emit-native: progress %d", task.Progress)
+	}
+
+	// Log lines and commands outside the conversion only update the message
+	plugins.ReportProgress(ctx, plugins.ProgressEvent{Kind: plugins.EventLog, Command: "extract-ir", Message: "note", Done: 1, Total: 4})
+	plugins.ReportProgress(ctx, plugins.ProgressEvent{Command: "detect", Done: 1, Total: 4})
+	if task.Progress != 90 || task.Message != "note" {
+		t.Errorf("after log: progress %d, message %q", task.Progress, task.Message)
+	}
+
+	q.CancelTask(task.ID)
+	if ctx.Err() == nil {
+		t.Error("expected the plugin context to be cancelled with the task")
+	}
+}
@@ -2,48 +2,39 @@
 // It serves as a template for external/premium plugins and indicates where
 // additional plugins can be installed.
 //
-// This plugin does nothing - it's a noop (no operation) placeholder.
+// This plugin does nothing - it's a noop (no operation) placeholder. It
+// runs through ipc.Main, so it answers both one-shot requests and
+// persistent JSON-RPC sessions.
 package main
 
 import (
-	"encoding/json"
-	"os"
+	"context"
+	"fmt"
 
 	"github.com/FocuswithJustin/JuniperBible/plugins/ipc"
 )
 
 func main() {
-	var req ipc.Request
-	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
-		respond(ipc.Response{Status: "error", Error: "failed to decode request"})
-		return
-	}
+	ipc.Main(ipc.ServerInfo{
+		PluginID: "example.noop",
+		Version:  "1.0.0",
+		Methods:  []string{"detect", "info"},
+	}, handle)
+}
 
+func handle(ctx context.Context, req *ipc.Request) (interface{}, error) {
 	switch req.Command {
 	case "detect":
-		respond(ipc.Response{
-			Status: "ok",
-			Result: map[string]interface{}{
-				"detected": false,
-				"reason":   "noop plugin - placeholder for external/premium plugins",
-			},
-		})
+		return map[string]interface{}{
+			"detected": false,
+			"reason":   "noop plugin - placeholder for external/premium plugins",
+		}, nil
 	case "info":
-		respond(ipc.Response{
-			Status: "ok",
-			Result: map[string]interface{}{
-				"description": "Placeholder plugin for the plugins directory. Add external or premium plugins here.",
-				"note":        "Core functionality is embedded in the main binaries.",
-			},
-		})
+		return map[string]interface{}{
+			"description": "Placeholder plugin for the plugins directory. Add external or premium plugins here.",
+			"note":        "Core functionality is embedded in the main binaries.",
+		}, nil
 	default:
-		respond(ipc.Response{
-			Status: "error",
-			Error:  "noop plugin: command not supported - this is a placeholder",
-		})
+		return nil, fmt.Errorf("noop plugin: command not supported - this is a placeholder")
 	}
 }
-
-func respond(resp ipc.Response) {
-	json.NewEncoder(os.Stdout).Encode(resp)
-}
@@ -0,0 +1,144 @@
+// Plugin example-wasm is a template for format plugins that run as
+// sandboxed WebAssembly modules. It handles a toy "verse list" format:
+// text files with the .vrs extension holding one verse per line as a
+// reference and the verse text separated by a tab.
+//
+// The plugin is ordinary Go built for WASI:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -o example-wasm.wasm ./plugins/example/wasm
+//
+// The host runs it in-process with the one-shot protocol: the request is
+// on stdin and the response goes to stdout, so ipc.Main works unchanged.
+// The module can only read the files named by the path and ir_path
+// arguments and write under output_dir; it has no network access and
+// cannot start processes.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/plugins/ipc"
+)
+
+const formatName = "verse-list"
+
+func main() {
+	ipc.Main(ipc.ServerInfo{
+		PluginID: "example.wasm",
+		Version:  "1.0.0",
+		Methods:  []string{"detect", "ingest", "enumerate"},
+	}, handle)
+}
+
+func handle(ctx context.Context, req *ipc.Request) (interface{}, error) {
+	switch req.Command {
+	case "detect":
+		return detect(req.Args)
+	case "ingest":
+		return ingest(ctx, req.Args)
+	case "enumerate":
+		return enumerate(req.Args)
+	default:
+		return nil, fmt.Errorf("unknown command: %s", req.Command)
+	}
+}
+
+// detect accepts .vrs files whose first line is a tab-separated verse.
+func detect(args map[string]interface{}) (*ipc.DetectResult, error) {
+	path, err := ipc.StringArg(args, "path")
+	if err != nil {
+		return nil, err
+	}
+	if !ipc.CheckExtension(path, ".vrs") {
+		return ipc.DetectFailure("not a .vrs file"), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ipc.DetectFailure(fmt.Sprintf("cannot read file: %v", err)), nil
+	}
+	verses := parseVerses(data)
+	if len(verses) == 0 {
+		return ipc.DetectFailure("no tab-separated verses"), nil
+	}
+	return ipc.DetectSuccess(formatName, fmt.Sprintf("%d verses", len(verses))), nil
+}
+
+// ingest stores the file as a blob, reporting progress as it goes.
+func ingest(ctx context.Context, args map[string]interface{}) (*ipc.IngestResult, error) {
+	path, outputDir, err := ipc.PathAndOutputDir(args)
+	if err != nil {
+		return nil, err
+	}
+	ipc.Progress(ctx, "read", "reading "+filepath.Base(path), 1, 3)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	ipc.Progress(ctx, "parse", "counting verses", 2, 3)
+	verses := parseVerses(data)
+	ipc.Progress(ctx, "store", "storing blob", 3, 3)
+	hashHex, err := ipc.StoreBlob(outputDir, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store blob: %w", err)
+	}
+	return &ipc.IngestResult{
+		ArtifactID: ipc.ArtifactIDFromPath(path),
+		BlobSHA256: hashHex,
+		SizeBytes:  int64(len(data)),
+		Metadata: map[string]string{
+			"format":      formatName,
+			"verse_count": strconv.Itoa(len(verses)),
+		},
+	}, nil
+}
+
+// enumerate lists the verses of the file.
+func enumerate(args map[string]interface{}) (*ipc.EnumerateResult, error) {
+	path, err := ipc.StringArg(args, "path")
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	result := &ipc.EnumerateResult{Entries: []ipc.EnumerateEntry{}}
+	for _, v := range parseVerses(data) {
+		result.Entries = append(result.Entries, ipc.EnumerateEntry{
+			Path:      v.ref,
+			SizeBytes: int64(len(v.text)),
+			Metadata:  map[string]string{"format": formatName},
+		})
+	}
+	return result, nil
+}
+
+type verse struct {
+	ref, text string
+}
+
+// parseVerses returns the verses of a verse list, skipping blank lines.
+// It returns nil if any other line is not a tab-separated verse.
+func parseVerses(data []byte) []verse {
+	var verses []verse
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		ref, text, ok := strings.Cut(line, "\t")
+		if !ok || ref == "" {
+			return nil
+		}
+		verses = append(verses, verse{ref: ref, text: text})
+	}
+	return verses
+}
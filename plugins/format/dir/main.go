@@ -54,9 +54,10 @@ func handleDetect(args map[string]interface{}) {
 	}
 
 	ipc.MustRespond(&ipc.DetectResult{
-		Detected: true,
-		Format:   "dir",
-		Reason:   "directory detected",
+		Detected:    true,
+		Format:      "dir",
+		Reason:      "directory detected",
+		Specificity: ipc.SpecificityAny,
 	})
 }
 
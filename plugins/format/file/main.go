@@ -54,9 +54,10 @@ func handleDetect(args map[string]interface{}) {
 	}
 
 	ipc.MustRespond(&ipc.DetectResult{
-		Detected: true,
-		Format:   "file",
-		Reason:   "single file detected",
+		Detected:    true,
+		Format:      "file",
+		Reason:      "single file detected",
+		Specificity: ipc.SpecificityAny,
 	})
 }
 
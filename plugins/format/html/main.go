@@ -324,6 +324,12 @@ func parseHTMLContent(content, artifactID string) []*ipc.Document {
 	return []*ipc.Document{doc}
 }
 
+// Page layouts of emitted HTML, chosen with the layout option.
+const (
+	layoutSingle  = "single"  // One page with every book
+	layoutChapter = "chapter" // A directory with a page per chapter and an index page
+)
+
 func handleEmitNative(args map[string]interface{}) {
 	irPath, ok := args["ir_path"].(string)
 	if !ok {
@@ -349,6 +355,27 @@ func handleEmitNative(args map[string]interface{}) {
 		return
 	}
 
+	lossReport := &ipc.LossReport{
+		SourceFormat: "IR",
+		TargetFormat: "HTML",
+		LossClass:    "L1",
+	}
+
+	if ipc.OptionString(args, "layout", layoutSingle) == layoutChapter {
+		outputPath := filepath.Join(outputDir, corpus.ID)
+		if err := emitChapterPages(&corpus, outputPath); err != nil {
+			ipc.RespondErrorf("%v", err)
+			return
+		}
+		ipc.MustRespond(&ipc.EmitNativeResult{
+			OutputPath: outputPath,
+			Format:     "HTML",
+			LossClass:  "L1",
+			LossReport: lossReport,
+		})
+		return
+	}
+
 	outputPath := filepath.Join(outputDir, corpus.ID+".html")
 
 	// Check for raw HTML for round-trip
@@ -372,65 +399,19 @@ func handleEmitNative(args map[string]interface{}) {
 
 	// Generate HTML from IR
 	var buf strings.Builder
-
-	// HTML header
-	buf.WriteString("<!DOCTYPE html>\n")
-	buf.WriteString("<html lang=\"")
-	if corpus.Language != "" {
-		buf.WriteString(corpus.Language)
-	} else {
-		buf.WriteString("en")
-	}
-	buf.WriteString("\">\n")
-	buf.WriteString("<head>\n")
-	buf.WriteString("  <meta charset=\"UTF-8\">\n")
-	buf.WriteString("  <meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\">\n")
-	buf.WriteString(fmt.Sprintf("  <title>%s</title>\n", escapeHTML(corpus.Title)))
-	buf.WriteString("  <style>\n")
-	buf.WriteString("    body { font-family: Georgia, serif; max-width: 800px; margin: 0 auto; padding: 20px; }\n")
-	buf.WriteString("    h1 { text-align: center; }\n")
-	buf.WriteString("    h2 { margin-top: 2em; border-bottom: 1px solid #ccc; }\n")
-	buf.WriteString("    .verse { margin: 0.5em 0; }\n")
-	buf.WriteString("    .verse-num { font-weight: bold; color: #666; margin-right: 0.5em; }\n")
-	buf.WriteString("  </style>\n")
-	buf.WriteString("</head>\n")
-	buf.WriteString("<body>\n")
-
+	writePageHeader(&buf, corpus.Language, corpus.Title)
 	buf.WriteString(fmt.Sprintf("<h1>%s</h1>\n", escapeHTML(corpus.Title)))
 
 	for _, doc := range corpus.Documents {
 		buf.WriteString(fmt.Sprintf("<article id=\"%s\">\n", doc.ID))
 		buf.WriteString(fmt.Sprintf("<h2>%s</h2>\n", escapeHTML(doc.Title)))
-
-		currentChapter := 0
-		for _, cb := range doc.ContentBlocks {
-			for _, anchor := range cb.Anchors {
-				for _, span := range anchor.Spans {
-					if span.Ref != nil && span.Type == "VERSE" {
-						if span.Ref.Chapter != currentChapter {
-							if currentChapter > 0 {
-								buf.WriteString("</section>\n")
-							}
-							currentChapter = span.Ref.Chapter
-							buf.WriteString(fmt.Sprintf("<section class=\"chapter\" id=\"ch%d\">\n", currentChapter))
-							buf.WriteString(fmt.Sprintf("<h3>Chapter %d</h3>\n", currentChapter))
-						}
-						buf.WriteString(fmt.Sprintf("<p class=\"verse\" data-verse=\"%d\">", span.Ref.Verse))
-						buf.WriteString(fmt.Sprintf("<span class=\"verse-num\">%d</span>", span.Ref.Verse))
-						buf.WriteString(fmt.Sprintf("<span class=\"verse-text\">%s</span>", escapeHTML(cb.Text)))
-						buf.WriteString("</p>\n")
-					}
-				}
-			}
-		}
-		if currentChapter > 0 {
-			buf.WriteString("</section>\n")
+		for _, ch := range documentChapters(doc) {
+			writeChapter(&buf, ch)
 		}
 		buf.WriteString("</article>\n")
 	}
 
-	buf.WriteString("</body>\n")
-	buf.WriteString("</html>\n")
+	writePageFooter(&buf)
 
 	if err := os.WriteFile(outputPath, []byte(buf.String()), 0644); err != nil {
 		ipc.RespondErrorf("failed to write HTML: %v", err)
@@ -440,14 +421,151 @@ func handleEmitNative(args map[string]interface{}) {
 		OutputPath: outputPath,
 		Format:     "HTML",
 		LossClass:  "L1",
-		LossReport: &ipc.LossReport{
-			SourceFormat: "IR",
-			TargetFormat: "HTML",
-			LossClass:    "L1",
-		},
+		LossReport: lossReport,
 	})
 }
 
+// htmlChapter is a chapter of a document with the text of its verses.
+type htmlChapter struct {
+	Doc    *ipc.Document
+	Number int
+	Verses []htmlVerse
+}
+
+type htmlVerse struct {
+	Number int
+	Text   string
+}
+
+// documentChapters groups the verses of a document by chapter, in
+// document order.
+func documentChapters(doc *ipc.Document) []*htmlChapter {
+	var chapters []*htmlChapter
+	var current *htmlChapter
+	for _, cb := range doc.ContentBlocks {
+		for _, anchor := range cb.Anchors {
+			for _, span := range anchor.Spans {
+				if span.Ref == nil || span.Type != "VERSE" {
+					continue
+				}
+				if current == nil || span.Ref.Chapter != current.Number {
+					current = &htmlChapter{Doc: doc, Number: span.Ref.Chapter}
+					chapters = append(chapters, current)
+				}
+				current.Verses = append(current.Verses, htmlVerse{Number: span.Ref.Verse, Text: cb.Text})
+			}
+		}
+	}
+	return chapters
+}
+
+// chapterFileName is the page of a chapter in the chapter layout.
+func chapterFileName(ch *htmlChapter) string {
+	return fmt.Sprintf("%s.%d.html", ch.Doc.ID, ch.Number)
+}
+
+// emitChapterPages writes the chapter layout into dir: a page per
+// chapter, linked to its neighbours, and an index.html listing them.
+func emitChapterPages(corpus *ipc.Corpus, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var index strings.Builder
+	writePageHeader(&index, corpus.Language, corpus.Title)
+	index.WriteString(fmt.Sprintf("<h1>%s</h1>\n", escapeHTML(corpus.Title)))
+
+	var chapters []*htmlChapter
+	for _, doc := range corpus.Documents {
+		docChapters := documentChapters(doc)
+		if len(docChapters) == 0 {
+			continue
+		}
+		index.WriteString(fmt.Sprintf("<h2>%s</h2>\n<ul class=\"chapters\">\n", escapeHTML(doc.Title)))
+		for _, ch := range docChapters {
+			index.WriteString(fmt.Sprintf("<li><a href=\"%s\">Chapter %d</a></li>\n", escapeHTML(chapterFileName(ch)), ch.Number))
+		}
+		index.WriteString("</ul>\n")
+		chapters = append(chapters, docChapters...)
+	}
+	writePageFooter(&index)
+
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte(index.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write HTML: %w", err)
+	}
+
+	for i, ch := range chapters {
+		var page strings.Builder
+		writePageHeader(&page, corpus.Language, fmt.Sprintf("%s - %s %d", corpus.Title, ch.Doc.Title, ch.Number))
+		nav := chapterNav(chapters, i)
+		page.WriteString(nav)
+		page.WriteString(fmt.Sprintf("<h2>%s</h2>\n", escapeHTML(ch.Doc.Title)))
+		writeChapter(&page, ch)
+		page.WriteString(nav)
+		writePageFooter(&page)
+
+		if err := os.WriteFile(filepath.Join(dir, chapterFileName(ch)), []byte(page.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write HTML: %w", err)
+		}
+	}
+	return nil
+}
+
+// chapterNav links a chapter page to the previous and next chapters and
+// the index page.
+func chapterNav(chapters []*htmlChapter, i int) string {
+	links := make([]string, 0, 3)
+	if i > 0 {
+		links = append(links, fmt.Sprintf("<a href=\"%s\" rel=\"prev\">Previous</a>", escapeHTML(chapterFileName(chapters[i-1]))))
+	}
+	links = append(links, "<a href=\"index.html\">Contents</a>")
+	if i < len(chapters)-1 {
+		links = append(links, fmt.Sprintf("<a href=\"%s\" rel=\"next\">Next</a>", escapeHTML(chapterFileName(chapters[i+1]))))
+	}
+	return "<nav>" + strings.Join(links, " | ") + "</nav>\n"
+}
+
+// writeChapter writes a chapter section with its verses.
+func writeChapter(buf *strings.Builder, ch *htmlChapter) {
+	buf.WriteString(fmt.Sprintf("<section class=\"chapter\" id=\"ch%d\">\n", ch.Number))
+	buf.WriteString(fmt.Sprintf("<h3>Chapter %d</h3>\n", ch.Number))
+	for _, v := range ch.Verses {
+		buf.WriteString(fmt.Sprintf("<p class=\"verse\" data-verse=\"%d\">", v.Number))
+		buf.WriteString(fmt.Sprintf("<span class=\"verse-num\">%d</span>", v.Number))
+		buf.WriteString(fmt.Sprintf("<span class=\"verse-text\">%s</span>", escapeHTML(v.Text)))
+		buf.WriteString("</p>\n")
+	}
+	buf.WriteString("</section>\n")
+}
+
+// writePageHeader writes the doctype, head and opening body tag of a page.
+func writePageHeader(buf *strings.Builder, lang, title string) {
+	if lang == "" {
+		lang = "en"
+	}
+	buf.WriteString("<!DOCTYPE html>\n")
+	buf.WriteString(fmt.Sprintf("<html lang=\"%s\">\n", lang))
+	buf.WriteString("<head>\n")
+	buf.WriteString("  <meta charset=\"UTF-8\">\n")
+	buf.WriteString("  <meta name=\"viewport\" content=\"width=device-width, initial-scale=1.0\">\n")
+	buf.WriteString(fmt.Sprintf("  <title>%s</title>\n", escapeHTML(title)))
+	buf.WriteString("  <style>\n")
+	buf.WriteString("    body { font-family: Georgia, serif; max-width: 800px; margin: 0 auto; padding: 20px; }\n")
+	buf.WriteString("    h1 { text-align: center; }\n")
+	buf.WriteString("    h2 { margin-top: 2em; border-bottom: 1px solid #ccc; }\n")
+	buf.WriteString("    .verse { margin: 0.5em 0; }\n")
+	buf.WriteString("    .verse-num { font-weight: bold; color: #666; margin-right: 0.5em; }\n")
+	buf.WriteString("  </style>\n")
+	buf.WriteString("</head>\n")
+	buf.WriteString("<body>\n")
+}
+
+// writePageFooter closes a page opened by writePageHeader.
+func writePageFooter(buf *strings.Builder) {
+	buf.WriteString("</body>\n")
+	buf.WriteString("</html>\n")
+}
+
 func escapeHTML(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
 	s = strings.ReplaceAll(s, "<", "&lt;")
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -308,6 +309,78 @@ func TestHTMLEmitNative(t *testing.T) {
 	}
 }
 
+// TestHTMLEmitNativeChapterLayout tests the chapter layout option.
+func TestHTMLEmitNativeChapterLayout(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	verse := func(chapter int, text string) *ipc.ContentBlock {
+		return &ipc.ContentBlock{
+			ID:   fmt.Sprintf("cb-%d", chapter),
+			Text: text,
+			Anchors: []*ipc.Anchor{
+				{
+					ID: "a",
+					Spans: []*ipc.Span{
+						{ID: "s", Type: "VERSE", Ref: &ipc.Ref{Book: "Gen", Chapter: chapter, Verse: 1}},
+					},
+				},
+			},
+		}
+	}
+	corpus := ipc.Corpus{
+		ID:    "test",
+		Title: "Test Bible",
+		Documents: []*ipc.Document{
+			{
+				ID:            "Gen",
+				Title:         "Genesis",
+				ContentBlocks: []*ipc.ContentBlock{verse(1, "In the beginning."), verse(2, "Thus the heavens.")},
+			},
+		},
+	}
+
+	irData, err := json.Marshal(&corpus)
+	if err != nil {
+		t.Fatalf("failed to marshal IR: %v", err)
+	}
+	irPath := filepath.Join(tmpDir, "test.ir.json")
+	if err := os.WriteFile(irPath, irData, 0644); err != nil {
+		t.Fatalf("failed to write IR file: %v", err)
+	}
+
+	req := ipc.Request{
+		Command: "emit-native",
+		Args: map[string]interface{}{
+			"ir_path":    irPath,
+			"output_dir": tmpDir,
+			"options":    map[string]interface{}{"layout": "chapter"},
+		},
+	}
+
+	resp := executePlugin(t, &req)
+	if resp.Status != "ok" {
+		t.Fatalf("expected status ok, got %s: %s", resp.Status, resp.Error)
+	}
+	result := resp.Result.(map[string]interface{})
+	outputPath, _ := result["output_path"].(string)
+	if outputPath != filepath.Join(tmpDir, "test") {
+		t.Fatalf("output_path = %q, want the corpus directory", outputPath)
+	}
+
+	for _, name := range []string{"index.html", "Gen.1.html", "Gen.2.html"} {
+		if _, err := os.Stat(filepath.Join(outputPath, name)); err != nil {
+			t.Errorf("expected %s: %v", name, err)
+		}
+	}
+	page, err := os.ReadFile(filepath.Join(outputPath, "Gen.2.html"))
+	if err != nil {
+		t.Fatalf("failed to read chapter page: %v", err)
+	}
+	if !strings.Contains(string(page), "Thus the heavens.") || strings.Contains(string(page), "In the beginning.") {
+		t.Errorf("chapter page has the wrong verses:\n%s", page)
+	}
+}
+
 // TestHTMLRoundTrip tests L0 lossless round-trip via raw storage.
 func TestHTMLRoundTrip(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "html-test-*")
@@ -335,7 +335,7 @@ func handleEmitNative(args map[string]interface{}) {
 	}
 
 	// Convert IR to OSIS
-	osisData, err := emitOSISFromIR(&corpus)
+	osisData, err := emitOSISFromIR(&corpus, ipc.OptionString(args, "verse_style", verseMilestone))
 	if err != nil {
 		ipc.RespondErrorf("failed to emit OSIS: %v", err)
 		return
@@ -589,8 +589,14 @@ func isBookID(osisID string) bool {
 	return books.MatchString(osisID)
 }
 
+// Verse styles of emitted OSIS, chosen with the verse_style option.
+const (
+	verseMilestone = "milestone" // <verse sID="..."/>text<verse eID="..."/>
+	verseContainer = "container" // <verse osisID="...">text</verse>
+)
+
 // emitOSISFromIR converts IR Corpus back to OSIS XML
-func emitOSISFromIR(corpus *ipc.Corpus) ([]byte, error) {
+func emitOSISFromIR(corpus *ipc.Corpus, verseStyle string) ([]byte, error) {
 	// Check if we have the original raw XML for L0 lossless round-trip
 	if rawXML, ok := corpus.Attributes["_osis_raw"]; ok && rawXML != "" {
 		return []byte(rawXML), nil
@@ -654,7 +660,7 @@ func emitOSISFromIR(corpus *ipc.Corpus) ([]byte, error) {
 			}
 
 			// Write as paragraph with verse markers if present
-			buf.WriteString("      <p>")
+			var osisIDs []string
 			for _, anchor := range block.Anchors {
 				for _, span := range anchor.Spans {
 					if span.Type == "VERSE" && span.Ref != nil {
@@ -662,11 +668,12 @@ func emitOSISFromIR(corpus *ipc.Corpus) ([]byte, error) {
 						if osisID == "" {
 							osisID = fmt.Sprintf("%s.%d.%d", span.Ref.Book, span.Ref.Chapter, span.Ref.Verse)
 						}
-						buf.WriteString(fmt.Sprintf(`<verse osisID="%s"/>`, escapeXML(osisID)))
+						osisIDs = append(osisIDs, osisID)
 					}
 				}
 			}
-			buf.WriteString(escapeXML(block.Text))
+			buf.WriteString("      <p>")
+			writeVerseText(&buf, osisIDs, block.Text, verseStyle)
 			buf.WriteString("</p>\n")
 		}
 
@@ -679,6 +686,22 @@ func emitOSISFromIR(corpus *ipc.Corpus) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// writeVerseText writes text marked up as the verses osisIDs, either
+// between milestones or inside a verse container.
+func writeVerseText(buf *bytes.Buffer, osisIDs []string, text, verseStyle string) {
+	if len(osisIDs) == 0 {
+		buf.WriteString(escapeXML(text))
+		return
+	}
+	osisID := escapeXML(strings.Join(osisIDs, " "))
+	if verseStyle == verseContainer {
+		fmt.Fprintf(buf, `<verse osisID="%s">%s</verse>`, osisID, escapeXML(text))
+		return
+	}
+	sID := escapeXML(osisIDs[0])
+	fmt.Fprintf(buf, `<verse sID="%s" osisID="%s"/>%s<verse eID="%s"/>`, sID, osisID, escapeXML(text), sID)
+}
+
 // escapeXML escapes special characters for XML
 func escapeXML(s string) string {
 	s = strings.ReplaceAll(s, "&", "&amp;")
@@ -593,7 +593,7 @@ func TestEmitOSISFromIR(t *testing.T) {
 		},
 	}
 
-	osisData, err := emitOSISFromIR(corpus)
+	osisData, err := emitOSISFromIR(corpus, verseMilestone)
 	if err != nil {
 		t.Fatalf("emitOSISFromIR failed: %v", err)
 	}
@@ -611,8 +611,16 @@ func TestEmitOSISFromIR(t *testing.T) {
 	if !strings.Contains(osisStr, "In the beginning.") {
 		t.Error("output does not contain verse text")
 	}
-	if !strings.Contains(osisStr, `osisID="Gen.1.1"`) {
-		t.Error("output does not contain verse osisID")
+	if !strings.Contains(osisStr, `<verse sID="Gen.1.1" osisID="Gen.1.1"/>In the beginning.<verse eID="Gen.1.1"/>`) {
+		t.Error("output does not contain verse milestones")
+	}
+
+	osisData, err = emitOSISFromIR(corpus, verseContainer)
+	if err != nil {
+		t.Fatalf("emitOSISFromIR failed: %v", err)
+	}
+	if !strings.Contains(string(osisData), `<verse osisID="Gen.1.1">In the beginning.</verse>`) {
+		t.Error("output does not contain verse container")
 	}
 }
 
@@ -887,7 +895,7 @@ func TestEmitOSISFromIRWithPoetry(t *testing.T) {
 		},
 	}
 
-	osisData, err := emitOSISFromIR(corpus)
+	osisData, err := emitOSISFromIR(corpus, verseMilestone)
 	if err != nil {
 		t.Fatalf("emitOSISFromIR failed: %v", err)
 	}
@@ -405,7 +405,10 @@ func handleEmitNative(req *ipc.Request) {
 	}
 
 	// Use EmitZText for full binary generation
-	result, err := EmitZText(&corpus, outputDir)
+	result, err := EmitZTextWithOptions(&corpus, outputDir, ZTextOptions{
+		Driver:    ipc.OptionString(req.Args, "module_driver", ZTextDriver),
+		BlockSize: ipc.OptionInt(req.Args, "block_size", DefaultZTextBlockSize),
+	})
 	if err != nil {
 		sendError(fmt.Sprintf("failed to emit zText: %v", err))
 		return
@@ -180,7 +180,7 @@ func (w *ZComWriter) addEntryEntry(blockNum, offset uint32, size uint16) {
 	w.entryEntries = append(w.entryEntries, VerseEntry{
 		BlockNum: blockNum,
 		Offset:   offset,
-		Size:     size,
+		Size:     uint32(size),
 	})
 }
 
@@ -251,7 +251,7 @@ func (w *ZComWriter) writeFiles(prefix string) error {
 		offset := i * 10
 		binary.LittleEndian.PutUint32(bzvData[offset:], entry.BlockNum)
 		binary.LittleEndian.PutUint32(bzvData[offset+4:], entry.Offset)
-		binary.LittleEndian.PutUint16(bzvData[offset+8:], entry.Size)
+		binary.LittleEndian.PutUint16(bzvData[offset+8:], uint16(entry.Size))
 	}
 	if err := os.WriteFile(bzvPath, bzvData, 0644); err != nil {
 		return fmt.Errorf("failed to write bzv: %w", err)
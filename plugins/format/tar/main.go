@@ -61,9 +61,11 @@ func handleDetect(args map[string]interface{}) {
 		strings.HasSuffix(lower, ".txz") {
 
 		ipc.MustRespond(&ipc.DetectResult{
-			Detected: true,
-			Format:   "tar",
-			Reason:   "tar file extension detected",
+			Detected:    true,
+			Format:      "tar",
+			Reason:      "tar file extension detected",
+			Confidence:  ipc.ExtensionConfidence,
+			Specificity: ipc.SpecificityGeneric,
 		})
 		return
 	}
@@ -84,9 +86,10 @@ func handleDetect(args map[string]interface{}) {
 	_, err = tr.Next()
 	if err == nil {
 		ipc.MustRespond(&ipc.DetectResult{
-			Detected: true,
-			Format:   "tar",
-			Reason:   "valid tar header found",
+			Detected:    true,
+			Format:      "tar",
+			Reason:      "valid tar header found",
+			Specificity: ipc.SpecificityGeneric,
 		})
 		return
 	}
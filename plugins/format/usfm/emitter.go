@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/plugins/ipc"
+)
+
+// headerMarkers are the identification markers written after \id, in
+// order. Main titles follow, in their source order.
+var headerMarkers = []string{
+	"usfm", "ide", "sts", "rem", "h", "h1", "h2", "h3",
+	"toc1", "toc2", "toc3", "toca1", "toca2", "toca3",
+}
+
+// emitUSFMFromIR converts IR Corpus back to USFM text
+func emitUSFMFromIR(corpus *ipc.Corpus) ([]byte, error) {
+	var buf bytes.Buffer
+
+	for _, doc := range corpus.Documents {
+		w := &usfmWriter{buf: &buf, fromUSFM: fromUSFM(doc)}
+		w.header(doc)
+		w.body(doc)
+		w.line()
+	}
+
+	return buf.Bytes(), nil
+}
+
+// usfmWriter writes one book of USFM.
+type usfmWriter struct {
+	buf       *bytes.Buffer
+	fromUSFM  bool        // The IR was parsed from USFM and keeps its markers
+	needSpace bool        // A paragraph, chapter or verse marker still needs the space ending it
+	chars     []*ipc.Span // Open character styles, innermost last
+}
+
+// fromUSFM reports whether the spans of a document carry USFM markers.
+func fromUSFM(doc *ipc.Document) bool {
+	for _, b := range doc.ContentBlocks {
+		for _, a := range b.Anchors {
+			for _, s := range a.Spans {
+				if _, ok := s.Attributes["marker"]; ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// line ends the current line, dropping its trailing spaces.
+func (w *usfmWriter) line() {
+	b := bytes.TrimRight(w.buf.Bytes(), " ")
+	w.buf.Truncate(len(b))
+	if w.buf.Len() > 0 && b[len(b)-1] != '\n' {
+		w.buf.WriteByte('\n')
+	}
+	w.needSpace = false
+}
+
+// marker writes a paragraph, chapter or verse marker on a new line.
+func (w *usfmWriter) marker(m string) {
+	w.line()
+	w.buf.WriteString(`\` + m)
+	w.needSpace = true
+}
+
+func (w *usfmWriter) text(s string) {
+	if w.needSpace {
+		if s = strings.TrimLeft(s, " "); s == "" {
+			return
+		}
+		w.buf.WriteByte(' ')
+		w.needSpace = false
+	}
+	w.buf.WriteString(s)
+}
+
+// inline writes inline markup.
+func (w *usfmWriter) inline(s string) {
+	if w.needSpace {
+		w.buf.WriteByte(' ')
+		w.needSpace = false
+	}
+	w.buf.WriteString(s)
+}
+
+func (w *usfmWriter) header(doc *ipc.Document) {
+	w.buf.WriteString(`\id ` + doc.ID)
+	if id := doc.Attributes["id"]; id != "" {
+		w.buf.WriteString(" " + id)
+	}
+
+	for _, m := range headerMarkers {
+		value, ok := doc.Attributes[m]
+		if m == "h" && !ok && !w.fromUSFM && doc.Title != "" {
+			value, ok = doc.Title, true
+		}
+		if !ok {
+			continue
+		}
+		for _, v := range strings.Split(value, "\n") {
+			w.marker(m)
+			w.text(v)
+		}
+	}
+
+	// Main titles, whose repeated markers take their values in turn
+	var order []string
+	if o := doc.Attributes["title_order"]; o != "" {
+		order = strings.Fields(o)
+	} else {
+		for m := range doc.Attributes {
+			if baseMarker(m) == "mt" {
+				order = append(order, m)
+			}
+		}
+		sort.Strings(order)
+	}
+	values := map[string][]string{}
+	for _, m := range order {
+		if _, ok := values[m]; !ok {
+			values[m] = strings.Split(doc.Attributes[m], "\n")
+		}
+		if len(values[m]) == 0 {
+			continue
+		}
+		w.marker(m)
+		w.text(values[m][0])
+		values[m] = values[m][1:]
+	}
+}
+
+func (w *usfmWriter) body(doc *ipc.Document) {
+	// Spans by the anchor they end at
+	ends := map[string][]*ipc.Span{}
+	for _, b := range doc.ContentBlocks {
+		for _, a := range b.Anchors {
+			for _, s := range a.Spans {
+				if s.EndAnchorID != "" {
+					ends[s.EndAnchorID] = append(ends[s.EndAnchorID], s)
+				}
+			}
+		}
+	}
+
+	chapter := 0
+	for _, b := range doc.ContentBlocks {
+		w.line()
+		if !w.fromUSFM {
+			w.plainBlock(b, &chapter)
+			continue
+		}
+
+		anchors := append([]*ipc.Anchor(nil), b.Anchors...)
+		sort.SliceStable(anchors, func(i, j int) bool {
+			return anchors[i].CharOffset < anchors[j].CharOffset
+		})
+		var paraAttrs []*ipc.Span
+		pos := 0
+		for _, a := range anchors {
+			if off := min(a.CharOffset, len(b.Text)); off > pos {
+				w.text(b.Text[pos:off])
+				pos = off
+			}
+			for _, s := range ends[a.ID] {
+				w.end(s)
+			}
+			spans := append([]*ipc.Span(nil), a.Spans...)
+			sort.SliceStable(spans, func(i, j int) bool {
+				return spanRank(spans[i]) < spanRank(spans[j])
+			})
+			for _, s := range spans {
+				if w.start(s) {
+					paraAttrs = append(paraAttrs, s)
+				}
+			}
+		}
+		w.text(b.Text[pos:])
+		for _, s := range paraAttrs {
+			w.inline("|" + formatAttributes(s, "attributes"))
+		}
+	}
+}
+
+// plainBlock writes a block of IR from another format, which has verse
+// and chapter references but no USFM markers.
+func (w *usfmWriter) plainBlock(b *ipc.ContentBlock, chapter *int) {
+	var verse *ipc.Span
+	for _, a := range b.Anchors {
+		for _, s := range a.Spans {
+			if s.Type == spanVerse && s.Ref != nil && verse == nil {
+				verse = s
+			}
+		}
+	}
+
+	if verse != nil {
+		if ch := verse.Ref.Chapter; ch > 0 && ch != *chapter {
+			*chapter = ch
+			w.marker(fmt.Sprintf("c %d", ch))
+			w.marker("p")
+		}
+		w.marker("v " + verseNumber(verse))
+	} else {
+		m, _ := b.Attributes["marker"].(string)
+		if m == "" {
+			m = "p"
+		}
+		w.marker(m)
+	}
+	w.text(b.Text)
+}
+
+// spanRank orders the spans starting at one anchor: the chapter, then
+// paragraphs, then the verse, then inline spans.
+func spanRank(s *ipc.Span) int {
+	switch s.Type {
+	case spanChapter:
+		return 0
+	case spanVerse:
+		return 2
+	}
+	if _, ok := s.Attributes["milestone"]; !ok && isParagraphMarker(spanMarker(s)) {
+		return 1
+	}
+	return 3
+}
+
+func spanMarker(s *ipc.Span) string {
+	m, _ := s.Attributes["marker"].(string)
+	return m
+}
+
+func isParagraphMarker(m string) bool {
+	if m == "" {
+		return false
+	}
+	switch lookupMarker(m).kind {
+	case kindHeader, kindBlock, kindFlow:
+		return true
+	}
+	return false
+}
+
+// start writes the opening of a span. It reports whether the span is a
+// paragraph with attributes, which are written at the end of its block.
+func (w *usfmWriter) start(s *ipc.Span) bool {
+	m := spanMarker(s)
+	switch {
+	case s.Type == spanChapter:
+		num, _ := s.Attributes["number"].(string)
+		if num == "" && s.Ref != nil {
+			num = fmt.Sprint(s.Ref.Chapter)
+		}
+		w.marker("c " + num)
+	case s.Type == spanVerse:
+		w.marker("v " + verseNumber(s))
+	case m == "":
+	case s.Attributes["milestone"] != nil:
+		suffix := ""
+		switch s.Attributes["milestone"] {
+		case "start":
+			suffix = "-s"
+		case "end":
+			suffix = "-e"
+		}
+		w.inline(`\` + m + suffix + attributeSuffix(s, "attributes") + `\*`)
+	case s.Type == spanNote || s.Type == spanCrossRef:
+		w.note(s, m)
+	case s.Type == spanTableCell:
+		w.inline(`\` + m + " ")
+	case isParagraphMarker(m):
+		w.marker(m)
+		_, ok := s.Attributes["attributes"]
+		return ok
+	default:
+		plus := ""
+		if len(w.chars) > 0 {
+			plus = "+"
+		}
+		w.inline(`\` + plus + m + " ")
+		w.chars = append(w.chars, s)
+	}
+	return false
+}
+
+// end writes the closing of a span.
+func (w *usfmWriter) end(s *ipc.Span) {
+	m := spanMarker(s)
+	if s.Attributes["milestone"] == "start" {
+		w.inline(`\` + m + "-e" + attributeSuffix(s, "end_attributes") + `\*`)
+		return
+	}
+
+	i := len(w.chars) - 1
+	for i >= 0 && w.chars[i] != s {
+		i--
+	}
+	if i < 0 {
+		return
+	}
+	w.chars = w.chars[:i]
+	if s.Attributes["unclosed"] == true {
+		return
+	}
+	plus := ""
+	if i > 0 {
+		plus = "+"
+	}
+	if _, ok := s.Attributes["attributes"]; ok {
+		w.inline("|" + formatAttributes(s, "attributes"))
+	}
+	w.inline(`\` + plus + m + "*")
+}
+
+func (w *usfmWriter) note(s *ipc.Span, m string) {
+	var sb strings.Builder
+	sb.WriteString(`\` + m)
+	if caller, _ := s.Attributes["caller"].(string); caller != "" {
+		sb.WriteString(" " + caller)
+	}
+	content, _ := s.Attributes["content"].([]interface{})
+	for i, c := range content {
+		part, _ := c.(map[string]interface{})
+		pm, _ := part["marker"].(string)
+		text, _ := part["text"].(string)
+		// Text after a closed part continues it without a separator
+		if (pm != "" || i == 0) && !strings.HasSuffix(sb.String(), " ") {
+			sb.WriteByte(' ')
+		}
+		if pm != "" {
+			sb.WriteString(`\` + pm + " ")
+		}
+		sb.WriteString(text)
+		if pm != "" && part["closed"] == true {
+			sb.WriteString(`\` + pm + "*")
+		}
+	}
+	if s.Attributes["unclosed"] != true {
+		sb.WriteString(`\` + m + "*")
+	}
+	w.inline(sb.String())
+}
+
+// verseNumber returns the number written after \v.
+func verseNumber(s *ipc.Span) string {
+	if num, _ := s.Attributes["number"].(string); num != "" {
+		return num
+	}
+	if s.Ref == nil {
+		return ""
+	}
+	num := fmt.Sprint(s.Ref.Verse)
+	if s.Ref.VerseEnd > s.Ref.Verse {
+		num += fmt.Sprintf("-%d", s.Ref.VerseEnd)
+	}
+	return num + s.Ref.SubVerse
+}
+
+// attributeSuffix returns the |attributes of a milestone, with the space
+// that separates them from the marker.
+func attributeSuffix(s *ipc.Span, key string) string {
+	if _, ok := s.Attributes[key]; !ok {
+		return ""
+	}
+	return " |" + formatAttributes(s, key)
+}
+
+// formatAttributes writes span attributes back as USFM attribute source.
+func formatAttributes(s *ipc.Span, key string) string {
+	attrs, _ := s.Attributes[key].(map[string]interface{})
+	if s.Attributes["default_attribute"] == true && len(attrs) == 1 {
+		for _, v := range attrs {
+			return fmt.Sprint(v)
+		}
+	}
+
+	var names []string
+	seen := map[string]bool{}
+	order, _ := s.Attributes[key+"_order"].([]interface{})
+	for _, o := range order {
+		if name, ok := o.(string); ok && !seen[name] {
+			if _, ok := attrs[name]; ok {
+				names = append(names, name)
+				seen[name] = true
+			}
+		}
+	}
+	var rest []string
+	for name := range attrs {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	names = append(names, rest...)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf(`%s="%v"`, name, attrs[name])
+	}
+	return strings.Join(parts, " ")
+}
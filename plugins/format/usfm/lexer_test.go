@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []token
+	}{
+		{
+			name: "marker consumes one space",
+			src:  `\v 1  In`,
+			want: []token{
+				{kind: tokMarker, marker: "v", raw: `\v `},
+				{kind: tokText, text: "1  In", raw: "1  In"},
+			},
+		},
+		{
+			name: "CRLF after marker",
+			src:  "\\p\r\ntext",
+			want: []token{
+				{kind: tokMarker, marker: "p", raw: "\\p\r\n"},
+				{kind: tokText, text: "text", raw: "text"},
+			},
+		},
+		{
+			name: "nested character style",
+			src:  `\+nd Lord\+nd*`,
+			want: []token{
+				{kind: tokMarker, marker: "nd", nested: true, raw: `\+nd `},
+				{kind: tokText, text: "Lord", raw: "Lord"},
+				{kind: tokEndMarker, marker: "nd", nested: true, raw: `\+nd*`},
+			},
+		},
+		{
+			name: "word attributes",
+			src:  `\w grace|strong="H2580"\w*`,
+			want: []token{
+				{kind: tokMarker, marker: "w", raw: `\w `},
+				{kind: tokText, text: "grace", raw: "grace"},
+				{kind: tokAttributes, text: `strong="H2580"`, raw: `|strong="H2580"`},
+				{kind: tokEndMarker, marker: "w", raw: `\w*`},
+			},
+		},
+		{
+			name: "milestone",
+			src:  `\qt-s |who="Pilate"\*`,
+			want: []token{
+				{kind: tokMarker, marker: "qt-s", raw: `\qt-s `},
+				{kind: tokAttributes, text: `who="Pilate"`, raw: `|who="Pilate"`},
+				{kind: tokMilestoneEnd, raw: `\*`},
+			},
+		},
+		{
+			name: "lone backslash is text",
+			src:  `a \ b`,
+			want: []token{
+				{kind: tokText, text: `a \ b`, raw: `a \ b`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tokenize(tt.src)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize(%q) =\n%+v\nwant\n%+v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupMarker(t *testing.T) {
+	tests := []struct {
+		marker string
+		kind   markerKind
+	}{
+		{"q2", kindFlow},
+		{"s1", kindBlock},
+		{"mt1", kindHeader},
+		{"toc3", kindHeader},
+		{"th1-2", kindCell},
+		{"fqa", kindNoteChar},
+		{"x", kindNote},
+		{"zcustom", kindChar},
+	}
+	for _, tt := range tests {
+		if got := lookupMarker(tt.marker).kind; got != tt.kind {
+			t.Errorf("lookupMarker(%q).kind = %d, want %d", tt.marker, got, tt.kind)
+		}
+	}
+
+	if name, end, ok := milestoneMarker("qt1-s"); !ok || name != "qt1" || end != 's' {
+		t.Errorf("milestoneMarker(qt1-s) = %q, %c, %v", name, end, ok)
+	}
+	if _, _, ok := milestoneMarker("th1-2"); ok {
+		t.Error("th1-2 should not be a milestone")
+	}
+}
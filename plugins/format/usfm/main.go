@@ -1,10 +1,9 @@
 // Plugin format-usfm handles USFM (Unified Standard Format Markers) Bible files.
-// It supports L0 lossless round-trip conversion through IR.
+// It parses USFM 3 into IR spans, annotations and tokens and writes it back
+// with only whitespace changes (L1), like the embedded handler.
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -14,7 +13,6 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -23,6 +21,8 @@ var (
 	markerRegex   = regexp.MustCompile(`\\([a-zA-Z0-9]+)\*?(?:\s|$)`)
 	verseNumRegex = regexp.MustCompile(`^(\d+)(?:-(\d+))?`)
 	chapterRegex  = regexp.MustCompile(`^(\d+)`)
+	subVerseRegex = regexp.MustCompile(`^\d+([a-z])`)
+	attrRegex     = regexp.MustCompile(`([A-Za-z0-9_-]+)\s*=\s*"([^"]*)"`)
 )
 
 // Common USFM book IDs
@@ -122,9 +122,10 @@ func handleDetect(args map[string]interface{}) {
 	ext := strings.ToLower(filepath.Ext(path))
 	if ext == ".usfm" || ext == ".sfm" || ext == ".ptx" {
 		ipc.MustRespond(&ipc.DetectResult{
-			Detected: true,
-			Format:   "USFM",
-			Reason:   "USFM file extension detected",
+			Detected:   true,
+			Format:     "USFM",
+			Reason:     "USFM file extension detected",
+			Confidence: ipc.ExtensionConfidence,
 		})
 		return
 	}
@@ -256,11 +257,11 @@ func handleExtractIR(args map[string]interface{}) {
 	}
 	ipc.MustRespond(&ipc.ExtractIRResult{
 		IRPath:    irPath,
-		LossClass: "L0",
+		LossClass: corpus.LossClass,
 		LossReport: &ipc.LossReport{
 			SourceFormat: "USFM",
 			TargetFormat: "IR",
-			LossClass:    "L0",
+			LossClass:    corpus.LossClass,
 		},
 	})
 }
@@ -308,268 +309,14 @@ func handleEmitNative(args map[string]interface{}) {
 	ipc.MustRespond(&ipc.EmitNativeResult{
 		OutputPath: outputPath,
 		Format:     "USFM",
-		LossClass:  "L0",
+		LossClass:  corpus.LossClass,
 		LossReport: &ipc.LossReport{
 			SourceFormat: "IR",
 			TargetFormat: "USFM",
-			LossClass:    "L0",
+			LossClass:    corpus.LossClass,
 		},
 	})
 }
 
-// parseUSFMToIR converts USFM text to IR Corpus
-func parseUSFMToIR(data []byte) (*ipc.Corpus, error) {
-	content := string(data)
-
-	corpus := &ipc.Corpus{
-		Version:      "1.0.0",
-		ModuleType:   "BIBLE",
-		SourceFormat: "USFM",
-		LossClass:    "L0",
-		Attributes:   make(map[string]string),
-	}
-
-	// Store raw content for L0 round-trip
-	corpus.Attributes["_usfm_raw"] = content
-
-	var currentDoc *ipc.Document
-	var currentChapter int
-	var blockSeq int
-
-	scanner := bufio.NewScanner(bytes.NewReader(data))
-	for scanner.Scan() {
-		line := scanner.Text()
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" {
-			continue
-		}
-
-		// Parse markers
-		if strings.HasPrefix(trimmed, "\\") {
-			parts := strings.SplitN(trimmed, " ", 2)
-			marker := strings.TrimPrefix(parts[0], "\\")
-			var value string
-			if len(parts) > 1 {
-				value = parts[1]
-			}
-
-			switch marker {
-			case "id":
-				// Book ID
-				idParts := strings.Fields(value)
-				if len(idParts) > 0 {
-					bookID := strings.ToUpper(idParts[0])
-					corpus.ID = bookID
-					currentDoc = &ipc.Document{
-						ID:         bookID,
-						Order:      len(corpus.Documents) + 1,
-						Attributes: make(map[string]string),
-					}
-					if name, ok := bookNames[bookID]; ok {
-						currentDoc.Title = name
-					}
-					corpus.Documents = append(corpus.Documents, currentDoc)
-				}
-
-			case "h", "toc1", "toc2", "toc3":
-				// Header/TOC entries
-				if currentDoc != nil && value != "" {
-					if marker == "h" && currentDoc.Title == "" {
-						currentDoc.Title = value
-					}
-					currentDoc.Attributes[marker] = value
-				}
-
-			case "mt", "mt1", "mt2", "mt3":
-				// Main title
-				if corpus.Title == "" && value != "" {
-					corpus.Title = value
-				}
-				if currentDoc != nil {
-					currentDoc.Attributes[marker] = value
-				}
-
-			case "c":
-				// Chapter
-				if matches := chapterRegex.FindStringSubmatch(value); len(matches) > 0 {
-					currentChapter, _ = strconv.Atoi(matches[1])
-				}
-
-			case "v":
-				// Verse
-				if currentDoc != nil {
-					verseText := value
-					verseNum := 0
-					verseEnd := 0
-
-					// Parse verse number
-					if matches := verseNumRegex.FindStringSubmatch(value); len(matches) > 0 {
-						verseNum, _ = strconv.Atoi(matches[1])
-						if matches[2] != "" {
-							verseEnd, _ = strconv.Atoi(matches[2])
-						}
-						// Extract text after verse number
-						verseText = strings.TrimSpace(value[len(matches[0]):])
-					}
-
-					if verseText != "" {
-						blockSeq++
-						osisID := fmt.Sprintf("%s.%d.%d", corpus.ID, currentChapter, verseNum)
-
-						block := &ipc.ContentBlock{
-							ID:       fmt.Sprintf("cb-%d", blockSeq),
-							Sequence: blockSeq,
-							Text:     verseText,
-							Anchors: []*ipc.Anchor{
-								{
-									ID:       fmt.Sprintf("a-%d-0", blockSeq),
-									Position: 0,
-									Spans: []*ipc.Span{
-										{
-											ID:            fmt.Sprintf("s-%s", osisID),
-											Type:          "VERSE",
-											StartAnchorID: fmt.Sprintf("a-%d-0", blockSeq),
-											Ref: &ipc.Ref{
-												Book:     corpus.ID,
-												Chapter:  currentChapter,
-												Verse:    verseNum,
-												VerseEnd: verseEnd,
-												OSISID:   osisID,
-											},
-										},
-									},
-								},
-							},
-						}
-
-						// Compute hash
-						h := sha256.Sum256([]byte(verseText))
-						block.Hash = hex.EncodeToString(h[:])
-
-						currentDoc.ContentBlocks = append(currentDoc.ContentBlocks, block)
-					}
-				}
-
-			case "p", "m", "pi", "mi", "nb":
-				// Paragraph markers - may contain text
-				if currentDoc != nil && value != "" {
-					blockSeq++
-					block := &ipc.ContentBlock{
-						ID:       fmt.Sprintf("cb-%d", blockSeq),
-						Sequence: blockSeq,
-						Text:     value,
-						Attributes: map[string]interface{}{
-							"type":   "paragraph",
-							"marker": marker,
-						},
-					}
-					h := sha256.Sum256([]byte(value))
-					block.Hash = hex.EncodeToString(h[:])
-					currentDoc.ContentBlocks = append(currentDoc.ContentBlocks, block)
-				}
-
-			case "q", "q1", "q2", "q3", "qr", "qc", "qm":
-				// Poetry markers
-				if currentDoc != nil && value != "" {
-					blockSeq++
-					block := &ipc.ContentBlock{
-						ID:       fmt.Sprintf("cb-%d", blockSeq),
-						Sequence: blockSeq,
-						Text:     value,
-						Attributes: map[string]interface{}{
-							"type":   "poetry",
-							"marker": marker,
-						},
-					}
-					h := sha256.Sum256([]byte(value))
-					block.Hash = hex.EncodeToString(h[:])
-					currentDoc.ContentBlocks = append(currentDoc.ContentBlocks, block)
-				}
-			}
-		}
-	}
-
-	// Compute source hash
-	h := sha256.Sum256(data)
-	corpus.SourceHash = hex.EncodeToString(h[:])
-
-	return corpus, nil
-}
-
-// emitUSFMFromIR converts IR Corpus back to USFM text
-func emitUSFMFromIR(corpus *ipc.Corpus) ([]byte, error) {
-	// Check if we have the original raw USFM for L0 lossless round-trip
-	if rawUSFM, ok := corpus.Attributes["_usfm_raw"]; ok && rawUSFM != "" {
-		return []byte(rawUSFM), nil
-	}
-
-	// Otherwise, reconstruct USFM from IR structure
-	var buf bytes.Buffer
-
-	for _, doc := range corpus.Documents {
-		// Write book ID
-		buf.WriteString(fmt.Sprintf("\\id %s\n", doc.ID))
-
-		// Write header
-		if doc.Title != "" {
-			buf.WriteString(fmt.Sprintf("\\h %s\n", doc.Title))
-		}
-
-		// Write attributes (toc entries, mt, etc.)
-		if doc.Attributes != nil {
-			for key, val := range doc.Attributes {
-				if key != "h" && !strings.HasPrefix(key, "_") {
-					buf.WriteString(fmt.Sprintf("\\%s %s\n", key, val))
-				}
-			}
-		}
-
-		currentChapter := 0
-
-		for _, block := range doc.ContentBlocks {
-			// Check for verse spans to determine chapter/verse
-			for _, anchor := range block.Anchors {
-				for _, span := range anchor.Spans {
-					if span.Type == "VERSE" && span.Ref != nil {
-						// Write chapter marker if changed
-						if span.Ref.Chapter != currentChapter {
-							currentChapter = span.Ref.Chapter
-							buf.WriteString(fmt.Sprintf("\\c %d\n", currentChapter))
-						}
-
-						// Write verse
-						if span.Ref.VerseEnd > 0 && span.Ref.VerseEnd != span.Ref.Verse {
-							buf.WriteString(fmt.Sprintf("\\v %d-%d %s\n", span.Ref.Verse, span.Ref.VerseEnd, block.Text))
-						} else {
-							buf.WriteString(fmt.Sprintf("\\v %d %s\n", span.Ref.Verse, block.Text))
-						}
-						break
-					}
-				}
-			}
-
-			// Handle non-verse blocks (paragraphs, poetry)
-			if len(block.Anchors) == 0 && block.Text != "" {
-				if block.Attributes != nil {
-					if marker, ok := block.Attributes["marker"].(string); ok {
-						buf.WriteString(fmt.Sprintf("\\%s %s\n", marker, block.Text))
-					} else if blockType, ok := block.Attributes["type"].(string); ok {
-						switch blockType {
-						case "poetry":
-							buf.WriteString(fmt.Sprintf("\\q %s\n", block.Text))
-						case "paragraph":
-							buf.WriteString(fmt.Sprintf("\\p %s\n", block.Text))
-						default:
-							buf.WriteString(fmt.Sprintf("\\p %s\n", block.Text))
-						}
-					}
-				}
-			}
-		}
-	}
-
-	return buf.Bytes(), nil
-}
-
 // Compile check
 var _ = io.Copy
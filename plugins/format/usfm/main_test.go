@@ -133,8 +133,8 @@ func TestUSFMExtractIR(t *testing.T) {
 		t.Fatal("result is not a map")
 	}
 
-	if result["loss_class"] != "L0" {
-		t.Errorf("expected loss_class L0, got %v", result["loss_class"])
+	if result["loss_class"] != "L1" {
+		t.Errorf("expected loss_class L1, got %v", result["loss_class"])
 	}
 
 	irPath, ok := result["ir_path"].(string)
@@ -269,7 +269,8 @@ func TestUSFMEmitNative(t *testing.T) {
 	}
 }
 
-// TestUSFMRoundTrip tests L0 lossless round-trip.
+// TestUSFMRoundTrip tests that a round trip through IR keeps a file
+// without extra whitespace unchanged.
 func TestUSFMRoundTrip(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "usfm-test-*")
 	if err != nil {
@@ -347,7 +348,7 @@ func TestUSFMRoundTrip(t *testing.T) {
 	outputHash := sha256.Sum256(outputData)
 
 	if originalHash != outputHash {
-		t.Errorf("L0 round-trip failed: hashes differ\noriginal: %s\noutput:   %s",
+		t.Errorf("round-trip failed: hashes differ\noriginal: %s\noutput:   %s",
 			hex.EncodeToString(originalHash[:]),
 			hex.EncodeToString(outputHash[:]))
 	}
@@ -481,8 +482,8 @@ func TestParseUSFMToIR(t *testing.T) {
 	if corpus.SourceFormat != "USFM" {
 		t.Errorf("expected source format USFM, got %s", corpus.SourceFormat)
 	}
-	if corpus.LossClass != "L0" {
-		t.Errorf("expected loss class L0, got %s", corpus.LossClass)
+	if corpus.LossClass != "L1" {
+		t.Errorf("expected loss class L1, got %s", corpus.LossClass)
 	}
 
 	// Check documents
@@ -499,9 +500,23 @@ func TestParseUSFMToIR(t *testing.T) {
 		t.Fatalf("expected at least 2 content blocks, got %d", len(doc.ContentBlocks))
 	}
 
-	// Check that raw USFM is stored for L0 round-trip
-	if _, ok := corpus.Attributes["_usfm_raw"]; !ok {
-		t.Error("expected _usfm_raw attribute for L0 round-trip")
+	// The raw USFM is not kept; the emitter works from the IR alone
+	if corpus.LossClass != "L1" || corpus.Attributes["_usfm_raw"] != "" {
+		t.Errorf("expected an L1 corpus without raw USFM, got %s %v", corpus.LossClass, corpus.Attributes)
+	}
+}
+
+// TestParseUSFMToIRNoBookID tests that input without \id still gets a corpus ID.
+func TestParseUSFMToIRNoBookID(t *testing.T) {
+	corpus, err := parseUSFMToIR([]byte("\\p Just a paragraph.\n"))
+	if err != nil {
+		t.Fatalf("parseUSFMToIR failed: %v", err)
+	}
+	if corpus.ID != "usfm" {
+		t.Errorf("expected fallback ID usfm, got %q", corpus.ID)
+	}
+	if corpus.LossClass != "L1" {
+		t.Errorf("expected loss class L1, got %s", corpus.LossClass)
 	}
 }
 
@@ -839,3 +854,65 @@ func TestUSFMEmitWithAttributes(t *testing.T) {
 		t.Error("output should contain toc2 marker from attributes")
 	}
 }
+
+// TestUSFM3StructuredRoundTrip tests that USFM 3 markup survives the IR
+// without the raw source.
+func TestUSFM3StructuredRoundTrip(t *testing.T) {
+	src := `\id MAT
+\h Matthew
+\mt1 Matthew
+\c 1
+\s1 The Ancestors of Jesus Christ
+\p
+\v 1 The list,\f + \fr 1.1: \ft Or \fq a descendant\fq* of David.\f* of David.
+\v 2 From \w Abraham|lemma="avraham" strong="H85"\w* the \nd Lord\nd* said \wj Let \+nd there\+nd* be\wj* light.\x - \xo 1.2: \xt Gen 21.3\x*
+\q1
+\v 3 \qt-s |sid="qt1" who="Pilate"\*Are you the king?\qt-e |eid="qt1"\*
+\tr \th1 Tribe \thr2 Number
+\periph Title Page|id="title"
+`
+	corpus, err := parseUSFMToIR([]byte(src))
+	if err != nil {
+		t.Fatalf("parseUSFMToIR failed: %v", err)
+	}
+
+	data, err := json.Marshal(corpus)
+	if err != nil {
+		t.Fatalf("marshal IR: %v", err)
+	}
+	var decoded ipc.Corpus
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal IR: %v", err)
+	}
+
+	out, err := emitUSFMFromIR(&decoded)
+	if err != nil {
+		t.Fatalf("emitUSFMFromIR failed: %v", err)
+	}
+	if string(out) != src {
+		t.Errorf("round trip changed the file:\n%s", out)
+	}
+
+	doc := decoded.Documents[0]
+	types := map[string]bool{}
+	for _, a := range doc.Annotations {
+		types[a.Type] = true
+	}
+	for _, want := range []string{"FOOTNOTE", "CROSS_REF", "STRONGS"} {
+		if !types[want] {
+			t.Errorf("missing %s annotation", want)
+		}
+	}
+
+	found := false
+	for _, cb := range doc.ContentBlocks {
+		for _, tok := range cb.Tokens {
+			if tok.Text == "Abraham" && tok.Lemma == "avraham" && len(tok.Strongs) == 1 && tok.Strongs[0] == "H85" {
+				found = true
+			}
+		}
+	}
+	if !found {
+		t.Error("expected Abraham token with lemma and Strong's number")
+	}
+}
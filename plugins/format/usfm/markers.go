@@ -0,0 +1,241 @@
+package main
+
+import (
+	"strings"
+)
+
+// IR span types, as defined by core/ir.
+const (
+	spanChapter    = "CHAPTER"
+	spanVerse      = "VERSE"
+	spanParagraph  = "PARAGRAPH"
+	spanPoetryLine = "POETRY_LINE"
+	spanSection    = "SECTION"
+	spanTitle      = "TITLE"
+	spanNote       = "NOTE"
+	spanCrossRef   = "CROSS_REF"
+	spanQuotation  = "QUOTATION"
+	spanRedLetter  = "RED_LETTER"
+	spanDivine     = "DIVINE_NAME"
+	spanSelah      = "SELAH"
+	spanEmphasis   = "EMPHASIS"
+	spanForeign    = "FOREIGN"
+	spanWord       = "WORD"
+	spanCharStyle  = "CHAR_STYLE"
+	spanMilestone  = "MILESTONE"
+	spanTableCell  = "TABLE_CELL"
+)
+
+// IR annotation types, as defined by core/ir.
+const (
+	annotationStrongs    = "STRONGS"
+	annotationMorphology = "MORPHOLOGY"
+	annotationFootnote   = "FOOTNOTE"
+	annotationCrossRef   = "CROSS_REF"
+	annotationGloss      = "GLOSS"
+)
+
+// markerKind is how the parser treats a marker.
+type markerKind int
+
+const (
+	kindChar     markerKind = iota // Character style closed with \xx*; also unknown markers
+	kindHeader                     // Identification line or main title, kept as a document attribute
+	kindBlock                      // Paragraph starting its own block: headings, introductions, table rows
+	kindFlow                       // Body paragraph or poetry line, which verses flow through
+	kindChapter                    // \c
+	kindVerse                      // \v
+	kindNote                       // Footnote, endnote or cross-reference
+	kindNoteChar                   // Marker inside a note: \fr, \ft, \xo, \xt
+	kindCell                       // Table cell
+)
+
+// markerInfo describes how a marker maps to the IR.
+type markerInfo struct {
+	kind      markerKind
+	span      string
+	blockType string // Type attribute of the block a paragraph marker starts
+}
+
+// Block types recorded in the "type" attribute of content blocks.
+const (
+	blockVerse     = "verse"
+	blockParagraph = "paragraph"
+	blockPoetry    = "poetry"
+	blockHeading   = "heading"
+	blockTitle     = "title"
+	blockIntro     = "intro"
+	blockTable     = "table"
+	blockPeriph    = "periph"
+	blockRemark    = "remark"
+)
+
+// markers maps USFM 3 marker names, without their number, to the IR.
+var markers = map[string]markerInfo{
+	// Identification and main titles
+	"usfm": {kind: kindHeader}, "ide": {kind: kindHeader}, "sts": {kind: kindHeader},
+	"rem": {kind: kindHeader}, "h": {kind: kindHeader}, "toc": {kind: kindHeader},
+	"toca": {kind: kindHeader}, "mt": {kind: kindHeader},
+
+	// Chapters and verses
+	"c": {kind: kindChapter, span: spanChapter},
+	"v": {kind: kindVerse, span: spanVerse},
+
+	// Titles, headings and labels
+	"mte":    {kind: kindBlock, span: spanTitle, blockType: blockTitle},
+	"ms":     {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"mr":     {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"s":      {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"sr":     {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"r":      {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"d":      {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"sp":     {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"sd":     {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"qa":     {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"cl":     {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"cd":     {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"cp":     {kind: kindBlock, span: spanSection, blockType: blockHeading},
+	"periph": {kind: kindBlock, span: spanSection, blockType: blockPeriph},
+
+	// Introductions
+	"imt":  {kind: kindBlock, span: spanTitle, blockType: blockIntro},
+	"imte": {kind: kindBlock, span: spanTitle, blockType: blockIntro},
+	"is":   {kind: kindBlock, span: spanSection, blockType: blockIntro},
+	"iot":  {kind: kindBlock, span: spanSection, blockType: blockIntro},
+	"ip":   {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"ipi":  {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"im":   {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"imi":  {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"ipq":  {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"imq":  {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"ipr":  {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"ipc":  {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"ib":   {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"ili":  {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"io":   {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"iex":  {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"ie":   {kind: kindBlock, span: spanParagraph, blockType: blockIntro},
+	"iq":   {kind: kindBlock, span: spanPoetryLine, blockType: blockIntro},
+
+	// Tables
+	"tr":  {kind: kindBlock, span: spanParagraph, blockType: blockTable},
+	"th":  {kind: kindCell, span: spanTableCell},
+	"thr": {kind: kindCell, span: spanTableCell},
+	"thc": {kind: kindCell, span: spanTableCell},
+	"tc":  {kind: kindCell, span: spanTableCell},
+	"tcr": {kind: kindCell, span: spanTableCell},
+	"tcc": {kind: kindCell, span: spanTableCell},
+
+	// Body paragraphs and lists
+	"p":   {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"m":   {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"po":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"pr":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"cls": {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"pmo": {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"pm":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"pmc": {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"pmr": {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"pi":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"mi":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"nb":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"pc":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"ph":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"lh":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"li":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"lf":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"lim": {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"lit": {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"b":   {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+	"pb":  {kind: kindFlow, span: spanParagraph, blockType: blockParagraph},
+
+	// Poetry
+	"q":  {kind: kindFlow, span: spanPoetryLine, blockType: blockPoetry},
+	"qr": {kind: kindFlow, span: spanPoetryLine, blockType: blockPoetry},
+	"qc": {kind: kindFlow, span: spanPoetryLine, blockType: blockPoetry},
+	"qm": {kind: kindFlow, span: spanPoetryLine, blockType: blockPoetry},
+	"qd": {kind: kindFlow, span: spanPoetryLine, blockType: blockPoetry},
+
+	// Notes
+	"f":  {kind: kindNote, span: spanNote},
+	"fe": {kind: kindNote, span: spanNote},
+	"ef": {kind: kindNote, span: spanNote},
+	"x":  {kind: kindNote, span: spanCrossRef},
+	"ex": {kind: kindNote, span: spanCrossRef},
+
+	// Character styles with a span type of their own; the others are CHAR_STYLE
+	"w":    {kind: kindChar, span: spanWord},
+	"nd":   {kind: kindChar, span: spanDivine},
+	"wj":   {kind: kindChar, span: spanRedLetter},
+	"em":   {kind: kindChar, span: spanEmphasis},
+	"bd":   {kind: kindChar, span: spanEmphasis},
+	"it":   {kind: kindChar, span: spanEmphasis},
+	"bdit": {kind: kindChar, span: spanEmphasis},
+	"tl":   {kind: kindChar, span: spanForeign},
+	"qs":   {kind: kindChar, span: spanSelah},
+	"qt":   {kind: kindChar, span: spanQuotation},
+}
+
+// noteMarkers are the markers structuring the content of a note.
+var noteMarkers = map[string]bool{
+	"fr": true, "ft": true, "fk": true, "fq": true, "fqa": true, "fl": true,
+	"fw": true, "fp": true, "fv": true, "fdc": true, "fm": true,
+	"xo": true, "xk": true, "xq": true, "xt": true, "xta": true, "xop": true,
+	"xot": true, "xnt": true, "xdc": true,
+}
+
+// defaultAttributes names the attribute given without a name, as in
+// \w grace|grace\w*.
+var defaultAttributes = map[string]string{
+	"w":   "lemma",
+	"rb":  "gloss",
+	"xt":  "link-href",
+	"jmp": "link-href",
+	"ref": "loc",
+}
+
+// baseMarker strips the number of a numbered marker: q2 and th1-2 are q
+// and th.
+func baseMarker(name string) string {
+	if i := strings.LastIndexByte(name, '-'); i > 0 && isDigits(name[i+1:]) {
+		name = name[:i]
+	}
+	return strings.TrimRight(name, "0123456789")
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupMarker returns how a marker maps to the IR. Unknown markers, such
+// as the z markers of a project stylesheet, are character styles.
+func lookupMarker(name string) markerInfo {
+	base := baseMarker(name)
+	if info, ok := markers[base]; ok {
+		return info
+	}
+	if noteMarkers[base] {
+		return markerInfo{kind: kindNoteChar, span: spanCharStyle}
+	}
+	return markerInfo{kind: kindChar, span: spanCharStyle}
+}
+
+// milestoneMarker splits a milestone marker such as qt1-s into its name
+// and end: "qt1" and 's'. It returns false for other markers.
+func milestoneMarker(name string) (string, byte, bool) {
+	if len(name) > 2 && name[len(name)-2] == '-' {
+		if end := name[len(name)-1]; end == 's' || end == 'e' {
+			return name[:len(name)-2], end, true
+		}
+	}
+	return "", 0, false
+}
@@ -0,0 +1,819 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/FocuswithJustin/JuniperBible/plugins/ipc"
+)
+
+// parseUSFMToIR converts USFM text to IR Corpus
+func parseUSFMToIR(data []byte) (*ipc.Corpus, error) {
+	p := &parser{
+		corpus: &ipc.Corpus{
+			Version:      "1.0.0",
+			ModuleType:   "BIBLE",
+			SourceFormat: "USFM",
+			LossClass:    "L1",
+		},
+		toks: tokenize(string(data)),
+	}
+	p.parse()
+
+	// A file without a book code still needs a corpus ID
+	if p.corpus.ID == "" {
+		p.corpus.ID = "usfm"
+	}
+
+	// Compute source hash
+	h := sha256.Sum256(data)
+	p.corpus.SourceHash = hex.EncodeToString(h[:])
+
+	return p.corpus, nil
+}
+
+// parser builds the IR of a USFM file.
+//
+// Each verse is a content block; headings, introduction paragraphs and table
+// rows are blocks of their own. Paragraph, poetry, chapter and verse markers
+// become spans anchored where they occur, so a verse flowing over several
+// poetry lines stays one block. Character styles, notes and milestones are
+// spans carrying their marker and attributes, which is what the emitter
+// needs to write the file back.
+type parser struct {
+	corpus *ipc.Corpus
+	doc    *ipc.Document
+	block  *ipc.ContentBlock
+	toks   []token
+	pos    int
+
+	blockSeq int
+	spanSeq  int
+	annSeq   int
+	chapter  int
+	titles   []string // Order of the main title markers
+
+	inBody      bool        // A chapter, verse or paragraph marker has been seen
+	inVerse     bool        // The current block is the current verse
+	pending     []*ipc.Span // Chapter and paragraph spans waiting for their content
+	pendingType string      // Block type of the pending paragraph
+	para        *ipc.Span   // Paragraph span of the current block, which may carry attributes
+	chars       []*ipc.Span // Open character styles, innermost last
+	milestones  []*ipc.Span // Open milestones
+}
+
+func (p *parser) parse() {
+	for p.pos < len(p.toks) {
+		tok := p.toks[p.pos]
+		p.pos++
+		switch tok.kind {
+		case tokText:
+			if p.doc != nil {
+				p.text(tok.text)
+			}
+		case tokMarker:
+			p.marker(tok)
+		case tokEndMarker:
+			if p.doc != nil {
+				p.endMarker(tok)
+			}
+		case tokAttributes:
+			if p.doc != nil {
+				p.attributes(tok)
+			}
+		}
+	}
+	p.finishDocument()
+}
+
+func (p *parser) marker(tok token) {
+	if tok.marker == "id" {
+		p.startDocument()
+		return
+	}
+	if p.doc == nil {
+		// Content before \id is ignored
+		return
+	}
+	if name, end, ok := milestoneMarker(tok.marker); ok {
+		p.milestone(name, end)
+		return
+	}
+	if p.standalone() {
+		p.milestone(tok.marker, 0)
+		return
+	}
+
+	info := lookupMarker(tok.marker)
+	switch info.kind {
+	case kindHeader:
+		switch base := baseMarker(tok.marker); {
+		case !p.inBody:
+			p.header(tok.marker)
+		case base == "rem":
+			p.blockMarker(tok.marker, markerInfo{span: spanParagraph, blockType: blockRemark})
+		case base == "mt":
+			p.blockMarker(tok.marker, markerInfo{span: spanTitle, blockType: blockTitle})
+		default:
+			p.header(tok.marker)
+		}
+	case kindChapter:
+		p.chapterMarker()
+	case kindVerse:
+		p.verseMarker()
+	case kindBlock:
+		p.blockMarker(tok.marker, info)
+	case kindFlow:
+		p.closeChars()
+		p.inBody = true
+		p.pending = append(p.pending, p.newSpan(info.span, tok.marker))
+		p.pendingType = info.blockType
+	case kindNote:
+		p.note(tok.marker, info)
+	case kindCell:
+		p.inline()
+		p.addAnchor(p.newSpan(info.span, tok.marker))
+	default:
+		p.inline()
+		span := p.newSpan(info.span, tok.marker)
+		p.addAnchor(span)
+		p.chars = append(p.chars, span)
+	}
+}
+
+// startDocument starts the book named by an \id line.
+func (p *parser) startDocument() {
+	p.finishDocument()
+
+	fields := strings.Fields(p.readLine())
+	if len(fields) == 0 {
+		return
+	}
+	bookID := strings.ToUpper(fields[0])
+	if p.corpus.ID == "" {
+		p.corpus.ID = bookID
+	}
+	p.doc = &ipc.Document{
+		ID:            bookID,
+		Order:         len(p.corpus.Documents) + 1,
+		ContentBlocks: []*ipc.ContentBlock{},
+		Attributes:    map[string]string{},
+	}
+	if name, ok := bookNames[bookID]; ok {
+		p.doc.Title = name
+	}
+	if len(fields) > 1 {
+		p.doc.Attributes["id"] = strings.Join(fields[1:], " ")
+	}
+	p.corpus.Documents = append(p.corpus.Documents, p.doc)
+}
+
+// finishDocument completes the current book.
+func (p *parser) finishDocument() {
+	if p.doc == nil {
+		return
+	}
+	p.closeChars()
+	if len(p.pending) > 0 {
+		// Markers without content, such as a closing \b
+		p.startBlock(p.pendingType, p.pendingMarker())
+	}
+	p.finishBlock()
+	if len(p.titles) > 1 {
+		p.doc.Attributes["title_order"] = strings.Join(p.titles, " ")
+	}
+	if len(p.doc.Attributes) == 0 {
+		p.doc.Attributes = nil
+	}
+	p.tokenizeWords()
+
+	p.doc = nil
+	p.chapter = 0
+	p.titles = nil
+	p.inBody, p.inVerse = false, false
+	p.pending, p.para, p.chars, p.milestones = nil, nil, nil, nil
+}
+
+// header stores an identification or main title line as a document
+// attribute named after its marker.
+func (p *parser) header(marker string) {
+	value := p.readLine()
+	if prev, ok := p.doc.Attributes[marker]; ok {
+		value = prev + "\n" + value
+	}
+	p.doc.Attributes[marker] = value
+
+	switch baseMarker(marker) {
+	case "h":
+		if p.doc.Title == "" {
+			p.doc.Title = value
+		}
+	case "mt":
+		p.titles = append(p.titles, marker)
+		if p.corpus.Title == "" {
+			p.corpus.Title = value
+		}
+	}
+}
+
+// readLine consumes the text following a marker.
+func (p *parser) readLine() string {
+	var sb strings.Builder
+	for p.pos < len(p.toks) && p.toks[p.pos].kind == tokText {
+		sb.WriteString(p.toks[p.pos].text)
+		p.pos++
+	}
+	return strings.TrimSpace(normalizeSpace(sb.String()))
+}
+
+// readNumber consumes the number following \c or \v.
+func (p *parser) readNumber() string {
+	if p.pos >= len(p.toks) || p.toks[p.pos].kind != tokText {
+		return ""
+	}
+	tok := &p.toks[p.pos]
+	s := strings.TrimLeft(tok.text, " \t\r\n")
+	end := strings.IndexAny(s, " \t\r\n")
+	if end < 0 {
+		end = len(s)
+	}
+	num, rest := s[:end], s[end:]
+	if rest != "" {
+		// The space ending the number
+		rest = rest[1:]
+	}
+	if strings.TrimSpace(rest) == "" {
+		p.pos++
+	} else {
+		tok.text = rest
+	}
+	return num
+}
+
+func (p *parser) chapterMarker() {
+	p.closeChars()
+	p.inBody, p.inVerse = true, false
+
+	num := p.readNumber()
+	if m := chapterRegex.FindStringSubmatch(num); m != nil {
+		p.chapter, _ = strconv.Atoi(m[1])
+	}
+	span := p.newSpan(spanChapter, "c")
+	span.Attributes["number"] = num
+	span.Ref = &ipc.Ref{
+		Book:    p.doc.ID,
+		Chapter: p.chapter,
+		OSISID:  fmt.Sprintf("%s.%d", p.doc.ID, p.chapter),
+	}
+	p.pending = append(p.pending, span)
+}
+
+func (p *parser) verseMarker() {
+	p.inBody = true
+
+	num := p.readNumber()
+	span := p.newSpan(spanVerse, "v")
+	span.Attributes["number"] = num
+	ref := &ipc.Ref{Book: p.doc.ID, Chapter: p.chapter}
+	if m := verseNumRegex.FindStringSubmatch(num); m != nil {
+		ref.Verse, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			ref.VerseEnd, _ = strconv.Atoi(m[2])
+		}
+	}
+	if m := subVerseRegex.FindStringSubmatch(num); m != nil {
+		ref.SubVerse = m[1]
+	}
+	ref.OSISID = fmt.Sprintf("%s.%d.%d", p.doc.ID, ref.Chapter, ref.Verse)
+	span.Ref = ref
+
+	// The verse span leads the anchor so it is found first
+	p.pending = append([]*ipc.Span{span}, p.pending...)
+	p.startBlock(blockVerse, "")
+	p.inVerse = true
+}
+
+// blockMarker starts a heading, introduction, table row or other
+// paragraph that is a block of its own.
+func (p *parser) blockMarker(marker string, info markerInfo) {
+	p.closeChars()
+	p.inBody, p.inVerse = true, false
+	span := p.newSpan(info.span, marker)
+	p.pending = append(p.pending, span)
+	p.startBlock(info.blockType, marker)
+	p.para = span
+}
+
+// startBlock starts a content block whose first anchor holds the pending
+// spans.
+func (p *parser) startBlock(blockType, marker string) {
+	p.finishBlock()
+
+	p.blockSeq++
+	if blockType == "" {
+		blockType = blockParagraph
+	}
+	p.block = &ipc.ContentBlock{
+		ID:         fmt.Sprintf("cb-%d", p.blockSeq),
+		Sequence:   p.blockSeq,
+		Attributes: map[string]interface{}{"type": blockType},
+	}
+	if marker != "" {
+		p.block.Attributes["marker"] = marker
+	}
+	p.doc.ContentBlocks = append(p.doc.ContentBlocks, p.block)
+
+	p.addAnchor(p.pending...)
+	p.pending = nil
+	p.para = nil
+	p.inVerse = false
+}
+
+// finishBlock trims the text of the current block and computes its hash.
+func (p *parser) finishBlock() {
+	b := p.block
+	if b == nil {
+		return
+	}
+	b.Text = strings.TrimRight(b.Text, " ")
+	for _, a := range b.Anchors {
+		if a.CharOffset > len(b.Text) {
+			a.CharOffset = len(b.Text)
+		}
+	}
+	h := sha256.Sum256([]byte(b.Text))
+	b.Hash = hex.EncodeToString(h[:])
+	p.block = nil
+}
+
+// pendingMarker returns the marker of the last pending paragraph.
+func (p *parser) pendingMarker() string {
+	for i := len(p.pending) - 1; i >= 0; i-- {
+		if s := p.pending[i]; s.Type != spanChapter && s.Type != spanVerse {
+			m, _ := s.Attributes["marker"].(string)
+			return m
+		}
+	}
+	return ""
+}
+
+// inline makes the current block ready for text or an inline span.
+// Pending paragraph markers either continue the open verse or start a
+// paragraph block.
+func (p *parser) inline() {
+	switch {
+	case len(p.pending) > 0 && p.inVerse:
+		if p.block.Text != "" && !strings.HasSuffix(p.block.Text, " ") {
+			p.block.Text += " "
+		}
+		p.addAnchor(p.pending...)
+		p.pending = nil
+	case len(p.pending) > 0:
+		p.startBlock(p.pendingType, p.pendingMarker())
+	case p.block == nil:
+		p.startBlock(blockParagraph, "")
+	}
+}
+
+func (p *parser) text(s string) {
+	s = normalizeSpace(s)
+	if strings.TrimSpace(s) == "" {
+		// Whitespace between markers only separates words of a block
+		if p.block == nil || len(p.pending) > 0 {
+			return
+		}
+	} else {
+		p.inline()
+	}
+	if p.block.Text == "" || strings.HasSuffix(p.block.Text, " ") {
+		s = strings.TrimLeft(s, " ")
+	}
+	p.block.Text += s
+}
+
+// normalizeSpace turns line breaks and tabs into spaces and collapses runs
+// of spaces.
+func normalizeSpace(s string) string {
+	var sb strings.Builder
+	space := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+func (p *parser) newSpan(spanType string, marker string) *ipc.Span {
+	p.spanSeq++
+	return &ipc.Span{
+		ID:         fmt.Sprintf("s-%d", p.spanSeq),
+		Type:       spanType,
+		Attributes: map[string]interface{}{"marker": marker},
+	}
+}
+
+// addAnchor adds an anchor at the end of the current block text where the
+// given spans start.
+func (p *parser) addAnchor(spans ...*ipc.Span) *ipc.Anchor {
+	a := &ipc.Anchor{
+		ID:             fmt.Sprintf("a-%d-%d", p.blockSeq, len(p.block.Anchors)),
+		ContentBlockID: p.block.ID,
+		CharOffset:     len(p.block.Text),
+	}
+	if len(spans) > 0 {
+		a.Spans = spans
+	}
+	for _, s := range spans {
+		s.StartAnchorID = a.ID
+	}
+	p.block.Anchors = append(p.block.Anchors, a)
+	return a
+}
+
+// endSpan ends a span at the end of the current block text.
+func (p *parser) endSpan(span *ipc.Span) {
+	span.EndAnchorID = p.addAnchor().ID
+}
+
+func (p *parser) endMarker(tok token) {
+	for i := len(p.chars) - 1; i >= 0; i-- {
+		if p.chars[i].Attributes["marker"] != tok.marker {
+			continue
+		}
+		for j := len(p.chars) - 1; j > i; j-- {
+			p.closeChar(p.chars[j], false)
+		}
+		p.closeChar(p.chars[i], true)
+		p.chars = p.chars[:i]
+		return
+	}
+	// A closing marker without its opening marker is dropped
+}
+
+// closeChars closes the open character styles at a paragraph, chapter or
+// book boundary.
+func (p *parser) closeChars() {
+	for i := len(p.chars) - 1; i >= 0; i-- {
+		p.closeChar(p.chars[i], false)
+	}
+	p.chars = nil
+}
+
+func (p *parser) closeChar(span *ipc.Span, closed bool) {
+	p.endSpan(span)
+	if !closed {
+		span.Attributes["unclosed"] = true
+	}
+	if span.Type == spanWord {
+		p.wordAnnotations(span)
+	}
+}
+
+// attributes attaches |attributes to the innermost open character style or
+// to a paragraph such as \periph.
+func (p *parser) attributes(tok token) {
+	switch {
+	case len(p.chars) > 0:
+		setAttributes(p.chars[len(p.chars)-1], "attributes", tok.text)
+	case p.para != nil && len(p.pending) == 0:
+		setAttributes(p.para, "attributes", tok.text)
+	default:
+		p.text(tok.raw)
+	}
+}
+
+// setAttributes parses attribute source into a span attribute. The
+// attribute order is kept when there is more than one.
+func setAttributes(span *ipc.Span, key, src string) {
+	attrs := map[string]interface{}{}
+	var order []interface{}
+	matches := attrRegex.FindAllStringSubmatch(src, -1)
+	if len(matches) == 0 {
+		value := strings.TrimSpace(src)
+		if value == "" {
+			return
+		}
+		marker, _ := span.Attributes["marker"].(string)
+		name := defaultAttributes[baseMarker(marker)]
+		if name == "" {
+			name = "default"
+		}
+		attrs[name] = value
+		span.Attributes["default_attribute"] = true
+	}
+	for _, m := range matches {
+		attrs[m[1]] = m[2]
+		order = append(order, m[1])
+	}
+	span.Attributes[key] = attrs
+	if len(order) > 1 {
+		span.Attributes[key+"_order"] = order
+	}
+}
+
+// spanAttribute returns a |attribute of a span.
+func spanAttribute(span *ipc.Span, name string) string {
+	attrs, _ := span.Attributes["attributes"].(map[string]interface{})
+	v, _ := attrs[name].(string)
+	return v
+}
+
+// standalone reports whether the marker just read is a standalone
+// milestone such as \ts\*.
+func (p *parser) standalone() bool {
+	i := p.pos
+	if i < len(p.toks) && p.toks[i].kind == tokAttributes {
+		i++
+	}
+	return i < len(p.toks) && p.toks[i].kind == tokMilestoneEnd
+}
+
+// milestone handles \qt-s ... \qt-e pairs and standalone milestones. end is
+// 's', 'e' or 0 for a standalone milestone.
+func (p *parser) milestone(name string, end byte) {
+	var attrSrc string
+	if p.pos < len(p.toks) && p.toks[p.pos].kind == tokAttributes {
+		attrSrc = p.toks[p.pos].text
+		p.pos++
+	}
+	if p.pos < len(p.toks) && p.toks[p.pos].kind == tokMilestoneEnd {
+		p.pos++
+	}
+	p.inline()
+
+	if end == 'e' {
+		if start := p.openMilestone(name, attrSrc); start != nil {
+			p.endSpan(start)
+			if attrSrc != "" {
+				setAttributes(start, "end_attributes", attrSrc)
+			}
+			return
+		}
+	}
+
+	spanType := spanMilestone
+	if baseMarker(name) == "qt" {
+		spanType = spanQuotation
+	}
+	span := p.newSpan(spanType, name)
+	switch end {
+	case 's':
+		span.Attributes["milestone"] = "start"
+		p.milestones = append(p.milestones, span)
+	case 'e':
+		// An end without its start
+		span.Attributes["milestone"] = "end"
+	default:
+		span.Attributes["milestone"] = "standalone"
+	}
+	if attrSrc != "" {
+		setAttributes(span, "attributes", attrSrc)
+	}
+	p.addAnchor(span)
+}
+
+// openMilestone removes and returns the open milestone an end milestone
+// closes, matching its eid to the start's sid when both are given.
+func (p *parser) openMilestone(name, attrSrc string) *ipc.Span {
+	var eid string
+	for _, m := range attrRegex.FindAllStringSubmatch(attrSrc, -1) {
+		if m[1] == "eid" {
+			eid = m[2]
+		}
+	}
+	for i := len(p.milestones) - 1; i >= 0; i-- {
+		s := p.milestones[i]
+		if s.Attributes["marker"] != name {
+			continue
+		}
+		if sid := spanAttribute(s, "sid"); eid != "" && sid != "" && sid != eid {
+			continue
+		}
+		p.milestones = append(p.milestones[:i], p.milestones[i+1:]...)
+		return s
+	}
+	return nil
+}
+
+// note reads a footnote or cross-reference up to its closing marker. The
+// note becomes a span at its position in the text, keeping each part with
+// its marker, and a stand-off annotation with its plain text.
+func (p *parser) note(marker string, info markerInfo) {
+	p.inline()
+
+	type notePart struct {
+		marker string
+		text   strings.Builder
+		plain  strings.Builder
+		closed bool
+	}
+	parts := []*notePart{{}}
+	cur := parts[0]
+	closed := false
+
+loop:
+	for ; p.pos < len(p.toks); p.pos++ {
+		t := p.toks[p.pos]
+		switch t.kind {
+		case tokEndMarker:
+			if t.marker == marker {
+				p.pos++
+				closed = true
+				break loop
+			}
+			if !t.nested && t.marker == cur.marker {
+				cur.closed = true
+				cur = &notePart{}
+				parts = append(parts, cur)
+				continue
+			}
+			cur.text.WriteString(t.raw)
+		case tokMarker:
+			switch lookupMarker(t.marker).kind {
+			case kindNoteChar:
+				if !t.nested {
+					cur = &notePart{marker: t.marker}
+					parts = append(parts, cur)
+					continue
+				}
+			case kindChar, kindCell:
+			default:
+				if _, _, ok := milestoneMarker(t.marker); !ok && !t.nested {
+					// A paragraph, verse or chapter ends an unclosed note
+					break loop
+				}
+			}
+			cur.text.WriteString(t.raw)
+		case tokText:
+			cur.text.WriteString(t.text)
+			cur.plain.WriteString(t.text)
+		default:
+			cur.text.WriteString(t.raw)
+		}
+	}
+
+	span := p.newSpan(info.span, marker)
+	lead := strings.TrimLeft(normalizeSpace(parts[0].text.String()), " ")
+	caller, rest, _ := strings.Cut(lead, " ")
+	span.Attributes["caller"] = caller
+
+	var content []interface{}
+	var plain []string
+	if rest != "" {
+		content = append(content, map[string]interface{}{"marker": "", "text": rest})
+		plain = append(plain, rest)
+	}
+	for _, part := range parts[1:] {
+		if part.marker == "" && part.text.Len() == 0 {
+			continue
+		}
+		entry := map[string]interface{}{
+			"marker": part.marker,
+			"text":   normalizeSpace(part.text.String()),
+		}
+		if part.closed {
+			entry["closed"] = true
+		}
+		content = append(content, entry)
+
+		base := baseMarker(part.marker)
+		keep := base != "fr" && base != "xo" && base != "fv"
+		if info.span == spanCrossRef {
+			keep = base == "xt"
+		}
+		if text := strings.TrimSpace(part.plain.String()); keep && text != "" {
+			plain = append(plain, text)
+		}
+	}
+	span.Attributes["content"] = content
+	if !closed {
+		span.Attributes["unclosed"] = true
+	}
+	p.addAnchor(span)
+
+	annType := annotationFootnote
+	if info.span == spanCrossRef {
+		annType = annotationCrossRef
+	}
+	if value := strings.TrimSpace(normalizeSpace(strings.Join(plain, " "))); value != "" {
+		p.annotate(span, annType, value)
+	}
+}
+
+func (p *parser) annotate(span *ipc.Span, annType string, value interface{}) {
+	p.annSeq++
+	p.doc.Annotations = append(p.doc.Annotations, &ipc.Annotation{
+		ID:     fmt.Sprintf("ann-%d", p.annSeq),
+		SpanID: span.ID,
+		Type:   annType,
+		Value:  value,
+	})
+}
+
+// wordAnnotations adds the Strong's numbers, morphology and gloss of a
+// \w word as stand-off annotations.
+func (p *parser) wordAnnotations(span *ipc.Span) {
+	for _, num := range strongsNumbers(span) {
+		p.annotate(span, annotationStrongs, num)
+	}
+	if morph := spanAttribute(span, "x-morph"); morph != "" {
+		p.annotate(span, annotationMorphology, morph)
+	}
+	if gloss := spanAttribute(span, "gloss"); gloss != "" {
+		p.annotate(span, annotationGloss, gloss)
+	}
+}
+
+// strongsNumbers splits the strong attribute of a \w word.
+func strongsNumbers(span *ipc.Span) []string {
+	var nums []string
+	for _, n := range strings.Split(spanAttribute(span, "strong"), ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			nums = append(nums, n)
+		}
+	}
+	return nums
+}
+
+// tokenizeWords tokenizes the blocks holding \w words and gives the tokens
+// inside each word its lemma, Strong's numbers and morphology.
+func (p *parser) tokenizeWords() {
+	for _, b := range p.doc.ContentBlocks {
+		offsets := map[string]int{}
+		var words []*ipc.Span
+		for _, a := range b.Anchors {
+			offsets[a.ID] = a.CharOffset
+			for _, s := range a.Spans {
+				if s.Type == spanWord {
+					words = append(words, s)
+				}
+			}
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		b.Tokens = tokenizeText(b.Text, b.Sequence)
+		for _, w := range words {
+			start := offsets[w.StartAnchorID]
+			end, ok := offsets[w.EndAnchorID]
+			if !ok {
+				// The word ends in another block
+				end = len(b.Text)
+			}
+			for _, t := range b.Tokens {
+				if t.Type != "word" || t.StartPos < start || t.EndPos > end {
+					continue
+				}
+				t.Lemma = spanAttribute(w, "lemma")
+				t.Strongs = strongsNumbers(w)
+				t.Morphology = spanAttribute(w, "x-morph")
+			}
+		}
+	}
+}
+
+// tokenizeText splits block text into word, whitespace and punctuation
+// tokens.
+func tokenizeText(text string, seq int) []*ipc.Token {
+	var tokens []*ipc.Token
+	start := 0
+	kind := ""
+	for i := 0; i <= len(text); i++ {
+		var k string
+		if i < len(text) {
+			switch c := text[i]; {
+			case c == ' ':
+				k = "whitespace"
+			case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+				(c >= '0' && c <= '9') || c == '\'' || c >= 0x80:
+				k = "word"
+			default:
+				k = "punctuation"
+			}
+		}
+		if i > start && k != kind {
+			tokens = append(tokens, &ipc.Token{
+				ID:       fmt.Sprintf("t-%d-%d", seq, len(tokens)),
+				Type:     kind,
+				Text:     text[start:i],
+				StartPos: start,
+				EndPos:   i,
+			})
+			start = i
+		}
+		kind = k
+	}
+	return tokens
+}
@@ -77,6 +77,9 @@ func handleDetect(args map[string]interface{}) {
 		[]string{".xml"},
 		[]string{"<bible", "<book", "<verse"},
 	)
+	if result.Detected {
+		result.Specificity = ipc.SpecificityGeneric
+	}
 	ipc.MustRespond(result)
 }
 
@@ -53,6 +53,9 @@ func handleDetect(args map[string]interface{}) {
 
 	// Magic byte detection
 	result := ipc.DetectByMagicBytes(path, "zip", zipMagic)
+	if result.Detected {
+		result.Specificity = ipc.SpecificityGeneric
+	}
 	ipc.MustRespond(result)
 }
 
@@ -48,6 +48,35 @@ func BoolArg(args map[string]interface{}, name string, defaultVal bool) bool {
 	return b
 }
 
+// Options returns the options of an extract-ir or emit-native request.
+// The host has validated them against the options declared in plugin.json
+// and filled in their defaults. Returns nil if the request has none.
+func Options(args map[string]interface{}) map[string]interface{} {
+	opts, _ := args["options"].(map[string]interface{})
+	return opts
+}
+
+// OptionString extracts a string or enum option with a default value.
+func OptionString(args map[string]interface{}, name, defaultVal string) string {
+	return StringArgOr(Options(args), name, defaultVal)
+}
+
+// OptionBool extracts a bool option with a default value.
+func OptionBool(args map[string]interface{}, name string, defaultVal bool) bool {
+	return BoolArg(Options(args), name, defaultVal)
+}
+
+// OptionInt extracts an int option with a default value.
+func OptionInt(args map[string]interface{}, name string, defaultVal int) int {
+	switch v := Options(args)[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return defaultVal
+}
+
 // PathAndOutputDir extracts the common path and output_dir arguments.
 // Returns an error if either is missing.
 func PathAndOutputDir(args map[string]interface{}) (path, outputDir string, err error) {
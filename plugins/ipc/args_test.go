@@ -138,6 +138,38 @@ func TestBoolArg(t *testing.T) {
 	}
 }
 
+func TestOptionHelpers(t *testing.T) {
+	args := map[string]interface{}{
+		"path": "/test",
+		"options": map[string]interface{}{
+			"verse_style": "container",
+			"strict":      true,
+			"block_size":  float64(8192),
+		},
+	}
+
+	if got := OptionString(args, "verse_style", "milestone"); got != "container" {
+		t.Errorf("OptionString() = %q, want container", got)
+	}
+	if got := OptionString(args, "missing", "milestone"); got != "milestone" {
+		t.Errorf("OptionString(missing) = %q, want milestone", got)
+	}
+	if got := OptionBool(args, "strict", false); !got {
+		t.Error("OptionBool() = false, want true")
+	}
+	if got := OptionInt(args, "block_size", 4096); got != 8192 {
+		t.Errorf("OptionInt() = %d, want 8192", got)
+	}
+
+	noOpts := map[string]interface{}{"path": "/test"}
+	if Options(noOpts) != nil {
+		t.Error("Options() should be nil without options")
+	}
+	if got := OptionInt(noOpts, "block_size", 4096); got != 4096 {
+		t.Errorf("OptionInt(no options) = %d, want 4096", got)
+	}
+}
+
 func TestPathAndOutputDir(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -94,15 +94,17 @@ func CheckMagicBytes(path string, magic []byte) bool {
 }
 
 // DetectByExtension performs standard extension-based detection.
-// Returns a DetectResult with appropriate format and reason.
+// Returns a DetectResult with appropriate format and reason, and
+// ExtensionConfidence.
 // Extensions should be provided with dots (e.g., ".xml", ".html").
 func DetectByExtension(path, formatName string, extensions ...string) *DetectResult {
 	if CheckExtension(path, extensions...) {
 		extList := strings.Join(extensions, ", ")
 		return &DetectResult{
-			Detected: true,
-			Format:   formatName,
-			Reason:   fmt.Sprintf("%s file extension detected (%s)", formatName, extList),
+			Detected:   true,
+			Format:     formatName,
+			Reason:     fmt.Sprintf("%s file extension detected (%s)", formatName, extList),
+			Confidence: ExtensionConfidence,
 		}
 	}
 	return &DetectResult{
@@ -192,9 +194,10 @@ func StandardDetect(path, formatName string, extensions []string, contentPattern
 
 	// No content validation needed, extension match is sufficient
 	return &DetectResult{
-		Detected: true,
-		Format:   formatName,
-		Reason:   fmt.Sprintf("%s file extension detected", formatName),
+		Detected:   true,
+		Format:     formatName,
+		Reason:     fmt.Sprintf("%s file extension detected", formatName),
+		Confidence: ExtensionConfidence,
 	}
 }
 
@@ -27,6 +27,7 @@ type Document struct {
 	Title         string            `json:"title,omitempty"`
 	Order         int               `json:"order"`
 	ContentBlocks []*ContentBlock   `json:"content_blocks,omitempty"`
+	Annotations   []*Annotation     `json:"annotations,omitempty"`
 	Attributes    map[string]string `json:"attributes,omitempty"`
 }
 
@@ -43,18 +44,25 @@ type ContentBlock struct {
 
 // Token represents a tokenized word or morpheme.
 type Token struct {
-	ID       string `json:"id"`
-	Type     string `json:"type"`
-	Text     string `json:"text"`
-	StartPos int    `json:"start_pos"`
-	EndPos   int    `json:"end_pos"`
+	ID         string   `json:"id"`
+	Type       string   `json:"type"`
+	Text       string   `json:"text"`
+	StartPos   int      `json:"start_pos"`
+	EndPos     int      `json:"end_pos"`
+	Lemma      string   `json:"lemma,omitempty"`
+	Strongs    []string `json:"strongs,omitempty"`
+	Morphology string   `json:"morphology,omitempty"`
 }
 
 // Anchor represents a position in the text where spans can attach.
+// ContentBlockID and CharOffset match the core IR anchor; Position is the
+// offset used by older plugins.
 type Anchor struct {
-	ID       string  `json:"id"`
-	Position int     `json:"position"`
-	Spans    []*Span `json:"spans,omitempty"`
+	ID             string  `json:"id"`
+	ContentBlockID string  `json:"content_block_id,omitempty"`
+	CharOffset     int     `json:"char_offset,omitempty"`
+	Position       int     `json:"position,omitempty"`
+	Spans          []*Span `json:"spans,omitempty"`
 }
 
 // Span represents markup that spans from one anchor to another.
@@ -67,6 +75,17 @@ type Span struct {
 	Attributes    map[string]interface{} `json:"attributes,omitempty"`
 }
 
+// Annotation represents data attached to a span, such as a Strong's
+// number or the text of a footnote.
+type Annotation struct {
+	ID         string      `json:"id"`
+	SpanID     string      `json:"span_id"`
+	Type       string      `json:"type"`
+	Value      interface{} `json:"value"`
+	Confidence float64     `json:"confidence,omitempty"`
+	Source     string      `json:"source,omitempty"`
+}
+
 // Ref represents a biblical or textual reference.
 type Ref struct {
 	Book     string `json:"book"`
@@ -0,0 +1,84 @@
+package ipc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Kinds of interim messages.
+const (
+	EventProgress = "progress"
+	EventLog      = "log"
+)
+
+// ProgressEvent is an interim message sent to the host before the final
+// response: a progress update or a log line.
+type ProgressEvent struct {
+	Kind    string `json:"kind,omitempty"`    // EventProgress or EventLog
+	Stage   string `json:"stage,omitempty"`   // Short name of the current step
+	Message string `json:"message,omitempty"` // Human-readable status
+	Level   string `json:"level,omitempty"`   // Log level: debug, info, warn, error
+	Done    int64  `json:"done,omitempty"`
+	Total   int64  `json:"total,omitempty"`
+}
+
+// ProgressParams are the parameters of a $/progress notification.
+type ProgressParams struct {
+	ID int64 `json:"id"`
+	ProgressEvent
+}
+
+type reporterKey struct{}
+
+// reporter sends the interim messages of one request.
+type reporter func(ProgressEvent)
+
+func withReporter(ctx context.Context, r reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, r)
+}
+
+// Report sends an interim message for the request handled with ctx. In a
+// session it is a $/progress notification; in one-shot mode it is a line
+// written before the response. It does nothing for contexts not passed to
+// a HandlerFunc.
+func Report(ctx context.Context, ev ProgressEvent) {
+	if r, ok := ctx.Value(reporterKey{}).(reporter); ok {
+		if ev.Kind == "" {
+			ev.Kind = EventProgress
+		}
+		r(ev)
+	}
+}
+
+// Progress reports that done of total units of the current stage are
+// complete. total may be 0 when it is unknown.
+func Progress(ctx context.Context, stage, message string, done, total int64) {
+	Report(ctx, ProgressEvent{Kind: EventProgress, Stage: stage, Message: message, Done: done, Total: total})
+}
+
+// Log sends a log line to the host.
+func Log(ctx context.Context, level, format string, args ...interface{}) {
+	Report(ctx, ProgressEvent{Kind: EventLog, Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+// stdoutMu serializes interim messages written from several goroutines.
+var stdoutMu sync.Mutex
+
+// RespondProgress writes an interim message to stdout. Plugins that read
+// their request with ReadRequest may call it any number of times before
+// Respond.
+func RespondProgress(ev ProgressEvent) error {
+	if ev.Kind == "" {
+		ev.Kind = EventProgress
+	}
+	resp := Response{Status: ev.Kind, Event: &ev}
+	stdoutMu.Lock()
+	defer stdoutMu.Unlock()
+	if err := json.NewEncoder(os.Stdout).Encode(resp); err != nil {
+		return fmt.Errorf("failed to encode progress: %w", err)
+	}
+	return nil
+}
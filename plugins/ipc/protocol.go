@@ -19,15 +19,35 @@ type Response struct {
 	Status string      `json:"status"`
 	Result interface{} `json:"result,omitempty"`
 	Error  string      `json:"error,omitempty"`
+
+	// Event is the content of an interim message, whose status is
+	// EventProgress or EventLog.
+	Event *ProgressEvent `json:"event,omitempty"`
 }
 
-// DetectResult is the result of a detect command.
+// DetectResult is the result of a detect command. Confidence and
+// Specificity rank the results of several plugins detecting the same
+// input; a plugin that reports neither is taken to be certain of a
+// specific format.
 type DetectResult struct {
-	Detected bool   `json:"detected"`
-	Format   string `json:"format,omitempty"`
-	Reason   string `json:"reason,omitempty"`
+	Detected    bool    `json:"detected"`
+	Format      string  `json:"format,omitempty"`
+	Reason      string  `json:"reason,omitempty"`
+	Confidence  float64 `json:"confidence,omitempty"`  // 0 to 1
+	Specificity int     `json:"specificity,omitempty"` // One of the Specificity constants
 }
 
+// Detection specificity, from the least to the most specific.
+const (
+	SpecificityAny     = 1 // Matches any file or directory
+	SpecificityGeneric = 2 // A generic syntax or container, such as XML, zip or tar
+	SpecificityFormat  = 3 // A specific format
+)
+
+// ExtensionConfidence is the confidence of a detection based on the file
+// extension alone.
+const ExtensionConfidence = 0.5
+
 // IngestResult is the result of an ingest command.
 type IngestResult struct {
 	ArtifactID string            `json:"artifact_id"`
@@ -0,0 +1,296 @@
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ServeFlag is the argument the host starts a plugin with to open a
+// persistent JSON-RPC session instead of sending one request.
+const ServeFlag = "--jsonrpc"
+
+// ProtocolVersion is the version of the session protocol.
+const ProtocolVersion = 1
+
+// JSON-RPC error codes.
+const (
+	RPCParseError       = -32700
+	RPCInvalidRequest   = -32600
+	RPCMethodNotFound   = -32601
+	RPCInvalidParams    = -32602
+	RPCInternalError    = -32603
+	RPCRequestCancelled = -32800
+)
+
+// RPCMessage is a JSON-RPC 2.0 request, response or notification. Each
+// message is one line of JSON.
+type RPCMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// RPCError is the error of a JSON-RPC response.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// ServerInfo identifies a plugin in the initialize handshake.
+type ServerInfo struct {
+	PluginID string
+	Version  string
+	Methods  []string // Commands the plugin handles
+}
+
+// InitializeResult is the plugin's answer to the initialize request.
+type InitializeResult struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	PluginID        string   `json:"plugin_id"`
+	Version         string   `json:"version"`
+	Methods         []string `json:"methods,omitempty"`
+}
+
+// CancelParams are the parameters of a $/cancel notification.
+type CancelParams struct {
+	ID int64 `json:"id"`
+}
+
+// HandlerFunc handles one command. The context is cancelled when the host
+// cancels the request or the session ends. A returned error is sent as an
+// error response.
+type HandlerFunc func(ctx context.Context, req *Request) (interface{}, error)
+
+// Main runs a plugin. Started with ServeFlag it serves a session on stdin
+// and stdout; otherwise it handles the single request on stdin, so the
+// plugin also works with hosts that start a process per request. In both
+// modes the handler reports interim messages with Report, Progress and
+// Log.
+func Main(info ServerInfo, handler HandlerFunc) {
+	if len(os.Args) > 1 && os.Args[1] == ServeFlag {
+		if err := Serve(os.Stdin, os.Stdout, info, handler); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", info.PluginID, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	req, err := ReadRequest()
+	if err != nil {
+		RespondErrorfAndExit("failed to decode request: %v", err)
+	}
+	ctx := withReporter(context.Background(), func(ev ProgressEvent) {
+		RespondProgress(ev)
+	})
+	result, err := handler(ctx, req)
+	if err != nil {
+		RespondError(err.Error())
+		return
+	}
+	MustRespond(result)
+}
+
+// Serve runs a session: it answers the initialize handshake, runs each
+// request concurrently with handler, cancels requests named by $/cancel
+// and, on shutdown, waits for running requests before answering. It
+// returns on the exit notification or when r ends.
+func Serve(r io.Reader, w io.Writer, info ServerInfo, handler HandlerFunc) error {
+	s := &server{
+		w:       w,
+		info:    info,
+		handler: handler,
+		running: make(map[int64]context.CancelFunc),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		s.wg.Wait()
+	}()
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if s.handle(ctx, line) {
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read request: %w", err)
+		}
+	}
+}
+
+// server is the state of a session.
+type server struct {
+	w        io.Writer
+	info     ServerInfo
+	handler  HandlerFunc
+	writeMu  sync.Mutex
+	mu       sync.Mutex
+	running  map[int64]context.CancelFunc
+	wg       sync.WaitGroup
+	shutdown bool
+}
+
+// handle processes one message and reports whether the session ends.
+func (s *server) handle(ctx context.Context, line []byte) bool {
+	var msg RPCMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		s.respondError(nil, RPCParseError, "invalid JSON: "+err.Error())
+		return false
+	}
+
+	switch msg.Method {
+	case "initialize":
+		s.respond(msg.ID, InitializeResult{
+			ProtocolVersion: ProtocolVersion,
+			PluginID:        s.info.PluginID,
+			Version:         s.info.Version,
+			Methods:         s.info.Methods,
+		})
+	case "$/cancel":
+		var params CancelParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			s.cancel(params.ID)
+		}
+	case "shutdown":
+		s.mu.Lock()
+		s.shutdown = true
+		s.mu.Unlock()
+		s.wg.Wait()
+		s.respond(msg.ID, nil)
+	case "exit":
+		return true
+	case "":
+		s.respondError(msg.ID, RPCInvalidRequest, "missing method")
+	default:
+		if msg.ID == nil {
+			return false // Unknown notifications are ignored
+		}
+		s.start(ctx, msg)
+	}
+	return false
+}
+
+// start runs a command request in its own goroutine.
+func (s *server) start(ctx context.Context, msg RPCMessage) {
+	id := *msg.ID
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		s.respondError(msg.ID, RPCInvalidRequest, "session is shutting down")
+		return
+	}
+	if _, ok := s.running[id]; ok {
+		s.mu.Unlock()
+		s.respondError(msg.ID, RPCInvalidRequest, fmt.Sprintf("request %d is already running", id))
+		return
+	}
+	reqCtx, cancel := context.WithCancel(ctx)
+	reqCtx = withReporter(reqCtx, func(ev ProgressEvent) {
+		s.progress(reqCtx, id, ev)
+	})
+	s.running[id] = cancel
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		resp := s.run(reqCtx, msg)
+
+		// A cancelled request has been answered already
+		s.mu.Lock()
+		_, ok := s.running[id]
+		delete(s.running, id)
+		s.mu.Unlock()
+		if ok {
+			s.respond(msg.ID, resp)
+		}
+	}()
+}
+
+// run calls the handler, turning errors and panics into error responses.
+func (s *server) run(ctx context.Context, msg RPCMessage) (resp Response) {
+	defer func() {
+		if r := recover(); r != nil {
+			resp = Response{Status: "error", Error: fmt.Sprintf("%s panicked: %v", msg.Method, r)}
+		}
+	}()
+	req := &Request{Command: msg.Method}
+	if len(msg.Params) > 0 && string(msg.Params) != "null" {
+		if err := json.Unmarshal(msg.Params, &req.Args); err != nil {
+			return Response{Status: "error", Error: "invalid params: " + err.Error()}
+		}
+	}
+	result, err := s.handler(ctx, req)
+	if err != nil {
+		return Response{Status: "error", Error: err.Error()}
+	}
+	return Response{Status: "ok", Result: result}
+}
+
+// cancel cancels a running request and answers it as cancelled.
+func (s *server) cancel(id int64) {
+	s.mu.Lock()
+	cancel, ok := s.running[id]
+	delete(s.running, id)
+	s.mu.Unlock()
+	if ok {
+		cancel()
+		s.respondError(&id, RPCRequestCancelled, "request cancelled")
+	}
+}
+
+// progress sends a $/progress notification for a running request.
+// Cancelled requests report nothing.
+func (s *server) progress(ctx context.Context, id int64, ev ProgressEvent) {
+	if ctx.Err() != nil {
+		return
+	}
+	data, err := json.Marshal(ProgressParams{ID: id, ProgressEvent: ev})
+	if err != nil {
+		return
+	}
+	s.write(RPCMessage{JSONRPC: "2.0", Method: "$/progress", Params: data})
+}
+
+func (s *server) respond(id *int64, result interface{}) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		s.respondError(id, RPCInternalError, "failed to encode result: "+err.Error())
+		return
+	}
+	s.write(RPCMessage{JSONRPC: "2.0", ID: id, Result: data})
+}
+
+func (s *server) respondError(id *int64, code int, message string) {
+	s.write(RPCMessage{JSONRPC: "2.0", ID: id, Error: &RPCError{Code: code, Message: message}})
+}
+
+func (s *server) write(msg RPCMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.w.Write(append(data, '\n'))
+}
@@ -0,0 +1,176 @@
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+// session drives Serve over pipes.
+type session struct {
+	t   *testing.T
+	in  *io.PipeWriter
+	out *bufio.Reader
+	end chan error
+}
+
+func startSession(t *testing.T, handler HandlerFunc) *session {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := &session{t: t, in: inW, out: bufio.NewReader(outR), end: make(chan error, 1)}
+	go func() {
+		s.end <- Serve(inR, outW, ServerInfo{PluginID: "test.plugin", Version: "1.0.0", Methods: []string{"echo"}}, handler)
+		outW.Close()
+	}()
+	return s
+}
+
+func (s *session) send(line string) {
+	s.t.Helper()
+	if _, err := io.WriteString(s.in, line+"\n"); err != nil {
+		s.t.Fatalf("write failed: %v", err)
+	}
+}
+
+func (s *session) read() RPCMessage {
+	s.t.Helper()
+	line, err := s.out.ReadBytes('\n')
+	if err != nil {
+		s.t.Fatalf("read failed: %v", err)
+	}
+	var msg RPCMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		s.t.Fatalf("invalid response %q: %v", line, err)
+	}
+	return msg
+}
+
+func TestServeSession(t *testing.T) {
+	release := make(chan struct{})
+	s := startSession(t, func(ctx context.Context, req *Request) (interface{}, error) {
+		switch req.Command {
+		case "block":
+			select {
+			case <-release:
+				return "released", nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		case "echo":
+			return req.Args, nil
+		}
+		panic("unexpected command " + req.Command)
+	})
+
+	s.send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocol_version":1}}`)
+	msg := s.read()
+	var info InitializeResult
+	json.Unmarshal(msg.Result, &info)
+	if *msg.ID != 1 || info.ProtocolVersion != ProtocolVersion || info.PluginID != "test.plugin" {
+		t.Errorf("unexpected initialize response %+v", msg)
+	}
+
+	// A blocked request does not hold up later ones
+	s.send(`{"jsonrpc":"2.0","id":2,"method":"block"}`)
+	s.send(`{"jsonrpc":"2.0","id":3,"method":"echo","params":{"x":1}}`)
+	msg = s.read()
+	if *msg.ID != 3 || string(msg.Result) != `{"status":"ok","result":{"x":1}}` {
+		t.Errorf("unexpected echo response %s", msg.Result)
+	}
+
+	// Cancelling answers the request at once
+	s.send(`{"jsonrpc":"2.0","method":"$/cancel","params":{"id":2}}`)
+	msg = s.read()
+	if *msg.ID != 2 || msg.Error == nil || msg.Error.Code != RPCRequestCancelled {
+		t.Errorf("unexpected cancel response %+v", msg)
+	}
+
+	// Panics become error responses
+	s.send(`{"jsonrpc":"2.0","id":4,"method":"boom"}`)
+	msg = s.read()
+	var resp Response
+	json.Unmarshal(msg.Result, &resp)
+	if resp.Status != "error" {
+		t.Errorf("expected an error response, got %s", msg.Result)
+	}
+
+	s.send(`not json`)
+	if msg = s.read(); msg.Error == nil || msg.Error.Code != RPCParseError {
+		t.Errorf("expected a parse error, got %+v", msg)
+	}
+
+	s.send(`{"jsonrpc":"2.0","id":5,"method":"shutdown"}`)
+	if msg = s.read(); *msg.ID != 5 || string(msg.Result) != "null" {
+		t.Errorf("unexpected shutdown response %+v", msg)
+	}
+	s.send(`{"jsonrpc":"2.0","method":"exit"}`)
+	select {
+	case err := <-s.end:
+		if err != nil {
+			t.Errorf("Serve returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after exit")
+	}
+	close(release)
+}
+
+func TestServeShutdownWaitsForRequests(t *testing.T) {
+	release := make(chan struct{})
+	s := startSession(t, func(ctx context.Context, req *Request) (interface{}, error) {
+		<-release
+		return "done", nil
+	})
+
+	s.send(`{"jsonrpc":"2.0","id":1,"method":"work"}`)
+	s.send(`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`)
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	// The running request is answered before the shutdown
+	if msg := s.read(); *msg.ID != 1 {
+		t.Errorf("expected the request response first, got %+v", msg)
+	}
+	if msg := s.read(); *msg.ID != 2 {
+		t.Errorf("expected the shutdown response, got %+v", msg)
+	}
+	s.in.Close()
+	if err := <-s.end; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+}
+
+func TestServeProgress(t *testing.T) {
+	s := startSession(t, func(ctx context.Context, req *Request) (interface{}, error) {
+		Progress(ctx, "parse", "Parsing", 1, 2)
+		Log(ctx, "warn", "skipped %d markers", 3)
+		return "done", nil
+	})
+
+	s.send(`{"jsonrpc":"2.0","id":7,"method":"convert"}`)
+	msg := s.read()
+	var params ProgressParams
+	json.Unmarshal(msg.Params, &params)
+	if msg.Method != "$/progress" || msg.ID != nil || params.ID != 7 || params.Kind != EventProgress || params.Done != 1 || params.Total != 2 {
+		t.Errorf("unexpected progress notification %+v %+v", msg, params)
+	}
+	msg = s.read()
+	json.Unmarshal(msg.Params, &params)
+	if msg.Method != "$/progress" || params.Kind != EventLog || params.Level != "warn" || params.Message != "skipped 3 markers" {
+		t.Errorf("unexpected log notification %+v %+v", msg, params)
+	}
+	msg = s.read()
+	if msg.ID == nil || *msg.ID != 7 || string(msg.Result) != `{"status":"ok","result":"done"}` {
+		t.Errorf("unexpected response %+v", msg)
+	}
+
+	// Reporting outside a request does nothing
+	Progress(context.Background(), "idle", "", 0, 0)
+	s.in.Close()
+	if err := <-s.end; err != nil {
+		t.Errorf("Serve returned %v", err)
+	}
+}